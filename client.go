@@ -2,6 +2,7 @@ package quic
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -20,7 +21,15 @@ import (
 type client struct {
 	mutex sync.Mutex
 
-	conn     connection
+	conn connection
+	// the net.PacketConn that is currently used by the session
+	pconn net.PacketConn
+	// If the client is created with DialAddr, we create a packet conn.
+	// If it is started with Dial, we take a packet conn as a parameter.
+	createdPacketConn bool
+	// the packetHandlers route the packets received on the pconn to the client
+	packetHandlers packetHandlerManager
+
 	hostname string
 
 	versionNegotiationChan           chan struct{} // the versionNegotiationChan is closed as soon as the server accepted the suggested version
@@ -33,22 +42,65 @@ type client struct {
 	tls     handshake.MintTLS // only used when using TLS
 
 	connectionID protocol.ConnectionID
+	// additional connection IDs issued by the server
+	// The connIDsMutex also protects the pconn, the createdPacketConn and the packetHandlers.
+	// It is used instead of the mutex, since the session uses it when adding connection IDs and when migrating.
+	connIDsMutex sync.Mutex
+	connIDs      map[protocol.ConnectionID]struct{}
 
 	initialVersion protocol.VersionNumber
 	version        protocol.VersionNumber
 
+	// use0RTT is set when dialing with DialEarly.
+	// The session is then returned as soon as it can be used, before the handshake completes.
+	use0RTT bool
+
 	session packetHandler
 }
 
+var _ sessionRunner = &client{}
+var _ packetReceiver = &client{}
+
 var (
 	// make it possible to mock connection ID generation in the tests
 	generateConnectionID         = utils.GenerateConnectionID
 	errCloseSessionForNewVersion = errors.New("closing session in order to recreate it with a new version")
 )
 
+// Err0RTTRejected is returned when using an EarlySession that was closed before the handshake completed,
+// because the client had to start a new session (e.g. after a version negotiation).
+// The data sent on the EarlySession was lost, and needs to be resent on a new session.
+var Err0RTTRejected = errors.New("0-RTT rejected")
+
 // DialAddr establishes a new QUIC connection to a server.
 // The hostname for SNI is taken from the given address.
 func DialAddr(addr string, tlsConf *tls.Config, config *Config) (Session, error) {
+	return DialAddrContext(context.Background(), addr, tlsConf, config)
+}
+
+// DialAddrContext establishes a new QUIC connection to a server using the provided context.
+// If the context is canceled before the handshake completes, the session is closed,
+// and the context's error is returned.
+// The hostname for SNI is taken from the given address.
+func DialAddrContext(ctx context.Context, addr string, tlsConf *tls.Config, config *Config) (Session, error) {
+	return dialAddr(ctx, addr, tlsConf, config, false)
+}
+
+// DialAddrEarly establishes a new QUIC connection to a server.
+// It returns the session as soon as it can be used, before the handshake completes.
+// The hostname for SNI is taken from the given address.
+func DialAddrEarly(addr string, tlsConf *tls.Config, config *Config) (EarlySession, error) {
+	return DialAddrEarlyContext(context.Background(), addr, tlsConf, config)
+}
+
+// DialAddrEarlyContext establishes a new QUIC connection to a server using the provided context.
+// It returns the session as soon as it can be used, before the handshake completes.
+// See DialAddrContext for details about the context.
+func DialAddrEarlyContext(ctx context.Context, addr string, tlsConf *tls.Config, config *Config) (EarlySession, error) {
+	return dialAddr(ctx, addr, tlsConf, config, true)
+}
+
+func dialAddr(ctx context.Context, addr string, tlsConf *tls.Config, config *Config, use0RTT bool) (EarlySession, error) {
 	udpAddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
 		return nil, err
@@ -57,10 +109,13 @@ func DialAddr(addr string, tlsConf *tls.Config, config *Config) (Session, error)
 	if err != nil {
 		return nil, err
 	}
-	return Dial(udpConn, udpAddr, addr, tlsConf, config)
+	return dial(ctx, udpConn, udpAddr, addr, tlsConf, config, use0RTT, true)
 }
 
 // Dial establishes a new QUIC connection to a server using a net.PacketConn.
+// The same net.PacketConn can be used for multiple calls to Dial and Listen,
+// QUIC connection IDs are used for demultiplexing the different connections.
+// The net.PacketConn is not closed when the session is closed.
 // The host parameter is used for SNI.
 func Dial(
 	pconn net.PacketConn,
@@ -69,6 +124,67 @@ func Dial(
 	tlsConf *tls.Config,
 	config *Config,
 ) (Session, error) {
+	return DialContext(context.Background(), pconn, remoteAddr, host, tlsConf, config)
+}
+
+// DialContext establishes a new QUIC connection to a server using a net.PacketConn using the provided context.
+// If the context is canceled before the handshake completes, the session is closed,
+// and the context's error is returned.
+// See Dial for details about the net.PacketConn.
+func DialContext(
+	ctx context.Context,
+	pconn net.PacketConn,
+	remoteAddr net.Addr,
+	host string,
+	tlsConf *tls.Config,
+	config *Config,
+) (Session, error) {
+	return dial(ctx, pconn, remoteAddr, host, tlsConf, config, false, false)
+}
+
+// DialEarly establishes a new QUIC connection to a server using a net.PacketConn.
+// It returns the session as soon as it can be used, before the handshake completes.
+// If the client can resume a previous session with the server (see Config.ClientSessionCache),
+// this is right away, and data is sent as 0-RTT data.
+// Otherwise, the session is returned when the handshake completes.
+// The host parameter is used for SNI.
+func DialEarly(
+	pconn net.PacketConn,
+	remoteAddr net.Addr,
+	host string,
+	tlsConf *tls.Config,
+	config *Config,
+) (EarlySession, error) {
+	return DialEarlyContext(context.Background(), pconn, remoteAddr, host, tlsConf, config)
+}
+
+// DialEarlyContext establishes a new QUIC connection to a server using a net.PacketConn using the provided context.
+// It returns the session as soon as it can be used, before the handshake completes.
+// See DialEarly and DialContext for details.
+func DialEarlyContext(
+	ctx context.Context,
+	pconn net.PacketConn,
+	remoteAddr net.Addr,
+	host string,
+	tlsConf *tls.Config,
+	config *Config,
+) (EarlySession, error) {
+	return dial(ctx, pconn, remoteAddr, host, tlsConf, config, true, false)
+}
+
+func dial(
+	ctx context.Context,
+	pconn net.PacketConn,
+	remoteAddr net.Addr,
+	host string,
+	tlsConf *tls.Config,
+	config *Config,
+	use0RTT bool,
+	createdPacketConn bool,
+) (EarlySession, error) {
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
 	connID, err := generateConnectionID()
 	if err != nil {
 		return nil, err
@@ -85,28 +201,54 @@ func Dial(
 		}
 	}
 
-	clientConfig := populateClientConfig(config)
+	clientConfig := populateClientConfig(config, createdPacketConn)
 	c := &client{
-		conn:                   &conn{pconn: pconn, currentAddr: remoteAddr},
+		pconn:                  pconn,
+		createdPacketConn:      createdPacketConn,
+		packetHandlers:         getMultiplexer().AddConn(pconn),
 		connectionID:           connID,
 		hostname:               hostname,
 		tlsConf:                tlsConf,
 		config:                 clientConfig,
 		version:                clientConfig.Versions[0],
 		versionNegotiationChan: make(chan struct{}),
+		use0RTT:                use0RTT,
+	}
+	c.conn = &clientConn{
+		connection: newConn(pconn, remoteAddr),
+		client:     c,
+	}
+	if c.config.RequestConnectionIDOmission {
+		// Packets without a connection ID are passed to the client as unknown packets.
+		// This only works since the client created the net.PacketConn, and doesn't share it.
+		if err := c.packetHandlers.SetUnknownPacketHandler(c); err != nil {
+			return nil, err
+		}
 	}
 
 	utils.Infof("Starting new connection to %s (%s -> %s), connectionID %x, version %s", hostname, c.conn.LocalAddr().String(), c.conn.RemoteAddr().String(), c.connectionID, c.version)
 
-	if err := c.dial(); err != nil {
+	if err := c.dial(ctx); err != nil {
 		return nil, err
 	}
 	return c.session, nil
 }
 
+// validateConfig checks the values of a quic.Config that can't be replaced by a default value.
+// It is used by the client and the server, and may be called with nil.
+func validateConfig(config *Config) error {
+	if config == nil {
+		return nil
+	}
+	if config.MaxPacketSize != 0 && config.MaxPacketSize < protocol.InitialPacketSize {
+		return fmt.Errorf("MaxPacketSize too small (%d bytes, minimum %d bytes)", config.MaxPacketSize, protocol.InitialPacketSize)
+	}
+	return nil
+}
+
 // populateClientConfig populates fields in the quic.Config with their default values, if none are set
 // it may be called with nil
-func populateClientConfig(config *Config) *Config {
+func populateClientConfig(config *Config, createdPacketConn bool) *Config {
 	if config == nil {
 		config = &Config{}
 	}
@@ -145,41 +287,73 @@ func populateClientConfig(config *Config) *Config {
 		maxIncomingUniStreams = 0
 	}
 
+	congestionControl := config.CongestionControl
+	if congestionControl == nil {
+		congestionControl = CongestionControlCubic
+	}
+	keyUpdateInterval := config.KeyUpdateInterval
+	if keyUpdateInterval == 0 {
+		keyUpdateInterval = protocol.DefaultKeyUpdateInterval
+	}
+	maxPacketSize := config.MaxPacketSize
+	if maxPacketSize == 0 {
+		maxPacketSize = protocol.DefaultMaxPacketSize
+	}
+	maxPacketSize = utils.MinByteCount(maxPacketSize, protocol.MaxMaxPacketSize)
+	maxAckDelay := config.MaxAckDelay
+	if maxAckDelay == 0 {
+		maxAckDelay = protocol.AckSendDelay
+	}
+	maxAckDelay = utils.MinDuration(maxAckDelay, protocol.MaxMaxAckDelay)
+	maxAckDelay = utils.MaxDuration(maxAckDelay, protocol.MinAckDelay)
+	packetsBeforeAck := config.RetransmittablePacketsBeforeAck
+	if packetsBeforeAck <= 0 {
+		packetsBeforeAck = protocol.RetransmittablePacketsBeforeAck
+	}
+
 	return &Config{
 		Versions:                              versions,
 		HandshakeTimeout:                      handshakeTimeout,
 		IdleTimeout:                           idleTimeout,
-		RequestConnectionIDOmission:           config.RequestConnectionIDOmission,
+		RequestConnectionIDOmission:           config.RequestConnectionIDOmission && createdPacketConn,
 		MaxReceiveStreamFlowControlWindow:     maxReceiveStreamFlowControlWindow,
 		MaxReceiveConnectionFlowControlWindow: maxReceiveConnectionFlowControlWindow,
 		MaxIncomingStreams:                    maxIncomingStreams,
 		MaxIncomingUniStreams:                 maxIncomingUniStreams,
 		KeepAlive:                             config.KeepAlive,
+		EnableDatagrams:                       config.EnableDatagrams,
+		Tracer:                                config.Tracer,
+		GetLogWriter:                          config.GetLogWriter,
+		CongestionControl:                     congestionControl,
+		ClientSessionCache:                    config.ClientSessionCache,
+		KeyUpdateInterval:                     keyUpdateInterval,
+		MaxPacketSize:                         maxPacketSize,
+		MaxAckDelay:                           maxAckDelay,
+		RetransmittablePacketsBeforeAck:       packetsBeforeAck,
 	}
 }
 
-func (c *client) dial() error {
+func (c *client) dial(ctx context.Context) error {
 	var err error
 	if c.version.UsesTLS() {
-		err = c.dialTLS()
+		err = c.dialTLS(ctx)
 	} else {
-		err = c.dialGQUIC()
+		err = c.dialGQUIC(ctx)
 	}
 	if err == errCloseSessionForNewVersion {
-		return c.dial()
+		return c.dial(ctx)
 	}
 	return err
 }
 
-func (c *client) dialGQUIC() error {
+func (c *client) dialGQUIC(ctx context.Context) error {
 	if err := c.createNewGQUICSession(); err != nil {
 		return err
 	}
-	go c.listen()
-	return c.establishSecureConnection()
+	return c.establishSecureConnection(ctx)
 }
 
-func (c *client) dialTLS() error {
+func (c *client) dialTLS(ctx context.Context) error {
 	params := &handshake.TransportParameters{
 		StreamFlowControlWindow:     protocol.ReceiveStreamFlowControlWindow,
 		ConnectionFlowControlWindow: protocol.ReceiveConnectionFlowControlWindow,
@@ -187,6 +361,9 @@ func (c *client) dialTLS() error {
 		OmitConnectionID:            c.config.RequestConnectionIDOmission,
 		MaxBidiStreamID:             protocol.MaxBidiStreamID(c.config.MaxIncomingStreams, protocol.PerspectiveClient),
 		MaxUniStreamID:              protocol.MaxUniStreamID(c.config.MaxIncomingUniStreams, protocol.PerspectiveClient),
+		MaxDatagramFrameSize:        maxDatagramFrameSize(c.config),
+		MaxAckDelay:                 c.config.MaxAckDelay,
+		MinAckDelay:                 protocol.MinAckDelay,
 	}
 	csc := handshake.NewCryptoStreamConn(nil)
 	extHandler := handshake.NewExtensionHandlerClient(params, c.initialVersion, c.config.Versions, c.version)
@@ -196,21 +373,23 @@ func (c *client) dialTLS() error {
 	}
 	mintConf.ExtensionHandler = extHandler
 	mintConf.ServerName = c.hostname
+	if c.config.ClientSessionCache != nil {
+		mintConf.PSKs = handshake.NewClientPSKCache(c.config.ClientSessionCache, clientSessionCacheKey(c.hostname, c.conn.RemoteAddr()), extHandler)
+	}
 	c.tls = newMintController(csc, mintConf, protocol.PerspectiveClient)
 
-	if err := c.createNewTLSSession(extHandler.GetPeerParams(), c.version); err != nil {
+	if err := c.createNewTLSSession(extHandler, c.version); err != nil {
 		return err
 	}
-	go c.listen()
-	if err := c.establishSecureConnection(); err != nil {
+	if err := c.establishSecureConnection(ctx); err != nil {
 		if err != handshake.ErrCloseSessionForRetry {
 			return err
 		}
 		utils.Infof("Received a Retry packet. Recreating session.")
-		if err := c.createNewTLSSession(extHandler.GetPeerParams(), c.version); err != nil {
+		if err := c.createNewTLSSession(extHandler, c.version); err != nil {
 			return err
 		}
-		if err := c.establishSecureConnection(); err != nil {
+		if err := c.establishSecureConnection(ctx); err != nil {
 			return err
 		}
 	}
@@ -222,27 +401,62 @@ func (c *client) dialTLS() error {
 // - errCloseSessionForNewVersion when the server sends a version negotiation packet
 // - handshake.ErrCloseSessionForRetry when the server performs a stateless retry (for IETF QUIC)
 // - any other error that might occur
+// - the context's error when the context is canceled
 // - when the connection is secure (for gQUIC), or forward-secure (for IETF QUIC)
-func (c *client) establishSecureConnection() error {
+// When dialing with DialEarly, it returns as soon as the session can be used.
+func (c *client) establishSecureConnection(ctx context.Context) error {
 	var runErr error
 	errorChan := make(chan struct{})
+	sess := c.session
 	go func() {
-		runErr = c.session.run() // returns as soon as the session is closed
-		close(errorChan)
+		runErr = sess.run() // returns as soon as the session is closed
 		utils.Infof("Connection %x closed.", c.connectionID)
-		if runErr != handshake.ErrCloseSessionForRetry && runErr != errCloseSessionForNewVersion {
-			c.conn.Close()
+		if (runErr != handshake.ErrCloseSessionForRetry && runErr != errCloseSessionForNewVersion) || c.returnedEarlySession(sess) {
+			c.close()
 		}
+		close(errorChan)
 	}()
 
+	// abort closes the session when the context is canceled.
+	// It returns after the client was cleaned up.
+	abort := func() error {
+		sess.Close(ctx.Err())
+		<-errorChan
+		// the session might have been closed for a new version or a retry before,
+		// in which case it wasn't cleaned up
+		if runErr == handshake.ErrCloseSessionForRetry || runErr == errCloseSessionForNewVersion {
+			c.close()
+		}
+		return ctx.Err()
+	}
+
+	if c.use0RTT {
+		select {
+		case <-ctx.Done():
+			return abort()
+		case <-errorChan:
+			if (runErr == handshake.ErrCloseSessionForRetry || runErr == errCloseSessionForNewVersion) && c.returnedEarlySession(sess) {
+				// the session can't be recreated, since it might already be in use
+				return Err0RTTRejected
+			}
+			return runErr
+		case <-sess.earlySessionReady():
+			return nil
+		}
+	}
+
 	// wait until the server accepts the QUIC version (or an error occurs)
 	select {
+	case <-ctx.Done():
+		return abort()
 	case <-errorChan:
 		return runErr
 	case <-c.versionNegotiationChan:
 	}
 
 	select {
+	case <-ctx.Done():
+		return abort()
 	case <-errorChan:
 		return runErr
 	case err := <-c.session.handshakeStatus():
@@ -250,34 +464,80 @@ func (c *client) establishSecureConnection() error {
 	}
 }
 
-// Listen listens on the underlying connection and passes packets on for handling.
-// It returns when the connection is closed.
-func (c *client) listen() {
-	var err error
+// returnedEarlySession says if DialEarly might have returned the session.
+// This is the case as soon as the session could be used.
+func (c *client) returnedEarlySession(sess packetHandler) bool {
+	if !c.use0RTT {
+		return false
+	}
+	select {
+	case <-sess.earlySessionReady():
+		return true
+	default:
+		return false
+	}
+}
 
-	for {
-		var n int
-		var addr net.Addr
-		data := *getPacketBuffer()
-		data = data[:protocol.MaxReceivePacketSize]
-		// The packet size should not exceed protocol.MaxReceivePacketSize bytes
-		// If it does, we only read a truncated packet, which will then end up undecryptable
-		n, addr, err = c.conn.Read(data)
-		if err != nil {
-			if !strings.HasSuffix(err.Error(), "use of closed network connection") {
-				c.mutex.Lock()
-				if c.session != nil {
-					c.session.Close(err)
-				}
-				c.mutex.Unlock()
-			}
-			break
+// close removes the client from the packetHandlers.
+// The net.PacketConn is only closed if it was created by the client.
+func (c *client) close() {
+	c.connIDsMutex.Lock()
+	defer c.connIDsMutex.Unlock()
+
+	c.packetHandlers.Remove(c.connectionID)
+	for id := range c.connIDs {
+		c.packetHandlers.Remove(id)
+	}
+	if c.config.RequestConnectionIDOmission {
+		c.packetHandlers.RemoveUnknownPacketHandler()
+	}
+	if c.createdPacketConn {
+		c.conn.Close()
+	}
+}
+
+// closeWithError is called when reading from the net.PacketConn fails.
+func (c *client) closeWithError(err error) {
+	// the net.PacketConn is closed after the session was closed
+	if strings.HasSuffix(err.Error(), "use of closed network connection") {
+		return
+	}
+	c.mutex.Lock()
+	if c.session != nil {
+		c.session.Close(err)
+	}
+	c.mutex.Unlock()
+}
+
+// switchPacketConn is called when the session migrates to a new net.PacketConn.
+// From now on, the client receives packets on the new net.PacketConn.
+func (c *client) switchPacketConn(pconn net.PacketConn) {
+	c.connIDsMutex.Lock()
+	defer c.connIDsMutex.Unlock()
+
+	packetHandlers := getMultiplexer().AddConn(pconn)
+	c.packetHandlers.Remove(c.connectionID)
+	packetHandlers.Add(c.connectionID, c)
+	for id := range c.connIDs {
+		c.packetHandlers.Remove(id)
+		packetHandlers.Add(id, c)
+	}
+	if c.config.RequestConnectionIDOmission {
+		c.packetHandlers.RemoveUnknownPacketHandler()
+		if err := packetHandlers.SetUnknownPacketHandler(c); err != nil {
+			utils.Errorf("Not receiving packets with omitted connection IDs after migrating: %s", err)
 		}
-		c.handlePacket(addr, data[:n])
 	}
+	// the application can't close the net.PacketConn we created
+	if c.createdPacketConn {
+		c.pconn.Close()
+		c.createdPacketConn = false
+	}
+	c.pconn = pconn
+	c.packetHandlers = packetHandlers
 }
 
-func (c *client) handlePacket(remoteAddr net.Addr, packet []byte) {
+func (c *client) handlePacket(remoteAddr net.Addr, packet []byte, ecn protocol.ECN) {
 	rcvTime := time.Now()
 
 	r := bytes.NewReader(packet)
@@ -296,8 +556,13 @@ func (c *client) handlePacket(remoteAddr net.Addr, packet []byte) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
+	// packets might arrive before the session was created
+	if c.session == nil {
+		return
+	}
+
 	// reject packets with the wrong connection ID
-	if !hdr.OmitConnectionID && hdr.ConnectionID != c.connectionID {
+	if !hdr.OmitConnectionID && hdr.ConnectionID != c.connectionID && !c.isActiveConnectionID(hdr.ConnectionID) {
 		return
 	}
 
@@ -305,17 +570,19 @@ func (c *client) handlePacket(remoteAddr net.Addr, packet []byte) {
 		cr := c.conn.RemoteAddr()
 		// check if the remote address and the connection ID match
 		// otherwise this might be an attacker trying to inject a PUBLIC_RESET to kill the connection
-		if cr.Network() != remoteAddr.Network() || cr.String() != remoteAddr.String() || hdr.ConnectionID != c.connectionID {
+		if cr.Network() != remoteAddr.Network() || cr.String() != remoteAddr.String() ||
+			hdr.OmitConnectionID || (hdr.ConnectionID != c.connectionID && !c.isActiveConnectionID(hdr.ConnectionID)) {
 			utils.Infof("Received a spoofed Public Reset. Ignoring.")
 			return
 		}
-		pr, err := wire.ParsePublicReset(r)
-		if err != nil {
-			utils.Infof("Received a Public Reset. An error occurred parsing the packet: %s", err)
-			return
-		}
-		utils.Infof("Received Public Reset, rejected packet number: %#x.", pr.RejectedPacketNumber)
-		c.session.closeRemote(qerr.Error(qerr.PublicReset, fmt.Sprintf("Received a Public Reset for packet number %#x", pr.RejectedPacketNumber)))
+		// the session checks the nonce proof
+		c.session.handlePacket(&receivedPacket{
+			remoteAddr: remoteAddr,
+			header:     hdr,
+			data:       packet[len(packet)-r.Len():],
+			rcvTime:    rcvTime,
+			ecn:        ecn,
+		})
 		return
 	}
 
@@ -347,6 +614,7 @@ func (c *client) handlePacket(remoteAddr net.Addr, packet []byte) {
 		header:     hdr,
 		data:       packet[len(packet)-r.Len():],
 		rcvTime:    rcvTime,
+		ecn:        ecn,
 	})
 }
 
@@ -372,6 +640,9 @@ func (c *client) handleVersionNegotiationPacket(hdr *wire.Header) error {
 	// switch to negotiated version
 	c.initialVersion = c.version
 	c.version = newVersion
+	c.connIDsMutex.Lock()
+	c.packetHandlers.Remove(c.connectionID)
+	c.connIDsMutex.Unlock()
 	var err error
 	c.connectionID, err = utils.GenerateConnectionID()
 	if err != nil {
@@ -395,24 +666,75 @@ func (c *client) createNewGQUICSession() (err error) {
 		c.initialVersion,
 		c.negotiatedVersions,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	c.connIDsMutex.Lock()
+	c.packetHandlers.Add(c.connectionID, c)
+	c.connIDsMutex.Unlock()
+	return nil
 }
 
 func (c *client) createNewTLSSession(
-	paramsChan <-chan handshake.TransportParameters,
+	extHandler handshake.TLSExtensionHandler,
 	version protocol.VersionNumber,
 ) (err error) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 	c.session, err = newTLSClientSession(
 		c.conn,
+		c,
 		c.hostname,
 		c.version,
 		c.connectionID,
 		c.config,
 		c.tls,
-		paramsChan,
+		extHandler,
 		1,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	c.connIDsMutex.Lock()
+	c.packetHandlers.Add(c.connectionID, c)
+	c.connIDsMutex.Unlock()
+	return nil
+}
+
+func (c *client) addConnectionID(id protocol.ConnectionID, _ packetHandler) {
+	c.connIDsMutex.Lock()
+	if c.connIDs == nil {
+		c.connIDs = make(map[protocol.ConnectionID]struct{})
+	}
+	c.connIDs[id] = struct{}{}
+	c.packetHandlers.Add(id, c)
+	c.connIDsMutex.Unlock()
+}
+
+func (c *client) retireConnectionID(id protocol.ConnectionID) {
+	c.connIDsMutex.Lock()
+	delete(c.connIDs, id)
+	c.packetHandlers.Remove(id)
+	c.connIDsMutex.Unlock()
+}
+
+func (c *client) isActiveConnectionID(id protocol.ConnectionID) bool {
+	c.connIDsMutex.Lock()
+	defer c.connIDsMutex.Unlock()
+	_, ok := c.connIDs[id]
+	return ok
+}
+
+// The clientConn is the connection used by the client's sessions.
+// It notifies the client when the session migrates to a new net.PacketConn.
+type clientConn struct {
+	connection
+	client *client
+}
+
+var _ connection = &clientConn{}
+
+func (c *clientConn) SetPacketConn(pconn net.PacketConn) {
+	c.client.switchPacketConn(pconn)
+	c.connection.SetPacketConn(pconn)
 }
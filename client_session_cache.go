@@ -0,0 +1,23 @@
+package quic
+
+import (
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/handshake"
+)
+
+// NewLRUClientSessionCache returns a ClientSessionCache with the given capacity that uses an LRU strategy.
+// If capacity is < 1, a default capacity is used instead.
+func NewLRUClientSessionCache(capacity int) ClientSessionCache {
+	return handshake.NewLRUClientSessionCache(capacity)
+}
+
+// clientSessionCacheKey returns the key used to store the state for a server in the ClientSessionCache.
+// Servers running on different ports of the same host don't share their state.
+func clientSessionCacheKey(hostname string, remoteAddr net.Addr) string {
+	_, port, err := net.SplitHostPort(remoteAddr.String())
+	if err != nil {
+		return hostname
+	}
+	return net.JoinHostPort(hostname, port)
+}
@@ -0,0 +1,21 @@
+package quic
+
+import (
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client Session Cache", func() {
+	It("uses the hostname and the port as the key", func() {
+		addr := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1337}
+		Expect(clientSessionCacheKey("quic.clemente.io", addr)).To(Equal("quic.clemente.io:1337"))
+	})
+
+	It("uses different keys for different ports", func() {
+		addr1 := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 443}
+		addr2 := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 1337}
+		Expect(clientSessionCacheKey("quic.clemente.io", addr1)).ToNot(Equal(clientSessionCacheKey("quic.clemente.io", addr2)))
+	})
+})
@@ -2,14 +2,20 @@ package quic
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"errors"
+	"io"
 	"net"
 	"os"
+	"reflect"
 	"sync/atomic"
 	"time"
 
+	"github.com/bifurcation/mint"
+	"github.com/golang/mock/gomock"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/mocks/logging"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 	"github.com/lucas-clemente/quic-go/qerr"
@@ -20,11 +26,12 @@ import (
 
 var _ = Describe("Client", func() {
 	var (
-		cl         *client
-		config     *Config
-		sess       *mockSession
-		packetConn *mockPacketConn
-		addr       net.Addr
+		cl             *client
+		config         *Config
+		sess           *mockSession
+		packetConn     *mockPacketConn
+		packetHandlers *MockPacketHandlerManager
+		addr           net.Addr
 
 		originalClientSessConstructor func(conn connection, hostname string, v protocol.VersionNumber, connectionID protocol.ConnectionID, tlsConf *tls.Config, config *Config, initialVersion protocol.VersionNumber, negotiatedVersions []protocol.VersionNumber) (packetHandler, error)
 	)
@@ -53,12 +60,15 @@ var _ = Describe("Client", func() {
 		config = &Config{
 			Versions: []protocol.VersionNumber{protocol.SupportedVersions[0], 77, 78},
 		}
+		packetHandlers = NewMockPacketHandlerManager(mockCtrl)
 		cl = &client{
-			config:       config,
-			connectionID: 0x1337,
-			session:      sess,
-			version:      protocol.SupportedVersions[0],
-			conn:         &conn{pconn: packetConn, currentAddr: addr},
+			config:                 config,
+			connectionID:           0x1337,
+			session:                sess,
+			version:                protocol.SupportedVersions[0],
+			conn:                   &conn{pconn: packetConn, currentAddr: addr},
+			pconn:                  packetConn,
+			packetHandlers:         packetHandlers,
 			versionNegotiationChan: make(chan struct{}),
 		}
 	})
@@ -88,7 +98,7 @@ var _ = Describe("Client", func() {
 				_ protocol.VersionNumber,
 				_ []protocol.VersionNumber,
 			) (packetHandler, error) {
-				Expect(conn.Write([]byte("0 fake CHLO"))).To(Succeed())
+				Expect(conn.Write([]byte("0 fake CHLO"), protocol.ECNNon)).To(Succeed())
 				return sess, nil
 			}
 			origGenerateConnectionID = generateConnectionID
@@ -115,6 +125,21 @@ var _ = Describe("Client", func() {
 			Eventually(dialed).Should(BeClosed())
 		})
 
+		It("returns early when using DialEarly", func() {
+			packetConn.dataToRead <- acceptClientVersionPacket(cl.connectionID)
+			dialed := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				s, err := DialEarly(packetConn, addr, "quic.clemente.io:1337", nil, config)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(s).To(Equal(sess))
+				close(dialed)
+			}()
+			Consistently(dialed).ShouldNot(BeClosed())
+			close(sess.earlySessionReadyChan)
+			Eventually(dialed).Should(BeClosed())
+		})
+
 		It("resolves the address", func() {
 			if os.Getenv("APPVEYOR") == "True" {
 				Skip("This test is flaky on AppVeyor.")
@@ -201,20 +226,72 @@ var _ = Describe("Client", func() {
 			Eventually(done).Should(BeClosed())
 		})
 
+		It("aborts dialing when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				_, err := DialContext(ctx, packetConn, addr, "quic.clemente.io:1337", nil, config)
+				Expect(err).To(MatchError(context.Canceled))
+				close(done)
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			cancel()
+			Eventually(done).Should(BeClosed())
+			Expect(sess.closed).To(BeTrue())
+			Expect(sess.closeReason).To(MatchError(context.Canceled))
+			Expect(packetConn.closed).To(BeFalse())
+		})
+
+		It("closes the packet conn it created when the context is canceled", func() {
+			packetConn.dataToRead <- acceptClientVersionPacket(cl.connectionID)
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				_, err := dial(ctx, packetConn, addr, "quic.clemente.io:1337", nil, config, false, true)
+				Expect(err).To(MatchError(context.Canceled))
+				close(done)
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			cancel()
+			Eventually(done).Should(BeClosed())
+			Expect(sess.closed).To(BeTrue())
+			Expect(packetConn.closed).To(BeTrue())
+		})
+
 		It("setups with the right values", func() {
+			tracer := mocklogging.NewMockTracer(mockCtrl)
+			sessionCache := NewLRUClientSessionCache(10)
 			config := &Config{
 				HandshakeTimeout:            1337 * time.Minute,
 				IdleTimeout:                 42 * time.Hour,
 				RequestConnectionIDOmission: true,
 				MaxIncomingStreams:          1234,
 				MaxIncomingUniStreams:       4321,
+				Tracer:                      tracer,
+				GetLogWriter:                func(ConnectionID) io.WriteCloser { return nil },
+				CongestionControl:           CongestionControlNewReno,
+				EnableDatagrams:             true,
+				ClientSessionCache:          sessionCache,
 			}
-			c := populateClientConfig(config)
+			c := populateClientConfig(config, true)
 			Expect(c.HandshakeTimeout).To(Equal(1337 * time.Minute))
 			Expect(c.IdleTimeout).To(Equal(42 * time.Hour))
 			Expect(c.RequestConnectionIDOmission).To(BeTrue())
 			Expect(c.MaxIncomingStreams).To(Equal(1234))
 			Expect(c.MaxIncomingUniStreams).To(Equal(4321))
+			Expect(c.Tracer).To(Equal(tracer))
+			Expect(c.GetLogWriter).ToNot(BeNil())
+			Expect(reflect.ValueOf(c.CongestionControl)).To(Equal(reflect.ValueOf(CongestionControlNewReno)))
+			Expect(c.EnableDatagrams).To(BeTrue())
+			Expect(c.ClientSessionCache).To(Equal(sessionCache))
+		})
+
+		It("only requests connection ID omission if the client created the packet conn", func() {
+			config := &Config{RequestConnectionIDOmission: true}
+			Expect(populateClientConfig(config, true).RequestConnectionIDOmission).To(BeTrue())
+			Expect(populateClientConfig(config, false).RequestConnectionIDOmission).To(BeFalse())
 		})
 
 		It("disables bidirectional streams", func() {
@@ -222,7 +299,7 @@ var _ = Describe("Client", func() {
 				MaxIncomingStreams:    -1,
 				MaxIncomingUniStreams: 4321,
 			}
-			c := populateClientConfig(config)
+			c := populateClientConfig(config, false)
 			Expect(c.MaxIncomingStreams).To(BeZero())
 			Expect(c.MaxIncomingUniStreams).To(Equal(4321))
 		})
@@ -232,17 +309,32 @@ var _ = Describe("Client", func() {
 				MaxIncomingStreams:    1234,
 				MaxIncomingUniStreams: -1,
 			}
-			c := populateClientConfig(config)
+			c := populateClientConfig(config, false)
 			Expect(c.MaxIncomingStreams).To(Equal(1234))
 			Expect(c.MaxIncomingUniStreams).To(BeZero())
 		})
 
 		It("fills in default values if options are not set in the Config", func() {
-			c := populateClientConfig(&Config{})
+			c := populateClientConfig(&Config{}, false)
 			Expect(c.Versions).To(Equal(protocol.SupportedVersions))
 			Expect(c.HandshakeTimeout).To(Equal(protocol.DefaultHandshakeTimeout))
 			Expect(c.IdleTimeout).To(Equal(protocol.DefaultIdleTimeout))
 			Expect(c.RequestConnectionIDOmission).To(BeFalse())
+			Expect(reflect.ValueOf(c.CongestionControl)).To(Equal(reflect.ValueOf(CongestionControlCubic)))
+			Expect(c.KeyUpdateInterval).To(BeEquivalentTo(protocol.DefaultKeyUpdateInterval))
+			Expect(c.MaxPacketSize).To(Equal(protocol.DefaultMaxPacketSize))
+			Expect(c.MaxAckDelay).To(Equal(protocol.AckSendDelay))
+			Expect(c.RetransmittablePacketsBeforeAck).To(Equal(protocol.RetransmittablePacketsBeforeAck))
+		})
+
+		It("limits the maximum ACK delay", func() {
+			Expect(populateClientConfig(&Config{MaxAckDelay: time.Hour}, false).MaxAckDelay).To(Equal(protocol.MaxMaxAckDelay))
+			Expect(populateClientConfig(&Config{MaxAckDelay: time.Microsecond}, false).MaxAckDelay).To(Equal(protocol.MinAckDelay))
+		})
+
+		It("errors when the maximum packet size is smaller than the initial packet size", func() {
+			_, err := Dial(packetConn, addr, "quic.clemente.io:1337", nil, &Config{MaxPacketSize: 1199})
+			Expect(err).To(MatchError("MaxPacketSize too small (1199 bytes, minimum 1200 bytes)"))
 		})
 
 		It("errors when receiving an error from the connection", func() {
@@ -271,6 +363,11 @@ var _ = Describe("Client", func() {
 		})
 
 		Context("version negotiation", func() {
+			BeforeEach(func() {
+				packetHandlers.EXPECT().Add(gomock.Any(), gomock.Any()).AnyTimes()
+				packetHandlers.EXPECT().Remove(gomock.Any()).AnyTimes()
+			})
+
 			It("recognizes that a packet without VersionFlag means that the server accepted the suggested version", func() {
 				ph := wire.Header{
 					PacketNumber:    1,
@@ -280,7 +377,7 @@ var _ = Describe("Client", func() {
 				b := &bytes.Buffer{}
 				err := ph.Write(b, protocol.PerspectiveServer, protocol.VersionWhatever)
 				Expect(err).ToNot(HaveOccurred())
-				cl.handlePacket(nil, b.Bytes())
+				cl.handlePacket(nil, b.Bytes(), protocol.ECNNon)
 				Expect(cl.versionNegotiated).To(BeTrue())
 				Expect(cl.versionNegotiationChan).To(BeClosed())
 			})
@@ -315,18 +412,29 @@ var _ = Describe("Client", func() {
 					return sess, nil
 				}
 
+				cl.packetHandlers = getMultiplexer().AddConn(packetConn)
+				// the session is created before the client registers its connection ID
+				isRegistered := func(connID protocol.ConnectionID) func() bool {
+					return func() bool {
+						handlers := cl.packetHandlers.(*packetHandlerMap)
+						handlers.mutex.RLock()
+						defer handlers.mutex.RUnlock()
+						_, ok := handlers.handlers[connID]
+						return ok
+					}
+				}
 				established := make(chan struct{})
 				go func() {
 					defer GinkgoRecover()
-					err := cl.dial()
+					err := cl.dial(context.Background())
 					Expect(err).ToNot(HaveOccurred())
 					close(established)
 				}()
-				go cl.listen()
 
 				actualInitialVersion := cl.version
 				var firstSession, secondSession *mockSession
 				Eventually(sessionChan).Should(Receive(&firstSession))
+				Eventually(isRegistered(firstSession.connectionID)).Should(BeTrue())
 				packetConn.dataToRead <- wire.ComposeGQUICVersionNegotiation(
 					cl.connectionID,
 					[]protocol.VersionNumber{newVersion},
@@ -336,6 +444,7 @@ var _ = Describe("Client", func() {
 				Expect(firstSession.closeReason).To(Equal(errCloseSessionForNewVersion))
 				Expect(firstSession.packetCount).To(BeZero())
 				Eventually(sessionChan).Should(Receive(&secondSession))
+				Eventually(isRegistered(secondSession.connectionID)).Should(BeTrue())
 				// make the server accept the new version
 				packetConn.dataToRead <- acceptClientVersionPacket(secondSession.connectionID)
 				Consistently(func() bool { return secondSession.closed }).Should(BeFalse())
@@ -365,22 +474,22 @@ var _ = Describe("Client", func() {
 						stopRunLoop:  make(chan struct{}),
 					}, nil
 				}
-				go cl.dial()
+				go cl.dial(context.Background())
 				Eventually(func() uint32 { return atomic.LoadUint32(&sessionCounter) }).Should(BeEquivalentTo(1))
 				newVersion := protocol.VersionNumber(77)
 				Expect(newVersion).ToNot(Equal(cl.version))
 				Expect(config.Versions).To(ContainElement(newVersion))
-				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{newVersion}))
+				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{newVersion}), protocol.ECNNon)
 				Eventually(func() uint32 { return atomic.LoadUint32(&sessionCounter) }).Should(BeEquivalentTo(2))
 				newVersion = protocol.VersionNumber(78)
 				Expect(newVersion).ToNot(Equal(cl.version))
 				Expect(config.Versions).To(ContainElement(newVersion))
-				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{newVersion}))
+				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{newVersion}), protocol.ECNNon)
 				Consistently(func() uint32 { return atomic.LoadUint32(&sessionCounter) }).Should(BeEquivalentTo(2))
 			})
 
 			It("errors if no matching version is found", func() {
-				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{1}))
+				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{1}), protocol.ECNNon)
 				Expect(cl.session.(*mockSession).closed).To(BeTrue())
 				Expect(cl.session.(*mockSession).closeReason).To(MatchError(qerr.InvalidVersion))
 			})
@@ -389,13 +498,13 @@ var _ = Describe("Client", func() {
 				v := protocol.VersionNumber(111)
 				Expect(v).ToNot(Equal(cl.version))
 				Expect(config.Versions).ToNot(ContainElement(v))
-				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{v}))
+				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{v}), protocol.ECNNon)
 				Expect(cl.session.(*mockSession).closed).To(BeTrue())
 				Expect(cl.session.(*mockSession).closeReason).To(MatchError(qerr.InvalidVersion))
 			})
 
 			It("changes to the version preferred by the quic.Config", func() {
-				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{config.Versions[2], config.Versions[1]}))
+				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{config.Versions[2], config.Versions[1]}), protocol.ECNNon)
 				Expect(cl.version).To(Equal(config.Versions[1]))
 			})
 
@@ -403,21 +512,21 @@ var _ = Describe("Client", func() {
 				// if the version was not yet negotiated, handlePacket would return a VersionNegotiationMismatch error, see above test
 				cl.versionNegotiated = true
 				Expect(sess.packetCount).To(BeZero())
-				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{1}))
+				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{1}), protocol.ECNNon)
 				Expect(cl.versionNegotiated).To(BeTrue())
 				Expect(sess.packetCount).To(BeZero())
 			})
 
 			It("drops version negotiation packets that contain the offered version", func() {
 				ver := cl.version
-				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{ver}))
+				cl.handlePacket(nil, wire.ComposeGQUICVersionNegotiation(0x1337, []protocol.VersionNumber{ver}), protocol.ECNNon)
 				Expect(cl.version).To(Equal(ver))
 			})
 		})
 	})
 
 	It("ignores packets with an invalid public header", func() {
-		cl.handlePacket(addr, []byte("invalid packet"))
+		cl.handlePacket(addr, []byte("invalid packet"), protocol.ECNNon)
 		Expect(sess.packetCount).To(BeZero())
 		Expect(sess.closed).To(BeFalse())
 	})
@@ -430,7 +539,7 @@ var _ = Describe("Client", func() {
 			PacketNumber:     1,
 			PacketNumberLen:  1,
 		}).Write(buf, protocol.PerspectiveServer, protocol.VersionWhatever)
-		cl.handlePacket(addr, buf.Bytes())
+		cl.handlePacket(addr, buf.Bytes(), protocol.ECNNon)
 		Expect(sess.packetCount).To(BeZero())
 		Expect(sess.closed).To(BeFalse())
 	})
@@ -442,11 +551,29 @@ var _ = Describe("Client", func() {
 			PacketNumber:    1,
 			PacketNumberLen: 1,
 		}).Write(buf, protocol.PerspectiveServer, protocol.VersionWhatever)
-		cl.handlePacket(addr, buf.Bytes())
+		cl.handlePacket(addr, buf.Bytes(), protocol.ECNNon)
 		Expect(sess.packetCount).To(BeZero())
 		Expect(sess.closed).To(BeFalse())
 	})
 
+	It("accepts packets with connection IDs issued by the server", func() {
+		packetHandlers.EXPECT().Add(cl.connectionID+1, cl)
+		packetHandlers.EXPECT().Remove(cl.connectionID + 1)
+		cl.addConnectionID(cl.connectionID+1, sess)
+		buf := &bytes.Buffer{}
+		(&wire.Header{
+			ConnectionID:    cl.connectionID + 1,
+			PacketNumber:    1,
+			PacketNumberLen: 1,
+		}).Write(buf, protocol.PerspectiveServer, protocol.VersionWhatever)
+		cl.handlePacket(addr, buf.Bytes(), protocol.ECNNon)
+		Expect(sess.packetCount).To(Equal(1))
+		// retired connection IDs are not accepted any more
+		cl.retireConnectionID(cl.connectionID + 1)
+		cl.handlePacket(addr, buf.Bytes(), protocol.ECNNon)
+		Expect(sess.packetCount).To(Equal(1))
+	})
+
 	It("creates new GQUIC sessions with the right parameters", func() {
 		closeErr := errors.New("peer doesn't reply")
 		c := make(chan struct{})
@@ -479,7 +606,7 @@ var _ = Describe("Client", func() {
 			close(dialed)
 		}()
 		Eventually(c).Should(BeClosed())
-		Expect(cconn.(*conn).pconn).To(Equal(packetConn))
+		Expect(cconn.(*clientConn).connection.(*conn).pconn).To(Equal(packetConn))
 		Expect(hostname).To(Equal("quic.clemente.io"))
 		Expect(version).To(Equal(config.Versions[0]))
 		Expect(conf.Versions).To(Equal(config.Versions))
@@ -496,12 +623,13 @@ var _ = Describe("Client", func() {
 		var conf *Config
 		newTLSClientSession = func(
 			connP connection,
+			_ sessionRunner,
 			hostnameP string,
 			versionP protocol.VersionNumber,
 			_ protocol.ConnectionID,
 			configP *Config,
 			tls handshake.MintTLS,
-			paramsChan <-chan handshake.TransportParameters,
+			_ handshake.TLSExtensionHandler,
 			_ protocol.PacketNumber,
 		) (packetHandler, error) {
 			cconn = connP
@@ -518,7 +646,7 @@ var _ = Describe("Client", func() {
 			close(dialed)
 		}()
 		Eventually(c).Should(BeClosed())
-		Expect(cconn.(*conn).pconn).To(Equal(packetConn))
+		Expect(cconn.(*clientConn).connection.(*conn).pconn).To(Equal(packetConn))
 		Expect(hostname).To(Equal("quic.clemente.io"))
 		Expect(version).To(Equal(config.Versions[0]))
 		Expect(conf.Versions).To(Equal(config.Versions))
@@ -526,17 +654,54 @@ var _ = Describe("Client", func() {
 		Eventually(dialed).Should(BeClosed())
 	})
 
+	It("stores TLS session tickets in the session cache", func() {
+		config.Versions = []protocol.VersionNumber{protocol.VersionTLS}
+		config.ClientSessionCache = NewLRUClientSessionCache(1)
+		c := make(chan struct{})
+		var mintConf *mint.Config
+		newTLSClientSession = func(
+			_ connection,
+			_ sessionRunner,
+			_ string,
+			_ protocol.VersionNumber,
+			_ protocol.ConnectionID,
+			_ *Config,
+			tls handshake.MintTLS,
+			_ handshake.TLSExtensionHandler,
+			_ protocol.PacketNumber,
+		) (packetHandler, error) {
+			mintConf = tls.(*mintController).mconf
+			close(c)
+			return sess, nil
+		}
+		dialed := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			Dial(packetConn, addr, "quic.clemente.io:1337", nil, config)
+			close(dialed)
+		}()
+		Eventually(c).Should(BeClosed())
+		psk := mint.PreSharedKey{Identity: []byte("foobar"), ExpiresAt: time.Now().Add(time.Hour)}
+		mintConf.PSKs.Put("quic.clemente.io", psk)
+		p, ok := handshake.NewClientPSKCache(config.ClientSessionCache, "quic.clemente.io:1337", nil).Get("quic.clemente.io")
+		Expect(ok).To(BeTrue())
+		Expect(p).To(Equal(psk))
+		sess.Close(errors.New("peer doesn't reply"))
+		Eventually(dialed).Should(BeClosed())
+	})
+
 	It("creates a new session when the server performs a retry", func() {
 		config.Versions = []protocol.VersionNumber{protocol.VersionTLS}
 		sessionChan := make(chan *mockSession)
 		newTLSClientSession = func(
 			connP connection,
+			_ sessionRunner,
 			hostnameP string,
 			versionP protocol.VersionNumber,
 			_ protocol.ConnectionID,
 			configP *Config,
 			tls handshake.MintTLS,
-			paramsChan <-chan handshake.TransportParameters,
+			_ handshake.TLSExtensionHandler,
 			_ protocol.PacketNumber,
 		) (packetHandler, error) {
 			sess := &mockSession{
@@ -559,6 +724,45 @@ var _ = Describe("Client", func() {
 		Eventually(dialed).Should(BeClosed())
 	})
 
+	It("returns Err0RTTRejected when the server performs a retry after the session was returned early", func() {
+		config.Versions = []protocol.VersionNumber{protocol.VersionTLS}
+		sessionChan := make(chan *mockSession)
+		newTLSClientSession = func(
+			connP connection,
+			_ sessionRunner,
+			hostnameP string,
+			versionP protocol.VersionNumber,
+			_ protocol.ConnectionID,
+			configP *Config,
+			tls handshake.MintTLS,
+			_ handshake.TLSExtensionHandler,
+			_ protocol.PacketNumber,
+		) (packetHandler, error) {
+			sess := &mockSession{
+				stopRunLoop:           make(chan struct{}),
+				earlySessionReadyChan: make(chan struct{}),
+			}
+			sessionChan <- sess
+			return sess, nil
+		}
+		var dialedSess EarlySession
+		dialed := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			var err error
+			dialedSess, err = DialEarly(packetConn, addr, "quic.clemente.io:1337", nil, config)
+			Expect(err).ToNot(HaveOccurred())
+			close(dialed)
+		}()
+		var firstSession *mockSession
+		Eventually(sessionChan).Should(Receive(&firstSession))
+		close(firstSession.earlySessionReadyChan)
+		Eventually(dialed).Should(BeClosed())
+		Expect(dialedSess).To(Equal(firstSession))
+		firstSession.Close(handshake.ErrCloseSessionForRetry)
+		Consistently(sessionChan).ShouldNot(Receive())
+	})
+
 	Context("handling packets", func() {
 		It("handles packets", func() {
 			ph := wire.Header{
@@ -572,53 +776,119 @@ var _ = Describe("Client", func() {
 			packetConn.dataToRead <- b.Bytes()
 
 			Expect(sess.packetCount).To(BeZero())
-			stoppedListening := make(chan struct{})
-			go func() {
-				cl.listen()
-				// it should continue listening when receiving valid packets
-				close(stoppedListening)
-			}()
-
+			getMultiplexer().AddConn(packetConn).Add(cl.connectionID, cl)
 			Eventually(func() int { return sess.packetCount }).Should(Equal(1))
 			Expect(sess.closed).To(BeFalse())
-			Consistently(stoppedListening).ShouldNot(BeClosed())
 		})
 
 		It("closes the session when encountering an error while reading from the connection", func() {
 			testErr := errors.New("test error")
-			packetConn.readErr = testErr
-			cl.listen()
+			cl.closeWithError(testErr)
 			Expect(sess.closed).To(BeTrue())
 			Expect(sess.closeReason).To(MatchError(testErr))
 		})
+
+		It("doesn't close the session when the connection was closed", func() {
+			cl.closeWithError(errors.New("read udp [::]:1234: use of closed network connection"))
+			Expect(sess.closed).To(BeFalse())
+		})
+
+		It("ignores packets that arrive before the session is created", func() {
+			cl.session = nil
+			cl.handlePacket(addr, wire.WritePublicReset(cl.connectionID, 1, 0), protocol.ECNNon)
+		})
+	})
+
+	Context("multiplexing", func() {
+		It("registers and unregisters connection IDs issued by the server", func() {
+			packetHandlers.EXPECT().Add(protocol.ConnectionID(0x42), cl)
+			cl.addConnectionID(0x42, cl.session)
+			packetHandlers.EXPECT().Remove(protocol.ConnectionID(0x42))
+			cl.retireConnectionID(0x42)
+		})
+
+		It("unregisters all connection IDs when closing, without closing the packet conn", func() {
+			packetHandlers.EXPECT().Add(protocol.ConnectionID(0x42), cl)
+			cl.addConnectionID(0x42, cl.session)
+			packetHandlers.EXPECT().Remove(protocol.ConnectionID(0x1337))
+			packetHandlers.EXPECT().Remove(protocol.ConnectionID(0x42))
+			cl.close()
+			Expect(packetConn.closed).To(BeFalse())
+		})
+
+		It("closes the packet conn if it created it", func() {
+			cl.createdPacketConn = true
+			packetHandlers.EXPECT().Remove(protocol.ConnectionID(0x1337))
+			cl.close()
+			Expect(packetConn.closed).To(BeTrue())
+		})
+
+		It("unregisters the handler for unknown packets when using connection ID omission", func() {
+			config.RequestConnectionIDOmission = true
+			cl.createdPacketConn = true
+			packetHandlers.EXPECT().Remove(protocol.ConnectionID(0x1337))
+			packetHandlers.EXPECT().RemoveUnknownPacketHandler()
+			cl.close()
+		})
+
+		It("moves all connection IDs to the new packet conn when migrating", func() {
+			packetHandlers.EXPECT().Add(protocol.ConnectionID(0x42), cl)
+			cl.addConnectionID(0x42, cl.session)
+			cl.createdPacketConn = true
+			cl.conn = &clientConn{connection: cl.conn, client: cl}
+			newPacketConn := newMockPacketConn()
+			newPacketHandlers := NewMockPacketHandlerManager(mockCtrl)
+			origNewPacketHandlerManager := getMultiplexer().newPacketHandlerManager
+			defer func() { getMultiplexer().newPacketHandlerManager = origNewPacketHandlerManager }()
+			getMultiplexer().newPacketHandlerManager = func(c net.PacketConn, _ func()) packetHandlerManager {
+				Expect(c).To(Equal(newPacketConn))
+				return newPacketHandlers
+			}
+			packetHandlers.EXPECT().Remove(protocol.ConnectionID(0x1337))
+			packetHandlers.EXPECT().Remove(protocol.ConnectionID(0x42))
+			newPacketHandlers.EXPECT().Add(protocol.ConnectionID(0x1337), cl)
+			newPacketHandlers.EXPECT().Add(protocol.ConnectionID(0x42), cl)
+			cl.conn.SetPacketConn(newPacketConn)
+			Expect(cl.packetHandlers).To(Equal(newPacketHandlers))
+			Expect(cl.pconn).To(Equal(newPacketConn))
+			// the client created the old packet conn, so it needs to close it
+			Expect(packetConn.closed).To(BeTrue())
+			Expect(cl.createdPacketConn).To(BeFalse())
+			Expect(cl.conn.(*clientConn).connection.(*conn).pconn).To(Equal(newPacketConn))
+		})
 	})
 
 	Context("Public Reset handling", func() {
-		It("closes the session when receiving a Public Reset", func() {
-			cl.handlePacket(addr, wire.WritePublicReset(cl.connectionID, 1, 0))
-			Expect(cl.session.(*mockSession).closed).To(BeTrue())
-			Expect(cl.session.(*mockSession).closedRemote).To(BeTrue())
-			Expect(cl.session.(*mockSession).closeReason.(*qerr.QuicError).ErrorCode).To(Equal(qerr.PublicReset))
+		It("passes Public Resets to the session", func() {
+			cl.handlePacket(addr, wire.WritePublicReset(cl.connectionID, 1, 0), protocol.ECNNon)
+			Expect(cl.session.(*mockSession).packetCount).To(Equal(1))
+		})
+
+		It("passes Public Resets for connection IDs issued by the server to the session", func() {
+			packetHandlers.EXPECT().Add(cl.connectionID+1, cl)
+			cl.addConnectionID(cl.connectionID+1, cl.session)
+			cl.handlePacket(addr, wire.WritePublicReset(cl.connectionID+1, 1, 0), protocol.ECNNon)
+			Expect(cl.session.(*mockSession).packetCount).To(Equal(1))
 		})
 
 		It("ignores Public Resets with the wrong connection ID", func() {
-			cl.handlePacket(addr, wire.WritePublicReset(cl.connectionID+1, 1, 0))
-			Expect(cl.session.(*mockSession).closed).To(BeFalse())
-			Expect(cl.session.(*mockSession).closedRemote).To(BeFalse())
+			cl.handlePacket(addr, wire.WritePublicReset(cl.connectionID+1, 1, 0), protocol.ECNNon)
+			Expect(cl.session.(*mockSession).packetCount).To(BeZero())
 		})
 
-		It("ignores Public Resets from the wrong remote address", func() {
-			spoofedAddr := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 5678}
-			cl.handlePacket(spoofedAddr, wire.WritePublicReset(cl.connectionID, 1, 0))
-			Expect(cl.session.(*mockSession).closed).To(BeFalse())
-			Expect(cl.session.(*mockSession).closedRemote).To(BeFalse())
+		It("ignores Public Resets for retired connection IDs", func() {
+			packetHandlers.EXPECT().Add(cl.connectionID+1, cl)
+			packetHandlers.EXPECT().Remove(cl.connectionID + 1)
+			cl.addConnectionID(cl.connectionID+1, cl.session)
+			cl.retireConnectionID(cl.connectionID + 1)
+			cl.handlePacket(addr, wire.WritePublicReset(cl.connectionID+1, 1, 0), protocol.ECNNon)
+			Expect(cl.session.(*mockSession).packetCount).To(BeZero())
 		})
 
-		It("ignores unparseable Public Resets", func() {
-			pr := wire.WritePublicReset(cl.connectionID, 1, 0)
-			cl.handlePacket(addr, pr[:len(pr)-5])
-			Expect(cl.session.(*mockSession).closed).To(BeFalse())
-			Expect(cl.session.(*mockSession).closedRemote).To(BeFalse())
+		It("ignores Public Resets from the wrong remote address", func() {
+			spoofedAddr := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 5678}
+			cl.handlePacket(spoofedAddr, wire.WritePublicReset(cl.connectionID, 1, 0), protocol.ECNNon)
+			Expect(cl.session.(*mockSession).packetCount).To(BeZero())
 		})
 	})
 })
@@ -0,0 +1,53 @@
+package quic
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// CongestionControlCubic creates a CUBIC congestion controller.
+// This is the congestion controller that is used if Config.CongestionControl is not set.
+func CongestionControlCubic(rttStats *RTTStats) SendAlgorithm {
+	return newCubicSender(rttStats, false)
+}
+
+// CongestionControlNewReno creates a NewReno congestion controller.
+func CongestionControlNewReno(rttStats *RTTStats) SendAlgorithm {
+	return newCubicSender(rttStats, true)
+}
+
+// CongestionControlBBR creates a BBR congestion controller.
+// BBR estimates the bottleneck bandwidth and the minimum RTT of the path, and paces packets accordingly.
+// It doesn't use packet loss as a congestion signal.
+func CongestionControlBBR(rttStats *RTTStats) SendAlgorithm {
+	return congestion.NewBBRSender(
+		congestion.DefaultClock{},
+		rttStats,
+		protocol.InitialCongestionWindow,
+		protocol.DefaultMaxCongestionWindow,
+	)
+}
+
+// CongestionControlLEDBAT creates a LEDBAT-style low-priority ("scavenger") congestion controller.
+// It uses the increase of the RTT over the minimum RTT as an estimate of the queueing delay,
+// and backs off as soon as a queue builds up, yielding to other traffic on the same path.
+func CongestionControlLEDBAT(rttStats *RTTStats) SendAlgorithm {
+	return congestion.NewLEDBATSender(
+		rttStats,
+		protocol.InitialCongestionWindow,
+		protocol.DefaultMaxCongestionWindow,
+	)
+}
+
+func newCubicSender(rttStats *RTTStats, reno bool) SendAlgorithm {
+	return congestion.NewCubicSender(
+		congestion.DefaultClock{},
+		rttStats,
+		reno,
+		protocol.InitialCongestionWindow,
+		protocol.DefaultMaxCongestionWindow,
+	)
+}
+
+var _ SendAlgorithm = congestion.SendAlgorithm(nil)
+var _ congestion.SendAlgorithm = SendAlgorithm(nil)
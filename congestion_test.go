@@ -0,0 +1,40 @@
+package quic
+
+import (
+	"reflect"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Congestion Control", func() {
+	isReno := func(s SendAlgorithm) bool {
+		return reflect.ValueOf(s).Elem().FieldByName("reno").Bool()
+	}
+
+	It("creates a CUBIC sender", func() {
+		s := CongestionControlCubic(&RTTStats{})
+		Expect(s.GetCongestionWindow()).To(Equal(protocol.InitialCongestionWindow * protocol.DefaultTCPMSS))
+		Expect(isReno(s)).To(BeFalse())
+	})
+
+	It("creates a NewReno sender", func() {
+		s := CongestionControlNewReno(&RTTStats{})
+		Expect(s.GetCongestionWindow()).To(Equal(protocol.InitialCongestionWindow * protocol.DefaultTCPMSS))
+		Expect(isReno(s)).To(BeTrue())
+	})
+
+	It("creates a BBR sender", func() {
+		s := CongestionControlBBR(&RTTStats{})
+		Expect(s.GetCongestionWindow()).To(Equal(protocol.InitialCongestionWindow * protocol.DefaultTCPMSS))
+		Expect(reflect.TypeOf(s).String()).To(Equal("*congestion.bbrSender"))
+	})
+
+	It("creates a LEDBAT sender", func() {
+		s := CongestionControlLEDBAT(&RTTStats{})
+		Expect(s.GetCongestionWindow()).To(Equal(protocol.InitialCongestionWindow * protocol.DefaultTCPMSS))
+		Expect(reflect.TypeOf(s).String()).To(Equal("*congestion.ledbatSender"))
+	})
+})
@@ -3,15 +3,34 @@ package quic
 import (
 	"net"
 	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
 )
 
 type connection interface {
-	Write([]byte) error
-	Read([]byte) (int, net.Addr, error)
+	Write([]byte, protocol.ECN) error
+	// WriteBatch writes multiple packets to the current remote address.
+	// It must not be called concurrently.
+	WriteBatch([]outgoingPacket) error
+	WriteTo([]byte, net.Addr) error
+	SupportsECN() bool
+	SupportsPathMTUDiscovery() bool
 	Close() error
 	LocalAddr() net.Addr
 	RemoteAddr() net.Addr
 	SetCurrentRemoteAddr(net.Addr)
+	SetPacketConn(net.PacketConn)
+}
+
+// An outgoingPacket is a packet that is written as part of a batch.
+type outgoingPacket struct {
+	data []byte
+	ecn  protocol.ECN
+}
+
+// A batchWriter writes multiple packets to the same remote address using a single system call.
+type batchWriter interface {
+	WriteBatch([]outgoingPacket, net.Addr) error
 }
 
 type conn struct {
@@ -19,17 +38,85 @@ type conn struct {
 
 	pconn       net.PacketConn
 	currentAddr net.Addr
+	// nil if the net.PacketConn doesn't support batched writes
+	batchWriter batchWriter
+	// set if the Don't Fragment bit is set on packets sent on the net.PacketConn
+	pathMTUDiscovery bool
 }
 
 var _ connection = &conn{}
 
-func (c *conn) Write(p []byte) error {
-	_, err := c.pconn.WriteTo(p, c.currentAddr)
+func newConn(pconn net.PacketConn, remoteAddr net.Addr) *conn {
+	return &conn{
+		pconn:            pconn,
+		currentAddr:      remoteAddr,
+		batchWriter:      newBatchWriter(pconn),
+		pathMTUDiscovery: enablePathMTUDiscovery(pconn),
+	}
+}
+
+// Write writes a packet to the current remote address, and marks it with the ECN codepoint.
+func (c *conn) Write(p []byte, ecn protocol.ECN) error {
+	c.mutex.RLock()
+	pconn := c.pconn
+	addr := c.currentAddr
+	c.mutex.RUnlock()
+	return writePacket(pconn, p, addr, ecn)
+}
+
+// WriteBatch writes multiple packets to the current remote address.
+// If the net.PacketConn doesn't support batched writes, the packets are written one by one.
+func (c *conn) WriteBatch(packets []outgoingPacket) error {
+	c.mutex.RLock()
+	pconn := c.pconn
+	addr := c.currentAddr
+	bw := c.batchWriter
+	c.mutex.RUnlock()
+	if bw != nil {
+		return bw.WriteBatch(packets, addr)
+	}
+	for _, p := range packets {
+		if err := writePacket(pconn, p.data, addr, p.ecn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTo writes a packet to a remote address other than the current remote address
+func (c *conn) WriteTo(p []byte, addr net.Addr) error {
+	c.mutex.RLock()
+	pconn := c.pconn
+	c.mutex.RUnlock()
+	_, err := pconn.WriteTo(p, addr)
 	return err
 }
 
-func (c *conn) Read(p []byte) (int, net.Addr, error) {
-	return c.pconn.ReadFrom(p)
+// SupportsECN says if packets can be marked with an ECN codepoint.
+func (c *conn) SupportsECN() bool {
+	c.mutex.RLock()
+	pconn := c.pconn
+	c.mutex.RUnlock()
+	return supportsECN(pconn)
+}
+
+// SupportsPathMTUDiscovery says if the connection supports probing for larger packet sizes.
+// This is only the case if the Don't Fragment bit could be set on the net.PacketConn.
+func (c *conn) SupportsPathMTUDiscovery() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.pathMTUDiscovery
+}
+
+// SetPacketConn replaces the net.PacketConn that is used for sending packets.
+func (c *conn) SetPacketConn(pconn net.PacketConn) {
+	bw := newBatchWriter(pconn)
+	pathMTUDiscovery := enablePathMTUDiscovery(pconn)
+	c.mutex.Lock()
+	c.pconn = pconn
+	c.batchWriter = bw
+	c.pathMTUDiscovery = pathMTUDiscovery
+	c.mutex.Unlock()
 }
 
 func (c *conn) SetCurrentRemoteAddr(addr net.Addr) {
@@ -39,7 +126,10 @@ func (c *conn) SetCurrentRemoteAddr(addr net.Addr) {
 }
 
 func (c *conn) LocalAddr() net.Addr {
-	return c.pconn.LocalAddr()
+	c.mutex.RLock()
+	pconn := c.pconn
+	c.mutex.RUnlock()
+	return pconn.LocalAddr()
 }
 
 func (c *conn) RemoteAddr() net.Addr {
@@ -50,5 +140,8 @@ func (c *conn) RemoteAddr() net.Addr {
 }
 
 func (c *conn) Close() error {
-	return c.pconn.Close()
+	c.mutex.RLock()
+	pconn := c.pconn
+	c.mutex.RUnlock()
+	return pconn.Close()
 }
@@ -0,0 +1,463 @@
+// +build linux
+
+package quic
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+const (
+	// socket options for UDP segmentation offload, see include/uapi/linux/udp.h
+	udpSegment = 103
+	udpGRO     = 104
+
+	// the maximum size of a GSO datagram (the maximum UDP payload of an IPv4 packet)
+	maxGSOSize = 65535 - 20 - 8
+	// the kernel limits the number of segments of a GSO datagram
+	maxGSOSegments = 64
+	// GRO coalesces packets up to the maximum IP packet size
+	groBufferSize = 1 << 16
+)
+
+// With GRO, a batchReader needs groBufferSize * protocol.PacketBatchSize (1 MiB) of receive buffers.
+// They are taken from this pool when the socket becomes readable, and returned once all datagrams were read,
+// such that idle connections don't hold on to them.
+var groBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, groBufferSize)
+		return &b
+	},
+}
+
+// Batched I/O can be disabled by setting this environment variable.
+const disableBatchingEnv = "QUIC_GO_DISABLE_BATCHING"
+
+var batchingDisabled = os.Getenv(disableBatchingEnv) != ""
+
+// mmsghdr is the struct mmsghdr used by recvmmsg and sendmmsg
+type mmsghdr struct {
+	hdr syscall.Msghdr
+	len uint32
+}
+
+func mmsg(trap uintptr, fd uintptr, msgs []mmsghdr) (int, error) {
+	n, _, errno := syscall.Syscall6(trap, fd, uintptr(unsafe.Pointer(&msgs[0])), uintptr(len(msgs)), 0, 0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// parseControlMessages parses the ECN codepoint and the GRO segment size from the control messages returned by recvmsg.
+// The segment size is 0 if the packet wasn't coalesced.
+func parseControlMessages(oob []byte) (protocol.ECN, int) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return protocol.ECNNon, 0
+	}
+	ecn := protocol.ECNNon
+	var segmentSize int
+	for _, msg := range msgs {
+		switch {
+		case msg.Header.Level == syscall.IPPROTO_IP && msg.Header.Type == syscall.IP_TOS && len(msg.Data) >= 1:
+			ecn = protocol.ECN(msg.Data[0] & ecnMask)
+		case msg.Header.Level == syscall.IPPROTO_IPV6 && msg.Header.Type == syscall.IPV6_TCLASS && len(msg.Data) >= 4:
+			// The Traffic Class is an int in host byte order.
+			// Its value fits into a single byte, which is either the first or the last one.
+			ecn = protocol.ECN((msg.Data[0] | msg.Data[3]) & ecnMask)
+		case msg.Header.Level == syscall.IPPROTO_UDP && msg.Header.Type == udpGRO && len(msg.Data) >= 4:
+			segmentSize = int(*(*int32)(unsafe.Pointer(&msg.Data[0])))
+		}
+	}
+	return ecn, segmentSize
+}
+
+// The batchReader reads up to protocol.PacketBatchSize datagrams using a single recvmmsg call.
+// If the kernel supports UDP GRO, every datagram can contain multiple coalesced packets.
+// The packets are then returned one by one.
+type batchReader struct {
+	rawConn syscall.RawConn
+
+	msgs    []mmsghdr
+	iovecs  []syscall.Iovec
+	names   []syscall.RawSockaddrInet6 // large enough for IPv4 and IPv6 addresses
+	buffers [][]byte
+	oob     [][]byte
+
+	// set if the kernel coalesces datagrams
+	// The buffers are then taken from the groBufferPool, and are nil while no datagrams are being read.
+	gro        bool
+	groBuffers []*[]byte
+
+	// the datagrams received by the last recvmmsg call
+	numMsgs, msgIndex int
+	// the datagram that is currently returned
+	offset      int
+	segmentSize int
+	remoteAddr  *net.UDPAddr
+	ecn         protocol.ECN
+}
+
+// newBatchReadFunc returns a packetReadFunc that uses recvmmsg.
+// It returns nil if batching is disabled.
+func newBatchReadFunc(c *net.UDPConn) packetReadFunc {
+	r := newBatchReader(c)
+	if r == nil {
+		return nil
+	}
+	return r.read
+}
+
+func newBatchReader(c *net.UDPConn) *batchReader {
+	if batchingDisabled {
+		return nil
+	}
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	r := &batchReader{
+		rawConn: rawConn,
+		msgs:    make([]mmsghdr, protocol.PacketBatchSize),
+		iovecs:  make([]syscall.Iovec, protocol.PacketBatchSize),
+		names:   make([]syscall.RawSockaddrInet6, protocol.PacketBatchSize),
+		buffers: make([][]byte, protocol.PacketBatchSize),
+		oob:     make([][]byte, protocol.PacketBatchSize),
+		gro:     enableGRO(rawConn),
+	}
+	if r.gro {
+		r.groBuffers = make([]*[]byte, protocol.PacketBatchSize)
+	}
+	for i := range r.msgs {
+		if !r.gro {
+			r.buffers[i] = make([]byte, protocol.MaxReceivePacketSize)
+			r.iovecs[i].Base = &r.buffers[i][0]
+			r.iovecs[i].SetLen(len(r.buffers[i]))
+		}
+		r.oob[i] = make([]byte, ecnOOBBufferSize)
+		h := &r.msgs[i].hdr
+		h.Name = (*byte)(unsafe.Pointer(&r.names[i]))
+		h.Iov = &r.iovecs[i]
+		h.Iovlen = 1
+		h.Control = &r.oob[i][0]
+	}
+	return r
+}
+
+// getBuffers takes the GRO buffers from the pool.
+func (r *batchReader) getBuffers() {
+	if !r.gro || r.groBuffers[0] != nil {
+		return
+	}
+	for i := range r.groBuffers {
+		b := groBufferPool.Get().(*[]byte)
+		r.groBuffers[i] = b
+		r.buffers[i] = *b
+		r.iovecs[i].Base = &(*b)[0]
+		r.iovecs[i].SetLen(len(*b))
+	}
+}
+
+// putBuffers returns the GRO buffers to the pool.
+func (r *batchReader) putBuffers() {
+	if !r.gro || r.groBuffers[0] == nil {
+		return
+	}
+	for i, b := range r.groBuffers {
+		groBufferPool.Put(b)
+		r.groBuffers[i] = nil
+		r.buffers[i] = nil
+		r.iovecs[i].Base = nil
+	}
+}
+
+// enableGRO asks the kernel to coalesce received UDP datagrams.
+func enableGRO(rawConn syscall.RawConn) bool {
+	var serr error
+	if err := rawConn.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_UDP, udpGRO, 1)
+	}); err != nil {
+		return false
+	}
+	return serr == nil
+}
+
+func (r *batchReader) read(b []byte) (int, net.Addr, protocol.ECN, error) {
+	for r.msgIndex >= r.numMsgs {
+		if err := r.receive(); err != nil {
+			return 0, nil, protocol.ECNNon, err
+		}
+	}
+	msg := &r.msgs[r.msgIndex]
+	data := r.buffers[r.msgIndex][:msg.len]
+	if r.offset == 0 {
+		r.ecn, r.segmentSize = parseControlMessages(r.oob[r.msgIndex][:msg.hdr.Controllen])
+		if r.segmentSize == 0 {
+			r.segmentSize = len(data)
+		}
+		r.remoteAddr = sockaddrToUDPAddr(&r.names[r.msgIndex])
+	}
+	end := r.offset + r.segmentSize
+	if end > len(data) {
+		end = len(data)
+	}
+	n := copy(b, data[r.offset:end])
+	r.offset = end
+	if r.offset >= len(data) {
+		r.msgIndex++
+		r.offset = 0
+		if r.msgIndex >= r.numMsgs {
+			r.putBuffers()
+		}
+	}
+	return n, r.remoteAddr, r.ecn, nil
+}
+
+func (r *batchReader) receive() error {
+	for i := range r.msgs {
+		h := &r.msgs[i].hdr
+		h.Namelen = syscall.SizeofSockaddrInet6
+		h.SetControllen(len(r.oob[i]))
+		h.Flags = 0
+		r.msgs[i].len = 0
+	}
+	var n int
+	var operr error
+	if err := r.rawConn.Read(func(fd uintptr) bool {
+		r.getBuffers()
+		n, operr = mmsg(syscall.SYS_RECVMMSG, fd, r.msgs)
+		if operr == syscall.EAGAIN || operr == syscall.EINTR {
+			// don't hold on to the buffers while waiting for the socket to become readable
+			r.putBuffers()
+			return false
+		}
+		return true
+	}); err != nil {
+		r.putBuffers()
+		return err
+	}
+	if operr != nil {
+		r.putBuffers()
+		return os.NewSyscallError("recvmmsg", operr)
+	}
+	r.numMsgs = n
+	r.msgIndex = 0
+	r.offset = 0
+	return nil
+}
+
+func sockaddrToUDPAddr(sa *syscall.RawSockaddrInet6) *net.UDPAddr {
+	switch sa.Family {
+	case syscall.AF_INET:
+		sa4 := (*syscall.RawSockaddrInet4)(unsafe.Pointer(sa))
+		ip := make(net.IP, net.IPv4len)
+		copy(ip, sa4.Addr[:])
+		return &net.UDPAddr{IP: ip, Port: ntohs(sa4.Port)}
+	case syscall.AF_INET6:
+		ip := make(net.IP, net.IPv6len)
+		copy(ip, sa.Addr[:])
+		addr := &net.UDPAddr{IP: ip, Port: ntohs(sa.Port)}
+		if sa.Scope_id != 0 {
+			if ifi, err := net.InterfaceByIndex(int(sa.Scope_id)); err == nil {
+				addr.Zone = ifi.Name
+			}
+		}
+		return addr
+	default:
+		return &net.UDPAddr{}
+	}
+}
+
+// The mmsgWriter writes packets using a single sendmmsg call.
+// If the kernel supports UDP GSO, consecutive packets of the same size are sent as a single GSO datagram.
+type mmsgWriter struct {
+	rawConn syscall.RawConn
+	family  int
+	gso     bool
+
+	msgs   []mmsghdr
+	iovecs []syscall.Iovec
+	oob    []byte
+	name   syscall.RawSockaddrInet6
+	// the index of the first packet of every message
+	firstPacket []int
+}
+
+var _ batchWriter = &mmsgWriter{}
+
+// newBatchWriter returns a batchWriter that uses sendmmsg.
+// It returns nil if the net.PacketConn is not a *net.UDPConn, or if batching is disabled.
+func newBatchWriter(c net.PacketConn) batchWriter {
+	udpConn, ok := c.(*net.UDPConn)
+	if !ok || batchingDisabled {
+		return nil
+	}
+	rawConn, err := udpConn.SyscallConn()
+	if err != nil {
+		return nil
+	}
+	var family int
+	var familyErr, gsoErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		family, familyErr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_DOMAIN)
+		_, gsoErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_UDP, udpSegment)
+	}); err != nil || familyErr != nil {
+		return nil
+	}
+	return &mmsgWriter{
+		rawConn:     rawConn,
+		family:      family,
+		gso:         gsoErr == nil,
+		msgs:        make([]mmsghdr, protocol.PacketBatchSize),
+		iovecs:      make([]syscall.Iovec, protocol.PacketBatchSize),
+		oob:         make([]byte, 0, protocol.PacketBatchSize*(syscall.CmsgSpace(4)+syscall.CmsgSpace(2))),
+		firstPacket: make([]int, protocol.PacketBatchSize),
+	}
+}
+
+func (w *mmsgWriter) WriteBatch(packets []outgoingPacket, addr net.Addr) error {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return errors.New("batched writes require a *net.UDPAddr")
+	}
+	namelen, err := w.setRemoteAddr(udpAddr)
+	if err != nil {
+		return err
+	}
+	for len(packets) > 0 {
+		n := len(packets)
+		if n > protocol.PacketBatchSize {
+			n = protocol.PacketBatchSize
+		}
+		if err := w.writeBatch(packets[:n], udpAddr, namelen); err != nil {
+			return err
+		}
+		packets = packets[n:]
+	}
+	return nil
+}
+
+func (w *mmsgWriter) writeBatch(packets []outgoingPacket, addr *net.UDPAddr, namelen uint32) error {
+	numMsgs := w.prepare(packets, addr, namelen)
+	var sent int
+	for sent < numMsgs {
+		var n int
+		var operr error
+		if err := w.rawConn.Write(func(fd uintptr) bool {
+			n, operr = mmsg(sysSendmmsg, fd, w.msgs[sent:numMsgs])
+			return operr != syscall.EAGAIN && operr != syscall.EINTR
+		}); err != nil {
+			return err
+		}
+		if operr == syscall.EIO && w.gso {
+			// GSO fails if the network interface doesn't support checksum offloading.
+			w.gso = false
+			return w.writeBatch(packets[w.firstPacket[sent]:], addr, namelen)
+		}
+		if operr != nil {
+			return os.NewSyscallError("sendmmsg", operr)
+		}
+		sent += n
+	}
+	return nil
+}
+
+// prepare fills the messages for a batch of packets, and returns the number of messages.
+func (w *mmsgWriter) prepare(packets []outgoingPacket, addr *net.UDPAddr, namelen uint32) int {
+	w.oob = w.oob[:0]
+	var numMsgs int
+	for i := 0; i < len(packets); {
+		// Packets can be sent as a single GSO datagram if they have the same ECN codepoint and the same size.
+		// Only the last packet may be smaller.
+		size := len(packets[i].data)
+		j := i + 1
+		if w.gso {
+			for j < len(packets) && j-i < maxGSOSegments && (j-i+1)*size <= maxGSOSize &&
+				packets[j].ecn == packets[i].ecn && len(packets[j].data) <= size {
+				j++
+				if len(packets[j-1].data) < size {
+					break
+				}
+			}
+		}
+		for k := i; k < j; k++ {
+			w.iovecs[k].Base = &packets[k].data[0]
+			w.iovecs[k].SetLen(len(packets[k].data))
+		}
+		h := &w.msgs[numMsgs].hdr
+		h.Name = (*byte)(unsafe.Pointer(&w.name))
+		h.Namelen = namelen
+		h.Iov = &w.iovecs[i]
+		setIovlen(h, j-i)
+		h.Control = nil
+		h.SetControllen(0)
+		h.Flags = 0
+		// w.oob has enough capacity for the control messages of all messages, so appending never reallocates.
+		oobStart := len(w.oob)
+		if packets[i].ecn != protocol.ECNNon {
+			w.oob = appendECNControlMessage(w.oob, addr, packets[i].ecn)
+		}
+		if j-i > 1 {
+			var data []byte
+			w.oob, data = appendControlMessage(w.oob, syscall.IPPROTO_UDP, udpSegment, 2)
+			*(*uint16)(unsafe.Pointer(&data[0])) = uint16(size)
+		}
+		if len(w.oob) > oobStart {
+			h.Control = &w.oob[oobStart]
+			h.SetControllen(len(w.oob) - oobStart)
+		}
+		w.firstPacket[numMsgs] = i
+		numMsgs++
+		i = j
+	}
+	return numMsgs
+}
+
+// setRemoteAddr encodes the remote address for the address family of the socket.
+func (w *mmsgWriter) setRemoteAddr(addr *net.UDPAddr) (uint32, error) {
+	w.name = syscall.RawSockaddrInet6{}
+	if w.family == syscall.AF_INET {
+		ip := addr.IP.To4()
+		if ip == nil {
+			return 0, &net.AddrError{Err: "non-IPv4 address", Addr: addr.String()}
+		}
+		sa4 := (*syscall.RawSockaddrInet4)(unsafe.Pointer(&w.name))
+		sa4.Family = syscall.AF_INET
+		sa4.Port = htons(addr.Port)
+		copy(sa4.Addr[:], ip)
+		return syscall.SizeofSockaddrInet4, nil
+	}
+	ip := addr.IP.To16()
+	if ip == nil {
+		return 0, &net.AddrError{Err: "invalid IP address", Addr: addr.String()}
+	}
+	w.name.Family = syscall.AF_INET6
+	w.name.Port = htons(addr.Port)
+	copy(w.name.Addr[:], ip)
+	if addr.Zone != "" {
+		if ifi, err := net.InterfaceByName(addr.Zone); err == nil {
+			w.name.Scope_id = uint32(ifi.Index)
+		}
+	}
+	return syscall.SizeofSockaddrInet6, nil
+}
+
+// The port of a sockaddr is stored in network byte order.
+func htons(port int) uint16 {
+	var b [2]byte
+	b[0] = byte(port >> 8)
+	b[1] = byte(port)
+	return *(*uint16)(unsafe.Pointer(&b[0]))
+}
+
+func ntohs(port uint16) int {
+	b := (*[2]byte)(unsafe.Pointer(&port))
+	return int(b[0])<<8 | int(b[1])
+}
@@ -0,0 +1,10 @@
+package quic
+
+import "syscall"
+
+// the syscall package doesn't define SYS_SENDMMSG for 386
+const sysSendmmsg = 345
+
+func setIovlen(h *syscall.Msghdr, n int) {
+	h.Iovlen = uint32(n)
+}
@@ -0,0 +1,10 @@
+package quic
+
+import "syscall"
+
+// the syscall package doesn't define SYS_SENDMMSG for amd64
+const sysSendmmsg = 307
+
+func setIovlen(h *syscall.Msghdr, n int) {
+	h.Iovlen = uint64(n)
+}
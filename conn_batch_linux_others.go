@@ -0,0 +1,11 @@
+// +build linux,!amd64,!386,!arm,!mips,!mipsle
+
+package quic
+
+import "syscall"
+
+const sysSendmmsg = syscall.SYS_SENDMMSG
+
+func setIovlen(h *syscall.Msghdr, n int) {
+	h.Iovlen = uint64(n)
+}
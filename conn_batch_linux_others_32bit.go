@@ -0,0 +1,12 @@
+// +build linux,arm linux,mips linux,mipsle
+
+package quic
+
+import "syscall"
+
+const sysSendmmsg = syscall.SYS_SENDMMSG
+
+// the msg_iovlen field is 32 bits wide on 32-bit platforms
+func setIovlen(h *syscall.Msghdr, n int) {
+	h.Iovlen = uint32(n)
+}
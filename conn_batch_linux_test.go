@@ -0,0 +1,128 @@
+// +build linux
+
+package quic
+
+import (
+	"bytes"
+	"net"
+	"syscall"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Batched I/O on Linux", func() {
+	var server, client *net.UDPConn
+
+	BeforeEach(func() {
+		addr, err := net.ResolveUDPAddr("udp4", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		server, err = net.ListenUDP("udp4", addr)
+		Expect(err).ToNot(HaveOccurred())
+		client, err = net.ListenUDP("udp4", addr)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		client.Close()
+	})
+
+	getPackets := func() []outgoingPacket {
+		var packets []outgoingPacket
+		// GSO can combine the first three packets, and the fourth one (which is smaller)
+		for i := 0; i < 4; i++ {
+			packets = append(packets, outgoingPacket{data: bytes.Repeat([]byte{byte(i)}, 1000), ecn: protocol.ECT0})
+		}
+		packets[3].data = packets[3].data[:500]
+		// different ECN codepoint, this packet needs to be sent separately
+		packets = append(packets, outgoingPacket{data: bytes.Repeat([]byte{4}, 1000), ecn: protocol.ECNNon})
+		packets = append(packets, outgoingPacket{data: bytes.Repeat([]byte{5}, 100), ecn: protocol.ECNNon})
+		return packets
+	}
+
+	readPackets := func(packets []outgoingPacket) {
+		read := newBatchReadFunc(server)
+		Expect(read).ToNot(BeNil())
+		for _, p := range packets {
+			b := make([]byte, protocol.MaxReceivePacketSize)
+			n, addr, ecn, err := read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b[:n]).To(Equal(p.data))
+			Expect(addr.String()).To(Equal(client.LocalAddr().String()))
+			Expect(ecn).To(Equal(p.ecn))
+		}
+	}
+
+	It("sends and receives a batch of packets", func() {
+		Expect(enableECN(server)).To(BeTrue())
+		w := newBatchWriter(client)
+		Expect(w).ToNot(BeNil())
+		packets := getPackets()
+		Expect(w.WriteBatch(packets, server.LocalAddr())).To(Succeed())
+		readPackets(packets)
+	})
+
+	It("sends a batch of packets without GSO", func() {
+		Expect(enableECN(server)).To(BeTrue())
+		w := newBatchWriter(client)
+		w.(*mmsgWriter).gso = false
+		packets := getPackets()
+		Expect(w.WriteBatch(packets, server.LocalAddr())).To(Succeed())
+		readPackets(packets)
+	})
+
+	It("splits batches larger than the batch size", func() {
+		var packets []outgoingPacket
+		for i := 0; i < 2*protocol.PacketBatchSize+1; i++ {
+			packets = append(packets, outgoingPacket{data: bytes.Repeat([]byte{byte(i)}, 10+i)})
+		}
+		Expect(newBatchWriter(client).WriteBatch(packets, server.LocalAddr())).To(Succeed())
+		readPackets(packets)
+	})
+
+	It("uses the batch writer for *net.UDPConns", func() {
+		c := newConn(client, server.LocalAddr())
+		Expect(c.batchWriter).ToNot(BeNil())
+		packets := getPackets()[4:]
+		Expect(c.WriteBatch(packets)).To(Succeed())
+		readPackets(packets)
+	})
+
+	It("rejects IPv6 addresses on an IPv4 socket", func() {
+		err := newBatchWriter(client).WriteBatch(getPackets(), &net.UDPAddr{IP: net.IPv6loopback, Port: 1234})
+		Expect(err).To(MatchError(ContainSubstring("non-IPv4 address")))
+	})
+
+	It("doesn't use batching for other net.PacketConns", func() {
+		Expect(newBatchWriter(newMockPacketConn())).To(BeNil())
+	})
+
+	It("only holds the GRO buffers while reading datagrams", func() {
+		r := newBatchReader(server)
+		Expect(r).ToNot(BeNil())
+		if !r.gro {
+			Skip("UDP GRO not supported")
+		}
+		Expect(r.groBuffers[0]).To(BeNil())
+		packets := getPackets()
+		Expect(newBatchWriter(client).WriteBatch(packets[:2], server.LocalAddr())).To(Succeed())
+		b := make([]byte, protocol.MaxReceivePacketSize)
+		n, _, _, err := r.read(b)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b[:n]).To(Equal(packets[0].data))
+		Expect(r.groBuffers[0]).ToNot(BeNil())
+		n, _, _, err = r.read(b)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b[:n]).To(Equal(packets[1].data))
+		Expect(r.groBuffers[0]).To(BeNil())
+	})
+
+	It("sets the iovec length", func() {
+		var h syscall.Msghdr
+		setIovlen(&h, 3)
+		Expect(h.Iovlen).To(BeEquivalentTo(3))
+	})
+})
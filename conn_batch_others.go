@@ -0,0 +1,9 @@
+// +build !linux
+
+package quic
+
+import "net"
+
+func newBatchReadFunc(*net.UDPConn) packetReadFunc { return nil }
+
+func newBatchWriter(net.PacketConn) batchWriter { return nil }
@@ -0,0 +1,38 @@
+// +build linux
+
+package quic
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// setDF sets the Don't Fragment bit on all packets sent on the connection, which is required for path MTU discovery.
+// IP_PMTUDISC_PROBE makes the kernel ignore its path MTU estimate: packets up to the MTU of the interface are sent,
+// and it's left to path MTU discovery to find out which packet sizes work.
+// A socket only supports the options for its own address family, so it's sufficient if one of them can be set.
+func setDF(c *net.UDPConn) bool {
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var errIPv4, errIPv6 error
+	if err := rawConn.Control(func(fd uintptr) {
+		errIPv4 = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_PROBE)
+		errIPv6 = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_MTU_DISCOVER, syscall.IPV6_PMTUDISC_PROBE)
+	}); err != nil {
+		return false
+	}
+	return errIPv4 == nil || errIPv6 == nil
+}
+
+// isMsgSizeErr says if an error was returned because a packet was larger than the MTU of the interface.
+func isMsgSizeErr(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	sysErr, ok := opErr.Err.(*os.SyscallError)
+	return ok && sysErr.Err == syscall.EMSGSIZE
+}
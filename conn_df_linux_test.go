@@ -0,0 +1,50 @@
+// +build linux
+
+package quic
+
+import (
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Don't Fragment bit on Linux", func() {
+	It("sets the DF bit", func() {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		Expect(setDF(conn)).To(BeTrue())
+		Expect(enablePathMTUDiscovery(conn)).To(BeTrue())
+	})
+
+	It("doesn't support path MTU discovery for other net.PacketConns", func() {
+		Expect(enablePathMTUDiscovery(newMockPacketConn())).To(BeFalse())
+	})
+
+	It("checks if the DF bit can be set on the new net.PacketConn", func() {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		c := newConn(conn, conn.LocalAddr())
+		Expect(c.SupportsPathMTUDiscovery()).To(BeTrue())
+		c.SetPacketConn(newMockPacketConn())
+		Expect(c.SupportsPathMTUDiscovery()).To(BeFalse())
+		c.SetPacketConn(conn)
+		Expect(c.SupportsPathMTUDiscovery()).To(BeTrue())
+	})
+
+	It("detects packets that are too large to be sent", func() {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		Expect(setDF(conn)).To(BeTrue())
+		// larger than the MTU of the loopback interface
+		err = writePacket(conn, make([]byte, 1<<16), conn.LocalAddr(), protocol.ECNNon)
+		Expect(err).To(HaveOccurred())
+		Expect(isMsgSizeErr(err)).To(BeTrue())
+		Expect(writePacket(conn, make([]byte, protocol.MaxReceivePacketSize), conn.LocalAddr(), protocol.ECNNon)).To(Succeed())
+	})
+})
@@ -0,0 +1,9 @@
+// +build !linux
+
+package quic
+
+import "net"
+
+func setDF(*net.UDPConn) bool { return false }
+
+func isMsgSizeErr(error) bool { return false }
@@ -0,0 +1,67 @@
+package quic
+
+import (
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// A packetReadFunc reads a packet from a net.PacketConn.
+// In addition to the remote address, it returns the ECN codepoint of the IP header.
+type packetReadFunc func([]byte) (int, net.Addr, protocol.ECN, error)
+
+// newPacketReadFunc returns a packetReadFunc for a net.PacketConn.
+// Reading the ECN codepoint requires a *net.UDPConn on a platform that supports reading the IP header fields.
+// For all other connections, packets are reported as not ECN-capable.
+// If supported, multiple packets are read using a single system call.
+func newPacketReadFunc(c net.PacketConn) packetReadFunc {
+	if udpConn, ok := c.(*net.UDPConn); ok {
+		ecnEnabled := enableECN(udpConn)
+		if read := newBatchReadFunc(udpConn); read != nil {
+			return read
+		}
+		if ecnEnabled {
+			oob := make([]byte, ecnOOBBufferSize)
+			return func(b []byte) (int, net.Addr, protocol.ECN, error) {
+				n, oobn, _, addr, err := udpConn.ReadMsgUDP(b, oob)
+				if err != nil {
+					return 0, nil, protocol.ECNNon, err
+				}
+				return n, addr, parseECN(oob[:oobn]), nil
+			}
+		}
+	}
+	return func(b []byte) (int, net.Addr, protocol.ECN, error) {
+		n, addr, err := c.ReadFrom(b)
+		return n, addr, protocol.ECNNon, err
+	}
+}
+
+// supportsECN says if packets sent on a net.PacketConn can be marked with an ECN codepoint.
+func supportsECN(c net.PacketConn) bool {
+	_, ok := c.(*net.UDPConn)
+	return ok && ecnSupported
+}
+
+// enablePathMTUDiscovery sets the Don't Fragment bit for packets sent on a net.PacketConn.
+// It returns false if that's not possible. Without it, probe packets might be fragmented,
+// and path MTU discovery would confirm packet sizes that don't work.
+func enablePathMTUDiscovery(c net.PacketConn) bool {
+	udpConn, ok := c.(*net.UDPConn)
+	return ok && setDF(udpConn)
+}
+
+// writePacket writes a packet, and marks it with the ECN codepoint.
+// If the net.PacketConn doesn't support ECN, the packet is sent without a marking.
+func writePacket(c net.PacketConn, p []byte, addr net.Addr, ecn protocol.ECN) error {
+	if ecn != protocol.ECNNon {
+		udpConn, ok1 := c.(*net.UDPConn)
+		udpAddr, ok2 := addr.(*net.UDPAddr)
+		if ok1 && ok2 && ecnSupported {
+			_, _, err := udpConn.WriteMsgUDP(p, ecnControlMessage(udpAddr, ecn), udpAddr)
+			return err
+		}
+	}
+	_, err := c.WriteTo(p, addr)
+	return err
+}
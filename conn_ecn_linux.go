@@ -0,0 +1,72 @@
+// +build linux
+
+package quic
+
+import (
+	"net"
+	"syscall"
+	"unsafe"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+const ecnSupported = true
+
+// the ECN codepoint is stored in the two least significant bits of the TOS / Traffic Class field
+const ecnMask = 0x3
+
+// large enough for an IP_TOS and an IPV6_TCLASS control message
+const ecnOOBBufferSize = 128
+
+// enableECN asks the kernel to report the TOS / Traffic Class field of received packets.
+// A socket only supports the options for its own address family, so it's sufficient if one of them can be set.
+func enableECN(c *net.UDPConn) bool {
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return false
+	}
+	var errIPv4, errIPv6 error
+	if err := rawConn.Control(func(fd uintptr) {
+		errIPv4 = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_RECVTOS, 1)
+		errIPv6 = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_RECVTCLASS, 1)
+	}); err != nil {
+		return false
+	}
+	return errIPv4 == nil || errIPv6 == nil
+}
+
+// parseECN parses the ECN codepoint from the control messages returned by recvmsg.
+func parseECN(oob []byte) protocol.ECN {
+	ecn, _ := parseControlMessages(oob)
+	return ecn
+}
+
+// ecnControlMessage creates the control message that sets the ECN codepoint of a packet sent to addr.
+func ecnControlMessage(addr *net.UDPAddr, ecn protocol.ECN) []byte {
+	return appendECNControlMessage(nil, addr, ecn)
+}
+
+// appendECNControlMessage appends the control message that sets the ECN codepoint of a packet sent to addr.
+// IPv4-mapped IPv6 addresses are sent on the IPv4 path by the kernel, and therefore use the IP_TOS option.
+func appendECNControlMessage(b []byte, addr *net.UDPAddr, ecn protocol.ECN) []byte {
+	level, typ := syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS
+	if addr.IP.To4() != nil {
+		level, typ = syscall.IPPROTO_IP, syscall.IP_TOS
+	}
+	b, data := appendControlMessage(b, level, typ, 4)
+	*(*int32)(unsafe.Pointer(&data[0])) = int32(ecn)
+	return b
+}
+
+// appendControlMessage appends a control message with dataLen bytes of data to b.
+// It returns the extended buffer and the (zeroed) data of the new message.
+func appendControlMessage(b []byte, level, typ, dataLen int) ([]byte, []byte) {
+	start := len(b)
+	b = append(b, make([]byte, syscall.CmsgSpace(dataLen))...)
+	h := (*syscall.Cmsghdr)(unsafe.Pointer(&b[start]))
+	h.Level = int32(level)
+	h.Type = int32(typ)
+	h.SetLen(syscall.CmsgLen(dataLen))
+	dataStart := start + syscall.CmsgLen(0)
+	return b, b[dataStart : dataStart+dataLen]
+}
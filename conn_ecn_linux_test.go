@@ -0,0 +1,52 @@
+// +build linux
+
+package quic
+
+import (
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ECN on Linux", func() {
+	roundTrip := func(network, address string) {
+		addr, err := net.ResolveUDPAddr(network, address)
+		Expect(err).ToNot(HaveOccurred())
+		server, err := net.ListenUDP(network, addr)
+		Expect(err).ToNot(HaveOccurred())
+		defer server.Close()
+		client, err := net.ListenUDP(network, addr)
+		Expect(err).ToNot(HaveOccurred())
+		defer client.Close()
+
+		read := newPacketReadFunc(server)
+		Expect(supportsECN(client)).To(BeTrue())
+		for _, ecn := range []protocol.ECN{protocol.ECNNon, protocol.ECT0, protocol.ECT1, protocol.ECNCE} {
+			Expect(writePacket(client, []byte("foobar"), server.LocalAddr(), ecn)).To(Succeed())
+			b := make([]byte, 100)
+			n, remoteAddr, rcvdECN, err := read(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b[:n]).To(Equal([]byte("foobar")))
+			Expect(remoteAddr.String()).To(Equal(client.LocalAddr().String()))
+			Expect(rcvdECN).To(Equal(ecn))
+		}
+	}
+
+	It("sends and receives ECN-marked packets, using IPv4", func() {
+		roundTrip("udp4", "127.0.0.1:0")
+	})
+
+	It("sends and receives ECN-marked packets, using IPv6", func() {
+		if _, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback}); err != nil {
+			Skip("IPv6 not available")
+		}
+		roundTrip("udp6", "[::1]:0")
+	})
+
+	It("reports packets as not ECN-capable, if the control message is missing", func() {
+		Expect(parseECN(nil)).To(Equal(protocol.ECNNon))
+	})
+})
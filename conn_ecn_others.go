@@ -0,0 +1,19 @@
+// +build !linux
+
+package quic
+
+import (
+	"net"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+const ecnSupported = false
+
+const ecnOOBBufferSize = 0
+
+func enableECN(*net.UDPConn) bool { return false }
+
+func parseECN([]byte) protocol.ECN { return protocol.ECNNon }
+
+func ecnControlMessage(*net.UDPAddr, protocol.ECN) []byte { return nil }
@@ -0,0 +1,96 @@
+package quic
+
+import (
+	"fmt"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/qerr"
+)
+
+// The connIDGenerator issues additional connection IDs to the client.
+// All active connection IDs are registered with the sessionRunner, such that packets sent with any of them are routed to the same session.
+// It is only used by the server.
+type connIDGenerator struct {
+	// the initial connection ID has the sequence number 0
+	highestSeq    uint64
+	activeConnIDs map[uint64]protocol.ConnectionID
+
+	handler                packetHandler
+	runner                 sessionRunner
+	generateConnID         func() (protocol.ConnectionID, error)
+	getStatelessResetToken func(protocol.ConnectionID) [16]byte
+	queueControlFrame      func(wire.Frame)
+}
+
+func newConnIDGenerator(
+	initialConnID protocol.ConnectionID,
+	handler packetHandler,
+	runner sessionRunner,
+	getStatelessResetToken func(protocol.ConnectionID) [16]byte,
+	queueControlFrame func(wire.Frame),
+) *connIDGenerator {
+	return &connIDGenerator{
+		activeConnIDs:          map[uint64]protocol.ConnectionID{0: initialConnID},
+		handler:                handler,
+		runner:                 runner,
+		generateConnID:         utils.GenerateConnectionID,
+		getStatelessResetToken: getStatelessResetToken,
+		queueControlFrame:      queueControlFrame,
+	}
+}
+
+// IssueConnectionIDs issues new connection IDs, until protocol.MaxActiveConnectionIDs are active.
+// It is called when the handshake completes.
+func (g *connIDGenerator) IssueConnectionIDs() error {
+	for len(g.activeConnIDs) < protocol.MaxActiveConnectionIDs {
+		if err := g.issueNewConnID(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Retire handles a RETIRE_CONNECTION_ID frame.
+// The retired connection ID is replaced by a new one.
+func (g *connIDGenerator) Retire(seq uint64) error {
+	if seq > g.highestSeq {
+		return qerr.Error(qerr.InvalidFrameData, fmt.Sprintf("tried to retire connection ID %d, highest issued: %d", seq, g.highestSeq))
+	}
+	connID, ok := g.activeConnIDs[seq]
+	// might be a retransmission of a RETIRE_CONNECTION_ID frame
+	if !ok {
+		return nil
+	}
+	delete(g.activeConnIDs, seq)
+	g.runner.retireConnectionID(connID)
+	return g.issueNewConnID()
+}
+
+// RemoveAll retires all connection IDs, except for the initial connection ID.
+// It is called when the session is closed. The initial connection ID is removed by the server.
+func (g *connIDGenerator) RemoveAll() {
+	for seq, connID := range g.activeConnIDs {
+		if seq == 0 {
+			continue
+		}
+		g.runner.retireConnectionID(connID)
+	}
+}
+
+func (g *connIDGenerator) issueNewConnID() error {
+	connID, err := g.generateConnID()
+	if err != nil {
+		return err
+	}
+	g.highestSeq++
+	g.activeConnIDs[g.highestSeq] = connID
+	g.runner.addConnectionID(connID, g.handler)
+	g.queueControlFrame(&wire.NewConnectionIDFrame{
+		SequenceNumber:      g.highestSeq,
+		ConnectionID:        connID,
+		StatelessResetToken: g.getStatelessResetToken(connID),
+	})
+	return nil
+}
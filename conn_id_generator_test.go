@@ -0,0 +1,100 @@
+package quic
+
+import (
+	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/qerr"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connection ID Generator", func() {
+	const initialConnID protocol.ConnectionID = 0x1337
+
+	var (
+		g            *connIDGenerator
+		runner       *MockSessionRunner
+		handler      *mockSession
+		queuedFrames []wire.Frame
+		nextConnID   protocol.ConnectionID
+	)
+
+	BeforeEach(func() {
+		queuedFrames = nil
+		nextConnID = 0x100
+		runner = NewMockSessionRunner(mockCtrl)
+		handler = &mockSession{}
+		g = newConnIDGenerator(
+			initialConnID,
+			handler,
+			runner,
+			func(c protocol.ConnectionID) [16]byte { return [16]byte{byte(c)} },
+			func(f wire.Frame) { queuedFrames = append(queuedFrames, f) },
+		)
+		g.generateConnID = func() (protocol.ConnectionID, error) {
+			nextConnID++
+			return nextConnID, nil
+		}
+	})
+
+	It("issues new connection IDs", func() {
+		for i := 1; i < protocol.MaxActiveConnectionIDs; i++ {
+			runner.EXPECT().addConnectionID(protocol.ConnectionID(0x100+i), handler)
+		}
+		Expect(g.IssueConnectionIDs()).To(Succeed())
+		Expect(queuedFrames).To(HaveLen(protocol.MaxActiveConnectionIDs - 1))
+		for i, f := range queuedFrames {
+			ncid := f.(*wire.NewConnectionIDFrame)
+			Expect(ncid.SequenceNumber).To(Equal(uint64(i + 1)))
+			Expect(ncid.ConnectionID).To(Equal(protocol.ConnectionID(0x100 + i + 1)))
+			Expect(ncid.StatelessResetToken).To(Equal([16]byte{byte(0x100 + i + 1)}))
+		}
+	})
+
+	Context("retiring connection IDs", func() {
+		BeforeEach(func() {
+			runner.EXPECT().addConnectionID(gomock.Any(), handler).Times(protocol.MaxActiveConnectionIDs - 1)
+			Expect(g.IssueConnectionIDs()).To(Succeed())
+			queuedFrames = nil
+		})
+
+		It("retires a connection ID and issues a new one", func() {
+			gomock.InOrder(
+				runner.EXPECT().retireConnectionID(protocol.ConnectionID(0x102)),
+				runner.EXPECT().addConnectionID(protocol.ConnectionID(0x100+protocol.MaxActiveConnectionIDs), handler),
+			)
+			Expect(g.Retire(2)).To(Succeed())
+			Expect(queuedFrames).To(HaveLen(1))
+			Expect(queuedFrames[0].(*wire.NewConnectionIDFrame).SequenceNumber).To(Equal(uint64(protocol.MaxActiveConnectionIDs)))
+		})
+
+		It("retires the initial connection ID", func() {
+			runner.EXPECT().retireConnectionID(initialConnID)
+			runner.EXPECT().addConnectionID(gomock.Any(), handler)
+			Expect(g.Retire(0)).To(Succeed())
+		})
+
+		It("ignores duplicate RETIRE_CONNECTION_ID frames", func() {
+			runner.EXPECT().retireConnectionID(protocol.ConnectionID(0x102))
+			runner.EXPECT().addConnectionID(gomock.Any(), handler)
+			Expect(g.Retire(2)).To(Succeed())
+			Expect(g.Retire(2)).To(Succeed())
+			Expect(queuedFrames).To(HaveLen(1))
+		})
+
+		It("errors when the peer retires a connection ID that wasn't issued yet", func() {
+			err := g.Retire(protocol.MaxActiveConnectionIDs)
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.InvalidFrameData))
+		})
+
+		It("removes all connection IDs, except for the initial one", func() {
+			for i := 1; i < protocol.MaxActiveConnectionIDs; i++ {
+				runner.EXPECT().retireConnectionID(protocol.ConnectionID(0x100 + i))
+			}
+			g.RemoveAll()
+		})
+	})
+})
@@ -0,0 +1,82 @@
+package quic
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/qerr"
+)
+
+// The connIDManager keeps track of the connection IDs issued by the server.
+// When the client migrates, it switches to an unused connection ID, such that the new path can't be linked to the old one.
+// It is only used by the client.
+type connIDManager struct {
+	activeSeq    uint64
+	activeConnID protocol.ConnectionID
+	// unused connection IDs, sorted by their sequence number
+	queue []*wire.NewConnectionIDFrame
+
+	handler           packetHandler
+	runner            sessionRunner
+	queueControlFrame func(wire.Frame)
+}
+
+func newConnIDManager(
+	initialConnID protocol.ConnectionID,
+	handler packetHandler,
+	runner sessionRunner,
+	queueControlFrame func(wire.Frame),
+) *connIDManager {
+	return &connIDManager{
+		activeConnID:      initialConnID,
+		handler:           handler,
+		runner:            runner,
+		queueControlFrame: queueControlFrame,
+	}
+}
+
+// Add handles a NEW_CONNECTION_ID frame.
+func (m *connIDManager) Add(f *wire.NewConnectionIDFrame) error {
+	// We already switched to a connection ID with a higher sequence number.
+	// Connection IDs are used in order, so this connection ID can be retired right away.
+	// If we already retired it, the server will ignore the duplicate RETIRE_CONNECTION_ID frame.
+	if f.SequenceNumber < m.activeSeq {
+		m.queueControlFrame(&wire.RetireConnectionIDFrame{SequenceNumber: f.SequenceNumber})
+		return nil
+	}
+	if f.SequenceNumber == m.activeSeq {
+		return nil
+	}
+	var i int
+	for ; i < len(m.queue); i++ {
+		if m.queue[i].SequenceNumber == f.SequenceNumber { // a retransmission
+			return nil
+		}
+		if m.queue[i].SequenceNumber > f.SequenceNumber {
+			break
+		}
+	}
+	if len(m.queue)+1 >= protocol.MaxActiveConnectionIDs {
+		return qerr.Error(qerr.InvalidFrameData, "too many active connection IDs")
+	}
+	m.queue = append(m.queue, nil)
+	copy(m.queue[i+1:], m.queue[i:])
+	m.queue[i] = f
+	m.runner.addConnectionID(f.ConnectionID, m.handler)
+	return nil
+}
+
+// SwitchToNext switches to the unused connection ID with the lowest sequence number,
+// and retires the connection ID used so far.
+// It returns false if the server didn't provide any unused connection IDs.
+func (m *connIDManager) SwitchToNext() (protocol.ConnectionID, bool) {
+	if len(m.queue) == 0 {
+		return 0, false
+	}
+	next := m.queue[0]
+	m.queue = m.queue[1:]
+	m.queueControlFrame(&wire.RetireConnectionIDFrame{SequenceNumber: m.activeSeq})
+	m.runner.retireConnectionID(m.activeConnID)
+	m.activeSeq = next.SequenceNumber
+	m.activeConnID = next.ConnectionID
+	return next.ConnectionID, true
+}
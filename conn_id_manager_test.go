@@ -0,0 +1,95 @@
+package quic
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/qerr"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connection ID Manager", func() {
+	const initialConnID protocol.ConnectionID = 0x1337
+
+	var (
+		m            *connIDManager
+		runner       *MockSessionRunner
+		handler      *mockSession
+		queuedFrames []wire.Frame
+	)
+
+	BeforeEach(func() {
+		queuedFrames = nil
+		runner = NewMockSessionRunner(mockCtrl)
+		handler = &mockSession{}
+		m = newConnIDManager(initialConnID, handler, runner, func(f wire.Frame) { queuedFrames = append(queuedFrames, f) })
+	})
+
+	It("doesn't switch if no unused connection ID is available", func() {
+		_, ok := m.SwitchToNext()
+		Expect(ok).To(BeFalse())
+		Expect(queuedFrames).To(BeEmpty())
+	})
+
+	It("switches to a new connection ID and retires the old one", func() {
+		runner.EXPECT().addConnectionID(protocol.ConnectionID(0xdeadbeef), handler)
+		Expect(m.Add(&wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: 0xdeadbeef})).To(Succeed())
+		runner.EXPECT().retireConnectionID(initialConnID)
+		connID, ok := m.SwitchToNext()
+		Expect(ok).To(BeTrue())
+		Expect(connID).To(Equal(protocol.ConnectionID(0xdeadbeef)))
+		Expect(queuedFrames).To(Equal([]wire.Frame{&wire.RetireConnectionIDFrame{SequenceNumber: 0}}))
+		// no more connection IDs left
+		_, ok = m.SwitchToNext()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("uses connection IDs in the order of their sequence numbers", func() {
+		runner.EXPECT().addConnectionID(protocol.ConnectionID(0x2), handler)
+		runner.EXPECT().addConnectionID(protocol.ConnectionID(0x1), handler)
+		Expect(m.Add(&wire.NewConnectionIDFrame{SequenceNumber: 2, ConnectionID: 0x2})).To(Succeed())
+		Expect(m.Add(&wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: 0x1})).To(Succeed())
+		runner.EXPECT().retireConnectionID(initialConnID)
+		connID, ok := m.SwitchToNext()
+		Expect(ok).To(BeTrue())
+		Expect(connID).To(Equal(protocol.ConnectionID(0x1)))
+		runner.EXPECT().retireConnectionID(protocol.ConnectionID(0x1))
+		connID, ok = m.SwitchToNext()
+		Expect(ok).To(BeTrue())
+		Expect(connID).To(Equal(protocol.ConnectionID(0x2)))
+		Expect(queuedFrames).To(Equal([]wire.Frame{
+			&wire.RetireConnectionIDFrame{SequenceNumber: 0},
+			&wire.RetireConnectionIDFrame{SequenceNumber: 1},
+		}))
+	})
+
+	It("ignores retransmitted NEW_CONNECTION_ID frames", func() {
+		runner.EXPECT().addConnectionID(protocol.ConnectionID(0x1), handler)
+		f := &wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: 0x1}
+		Expect(m.Add(f)).To(Succeed())
+		Expect(m.Add(f)).To(Succeed())
+		Expect(m.queue).To(HaveLen(1))
+	})
+
+	It("retires connection IDs with a sequence number lower than the active one", func() {
+		runner.EXPECT().addConnectionID(protocol.ConnectionID(0x2), handler)
+		Expect(m.Add(&wire.NewConnectionIDFrame{SequenceNumber: 2, ConnectionID: 0x2})).To(Succeed())
+		runner.EXPECT().retireConnectionID(initialConnID)
+		_, ok := m.SwitchToNext()
+		Expect(ok).To(BeTrue())
+		queuedFrames = nil
+		Expect(m.Add(&wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: 0x1})).To(Succeed())
+		Expect(queuedFrames).To(Equal([]wire.Frame{&wire.RetireConnectionIDFrame{SequenceNumber: 1}}))
+	})
+
+	It("errors when the server issues too many connection IDs", func() {
+		for i := 1; i < protocol.MaxActiveConnectionIDs; i++ {
+			runner.EXPECT().addConnectionID(protocol.ConnectionID(i), handler)
+			Expect(m.Add(&wire.NewConnectionIDFrame{SequenceNumber: uint64(i), ConnectionID: protocol.ConnectionID(i)})).To(Succeed())
+		}
+		err := m.Add(&wire.NewConnectionIDFrame{SequenceNumber: protocol.MaxActiveConnectionIDs, ConnectionID: 0x42})
+		Expect(err).To(HaveOccurred())
+		Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.InvalidFrameData))
+	})
+})
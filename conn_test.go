@@ -6,6 +6,8 @@ import (
 	"net"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -18,11 +20,13 @@ type mockPacketConn struct {
 	dataWritten   bytes.Buffer
 	dataWrittenTo net.Addr
 	closed        bool
+	deadline      chan struct{} // closed when the read deadline is set
 }
 
 func newMockPacketConn() *mockPacketConn {
 	return &mockPacketConn{
 		dataToRead: make(chan []byte, 1000),
+		deadline:   make(chan struct{}),
 	}
 }
 
@@ -30,12 +34,16 @@ func (c *mockPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
 	if c.readErr != nil {
 		return 0, nil, c.readErr
 	}
-	data, ok := <-c.dataToRead
-	if !ok {
-		return 0, nil, errors.New("connection closed")
+	select {
+	case data, ok := <-c.dataToRead:
+		if !ok {
+			return 0, nil, errors.New("connection closed")
+		}
+		n := copy(b, data)
+		return n, c.dataReadFrom, nil
+	case <-c.deadline:
+		return 0, nil, errors.New("i/o timeout")
 	}
-	n := copy(b, data)
-	return n, c.dataReadFrom, nil
 }
 func (c *mockPacketConn) WriteTo(b []byte, addr net.Addr) (n int, err error) {
 	c.dataWrittenTo = addr
@@ -50,8 +58,11 @@ func (c *mockPacketConn) Close() error {
 }
 func (c *mockPacketConn) LocalAddr() net.Addr                { return c.addr }
 func (c *mockPacketConn) SetDeadline(t time.Time) error      { panic("not implemented") }
-func (c *mockPacketConn) SetReadDeadline(t time.Time) error  { panic("not implemented") }
 func (c *mockPacketConn) SetWriteDeadline(t time.Time) error { panic("not implemented") }
+func (c *mockPacketConn) SetReadDeadline(t time.Time) error {
+	close(c.deadline)
+	return nil
+}
 
 var _ net.PacketConn = &mockPacketConn{}
 
@@ -72,21 +83,16 @@ var _ = Describe("Connection", func() {
 	})
 
 	It("writes", func() {
-		err := c.Write([]byte("foobar"))
+		err := c.Write([]byte("foobar"), protocol.ECNNon)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(packetConn.dataWritten.Bytes()).To(Equal([]byte("foobar")))
 		Expect(packetConn.dataWrittenTo.String()).To(Equal("192.168.100.200:1337"))
 	})
 
-	It("reads", func() {
-		packetConn.dataToRead <- []byte("foo")
-		packetConn.dataReadFrom = &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1336}
-		p := make([]byte, 10)
-		n, raddr, err := c.Read(p)
-		Expect(err).ToNot(HaveOccurred())
-		Expect(raddr.String()).To(Equal("127.0.0.1:1336"))
-		Expect(n).To(Equal(3))
-		Expect(p[0:3]).To(Equal([]byte("foo")))
+	It("writes ECN-marked packets, if the packet conn doesn't support ECN", func() {
+		Expect(c.SupportsECN()).To(BeFalse())
+		Expect(c.Write([]byte("foobar"), protocol.ECT0)).To(Succeed())
+		Expect(packetConn.dataWritten.Bytes()).To(Equal([]byte("foobar")))
 	})
 
 	It("gets the remote address", func() {
@@ -111,6 +117,26 @@ var _ = Describe("Connection", func() {
 		Expect(c.RemoteAddr().String()).To(Equal(addr.String()))
 	})
 
+	Context("switching the packet conn", func() {
+		It("writes to the new packet conn", func() {
+			newPacketConn := newMockPacketConn()
+			c.SetPacketConn(newPacketConn)
+			err := c.Write([]byte("foobar"), protocol.ECNNon)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(packetConn.dataWritten.Len()).To(BeZero())
+			Expect(newPacketConn.dataWritten.Bytes()).To(Equal([]byte("foobar")))
+			Expect(newPacketConn.dataWrittenTo.String()).To(Equal("192.168.100.200:1337"))
+		})
+
+		It("gets the local address of the new packet conn", func() {
+			addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1234}
+			newPacketConn := newMockPacketConn()
+			newPacketConn.addr = addr
+			c.SetPacketConn(newPacketConn)
+			Expect(c.LocalAddr()).To(Equal(addr))
+		})
+	})
+
 	It("closes", func() {
 		err := c.Close()
 		Expect(err).ToNot(HaveOccurred())
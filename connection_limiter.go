@@ -0,0 +1,236 @@
+package quic
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// ListenerStats is a snapshot of the counters of a Listener.
+type ListenerStats struct {
+	// ActiveSessions is the number of sessions, including the sessions that are still handshaking.
+	ActiveSessions uint64
+	// ActiveHandshakes is the number of sessions whose handshake didn't complete yet.
+	ActiveHandshakes uint64
+	// UnderLoad is set if the server currently requires clients to validate their address,
+	// see Config.AddressValidationThreshold.
+	UnderLoad bool
+	// SessionsCreated is the number of sessions created since the server was started.
+	SessionsCreated uint64
+	// RefusedSessionLimit is the number of connection attempts that were dropped because of Config.MaxIncomingSessions.
+	RefusedSessionLimit uint64
+	// RefusedHandshakeLimit is the number of connection attempts that were dropped because of Config.MaxIncomingHandshakes.
+	RefusedHandshakeLimit uint64
+	// RefusedRateLimit is the number of connection attempts that were dropped because of Config.MaxIncomingSessionRatePerIP.
+	RefusedRateLimit uint64
+	// EnforcedAddressValidations is the number of client addresses that were not accepted,
+	// because the server required address validation while it was under load.
+	EnforcedAddressValidations uint64
+}
+
+// The connectionLimiter decides if the server accepts a new connection.
+// It keeps track of the number of sessions and handshakes, and of the rate of new connections per source IP.
+type connectionLimiter struct {
+	mutex sync.Mutex
+
+	maxSessions                int
+	maxHandshakes              int
+	addressValidationThreshold int
+
+	rateLimiter *sourceRateLimiter
+
+	numSessions   int
+	numHandshakes int
+	stats         ListenerStats
+}
+
+func newConnectionLimiter(config *Config) *connectionLimiter {
+	l := &connectionLimiter{
+		maxSessions:                config.MaxIncomingSessions,
+		maxHandshakes:              config.MaxIncomingHandshakes,
+		addressValidationThreshold: config.AddressValidationThreshold,
+	}
+	if config.MaxIncomingSessionRatePerIP > 0 {
+		l.rateLimiter = newSourceRateLimiter(config.MaxIncomingSessionRatePerIP)
+	}
+	return l
+}
+
+// Reserve is called before the server creates a new session.
+// If it returns true, the session and its handshake are counted,
+// and either Release or SessionClosed must be called later.
+func (l *connectionLimiter) Reserve(remoteAddr net.Addr, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.maxSessions > 0 && l.numSessions >= l.maxSessions {
+		l.stats.RefusedSessionLimit++
+		return false
+	}
+	if l.maxHandshakes > 0 && l.numHandshakes >= l.maxHandshakes {
+		l.stats.RefusedHandshakeLimit++
+		return false
+	}
+	if l.rateLimiter != nil && !l.rateLimiter.Allow(sourceIP(remoteAddr), now) {
+		l.stats.RefusedRateLimit++
+		return false
+	}
+	l.numSessions++
+	l.numHandshakes++
+	return true
+}
+
+// Release releases a reservation, if no session was created.
+// This happens when the server replies with a stateless Retry,
+// so the connection attempt isn't counted towards the rate limit of the remote address.
+func (l *connectionLimiter) Release(remoteAddr net.Addr) {
+	l.mutex.Lock()
+	l.numSessions--
+	l.numHandshakes--
+	if l.rateLimiter != nil {
+		l.rateLimiter.Refund(sourceIP(remoteAddr))
+	}
+	l.mutex.Unlock()
+}
+
+// SessionCreated is called when a session was created for a reservation.
+func (l *connectionLimiter) SessionCreated() {
+	l.mutex.Lock()
+	l.stats.SessionsCreated++
+	l.mutex.Unlock()
+}
+
+// HandshakeFinished is called when the handshake of a session completed or failed.
+func (l *connectionLimiter) HandshakeFinished() {
+	l.mutex.Lock()
+	l.numHandshakes--
+	l.mutex.Unlock()
+}
+
+// SessionClosed is called when the run loop of a session returned.
+func (l *connectionLimiter) SessionClosed() {
+	l.mutex.Lock()
+	l.numSessions--
+	l.mutex.Unlock()
+}
+
+// UnderLoad says if clients have to validate their address before the server spends resources on the handshake.
+func (l *connectionLimiter) UnderLoad() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.underLoad()
+}
+
+func (l *connectionLimiter) underLoad() bool {
+	return l.addressValidationThreshold > 0 && l.numHandshakes >= l.addressValidationThreshold
+}
+
+// AcceptCookie wraps the AcceptCookie callback of the Config.
+// While the server is under load, it only accepts valid cookies, even if the callback would accept the client.
+func (l *connectionLimiter) AcceptCookie(acceptCookie func(net.Addr, *Cookie) bool) func(net.Addr, *Cookie) bool {
+	return func(clientAddr net.Addr, cookie *Cookie) bool {
+		if !acceptCookie(clientAddr, cookie) {
+			return false
+		}
+		if !l.UnderLoad() || defaultAcceptCookie(clientAddr, cookie) {
+			return true
+		}
+		l.mutex.Lock()
+		l.stats.EnforcedAddressValidations++
+		l.mutex.Unlock()
+		return false
+	}
+}
+
+// Stats returns a snapshot of the counters.
+func (l *connectionLimiter) Stats() ListenerStats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	stats := l.stats
+	stats.ActiveSessions = uint64(l.numSessions)
+	stats.ActiveHandshakes = uint64(l.numHandshakes)
+	stats.UnderLoad = l.underLoad()
+	return stats
+}
+
+func sourceIP(addr net.Addr) string {
+	if udpAddr, ok := addr.(*net.UDPAddr); ok {
+		return udpAddr.IP.String()
+	}
+	return addr.String()
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastUpdate time.Time
+}
+
+// The sourceRateLimiter limits the rate of new connections per source IP.
+// It uses a token bucket for every IP, which allows a burst of as many connections as are allowed per second.
+// It is not safe for concurrent use.
+type sourceRateLimiter struct {
+	rate    float64 // tokens per second
+	buckets map[string]*tokenBucket
+
+	lastCleanup time.Time
+}
+
+func newSourceRateLimiter(ratePerSecond int) *sourceRateLimiter {
+	return &sourceRateLimiter{
+		rate:    float64(ratePerSecond),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (r *sourceRateLimiter) Allow(ip string, now time.Time) bool {
+	r.maybeCleanup(now)
+	b, ok := r.buckets[ip]
+	if !ok {
+		b = &tokenBucket{tokens: r.rate, lastUpdate: now}
+		r.buckets[ip] = b
+	}
+	r.refill(b, now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Refund returns a token that was taken by Allow.
+func (r *sourceRateLimiter) Refund(ip string) {
+	b, ok := r.buckets[ip]
+	if !ok { // the bucket was already full, and has been deleted
+		return
+	}
+	b.tokens++
+	if b.tokens > r.rate {
+		b.tokens = r.rate
+	}
+}
+
+func (r *sourceRateLimiter) refill(b *tokenBucket, now time.Time) {
+	if now.After(b.lastUpdate) {
+		b.tokens += now.Sub(b.lastUpdate).Seconds() * r.rate
+		if b.tokens > r.rate {
+			b.tokens = r.rate
+		}
+		b.lastUpdate = now
+	}
+}
+
+// maybeCleanup deletes buckets that are full again.
+// A full bucket behaves exactly like a bucket that doesn't exist.
+// Buckets are refilled within one second, so it's sufficient to clean up once per second.
+func (r *sourceRateLimiter) maybeCleanup(now time.Time) {
+	if now.Sub(r.lastCleanup) < time.Second {
+		return
+	}
+	r.lastCleanup = now
+	for ip, b := range r.buckets {
+		r.refill(b, now)
+		if b.tokens >= r.rate {
+			delete(r.buckets, ip)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package quic
+
+import (
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connection Limiter", func() {
+	var (
+		addr1 = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}
+		addr2 = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 2), Port: 1337}
+	)
+
+	It("doesn't limit anything by default", func() {
+		l := newConnectionLimiter(&Config{})
+		now := time.Now()
+		for i := 0; i < 1000; i++ {
+			Expect(l.Reserve(addr1, now)).To(BeTrue())
+		}
+		Expect(l.UnderLoad()).To(BeFalse())
+		stats := l.Stats()
+		Expect(stats.ActiveSessions).To(BeEquivalentTo(1000))
+		Expect(stats.ActiveHandshakes).To(BeEquivalentTo(1000))
+	})
+
+	It("releases reservations", func() {
+		l := newConnectionLimiter(&Config{MaxIncomingSessions: 1})
+		Expect(l.Reserve(addr1, time.Now())).To(BeTrue())
+		Expect(l.Reserve(addr1, time.Now())).To(BeFalse())
+		l.Release(addr1)
+		Expect(l.Stats().ActiveSessions).To(BeZero())
+		Expect(l.Stats().ActiveHandshakes).To(BeZero())
+		Expect(l.Reserve(addr1, time.Now())).To(BeTrue())
+	})
+
+	It("counts created sessions", func() {
+		l := newConnectionLimiter(&Config{})
+		Expect(l.Reserve(addr1, time.Now())).To(BeTrue())
+		l.SessionCreated()
+		l.HandshakeFinished()
+		l.SessionClosed()
+		stats := l.Stats()
+		Expect(stats.SessionsCreated).To(BeEquivalentTo(1))
+		Expect(stats.ActiveSessions).To(BeZero())
+		Expect(stats.ActiveHandshakes).To(BeZero())
+	})
+
+	It("limits the number of sessions", func() {
+		l := newConnectionLimiter(&Config{MaxIncomingSessions: 2})
+		Expect(l.Reserve(addr1, time.Now())).To(BeTrue())
+		Expect(l.Reserve(addr2, time.Now())).To(BeTrue())
+		l.HandshakeFinished()
+		l.HandshakeFinished()
+		Expect(l.Reserve(addr1, time.Now())).To(BeFalse())
+		Expect(l.Stats().RefusedSessionLimit).To(BeEquivalentTo(1))
+		l.SessionClosed()
+		Expect(l.Reserve(addr1, time.Now())).To(BeTrue())
+	})
+
+	It("limits the number of handshakes", func() {
+		l := newConnectionLimiter(&Config{MaxIncomingHandshakes: 2})
+		Expect(l.Reserve(addr1, time.Now())).To(BeTrue())
+		Expect(l.Reserve(addr2, time.Now())).To(BeTrue())
+		Expect(l.Reserve(addr1, time.Now())).To(BeFalse())
+		Expect(l.Stats().RefusedHandshakeLimit).To(BeEquivalentTo(1))
+		l.HandshakeFinished()
+		Expect(l.Reserve(addr1, time.Now())).To(BeTrue())
+		Expect(l.Stats().ActiveSessions).To(BeEquivalentTo(3))
+	})
+
+	Context("rate limiting", func() {
+		It("allows a burst, and then limits the rate per IP", func() {
+			l := newConnectionLimiter(&Config{MaxIncomingSessionRatePerIP: 4})
+			now := time.Now()
+			for i := 0; i < 4; i++ {
+				Expect(l.Reserve(addr1, now)).To(BeTrue())
+			}
+			Expect(l.Reserve(addr1, now)).To(BeFalse())
+			Expect(l.Stats().RefusedRateLimit).To(BeEquivalentTo(1))
+			// other IPs are not affected
+			Expect(l.Reserve(addr2, now)).To(BeTrue())
+			// one connection every 250ms
+			Expect(l.Reserve(addr1, now.Add(200*time.Millisecond))).To(BeFalse())
+			Expect(l.Reserve(addr1, now.Add(250*time.Millisecond))).To(BeTrue())
+			Expect(l.Reserve(addr1, now.Add(250*time.Millisecond))).To(BeFalse())
+			Expect(l.Stats().RefusedRateLimit).To(BeEquivalentTo(3))
+		})
+
+		It("returns the token when a reservation is released", func() {
+			l := newConnectionLimiter(&Config{MaxIncomingSessionRatePerIP: 1})
+			now := time.Now()
+			Expect(l.Reserve(addr1, now)).To(BeTrue())
+			l.Release(addr1)
+			Expect(l.Reserve(addr1, now)).To(BeTrue())
+			Expect(l.Reserve(addr1, now)).To(BeFalse())
+		})
+
+		It("doesn't refund more tokens than the rate", func() {
+			r := newSourceRateLimiter(2)
+			now := time.Now()
+			Expect(r.Allow("192.168.0.1", now)).To(BeTrue())
+			r.Refund("192.168.0.1")
+			r.Refund("192.168.0.1")
+			Expect(r.Allow("192.168.0.1", now)).To(BeTrue())
+			Expect(r.Allow("192.168.0.1", now)).To(BeTrue())
+			Expect(r.Allow("192.168.0.1", now)).To(BeFalse())
+		})
+
+		It("doesn't allow bursts larger than the rate", func() {
+			r := newSourceRateLimiter(2)
+			now := time.Now()
+			Expect(r.Allow("192.168.0.1", now)).To(BeTrue())
+			now = now.Add(time.Hour)
+			Expect(r.Allow("192.168.0.1", now)).To(BeTrue())
+			Expect(r.Allow("192.168.0.1", now)).To(BeTrue())
+			Expect(r.Allow("192.168.0.1", now)).To(BeFalse())
+		})
+
+		It("deletes the state for IPs that didn't connect recently", func() {
+			r := newSourceRateLimiter(2)
+			now := time.Now()
+			Expect(r.Allow("192.168.0.1", now)).To(BeTrue())
+			Expect(r.Allow("192.168.0.2", now)).To(BeTrue())
+			Expect(r.buckets).To(HaveLen(2))
+			now = now.Add(500 * time.Millisecond)
+			Expect(r.Allow("192.168.0.2", now)).To(BeTrue())
+			Expect(r.Allow("192.168.0.2", now)).To(BeTrue())
+			now = now.Add(600 * time.Millisecond)
+			Expect(r.Allow("192.168.0.3", now)).To(BeTrue())
+			// the bucket for 192.168.0.1 is full again, the one for 192.168.0.2 isn't
+			Expect(r.buckets).To(HaveLen(2))
+			Expect(r.buckets).To(HaveKey("192.168.0.2"))
+			Expect(r.buckets).To(HaveKey("192.168.0.3"))
+		})
+	})
+
+	Context("address validation", func() {
+		var acceptAll = func(net.Addr, *Cookie) bool { return true }
+
+		It("is under load when the threshold is reached", func() {
+			l := newConnectionLimiter(&Config{AddressValidationThreshold: 2})
+			Expect(l.Reserve(addr1, time.Now())).To(BeTrue())
+			Expect(l.UnderLoad()).To(BeFalse())
+			Expect(l.Reserve(addr2, time.Now())).To(BeTrue())
+			Expect(l.UnderLoad()).To(BeTrue())
+			Expect(l.Stats().UnderLoad).To(BeTrue())
+			l.HandshakeFinished()
+			Expect(l.UnderLoad()).To(BeFalse())
+		})
+
+		It("uses the callback when not under load", func() {
+			l := newConnectionLimiter(&Config{AddressValidationThreshold: 1})
+			Expect(l.AcceptCookie(acceptAll)(addr1, nil)).To(BeTrue())
+			acceptNone := func(net.Addr, *Cookie) bool { return false }
+			Expect(l.AcceptCookie(acceptNone)(addr1, nil)).To(BeFalse())
+			Expect(l.Stats().EnforcedAddressValidations).To(BeZero())
+		})
+
+		It("requires a valid cookie when under load", func() {
+			l := newConnectionLimiter(&Config{AddressValidationThreshold: 1})
+			Expect(l.Reserve(addr1, time.Now())).To(BeTrue())
+			acceptCookie := l.AcceptCookie(acceptAll)
+			Expect(acceptCookie(addr1, nil)).To(BeFalse())
+			Expect(acceptCookie(addr1, &Cookie{RemoteAddr: "192.168.0.2", SentTime: time.Now()})).To(BeFalse())
+			Expect(l.Stats().EnforcedAddressValidations).To(BeEquivalentTo(2))
+			Expect(acceptCookie(addr1, &Cookie{RemoteAddr: "192.168.0.1", SentTime: time.Now()})).To(BeTrue())
+		})
+
+		It("doesn't accept cookies rejected by the callback when under load", func() {
+			l := newConnectionLimiter(&Config{AddressValidationThreshold: 1})
+			Expect(l.Reserve(addr1, time.Now())).To(BeTrue())
+			acceptNone := func(net.Addr, *Cookie) bool { return false }
+			Expect(l.AcceptCookie(acceptNone)(addr1, &Cookie{RemoteAddr: "192.168.0.1", SentTime: time.Now()})).To(BeFalse())
+			Expect(l.Stats().EnforcedAddressValidations).To(BeZero())
+		})
+	})
+})
@@ -0,0 +1,163 @@
+package quic
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+var errDatagramsNotNegotiated = errors.New("datagram support not negotiated")
+
+// maxDatagramFrameSize is the maximum size of DATAGRAM frames that we accept, as sent in the transport parameters.
+// It is 0 if datagram support is disabled.
+func maxDatagramFrameSize(config *Config) protocol.ByteCount {
+	if !config.EnableDatagrams {
+		return 0
+	}
+	return protocol.MaxReceivePacketSize
+}
+
+// A queuedDatagram is a DATAGRAM frame waiting to be sent.
+// sent is closed when the frame is packed into a packet.
+type queuedDatagram struct {
+	frame *wire.DatagramFrame
+	sent  chan struct{}
+}
+
+type datagramQueue struct {
+	sendQueue chan *queuedDatagram
+	next      *queuedDatagram // only accessed from the run loop
+
+	mutex sync.Mutex
+	// the maximum size of a DATAGRAM frame that can be sent, 0 if the peer doesn't support datagrams
+	maxFrameSize protocol.ByteCount
+	rcvQueue     [][]byte
+	rcvd         chan struct{} // used to notify Receive that a new datagram was received
+
+	closeErr error
+	closed   chan struct{}
+
+	hasData func()
+}
+
+func newDatagramQueue(hasData func()) *datagramQueue {
+	return &datagramQueue{
+		sendQueue: make(chan *queuedDatagram, 1),
+		rcvd:      make(chan struct{}, 1),
+		closed:    make(chan struct{}),
+		hasData:   hasData,
+	}
+}
+
+// SetMaxFrameSize sets the maximum size of DATAGRAM frames that are sent.
+// It is called when the transport parameters of the peer are received.
+func (h *datagramQueue) SetMaxFrameSize(s protocol.ByteCount) {
+	h.mutex.Lock()
+	h.maxFrameSize = utils.MinByteCount(s, protocol.MaxDatagramFrameSize)
+	h.mutex.Unlock()
+}
+
+// AddAndWait queues a new DATAGRAM frame for sending.
+// It blocks until the frame has been packed into a packet.
+func (h *datagramQueue) AddAndWait(f *wire.DatagramFrame) error {
+	h.mutex.Lock()
+	maxFrameSize := h.maxFrameSize
+	h.mutex.Unlock()
+	if maxFrameSize == 0 {
+		return errDatagramsNotNegotiated
+	}
+	if l := f.Length(protocol.VersionWhatever); l > maxFrameSize {
+		return fmt.Errorf("DATAGRAM frame too large (%d bytes, maximum %d bytes)", l, maxFrameSize)
+	}
+
+	d := &queuedDatagram{frame: f, sent: make(chan struct{})}
+	select {
+	case h.sendQueue <- d:
+		h.hasData()
+	case <-h.closed:
+		return h.closeErr
+	}
+
+	select {
+	case <-d.sent:
+		return nil
+	case <-h.closed:
+		return h.closeErr
+	}
+}
+
+// Peek gets the next DATAGRAM frame for sending.
+// If actually sent out, Pop needs to be called before the next call to Peek.
+func (h *datagramQueue) Peek() *wire.DatagramFrame {
+	if h.next == nil {
+		select {
+		case h.next = <-h.sendQueue:
+		default:
+			return nil
+		}
+	}
+	return h.next.frame
+}
+
+// Pop removes the frame returned by Peek.
+// It is called when the frame was packed, and unblocks the corresponding call to AddAndWait.
+func (h *datagramQueue) Pop() {
+	if h.next == nil {
+		panic("datagramQueue BUG: Pop called for nil frame")
+	}
+	close(h.next.sent)
+	h.next = nil
+}
+
+// HandleDatagramFrame handles a received DATAGRAM frame.
+func (h *datagramQueue) HandleDatagramFrame(f *wire.DatagramFrame) {
+	data := make([]byte, len(f.Data))
+	copy(data, f.Data)
+	var queued bool
+	h.mutex.Lock()
+	if len(h.rcvQueue) < protocol.DatagramRcvQueueLen {
+		h.rcvQueue = append(h.rcvQueue, data)
+		queued = true
+		select {
+		case h.rcvd <- struct{}{}:
+		default:
+		}
+	}
+	h.mutex.Unlock()
+	if !queued {
+		utils.Debugf("Discarding DATAGRAM frame (%d bytes payload)", len(f.Data))
+	}
+}
+
+// Receive gets a received DATAGRAM frame.
+// It blocks until a DATAGRAM frame is received, or the queue is closed.
+func (h *datagramQueue) Receive() ([]byte, error) {
+	for {
+		h.mutex.Lock()
+		if len(h.rcvQueue) > 0 {
+			data := h.rcvQueue[0]
+			h.rcvQueue = h.rcvQueue[1:]
+			h.mutex.Unlock()
+			return data, nil
+		}
+		h.mutex.Unlock()
+		select {
+		case <-h.rcvd:
+			continue
+		case <-h.closed:
+			return nil, h.closeErr
+		}
+	}
+}
+
+// CloseWithError closes the queue.
+// Calls to AddAndWait and Receive return the error.
+// It must only be called once.
+func (h *datagramQueue) CloseWithError(e error) {
+	h.closeErr = e
+	close(h.closed)
+}
@@ -0,0 +1,183 @@
+package quic
+
+import (
+	"errors"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Datagram Queue", func() {
+	var (
+		queue          *datagramQueue
+		queued         chan struct{}
+		maxDatagramLen protocol.ByteCount
+	)
+
+	BeforeEach(func() {
+		queued = make(chan struct{}, 100)
+		queue = newDatagramQueue(func() { queued <- struct{}{} })
+		queue.SetMaxFrameSize(protocol.MaxDatagramFrameSize)
+		maxDatagramLen = protocol.MaxDatagramFrameSize - (&wire.DatagramFrame{DataLenPresent: true}).Length(protocol.VersionWhatever)
+	})
+
+	Context("sending", func() {
+		It("returns nil when there's no datagram to send", func() {
+			Expect(queue.Peek()).To(BeNil())
+		})
+
+		It("queues a datagram", func() {
+			done := make(chan struct{})
+			f := &wire.DatagramFrame{DataLenPresent: true, Data: []byte("foobar")}
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(queue.AddAndWait(f)).To(Succeed())
+			}()
+
+			Eventually(queued).Should(HaveLen(1))
+			Consistently(done).ShouldNot(BeClosed())
+			Expect(queue.Peek()).To(Equal(f))
+			// Peek returns the same frame until it is popped
+			Expect(queue.Peek()).To(Equal(f))
+			// AddAndWait only returns when the frame was packed
+			Consistently(done).ShouldNot(BeClosed())
+			queue.Pop()
+			Eventually(done).Should(BeClosed())
+			Expect(queue.Peek()).To(BeNil())
+		})
+
+		It("unblocks the caller whose frame was sent", func() {
+			f1 := &wire.DatagramFrame{DataLenPresent: true, Data: []byte("foo")}
+			f2 := &wire.DatagramFrame{DataLenPresent: true, Data: []byte("bar")}
+			done1 := make(chan struct{})
+			done2 := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done1)
+				Expect(queue.AddAndWait(f1)).To(Succeed())
+			}()
+			Eventually(queued).Should(HaveLen(1))
+			Expect(queue.Peek()).To(Equal(f1))
+			go func() {
+				defer GinkgoRecover()
+				defer close(done2)
+				Expect(queue.AddAndWait(f2)).To(Succeed())
+			}()
+			Eventually(queued).Should(HaveLen(2))
+			Consistently(done1).ShouldNot(BeClosed())
+			queue.Pop()
+			Eventually(done1).Should(BeClosed())
+			Consistently(done2).ShouldNot(BeClosed())
+			Expect(queue.Peek()).To(Equal(f2))
+			queue.Pop()
+			Eventually(done2).Should(BeClosed())
+		})
+
+		It("panics when Pop is called without a frame", func() {
+			Expect(func() { queue.Pop() }).To(Panic())
+		})
+
+		It("errors if the peer doesn't support datagrams", func() {
+			queue = newDatagramQueue(func() {})
+			err := queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foobar")})
+			Expect(err).To(MatchError(errDatagramsNotNegotiated))
+		})
+
+		It("limits the frame size to the value sent by the peer", func() {
+			queue.SetMaxFrameSize(100)
+			err := queue.AddAndWait(&wire.DatagramFrame{Data: make([]byte, 100)})
+			Expect(err).To(MatchError("DATAGRAM frame too large (101 bytes, maximum 100 bytes)"))
+		})
+
+		It("limits the frame size to the maximum DATAGRAM frame size", func() {
+			queue.SetMaxFrameSize(protocol.MaxDatagramFrameSize + 1000)
+			err := queue.AddAndWait(&wire.DatagramFrame{DataLenPresent: true, Data: make([]byte, maxDatagramLen+1)})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("DATAGRAM frame too large"))
+		})
+
+		It("unblocks AddAndWait when the queue is closed", func() {
+			testErr := errors.New("test error")
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foobar")})).To(MatchError(testErr))
+			}()
+
+			Consistently(done).ShouldNot(BeClosed())
+			queue.CloseWithError(testErr)
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Context("receiving", func() {
+		It("receives DATAGRAM frames", func() {
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foo")})
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("bar")})
+			data, err := queue.Receive()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foo")))
+			data, err = queue.Receive()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("bar")))
+		})
+
+		It("copies the data", func() {
+			b := []byte("foobar")
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: b})
+			b[0] = 'x'
+			data, err := queue.Receive()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foobar")))
+		})
+
+		It("blocks until a frame is received", func() {
+			c := make(chan []byte)
+			go func() {
+				defer GinkgoRecover()
+				data, err := queue.Receive()
+				Expect(err).ToNot(HaveOccurred())
+				c <- data
+			}()
+
+			Consistently(c).ShouldNot(Receive())
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foobar")})
+			Eventually(c).Should(Receive(Equal([]byte("foobar"))))
+		})
+
+		It("drops DATAGRAM frames when the receive queue is full", func() {
+			for i := 0; i < protocol.DatagramRcvQueueLen; i++ {
+				queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte{uint8(i)}})
+			}
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foobar")})
+			for i := 0; i < protocol.DatagramRcvQueueLen; i++ {
+				data, err := queue.Receive()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte{uint8(i)}))
+			}
+			queue.CloseWithError(errors.New("closed"))
+			_, err := queue.Receive()
+			Expect(err).To(MatchError("closed"))
+		})
+
+		It("unblocks Receive when the queue is closed", func() {
+			testErr := errors.New("test error")
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				_, err := queue.Receive()
+				Expect(err).To(MatchError(testErr))
+			}()
+
+			Consistently(done).ShouldNot(BeClosed())
+			queue.CloseWithError(testErr)
+			Eventually(done).Should(BeClosed())
+		})
+	})
+})
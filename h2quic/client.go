@@ -46,10 +46,18 @@ type client struct {
 	requestWriter *requestWriter
 
 	responses map[protocol.StreamID]chan *http.Response
+
+	goingAway    bool
+	lastStreamID protocol.StreamID // the last stream ID that the server will process, taken from the GOAWAY frame
+	goAwayChan   chan struct{}     // this channel is closed when a GOAWAY frame is received
 }
 
 var _ http.RoundTripper = &client{}
 
+// ErrGoAway is returned for requests that were not processed by the server, because it is going away.
+// These requests can safely be retried on a new connection.
+var ErrGoAway = errors.New("h2quic: server is going away, request was not processed")
+
 var defaultQuicConfig = &quic.Config{
 	RequestConnectionIDOmission: true,
 	KeepAlive:                   true,
@@ -74,6 +82,7 @@ func newClient(
 		config:        config,
 		opts:          opts,
 		headerErrored: make(chan struct{}),
+		goAwayChan:    make(chan struct{}),
 		dialer:        dialer,
 	}
 }
@@ -119,6 +128,20 @@ func (c *client) readResponse(h2framer *http2.Framer, decoder *hpack.Decoder) er
 	if err != nil {
 		return err
 	}
+	if f, ok := frame.(*http2.GoAwayFrame); ok {
+		// The server will continue processing all requests up to the last stream ID,
+		// and close the session afterwards.
+		// Requests on higher streams were not processed, and need to be retried on a new session.
+		utils.Infof("Server is going away. Last processed data stream: %d", f.LastStreamID)
+		c.mutex.Lock()
+		if !c.goingAway {
+			c.goingAway = true
+			c.lastStreamID = protocol.StreamID(f.LastStreamID)
+			close(c.goAwayChan)
+		}
+		c.mutex.Unlock()
+		return nil
+	}
 	hframe, ok := frame.(*http2.HeadersFrame)
 	if !ok {
 		return errors.New("not a headers frame")
@@ -162,6 +185,14 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 		return nil, c.handshakeErr
 	}
 
+	c.mutex.RLock()
+	goingAway := c.goingAway
+	c.mutex.RUnlock()
+	// don't open new streams on a session that the server is closing
+	if goingAway {
+		return nil, ErrGoAway
+	}
+
 	hasBody := (req.Body != nil)
 
 	responseChan := make(chan *http.Response)
@@ -203,6 +234,7 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 	}
 
 	ctx := req.Context()
+	goAwayChan := c.goAwayChan
 	for !(bodySent && receivedResponse) {
 		select {
 		case res = <-responseChan:
@@ -223,6 +255,20 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 			delete(c.responses, dataStream.StreamID())
 			c.mutex.Unlock()
 			return nil, ctx.Err()
+		case <-goAwayChan:
+			goAwayChan = nil
+			c.mutex.RLock()
+			processed := dataStream.StreamID() <= c.lastStreamID
+			c.mutex.RUnlock()
+			if processed {
+				continue
+			}
+			dataStream.CancelRead(6)
+			dataStream.CancelWrite(6)
+			c.mutex.Lock()
+			delete(c.responses, dataStream.StreamID())
+			c.mutex.Unlock()
+			return nil, ErrGoAway
 		case <-c.headerErrored:
 			// an error occurred on the header stream
 			_ = c.CloseWithError(c.headerErr)
@@ -292,6 +292,66 @@ var _ = Describe("Client", func() {
 			Expect(client.headerErrored).ToNot(BeClosed())
 		})
 
+		Context("receiving a GOAWAY frame", func() {
+			receiveGoAway := func(lastStreamID uint32) {
+				b := &bytes.Buffer{}
+				Expect(http2.NewFramer(b, nil).WriteGoAway(lastStreamID, http2.ErrCodeNo, nil)).To(Succeed())
+				Expect(client.readResponse(http2.NewFramer(nil, b), hpack.NewDecoder(4096, nil))).To(Succeed())
+			}
+
+			It("fails requests on streams above the last stream ID", func() {
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					rsp, err := client.RoundTrip(request)
+					Expect(err).To(MatchError(ErrGoAway))
+					Expect(rsp).To(BeNil())
+					close(done)
+				}()
+
+				Eventually(func() bool {
+					client.mutex.Lock()
+					defer client.mutex.Unlock()
+					_, ok := client.responses[5]
+					return ok
+				}).Should(BeTrue())
+				receiveGoAway(3)
+				Eventually(done).Should(BeClosed())
+				Expect(dataStream.reset).To(BeTrue())
+				Expect(dataStream.canceledWrite).To(BeTrue())
+				Expect(client.responses).ToNot(HaveKey(protocol.StreamID(5)))
+			})
+
+			It("completes requests on streams up to the last stream ID", func() {
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					_, err := client.RoundTrip(request)
+					Expect(err).ToNot(HaveOccurred())
+					close(done)
+				}()
+
+				Eventually(func() bool {
+					client.mutex.Lock()
+					defer client.mutex.Unlock()
+					_, ok := client.responses[5]
+					return ok
+				}).Should(BeTrue())
+				receiveGoAway(5)
+				Consistently(done).ShouldNot(BeClosed())
+				injectResponse(5, &http.Response{})
+				Eventually(done).Should(BeClosed())
+				Expect(dataStream.reset).To(BeFalse())
+			})
+
+			It("doesn't open new streams", func() {
+				session.streamsToOpen = []quic.Stream{headerStream}
+				receiveGoAway(3)
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError(ErrGoAway))
+			})
+		})
+
 		It("closes the quic client when encountering an error on the header stream", func() {
 			headerStream.dataToRead.Write(bytes.Repeat([]byte{0}, 100))
 			done := make(chan struct{})
@@ -606,6 +666,20 @@ var _ = Describe("Client", func() {
 				Expect(client.headerErr).To(MatchError(qerr.Error(qerr.InvalidHeadersStreamData, "not a headers frame")))
 			})
 
+			It("handles GOAWAY frames", func() {
+				h2framer.WriteGoAway(23, http2.ErrCodeNo, nil)
+				h2framer.WriteGoAway(42, http2.ErrCodeNo, nil)
+				h2framer.WritePing(true, [8]byte{0, 0, 0, 0, 0, 0, 0, 0})
+				client.handleHeaderStream()
+				Eventually(client.headerErrored).Should(BeClosed())
+				// the error is caused by the PING frame
+				Expect(client.headerErr).To(MatchError(qerr.Error(qerr.InvalidHeadersStreamData, "not a headers frame")))
+				Expect(client.goAwayChan).To(BeClosed())
+				Expect(client.goingAway).To(BeTrue())
+				// only the first GOAWAY frame is used
+				Expect(client.lastStreamID).To(Equal(protocol.StreamID(23)))
+			})
+
 			It("errors if it can't read the HPACK encoded header fields", func() {
 				h2framer.WriteHeaders(http2.HeadersFrameParam{
 					StreamID:      23,
@@ -104,7 +104,22 @@ func (r *RoundTripper) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.
 	if err != nil {
 		return nil, err
 	}
-	return cl.RoundTrip(req)
+	rsp, err := cl.RoundTrip(req)
+	if err != ErrGoAway {
+		return rsp, err
+	}
+	// The server didn't process the request, since it is going away.
+	// Don't use this connection for any new requests, and retry the request on a new connection.
+	r.removeClient(hostname, cl)
+	retryReq, ok := rewindRequest(req)
+	if !ok {
+		return nil, err
+	}
+	cl, err = r.getClient(hostname, opt.OnlyCachedConn)
+	if err != nil {
+		return nil, err
+	}
+	return cl.RoundTrip(retryReq)
 }
 
 // RoundTrip does a round trip.
@@ -112,7 +127,7 @@ func (r *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	return r.RoundTripOpt(req, RoundTripOpt{})
 }
 
-func (r *RoundTripper) getClient(hostname string, onlyCached bool) (http.RoundTripper, error) {
+func (r *RoundTripper) getClient(hostname string, onlyCached bool) (roundTripCloser, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -137,6 +152,15 @@ func (r *RoundTripper) getClient(hostname string, onlyCached bool) (http.RoundTr
 	return client, nil
 }
 
+// removeClient removes a client, if it is still the client used for this hostname
+func (r *RoundTripper) removeClient(hostname string, cl roundTripCloser) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.clients[hostname] == cl {
+		delete(r.clients, hostname)
+	}
+}
+
 // Close closes the QUIC connections that this RoundTripper has used
 func (r *RoundTripper) Close() error {
 	r.mutex.Lock()
@@ -150,6 +174,24 @@ func (r *RoundTripper) Close() error {
 	return nil
 }
 
+// rewindRequest returns a request that can be sent again.
+// This is only possible if the request doesn't have a body, or if the body can be obtained again.
+func rewindRequest(req *http.Request) (*http.Request, bool) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, true
+	}
+	if req.GetBody == nil {
+		return nil, false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, false
+	}
+	newReq := *req
+	newReq.Body = body
+	return &newReq, true
+}
+
 func closeRequestBody(req *http.Request) {
 	if req.Body != nil {
 		req.Body.Close()
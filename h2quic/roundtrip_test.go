@@ -14,10 +14,14 @@ import (
 )
 
 type mockClient struct {
-	closed bool
+	closed    bool
+	goingAway bool
 }
 
 func (m *mockClient) RoundTrip(req *http.Request) (*http.Response, error) {
+	if m.goingAway {
+		return nil, ErrGoAway
+	}
 	return &http.Response{Request: req}, nil
 }
 func (m *mockClient) Close() error {
@@ -127,6 +131,24 @@ var _ = Describe("RoundTripper", func() {
 			Expect(rt.clients).To(HaveLen(1))
 		})
 
+		It("retries requests on a new client if the server is going away", func() {
+			cl := &mockClient{goingAway: true}
+			rt.clients = map[string]roundTripCloser{"www.example.org:443": cl}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(MatchError(streamOpenErr))
+			Expect(rt.clients).To(HaveLen(1))
+			Expect(rt.clients["www.example.org:443"]).ToNot(Equal(cl))
+		})
+
+		It("doesn't retry requests if the body can't be sent again", func() {
+			cl := &mockClient{goingAway: true}
+			rt.clients = map[string]roundTripCloser{"www.example.org:443": cl}
+			req1.Body = &mockBody{}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(MatchError(ErrGoAway))
+			Expect(rt.clients).To(BeEmpty())
+		})
+
 		It("doesn't create new clients if RoundTripOpt.OnlyCachedConn is set", func() {
 			req, err := http.NewRequest("GET", "https://quic.clemente.io/foobar.html", nil)
 			Expect(err).ToNot(HaveOccurred())
@@ -35,6 +35,9 @@ var (
 	quicListenAddr = quic.ListenAddr
 )
 
+// the error code used to reset streams of requests that we didn't process
+const errorCodeRefusedStream = quic.ErrorCode(http2.ErrCodeRefusedStream)
+
 // Server is a HTTP2 server listening for QUIC connections.
 type Server struct {
 	*http.Server
@@ -52,9 +55,22 @@ type Server struct {
 	listener      quic.Listener
 	closed        bool
 
+	sessionsMutex  sync.Mutex
+	sessions       map[streamCreator]*serverSession
+	goingAway      bool          // set by CloseGracefully
+	activeRequests int           // number of requests whose handler is still running
+	requestsDone   chan struct{} // closed when all requests are completed after going away
+
 	supportedVersionsAsString string
 }
 
+// serverSession holds the state of a session needed for sending a GOAWAY frame
+type serverSession struct {
+	headerStream      quic.Stream
+	headerStreamMutex *sync.Mutex
+	lastStreamID      protocol.StreamID // the highest data stream ID of a request we accepted
+}
+
 // ListenAndServe listens on the UDP address s.Addr and calls s.Handler to handle HTTP/2 requests on incoming connections.
 func (s *Server) ListenAndServe() error {
 	if s.Server == nil {
@@ -132,6 +148,12 @@ func (s *Server) handleHeaderStream(session streamCreator) {
 	h2framer := http2.NewFramer(nil, stream)
 
 	var headerStreamMutex sync.Mutex // Protects concurrent calls to Write()
+	if !s.addSession(session, &serverSession{headerStream: stream, headerStreamMutex: &headerStreamMutex}) {
+		session.Close(qerr.Error(qerr.PeerGoingAway, "server shutting down"))
+		return
+	}
+	defer s.removeSession(session)
+
 	for {
 		if err := s.handleRequest(session, stream, &headerStreamMutex, hpackDecoder, h2framer); err != nil {
 			// QuicErrors must originate from stream.Read() returning an error.
@@ -183,11 +205,21 @@ func (s *Server) handleRequest(session streamCreator, headerStream quic.Stream,
 	if dataStream == nil {
 		return nil
 	}
+	// requests sent after we sent the GOAWAY frame are not processed
+	// Reset the stream, so the client knows that it can retry the request on a new connection.
+	if !s.startRequest(session, protocol.StreamID(h2headersFrame.StreamID)) {
+		utils.Debugf("Server is going away. Refusing request on data stream %d", h2headersFrame.StreamID)
+		dataStream.CancelRead(errorCodeRefusedStream)
+		dataStream.CancelWrite(errorCodeRefusedStream)
+		return nil
+	}
 
 	// handleRequest should be as non-blocking as possible to minimize
 	// head-of-line blocking. Potentially blocking code is run in a separate
 	// goroutine, enabling handleRequest to return before the code is executed.
 	go func() {
+		defer s.finishRequest()
+
 		streamEnded := h2headersFrame.StreamEnded()
 		if streamEnded {
 			dataStream.(remoteCloser).CloseRemote(0)
@@ -242,6 +274,52 @@ func (s *Server) handleRequest(session streamCreator, headerStream quic.Stream,
 	return nil
 }
 
+// addSession registers a session after its header stream was accepted.
+// It returns false if the server is already going away.
+func (s *Server) addSession(session streamCreator, sess *serverSession) bool {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+	if s.goingAway {
+		return false
+	}
+	if s.sessions == nil {
+		s.sessions = make(map[streamCreator]*serverSession)
+	}
+	s.sessions[session] = sess
+	return true
+}
+
+func (s *Server) removeSession(session streamCreator) {
+	s.sessionsMutex.Lock()
+	delete(s.sessions, session)
+	s.sessionsMutex.Unlock()
+}
+
+// startRequest registers a request.
+// It returns false if the server is going away, in which case the request must not be processed.
+func (s *Server) startRequest(session streamCreator, id protocol.StreamID) bool {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+	if s.goingAway {
+		return false
+	}
+	if sess, ok := s.sessions[session]; ok && id > sess.lastStreamID {
+		sess.lastStreamID = id
+	}
+	s.activeRequests++
+	return true
+}
+
+func (s *Server) finishRequest() {
+	s.sessionsMutex.Lock()
+	defer s.sessionsMutex.Unlock()
+	s.activeRequests--
+	if s.activeRequests == 0 && s.requestsDone != nil {
+		close(s.requestsDone)
+		s.requestsDone = nil
+	}
+}
+
 // Close the server immediately, aborting requests and sending CONNECTION_CLOSE frames to connected clients.
 // Close in combination with ListenAndServe() (instead of Serve()) may race if it is called before a UDP socket is established.
 func (s *Server) Close() error {
@@ -257,10 +335,43 @@ func (s *Server) Close() error {
 }
 
 // CloseGracefully shuts down the server gracefully. The server sends a GOAWAY frame first, then waits for either timeout to trigger, or for all running requests to complete.
+// New sessions are closed immediately, and the streams of requests received after the GOAWAY frame was sent are reset with REFUSED_STREAM.
 // CloseGracefully in combination with ListenAndServe() (instead of Serve()) may race if it is called before a UDP socket is established.
 func (s *Server) CloseGracefully(timeout time.Duration) error {
-	// TODO: implement
-	return nil
+	s.sessionsMutex.Lock()
+	if s.goingAway {
+		s.sessionsMutex.Unlock()
+		return errors.New("Server is already going away")
+	}
+	// new sessions are closed right away, and requests sent after the GOAWAY are not processed
+	s.goingAway = true
+	requestsDone := make(chan struct{})
+	if s.activeRequests == 0 {
+		close(requestsDone)
+	} else {
+		s.requestsDone = requestsDone
+	}
+	sessions := make([]*serverSession, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.sessionsMutex.Unlock()
+
+	// lastStreamID isn't modified any more once goingAway is set
+	for _, sess := range sessions {
+		sess.headerStreamMutex.Lock()
+		err := http2.NewFramer(sess.headerStream, nil).WriteGoAway(uint32(sess.lastStreamID), http2.ErrCodeNo, nil)
+		sess.headerStreamMutex.Unlock()
+		if err != nil {
+			utils.Errorf("could not write GOAWAY frame: %s", err.Error())
+		}
+	}
+
+	select {
+	case <-requestsDone:
+	case <-time.After(timeout):
+	}
+	return s.Close()
 }
 
 // SetQuicHeaders can be used to set the proper headers that announce that this server supports QUIC.
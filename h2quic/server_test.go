@@ -79,9 +79,31 @@ func (s *mockSession) Context() context.Context {
 	return s.ctx
 }
 func (s *mockSession) ConnectionState() quic.ConnectionState        { panic("not implemented") }
+func (s *mockSession) ConnectionStats() quic.ConnectionStats        { panic("not implemented") }
 func (s *mockSession) AcceptUniStream() (quic.ReceiveStream, error) { panic("not implemented") }
 func (s *mockSession) OpenUniStream() (quic.SendStream, error)      { panic("not implemented") }
 func (s *mockSession) OpenUniStreamSync() (quic.SendStream, error)  { panic("not implemented") }
+func (s *mockSession) SendMessage([]byte) error                     { panic("not implemented") }
+func (s *mockSession) ReceiveMessage() ([]byte, error)              { panic("not implemented") }
+func (s *mockSession) MigrateTo(net.PacketConn) error               { panic("not implemented") }
+func (s *mockSession) UpdateKeys() error                            { panic("not implemented") }
+func (s *mockSession) GoAway(error) error                           { panic("not implemented") }
+
+func (*mockSession) AcceptStreamContext(context.Context) (quic.Stream, error) {
+	panic("not implemented")
+}
+func (*mockSession) AcceptUniStreamContext(context.Context) (quic.ReceiveStream, error) {
+	panic("not implemented")
+}
+func (*mockSession) OpenStreamSyncContext(context.Context) (quic.Stream, error) {
+	panic("not implemented")
+}
+func (*mockSession) OpenUniStreamSyncContext(context.Context) (quic.SendStream, error) {
+	panic("not implemented")
+}
+func (*mockSession) RequestAckFrequency(int, time.Duration, bool) error {
+	panic("not implemented")
+}
 
 var _ = Describe("H2 server", func() {
 	var (
@@ -497,9 +519,116 @@ var _ = Describe("H2 server", func() {
 		}, 0.5)
 	})
 
-	It("closes gracefully", func() {
-		err := s.CloseGracefully(0)
-		Expect(err).NotTo(HaveOccurred())
+	Context("closing gracefully", func() {
+		var headerStream *mockStream
+
+		// the request is sent on data stream 5
+		requestData := []byte{
+			0x0, 0x0, 0x11, 0x1, 0x4, 0x0, 0x0, 0x0, 0x5,
+			// Taken from https://http2.github.io/http2-spec/compression.html#request.examples.with.huffman.coding
+			0x82, 0x86, 0x84, 0x41, 0x8c, 0xf1, 0xe3, 0xc2, 0xe5, 0xf2, 0x3a, 0x6b, 0xa0, 0xab, 0x90, 0xf4, 0xff,
+		}
+
+		getGoAwayFrame := func() *http2.GoAwayFrame {
+			framer := http2.NewFramer(nil, bytes.NewReader(headerStream.dataWritten.Bytes()))
+			for {
+				frame, err := framer.ReadFrame()
+				if err != nil {
+					return nil
+				}
+				if f, ok := frame.(*http2.GoAwayFrame); ok {
+					return f
+				}
+			}
+		}
+
+		BeforeEach(func() {
+			headerStream = newMockStream(3)
+			session.streamToAccept = headerStream
+		})
+
+		It("closes gracefully without any sessions", func() {
+			err := s.CloseGracefully(0)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("sends a GOAWAY frame and waits for running requests to complete", func() {
+			handlerCalled := make(chan struct{})
+			unblockHandler := make(chan struct{})
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(handlerCalled)
+				<-unblockHandler
+			})
+			headerStream.dataToRead.Write(requestData)
+			go s.handleHeaderStream(session)
+			Eventually(handlerCalled).Should(BeClosed())
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				Expect(s.CloseGracefully(time.Hour)).To(Succeed())
+				close(done)
+			}()
+			Eventually(getGoAwayFrame).ShouldNot(BeNil())
+			f := getGoAwayFrame()
+			Expect(f.LastStreamID).To(BeEquivalentTo(5))
+			Expect(f.ErrCode).To(Equal(http2.ErrCodeNo))
+			Consistently(done).ShouldNot(BeClosed())
+			close(unblockHandler)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("closes when the timeout expires", func() {
+			handlerCalled := make(chan struct{})
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(handlerCalled)
+				<-make(chan struct{}) // block forever
+			})
+			headerStream.dataToRead.Write(requestData)
+			go s.handleHeaderStream(session)
+			Eventually(handlerCalled).Should(BeClosed())
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				Expect(s.CloseGracefully(50 * time.Millisecond)).To(Succeed())
+				close(done)
+			}()
+			Consistently(done, 25*time.Millisecond).ShouldNot(BeClosed())
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("refuses requests after going away", func() {
+			var handlerCalled bool
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerCalled = true
+			})
+			go s.handleHeaderStream(session)
+			Eventually(func() int {
+				s.sessionsMutex.Lock()
+				defer s.sessionsMutex.Unlock()
+				return len(s.sessions)
+			}).Should(Equal(1))
+			Expect(s.CloseGracefully(0)).To(Succeed())
+			Expect(getGoAwayFrame().LastStreamID).To(BeZero())
+			err := s.handleRequest(session, headerStream, &sync.Mutex{}, hpack.NewDecoder(4096, nil), http2.NewFramer(nil, bytes.NewReader(requestData)))
+			Expect(err).ToNot(HaveOccurred())
+			Consistently(func() bool { return handlerCalled }).Should(BeFalse())
+			Expect(dataStream.reset).To(BeTrue())
+			Expect(dataStream.canceledWrite).To(BeTrue())
+		})
+
+		It("closes new sessions after going away", func() {
+			Expect(s.CloseGracefully(0)).To(Succeed())
+			s.handleHeaderStream(session)
+			Expect(session.closed).To(BeTrue())
+			Expect(session.closedWithError).To(MatchError(qerr.Error(qerr.PeerGoingAway, "server shutting down")))
+		})
+
+		It("errors when called multiple times", func() {
+			Expect(s.CloseGracefully(0)).To(Succeed())
+			Expect(s.CloseGracefully(0)).To(MatchError("Server is already going away"))
+		})
 	})
 
 	It("errors when listening fails", func() {
@@ -6,8 +6,10 @@ import (
 	"net"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/logging"
 )
 
 // The StreamID is the ID of a QUIC stream.
@@ -16,12 +18,62 @@ type StreamID = protocol.StreamID
 // A VersionNumber is a QUIC version number.
 type VersionNumber = protocol.VersionNumber
 
+// A ConnectionID is a QUIC connection ID.
+type ConnectionID = protocol.ConnectionID
+
+// A ByteCount is used to count bytes.
+type ByteCount = protocol.ByteCount
+
+// A PacketNumber is a QUIC packet number.
+type PacketNumber = protocol.PacketNumber
+
+// RTTStats contains the RTT statistics of a connection.
+// They are updated by the connection, and can be used by the congestion controller.
+type RTTStats = congestion.RTTStats
+
 // A Cookie can be used to verify the ownership of the client address.
 type Cookie = handshake.Cookie
 
+// ClientSessionState contains the state needed by a client to resume a handshake with a server.
+type ClientSessionState = handshake.ClientSessionState
+
+// A ClientSessionCache is a cache of ClientSessionState objects.
+// It is used by clients to resume handshakes with servers.
+type ClientSessionCache = handshake.ClientSessionCache
+
 // ConnectionState records basic details about the QUIC connection.
 type ConnectionState = handshake.ConnectionState
 
+// ConnectionStats is a snapshot of the transport metrics of a QUIC connection.
+type ConnectionStats struct {
+	// MinRTT is the minimum RTT observed on this connection.
+	MinRTT time.Duration
+	// LatestRTT is the most recent RTT sample.
+	LatestRTT time.Duration
+	// SmoothedRTT is the EWMA smoothed RTT.
+	SmoothedRTT time.Duration
+	// MeanDeviation is the mean deviation of the RTT samples.
+	MeanDeviation time.Duration
+	// CongestionWindow is the current congestion window, in bytes.
+	CongestionWindow uint64
+	// BytesInFlight is the number of bytes that were sent, but neither acknowledged nor declared lost yet.
+	BytesInFlight uint64
+	// PacketsSent is the number of packets sent, including retransmissions.
+	PacketsSent uint64
+	// PacketsReceived is the number of packets that were received and successfully decrypted.
+	PacketsReceived uint64
+	// PacketsLost is the number of packets that were declared lost.
+	PacketsLost uint64
+	// PacketsRetransmitted is the number of packets sent that carried retransmitted frames.
+	PacketsRetransmitted uint64
+	// BytesSent is the number of bytes sent, including QUIC packet headers.
+	BytesSent uint64
+	// BytesReceived is the number of bytes received in packets that were successfully decrypted.
+	BytesReceived uint64
+	// SpinBit is the value of the latency spin bit that is currently sent.
+	SpinBit bool
+}
+
 // An ErrorCode is an application-defined error code.
 type ErrorCode = protocol.ApplicationErrorCode
 
@@ -73,6 +125,10 @@ type Stream interface {
 	// with the connection. It is equivalent to calling both
 	// SetReadDeadline and SetWriteDeadline.
 	SetDeadline(t time.Time) error
+	// SetPriority sets the priority of the stream.
+	// It determines the order in which data of different streams is sent.
+	// Warning: This API should not be considered stable and might change soon.
+	SetPriority(StreamPriority)
 }
 
 // A ReceiveStream is a unidirectional Receive Stream.
@@ -101,8 +157,25 @@ type SendStream interface {
 	Context() context.Context
 	// see Stream.SetWriteDeadline
 	SetWriteDeadline(t time.Time) error
+	// see Stream.SetPriority
+	SetPriority(StreamPriority)
 }
 
+// StreamPriority is the priority of a stream.
+// The zero value is not the default priority, see DefaultStreamPriority.
+type StreamPriority struct {
+	// Urgency is a strict priority level.
+	// Streams with a lower urgency are always served before streams with a higher urgency.
+	Urgency uint8
+	// Weight determines the share of streams with the same urgency.
+	// The streams are served round-robin, and a stream sends up to Weight STREAM frames in its turn.
+	// A weight of 0 is treated as 1.
+	Weight uint8
+}
+
+// DefaultStreamPriority is the priority of streams that SetPriority wasn't called on.
+var DefaultStreamPriority = StreamPriority{Urgency: 3, Weight: 1}
+
 // StreamError is returned by Read and Write when the peer cancels the stream.
 type StreamError interface {
 	error
@@ -114,8 +187,12 @@ type StreamError interface {
 type Session interface {
 	// AcceptStream returns the next stream opened by the peer, blocking until one is available.
 	AcceptStream() (Stream, error)
+	// AcceptStreamContext is like AcceptStream, but it returns the context's error when the context is canceled.
+	AcceptStreamContext(context.Context) (Stream, error)
 	// AcceptUniStream returns the next unidirectional stream opened by the peer, blocking until one is available.
 	AcceptUniStream() (ReceiveStream, error)
+	// AcceptUniStreamContext is like AcceptUniStream, but it returns the context's error when the context is canceled.
+	AcceptUniStreamContext(context.Context) (ReceiveStream, error)
 	// OpenStream opens a new bidirectional QUIC stream.
 	// It returns a special error when the peer's concurrent stream limit is reached.
 	// TODO(#1152): Enable testing for the special error
@@ -123,6 +200,8 @@ type Session interface {
 	// OpenStreamSync opens a new bidirectional QUIC stream.
 	// It blocks until the peer's concurrent stream limit allows a new stream to be opened.
 	OpenStreamSync() (Stream, error)
+	// OpenStreamSyncContext is like OpenStreamSync, but it returns the context's error when the context is canceled.
+	OpenStreamSyncContext(context.Context) (Stream, error)
 	// OpenUniStream opens a new outgoing unidirectional QUIC stream.
 	// It returns a special error when the peer's concurrent stream limit is reached.
 	// TODO(#1152): Enable testing for the special error
@@ -130,18 +209,75 @@ type Session interface {
 	// OpenUniStreamSync opens a new outgoing unidirectional QUIC stream.
 	// It blocks until the peer's concurrent stream limit allows a new stream to be opened.
 	OpenUniStreamSync() (SendStream, error)
+	// OpenUniStreamSyncContext is like OpenUniStreamSync, but it returns the context's error when the context is canceled.
+	OpenUniStreamSyncContext(context.Context) (SendStream, error)
 	// LocalAddr returns the local address.
 	LocalAddr() net.Addr
 	// RemoteAddr returns the address of the peer.
 	RemoteAddr() net.Addr
 	// Close closes the connection. The error will be sent to the remote peer in a CONNECTION_CLOSE frame. An error value of nil is allowed and will cause a normal PeerGoingAway to be sent.
 	Close(error) error
+	// GoAway tells the peer that the session is about to be closed, by sending a GOAWAY frame.
+	// Streams that were already opened by the peer are still processed, but new streams are not accepted any more.
+	// The error is sent to the peer. An error value of nil is allowed and will cause a PeerGoingAway to be sent.
+	// GOAWAY frames are only supported in gQUIC.
+	GoAway(error) error
 	// The context is cancelled when the session is closed.
 	// Warning: This API should not be considered stable and might change soon.
 	Context() context.Context
 	// ConnectionState returns basic details about the QUIC connection.
 	// Warning: This API should not be considered stable and might change soon.
 	ConnectionState() ConnectionState
+	// ConnectionStats returns a snapshot of the transport metrics of the connection.
+	// It can be called while the session is running, as well as after it was closed.
+	// Warning: This API should not be considered stable and might change soon.
+	ConnectionStats() ConnectionStats
+	// SendMessage sends a message as an unreliable datagram.
+	// The message is congestion controlled, but it is not retransmitted if the packet carrying it is lost.
+	// It blocks until the message has been packed into a packet.
+	// It doesn't wait for the packet to be acknowledged.
+	// Both endpoints need to enable datagram support using Config.EnableDatagrams.
+	// Warning: This API should not be considered stable and might change soon.
+	SendMessage([]byte) error
+	// ReceiveMessage gets a message received in a datagram.
+	// It blocks until a message is received, or the session is closed.
+	// Warning: This API should not be considered stable and might change soon.
+	ReceiveMessage() ([]byte, error)
+	// MigrateTo moves the session to a new net.PacketConn, e.g. when the network interface changed.
+	// Streams are kept open, and the RTT estimate and the congestion controller are reset.
+	// The old net.PacketConn isn't used any more. It is not closed, so it can be closed by the application.
+	// Only clients can migrate a session, and only after the handshake completed.
+	// Warning: This API should not be considered stable and might change soon.
+	MigrateTo(net.PacketConn) error
+	// UpdateKeys updates the 1-RTT keys.
+	// The update is initiated as soon as the peer has started using the current keys,
+	// i.e. when it acknowledged the previous key update.
+	// It is only supported for IETF QUIC, and only after the handshake completed.
+	// Warning: This API should not be considered stable and might change soon.
+	UpdateKeys() error
+	// RequestAckFrequency asks the peer to send an ACK after receiving packetTolerance retransmittable packets,
+	// and to delay ACKs by no more than maxAckDelay.
+	// If ignoreOrder is set, the peer doesn't send an ACK immediately when packets are received out of order.
+	// It is only supported for IETF QUIC, and only after the handshake completed.
+	// The peer must support the ACK frequency extension.
+	// Warning: This API should not be considered stable and might change soon.
+	RequestAckFrequency(packetTolerance int, maxAckDelay time.Duration, ignoreOrder bool) error
+}
+
+// An EarlySession is a session that can be used before the handshake completes.
+// On the client side, data is sent as 0-RTT data, if the client can resume a previous session with the server.
+// On the server side, the client's 0-RTT data can be received, and responses can be sent before the client's handshake messages arrive (0.5-RTT data).
+// Note that 0-RTT data can be replayed by an attacker.
+type EarlySession interface {
+	Session
+
+	// HandshakeComplete returns a context that is cancelled when the handshake completes, or when the session is closed.
+	// After that, ConnectionState().HandshakeComplete reports if the handshake succeeded,
+	// and ConnectionState().Used0RTT reports if the server accepted the 0-RTT data (IETF QUIC only).
+	// If the server rejected the 0-RTT data, it is retransmitted after the handshake completes.
+	// If the client had to start a new session (e.g. after a version negotiation), the session is closed,
+	// and using it returns Err0RTTRejected. The data then needs to be resent on a new session.
+	HandshakeComplete() context.Context
 }
 
 // Config contains all configuration data needed for a QUIC server or client.
@@ -152,7 +288,8 @@ type Config struct {
 	Versions []VersionNumber
 	// Ask the server to omit the connection ID sent in the Public Header.
 	// This saves 8 bytes in the Public Header in every packet. However, if the IP address of the server changes, the connection cannot be migrated.
-	// Currently only valid for the client.
+	// Currently only valid for the client, and only when dialing with DialAddr,
+	// since packets without a connection ID can't be demultiplexed on a shared net.PacketConn.
 	RequestConnectionIDOmission bool
 	// HandshakeTimeout is the maximum duration that the cryptographic handshake may take.
 	// If the timeout is exceeded, the connection is closed.
@@ -185,14 +322,146 @@ type Config struct {
 	MaxIncomingUniStreams int
 	// KeepAlive defines whether this peer will periodically send PING frames to keep the connection alive.
 	KeepAlive bool
+	// EnableDatagrams enables the unreliable datagram extension.
+	// It is negotiated during the handshake, and can only be used if both peers enable it.
+	// Warning: This API should not be considered stable and will change soon.
+	EnableDatagrams bool
+	// Tracer is used to trace events on new connections.
+	// If not set, connections are not traced.
+	// Warning: This API should not be considered stable and will change soon.
+	Tracer logging.Tracer
+	// GetLogWriter is called for every new session.
+	// If it returns a non-nil io.WriteCloser, a qlog-style JSON trace of the session is written to it.
+	// The io.WriteCloser is closed when the session is closed.
+	// Warning: This API should not be considered stable and will change soon.
+	GetLogWriter func(connID ConnectionID) io.WriteCloser
+	// CongestionControl creates the congestion controller for a new session.
+	// Built-in choices are CongestionControlCubic, CongestionControlNewReno, CongestionControlBBR
+	// and CongestionControlLEDBAT, a low-priority congestion controller for background transfers.
+	// If not set, CUBIC is used.
+	// Warning: This API should not be considered stable and will change soon.
+	CongestionControl func(rttStats *RTTStats) SendAlgorithm
+	// StatelessResetKey is the static key used to derive the stateless reset tokens of a server's connections.
+	// The tokens are sent to the client during the handshake, and authenticate Public Resets.
+	// Servers using the same key can reset connections they don't have any state for, e.g. after a restart.
+	// It must be at least 32 bytes long. If not set, a random key is generated when the server is started.
+	// It is only used by the server.
+	StatelessResetKey []byte
+	// KeyUpdateInterval is the number of packets sent with the same 1-RTT keys, after which the keys are updated.
+	// Packets sent by the peer after it updated its keys are decrypted transparently.
+	// If not set, it will default to 2^20 packets.
+	// This value doesn't have any effect in Google QUIC.
+	KeyUpdateInterval uint64
+	// MaxPacketSize is the maximum size of packets sent, not including the IP and UDP headers.
+	// Connections start out sending 1200 byte packets. Once the handshake completes, path MTU discovery
+	// probes the path for larger packet sizes up to this value.
+	// If not set, it will default to 1452 bytes, the largest packet that fits into an Ethernet frame.
+	// Values larger than 8952 bytes, the largest packet that fits into an Ethernet jumbo frame, are not supported.
+	// Values smaller than 1200 bytes are invalid. Setting it to 1200 disables path MTU discovery.
+	// Path MTU discovery is currently only supported on Linux.
+	MaxPacketSize ByteCount
+	// MaxAckDelay is the maximum time that an ACK for a retransmittable packet is delayed.
+	// It is announced to the peer, which uses it to estimate the RTT and to set its retransmission timer.
+	// If not set, it will default to 25 ms. Values larger than 2^14 - 1 ms are not supported.
+	// Values smaller than 1 ms are increased to 1 ms, the smallest ACK delay that the peer can request.
+	MaxAckDelay time.Duration
+	// RetransmittablePacketsBeforeAck is the number of retransmittable packets after which an ACK is sent immediately.
+	// Larger values reduce the number of ACKs sent (ACK decimation), which saves CPU and upstream bandwidth,
+	// but slow down loss recovery and congestion window growth on the peer's side.
+	// Setting it to 1 acknowledges every retransmittable packet immediately.
+	// If not set, it will default to 10 packets.
+	// The peer can change the ACK policy for the connection using Session.RequestAckFrequency.
+	RetransmittablePacketsBeforeAck int
+	// MaxIncomingSessions is the maximum number of concurrent sessions, including sessions that are still handshaking.
+	// New connections are dropped while the limit is reached.
+	// If not set, the number of sessions is not limited.
+	// It is only used by the server.
+	MaxIncomingSessions int
+	// MaxIncomingHandshakes is the maximum number of handshakes that can be in progress at the same time.
+	// New connections are dropped while the limit is reached.
+	// If not set, the number of handshakes is not limited.
+	// It is only used by the server.
+	MaxIncomingHandshakes int
+	// MaxIncomingSessionRatePerIP is the maximum number of connection attempts per second from a single IP address.
+	// A client can make that many attempts in a burst. Connection attempts above the rate are dropped.
+	// For IETF QUIC, Initial packets that are answered with a stateless retry are not counted as attempts.
+	// If not set, the rate is not limited.
+	// It is only used by the server.
+	MaxIncomingSessionRatePerIP int
+	// AddressValidationThreshold is the number of concurrent handshakes (including the new one) above which
+	// the server requires every client to validate its address, using a source address token (gQUIC) or a Retry (IETF QUIC),
+	// before it spends any more resources on the handshake.
+	// Only valid cookies are accepted, even if AcceptCookie would accept the client, and 0-RTT is disabled for IETF QUIC.
+	// If not set, address validation is only governed by AcceptCookie.
+	// It is only used by the server.
+	AddressValidationThreshold int
+	// ClientSessionCache is a cache of the state needed to resume handshakes with servers.
+	// For gQUIC, it stores the server config, the source address token and the certificate chain.
+	// When dialing a server that is in the cache, the client sends a complete CHLO right away,
+	// saving one round trip, and can send 0-RTT data.
+	// For IETF QUIC, it stores the TLS session tickets issued by the server.
+	// When dialing a server that issued a session ticket, the client resumes the TLS session and sends 0-RTT data.
+	// If the server rejects the 0-RTT data, it is retransmitted after the handshake completes.
+	// The state is stored using the hostname and the port of the server as the key.
+	// If not set, every handshake starts from scratch.
+	// It is only used by the client.
+	ClientSessionCache ClientSessionCache
+}
+
+// A SendAlgorithm performs congestion control and calculates the congestion window.
+// It is only used from the session's run loop, so implementations don't need to be safe for concurrent use.
+// Warning: This API should not be considered stable and will change soon.
+type SendAlgorithm interface {
+	TimeUntilSend(bytesInFlight ByteCount) time.Duration
+	OnPacketSent(sentTime time.Time, bytesInFlight ByteCount, packetNumber PacketNumber, bytes ByteCount, isRetransmittable bool) bool
+	GetCongestionWindow() ByteCount
+	MaybeExitSlowStart()
+	OnPacketAcked(number PacketNumber, ackedBytes ByteCount, bytesInFlight ByteCount)
+	OnPacketLost(number PacketNumber, lostBytes ByteCount, bytesInFlight ByteCount)
+	SetNumEmulatedConnections(n int)
+	OnRetransmissionTimeout(packetsRetransmitted bool)
+	OnConnectionMigration()
+	RetransmissionDelay() time.Duration
+
+	// Experiments
+	SetSlowStartLargeReduction(enabled bool)
 }
 
 // A Listener for incoming QUIC connections
 type Listener interface {
 	// Close the server, sending CONNECTION_CLOSE frames to each peer.
+	// The net.PacketConn is only closed if it was created by ListenAddr.
 	Close() error
 	// Addr returns the local network addr that the server is listening on.
 	Addr() net.Addr
 	// Accept returns new sessions. It should be called in a loop.
 	Accept() (Session, error)
+	// AcceptContext is like Accept, but it returns the context's error when the context is canceled.
+	// The server keeps running, and sessions are returned by the next call to Accept.
+	AcceptContext(context.Context) (Session, error)
+	// ListenerStats returns a snapshot of the server's session counters,
+	// including the number of connection attempts that were dropped because of the limits set in the Config.
+	// Warning: This API should not be considered stable and might change soon.
+	ListenerStats() ListenerStats
+}
+
+// An EarlyListener listens for incoming QUIC connections,
+// and returns them before the handshake completes, as soon as they can be used.
+// For gQUIC, this is the case after the server sent the SHLO.
+// For IETF QUIC, this is the case if the server accepted the client's 0-RTT data.
+// Data sent by the server is sent as soon as it has the forward-secure keys,
+// which, for IETF QUIC, is when the handshake completes.
+type EarlyListener interface {
+	// Close the server, sending CONNECTION_CLOSE frames to each peer.
+	// The net.PacketConn is only closed if it was created by ListenAddrEarly.
+	Close() error
+	// Addr returns the local network addr that the server is listening on.
+	Addr() net.Addr
+	// Accept returns new early sessions. It should be called in a loop.
+	Accept() (EarlySession, error)
+	// AcceptContext is like Accept, but it returns the context's error when the context is canceled.
+	AcceptContext(context.Context) (EarlySession, error)
+	// ListenerStats returns a snapshot of the server's session counters.
+	// Warning: This API should not be considered stable and might change soon.
+	ListenerStats() ListenerStats
 }
@@ -0,0 +1,167 @@
+package ackhandler
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+type ecnState uint8
+
+const (
+	// the first packets are marked ECT(0), to test if the path supports ECN
+	ecnStateTesting ecnState = iota
+	// all testing packets were sent, waiting for the ACK
+	ecnStateUnknown
+	// ECN validation failed, packets are not marked any more
+	ecnStateFailed
+	// ECN validation succeeded
+	ecnStateCapable
+)
+
+// numECNTestingPackets is the number of packets that are marked ECT(0), before ECN was validated
+const numECNTestingPackets = 10
+
+// The ecnTracker validates that the path (and the peer) support ECN.
+// Some network devices drop packets marked ECT(0), others clear the ECN codepoint, or mark all packets CE.
+// It is therefore only possible to use ECN after validating the path, see section 13.4 of the QUIC transport draft:
+// * the first numECNTestingPackets packets are marked ECT(0)
+// * validation fails if all of these packets are lost
+// * validation fails if the peer doesn't report ECN counts (consistent with the packets that were acknowledged)
+// * validation succeeds as soon as an ACK correctly reports the ECN counts for an ECT(0) marked packet
+type ecnTracker struct {
+	enabled bool
+	state   ecnState
+
+	numSentTesting, numLostTesting        uint64
+	firstTestingPacket, lastTestingPacket protocol.PacketNumber
+
+	numSentECT0 uint64
+	// the ECN counts reported by the peer in the last ACK frame
+	ect0, ect1, ecnce uint64
+}
+
+func newECNTracker(enabled bool) *ecnTracker {
+	t := &ecnTracker{enabled: enabled}
+	if !enabled {
+		t.state = ecnStateFailed
+	}
+	return t
+}
+
+// Mode returns the ECN codepoint that the next packet should be marked with.
+func (e *ecnTracker) Mode() protocol.ECN {
+	switch e.state {
+	case ecnStateTesting, ecnStateCapable:
+		return protocol.ECT0
+	default:
+		return protocol.ECNNon
+	}
+}
+
+// SentPacket is called for every packet that is sent.
+func (e *ecnTracker) SentPacket(pn protocol.PacketNumber, ecn protocol.ECN) {
+	if ecn != protocol.ECT0 {
+		return
+	}
+	e.numSentECT0++
+	if e.state != ecnStateTesting {
+		return
+	}
+	if e.numSentTesting == 0 {
+		e.firstTestingPacket = pn
+	}
+	e.lastTestingPacket = pn
+	e.numSentTesting++
+	if e.numSentTesting >= numECNTestingPackets {
+		e.state = ecnStateUnknown
+	}
+}
+
+// LostPacket is called for every packet that is declared lost.
+// If all testing packets are lost, the path probably drops ECN-marked packets.
+func (e *ecnTracker) LostPacket(pn protocol.PacketNumber) {
+	if e.state != ecnStateTesting && e.state != ecnStateUnknown {
+		return
+	}
+	if !e.isTestingPacket(pn) {
+		return
+	}
+	e.numLostTesting++
+	if e.state == ecnStateUnknown && e.numLostTesting >= e.numSentTesting {
+		utils.Debugf("Disabling ECN. All testing packets were lost.")
+		e.state = ecnStateFailed
+	}
+}
+
+func (e *ecnTracker) isTestingPacket(pn protocol.PacketNumber) bool {
+	if e.numSentTesting == 0 {
+		return false
+	}
+	return pn >= e.firstTestingPacket && pn <= e.lastTestingPacket
+}
+
+// HandleNewlyAcked is called with the packets that were newly acknowledged by an ACK frame, and the ECN counts of that frame.
+// It returns true if the peer reported new CE marks, i.e. if the congestion controller needs to react.
+func (e *ecnTracker) HandleNewlyAcked(packets []*Packet, ect0, ect1, ecnce uint64) bool {
+	if e.state == ecnStateFailed {
+		return false
+	}
+
+	var newECT0 uint64
+	for _, p := range packets {
+		if p.ECN == protocol.ECT0 {
+			newECT0++
+		}
+	}
+	if newECT0 == 0 && ect0 == e.ect0 && ect1 == e.ect1 && ecnce == e.ecnce {
+		return false
+	}
+
+	// The ECN counts are cumulative, they can't decrease.
+	// ACKs that arrive out of order are already rejected by the sentPacketHandler.
+	if ect0 < e.ect0 || ect1 < e.ect1 || ecnce < e.ecnce {
+		e.failValidation("ECN counts decreased")
+		return false
+	}
+	// We never send ECT(1), so an increase means that the path (or the peer) is misbehaving.
+	if ect1 > e.ect1 {
+		e.failValidation("peer reported ECT(1) marks")
+		return false
+	}
+	// Every ECT(0) marked packet that was acknowledged must either be reported as ECT(0) or as CE.
+	// If it's not, the path cleared the ECN codepoint (or the peer doesn't support ECN).
+	if (ect0-e.ect0)+(ecnce-e.ecnce) < newECT0 {
+		e.failValidation("ECN counts too small")
+		return false
+	}
+	if ect0+ecnce > e.numSentECT0 {
+		e.failValidation("ECN counts larger than the number of ECT(0) marked packets")
+		return false
+	}
+
+	newCE := ecnce > e.ecnce
+	e.ect0 = ect0
+	e.ect1 = ect1
+	e.ecnce = ecnce
+	if newECT0 > 0 && (e.state == ecnStateTesting || e.state == ecnStateUnknown) {
+		utils.Debugf("ECN validation succeeded.")
+		e.state = ecnStateCapable
+	}
+	return newCE
+}
+
+func (e *ecnTracker) failValidation(reason string) {
+	utils.Debugf("Disabling ECN. Validation failed: %s.", reason)
+	e.state = ecnStateFailed
+}
+
+// Reset restarts ECN validation.
+// It is called when the path of the connection changed.
+func (e *ecnTracker) Reset() {
+	if !e.enabled {
+		return
+	}
+	e.state = ecnStateTesting
+	e.numSentTesting = 0
+	e.numLostTesting = 0
+}
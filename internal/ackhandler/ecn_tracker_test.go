@@ -0,0 +1,130 @@
+package ackhandler
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ECN tracker", func() {
+	var tracker *ecnTracker
+
+	BeforeEach(func() {
+		tracker = newECNTracker(true)
+	})
+
+	// sendPackets sends packets, using the ECN mode returned by the tracker
+	sendPackets := func(from, to protocol.PacketNumber) []*Packet {
+		var packets []*Packet
+		for pn := from; pn <= to; pn++ {
+			p := &Packet{PacketNumber: pn, ECN: tracker.Mode()}
+			tracker.SentPacket(p.PacketNumber, p.ECN)
+			packets = append(packets, p)
+		}
+		return packets
+	}
+
+	It("doesn't mark packets if ECN is disabled", func() {
+		tracker = newECNTracker(false)
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+		tracker.Reset()
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+	})
+
+	It("marks the testing packets, and then waits for validation", func() {
+		packets := sendPackets(1, numECNTestingPackets)
+		for _, p := range packets {
+			Expect(p.ECN).To(Equal(protocol.ECT0))
+		}
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+		Expect(tracker.HandleNewlyAcked(packets[:2], 2, 0, 0)).To(BeFalse())
+		Expect(tracker.Mode()).To(Equal(protocol.ECT0))
+	})
+
+	It("validates ECN as soon as an ACK reports the ECN counts", func() {
+		packets := sendPackets(1, 3)
+		Expect(tracker.HandleNewlyAcked(packets[:1], 1, 0, 0)).To(BeFalse())
+		Expect(tracker.state).To(Equal(ecnStateCapable))
+		// all following packets are marked as well
+		packets = sendPackets(4, 4+numECNTestingPackets)
+		for _, p := range packets {
+			Expect(p.ECN).To(Equal(protocol.ECT0))
+		}
+	})
+
+	It("detects CE marks", func() {
+		packets := sendPackets(1, 5)
+		Expect(tracker.HandleNewlyAcked(packets[:2], 1, 0, 1)).To(BeTrue())
+		Expect(tracker.HandleNewlyAcked(packets[2:3], 2, 0, 1)).To(BeFalse())
+		Expect(tracker.HandleNewlyAcked(packets[3:], 2, 0, 3)).To(BeTrue())
+		Expect(tracker.Mode()).To(Equal(protocol.ECT0))
+	})
+
+	It("fails validation if the peer doesn't report ECN counts", func() {
+		packets := sendPackets(1, 3)
+		Expect(tracker.HandleNewlyAcked(packets[:1], 0, 0, 0)).To(BeFalse())
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+	})
+
+	It("fails validation if the path clears the ECN codepoint of some packets", func() {
+		packets := sendPackets(1, 3)
+		Expect(tracker.HandleNewlyAcked(packets, 2, 0, 0)).To(BeFalse())
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+	})
+
+	It("fails validation if the peer reports ECT(1)", func() {
+		packets := sendPackets(1, 3)
+		Expect(tracker.HandleNewlyAcked(packets[:1], 1, 1, 0)).To(BeFalse())
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+	})
+
+	It("fails validation if the ECN counts decrease", func() {
+		packets := sendPackets(1, 3)
+		Expect(tracker.HandleNewlyAcked(packets[:2], 1, 0, 1)).To(BeTrue())
+		Expect(tracker.HandleNewlyAcked(packets[2:], 2, 0, 0)).To(BeFalse())
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+	})
+
+	It("fails validation if the peer reports more marked packets than were sent", func() {
+		packets := sendPackets(1, 3)
+		Expect(tracker.HandleNewlyAcked(packets[:1], 4, 0, 0)).To(BeFalse())
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+	})
+
+	It("ignores CE marks after validation failed", func() {
+		packets := sendPackets(1, 3)
+		Expect(tracker.HandleNewlyAcked(packets[:1], 0, 0, 0)).To(BeFalse())
+		Expect(tracker.HandleNewlyAcked(packets[1:], 0, 0, 2)).To(BeFalse())
+	})
+
+	It("fails validation if all testing packets are lost", func() {
+		packets := sendPackets(1, numECNTestingPackets)
+		for _, p := range packets[:len(packets)-1] {
+			tracker.LostPacket(p.PacketNumber)
+			Expect(tracker.state).To(Equal(ecnStateUnknown))
+		}
+		tracker.LostPacket(packets[len(packets)-1].PacketNumber)
+		Expect(tracker.state).To(Equal(ecnStateFailed))
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+	})
+
+	It("doesn't fail validation if only some testing packets are lost", func() {
+		packets := sendPackets(1, numECNTestingPackets)
+		tracker.LostPacket(packets[0].PacketNumber)
+		Expect(tracker.HandleNewlyAcked(packets[1:2], 1, 0, 0)).To(BeFalse())
+		Expect(tracker.state).To(Equal(ecnStateCapable))
+	})
+
+	It("restarts validation on reset", func() {
+		packets := sendPackets(1, 2)
+		Expect(tracker.HandleNewlyAcked(packets[:1], 1, 0, 0)).To(BeFalse())
+		Expect(tracker.state).To(Equal(ecnStateCapable))
+		tracker.Reset()
+		Expect(tracker.state).To(Equal(ecnStateTesting))
+		packets = sendPackets(3, 2+numECNTestingPackets)
+		Expect(tracker.Mode()).To(Equal(protocol.ECNNon))
+		Expect(tracker.HandleNewlyAcked(packets[:1], 2, 0, 0)).To(BeFalse())
+		Expect(tracker.state).To(Equal(ecnStateCapable))
+	})
+})
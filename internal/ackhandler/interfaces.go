@@ -13,12 +13,31 @@ type SentPacketHandler interface {
 	SentPacket(packet *Packet) error
 	ReceivedAck(ackFrame *wire.AckFrame, withPacketNumber protocol.PacketNumber, encLevel protocol.EncryptionLevel, recvTime time.Time) error
 	SetHandshakeComplete()
+	// Queue0RTTPacketsForRetransmission queues all 0-RTT packets for retransmission.
+	// It is called when the server rejects 0-RTT data.
+	Queue0RTTPacketsForRetransmission()
+	// OnConnectionMigration resets the congestion state, when the path of the connection changed.
+	// Path MTU discovery is only performed if enablePathMTUDiscovery is set.
+	OnConnectionMigration(enablePathMTUDiscovery bool)
+	// SetPeerMaxPacketSize limits the packet sizes that path MTU discovery probes for.
+	// It is called with the maximum packet size announced by the peer.
+	SetPeerMaxPacketSize(protocol.ByteCount)
+	// SetPeerMaxAckDelay sets the maximum time that the peer delays sending an ACK.
+	// It is used to limit the ACK delay reported by the peer when updating the RTT.
+	SetPeerMaxAckDelay(time.Duration)
 
 	// SendingAllowed says if a packet can be sent.
 	// Sending packets might not be possible because:
 	// * we're congestion limited
 	// * we're tracking the maximum number of sent packets
 	SendingAllowed() bool
+	// ECNMode is the ECN codepoint that the next packet should be marked with.
+	ECNMode() protocol.ECN
+	// MaxPacketSize is the largest packet size confirmed by path MTU discovery.
+	MaxPacketSize() protocol.ByteCount
+	// MTUProbeSize returns the size of the MTU probe packet that should be sent now.
+	// It returns 0 if no probe packet should be sent.
+	MTUProbeSize(now time.Time) protocol.ByteCount
 	// TimeUntilSend is the time when the next packet should be sent.
 	// It is used for pacing packets.
 	TimeUntilSend() time.Time
@@ -36,12 +55,17 @@ type SentPacketHandler interface {
 
 	GetAlarmTimeout() time.Time
 	OnAlarm()
+
+	// GetStats returns statistics about the sent packets and the congestion controller
+	GetStats() Stats
 }
 
 // ReceivedPacketHandler handles ACKs needed to send for incoming packets
 type ReceivedPacketHandler interface {
-	ReceivedPacket(packetNumber protocol.PacketNumber, rcvTime time.Time, shouldInstigateAck bool) error
+	ReceivedPacket(packetNumber protocol.PacketNumber, ecn protocol.ECN, rcvTime time.Time, shouldInstigateAck bool) error
 	IgnoreBelow(protocol.PacketNumber)
+	// SetAckFrequency changes the ACK policy, as requested by the peer in an ACK_FREQUENCY frame.
+	SetAckFrequency(*wire.AckFrequencyFrame)
 
 	GetAlarmTimeout() time.Time
 	GetAckFrame() *wire.AckFrame
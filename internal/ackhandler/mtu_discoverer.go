@@ -0,0 +1,149 @@
+package ackhandler
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+const (
+	// Once the search interval is smaller than maxMTUDiff, the upper bound is probed directly.
+	// If that probe fails, path MTU discovery stops.
+	maxMTUDiff protocol.ByteCount = 20
+	// The time between two probe packets, in RTTs.
+	mtuProbeDelay = 5
+	// The number of probe packets of the same size that have to be lost, before this size is considered too large.
+	// This prevents random packet loss from limiting the packet size.
+	maxMTUProbes = 3
+)
+
+// The mtuDiscoverer implements datagram packetization layer path MTU discovery (RFC 8899).
+// It sends padded probe packets, and performs a binary search between the largest size that was acknowledged,
+// and the smallest size that was lost.
+// Only a single probe packet is in flight at any time.
+type mtuDiscoverer struct {
+	rttStats *congestion.RTTStats
+
+	started bool
+	// set if packets sent on the current path can't be fragmented
+	enabled bool
+	// the maximum packet size that we're allowed to send, as configured and as limited by the peer
+	maxPacketSize protocol.ByteCount
+	// the largest packet size that was acknowledged
+	current protocol.ByteCount
+	// the largest packet size that might still work
+	max protocol.ByteCount
+
+	probeInFlight bool
+	probeNumber   protocol.PacketNumber
+	lastProbeTime time.Time
+	// the number of probe packets of the current probe size that were lost
+	numLostProbes int
+}
+
+func newMTUDiscoverer(rttStats *congestion.RTTStats, maxPacketSize protocol.ByteCount, enabled bool) *mtuDiscoverer {
+	d := &mtuDiscoverer{
+		rttStats:      rttStats,
+		enabled:       enabled,
+		maxPacketSize: maxPacketSize,
+		current:       protocol.InitialPacketSize,
+	}
+	d.max = d.searchLimit()
+	return d
+}
+
+// searchLimit is the largest packet size that is probed for.
+// If path MTU discovery is disabled, no probe packets are sent.
+func (d *mtuDiscoverer) searchLimit() protocol.ByteCount {
+	if !d.enabled {
+		return protocol.InitialPacketSize
+	}
+	return d.maxPacketSize
+}
+
+// Start starts sending probe packets.
+// It is called when the handshake completes.
+func (d *mtuDiscoverer) Start(now time.Time) {
+	d.started = true
+	d.lastProbeTime = now
+}
+
+// SetPeerMaxPacketSize limits the packet size to the maximum packet size announced by the peer.
+func (d *mtuDiscoverer) SetPeerMaxPacketSize(s protocol.ByteCount) {
+	d.maxPacketSize = utils.MinByteCount(d.maxPacketSize, s)
+	d.max = utils.MinByteCount(d.max, s)
+}
+
+// Reset restarts path MTU discovery.
+// It is called when the path of the connection changed.
+// Path MTU discovery is only enabled if packets sent on the new path can't be fragmented.
+func (d *mtuDiscoverer) Reset(now time.Time, enabled bool) {
+	d.enabled = enabled
+	d.current = protocol.InitialPacketSize
+	d.max = d.searchLimit()
+	d.probeInFlight = false
+	d.numLostProbes = 0
+	d.lastProbeTime = now
+}
+
+// CurrentSize is the largest packet size confirmed by the peer.
+func (d *mtuDiscoverer) CurrentSize() protocol.ByteCount {
+	return d.current
+}
+
+// NextProbeSize returns the size of the next probe packet.
+// It returns 0 if no probe packet should be sent at this time.
+func (d *mtuDiscoverer) NextProbeSize(now time.Time) protocol.ByteCount {
+	if !d.started || d.probeInFlight || d.current >= d.max {
+		return 0
+	}
+	if now.Sub(d.lastProbeTime) < mtuProbeDelay*d.rttStats.SmoothedRTT() {
+		return 0
+	}
+	if d.max-d.current < maxMTUDiff {
+		return d.max
+	}
+	return (d.current + d.max) / 2
+}
+
+// SentProbe is called when a probe packet is sent.
+func (d *mtuDiscoverer) SentProbe(pn protocol.PacketNumber, now time.Time) {
+	d.probeInFlight = true
+	d.probeNumber = pn
+	d.lastProbeTime = now
+}
+
+// ProbeAcked is called when a probe packet is acknowledged.
+// It returns true if the packet size was increased.
+func (d *mtuDiscoverer) ProbeAcked(pn protocol.PacketNumber, size protocol.ByteCount) bool {
+	if !d.probeInFlight || pn != d.probeNumber {
+		return false
+	}
+	d.probeInFlight = false
+	d.numLostProbes = 0
+	if size <= d.current {
+		return false
+	}
+	d.current = size
+	return true
+}
+
+// ProbeLost is called when a probe packet is declared lost.
+func (d *mtuDiscoverer) ProbeLost(pn protocol.PacketNumber, size protocol.ByteCount) {
+	if !d.probeInFlight || pn != d.probeNumber {
+		return
+	}
+	d.probeInFlight = false
+	d.numLostProbes++
+	if d.numLostProbes < maxMTUProbes {
+		return
+	}
+	d.numLostProbes = 0
+	d.max = size - 1
+	// the search interval is small enough, stop probing
+	if d.max-d.current < maxMTUDiff {
+		d.max = d.current
+	}
+}
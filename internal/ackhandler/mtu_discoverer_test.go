@@ -0,0 +1,121 @@
+package ackhandler
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MTU discoverer", func() {
+	const rtt = 100 * time.Millisecond
+	var (
+		d    *mtuDiscoverer
+		now  time.Time
+		pn   protocol.PacketNumber
+		size protocol.ByteCount
+	)
+
+	BeforeEach(func() {
+		rttStats := &congestion.RTTStats{}
+		rttStats.UpdateRTT(rtt, 0, time.Now())
+		d = newMTUDiscoverer(rttStats, 1500, true)
+		now = time.Now()
+		d.Start(now)
+		pn = 0
+	})
+
+	// sendProbe waits until the next probe is due, and sends it
+	sendProbe := func() {
+		Expect(d.NextProbeSize(now)).To(BeZero())
+		now = now.Add(mtuProbeDelay * rtt)
+		size = d.NextProbeSize(now)
+		Expect(size).ToNot(BeZero())
+		pn++
+		d.SentProbe(pn, now)
+	}
+
+	It("doesn't send probes before it is started", func() {
+		d = newMTUDiscoverer(&congestion.RTTStats{}, 1500, true)
+		Expect(d.NextProbeSize(time.Now())).To(BeZero())
+	})
+
+	It("performs a binary search", func() {
+		sendProbe()
+		Expect(size).To(Equal(protocol.ByteCount(1350)))
+		Expect(d.ProbeAcked(pn, size)).To(BeTrue())
+		Expect(d.CurrentSize()).To(Equal(protocol.ByteCount(1350)))
+		sendProbe()
+		Expect(size).To(Equal(protocol.ByteCount(1425)))
+		for i := 0; i < maxMTUProbes; i++ {
+			Expect(d.NextProbeSize(now.Add(time.Hour))).To(BeZero())
+			d.ProbeLost(pn, size)
+			if i < maxMTUProbes-1 {
+				sendProbe()
+				Expect(size).To(Equal(protocol.ByteCount(1425)))
+			}
+		}
+		sendProbe()
+		Expect(size).To(Equal(protocol.ByteCount(1387)))
+		Expect(d.ProbeAcked(pn, size)).To(BeTrue())
+		Expect(d.CurrentSize()).To(Equal(protocol.ByteCount(1387)))
+	})
+
+	It("probes the maximum packet size directly when close enough", func() {
+		d = newMTUDiscoverer(d.rttStats, protocol.InitialPacketSize+10, true)
+		d.Start(now)
+		sendProbe()
+		Expect(size).To(Equal(protocol.InitialPacketSize + 10))
+		Expect(d.ProbeAcked(pn, size)).To(BeTrue())
+		Expect(d.NextProbeSize(now.Add(time.Hour))).To(BeZero())
+	})
+
+	It("stops when the search interval is small enough", func() {
+		d.current = 1490
+		sendProbe()
+		Expect(size).To(Equal(protocol.ByteCount(1500)))
+		for i := 0; i < maxMTUProbes; i++ {
+			d.ProbeLost(pn, size)
+			if i < maxMTUProbes-1 {
+				sendProbe()
+			}
+		}
+		Expect(d.NextProbeSize(now.Add(time.Hour))).To(BeZero())
+		Expect(d.CurrentSize()).To(Equal(protocol.ByteCount(1490)))
+	})
+
+	It("ignores acknowledgments and losses of old probe packets", func() {
+		sendProbe()
+		d.Reset(now, true)
+		Expect(d.ProbeAcked(pn, size)).To(BeFalse())
+		d.ProbeLost(pn, size)
+		Expect(d.CurrentSize()).To(Equal(protocol.InitialPacketSize))
+		Expect(d.numLostProbes).To(BeZero())
+	})
+
+	It("resets", func() {
+		sendProbe()
+		Expect(d.ProbeAcked(pn, size)).To(BeTrue())
+		d.Reset(now, true)
+		Expect(d.CurrentSize()).To(Equal(protocol.InitialPacketSize))
+		sendProbe()
+		Expect(size).To(Equal(protocol.ByteCount(1350)))
+	})
+
+	It("doesn't send probes when disabled", func() {
+		d = newMTUDiscoverer(d.rttStats, 1500, false)
+		d.Start(now)
+		Expect(d.NextProbeSize(now.Add(time.Hour))).To(BeZero())
+	})
+
+	It("enables and disables probing when resetting", func() {
+		d.Reset(now, false)
+		Expect(d.NextProbeSize(now.Add(time.Hour))).To(BeZero())
+		d.Reset(now, true)
+		sendProbe()
+		Expect(size).To(Equal(protocol.ByteCount(1350)))
+	})
+})
@@ -15,6 +15,12 @@ type Packet struct {
 	Frames          []wire.Frame
 	Length          protocol.ByteCount
 	EncryptionLevel protocol.EncryptionLevel
+	ECN             protocol.ECN
+	// IsMTUProbePacket is set for probe packets sent by path MTU discovery.
+	// Their loss is not a congestion signal, and they are not retransmitted.
+	IsMTUProbePacket bool
+	// IsRetransmission is set for packets that carry frames retransmitted from a lost packet.
+	IsRetransmission bool
 
 	largestAcked protocol.PacketNumber // if the packet contains an ACK, the LargestAcked value of that ACK
 	sendTime     time.Time
@@ -1,9 +1,11 @@
 package ackhandler
 
 import (
+	"math"
 	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 )
 
@@ -15,6 +17,12 @@ type receivedPacketHandler struct {
 	packetHistory *receivedPacketHistory
 
 	ackSendDelay time.Duration
+	// the number of retransmittable packets after which an ACK is sent immediately
+	packetsBeforeAck int
+	// if set, packets received out of order don't cause an immediate ACK
+	ignoreOrder bool
+	// ACK_FREQUENCY frames with a smaller sequence number are ignored
+	nextAckFrequencySeq uint64
 
 	packetsReceivedSinceLastAck                int
 	retransmittablePacketsReceivedSinceLastAck int
@@ -22,19 +30,25 @@ type receivedPacketHandler struct {
 	ackAlarm                                   time.Time
 	lastAck                                    *wire.AckFrame
 
+	// the number of packets received with the respective ECN codepoint
+	ect0, ect1, ecnce uint64
+
 	version protocol.VersionNumber
 }
 
-// NewReceivedPacketHandler creates a new receivedPacketHandler
-func NewReceivedPacketHandler(version protocol.VersionNumber) ReceivedPacketHandler {
+// NewReceivedPacketHandler creates a new receivedPacketHandler.
+// An ACK is sent at the latest maxAckDelay after receiving a retransmittable packet,
+// or immediately after receiving packetsBeforeAck retransmittable packets.
+func NewReceivedPacketHandler(maxAckDelay time.Duration, packetsBeforeAck int, version protocol.VersionNumber) ReceivedPacketHandler {
 	return &receivedPacketHandler{
-		packetHistory: newReceivedPacketHistory(),
-		ackSendDelay:  protocol.AckSendDelay,
-		version:       version,
+		packetHistory:    newReceivedPacketHistory(),
+		ackSendDelay:     maxAckDelay,
+		packetsBeforeAck: packetsBeforeAck,
+		version:          version,
 	}
 }
 
-func (h *receivedPacketHandler) ReceivedPacket(packetNumber protocol.PacketNumber, rcvTime time.Time, shouldInstigateAck bool) error {
+func (h *receivedPacketHandler) ReceivedPacket(packetNumber protocol.PacketNumber, ecn protocol.ECN, rcvTime time.Time, shouldInstigateAck bool) error {
 	if packetNumber > h.largestObserved {
 		h.largestObserved = packetNumber
 		h.largestObservedReceivedTime = rcvTime
@@ -47,7 +61,15 @@ func (h *receivedPacketHandler) ReceivedPacket(packetNumber protocol.PacketNumbe
 	if err := h.packetHistory.ReceivedPacket(packetNumber); err != nil {
 		return err
 	}
-	h.maybeQueueAck(packetNumber, rcvTime, shouldInstigateAck)
+	switch ecn {
+	case protocol.ECT0:
+		h.ect0++
+	case protocol.ECT1:
+		h.ect1++
+	case protocol.ECNCE:
+		h.ecnce++
+	}
+	h.maybeQueueAck(packetNumber, ecn, rcvTime, shouldInstigateAck)
 	return nil
 }
 
@@ -58,7 +80,20 @@ func (h *receivedPacketHandler) IgnoreBelow(p protocol.PacketNumber) {
 	h.packetHistory.DeleteBelow(p)
 }
 
-func (h *receivedPacketHandler) maybeQueueAck(packetNumber protocol.PacketNumber, rcvTime time.Time, shouldInstigateAck bool) {
+// SetAckFrequency applies the ACK policy requested by the peer in an ACK_FREQUENCY frame.
+// Reordered ACK_FREQUENCY frames are ignored.
+func (h *receivedPacketHandler) SetAckFrequency(f *wire.AckFrequencyFrame) {
+	if f.SequenceNumber < h.nextAckFrequencySeq {
+		return
+	}
+	h.nextAckFrequencySeq = f.SequenceNumber + 1
+	h.ackSendDelay = f.UpdateMaxAckDelay
+	// the packet tolerance is a varint, which doesn't necessarily fit into an int on 32 bit platforms
+	h.packetsBeforeAck = int(utils.MinUint64(f.PacketTolerance, math.MaxInt32))
+	h.ignoreOrder = f.IgnoreOrder
+}
+
+func (h *receivedPacketHandler) maybeQueueAck(packetNumber protocol.PacketNumber, ecn protocol.ECN, rcvTime time.Time, shouldInstigateAck bool) {
 	h.packetsReceivedSinceLastAck++
 
 	if shouldInstigateAck {
@@ -70,19 +105,26 @@ func (h *receivedPacketHandler) maybeQueueAck(packetNumber protocol.PacketNumber
 		h.ackQueued = true
 	}
 
-	// if the packet number is smaller than the largest acked packet, it must have been reported missing with the last ACK
-	// note that it cannot be a duplicate because they're already filtered out by ReceivedPacket()
-	if h.lastAck != nil && packetNumber < h.lastAck.LargestAcked {
-		h.ackQueued = true
+	if !h.ignoreOrder {
+		// if the packet number is smaller than the largest acked packet, it must have been reported missing with the last ACK
+		// note that it cannot be a duplicate because they're already filtered out by ReceivedPacket()
+		if h.lastAck != nil && packetNumber < h.lastAck.LargestAcked {
+			h.ackQueued = true
+		}
+
+		// check if a new missing range above the previously was created
+		if h.lastAck != nil && h.packetHistory.GetHighestAckRange().First > h.lastAck.LargestAcked {
+			h.ackQueued = true
+		}
 	}
 
-	// check if a new missing range above the previously was created
-	if h.lastAck != nil && h.packetHistory.GetHighestAckRange().First > h.lastAck.LargestAcked {
+	// report congestion to the peer as quickly as possible
+	if ecn == protocol.ECNCE && h.version.UsesIETFFrameFormat() {
 		h.ackQueued = true
 	}
 
 	if !h.ackQueued && shouldInstigateAck {
-		if h.retransmittablePacketsReceivedSinceLastAck >= protocol.RetransmittablePacketsBeforeAck {
+		if h.retransmittablePacketsReceivedSinceLastAck >= h.packetsBeforeAck {
 			h.ackQueued = true
 		} else {
 			if h.ackAlarm.IsZero() {
@@ -112,6 +154,11 @@ func (h *receivedPacketHandler) GetAckFrame() *wire.AckFrame {
 	if len(ackRanges) > 1 {
 		ack.AckRanges = ackRanges
 	}
+	if h.version.UsesIETFFrameFormat() {
+		ack.ECT0 = h.ect0
+		ack.ECT1 = h.ect1
+		ack.ECNCE = h.ecnce
+	}
 
 	h.lastAck = ack
 	h.ackAlarm = time.Time{}
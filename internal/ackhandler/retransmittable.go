@@ -13,6 +13,20 @@ func stripNonRetransmittableFrames(fs []wire.Frame) []wire.Frame {
 	return res
 }
 
+// Returns a new slice with all frames deleted that are not retransmitted when lost.
+// DATAGRAM, PATH_CHALLENGE and PATH_RESPONSE frames are congestion controlled, but they are never retransmitted.
+func stripUnreliableFrames(fs []wire.Frame) []wire.Frame {
+	res := make([]wire.Frame, 0, len(fs))
+	for _, f := range fs {
+		switch f.(type) {
+		case *wire.DatagramFrame, *wire.PathChallengeFrame, *wire.PathResponseFrame:
+		default:
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
 // IsFrameRetransmittable returns true if the frame should be retransmitted.
 func IsFrameRetransmittable(f wire.Frame) bool {
 	switch f.(type) {
@@ -10,16 +10,21 @@ import (
 
 var _ = Describe("retransmittable frames", func() {
 	for fl, el := range map[wire.Frame]bool{
-		&wire.AckFrame{}:             false,
-		&wire.StopWaitingFrame{}:     false,
-		&wire.BlockedFrame{}:         true,
-		&wire.ConnectionCloseFrame{}: true,
-		&wire.GoawayFrame{}:          true,
-		&wire.PingFrame{}:            true,
-		&wire.RstStreamFrame{}:       true,
-		&wire.StreamFrame{}:          true,
-		&wire.MaxDataFrame{}:         true,
-		&wire.MaxStreamDataFrame{}:   true,
+		&wire.AckFrame{}:                false,
+		&wire.StopWaitingFrame{}:        false,
+		&wire.BlockedFrame{}:            true,
+		&wire.ConnectionCloseFrame{}:    true,
+		&wire.GoawayFrame{}:             true,
+		&wire.PingFrame{}:               true,
+		&wire.RstStreamFrame{}:          true,
+		&wire.StreamFrame{}:             true,
+		&wire.MaxDataFrame{}:            true,
+		&wire.MaxStreamDataFrame{}:      true,
+		&wire.DatagramFrame{}:           true,
+		&wire.PathChallengeFrame{}:      true,
+		&wire.PathResponseFrame{}:       true,
+		&wire.NewConnectionIDFrame{}:    true,
+		&wire.RetireConnectionIDFrame{}: true,
 	} {
 		f := fl
 		e := el
@@ -42,4 +47,10 @@ var _ = Describe("retransmittable frames", func() {
 			Expect(HasRetransmittableFrames([]wire.Frame{f})).To(Equal(e))
 		})
 	}
+
+	It("strips frames that are not retransmitted", func() {
+		f := &wire.StreamFrame{}
+		fs := []wire.Frame{&wire.DatagramFrame{}, f, &wire.PathChallengeFrame{}, &wire.PathResponseFrame{}}
+		Expect(stripUnreliableFrames(fs)).To(Equal([]wire.Frame{f}))
+	})
 })
@@ -10,6 +10,7 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
 	"github.com/lucas-clemente/quic-go/qerr"
 )
 
@@ -55,6 +56,12 @@ type sentPacketHandler struct {
 	congestion congestion.SendAlgorithm
 	rttStats   *congestion.RTTStats
 
+	ecnTracker    *ecnTracker
+	mtuDiscoverer *mtuDiscoverer
+
+	// the maximum time the peer delays sending an ACK. 0 if unknown.
+	peerMaxAckDelay time.Duration
+
 	handshakeComplete bool
 	// The number of times the handshake packets have been retransmitted without receiving an ack.
 	handshakeCount uint32
@@ -67,23 +74,36 @@ type sentPacketHandler struct {
 
 	// The alarm timeout
 	alarm time.Time
-}
 
-// NewSentPacketHandler creates a new sentPacketHandler
-func NewSentPacketHandler(rttStats *congestion.RTTStats) SentPacketHandler {
-	congestion := congestion.NewCubicSender(
-		congestion.DefaultClock{},
-		rttStats,
-		false, /* don't use reno since chromium doesn't (why?) */
-		protocol.InitialCongestionWindow,
-		protocol.DefaultMaxCongestionWindow,
-	)
+	packetsLost          uint64
+	packetsRetransmitted uint64
 
+	tracer logging.ConnectionTracer
+	// the last congestion window that was passed to the tracer
+	lastTracedCongestionWindow protocol.ByteCount
+}
+
+// NewSentPacketHandler creates a new sentPacketHandler.
+// If enableECN is set, packets are marked ECT(0) until ECN validation fails.
+// If enablePathMTUDiscovery is set, path MTU discovery probes for packet sizes up to maxPacketSize,
+// once the handshake completes.
+// The tracer may be nil.
+func NewSentPacketHandler(
+	rttStats *congestion.RTTStats,
+	congestionControl congestion.SendAlgorithm,
+	enableECN bool,
+	maxPacketSize protocol.ByteCount,
+	enablePathMTUDiscovery bool,
+	tracer logging.ConnectionTracer,
+) SentPacketHandler {
 	return &sentPacketHandler{
 		packetHistory:      NewPacketList(),
 		stopWaitingManager: stopWaitingManager{},
 		rttStats:           rttStats,
-		congestion:         congestion,
+		congestion:         congestionControl,
+		ecnTracker:         newECNTracker(enableECN),
+		mtuDiscoverer:      newMTUDiscoverer(rttStats, maxPacketSize, enablePathMTUDiscovery),
+		tracer:             tracer,
 	}
 }
 
@@ -97,19 +117,58 @@ func (h *sentPacketHandler) lowestUnacked() protocol.PacketNumber {
 func (h *sentPacketHandler) SetHandshakeComplete() {
 	var queue []*Packet
 	for _, packet := range h.retransmissionQueue {
-		if packet.EncryptionLevel == protocol.EncryptionForwardSecure {
+		if keepAfterHandshake(packet) {
 			queue = append(queue, packet)
 		}
 	}
 	for el := h.packetHistory.Front(); el != nil; {
 		next := el.Next()
-		if el.Value.EncryptionLevel != protocol.EncryptionForwardSecure {
+		if !keepAfterHandshake(&el.Value) {
 			h.packetHistory.Remove(el)
 		}
 		el = next
 	}
 	h.retransmissionQueue = queue
 	h.handshakeComplete = true
+	h.mtuDiscoverer.Start(time.Now())
+}
+
+// keepAfterHandshake says if a packet still needs to be tracked after the handshake completed.
+// 0-RTT packets carry application data, so they are acknowledged and retransmitted like forward-secure packets.
+func keepAfterHandshake(p *Packet) bool {
+	return p.EncryptionLevel == protocol.EncryptionForwardSecure || p.PacketType == protocol.PacketType0RTT
+}
+
+func (h *sentPacketHandler) Queue0RTTPacketsForRetransmission() {
+	var packets []*PacketElement
+	for el := h.packetHistory.Front(); el != nil; el = el.Next() {
+		if el.Value.PacketType == protocol.PacketType0RTT {
+			packets = append(packets, el)
+		}
+	}
+	for _, el := range packets {
+		h.queuePacketForRetransmission(el)
+	}
+	h.updateLossDetectionAlarm(time.Now())
+}
+
+// OnConnectionMigration resets the RTT estimate and the congestion controller.
+// It is called when the path of the connection changed.
+// Path MTU discovery is restarted if enablePathMTUDiscovery is set, and disabled otherwise.
+func (h *sentPacketHandler) OnConnectionMigration(enablePathMTUDiscovery bool) {
+	h.rttStats.OnConnectionMigration()
+	h.congestion.OnConnectionMigration()
+	h.ecnTracker.Reset()
+	h.mtuDiscoverer.Reset(time.Now(), enablePathMTUDiscovery)
+	h.maybeTraceCongestionWindow()
+}
+
+func (h *sentPacketHandler) SetPeerMaxPacketSize(s protocol.ByteCount) {
+	h.mtuDiscoverer.SetPeerMaxPacketSize(s)
+}
+
+func (h *sentPacketHandler) SetPeerMaxAckDelay(d time.Duration) {
+	h.peerMaxAckDelay = d
 }
 
 func (h *sentPacketHandler) SentPacket(packet *Packet) error {
@@ -127,6 +186,13 @@ func (h *sentPacketHandler) SentPacket(packet *Packet) error {
 
 	now := time.Now()
 	h.lastSentPacketNumber = packet.PacketNumber
+	h.ecnTracker.SentPacket(packet.PacketNumber, packet.ECN)
+	if packet.IsRetransmission {
+		h.packetsRetransmitted++
+	}
+	if packet.IsMTUProbePacket {
+		h.mtuDiscoverer.SentProbe(packet.PacketNumber, now)
+	}
 
 	var largestAcked protocol.PacketNumber
 	if len(packet.Frames) > 0 {
@@ -185,6 +251,9 @@ func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumbe
 
 	if rttUpdated {
 		h.congestion.MaybeExitSlowStart()
+		if h.tracer != nil {
+			h.tracer.UpdatedRTT(h.rttStats)
+		}
 	}
 
 	ackedPackets, err := h.determineNewlyAckedPackets(ackFrame)
@@ -193,6 +262,10 @@ func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumbe
 	}
 
 	if len(ackedPackets) > 0 {
+		packets := make([]*Packet, len(ackedPackets))
+		for i, p := range ackedPackets {
+			packets[i] = &p.Value
+		}
 		for _, p := range ackedPackets {
 			if encLevel < p.Value.EncryptionLevel {
 				return fmt.Errorf("Received ACK with encryption level %s that acks a packet %d (encryption level %s)", encLevel, p.Value.PacketNumber, p.Value.EncryptionLevel)
@@ -205,11 +278,22 @@ func (h *sentPacketHandler) ReceivedAck(ackFrame *wire.AckFrame, withPacketNumbe
 			}
 			h.onPacketAcked(p)
 			h.congestion.OnPacketAcked(p.Value.PacketNumber, p.Value.Length, h.bytesInFlight)
+			if p.Value.IsMTUProbePacket && h.mtuDiscoverer.ProbeAcked(p.Value.PacketNumber, p.Value.Length) {
+				utils.Debugf("\tPath MTU discovery confirmed a packet size of %d bytes.", p.Value.Length)
+			}
+		}
+		// A CE mark is a congestion signal.
+		// It is reported to the congestion controller like the loss of the largest acknowledged packet (without any lost bytes),
+		// so that the congestion controller reduces the congestion window at most once per round trip.
+		if h.ecnTracker.HandleNewlyAcked(packets, ackFrame.ECT0, ackFrame.ECT1, ackFrame.ECNCE) {
+			utils.Debugf("\tPeer reported CE marks. Reducing the congestion window.")
+			h.congestion.OnPacketLost(packets[len(packets)-1].PacketNumber, 0, h.bytesInFlight)
 		}
 	}
 
 	h.detectLostPackets(rcvTime)
 	h.updateLossDetectionAlarm(rcvTime)
+	h.maybeTraceCongestionWindow()
 
 	h.garbageCollectSkippedPackets()
 	h.stopWaitingManager.ReceivedAck(ackFrame)
@@ -262,6 +346,11 @@ func (h *sentPacketHandler) maybeUpdateRTT(largestAcked protocol.PacketNumber, a
 	for el := h.packetHistory.Front(); el != nil; el = el.Next() {
 		packet := el.Value
 		if packet.PacketNumber == largestAcked {
+			// The peer never delays an ACK by more than its max_ack_delay.
+			// Larger values are caused by scheduling delays on the peer's side, and must not reduce the RTT sample.
+			if h.peerMaxAckDelay > 0 {
+				ackDelay = utils.MinDuration(ackDelay, h.peerMaxAckDelay)
+			}
 			h.rttStats.UpdateRTT(rcvTime.Sub(packet.sendTime), ackDelay, rcvTime)
 			return true
 		}
@@ -317,8 +406,17 @@ func (h *sentPacketHandler) detectLostPackets(now time.Time) {
 
 	if len(lostPackets) > 0 {
 		for _, p := range lostPackets {
+			if p.Value.IsMTUProbePacket {
+				h.onMTUProbeLost(p, logging.PacketLossTimeThreshold)
+				continue
+			}
 			h.queuePacketForRetransmission(p)
+			h.packetsLost++
+			h.ecnTracker.LostPacket(p.Value.PacketNumber)
 			h.congestion.OnPacketLost(p.Value.PacketNumber, p.Value.Length, h.bytesInFlight)
+			if h.tracer != nil {
+				h.tracer.LostPacket(p.Value.EncryptionLevel, p.Value.PacketNumber, logging.PacketLossTimeThreshold)
+			}
 		}
 	}
 }
@@ -340,6 +438,7 @@ func (h *sentPacketHandler) OnAlarm() {
 	}
 
 	h.updateLossDetectionAlarm(now)
+	h.maybeTraceCongestionWindow()
 }
 
 func (h *sentPacketHandler) GetAlarmTimeout() time.Time {
@@ -388,6 +487,18 @@ func (h *sentPacketHandler) SendingAllowed() bool {
 	return !maxTrackedLimited && (!congestionLimited || haveRetransmissions)
 }
 
+func (h *sentPacketHandler) ECNMode() protocol.ECN {
+	return h.ecnTracker.Mode()
+}
+
+func (h *sentPacketHandler) MaxPacketSize() protocol.ByteCount {
+	return h.mtuDiscoverer.CurrentSize()
+}
+
+func (h *sentPacketHandler) MTUProbeSize(now time.Time) protocol.ByteCount {
+	return h.mtuDiscoverer.NextProbeSize(now)
+}
+
 func (h *sentPacketHandler) TimeUntilSend() time.Time {
 	return h.nextPacketSendTime
 }
@@ -400,6 +511,15 @@ func (h *sentPacketHandler) ShouldSendNumPackets() int {
 	return int(math.Ceil(float64(protocol.MinPacingDelay) / float64(delay)))
 }
 
+func (h *sentPacketHandler) GetStats() Stats {
+	return Stats{
+		CongestionWindow:     h.congestion.GetCongestionWindow(),
+		BytesInFlight:        h.bytesInFlight,
+		PacketsLost:          h.packetsLost,
+		PacketsRetransmitted: h.packetsRetransmitted,
+	}
+}
+
 func (h *sentPacketHandler) retransmitOldestTwoPackets() {
 	if p := h.packetHistory.Front(); p != nil {
 		h.queueRTO(p)
@@ -411,14 +531,51 @@ func (h *sentPacketHandler) retransmitOldestTwoPackets() {
 
 func (h *sentPacketHandler) queueRTO(el *PacketElement) {
 	packet := &el.Value
+	if packet.IsMTUProbePacket {
+		h.onMTUProbeLost(el, logging.PacketLossRTO)
+		return
+	}
 	utils.Debugf(
 		"\tQueueing packet 0x%x for retransmission (RTO), %d outstanding",
 		packet.PacketNumber,
 		h.packetHistory.Len(),
 	)
 	h.queuePacketForRetransmission(el)
+	h.packetsLost++
+	h.ecnTracker.LostPacket(packet.PacketNumber)
 	h.congestion.OnPacketLost(packet.PacketNumber, packet.Length, h.bytesInFlight)
 	h.congestion.OnRetransmissionTimeout(true)
+	if h.tracer != nil {
+		h.tracer.LostPacket(packet.EncryptionLevel, packet.PacketNumber, logging.PacketLossRTO)
+	}
+}
+
+// onMTUProbeLost handles the loss of a probe packet.
+// A probe packet is most likely lost because it was too large, which is not a sign of congestion.
+// It is therefore neither reported to the congestion controller nor retransmitted.
+func (h *sentPacketHandler) onMTUProbeLost(el *PacketElement, reason logging.PacketLossReason) {
+	packet := &el.Value
+	utils.Debugf("\tPath MTU probe packet 0x%x (%d bytes) lost", packet.PacketNumber, packet.Length)
+	h.bytesInFlight -= packet.Length
+	h.packetHistory.Remove(el)
+	h.stopWaitingManager.QueuedRetransmissionForPacketNumber(packet.PacketNumber)
+	h.mtuDiscoverer.ProbeLost(packet.PacketNumber, packet.Length)
+	if h.tracer != nil {
+		h.tracer.LostPacket(packet.EncryptionLevel, packet.PacketNumber, reason)
+	}
+}
+
+// maybeTraceCongestionWindow passes the congestion window to the tracer, if it changed
+func (h *sentPacketHandler) maybeTraceCongestionWindow() {
+	if h.tracer == nil {
+		return
+	}
+	cwnd := h.congestion.GetCongestionWindow()
+	if cwnd == h.lastTracedCongestionWindow {
+		return
+	}
+	h.lastTracedCongestionWindow = cwnd
+	h.tracer.UpdatedCongestionWindow(cwnd)
 }
 
 func (h *sentPacketHandler) queueHandshakePacketsForRetransmission() {
@@ -436,7 +593,10 @@ func (h *sentPacketHandler) queueHandshakePacketsForRetransmission() {
 func (h *sentPacketHandler) queuePacketForRetransmission(packetElement *PacketElement) {
 	packet := &packetElement.Value
 	h.bytesInFlight -= packet.Length
-	h.retransmissionQueue = append(h.retransmissionQueue, packet)
+	packet.Frames = stripUnreliableFrames(packet.Frames)
+	if len(packet.Frames) > 0 {
+		h.retransmissionQueue = append(h.retransmissionQueue, packet)
+	}
 	h.packetHistory.Remove(packetElement)
 	h.stopWaitingManager.QueuedRetransmissionForPacketNumber(packet.PacketNumber)
 }
@@ -457,6 +617,8 @@ func (h *sentPacketHandler) computeRTOTimeout() time.Duration {
 	if rto == 0 {
 		rto = defaultRTOTimeout
 	}
+	// give the peer enough time to send a delayed ACK
+	rto += h.peerMaxAckDelay
 	rto = utils.MaxDuration(rto, minRTOTimeout)
 	// Exponential backoff
 	rto = rto << h.rtoCount
@@ -6,8 +6,10 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
+	"github.com/lucas-clemente/quic-go/internal/mocks/logging"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -34,6 +36,16 @@ func handshakePacket(num protocol.PacketNumber) *Packet {
 	}
 }
 
+func zeroRTTPacket(num protocol.PacketNumber) *Packet {
+	return &Packet{
+		PacketNumber:    num,
+		PacketType:      protocol.PacketType0RTT,
+		Length:          1,
+		Frames:          []wire.Frame{&wire.PingFrame{}},
+		EncryptionLevel: protocol.EncryptionSecure,
+	}
+}
+
 var _ = Describe("SentPacketHandler", func() {
 	var (
 		handler     *sentPacketHandler
@@ -42,7 +54,14 @@ var _ = Describe("SentPacketHandler", func() {
 
 	BeforeEach(func() {
 		rttStats := &congestion.RTTStats{}
-		handler = NewSentPacketHandler(rttStats).(*sentPacketHandler)
+		cong := congestion.NewCubicSender(
+			congestion.DefaultClock{},
+			rttStats,
+			false,
+			protocol.InitialCongestionWindow,
+			protocol.DefaultMaxCongestionWindow,
+		)
+		handler = NewSentPacketHandler(rttStats, cong, true, protocol.DefaultMaxPacketSize, true, nil).(*sentPacketHandler)
 		handler.SetHandshakeComplete()
 		streamFrame = wire.StreamFrame{
 			StreamID: 5,
@@ -508,6 +527,17 @@ var _ = Describe("SentPacketHandler", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(handler.rttStats.LatestRTT()).To(BeNumerically("~", 5*time.Minute, 1*time.Second))
 			})
+
+			It("limits the DelayTime to the peer's max_ack_delay", func() {
+				now := time.Now()
+				handler.SetPeerMaxAckDelay(time.Minute)
+				// make sure the rttStats have a min RTT, so that the delay is used
+				handler.rttStats.UpdateRTT(5*time.Minute, 0, time.Now())
+				getPacketElement(1).Value.sendTime = now.Add(-10 * time.Minute)
+				err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, DelayTime: 5 * time.Minute}, 1, protocol.EncryptionUnencrypted, time.Now())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(handler.rttStats.LatestRTT()).To(BeNumerically("~", 9*time.Minute, 1*time.Second))
+			})
 		})
 
 		Context("determining which ACKs we have received an ACK for", func() {
@@ -606,6 +636,25 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.DequeuePacketForRetransmission()).To(BeNil())
 		})
 
+		It("doesn't retransmit DATAGRAM frames", func() {
+			datagram := &wire.DatagramFrame{Data: []byte("foobar")}
+			el := getPacketElement(3)
+			el.Value.Frames = []wire.Frame{datagram, &streamFrame}
+			handler.queuePacketForRetransmission(el)
+			Expect(handler.retransmissionQueue).To(HaveLen(1))
+			Expect(handler.retransmissionQueue[0].Frames).To(Equal([]wire.Frame{&streamFrame}))
+		})
+
+		It("doesn't queue packets that only contain DATAGRAM frames", func() {
+			el := getPacketElement(3)
+			el.Value.Frames = []wire.Frame{&wire.DatagramFrame{Data: []byte("foobar")}}
+			handler.queuePacketForRetransmission(el)
+			Expect(handler.retransmissionQueue).To(BeEmpty())
+			Expect(handler.packetsRetransmitted).To(BeZero())
+			Expect(getPacketElement(3)).To(BeNil())
+			Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(5)))
+		})
+
 		Context("STOP_WAITINGs", func() {
 			It("gets a STOP_WAITING frame", func() {
 				ack := wire.AckFrame{LargestAcked: 5, LowestAcked: 5}
@@ -627,7 +676,6 @@ var _ = Describe("SentPacketHandler", func() {
 		packet3 := Packet{PacketNumber: 3, Frames: []wire.Frame{&streamFrame}, Length: 3}
 		err := handler.SentPacket(&packet1)
 		Expect(err).NotTo(HaveOccurred())
-		Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(1)))
 		err = handler.SentPacket(&packet2)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(1 + 2)))
@@ -684,6 +732,13 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 
+		It("resets the RTT and the congestion controller on connection migration", func() {
+			handler.rttStats.UpdateRTT(time.Second, 0, time.Now())
+			cong.EXPECT().OnConnectionMigration()
+			handler.OnConnectionMigration(true)
+			Expect(handler.rttStats.SmoothedRTT()).To(BeZero())
+		})
+
 		It("should call MaybeExitSlowStart and OnPacketAcked", func() {
 			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
 			cong.EXPECT().TimeUntilSend(gomock.Any()).Times(2)
@@ -719,6 +774,38 @@ var _ = Describe("SentPacketHandler", func() {
 			handler.OnAlarm() // RTO, meaning 2 lost packets
 		})
 
+		It("reports CE marks to the congestion controller", func() {
+			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(3)
+			cong.EXPECT().TimeUntilSend(gomock.Any()).Times(3)
+			cong.EXPECT().MaybeExitSlowStart().Times(2)
+			cong.EXPECT().OnPacketAcked(gomock.Any(), gomock.Any(), gomock.Any()).Times(3)
+			for i := protocol.PacketNumber(1); i <= 3; i++ {
+				p := retransmittablePacket(i)
+				p.ECN = handler.ECNMode()
+				Expect(p.ECN).To(Equal(protocol.ECT0))
+				handler.SentPacket(p)
+			}
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1, ECT0: 1}, 1, protocol.EncryptionForwardSecure, time.Now())
+			Expect(err).NotTo(HaveOccurred())
+			cong.EXPECT().OnPacketLost(protocol.PacketNumber(3), protocol.ByteCount(0), protocol.ByteCount(0))
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 3, LowestAcked: 1, ECT0: 2, ECNCE: 1}, 2, protocol.EncryptionForwardSecure, time.Now())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(handler.ECNMode()).To(Equal(protocol.ECT0))
+		})
+
+		It("stops marking packets when the peer doesn't report ECN counts", func() {
+			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+			cong.EXPECT().TimeUntilSend(gomock.Any())
+			cong.EXPECT().MaybeExitSlowStart()
+			cong.EXPECT().OnPacketAcked(gomock.Any(), gomock.Any(), gomock.Any())
+			p := retransmittablePacket(1)
+			p.ECN = handler.ECNMode()
+			handler.SentPacket(p)
+			err := handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, 1, protocol.EncryptionForwardSecure, time.Now())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(handler.ECNMode()).To(Equal(protocol.ECNNon))
+		})
+
 		It("allows or denies sending based on congestion", func() {
 			handler.bytesInFlight = 100
 			cong.EXPECT().GetCongestionWindow().Return(protocol.ByteCount(200))
@@ -781,6 +868,13 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.computeRTOTimeout()).To(Equal(expected))
 		})
 
+		It("adds the peer's max_ack_delay", func() {
+			rtt := time.Second
+			handler.SetPeerMaxAckDelay(100 * time.Millisecond)
+			handler.rttStats.UpdateRTT(rtt, 0, time.Now())
+			Expect(handler.computeRTOTimeout()).To(Equal(rtt + rtt/2*4 + 100*time.Millisecond))
+		})
+
 		It("limits RTO min", func() {
 			rtt := time.Millisecond
 			handler.rttStats.UpdateRTT(rtt, 0, time.Now())
@@ -885,6 +979,40 @@ var _ = Describe("SentPacketHandler", func() {
 		})
 	})
 
+	Context("0-RTT", func() {
+		BeforeEach(func() {
+			handler.handshakeComplete = false
+		})
+
+		It("keeps track of 0-RTT packets when the handshake completes", func() {
+			Expect(handler.SentPacket(handshakePacket(1))).To(Succeed())
+			Expect(handler.SentPacket(zeroRTTPacket(2))).To(Succeed())
+			Expect(handler.SentPacket(zeroRTTPacket(3))).To(Succeed())
+			handler.SetHandshakeComplete()
+			Expect(getPacketElement(1)).To(BeNil())
+			Expect(getPacketElement(2)).ToNot(BeNil())
+			Expect(getPacketElement(3)).ToNot(BeNil())
+		})
+
+		It("queues 0-RTT packets for retransmission when 0-RTT is rejected", func() {
+			Expect(handler.SentPacket(handshakePacket(1))).To(Succeed())
+			Expect(handler.SentPacket(zeroRTTPacket(2))).To(Succeed())
+			Expect(handler.SentPacket(zeroRTTPacket(3))).To(Succeed())
+			handler.SetHandshakeComplete()
+			Expect(handler.SentPacket(retransmittablePacket(4))).To(Succeed())
+			handler.Queue0RTTPacketsForRetransmission()
+			p := handler.DequeuePacketForRetransmission()
+			Expect(p).ToNot(BeNil())
+			Expect(p.PacketNumber).To(Equal(protocol.PacketNumber(2)))
+			p = handler.DequeuePacketForRetransmission()
+			Expect(p).ToNot(BeNil())
+			Expect(p.PacketNumber).To(Equal(protocol.PacketNumber(3)))
+			Expect(handler.DequeuePacketForRetransmission()).To(BeNil())
+			Expect(handler.packetHistory.Len()).To(Equal(1))
+			Expect(handler.GetStats().PacketsLost).To(BeZero())
+		})
+	})
+
 	Context("RTO retransmission", func() {
 		It("queues two packets if RTO expires", func() {
 			err := handler.SentPacket(retransmittablePacket(1))
@@ -907,4 +1035,187 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.rtoCount).To(BeEquivalentTo(1))
 		})
 	})
+
+	Context("path MTU discovery", func() {
+		mtuProbePacket := func(num protocol.PacketNumber, size protocol.ByteCount) *Packet {
+			p := retransmittablePacket(num)
+			p.Length = size
+			p.IsMTUProbePacket = true
+			return p
+		}
+
+		It("only sends probe packets after the handshake completed", func() {
+			handler = NewSentPacketHandler(handler.rttStats, handler.congestion, false, protocol.DefaultMaxPacketSize, true, nil).(*sentPacketHandler)
+			Expect(handler.MTUProbeSize(time.Now())).To(BeZero())
+			handler.SetHandshakeComplete()
+			Expect(handler.MTUProbeSize(time.Now())).To(Equal((protocol.InitialPacketSize + protocol.DefaultMaxPacketSize) / 2))
+		})
+
+		It("doesn't send probe packets if path MTU discovery is disabled", func() {
+			handler = NewSentPacketHandler(handler.rttStats, handler.congestion, false, protocol.DefaultMaxPacketSize, false, nil).(*sentPacketHandler)
+			handler.SetHandshakeComplete()
+			Expect(handler.MTUProbeSize(time.Now())).To(BeZero())
+		})
+
+		It("increases the packet size when a probe packet is acknowledged", func() {
+			Expect(handler.MaxPacketSize()).To(Equal(protocol.InitialPacketSize))
+			Expect(handler.SentPacket(mtuProbePacket(1, 1300))).To(Succeed())
+			Expect(handler.MTUProbeSize(time.Now())).To(BeZero()) // only a single probe packet is sent at a time
+			Expect(handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, 1, protocol.EncryptionForwardSecure, time.Now())).To(Succeed())
+			Expect(handler.MaxPacketSize()).To(Equal(protocol.ByteCount(1300)))
+		})
+
+		It("doesn't report the loss of probe packets to the congestion controller", func() {
+			cong := mocks.NewMockSendAlgorithm(mockCtrl)
+			handler.congestion = cong
+			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+			cong.EXPECT().TimeUntilSend(gomock.Any()).Times(2)
+			cong.EXPECT().MaybeExitSlowStart()
+			cong.EXPECT().OnPacketAcked(protocol.PacketNumber(2), gomock.Any(), gomock.Any())
+			cong.EXPECT().RetransmissionDelay().AnyTimes()
+			cong.EXPECT().GetCongestionWindow().AnyTimes()
+			// no call to OnPacketLost
+			Expect(handler.SentPacket(mtuProbePacket(1, 1300))).To(Succeed())
+			Expect(handler.SentPacket(retransmittablePacket(2))).To(Succeed())
+			handler.packetHistory.Front().Value.sendTime = time.Now().Add(-time.Hour)
+			Expect(handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 2}, 1, protocol.EncryptionForwardSecure, time.Now())).To(Succeed())
+			Expect(handler.packetHistory.Len()).To(BeZero())
+			Expect(handler.bytesInFlight).To(BeZero())
+			Expect(handler.DequeuePacketForRetransmission()).To(BeNil())
+			Expect(handler.GetStats().PacketsLost).To(BeZero())
+			Expect(handler.MaxPacketSize()).To(Equal(protocol.InitialPacketSize))
+			// the probe is repeated
+			Expect(handler.MTUProbeSize(time.Now().Add(time.Second))).To(Equal(protocol.ByteCount(1326)))
+		})
+
+		It("doesn't retransmit probe packets when the RTO fires", func() {
+			Expect(handler.SentPacket(mtuProbePacket(1, 1300))).To(Succeed())
+			Expect(handler.SentPacket(retransmittablePacket(2))).To(Succeed())
+			handler.OnAlarm()
+			p := handler.DequeuePacketForRetransmission()
+			Expect(p).ToNot(BeNil())
+			Expect(p.PacketNumber).To(Equal(protocol.PacketNumber(2)))
+			Expect(handler.DequeuePacketForRetransmission()).To(BeNil())
+			Expect(handler.packetHistory.Len()).To(BeZero())
+		})
+
+		It("limits the packet size to the peer's maximum packet size", func() {
+			handler.SetPeerMaxPacketSize(1300)
+			Expect(handler.MTUProbeSize(time.Now())).To(Equal(protocol.ByteCount(1250)))
+		})
+
+		It("restarts path MTU discovery on connection migration", func() {
+			Expect(handler.SentPacket(mtuProbePacket(1, 1300))).To(Succeed())
+			Expect(handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, 1, protocol.EncryptionForwardSecure, time.Now())).To(Succeed())
+			Expect(handler.MaxPacketSize()).To(Equal(protocol.ByteCount(1300)))
+			handler.OnConnectionMigration(true)
+			Expect(handler.MaxPacketSize()).To(Equal(protocol.InitialPacketSize))
+			Expect(handler.MTUProbeSize(time.Now().Add(time.Hour))).ToNot(BeZero())
+		})
+
+		It("stops path MTU discovery when migrating to a path that doesn't support it", func() {
+			handler.SetHandshakeComplete()
+			Expect(handler.MTUProbeSize(time.Now().Add(time.Hour))).ToNot(BeZero())
+			handler.OnConnectionMigration(false)
+			Expect(handler.MTUProbeSize(time.Now().Add(time.Hour))).To(BeZero())
+		})
+	})
+
+	Context("statistics", func() {
+		It("reports the congestion window and the bytes in flight", func() {
+			err := handler.SentPacket(&Packet{PacketNumber: 1, Frames: []wire.Frame{&streamFrame}, Length: 42})
+			Expect(err).ToNot(HaveOccurred())
+			stats := handler.GetStats()
+			Expect(stats.BytesInFlight).To(Equal(protocol.ByteCount(42)))
+			Expect(stats.CongestionWindow).To(Equal(handler.congestion.GetCongestionWindow()))
+		})
+
+		It("counts packets lost due to an RTO", func() {
+			err := handler.SentPacket(retransmittablePacket(1))
+			Expect(err).NotTo(HaveOccurred())
+			err = handler.SentPacket(retransmittablePacket(2))
+			Expect(err).NotTo(HaveOccurred())
+			handler.OnAlarm()
+			stats := handler.GetStats()
+			Expect(stats.PacketsLost).To(BeEquivalentTo(2))
+			Expect(stats.PacketsRetransmitted).To(BeZero())
+			Expect(stats.BytesInFlight).To(BeZero())
+		})
+
+		It("counts retransmissions when they are sent", func() {
+			Expect(handler.SentPacket(retransmittablePacket(1))).To(Succeed())
+			handler.OnAlarm()
+			Expect(handler.DequeuePacketForRetransmission()).ToNot(BeNil())
+			Expect(handler.GetStats().PacketsRetransmitted).To(BeZero())
+			p := retransmittablePacket(2)
+			p.IsRetransmission = true
+			Expect(handler.SentPacket(p)).To(Succeed())
+			Expect(handler.SentPacket(retransmittablePacket(3))).To(Succeed())
+			Expect(handler.GetStats().PacketsRetransmitted).To(BeEquivalentTo(1))
+		})
+
+		It("doesn't count retransmissions of handshake packets as lost", func() {
+			handler.handshakeComplete = false
+			err := handler.SentPacket(handshakePacket(1))
+			Expect(err).ToNot(HaveOccurred())
+			handler.OnAlarm()
+			stats := handler.GetStats()
+			Expect(stats.PacketsLost).To(BeZero())
+			Expect(stats.PacketsRetransmitted).To(BeZero())
+		})
+	})
+
+	Context("tracing", func() {
+		var tracer *mocklogging.MockConnectionTracer
+
+		BeforeEach(func() {
+			tracer = mocklogging.NewMockConnectionTracer(mockCtrl)
+			handler.tracer = tracer
+		})
+
+		It("traces RTT updates and congestion window changes", func() {
+			err := handler.SentPacket(retransmittablePacket(1))
+			Expect(err).NotTo(HaveOccurred())
+			getPacketElement(1).Value.sendTime = time.Now().Add(-time.Second)
+			gomock.InOrder(
+				tracer.EXPECT().UpdatedRTT(handler.rttStats).Do(func(rttStats *congestion.RTTStats) {
+					Expect(rttStats.LatestRTT()).To(BeNumerically("~", time.Second, 100*time.Millisecond))
+				}),
+				tracer.EXPECT().UpdatedCongestionWindow(gomock.Any()),
+			)
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 1, LowestAcked: 1}, 1, protocol.EncryptionForwardSecure, time.Now())
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("doesn't trace the congestion window if it didn't change", func() {
+			tracer.EXPECT().UpdatedCongestionWindow(handler.congestion.GetCongestionWindow())
+			handler.maybeTraceCongestionWindow()
+			handler.maybeTraceCongestionWindow()
+		})
+
+		It("traces packets lost due to the time threshold", func() {
+			err := handler.SentPacket(retransmittablePacket(1))
+			Expect(err).NotTo(HaveOccurred())
+			err = handler.SentPacket(retransmittablePacket(2))
+			Expect(err).NotTo(HaveOccurred())
+			getPacketElement(1).Value.sendTime = time.Now().Add(-2 * time.Hour)
+			getPacketElement(2).Value.sendTime = time.Now().Add(-time.Second)
+			tracer.EXPECT().UpdatedRTT(gomock.Any())
+			tracer.EXPECT().LostPacket(protocol.EncryptionForwardSecure, protocol.PacketNumber(1), logging.PacketLossTimeThreshold)
+			tracer.EXPECT().UpdatedCongestionWindow(gomock.Any())
+			err = handler.ReceivedAck(&wire.AckFrame{LargestAcked: 2, LowestAcked: 2}, 1, protocol.EncryptionForwardSecure, time.Now())
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("traces packets lost due to an RTO", func() {
+			err := handler.SentPacket(retransmittablePacket(1))
+			Expect(err).NotTo(HaveOccurred())
+			err = handler.SentPacket(retransmittablePacket(2))
+			Expect(err).NotTo(HaveOccurred())
+			tracer.EXPECT().LostPacket(protocol.EncryptionForwardSecure, protocol.PacketNumber(1), logging.PacketLossRTO)
+			tracer.EXPECT().LostPacket(protocol.EncryptionForwardSecure, protocol.PacketNumber(2), logging.PacketLossRTO)
+			tracer.EXPECT().UpdatedCongestionWindow(gomock.Any())
+			handler.OnAlarm()
+		})
+	})
 })
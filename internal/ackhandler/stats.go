@@ -0,0 +1,13 @@
+package ackhandler
+
+import "github.com/lucas-clemente/quic-go/internal/protocol"
+
+// Stats are statistics collected by the SentPacketHandler
+type Stats struct {
+	CongestionWindow protocol.ByteCount
+	BytesInFlight    protocol.ByteCount
+	// PacketsLost is the number of packets that were declared lost, either by loss detection or by an RTO
+	PacketsLost uint64
+	// PacketsRetransmitted is the number of packets sent that carried retransmitted frames
+	PacketsRetransmitted uint64
+}
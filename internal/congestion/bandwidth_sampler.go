@@ -0,0 +1,144 @@
+package congestion
+
+import (
+	"math"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+const infBandwidth Bandwidth = math.MaxUint64
+
+// A bandwidthSample is the delivery rate measured when a packet was acknowledged
+type bandwidthSample struct {
+	bandwidth Bandwidth
+	rtt       time.Duration
+}
+
+// The state of the connection at the time a packet was sent
+type sentPacketState struct {
+	size     protocol.ByteCount
+	sendTime time.Time
+	// the values of the bandwidthSampler at the time the packet was sent
+	totalBytesSent                  protocol.ByteCount
+	totalBytesAcked                 protocol.ByteCount
+	totalBytesSentAtLastAckedPacket protocol.ByteCount
+	lastAckedPacketSentTime         time.Time
+	lastAckedPacketAckTime          time.Time
+}
+
+// The bandwidthSampler calculates the delivery rate of the connection.
+// For every acknowledged packet, the delivery rate is the minimum of the send rate and the ack rate,
+// calculated between the time the packet was sent and the time it was acknowledged.
+// See https://tools.ietf.org/html/draft-cheng-iccrg-delivery-rate-estimation-00.
+type bandwidthSampler struct {
+	totalBytesSent  protocol.ByteCount
+	totalBytesAcked protocol.ByteCount
+	// the value of totalBytesSent when the last acknowledged packet was sent
+	totalBytesSentAtLastAckedPacket protocol.ByteCount
+	lastAckedPacketSentTime         time.Time
+	lastAckedPacketAckTime          time.Time
+
+	packets map[protocol.PacketNumber]*sentPacketState
+	// the lowest packet number contained in packets, if packets is not empty
+	lowestTrackedPacket protocol.PacketNumber
+}
+
+func newBandwidthSampler() *bandwidthSampler {
+	return &bandwidthSampler{packets: make(map[protocol.PacketNumber]*sentPacketState)}
+}
+
+// OnPacketSent must be called for every retransmittable packet that is sent
+func (s *bandwidthSampler) OnPacketSent(sentTime time.Time, pn protocol.PacketNumber, bytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	s.totalBytesSent += bytes
+	// If there are no packets in flight, the time between the last ACK and this packet would be counted as part of the sending interval,
+	// which would result in an underestimation of the bandwidth.
+	// Pretend that the last packet was acknowledged right now.
+	if bytesInFlight == 0 {
+		s.lastAckedPacketAckTime = sentTime
+		s.totalBytesSentAtLastAckedPacket = s.totalBytesSent
+		s.lastAckedPacketSentTime = sentTime
+	}
+	if len(s.packets) == 0 {
+		s.lowestTrackedPacket = pn
+	}
+	s.packets[pn] = &sentPacketState{
+		size:                            bytes,
+		sendTime:                        sentTime,
+		totalBytesSent:                  s.totalBytesSent,
+		totalBytesAcked:                 s.totalBytesAcked,
+		totalBytesSentAtLastAckedPacket: s.totalBytesSentAtLastAckedPacket,
+		lastAckedPacketSentTime:         s.lastAckedPacketSentTime,
+		lastAckedPacketAckTime:          s.lastAckedPacketAckTime,
+	}
+	// Packets that are neither acknowledged nor declared lost (e.g. handshake packets) would never be removed.
+	// Limit the number of tracked packets.
+	for len(s.packets) > protocol.MaxTrackedSentPackets {
+		s.removePacket(s.lowestTrackedPacket)
+	}
+}
+
+// OnPacketAcked must be called when a packet is acknowledged.
+// It returns false if no bandwidth sample could be taken.
+func (s *bandwidthSampler) OnPacketAcked(ackTime time.Time, pn protocol.PacketNumber) (bandwidthSample, bool) {
+	p, ok := s.packets[pn]
+	if !ok {
+		return bandwidthSample{}, false
+	}
+	s.removePacket(pn)
+
+	s.totalBytesAcked += p.size
+	s.totalBytesSentAtLastAckedPacket = p.totalBytesSent
+	s.lastAckedPacketSentTime = p.sendTime
+	s.lastAckedPacketAckTime = ackTime
+
+	// There might have been no packets acknowledged at the moment when the current packet was sent.
+	// In that case, there is no bandwidth data available.
+	if p.lastAckedPacketSentTime.IsZero() {
+		return bandwidthSample{}, false
+	}
+	// Infinite send rate, if the packets were sent at the same time.
+	var sendRate Bandwidth = infBandwidth
+	if p.sendTime.After(p.lastAckedPacketSentTime) {
+		sendRate = BandwidthFromDelta(p.totalBytesSent-p.totalBytesSentAtLastAckedPacket, p.sendTime.Sub(p.lastAckedPacketSentTime))
+	}
+	// During the slope calculation, ensure that the ACK time of the current packet is always larger than the time of the previous packet,
+	// otherwise division by zero or integer underflow can occur.
+	if !ackTime.After(p.lastAckedPacketAckTime) {
+		return bandwidthSample{}, false
+	}
+	ackRate := BandwidthFromDelta(s.totalBytesAcked-p.totalBytesAcked, ackTime.Sub(p.lastAckedPacketAckTime))
+	bw := ackRate
+	if sendRate < ackRate {
+		bw = sendRate
+	}
+	return bandwidthSample{bandwidth: bw, rtt: ackTime.Sub(p.sendTime)}, true
+}
+
+// OnPacketLost must be called when a packet is declared lost
+func (s *bandwidthSampler) OnPacketLost(pn protocol.PacketNumber) {
+	s.removePacket(pn)
+}
+
+func (s *bandwidthSampler) removePacket(pn protocol.PacketNumber) {
+	if _, ok := s.packets[pn]; !ok {
+		return
+	}
+	delete(s.packets, pn)
+	if pn != s.lowestTrackedPacket {
+		return
+	}
+	// Advance to the next tracked packet.
+	// Packet numbers are increasing, so every packet number is only skipped once.
+	for len(s.packets) > 0 {
+		s.lowestTrackedPacket++
+		if _, ok := s.packets[s.lowestTrackedPacket]; ok {
+			return
+		}
+	}
+}
+
+// TotalBytesAcked returns the number of bytes that were acknowledged
+func (s *bandwidthSampler) TotalBytesAcked() protocol.ByteCount {
+	return s.totalBytesAcked
+}
@@ -0,0 +1,127 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bandwidth sampler", func() {
+	var (
+		sampler *bandwidthSampler
+		now     time.Time
+	)
+
+	BeforeEach(func() {
+		sampler = newBandwidthSampler()
+		now = time.Now()
+	})
+
+	It("samples the send rate when packets are sent slower than they are acknowledged", func() {
+		// send one packet every 10ms, every packet is acknowledged after 40ms
+		var bytesInFlight protocol.ByteCount
+		var sample bandwidthSample
+		for i := 1; i <= 20; i++ {
+			if i > 4 {
+				var ok bool
+				sample, ok = sampler.OnPacketAcked(now, protocol.PacketNumber(i-4))
+				Expect(ok).To(BeTrue())
+				bytesInFlight -= 1000
+			}
+			sampler.OnPacketSent(now, protocol.PacketNumber(i), 1000, bytesInFlight)
+			bytesInFlight += 1000
+			now = now.Add(10 * time.Millisecond)
+		}
+		Expect(sample.bandwidth).To(Equal(BandwidthFromDelta(1000, 10*time.Millisecond)))
+		Expect(sampler.TotalBytesAcked()).To(Equal(protocol.ByteCount(16 * 1000)))
+	})
+
+	It("samples the ack rate when packets are acknowledged slower than they are sent", func() {
+		// send a burst of 10 packets
+		var bytesInFlight protocol.ByteCount
+		pn := protocol.PacketNumber(1)
+		for ; pn <= 10; pn++ {
+			sampler.OnPacketSent(now, pn, 1000, bytesInFlight)
+			bytesInFlight += 1000
+		}
+		now = now.Add(40 * time.Millisecond)
+		// acknowledge one packet every 5ms, and send 2 new packets for every acknowledged packet
+		var sample bandwidthSample
+		for acked := protocol.PacketNumber(1); acked <= 20; acked++ {
+			now = now.Add(5 * time.Millisecond)
+			sample, _ = sampler.OnPacketAcked(now, acked)
+			bytesInFlight -= 1000
+			for i := 0; i < 2; i++ {
+				sampler.OnPacketSent(now, pn, 1000, bytesInFlight)
+				bytesInFlight += 1000
+				pn++
+			}
+		}
+		Expect(sample.bandwidth).To(Equal(BandwidthFromDelta(1000, 5*time.Millisecond)))
+	})
+
+	It("measures the RTT", func() {
+		sampler.OnPacketSent(now, 1, 1000, 0)
+		sampler.OnPacketSent(now.Add(time.Millisecond), 2, 1000, 1000)
+		now = now.Add(40 * time.Millisecond)
+		sampler.OnPacketAcked(now, 1)
+		now = now.Add(time.Millisecond)
+		sample, ok := sampler.OnPacketAcked(now, 2)
+		Expect(ok).To(BeTrue())
+		Expect(sample.rtt).To(Equal(40 * time.Millisecond))
+	})
+
+	It("doesn't sample lost packets", func() {
+		sampler.OnPacketSent(now, 1, 1000, 0)
+		sampler.OnPacketLost(1)
+		_, ok := sampler.OnPacketAcked(now.Add(time.Second), 1)
+		Expect(ok).To(BeFalse())
+		Expect(sampler.TotalBytesAcked()).To(BeZero())
+	})
+
+	It("limits the number of tracked packets", func() {
+		for i := 0; i < 2*protocol.MaxTrackedSentPackets; i++ {
+			sampler.OnPacketSent(now, protocol.PacketNumber(i), 1000, 0)
+		}
+		Expect(sampler.packets).To(HaveLen(protocol.MaxTrackedSentPackets))
+		Expect(sampler.packets).ToNot(HaveKey(protocol.PacketNumber(0)))
+		Expect(sampler.packets).To(HaveKey(protocol.PacketNumber(2*protocol.MaxTrackedSentPackets - 1)))
+	})
+
+	It("tracks the lowest packet number when packets are acknowledged and lost", func() {
+		for i := 1; i <= 5; i++ {
+			sampler.OnPacketSent(now, protocol.PacketNumber(i), 1000, protocol.ByteCount(i-1)*1000)
+		}
+		sampler.OnPacketAcked(now.Add(time.Second), 2)
+		Expect(sampler.lowestTrackedPacket).To(Equal(protocol.PacketNumber(1)))
+		sampler.OnPacketLost(1)
+		Expect(sampler.lowestTrackedPacket).To(Equal(protocol.PacketNumber(3)))
+		sampler.OnPacketAcked(now.Add(time.Second), 3)
+		Expect(sampler.lowestTrackedPacket).To(Equal(protocol.PacketNumber(4)))
+		sampler.OnPacketLost(4)
+		sampler.OnPacketLost(5)
+		Expect(sampler.packets).To(BeEmpty())
+		// the next packet sent is the lowest tracked packet, even if packet numbers were skipped
+		sampler.OnPacketSent(now, 100, 1000, 0)
+		Expect(sampler.lowestTrackedPacket).To(Equal(protocol.PacketNumber(100)))
+	})
+
+	It("doesn't walk over removed packets when limiting the number of tracked packets", func() {
+		for i := 1; i <= protocol.MaxTrackedSentPackets; i++ {
+			sampler.OnPacketSent(now, protocol.PacketNumber(i), 1000, 0)
+			if i > 1 {
+				sampler.OnPacketAcked(now, protocol.PacketNumber(i))
+			}
+		}
+		Expect(sampler.lowestTrackedPacket).To(Equal(protocol.PacketNumber(1)))
+		sampler.OnPacketLost(1)
+		Expect(sampler.packets).To(BeEmpty())
+		for i := 0; i <= protocol.MaxTrackedSentPackets; i++ {
+			sampler.OnPacketSent(now, protocol.PacketNumber(10*protocol.MaxTrackedSentPackets+i), 1000, 0)
+		}
+		Expect(sampler.packets).To(HaveLen(protocol.MaxTrackedSentPackets))
+		Expect(sampler.lowestTrackedPacket).To(Equal(protocol.PacketNumber(10*protocol.MaxTrackedSentPackets + 1)))
+	})
+})
@@ -0,0 +1,505 @@
+package congestion
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// This is an implementation of the BBR congestion control algorithm, as described in
+// https://tools.ietf.org/html/draft-cardwell-iccrg-bbr-congestion-control-00.
+// It closely follows the BBR implementation in Chromium.
+
+const (
+	// The gain used for the pacing rate and the congestion window in STARTUP, equal to 2/ln(2).
+	bbrHighGain = 2.885
+	// The gain used in DRAIN, to drain the queue created during STARTUP in one round trip.
+	bbrDrainGain = 1 / bbrHighGain
+	// The gain used for the congestion window in PROBE_BW.
+	bbrCongestionWindowGain = 2.0
+	// The number of round trips the bandwidth filter remembers a maximum.
+	bbrBandwidthWindowSize = uint64(len(bbrPacingGainCycle) + 2)
+	// The time after which the current minimum RTT is considered expired, triggering PROBE_RTT.
+	bbrMinRTTExpiry = 10 * time.Second
+	// The minimum time spent in PROBE_RTT.
+	bbrProbeRTTTime = 200 * time.Millisecond
+	// The bandwidth has to grow by this factor every round trip in STARTUP.
+	bbrStartupGrowthTarget = 1.25
+	// If the bandwidth doesn't grow for this many round trips, the pipe is considered full, and STARTUP is exited.
+	bbrRoundTripsWithoutGrowthBeforeExitingStartup = 3
+	// The minimum congestion window.
+	bbrMinCongestionWindow = 4 * protocol.DefaultTCPMSS
+	// The RTT used before an RTT sample is taken.
+	bbrDefaultInitialRTT = 100 * time.Millisecond
+)
+
+// The pacing gains used in PROBE_BW.
+// The sender probes for more bandwidth during one round trip, then drains the queue that might have been created.
+var bbrPacingGainCycle = [...]float64{1.25, 0.75, 1, 1, 1, 1, 1, 1}
+
+type bbrMode uint8
+
+const (
+	// bbrModeStartup ramps up the sending rate rapidly to fill the pipe
+	bbrModeStartup bbrMode = iota
+	// bbrModeDrain drains the queue that was created during STARTUP
+	bbrModeDrain
+	// bbrModeProbeBandwidth cruises at the estimated bandwidth, periodically probing for more
+	bbrModeProbeBandwidth
+	// bbrModeProbeRTT temporarily slows down, in order to measure the minimum RTT
+	bbrModeProbeRTT
+)
+
+func (m bbrMode) String() string {
+	switch m {
+	case bbrModeStartup:
+		return "STARTUP"
+	case bbrModeDrain:
+		return "DRAIN"
+	case bbrModeProbeBandwidth:
+		return "PROBE_BW"
+	case bbrModeProbeRTT:
+		return "PROBE_RTT"
+	default:
+		return "unknown mode"
+	}
+}
+
+// The recovery state limits the congestion window after packet loss.
+type bbrRecoveryState uint8
+
+const (
+	// bbrNotInRecovery means that no packets were lost recently
+	bbrNotInRecovery bbrRecoveryState = iota
+	// bbrConservation is used in the first round trip of recovery.
+	// Only as many bytes are sent as are acknowledged (packet conservation).
+	bbrConservation
+	// bbrGrowth is used in the following round trips of recovery.
+	// The recovery window grows by the number of bytes acknowledged, similar to slow start.
+	bbrGrowth
+)
+
+type bbrSender struct {
+	clock    Clock
+	rttStats *RTTStats
+	sampler  *bandwidthSampler
+
+	mode bbrMode
+
+	// The number of round trips since the start of the connection, and
+	// the packet number of the packet that marks the end of the current round trip.
+	roundTripCount      uint64
+	currentRoundTripEnd protocol.PacketNumber
+	lastSentPacket      protocol.PacketNumber
+
+	maxBandwidth *windowedMaxBandwidth
+
+	// The minimum RTT, and the time it was measured.
+	minRTT          time.Duration
+	minRTTTimestamp time.Time
+
+	congestionWindow        protocol.ByteCount
+	initialCongestionWindow protocol.ByteCount
+	maxCongestionWindow     protocol.ByteCount
+
+	// The pacing rate. It is 0 as long as no bandwidth estimate is available.
+	pacingRate Bandwidth
+
+	pacingGain           float64
+	congestionWindowGain float64
+
+	// The current offset into bbrPacingGainCycle, and the time the current cycle phase was entered.
+	cycleCurrentOffset int
+	lastCycleStart     time.Time
+
+	// Set when the sender detected that the pipe is full, i.e. the bandwidth didn't grow in STARTUP.
+	isAtFullBandwidth          bool
+	roundsWithoutBandwidthGain int
+	bandwidthAtLastRound       Bandwidth
+
+	// The time at which PROBE_RTT is exited. Zero as long as PROBE_RTT is waiting for the bytes in flight to drop.
+	exitProbeRTTAt      time.Time
+	probeRTTRoundPassed bool
+
+	recoveryState bbrRecoveryState
+	// The congestion window is limited to the recovery window during recovery. 0 until it is initialized.
+	recoveryWindow protocol.ByteCount
+	// Recovery is exited when a packet sent after the last loss is acknowledged.
+	endRecoveryAt protocol.PacketNumber
+}
+
+var _ SendAlgorithm = &bbrSender{}
+
+// NewBBRSender makes a new BBR sender
+func NewBBRSender(clock Clock, rttStats *RTTStats, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) SendAlgorithm {
+	return newBBRSender(clock, rttStats, initialCongestionWindow, initialMaxCongestionWindow)
+}
+
+func newBBRSender(clock Clock, rttStats *RTTStats, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) *bbrSender {
+	b := &bbrSender{
+		clock:                   clock,
+		rttStats:                rttStats,
+		initialCongestionWindow: protocol.ByteCount(initialCongestionWindow) * protocol.DefaultTCPMSS,
+		maxCongestionWindow:     protocol.ByteCount(initialMaxCongestionWindow) * protocol.DefaultTCPMSS,
+	}
+	b.reset()
+	return b
+}
+
+func (b *bbrSender) reset() {
+	b.sampler = newBandwidthSampler()
+	b.maxBandwidth = newWindowedMaxBandwidth(bbrBandwidthWindowSize)
+	b.roundTripCount = 0
+	b.currentRoundTripEnd = 0
+	b.minRTT = 0
+	b.minRTTTimestamp = time.Time{}
+	b.congestionWindow = b.initialCongestionWindow
+	b.pacingRate = 0
+	b.isAtFullBandwidth = false
+	b.roundsWithoutBandwidthGain = 0
+	b.bandwidthAtLastRound = 0
+	b.exitProbeRTTAt = time.Time{}
+	b.probeRTTRoundPassed = false
+	b.recoveryState = bbrNotInRecovery
+	b.recoveryWindow = 0
+	b.endRecoveryAt = 0
+	b.enterStartupMode()
+}
+
+// TimeUntilSend returns the time between two packets, according to the pacing rate
+func (b *bbrSender) TimeUntilSend(bytesInFlight protocol.ByteCount) time.Duration {
+	rate := b.PacingRate()
+	if rate == 0 {
+		return 0
+	}
+	return time.Duration(uint64(protocol.DefaultTCPMSS) * uint64(BytesPerSecond) * uint64(time.Second) / uint64(rate))
+}
+
+func (b *bbrSender) OnPacketSent(sentTime time.Time, bytesInFlight protocol.ByteCount, packetNumber protocol.PacketNumber, bytes protocol.ByteCount, isRetransmittable bool) bool {
+	b.lastSentPacket = packetNumber
+	if !isRetransmittable {
+		return false
+	}
+	// bytesInFlight already includes this packet
+	var priorInFlight protocol.ByteCount
+	if bytesInFlight > bytes {
+		priorInFlight = bytesInFlight - bytes
+	}
+	b.sampler.OnPacketSent(sentTime, packetNumber, bytes, priorInFlight)
+	return true
+}
+
+// GetCongestionWindow returns the congestion window in bytes
+func (b *bbrSender) GetCongestionWindow() protocol.ByteCount {
+	if b.mode == bbrModeProbeRTT {
+		return bbrMinCongestionWindow
+	}
+	if b.recoveryState != bbrNotInRecovery {
+		return utils.MinByteCount(b.congestionWindow, b.recoveryWindow)
+	}
+	return b.congestionWindow
+}
+
+// MaybeExitSlowStart is a no-op. BBR decides when to leave STARTUP based on the bandwidth estimate.
+func (b *bbrSender) MaybeExitSlowStart() {}
+
+func (b *bbrSender) OnPacketAcked(number protocol.PacketNumber, ackedBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	now := b.clock.Now()
+	priorInFlight := bytesInFlight + ackedBytes
+
+	isRoundStart := b.updateRoundTripCounter(number)
+	b.updateRecoveryStateOnAck(number, isRoundStart)
+	minRTTExpired := b.updateBandwidthAndMinRTT(now, number)
+
+	if b.mode == bbrModeProbeBandwidth {
+		b.updateGainCyclePhase(now, priorInFlight)
+	}
+	if isRoundStart && !b.isAtFullBandwidth {
+		b.checkIfFullBandwidthReached()
+	}
+	b.maybeExitStartupOrDrain(now, bytesInFlight)
+	b.maybeEnterOrExitProbeRTT(now, isRoundStart, minRTTExpired, bytesInFlight)
+
+	b.calculatePacingRate()
+	b.calculateCongestionWindow(ackedBytes)
+	b.calculateRecoveryWindow(ackedBytes, 0, bytesInFlight)
+}
+
+// OnPacketLost removes the packet from the bandwidth sampler, and enters recovery.
+// BBR doesn't reduce its bandwidth estimate on packet loss.
+// In recovery, the congestion window is limited to the bytes in flight, until the losses are repaired.
+func (b *bbrSender) OnPacketLost(number protocol.PacketNumber, lostBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	b.sampler.OnPacketLost(number)
+	// ECN CE marks are reported without any lost bytes. BBR doesn't react to them.
+	if lostBytes == 0 {
+		return
+	}
+	b.endRecoveryAt = b.lastSentPacket
+	if b.recoveryState == bbrNotInRecovery {
+		utils.Debugf("BBR: entering recovery")
+		b.recoveryState = bbrConservation
+		b.recoveryWindow = 0
+		// The conservation phase lasts for one round trip, starting now.
+		b.currentRoundTripEnd = b.lastSentPacket
+	}
+	b.calculateRecoveryWindow(0, lostBytes, bytesInFlight)
+}
+
+// SetNumEmulatedConnections is a no-op for BBR
+func (b *bbrSender) SetNumEmulatedConnections(n int) {}
+
+// OnRetransmissionTimeout is a no-op for BBR. The packets are reported as lost to the sender.
+func (b *bbrSender) OnRetransmissionTimeout(packetsRetransmitted bool) {}
+
+// OnConnectionMigration resets the sender, since the path characteristics might have changed
+func (b *bbrSender) OnConnectionMigration() {
+	b.reset()
+}
+
+// RetransmissionDelay gives the time to retransmission
+func (b *bbrSender) RetransmissionDelay() time.Duration {
+	if b.rttStats.SmoothedRTT() == 0 {
+		return 0
+	}
+	return b.rttStats.SmoothedRTT() + b.rttStats.MeanDeviation()*4
+}
+
+// SetSlowStartLargeReduction is a no-op for BBR
+func (b *bbrSender) SetSlowStartLargeReduction(enabled bool) {}
+
+// BandwidthEstimate returns the current bandwidth estimate
+func (b *bbrSender) BandwidthEstimate() Bandwidth {
+	return b.maxBandwidth.GetBest()
+}
+
+// PacingRate returns the current pacing rate
+func (b *bbrSender) PacingRate() Bandwidth {
+	if b.pacingRate == 0 {
+		return Bandwidth(bbrHighGain * float64(BandwidthFromDelta(b.initialCongestionWindow, b.getMinRTT())))
+	}
+	return b.pacingRate
+}
+
+func (b *bbrSender) enterStartupMode() {
+	b.mode = bbrModeStartup
+	b.pacingGain = bbrHighGain
+	b.congestionWindowGain = bbrHighGain
+}
+
+func (b *bbrSender) enterProbeBandwidthMode(now time.Time) {
+	b.mode = bbrModeProbeBandwidth
+	b.congestionWindowGain = bbrCongestionWindowGain
+	// Pick a random offset for the gain cycle out of {0, 2..7} range.
+	// 1 is excluded because in that case increased gain and decreased gain would not follow each other.
+	b.cycleCurrentOffset = rand.Intn(len(bbrPacingGainCycle) - 1)
+	if b.cycleCurrentOffset >= 1 {
+		b.cycleCurrentOffset++
+	}
+	b.lastCycleStart = now
+	b.pacingGain = bbrPacingGainCycle[b.cycleCurrentOffset]
+}
+
+// updateRoundTripCounter returns true if a new round trip started
+func (b *bbrSender) updateRoundTripCounter(lastAckedPacket protocol.PacketNumber) bool {
+	if lastAckedPacket > b.currentRoundTripEnd {
+		b.roundTripCount++
+		b.currentRoundTripEnd = b.lastSentPacket
+		return true
+	}
+	return false
+}
+
+// updateBandwidthAndMinRTT returns true if the minimum RTT expired
+func (b *bbrSender) updateBandwidthAndMinRTT(now time.Time, pn protocol.PacketNumber) bool {
+	sample, ok := b.sampler.OnPacketAcked(now, pn)
+	if !ok {
+		return false
+	}
+	b.maxBandwidth.Update(sample.bandwidth, b.roundTripCount)
+
+	minRTTExpired := b.minRTT != 0 && now.After(b.minRTTTimestamp.Add(bbrMinRTTExpiry))
+	if minRTTExpired || sample.rtt < b.minRTT || b.minRTT == 0 {
+		utils.Debugf("BBR: min RTT updated: %s (expired: %t)", sample.rtt, minRTTExpired)
+		b.minRTT = sample.rtt
+		b.minRTTTimestamp = now
+	}
+	return minRTTExpired
+}
+
+func (b *bbrSender) updateRecoveryStateOnAck(lastAckedPacket protocol.PacketNumber, isRoundStart bool) {
+	switch b.recoveryState {
+	case bbrConservation:
+		if isRoundStart {
+			b.recoveryState = bbrGrowth
+		}
+		fallthrough
+	case bbrGrowth:
+		if lastAckedPacket > b.endRecoveryAt {
+			utils.Debugf("BBR: exiting recovery")
+			b.recoveryState = bbrNotInRecovery
+		}
+	}
+}
+
+func (b *bbrSender) updateGainCyclePhase(now time.Time, priorInFlight protocol.ByteCount) {
+	// In most cases, the cycle is advanced after an RTT passes.
+	shouldAdvanceGainCycling := now.Sub(b.lastCycleStart) > b.getMinRTT()
+	// If the pacing gain is above 1.0, the connection is trying to probe the bandwidth by increasing the number of bytes in flight to at least
+	// pacingGain * BDP. Make sure that it actually reaches the target.
+	if b.pacingGain > 1 && priorInFlight < b.getTargetCongestionWindow(b.pacingGain) {
+		shouldAdvanceGainCycling = false
+	}
+	// If pacing gain is below 1.0, the connection is trying to drain the extra queue which could have been incurred by probing prior to it.
+	// If the number of bytes in flight falls down to the estimated BDP value earlier, conclude that the queue has been successfully drained
+	// and exit this cycle early.
+	if b.pacingGain < 1 && priorInFlight <= b.getTargetCongestionWindow(1) {
+		shouldAdvanceGainCycling = true
+	}
+	if shouldAdvanceGainCycling {
+		b.cycleCurrentOffset = (b.cycleCurrentOffset + 1) % len(bbrPacingGainCycle)
+		b.lastCycleStart = now
+		b.pacingGain = bbrPacingGainCycle[b.cycleCurrentOffset]
+	}
+}
+
+func (b *bbrSender) checkIfFullBandwidthReached() {
+	target := Bandwidth(float64(b.bandwidthAtLastRound) * bbrStartupGrowthTarget)
+	if bw := b.BandwidthEstimate(); bw >= target {
+		b.bandwidthAtLastRound = bw
+		b.roundsWithoutBandwidthGain = 0
+		return
+	}
+	b.roundsWithoutBandwidthGain++
+	if b.roundsWithoutBandwidthGain >= bbrRoundTripsWithoutGrowthBeforeExitingStartup {
+		b.isAtFullBandwidth = true
+	}
+}
+
+func (b *bbrSender) maybeExitStartupOrDrain(now time.Time, bytesInFlight protocol.ByteCount) {
+	if b.mode == bbrModeStartup && b.isAtFullBandwidth {
+		utils.Debugf("BBR: exiting %s", b.mode)
+		b.mode = bbrModeDrain
+		b.pacingGain = bbrDrainGain
+		b.congestionWindowGain = bbrHighGain
+	}
+	if b.mode == bbrModeDrain && bytesInFlight <= b.getTargetCongestionWindow(1) {
+		utils.Debugf("BBR: exiting %s", b.mode)
+		b.enterProbeBandwidthMode(now)
+	}
+}
+
+func (b *bbrSender) maybeEnterOrExitProbeRTT(now time.Time, isRoundStart, minRTTExpired bool, bytesInFlight protocol.ByteCount) {
+	if minRTTExpired && b.mode != bbrModeProbeRTT {
+		utils.Debugf("BBR: entering %s", bbrModeProbeRTT)
+		b.mode = bbrModeProbeRTT
+		b.pacingGain = 1
+		// Do not decide on the time to exit PROBE_RTT until the bytes in flight are at the target small value.
+		b.exitProbeRTTAt = time.Time{}
+	}
+	if b.mode != bbrModeProbeRTT {
+		return
+	}
+	if b.exitProbeRTTAt.IsZero() {
+		// If the window has reached the appropriate size, schedule exiting PROBE_RTT.
+		if bytesInFlight < bbrMinCongestionWindow+protocol.InitialPacketSize {
+			b.exitProbeRTTAt = now.Add(bbrProbeRTTTime)
+			b.probeRTTRoundPassed = false
+		}
+		return
+	}
+	if isRoundStart {
+		b.probeRTTRoundPassed = true
+	}
+	if !now.Before(b.exitProbeRTTAt) && b.probeRTTRoundPassed {
+		utils.Debugf("BBR: exiting %s", bbrModeProbeRTT)
+		b.minRTTTimestamp = now
+		if !b.isAtFullBandwidth {
+			b.enterStartupMode()
+		} else {
+			b.enterProbeBandwidthMode(now)
+		}
+	}
+}
+
+func (b *bbrSender) calculatePacingRate() {
+	bw := b.BandwidthEstimate()
+	if bw == 0 {
+		return
+	}
+	targetRate := Bandwidth(b.pacingGain * float64(bw))
+	if b.isAtFullBandwidth {
+		b.pacingRate = targetRate
+		return
+	}
+	// Pace at the rate of initialCongestionWindow / RTT as soon as the RTT measurements are available.
+	if b.pacingRate == 0 && b.rttStats.MinRTT() != 0 {
+		b.pacingRate = BandwidthFromDelta(b.initialCongestionWindow, b.rttStats.MinRTT())
+		return
+	}
+	// Do not decrease the pacing rate during STARTUP.
+	if targetRate > b.pacingRate {
+		b.pacingRate = targetRate
+	}
+}
+
+func (b *bbrSender) calculateCongestionWindow(bytesAcked protocol.ByteCount) {
+	if b.mode == bbrModeProbeRTT {
+		return
+	}
+	targetWindow := b.getTargetCongestionWindow(b.congestionWindowGain)
+	if b.isAtFullBandwidth {
+		// If the pipe is full, move the congestion window towards the target by the number of bytes acknowledged.
+		b.congestionWindow = utils.MinByteCount(targetWindow, b.congestionWindow+bytesAcked)
+	} else if b.congestionWindow < targetWindow || b.sampler.TotalBytesAcked() < b.initialCongestionWindow {
+		// If the connection is not yet out of STARTUP, the congestion window doesn't decrease.
+		b.congestionWindow += bytesAcked
+	}
+	b.congestionWindow = utils.MaxByteCount(b.congestionWindow, bbrMinCongestionWindow)
+	b.congestionWindow = utils.MinByteCount(b.congestionWindow, b.maxCongestionWindow)
+}
+
+func (b *bbrSender) calculateRecoveryWindow(bytesAcked, bytesLost, bytesInFlight protocol.ByteCount) {
+	if b.recoveryState == bbrNotInRecovery {
+		return
+	}
+	// Start with the bytes in flight when entering recovery.
+	if b.recoveryWindow == 0 {
+		b.recoveryWindow = utils.MaxByteCount(bytesInFlight+bytesAcked, bbrMinCongestionWindow)
+		return
+	}
+	if b.recoveryWindow >= bytesLost {
+		b.recoveryWindow -= bytesLost
+	} else {
+		b.recoveryWindow = protocol.DefaultTCPMSS
+	}
+	if b.recoveryState == bbrGrowth {
+		b.recoveryWindow += bytesAcked
+	}
+	// Always allow sending at least as many bytes as were acknowledged.
+	b.recoveryWindow = utils.MaxByteCount(b.recoveryWindow, bytesInFlight+bytesAcked)
+	b.recoveryWindow = utils.MaxByteCount(b.recoveryWindow, bbrMinCongestionWindow)
+}
+
+// getMinRTT returns the minimum RTT, or the initial RTT if no RTT sample was taken yet
+func (b *bbrSender) getMinRTT() time.Duration {
+	if b.minRTT != 0 {
+		return b.minRTT
+	}
+	if initialRTT := time.Duration(b.rttStats.InitialRTTus()) * time.Microsecond; initialRTT != 0 {
+		return initialRTT
+	}
+	return bbrDefaultInitialRTT
+}
+
+// getTargetCongestionWindow returns the congestion window that corresponds to gain * BDP
+func (b *bbrSender) getTargetCongestionWindow(gain float64) protocol.ByteCount {
+	bdp := protocol.ByteCount(uint64(b.BandwidthEstimate()) * uint64(b.getMinRTT()) / uint64(time.Second) / uint64(BytesPerSecond))
+	congestionWindow := protocol.ByteCount(gain * float64(bdp))
+	// If the BDP is not known yet, use the initial congestion window.
+	if congestionWindow == 0 {
+		congestionWindow = protocol.ByteCount(gain * float64(b.initialCongestionWindow))
+	}
+	return utils.MaxByteCount(congestionWindow, bbrMinCongestionWindow)
+}
@@ -0,0 +1,260 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// A bbrSimulator simulates a connection over a link with a fixed bottleneck bandwidth and a fixed propagation delay.
+// The sender always has data to send.
+type bbrSimulator struct {
+	sender   *bbrSender
+	clock    *mockClock
+	rttStats *RTTStats
+
+	bandwidth Bandwidth
+	rtt       time.Duration
+
+	packetNumber  protocol.PacketNumber
+	bytesInFlight protocol.ByteCount
+	nextSendTime  time.Time
+	// the time the bottleneck link is free again
+	linkFreeAt time.Time
+	// packets in flight, ordered by the time they are acknowledged
+	inFlight []simulatedPacket
+
+	// the modes that the sender was in
+	modes []bbrMode
+}
+
+type simulatedPacket struct {
+	packetNumber protocol.PacketNumber
+	sentTime     time.Time
+	ackTime      time.Time
+}
+
+func (s *bbrSimulator) transmissionTime() time.Duration {
+	return time.Duration(uint64(protocol.DefaultTCPMSS) * uint64(BytesPerSecond) * uint64(time.Second) / uint64(s.bandwidth))
+}
+
+func (s *bbrSimulator) send() {
+	now := s.clock.Now()
+	departure := now
+	if s.linkFreeAt.After(departure) {
+		departure = s.linkFreeAt
+	}
+	departure = departure.Add(s.transmissionTime())
+	s.linkFreeAt = departure
+	s.packetNumber++
+	// the sentPacketHandler adds the packet to the bytes in flight before calling OnPacketSent
+	s.bytesInFlight += protocol.DefaultTCPMSS
+	s.sender.OnPacketSent(now, s.bytesInFlight, s.packetNumber, protocol.DefaultTCPMSS, true)
+	s.inFlight = append(s.inFlight, simulatedPacket{
+		packetNumber: s.packetNumber,
+		sentTime:     now,
+		ackTime:      departure.Add(s.rtt),
+	})
+	s.nextSendTime = now.Add(s.sender.TimeUntilSend(s.bytesInFlight))
+}
+
+func (s *bbrSimulator) ack() {
+	p := s.inFlight[0]
+	s.inFlight = s.inFlight[1:]
+	s.rttStats.UpdateRTT(s.clock.Now().Sub(p.sentTime), 0, s.clock.Now())
+	s.bytesInFlight -= protocol.DefaultTCPMSS
+	s.sender.OnPacketAcked(p.packetNumber, protocol.DefaultTCPMSS, s.bytesInFlight)
+	if len(s.modes) == 0 || s.modes[len(s.modes)-1] != s.sender.mode {
+		s.modes = append(s.modes, s.sender.mode)
+	}
+}
+
+// run simulates the connection for the given duration
+func (s *bbrSimulator) run(d time.Duration) {
+	end := s.clock.Now().Add(d)
+	for s.clock.Now().Before(end) {
+		canSend := s.bytesInFlight < s.sender.GetCongestionWindow()
+		if canSend && !s.nextSendTime.After(s.clock.Now()) {
+			s.send()
+			continue
+		}
+		next := end
+		if len(s.inFlight) > 0 && s.inFlight[0].ackTime.Before(next) {
+			next = s.inFlight[0].ackTime
+		}
+		if canSend && s.nextSendTime.Before(next) {
+			next = s.nextSendTime
+		}
+		if next.After(s.clock.Now()) {
+			s.clock.Advance(next.Sub(s.clock.Now()))
+		}
+		for len(s.inFlight) > 0 && !s.inFlight[0].ackTime.After(s.clock.Now()) {
+			s.ack()
+		}
+	}
+}
+
+var _ = Describe("BBR Sender", func() {
+	var (
+		sender   *bbrSender
+		clock    mockClock
+		rttStats *RTTStats
+	)
+
+	BeforeEach(func() {
+		clock = mockClock{}
+		rttStats = NewRTTStats()
+		sender = newBBRSender(&clock, rttStats, initialCongestionWindowPackets, MaxCongestionWindow)
+	})
+
+	newSimulator := func(bandwidth Bandwidth, rtt time.Duration) *bbrSimulator {
+		return &bbrSimulator{
+			sender:    sender,
+			clock:     &clock,
+			rttStats:  rttStats,
+			bandwidth: bandwidth,
+			rtt:       rtt,
+		}
+	}
+
+	It("starts in STARTUP", func() {
+		Expect(sender.mode).To(Equal(bbrModeStartup))
+		Expect(sender.GetCongestionWindow()).To(Equal(protocol.ByteCount(initialCongestionWindowPackets) * protocol.DefaultTCPMSS))
+		Expect(sender.BandwidthEstimate()).To(BeZero())
+	})
+
+	It("paces at a high rate before a bandwidth estimate is available", func() {
+		rate := Bandwidth(bbrHighGain * float64(BandwidthFromDelta(protocol.ByteCount(initialCongestionWindowPackets)*protocol.DefaultTCPMSS, 100*time.Millisecond)))
+		Expect(sender.PacingRate()).To(Equal(rate))
+		// the initial congestion window is sent within 1/bbrHighGain of the initial RTT
+		expected := float64(100*time.Millisecond) / bbrHighGain / float64(initialCongestionWindowPackets)
+		Expect(sender.TimeUntilSend(0)).To(BeNumerically("~", expected, float64(time.Microsecond)))
+	})
+
+	It("has a string representation for the modes", func() {
+		Expect(bbrModeStartup.String()).To(Equal("STARTUP"))
+		Expect(bbrModeDrain.String()).To(Equal("DRAIN"))
+		Expect(bbrModeProbeBandwidth.String()).To(Equal("PROBE_BW"))
+		Expect(bbrModeProbeRTT.String()).To(Equal("PROBE_RTT"))
+		Expect(bbrMode(42).String()).To(Equal("unknown mode"))
+	})
+
+	It("estimates the bandwidth and the minimum RTT, and reaches PROBE_BW", func() {
+		bandwidth := 10 * 1000 * 1000 * BitsPerSecond
+		sim := newSimulator(bandwidth, 50*time.Millisecond)
+		sim.run(5 * time.Second)
+		Expect(sim.modes[:3]).To(Equal([]bbrMode{bbrModeStartup, bbrModeDrain, bbrModeProbeBandwidth}))
+		Expect(sender.mode).To(Equal(bbrModeProbeBandwidth))
+		Expect(sender.BandwidthEstimate()).To(BeNumerically("~", bandwidth, bandwidth/20))
+		Expect(sender.minRTT).To(BeNumerically("~", 50*time.Millisecond, 5*time.Millisecond))
+		// in PROBE_BW, the congestion window is twice the BDP
+		bdp := protocol.ByteCount(uint64(bandwidth) * uint64(50*time.Millisecond) / uint64(time.Second) / uint64(BytesPerSecond))
+		Expect(sender.GetCongestionWindow()).To(BeNumerically("~", 2*bdp, bdp/5))
+	})
+
+	It("doesn't build a large queue", func() {
+		sim := newSimulator(10*1000*1000*BitsPerSecond, 50*time.Millisecond)
+		sim.run(5 * time.Second)
+		// after STARTUP and DRAIN, the smoothed RTT should be close to the minimum RTT
+		Expect(rttStats.LatestRTT()).To(BeNumerically("<", 75*time.Millisecond))
+	})
+
+	It("enters PROBE_RTT when the minimum RTT expires", func() {
+		sim := newSimulator(10*1000*1000*BitsPerSecond, 50*time.Millisecond)
+		sim.run(bbrMinRTTExpiry + 5*time.Second)
+		Expect(sim.modes).To(ContainElement(bbrModeProbeRTT))
+		Expect(sender.mode).To(Equal(bbrModeProbeBandwidth))
+	})
+
+	It("uses the minimum congestion window in PROBE_RTT", func() {
+		sender.mode = bbrModeProbeRTT
+		Expect(sender.GetCongestionWindow()).To(Equal(bbrMinCongestionWindow))
+	})
+
+	Context("recovery", func() {
+		// loseFirst declares the first packet in flight lost
+		loseFirst := func(sim *bbrSimulator, lostBytes protocol.ByteCount) {
+			p := sim.inFlight[0]
+			sim.inFlight = sim.inFlight[1:]
+			sim.bytesInFlight -= protocol.DefaultTCPMSS
+			sender.OnPacketLost(p.packetNumber, lostBytes, sim.bytesInFlight)
+		}
+
+		It("limits the congestion window to the bytes in flight on packet loss", func() {
+			sim := newSimulator(10*1000*1000*BitsPerSecond, 50*time.Millisecond)
+			sim.run(2 * time.Second)
+			cwnd := sender.GetCongestionWindow()
+			bw := sender.BandwidthEstimate()
+			loseFirst(sim, protocol.DefaultTCPMSS)
+			Expect(sender.recoveryState).To(Equal(bbrConservation))
+			Expect(sender.GetCongestionWindow()).To(Equal(utils.MaxByteCount(sim.bytesInFlight, bbrMinCongestionWindow)))
+			Expect(sender.GetCongestionWindow()).To(BeNumerically("<", cwnd))
+			Expect(sender.BandwidthEstimate()).To(Equal(bw))
+			sender.OnRetransmissionTimeout(true)
+			Expect(sender.recoveryState).To(Equal(bbrConservation))
+		})
+
+		It("exits recovery when packets sent after the loss are acknowledged", func() {
+			sim := newSimulator(10*1000*1000*BitsPerSecond, 50*time.Millisecond)
+			sim.run(2 * time.Second)
+			bw := sender.BandwidthEstimate()
+			loseFirst(sim, protocol.DefaultTCPMSS)
+			sim.run(time.Second)
+			Expect(sender.recoveryState).To(Equal(bbrNotInRecovery))
+			Expect(sender.GetCongestionWindow()).To(Equal(sender.congestionWindow))
+			Expect(sender.BandwidthEstimate()).To(BeNumerically("~", bw, bw/20))
+		})
+
+		It("only releases the acknowledged bytes in CONSERVATION", func() {
+			sender.recoveryState = bbrConservation
+			sender.recoveryWindow = 10 * protocol.DefaultTCPMSS
+			sender.calculateRecoveryWindow(protocol.DefaultTCPMSS, 0, 9*protocol.DefaultTCPMSS)
+			Expect(sender.recoveryWindow).To(Equal(10 * protocol.DefaultTCPMSS))
+			sender.calculateRecoveryWindow(0, 2*protocol.DefaultTCPMSS, 5*protocol.DefaultTCPMSS)
+			Expect(sender.recoveryWindow).To(Equal(8 * protocol.DefaultTCPMSS))
+		})
+
+		It("grows the recovery window in GROWTH", func() {
+			sender.recoveryState = bbrGrowth
+			sender.recoveryWindow = 10 * protocol.DefaultTCPMSS
+			sender.calculateRecoveryWindow(protocol.DefaultTCPMSS, 0, 9*protocol.DefaultTCPMSS)
+			Expect(sender.recoveryWindow).To(Equal(11 * protocol.DefaultTCPMSS))
+		})
+
+		It("doesn't enter recovery for ECN CE marks", func() {
+			sim := newSimulator(10*1000*1000*BitsPerSecond, 50*time.Millisecond)
+			sim.run(2 * time.Second)
+			cwnd := sender.GetCongestionWindow()
+			sender.OnPacketLost(sim.inFlight[0].packetNumber, 0, sim.bytesInFlight)
+			Expect(sender.recoveryState).To(Equal(bbrNotInRecovery))
+			Expect(sender.GetCongestionWindow()).To(Equal(cwnd))
+		})
+	})
+
+	It("resets on connection migration", func() {
+		sim := newSimulator(10*1000*1000*BitsPerSecond, 50*time.Millisecond)
+		sim.run(2 * time.Second)
+		Expect(sender.mode).ToNot(Equal(bbrModeStartup))
+		sender.OnPacketLost(sim.inFlight[0].packetNumber, protocol.DefaultTCPMSS, sim.bytesInFlight)
+		sender.OnConnectionMigration()
+		Expect(sender.mode).To(Equal(bbrModeStartup))
+		Expect(sender.recoveryState).To(Equal(bbrNotInRecovery))
+		Expect(sender.BandwidthEstimate()).To(BeZero())
+		Expect(sender.GetCongestionWindow()).To(Equal(protocol.ByteCount(initialCongestionWindowPackets) * protocol.DefaultTCPMSS))
+	})
+
+	It("passes the bytes in flight before sending the packet to the bandwidth sampler", func() {
+		sender.OnPacketSent(clock.Now(), protocol.DefaultTCPMSS, 1, protocol.DefaultTCPMSS, true)
+		// the sampler resets its state when a packet is sent while no other packets are in flight
+		Expect(sender.sampler.lastAckedPacketSentTime).To(Equal(clock.Now()))
+	})
+
+	It("calculates the retransmission delay", func() {
+		Expect(sender.RetransmissionDelay()).To(BeZero())
+		rttStats.UpdateRTT(100*time.Millisecond, 0, clock.Now())
+		Expect(sender.RetransmissionDelay()).To(Equal(100*time.Millisecond + 4*50*time.Millisecond))
+	})
+})
@@ -0,0 +1,214 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// This is a delay-based "scavenger" congestion controller, based on LEDBAT (RFC 6817).
+// It uses the difference between the latest RTT and the minimum RTT as an estimate of the queueing delay,
+// and yields to other traffic as soon as it detects a growing queue.
+// Instead of LEDBAT's additive decrease, it uses the multiplicative decrease proposed for LEDBAT++,
+// which makes it back off more quickly when competing with loss-based congestion controllers.
+
+const (
+	// The queueing delay that the controller aims for.
+	ledbatTarget = 25 * time.Millisecond
+	// The number of RTT samples that are used to filter the current delay.
+	ledbatCurrentFilter = 4
+	// The maximum multiplicative decrease per acknowledged byte, when the queueing delay is above the target.
+	ledbatMaxDecrease = 0.5
+	// The number of packets the congestion window may exceed the bytes in flight by.
+	ledbatAllowedIncrease = 2
+	// The minimum congestion window.
+	ledbatMinCongestionWindow = 2 * protocol.DefaultTCPMSS
+)
+
+type ledbatSender struct {
+	rttStats *RTTStats
+
+	congestionWindow        protocol.ByteCount
+	initialCongestionWindow protocol.ByteCount
+	maxCongestionWindow     protocol.ByteCount
+
+	// LEDBAT starts in slow start, and exits it as soon as a queue builds up, or a packet is lost.
+	inSlowStart bool
+
+	// the most recent RTT samples, used to filter out noise
+	currentDelays     [ledbatCurrentFilter]time.Duration
+	currentDelayIndex int
+
+	largestSentPacketNumber protocol.PacketNumber
+	// the largest packet number sent when the congestion window was last reduced due to loss
+	largestSentAtLastCutback protocol.PacketNumber
+}
+
+var _ SendAlgorithm = &ledbatSender{}
+
+// NewLEDBATSender makes a new LEDBAT sender
+func NewLEDBATSender(rttStats *RTTStats, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) SendAlgorithm {
+	return newLEDBATSender(rttStats, initialCongestionWindow, initialMaxCongestionWindow)
+}
+
+func newLEDBATSender(rttStats *RTTStats, initialCongestionWindow, initialMaxCongestionWindow protocol.PacketNumber) *ledbatSender {
+	l := &ledbatSender{
+		rttStats:                rttStats,
+		initialCongestionWindow: protocol.ByteCount(initialCongestionWindow) * protocol.DefaultTCPMSS,
+		maxCongestionWindow:     protocol.ByteCount(initialMaxCongestionWindow) * protocol.DefaultTCPMSS,
+	}
+	l.reset()
+	return l
+}
+
+func (l *ledbatSender) reset() {
+	l.congestionWindow = l.initialCongestionWindow
+	l.inSlowStart = true
+	l.currentDelays = [ledbatCurrentFilter]time.Duration{}
+	l.currentDelayIndex = 0
+	l.largestSentPacketNumber = 0
+	l.largestSentAtLastCutback = 0
+}
+
+// TimeUntilSend returns the time between two packets, pacing the congestion window over one RTT
+func (l *ledbatSender) TimeUntilSend(bytesInFlight protocol.ByteCount) time.Duration {
+	// pace at 1.25 * cwnd / RTT
+	return l.rttStats.SmoothedRTT() * time.Duration(protocol.DefaultTCPMSS) * 4 / time.Duration(5*l.congestionWindow)
+}
+
+func (l *ledbatSender) OnPacketSent(sentTime time.Time, bytesInFlight protocol.ByteCount, packetNumber protocol.PacketNumber, bytes protocol.ByteCount, isRetransmittable bool) bool {
+	if !isRetransmittable {
+		return false
+	}
+	l.largestSentPacketNumber = packetNumber
+	return true
+}
+
+// GetCongestionWindow returns the congestion window in bytes
+func (l *ledbatSender) GetCongestionWindow() protocol.ByteCount {
+	return l.congestionWindow
+}
+
+// MaybeExitSlowStart is called when a new RTT sample was taken.
+// The sample is recorded for the queueing delay estimate.
+// Slow start is exited based on the queueing delay when a packet is acknowledged.
+func (l *ledbatSender) MaybeExitSlowStart() {
+	latestRTT := l.rttStats.LatestRTT()
+	if latestRTT == 0 {
+		return
+	}
+	l.currentDelays[l.currentDelayIndex] = latestRTT
+	l.currentDelayIndex = (l.currentDelayIndex + 1) % ledbatCurrentFilter
+}
+
+func (l *ledbatSender) OnPacketAcked(number protocol.PacketNumber, ackedBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	queueingDelay, ok := l.queueingDelay()
+	if !ok {
+		// no RTT sample yet
+		return
+	}
+	// offTarget is positive if the queueing delay is below the target, and negative if it is above
+	offTarget := float64(ledbatTarget-queueingDelay) / float64(ledbatTarget)
+
+	if l.inSlowStart {
+		if queueingDelay < ledbatTarget*3/4 {
+			l.congestionWindow += ackedBytes
+			l.limitCongestionWindow(bytesInFlight + ackedBytes)
+			return
+		}
+		utils.Debugf("LEDBAT: exiting slow start, queueing delay: %s", queueingDelay)
+		l.inSlowStart = false
+	}
+
+	if offTarget >= 0 {
+		// additive increase, by at most one packet per RTT
+		l.congestionWindow += protocol.ByteCount(offTarget * float64(ackedBytes) * float64(protocol.DefaultTCPMSS) / float64(l.congestionWindow))
+	} else {
+		// multiplicative decrease, by at most half the congestion window per RTT
+		decrease := -offTarget
+		if decrease > ledbatMaxDecrease {
+			decrease = ledbatMaxDecrease
+		}
+		reduction := protocol.ByteCount(decrease * float64(ackedBytes))
+		if reduction >= l.congestionWindow {
+			l.congestionWindow = 0
+		} else {
+			l.congestionWindow -= reduction
+		}
+	}
+	l.limitCongestionWindow(bytesInFlight + ackedBytes)
+}
+
+// limitCongestionWindow makes sure that the congestion window doesn't grow beyond what the connection actually uses,
+// and that it stays between the minimum and the maximum congestion window
+func (l *ledbatSender) limitCongestionWindow(flightSize protocol.ByteCount) {
+	maxAllowed := flightSize + ledbatAllowedIncrease*protocol.DefaultTCPMSS
+	if maxAllowed < l.initialCongestionWindow {
+		maxAllowed = l.initialCongestionWindow
+	}
+	l.congestionWindow = utils.MinByteCount(l.congestionWindow, maxAllowed)
+	l.congestionWindow = utils.MinByteCount(l.congestionWindow, l.maxCongestionWindow)
+	l.congestionWindow = utils.MaxByteCount(l.congestionWindow, ledbatMinCongestionWindow)
+}
+
+// queueingDelay returns the estimated queueing delay
+func (l *ledbatSender) queueingDelay() (time.Duration, bool) {
+	baseDelay := l.rttStats.MinRTT()
+	if baseDelay == 0 {
+		return 0, false
+	}
+	// use the minimum of the most recent samples, in order to filter out noise
+	var currentDelay time.Duration
+	for _, d := range l.currentDelays {
+		if d != 0 && (currentDelay == 0 || d < currentDelay) {
+			currentDelay = d
+		}
+	}
+	if currentDelay == 0 {
+		return 0, false
+	}
+	if currentDelay < baseDelay {
+		return 0, true
+	}
+	return currentDelay - baseDelay, true
+}
+
+// OnPacketLost halves the congestion window, at most once per RTT
+func (l *ledbatSender) OnPacketLost(number protocol.PacketNumber, lostBytes protocol.ByteCount, bytesInFlight protocol.ByteCount) {
+	l.inSlowStart = false
+	if number <= l.largestSentAtLastCutback {
+		return
+	}
+	l.largestSentAtLastCutback = l.largestSentPacketNumber
+	l.congestionWindow = utils.MaxByteCount(l.congestionWindow/2, ledbatMinCongestionWindow)
+}
+
+// SetNumEmulatedConnections is a no-op for LEDBAT
+func (l *ledbatSender) SetNumEmulatedConnections(n int) {}
+
+// OnRetransmissionTimeout collapses the congestion window
+func (l *ledbatSender) OnRetransmissionTimeout(packetsRetransmitted bool) {
+	l.largestSentAtLastCutback = 0
+	if !packetsRetransmitted {
+		return
+	}
+	l.inSlowStart = false
+	l.congestionWindow = ledbatMinCongestionWindow
+}
+
+// OnConnectionMigration resets the sender, since the path characteristics might have changed
+func (l *ledbatSender) OnConnectionMigration() {
+	l.reset()
+}
+
+// RetransmissionDelay gives the time to retransmission
+func (l *ledbatSender) RetransmissionDelay() time.Duration {
+	if l.rttStats.SmoothedRTT() == 0 {
+		return 0
+	}
+	return l.rttStats.SmoothedRTT() + l.rttStats.MeanDeviation()*4
+}
+
+// SetSlowStartLargeReduction is a no-op for LEDBAT
+func (l *ledbatSender) SetSlowStartLargeReduction(enabled bool) {}
@@ -0,0 +1,221 @@
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LEDBAT Sender", func() {
+	const baseRTT = 100 * time.Millisecond
+
+	var (
+		sender        *ledbatSender
+		rttStats      *RTTStats
+		packetNumber  protocol.PacketNumber
+		bytesInFlight protocol.ByteCount
+	)
+
+	initialCwnd := protocol.ByteCount(initialCongestionWindowPackets) * protocol.DefaultTCPMSS
+
+	BeforeEach(func() {
+		rttStats = NewRTTStats()
+		sender = newLEDBATSender(rttStats, initialCongestionWindowPackets, MaxCongestionWindow)
+		packetNumber = 0
+		bytesInFlight = 0
+	})
+
+	// fillWindow sends packets until the congestion window is full
+	fillWindow := func() {
+		for bytesInFlight < sender.GetCongestionWindow() {
+			packetNumber++
+			bytesInFlight += protocol.DefaultTCPMSS
+			sender.OnPacketSent(time.Now(), bytesInFlight, packetNumber, protocol.DefaultTCPMSS, true)
+		}
+	}
+
+	// ackWithRTT acknowledges n packets, taking an RTT sample for every packet
+	ackWithRTT := func(n int, rtt time.Duration) {
+		for i := 0; i < n; i++ {
+			rttStats.UpdateRTT(rtt, 0, time.Now())
+			sender.MaybeExitSlowStart()
+			bytesInFlight -= protocol.DefaultTCPMSS
+			sender.OnPacketAcked(packetNumber, protocol.DefaultTCPMSS, bytesInFlight)
+			fillWindow()
+		}
+	}
+
+	// ackWindow acknowledges one congestion window worth of packets
+	ackWindow := func(rtt time.Duration) {
+		ackWithRTT(int(sender.GetCongestionWindow()/protocol.DefaultTCPMSS), rtt)
+	}
+
+	It("starts in slow start, with the initial congestion window", func() {
+		Expect(sender.inSlowStart).To(BeTrue())
+		Expect(sender.GetCongestionWindow()).To(Equal(initialCwnd))
+	})
+
+	It("doesn't change the congestion window before an RTT sample is available", func() {
+		fillWindow()
+		bytesInFlight -= protocol.DefaultTCPMSS
+		sender.OnPacketAcked(1, protocol.DefaultTCPMSS, bytesInFlight)
+		Expect(sender.GetCongestionWindow()).To(Equal(initialCwnd))
+	})
+
+	It("grows exponentially in slow start, as long as there's no queue", func() {
+		fillWindow()
+		ackWindow(baseRTT)
+		Expect(sender.inSlowStart).To(BeTrue())
+		Expect(sender.GetCongestionWindow()).To(Equal(2 * initialCwnd))
+	})
+
+	It("exits slow start when the queueing delay rises", func() {
+		fillWindow()
+		ackWithRTT(1, baseRTT)
+		ackWithRTT(ledbatCurrentFilter, baseRTT+ledbatTarget)
+		Expect(sender.inSlowStart).To(BeFalse())
+	})
+
+	It("filters out single delay spikes", func() {
+		fillWindow()
+		ackWithRTT(1, baseRTT)
+		ackWithRTT(ledbatCurrentFilter-1, baseRTT+2*ledbatTarget)
+		Expect(sender.inSlowStart).To(BeTrue())
+	})
+
+	It("only adds one sample to the filter per RTT sample", func() {
+		fillWindow()
+		ackWithRTT(1, baseRTT)
+		// an ACK frame that acknowledges many packets yields a single RTT sample
+		rttStats.UpdateRTT(baseRTT+2*ledbatTarget, 0, time.Now())
+		sender.MaybeExitSlowStart()
+		for i := 0; i < 2*ledbatCurrentFilter; i++ {
+			bytesInFlight -= protocol.DefaultTCPMSS
+			sender.OnPacketAcked(packetNumber, protocol.DefaultTCPMSS, bytesInFlight)
+		}
+		Expect(sender.inSlowStart).To(BeTrue())
+	})
+
+	Context("in congestion avoidance", func() {
+		BeforeEach(func() {
+			fillWindow()
+			ackWithRTT(1, baseRTT)
+			sender.inSlowStart = false
+		})
+
+		It("increases the congestion window by one packet per RTT if there's no queue", func() {
+			cwnd := sender.GetCongestionWindow()
+			ackWindow(baseRTT)
+			Expect(sender.GetCongestionWindow()).To(BeNumerically("~", cwnd+protocol.DefaultTCPMSS, protocol.DefaultTCPMSS/10))
+		})
+
+		It("increases the congestion window more slowly when the queueing delay approaches the target", func() {
+			ackWithRTT(ledbatCurrentFilter, baseRTT+ledbatTarget/2)
+			cwnd := sender.GetCongestionWindow()
+			ackWindow(baseRTT + ledbatTarget/2)
+			Expect(sender.GetCongestionWindow()).To(BeNumerically("~", cwnd+protocol.DefaultTCPMSS/2, protocol.DefaultTCPMSS/10))
+		})
+
+		It("doesn't change the congestion window when the queueing delay is at the target", func() {
+			ackWithRTT(ledbatCurrentFilter, baseRTT+ledbatTarget)
+			cwnd := sender.GetCongestionWindow()
+			ackWindow(baseRTT + ledbatTarget)
+			Expect(sender.GetCongestionWindow()).To(Equal(cwnd))
+		})
+
+		It("backs off when the queueing delay exceeds the target", func() {
+			ackWithRTT(ledbatCurrentFilter, baseRTT+ledbatTarget*5/4)
+			cwnd := sender.GetCongestionWindow()
+			ackWithRTT(4, baseRTT+ledbatTarget*5/4)
+			// the congestion window is reduced by 1/4 of the acknowledged bytes
+			Expect(sender.GetCongestionWindow()).To(Equal(cwnd - protocol.DefaultTCPMSS))
+		})
+
+		It("reduces the congestion window by at most half of the acknowledged bytes", func() {
+			ackWithRTT(ledbatCurrentFilter, baseRTT+10*ledbatTarget)
+			cwnd := sender.GetCongestionWindow()
+			ackWithRTT(4, baseRTT+10*ledbatTarget)
+			Expect(sender.GetCongestionWindow()).To(Equal(cwnd - 2*protocol.DefaultTCPMSS))
+		})
+
+		It("doesn't go below the minimum congestion window", func() {
+			for i := 0; i < 10; i++ {
+				ackWindow(baseRTT + 10*ledbatTarget)
+			}
+			Expect(sender.GetCongestionWindow()).To(Equal(ledbatMinCongestionWindow))
+		})
+
+		It("doesn't grow the congestion window if it's not used", func() {
+			for i := 0; i < 50; i++ {
+				rttStats.UpdateRTT(baseRTT, 0, time.Now())
+				sender.MaybeExitSlowStart()
+				sender.OnPacketAcked(packetNumber, protocol.DefaultTCPMSS, 0)
+			}
+			Expect(sender.GetCongestionWindow()).To(Equal(initialCwnd))
+		})
+	})
+
+	It("doesn't grow the congestion window beyond the maximum", func() {
+		sender = newLEDBATSender(rttStats, initialCongestionWindowPackets, 2*initialCongestionWindowPackets)
+		fillWindow()
+		for i := 0; i < 5; i++ {
+			ackWindow(baseRTT)
+		}
+		Expect(sender.GetCongestionWindow()).To(Equal(2 * initialCwnd))
+	})
+
+	It("halves the congestion window on packet loss, once per RTT", func() {
+		fillWindow()
+		ackWindow(baseRTT)
+		cwnd := sender.GetCongestionWindow()
+		sender.OnPacketLost(packetNumber-5, protocol.DefaultTCPMSS, bytesInFlight)
+		Expect(sender.inSlowStart).To(BeFalse())
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd / 2))
+		// another loss from the same window doesn't reduce the congestion window again
+		sender.OnPacketLost(packetNumber-4, protocol.DefaultTCPMSS, bytesInFlight)
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd / 2))
+		// a loss of a packet sent after the cutback does
+		packetNumber++
+		sender.OnPacketSent(time.Now(), bytesInFlight, packetNumber, protocol.DefaultTCPMSS, true)
+		sender.OnPacketLost(packetNumber, protocol.DefaultTCPMSS, bytesInFlight)
+		Expect(sender.GetCongestionWindow()).To(Equal(cwnd / 4))
+	})
+
+	It("collapses the congestion window on a retransmission timeout", func() {
+		fillWindow()
+		ackWindow(baseRTT)
+		sender.OnRetransmissionTimeout(true)
+		Expect(sender.inSlowStart).To(BeFalse())
+		Expect(sender.GetCongestionWindow()).To(Equal(ledbatMinCongestionWindow))
+	})
+
+	It("doesn't change the congestion window on a retransmission timeout, if no packets were retransmitted", func() {
+		sender.OnRetransmissionTimeout(false)
+		Expect(sender.GetCongestionWindow()).To(Equal(initialCwnd))
+	})
+
+	It("resets on connection migration", func() {
+		fillWindow()
+		ackWithRTT(1, baseRTT)
+		ackWithRTT(ledbatCurrentFilter, baseRTT+ledbatTarget)
+		Expect(sender.inSlowStart).To(BeFalse())
+		sender.OnConnectionMigration()
+		Expect(sender.inSlowStart).To(BeTrue())
+		Expect(sender.GetCongestionWindow()).To(Equal(initialCwnd))
+	})
+
+	It("paces packets over the RTT", func() {
+		rttStats.UpdateRTT(baseRTT, 0, time.Now())
+		// 1.25 * cwnd / RTT
+		expected := baseRTT * 4 / (5 * time.Duration(initialCongestionWindowPackets))
+		Expect(sender.TimeUntilSend(0)).To(Equal(expected))
+	})
+
+	It("calculates the retransmission delay", func() {
+		Expect(sender.RetransmissionDelay()).To(BeZero())
+		rttStats.UpdateRTT(baseRTT, 0, time.Now())
+		Expect(sender.RetransmissionDelay()).To(Equal(baseRTT + 4*baseRTT/2))
+	})
+})
@@ -0,0 +1,73 @@
+package congestion
+
+// windowedMaxBandwidth tracks the maximum bandwidth sample seen within a window.
+// The window is measured in round trips.
+// It implements Kathleen Nichols' algorithm for tracking the minimum (or maximum) estimate of a stream of samples
+// over some fixed time interval, by keeping the best, the second best and the third best sample.
+type windowedMaxBandwidth struct {
+	windowLength uint64
+	estimates    [3]bandwidthEstimate
+}
+
+type bandwidthEstimate struct {
+	bandwidth Bandwidth
+	round     uint64
+}
+
+func newWindowedMaxBandwidth(windowLength uint64) *windowedMaxBandwidth {
+	return &windowedMaxBandwidth{windowLength: windowLength}
+}
+
+// Update updates the estimates with a new sample
+func (f *windowedMaxBandwidth) Update(sample Bandwidth, round uint64) {
+	// Reset all estimates if they have not yet been initialized, if the sample is a new best,
+	// or if the newest recorded estimate is too old.
+	if f.estimates[0].bandwidth == 0 || sample >= f.estimates[0].bandwidth || round-f.estimates[2].round > f.windowLength {
+		f.Reset(sample, round)
+		return
+	}
+
+	newEstimate := bandwidthEstimate{bandwidth: sample, round: round}
+	if sample >= f.estimates[1].bandwidth {
+		f.estimates[1] = newEstimate
+		f.estimates[2] = newEstimate
+	} else if sample >= f.estimates[2].bandwidth {
+		f.estimates[2] = newEstimate
+	}
+
+	// Expire and update estimates as necessary.
+	if round-f.estimates[0].round > f.windowLength {
+		// The best estimate hasn't been updated for an entire window, so promote the second and third best estimates.
+		f.estimates[0] = f.estimates[1]
+		f.estimates[1] = f.estimates[2]
+		f.estimates[2] = newEstimate
+		// Need to iterate one more time.
+		// Check if the new best estimate is outside the window as well, since it may also have been recorded a long time ago.
+		if round-f.estimates[0].round > f.windowLength {
+			f.estimates[0] = f.estimates[1]
+			f.estimates[1] = f.estimates[2]
+		}
+		return
+	}
+	if f.estimates[1].bandwidth == f.estimates[0].bandwidth && round-f.estimates[1].round > f.windowLength/4 {
+		// A quarter of the window has passed without a better sample, so the second best estimate is taken from the second quarter of the window.
+		f.estimates[1] = newEstimate
+		f.estimates[2] = newEstimate
+		return
+	}
+	if f.estimates[2].bandwidth == f.estimates[1].bandwidth && round-f.estimates[2].round > f.windowLength/2 {
+		// We've passed a half of the window without a better estimate, so take a third best estimate from the second half of the window.
+		f.estimates[2] = newEstimate
+	}
+}
+
+// Reset resets all estimates to a new sample
+func (f *windowedMaxBandwidth) Reset(sample Bandwidth, round uint64) {
+	e := bandwidthEstimate{bandwidth: sample, round: round}
+	f.estimates = [3]bandwidthEstimate{e, e, e}
+}
+
+// GetBest returns the maximum bandwidth within the window
+func (f *windowedMaxBandwidth) GetBest() Bandwidth {
+	return f.estimates[0].bandwidth
+}
@@ -0,0 +1,50 @@
+package congestion
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Windowed max filter", func() {
+	var filter *windowedMaxBandwidth
+
+	BeforeEach(func() {
+		filter = newWindowedMaxBandwidth(10)
+	})
+
+	It("returns 0 before the first sample", func() {
+		Expect(filter.GetBest()).To(BeZero())
+	})
+
+	It("returns the maximum", func() {
+		filter.Update(100, 1)
+		Expect(filter.GetBest()).To(Equal(Bandwidth(100)))
+		filter.Update(200, 2)
+		Expect(filter.GetBest()).To(Equal(Bandwidth(200)))
+		filter.Update(150, 3)
+		Expect(filter.GetBest()).To(Equal(Bandwidth(200)))
+	})
+
+	It("expires the maximum after the window", func() {
+		filter.Update(1000, 1)
+		for i := uint64(2); i <= 11; i++ {
+			filter.Update(Bandwidth(100+i), i)
+			Expect(filter.GetBest()).To(Equal(Bandwidth(1000)))
+		}
+		filter.Update(50, 12)
+		Expect(filter.GetBest()).To(BeNumerically("<", 1000))
+		Expect(filter.GetBest()).To(BeNumerically(">=", 100))
+	})
+
+	It("resets when no sample was recorded for an entire window", func() {
+		filter.Update(1000, 1)
+		filter.Update(10, 20)
+		Expect(filter.GetBest()).To(Equal(Bandwidth(10)))
+	})
+
+	It("resets", func() {
+		filter.Update(1000, 1)
+		filter.Reset(42, 2)
+		Expect(filter.GetBest()).To(Equal(Bandwidth(42)))
+	})
+})
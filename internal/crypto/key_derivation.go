@@ -3,6 +3,7 @@ package crypto
 import (
 	"crypto"
 	"encoding/binary"
+	"fmt"
 
 	"github.com/bifurcation/mint"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
@@ -11,6 +12,7 @@ import (
 const (
 	clientExporterLabel = "EXPORTER-QUIC client 1rtt"
 	serverExporterLabel = "EXPORTER-QUIC server 1rtt"
+	earlyExporterLabel  = "EXPORTER-QUIC 0rtt"
 )
 
 // A TLSExporter gets the negotiated ciphersuite and computes exporter
@@ -29,8 +31,15 @@ func qhkdfExpand(secret []byte, label string, length int) []byte {
 	return mint.HkdfExpand(crypto.SHA256, secret, qlabel, length)
 }
 
-// DeriveAESKeys derives the AES keys and creates a matching AES-GCM AEAD instance
-func DeriveAESKeys(tls TLSExporter, pers protocol.Perspective) (AEAD, error) {
+// A KeyUpdater derives the AEADs used after 1-RTT key updates.
+// Every call to Next derives the keys of the next key phase.
+type KeyUpdater interface {
+	Next() (AEAD, error)
+}
+
+// DeriveAESKeys derives the AES keys and creates a matching AES-GCM AEAD instance.
+// The KeyUpdater derives the AEADs for the following key phases.
+func DeriveAESKeys(tls TLSExporter, pers protocol.Perspective) (AEAD, KeyUpdater, error) {
 	var myLabel, otherLabel string
 	if pers == protocol.PerspectiveClient {
 		myLabel = clientExporterLabel
@@ -39,14 +48,41 @@ func DeriveAESKeys(tls TLSExporter, pers protocol.Perspective) (AEAD, error) {
 		myLabel = serverExporterLabel
 		otherLabel = clientExporterLabel
 	}
-	myKey, myIV, err := computeKeyAndIV(tls, myLabel)
+	cs := tls.GetCipherSuite()
+	mySecret, err := tls.ComputeExporter(myLabel, nil, cs.Hash.Size())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	otherKey, otherIV, err := computeKeyAndIV(tls, otherLabel)
+	otherSecret, err := tls.ComputeExporter(otherLabel, nil, cs.Hash.Size())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	u := &aesKeyUpdater{cs: cs, mySecret: mySecret, otherSecret: otherSecret}
+	aead, err := u.aead()
+	if err != nil {
+		return nil, nil, err
 	}
+	return aead, u, nil
+}
+
+// The aesKeyUpdater derives the secrets of the next key phase from the current secrets.
+type aesKeyUpdater struct {
+	cs          mint.CipherSuiteParams
+	mySecret    []byte
+	otherSecret []byte
+}
+
+var _ KeyUpdater = &aesKeyUpdater{}
+
+func (u *aesKeyUpdater) Next() (AEAD, error) {
+	u.mySecret = qhkdfExpand(u.mySecret, "key update", len(u.mySecret))
+	u.otherSecret = qhkdfExpand(u.otherSecret, "key update", len(u.otherSecret))
+	return u.aead()
+}
+
+func (u *aesKeyUpdater) aead() (AEAD, error) {
+	myKey, myIV := computeKeyAndIVFromSecret(u.cs, u.mySecret)
+	otherKey, otherIV := computeKeyAndIVFromSecret(u.cs, u.otherSecret)
 	return NewAEADAESGCM(otherKey, myKey, otherIV, myIV)
 }
 
@@ -56,7 +92,57 @@ func computeKeyAndIV(tls TLSExporter, label string) (key, iv []byte, err error)
 	if err != nil {
 		return nil, nil, err
 	}
+	key, iv = computeKeyAndIVFromSecret(cs, secret)
+	return key, iv, nil
+}
+
+func computeKeyAndIVFromSecret(cs mint.CipherSuiteParams, secret []byte) (key, iv []byte) {
 	key = qhkdfExpand(secret, "key", cs.KeyLen)
 	iv = qhkdfExpand(secret, "iv", cs.IvLen)
-	return key, iv, nil
+	return key, iv
+}
+
+// DeriveEarlyAESKeys derives the AES keys used for 0-RTT packets and creates a matching AES-GCM AEAD instance.
+// The keys are derived from the pre-shared key used for resumption and the ClientHello that offered it.
+// 0-RTT packets are only sent by the client, so the same key is used in both directions.
+func DeriveEarlyAESKeys(psk *mint.PreSharedKey, clientHello []byte) (AEAD, error) {
+	cs, ok := earlyCipherSuites[psk.CipherSuite]
+	if !ok {
+		return nil, fmt.Errorf("unsupported cipher suite for 0-RTT: %#x", uint16(psk.CipherSuite))
+	}
+	key, iv, err := computeKeyAndIV(&earlyExporter{cs: cs, psk: psk.Key, clientHello: clientHello}, earlyExporterLabel)
+	if err != nil {
+		return nil, err
+	}
+	return NewAEADAESGCM(key, key, iv, iv)
+}
+
+var earlyCipherSuites = map[mint.CipherSuite]mint.CipherSuiteParams{
+	mint.TLS_AES_128_GCM_SHA256: {Suite: mint.TLS_AES_128_GCM_SHA256, Hash: crypto.SHA256, KeyLen: 16, IvLen: 12},
+	mint.TLS_AES_256_GCM_SHA384: {Suite: mint.TLS_AES_256_GCM_SHA384, Hash: crypto.SHA384, KeyLen: 32, IvLen: 12},
+}
+
+// The earlyExporter computes exporters from the early exporter master secret, as defined in TLS 1.3.
+type earlyExporter struct {
+	cs          mint.CipherSuiteParams
+	psk         []byte
+	clientHello []byte
+}
+
+var _ TLSExporter = &earlyExporter{}
+
+func (e *earlyExporter) GetCipherSuite() mint.CipherSuiteParams {
+	return e.cs
+}
+
+func (e *earlyExporter) ComputeExporter(label string, context []byte, keyLength int) ([]byte, error) {
+	hash := e.cs.Hash
+	earlySecret := mint.HkdfExtract(hash, make([]byte, hash.Size()), e.psk)
+	h := hash.New()
+	h.Write(e.clientHello)
+	exporterSecret := mint.HkdfExpandLabel(hash, earlySecret, "e exp master", h.Sum(nil), hash.Size())
+	tmpSecret := mint.HkdfExpandLabel(hash, exporterSecret, label, hash.New().Sum(nil), hash.Size())
+	h = hash.New()
+	h.Write(context)
+	return mint.HkdfExpandLabel(hash, tmpSecret, "exporter", h.Sum(nil), keyLength), nil
 }
@@ -36,9 +36,9 @@ func (c *mockTLSExporter) ComputeExporter(label string, context []byte, keyLengt
 
 var _ = Describe("Key Derivation", func() {
 	It("derives keys", func() {
-		clientAEAD, err := DeriveAESKeys(&mockTLSExporter{hash: crypto.SHA256}, protocol.PerspectiveClient)
+		clientAEAD, _, err := DeriveAESKeys(&mockTLSExporter{hash: crypto.SHA256}, protocol.PerspectiveClient)
 		Expect(err).ToNot(HaveOccurred())
-		serverAEAD, err := DeriveAESKeys(&mockTLSExporter{hash: crypto.SHA256}, protocol.PerspectiveServer)
+		serverAEAD, _, err := DeriveAESKeys(&mockTLSExporter{hash: crypto.SHA256}, protocol.PerspectiveServer)
 		Expect(err).ToNot(HaveOccurred())
 		ciphertext := clientAEAD.Seal(nil, []byte("foobar"), 0, []byte("aad"))
 		data, err := serverAEAD.Open(nil, ciphertext, 0, []byte("aad"))
@@ -46,9 +46,75 @@ var _ = Describe("Key Derivation", func() {
 		Expect(data).To(Equal([]byte("foobar")))
 	})
 
+	It("derives the keys of the next key phases", func() {
+		clientAEAD, clientUpdater, err := DeriveAESKeys(&mockTLSExporter{hash: crypto.SHA256}, protocol.PerspectiveClient)
+		Expect(err).ToNot(HaveOccurred())
+		serverAEAD, serverUpdater, err := DeriveAESKeys(&mockTLSExporter{hash: crypto.SHA256}, protocol.PerspectiveServer)
+		Expect(err).ToNot(HaveOccurred())
+		for i := 0; i < 3; i++ {
+			nextClientAEAD, err := clientUpdater.Next()
+			Expect(err).ToNot(HaveOccurred())
+			nextServerAEAD, err := serverUpdater.Next()
+			Expect(err).ToNot(HaveOccurred())
+			// the keys are updated in both directions
+			ciphertext := nextClientAEAD.Seal(nil, []byte("foobar"), 0, []byte("aad"))
+			_, err = serverAEAD.Open(nil, ciphertext, 0, []byte("aad"))
+			Expect(err).To(HaveOccurred())
+			data, err := nextServerAEAD.Open(nil, ciphertext, 0, []byte("aad"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foobar")))
+			ciphertext = nextServerAEAD.Seal(nil, []byte("raboof"), 0, []byte("aad"))
+			_, err = clientAEAD.Open(nil, ciphertext, 0, []byte("aad"))
+			Expect(err).To(HaveOccurred())
+			data, err = nextClientAEAD.Open(nil, ciphertext, 0, []byte("aad"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("raboof")))
+			clientAEAD = nextClientAEAD
+			serverAEAD = nextServerAEAD
+		}
+	})
+
 	It("fails when computing the exporter fails", func() {
 		testErr := errors.New("test error")
-		_, err := DeriveAESKeys(&mockTLSExporter{hash: crypto.SHA256, computerError: testErr}, protocol.PerspectiveClient)
+		_, _, err := DeriveAESKeys(&mockTLSExporter{hash: crypto.SHA256, computerError: testErr}, protocol.PerspectiveClient)
 		Expect(err).To(MatchError(testErr))
 	})
+
+	Context("0-RTT keys", func() {
+		psk := &mint.PreSharedKey{
+			CipherSuite: mint.TLS_AES_128_GCM_SHA256,
+			Key:         []byte("resumption secret"),
+		}
+
+		It("derives the same keys on both sides", func() {
+			clientAEAD, err := DeriveEarlyAESKeys(psk, []byte("ClientHello"))
+			Expect(err).ToNot(HaveOccurred())
+			serverAEAD, err := DeriveEarlyAESKeys(psk, []byte("ClientHello"))
+			Expect(err).ToNot(HaveOccurred())
+			ciphertext := clientAEAD.Seal(nil, []byte("foobar"), 0, []byte("aad"))
+			data, err := serverAEAD.Open(nil, ciphertext, 0, []byte("aad"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foobar")))
+		})
+
+		It("binds the keys to the ClientHello", func() {
+			clientAEAD, err := DeriveEarlyAESKeys(psk, []byte("ClientHello"))
+			Expect(err).ToNot(HaveOccurred())
+			serverAEAD, err := DeriveEarlyAESKeys(psk, []byte("another ClientHello"))
+			Expect(err).ToNot(HaveOccurred())
+			ciphertext := clientAEAD.Seal(nil, []byte("foobar"), 0, []byte("aad"))
+			_, err = serverAEAD.Open(nil, ciphertext, 0, []byte("aad"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("supports AES-256", func() {
+			_, err := DeriveEarlyAESKeys(&mint.PreSharedKey{CipherSuite: mint.TLS_AES_256_GCM_SHA384, Key: []byte("secret")}, []byte("ClientHello"))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("errors for unsupported cipher suites", func() {
+			_, err := DeriveEarlyAESKeys(&mint.PreSharedKey{CipherSuite: mint.TLS_CHACHA20_POLY1305_SHA256}, []byte("ClientHello"))
+			Expect(err).To(MatchError("unsupported cipher suite for 0-RTT: 0x1303"))
+		})
+	})
 })
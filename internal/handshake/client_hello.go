@@ -0,0 +1,55 @@
+package handshake
+
+import (
+	"errors"
+
+	"github.com/bifurcation/mint"
+)
+
+const recordHeaderLen = 5
+
+// parseClientHello parses the ClientHello from the first TLS record sent on the crypto stream.
+// It returns the raw handshake message, as well as the parsed ClientHello.
+func parseClientHello(data []byte) ([]byte, *mint.ClientHelloBody, error) {
+	if len(data) < recordHeaderLen || mint.RecordType(data[0]) != mint.RecordTypeHandshake {
+		return nil, nil, errors.New("expected a handshake record")
+	}
+	recordLen := int(data[3])<<8 | int(data[4])
+	record := data[recordHeaderLen:]
+	if len(record) < recordLen {
+		return nil, nil, errors.New("record too short")
+	}
+	record = record[:recordLen]
+	if len(record) < 4 || mint.HandshakeType(record[0]) != mint.HandshakeTypeClientHello {
+		return nil, nil, errors.New("expected a ClientHello")
+	}
+	msgLen := int(record[1])<<16 | int(record[2])<<8 | int(record[3])
+	if len(record) < 4+msgLen {
+		return nil, nil, errors.New("ClientHello doesn't fit into the first record")
+	}
+	ch := &mint.ClientHelloBody{}
+	if _, err := ch.Unmarshal(record[4 : 4+msgLen]); err != nil {
+		return nil, nil, err
+	}
+	return record[:4+msgLen], ch, nil
+}
+
+// getPSKIdentity returns the identity of the pre-shared key offered in the ClientHello.
+// mint never offers more than one pre-shared key.
+func getPSKIdentity(ch *mint.ClientHelloBody) ([]byte, bool) {
+	ext, ok := getPSKExtension(ch)
+	if !ok {
+		return nil, false
+	}
+	return ext.Identities[0].Identity, true
+}
+
+// getPSKExtension returns the pre_shared_key extension of the ClientHello.
+func getPSKExtension(ch *mint.ClientHelloBody) (*mint.PreSharedKeyExtension, bool) {
+	ext := &mint.PreSharedKeyExtension{HandshakeType: mint.HandshakeTypeClientHello}
+	found, err := ch.Extensions.Find(ext)
+	if err != nil || !found || len(ext.Identities) == 0 || len(ext.Identities) != len(ext.Binders) {
+		return nil, false
+	}
+	return ext, true
+}
@@ -0,0 +1,82 @@
+package handshake
+
+import (
+	"time"
+
+	"github.com/bifurcation/mint"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// getClientHello returns the first flight of a mint client.
+// If psk is set, the ClientHello offers it for resumption.
+func getClientHello(psk *mint.PreSharedKey) []byte {
+	psks := mint.PSKMapCache{}
+	if psk != nil {
+		psks["quic.clemente.io"] = *psk
+	}
+	cs := NewCryptoStreamConn(nil)
+	conn := mint.Client(cs, &mint.Config{
+		ServerName:  "quic.clemente.io",
+		NonBlocking: true,
+		PSKs:        &psks,
+	})
+	alert := conn.Handshake()
+	ExpectWithOffset(1, alert).To(Equal(mint.AlertNoAlert))
+	return cs.GetDataForWriting()
+}
+
+func getTestPSK() *mint.PreSharedKey {
+	return &mint.PreSharedKey{
+		CipherSuite:  mint.TLS_AES_128_GCM_SHA256,
+		IsResumption: true,
+		Identity:     []byte("session ticket"),
+		Key:          make([]byte, 32),
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+}
+
+var _ = Describe("ClientHello parsing", func() {
+	It("parses a ClientHello", func() {
+		data := getClientHello(nil)
+		raw, ch, err := parseClientHello(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(raw).To(Equal(data[recordHeaderLen:]))
+		Expect(ch.CipherSuites).ToNot(BeEmpty())
+	})
+
+	It("errors if the data doesn't start with a handshake record", func() {
+		data := getClientHello(nil)
+		data[0] = byte(mint.RecordTypeApplicationData)
+		_, _, err := parseClientHello(data)
+		Expect(err).To(MatchError("expected a handshake record"))
+	})
+
+	It("errors if the record is too short", func() {
+		data := getClientHello(nil)
+		_, _, err := parseClientHello(data[:len(data)-1])
+		Expect(err).To(MatchError("record too short"))
+	})
+
+	It("errors if the record doesn't contain a ClientHello", func() {
+		data := getClientHello(nil)
+		data[recordHeaderLen] = byte(mint.HandshakeTypeServerHello)
+		_, _, err := parseClientHello(data)
+		Expect(err).To(MatchError("expected a ClientHello"))
+	})
+
+	It("gets the identity of the pre-shared key", func() {
+		_, ch, err := parseClientHello(getClientHello(getTestPSK()))
+		Expect(err).ToNot(HaveOccurred())
+		identity, ok := getPSKIdentity(ch)
+		Expect(ok).To(BeTrue())
+		Expect(identity).To(Equal([]byte("session ticket")))
+	})
+
+	It("doesn't get an identity if no pre-shared key is offered", func() {
+		_, ch, err := parseClientHello(getClientHello(nil))
+		Expect(err).ToNot(HaveOccurred())
+		_, ok := getPSKIdentity(ch)
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,174 @@
+package handshake
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/bifurcation/mint"
+)
+
+// ClientSessionState contains the state a client needs to resume a handshake with a server.
+// In gQUIC, this is the server config, the source address token, the certificate chain,
+// and the proof the server sent for them.
+// With TLS, this is the session ticket.
+type ClientSessionState struct {
+	serverConfig     []byte // the raw server config
+	stk              []byte
+	certData         []byte // the raw certificate chain, as sent in the CERT tag
+	proof            []byte
+	chloForSignature []byte // the CHLO that the proof was calculated for
+
+	psk        *mint.PreSharedKey   // the pre-shared key derived from a TLS session ticket
+	peerParams *TransportParameters // the parameters the server sent, used for 0-RTT
+}
+
+// A ClientSessionCache is a cache of ClientSessionState objects.
+// It is used by the client to resume handshakes with a server.
+// Implementations must be safe for concurrent use.
+type ClientSessionCache interface {
+	// Get searches for a ClientSessionState associated with the given key.
+	// On return, ok is true if one was found.
+	Get(sessionKey string) (session *ClientSessionState, ok bool)
+	// Put adds the ClientSessionState to the cache with the given key.
+	Put(sessionKey string, cs *ClientSessionState)
+}
+
+type lruSessionCacheEntry struct {
+	sessionKey string
+	state      *ClientSessionState
+}
+
+// lruSessionCache is a ClientSessionCache implementation that uses an LRU caching strategy.
+type lruSessionCache struct {
+	mutex sync.Mutex
+
+	m        map[string]*list.Element
+	q        *list.List
+	capacity int
+}
+
+var _ ClientSessionCache = &lruSessionCache{}
+
+// NewLRUClientSessionCache returns a ClientSessionCache with the given capacity that uses an LRU strategy.
+// If capacity is < 1, a default capacity is used instead.
+func NewLRUClientSessionCache(capacity int) ClientSessionCache {
+	const defaultSessionCacheCapacity = 64
+
+	if capacity < 1 {
+		capacity = defaultSessionCacheCapacity
+	}
+	return &lruSessionCache{
+		m:        make(map[string]*list.Element),
+		q:        list.New(),
+		capacity: capacity,
+	}
+}
+
+// Put adds the provided (sessionKey, cs) pair to the cache.
+func (c *lruSessionCache) Put(sessionKey string, cs *ClientSessionState) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.m[sessionKey]; ok {
+		elem.Value.(*lruSessionCacheEntry).state = cs
+		c.q.MoveToFront(elem)
+		return
+	}
+
+	if c.q.Len() < c.capacity {
+		entry := &lruSessionCacheEntry{sessionKey, cs}
+		c.m[sessionKey] = c.q.PushFront(entry)
+		return
+	}
+
+	// the cache is full, reuse the least recently used entry
+	elem := c.q.Back()
+	entry := elem.Value.(*lruSessionCacheEntry)
+	delete(c.m, entry.sessionKey)
+	entry.sessionKey = sessionKey
+	entry.state = cs
+	c.q.MoveToFront(elem)
+	c.m[sessionKey] = elem
+}
+
+// Get returns the ClientSessionState value associated with a given key.
+// It returns (nil, false) if no value is found.
+func (c *lruSessionCache) Get(sessionKey string) (*ClientSessionState, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.m[sessionKey]; ok {
+		c.q.MoveToFront(elem)
+		return elem.Value.(*lruSessionCacheEntry).state, true
+	}
+	return nil, false
+}
+
+// sessionTicketKey returns the key used to store the session ticket for a server.
+// The sessionCacheKey consists of the hostname and the port of the server.
+// The gQUIC state of a server is stored under a different key, such that they don't overwrite each other.
+func sessionTicketKey(sessionCacheKey string) string {
+	return "tls:" + sessionCacheKey
+}
+
+// getSessionTicket returns the session state for a server, if it contains a session ticket.
+// Expired session tickets are never returned.
+func getSessionTicket(cache ClientSessionCache, sessionCacheKey string) (*ClientSessionState, bool) {
+	if cache == nil {
+		return nil, false
+	}
+	cs, ok := cache.Get(sessionTicketKey(sessionCacheKey))
+	if !ok || cs.psk == nil || time.Now().After(cs.psk.ExpiresAt) {
+		return nil, false
+	}
+	return cs, true
+}
+
+// The clientPSKCache allows mint to store its session tickets in a ClientSessionCache.
+type clientPSKCache struct {
+	cache           ClientSessionCache
+	sessionCacheKey string // the hostname and the port of the server
+	extHandler      TLSExtensionHandler
+}
+
+var _ mint.PreSharedKeyCache = &clientPSKCache{}
+
+// NewClientPSKCache creates a mint.PreSharedKeyCache that stores session tickets in a ClientSessionCache.
+// The parameters received by the extHandler are stored along with the session tickets.
+// mint looks up session tickets by the server name. They are stored using the sessionCacheKey instead,
+// such that servers running on different ports of the same host don't share their session tickets.
+func NewClientPSKCache(cache ClientSessionCache, sessionCacheKey string, extHandler TLSExtensionHandler) mint.PreSharedKeyCache {
+	return &clientPSKCache{
+		cache:           cache,
+		sessionCacheKey: sessionCacheKey,
+		extHandler:      extHandler,
+	}
+}
+
+// Get returns the pre-shared key for the server.
+// Expired keys are never returned.
+func (c *clientPSKCache) Get(string) (mint.PreSharedKey, bool) {
+	cs, ok := getSessionTicket(c.cache, c.sessionCacheKey)
+	if !ok {
+		return mint.PreSharedKey{}, false
+	}
+	return *cs.psk, true
+}
+
+func (c *clientPSKCache) Put(_ string, psk mint.PreSharedKey) {
+	cs := &ClientSessionState{psk: &psk}
+	if params, err := c.extHandler.ReceivedPeerParams(); err == nil && params != nil {
+		p := *params
+		// the stateless reset token is only valid for the connection it was issued for
+		p.StatelessResetToken = nil
+		cs.peerParams = &p
+	}
+	c.cache.Put(sessionTicketKey(c.sessionCacheKey), cs)
+}
+
+// Size is only used by mint on the server side.
+// The ClientSessionCache doesn't expose the number of cached sessions.
+func (c *clientPSKCache) Size() int {
+	return 0
+}
@@ -0,0 +1,152 @@
+package handshake
+
+import (
+	"time"
+
+	"github.com/bifurcation/mint"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LRU Client Session Cache", func() {
+	It("returns nil for unknown keys", func() {
+		cache := NewLRUClientSessionCache(2)
+		state, ok := cache.Get("quic.clemente.io")
+		Expect(ok).To(BeFalse())
+		Expect(state).To(BeNil())
+	})
+
+	It("saves sessions", func() {
+		cache := NewLRUClientSessionCache(2)
+		state := &ClientSessionState{stk: []byte("foobar")}
+		cache.Put("quic.clemente.io", state)
+		s, ok := cache.Get("quic.clemente.io")
+		Expect(ok).To(BeTrue())
+		Expect(s).To(Equal(state))
+	})
+
+	It("replaces sessions", func() {
+		cache := NewLRUClientSessionCache(2)
+		cache.Put("quic.clemente.io", &ClientSessionState{stk: []byte("foo")})
+		state := &ClientSessionState{stk: []byte("bar")}
+		cache.Put("quic.clemente.io", state)
+		s, ok := cache.Get("quic.clemente.io")
+		Expect(ok).To(BeTrue())
+		Expect(s).To(Equal(state))
+	})
+
+	It("evicts the least recently used session", func() {
+		cache := NewLRUClientSessionCache(2)
+		state1 := &ClientSessionState{stk: []byte("1")}
+		state2 := &ClientSessionState{stk: []byte("2")}
+		state3 := &ClientSessionState{stk: []byte("3")}
+		cache.Put("server1", state1)
+		cache.Put("server2", state2)
+		// use server1, so that server2 becomes the least recently used session
+		_, ok := cache.Get("server1")
+		Expect(ok).To(BeTrue())
+		cache.Put("server3", state3)
+		_, ok = cache.Get("server2")
+		Expect(ok).To(BeFalse())
+		s, ok := cache.Get("server1")
+		Expect(ok).To(BeTrue())
+		Expect(s).To(Equal(state1))
+		s, ok = cache.Get("server3")
+		Expect(ok).To(BeTrue())
+		Expect(s).To(Equal(state3))
+	})
+
+	It("uses a default capacity", func() {
+		cache := NewLRUClientSessionCache(0).(*lruSessionCache)
+		Expect(cache.capacity).To(BeNumerically(">", 0))
+	})
+})
+
+var _ = Describe("Client PSK Cache", func() {
+	var (
+		sessionCache ClientSessionCache
+		extHandler   *extensionHandlerClient
+		pskCache     mint.PreSharedKeyCache
+	)
+
+	BeforeEach(func() {
+		sessionCache = NewLRUClientSessionCache(2)
+		extHandler = &extensionHandlerClient{}
+		pskCache = NewClientPSKCache(sessionCache, "quic.clemente.io:443", extHandler)
+	})
+
+	It("returns false for unknown servers", func() {
+		_, ok := pskCache.Get("quic.clemente.io")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("saves pre-shared keys", func() {
+		psk := mint.PreSharedKey{
+			Identity:  []byte("identity"),
+			Key:       []byte("key"),
+			ExpiresAt: time.Now().Add(time.Hour),
+		}
+		pskCache.Put("quic.clemente.io", psk)
+		p, ok := pskCache.Get("quic.clemente.io")
+		Expect(ok).To(BeTrue())
+		Expect(p).To(Equal(psk))
+		state, ok := sessionCache.Get(sessionTicketKey("quic.clemente.io:443"))
+		Expect(ok).To(BeTrue())
+		Expect(state.psk).To(Equal(&psk))
+		Expect(state.peerParams).To(BeNil())
+	})
+
+	It("saves the transport parameters sent by the server, without the stateless reset token", func() {
+		extHandler.peerParams = &TransportParameters{
+			MaxBidiStreamID:     100,
+			StatelessResetToken: &[16]byte{1, 2, 3},
+		}
+		pskCache.Put("quic.clemente.io", mint.PreSharedKey{ExpiresAt: time.Now().Add(time.Hour)})
+		state, ok := sessionCache.Get(sessionTicketKey("quic.clemente.io:443"))
+		Expect(ok).To(BeTrue())
+		Expect(state.peerParams).To(Equal(&TransportParameters{MaxBidiStreamID: 100}))
+		Expect(extHandler.peerParams.StatelessResetToken).ToNot(BeNil())
+	})
+
+	It("doesn't return expired pre-shared keys", func() {
+		pskCache.Put("quic.clemente.io", mint.PreSharedKey{
+			Identity:  []byte("identity"),
+			ExpiresAt: time.Now().Add(-time.Second),
+		})
+		_, ok := pskCache.Get("quic.clemente.io")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("doesn't return a pre-shared key for gQUIC session states", func() {
+		sessionCache.Put("quic.clemente.io:443", &ClientSessionState{stk: []byte("foobar")})
+		_, ok := pskCache.Get("quic.clemente.io")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("doesn't overwrite the gQUIC session state for the same server", func() {
+		gquicState := &ClientSessionState{stk: []byte("foobar")}
+		sessionCache.Put("quic.clemente.io:443", gquicState)
+		pskCache.Put("quic.clemente.io", mint.PreSharedKey{ExpiresAt: time.Now().Add(time.Hour)})
+		state, ok := sessionCache.Get("quic.clemente.io:443")
+		Expect(ok).To(BeTrue())
+		Expect(state).To(Equal(gquicState))
+		_, ok = pskCache.Get("quic.clemente.io")
+		Expect(ok).To(BeTrue())
+	})
+
+	It("doesn't share pre-shared keys between servers on different ports of the same host", func() {
+		psk := mint.PreSharedKey{Identity: []byte("identity"), ExpiresAt: time.Now().Add(time.Hour)}
+		pskCache.Put("quic.clemente.io", psk)
+		otherPSKCache := NewClientPSKCache(sessionCache, "quic.clemente.io:1337", extHandler)
+		_, ok := otherPSKCache.Get("quic.clemente.io")
+		Expect(ok).To(BeFalse())
+		otherPSK := mint.PreSharedKey{Identity: []byte("other identity"), ExpiresAt: time.Now().Add(time.Hour)}
+		otherPSKCache.Put("quic.clemente.io", otherPSK)
+		p, ok := pskCache.Get("quic.clemente.io")
+		Expect(ok).To(BeTrue())
+		Expect(p).To(Equal(psk))
+		p, ok = otherPSKCache.Get("quic.clemente.io")
+		Expect(ok).To(BeTrue())
+		Expect(p).To(Equal(otherPSK))
+	})
+})
@@ -36,10 +36,19 @@ type cryptoSetupClient struct {
 	proof            []byte
 	chloForSignature []byte
 	lastSentCHLO     []byte
+	certData         []byte // the raw certificate chain, saved in the session cache
 	certManager      crypto.CertManager
+	tlsConfig        *tls.Config
+
+	sessionCache    ClientSessionCache
+	sessionCacheKey string // the hostname and the port of the server
+	// zeroRTT is set when a complete CHLO was sent using the state from the session cache
+	zeroRTT bool
 
 	divNonceChan         chan []byte
 	diversificationNonce []byte
+	// diversifiedSecureAEAD is set when the secureAEAD was derived using the diversification nonce
+	diversifiedSecureAEAD bool
 
 	clientHelloCounter int
 	serverVerified     bool // has the certificate chain and the proof already been verified
@@ -72,6 +81,8 @@ func NewCryptoSetupClient(
 	connID protocol.ConnectionID,
 	version protocol.VersionNumber,
 	tlsConfig *tls.Config,
+	sessionCache ClientSessionCache,
+	sessionCacheKey string,
 	params *TransportParameters,
 	paramsChan chan<- TransportParameters,
 	handshakeEvent chan<- struct{},
@@ -88,6 +99,9 @@ func NewCryptoSetupClient(
 		connID:             connID,
 		version:            version,
 		certManager:        crypto.NewCertManager(tlsConfig),
+		tlsConfig:          tlsConfig,
+		sessionCache:       sessionCache,
+		sessionCacheKey:    sessionCacheKey,
 		params:             params,
 		keyDerivation:      crypto.DeriveQuicCryptoAESKeys,
 		keyExchange:        getEphermalKEX,
@@ -115,6 +129,8 @@ func (h *cryptoSetupClient) HandleCryptoStream() error {
 		}
 	}()
 
+	h.restoreFromSessionCache()
+
 	for {
 		err := h.maybeUpgradeCrypto()
 		if err != nil {
@@ -122,7 +138,9 @@ func (h *cryptoSetupClient) HandleCryptoStream() error {
 		}
 
 		h.mutex.RLock()
-		sendCHLO := h.secureAEAD == nil
+		// When sending 0-RTT data, we already have a secureAEAD.
+		// If the server rejects the CHLO, we need to send a new one nevertheless.
+		sendCHLO := h.secureAEAD == nil || (!h.diversifiedSecureAEAD && !h.zeroRTT)
 		h.mutex.RUnlock()
 
 		if sendCHLO {
@@ -130,6 +148,10 @@ func (h *cryptoSetupClient) HandleCryptoStream() error {
 			if err != nil {
 				return err
 			}
+			// if this was a complete CHLO sent using the state from the session cache, we can now derive the keys for 0-RTT
+			if err := h.maybeUpgradeCrypto(); err != nil {
+				return err
+			}
 		}
 
 		var message HandshakeMessage
@@ -161,6 +183,7 @@ func (h *cryptoSetupClient) HandleCryptoStream() error {
 			h.paramsChan <- *params
 			h.handshakeEvent <- struct{}{}
 			close(h.handshakeEvent)
+			h.saveToSessionCache()
 		default:
 			return qerr.InvalidCryptoMessageType
 		}
@@ -170,6 +193,11 @@ func (h *cryptoSetupClient) HandleCryptoStream() error {
 func (h *cryptoSetupClient) handleREJMessage(cryptoData map[Tag][]byte) error {
 	var err error
 
+	if h.zeroRTT {
+		utils.Infof("Server rejected the 0-RTT handshake")
+		h.zeroRTT = false
+	}
+
 	if stk, ok := cryptoData[TagSTK]; ok {
 		h.stk = stk
 	}
@@ -180,6 +208,12 @@ func (h *cryptoSetupClient) handleREJMessage(cryptoData map[Tag][]byte) error {
 
 	// TODO: what happens if the server sends a different server config in two packets?
 	if scfg, ok := cryptoData[TagSCFG]; ok {
+		// The server config might have changed since we saved it in the session cache.
+		// The proof we verified for the cached server config doesn't apply to the new one.
+		if h.serverVerified && !bytes.Equal(h.serverConfig.Get(), scfg) {
+			h.resetServerVerification()
+		}
+
 		h.serverConfig, err = parseServerConfig(scfg)
 		if err != nil {
 			return err
@@ -208,6 +242,7 @@ func (h *cryptoSetupClient) handleREJMessage(cryptoData map[Tag][]byte) error {
 		if err != nil {
 			return qerr.Error(qerr.InvalidCryptoMessageParameter, "Certificate data invalid")
 		}
+		h.certData = crt
 
 		err = h.certManager.Verify(h.hostname)
 		if err != nil {
@@ -241,6 +276,10 @@ func (h *cryptoSetupClient) handleSHLOMessage(cryptoData map[Tag][]byte) (*Trans
 		h.sno = sno
 	}
 
+	if stk, ok := cryptoData[TagSTK]; ok {
+		h.stk = stk
+	}
+
 	serverPubs, ok := cryptoData[TagPUBS]
 	if !ok {
 		return nil, qerr.Error(qerr.CryptoMessageParameterNotFound, "PUBS")
@@ -381,6 +420,14 @@ func (h *cryptoSetupClient) SetDiversificationNonce(data []byte) {
 	h.divNonceChan <- data
 }
 
+func (h *cryptoSetupClient) KeyPhase() int {
+	panic("key updates not supported for gQUIC")
+}
+
+func (h *cryptoSetupClient) UpdateKeys() error {
+	panic("key updates not supported for gQUIC")
+}
+
 func (h *cryptoSetupClient) ConnectionState() ConnectionState {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
@@ -482,34 +529,121 @@ func (h *cryptoSetupClient) maybeUpgradeCrypto() error {
 	defer h.mutex.Unlock()
 
 	leafCert := h.certManager.GetLeafCert()
-	if h.secureAEAD == nil && (h.serverConfig != nil && len(h.serverConfig.sharedSecret) > 0 && len(h.nonc) > 0 && len(leafCert) > 0 && len(h.diversificationNonce) > 0 && len(h.lastSentCHLO) > 0) {
-		var err error
-		var nonce []byte
-		if h.sno == nil {
-			nonce = h.nonc
-		} else {
-			nonce = append(h.nonc, h.sno...)
-		}
+	if h.diversifiedSecureAEAD || !(h.serverConfig != nil && len(h.serverConfig.sharedSecret) > 0 && len(h.nonc) > 0 && len(leafCert) > 0 && len(h.lastSentCHLO) > 0) {
+		return nil
+	}
+	hasDivNonce := len(h.diversificationNonce) > 0
+	// Without the diversification nonce, the secureAEAD can only be used to send 0-RTT data.
+	// It is derived again when the server sends the diversification nonce.
+	if !hasDivNonce && (h.secureAEAD != nil || !h.zeroRTT) {
+		return nil
+	}
 
-		h.secureAEAD, err = h.keyDerivation(
-			false,
-			h.serverConfig.sharedSecret,
-			nonce,
-			h.connID,
-			h.lastSentCHLO,
-			h.serverConfig.Get(),
-			leafCert,
-			h.diversificationNonce,
-			protocol.PerspectiveClient,
-		)
-		if err != nil {
-			return err
-		}
-		h.handshakeEvent <- struct{}{}
+	var err error
+	var nonce []byte
+	if h.sno == nil {
+		nonce = h.nonc
+	} else {
+		nonce = append(h.nonc, h.sno...)
+	}
+
+	h.secureAEAD, err = h.keyDerivation(
+		false,
+		h.serverConfig.sharedSecret,
+		nonce,
+		h.connID,
+		h.lastSentCHLO,
+		h.serverConfig.Get(),
+		leafCert,
+		h.diversificationNonce,
+		protocol.PerspectiveClient,
+	)
+	if err != nil {
+		return err
+	}
+	h.diversifiedSecureAEAD = hasDivNonce
+	// Don't block if the session didn't process the event for the 0-RTT keys yet.
+	// It will use the new keys when it does so.
+	select {
+	case h.handshakeEvent <- struct{}{}:
+	default:
 	}
 	return nil
 }
 
+// restoreFromSessionCache restores the state saved for this server when a previous connection was established.
+// If the cached state is still valid, the client can send a complete CHLO right away and start sending 0-RTT data.
+func (h *cryptoSetupClient) restoreFromSessionCache() {
+	if h.sessionCache == nil {
+		return
+	}
+	state, ok := h.sessionCache.Get(h.sessionCacheKey)
+	if !ok {
+		return
+	}
+	serverConfig, err := parseServerConfig(state.serverConfig)
+	if err != nil || serverConfig.IsExpired() {
+		utils.Debugf("Not using the cached server config for %s", h.sessionCacheKey)
+		return
+	}
+	if err := h.certManager.SetData(state.certData); err != nil {
+		h.resetServerVerification()
+		return
+	}
+	if err := h.certManager.Verify(h.hostname); err != nil {
+		utils.Infof("Validation of the cached certificate failed: %s", err.Error())
+		h.resetServerVerification()
+		return
+	}
+	if !h.certManager.VerifyServerProof(state.proof, state.chloForSignature, serverConfig.Get()) {
+		utils.Infof("Verification of the cached server proof failed")
+		h.resetServerVerification()
+		return
+	}
+
+	h.serverConfig = serverConfig
+	if err := h.generateClientNonce(); err != nil {
+		h.resetServerVerification()
+		return
+	}
+	h.stk = state.stk
+	h.certData = state.certData
+	h.proof = state.proof
+	h.chloForSignature = state.chloForSignature
+	h.serverVerified = true
+	h.zeroRTT = true
+	utils.Debugf("Using the cached server config for %s", h.sessionCacheKey)
+}
+
+// resetServerVerification discards the server config, the certificate chain and the proof
+func (h *cryptoSetupClient) resetServerVerification() {
+	// the certManager is accessed by ConnectionState
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.serverConfig = nil
+	h.nonc = nil
+	h.proof = nil
+	h.chloForSignature = nil
+	h.certData = nil
+	h.certManager = crypto.NewCertManager(h.tlsConfig)
+	h.serverVerified = false
+}
+
+// saveToSessionCache saves the state needed to resume the handshake with this server
+func (h *cryptoSetupClient) saveToSessionCache() {
+	if h.sessionCache == nil || h.serverConfig == nil || !h.serverVerified || len(h.certData) == 0 {
+		return
+	}
+	h.sessionCache.Put(h.sessionCacheKey, &ClientSessionState{
+		serverConfig:     h.serverConfig.Get(),
+		stk:              h.stk,
+		certData:         h.certData,
+		proof:            h.proof,
+		chloForSignature: h.chloForSignature,
+	})
+}
+
 func (h *cryptoSetupClient) generateClientNonce() error {
 	if len(h.nonc) > 0 {
 		return errClientNonceAlreadyExists
@@ -125,6 +125,8 @@ var _ = Describe("Client Crypto Setup", func() {
 			0,
 			version,
 			nil,
+			nil,
+			"hostname:443",
 			&TransportParameters{IdleTimeout: protocol.DefaultIdleTimeout},
 			paramsChan,
 			handshakeEvent,
@@ -914,6 +916,238 @@ var _ = Describe("Client Crypto Setup", func() {
 		})
 	})
 
+	Context("using the session cache", func() {
+		var (
+			sessionCache ClientSessionCache
+			state        *ClientSessionState
+		)
+
+		BeforeEach(func() {
+			b := &bytes.Buffer{}
+			HandshakeMessage{Tag: TagSCFG, Data: getDefaultServerConfigClient()}.Write(b)
+			state = &ClientSessionState{
+				serverConfig:     b.Bytes(),
+				stk:              []byte("stk"),
+				certData:         []byte("cert"),
+				proof:            []byte("proof"),
+				chloForSignature: []byte("chlo"),
+			}
+			sessionCache = NewLRUClientSessionCache(1)
+			sessionCache.Put("hostname:443", state)
+			cs.sessionCache = sessionCache
+			certManager.leafCert = []byte("leafCert")
+			certManager.verifyServerProofResult = true
+		})
+
+		It("restores the cached state", func() {
+			cs.restoreFromSessionCache()
+			Expect(certManager.setDataCalledWith).To(Equal(state.certData))
+			Expect(certManager.verifyCalled).To(BeTrue())
+			Expect(certManager.verifyServerProofCalled).To(BeTrue())
+			Expect(cs.serverVerified).To(BeTrue())
+			Expect(cs.zeroRTT).To(BeTrue())
+			Expect(cs.serverConfig).ToNot(BeNil())
+			Expect(cs.serverConfig.Get()).To(Equal(state.serverConfig))
+			Expect(cs.stk).To(Equal(state.stk))
+			Expect(cs.proof).To(Equal(state.proof))
+			Expect(cs.chloForSignature).To(Equal(state.chloForSignature))
+			Expect(cs.nonc).To(HaveLen(32))
+		})
+
+		It("doesn't do anything if there's no state for this server", func() {
+			cs.sessionCacheKey = "another host:443"
+			cs.restoreFromSessionCache()
+			Expect(cs.serverVerified).To(BeFalse())
+			Expect(cs.zeroRTT).To(BeFalse())
+			Expect(cs.serverConfig).To(BeNil())
+		})
+
+		It("doesn't use the state saved for a different port on the same host", func() {
+			cs.sessionCacheKey = "hostname:1337"
+			cs.restoreFromSessionCache()
+			Expect(cs.serverVerified).To(BeFalse())
+			Expect(cs.zeroRTT).To(BeFalse())
+			Expect(cs.serverConfig).To(BeNil())
+		})
+
+		It("doesn't use expired server configs", func() {
+			b := &bytes.Buffer{}
+			scfg := getDefaultServerConfigClient()
+			scfg[TagEXPY] = []byte{0x80, 0x54, 0x72, 0x4F, 0, 0, 0, 0} // 2012-03-28
+			HandshakeMessage{Tag: TagSCFG, Data: scfg}.Write(b)
+			state.serverConfig = b.Bytes()
+			cs.restoreFromSessionCache()
+			Expect(cs.serverVerified).To(BeFalse())
+			Expect(cs.zeroRTT).To(BeFalse())
+			Expect(cs.serverConfig).To(BeNil())
+		})
+
+		It("doesn't use the cached state if the certificate chain is not valid anymore", func() {
+			certManager.verifyError = errors.New("expired")
+			cs.restoreFromSessionCache()
+			Expect(cs.serverVerified).To(BeFalse())
+			Expect(cs.zeroRTT).To(BeFalse())
+			Expect(cs.serverConfig).To(BeNil())
+			Expect(cs.certManager).ToNot(Equal(certManager))
+		})
+
+		It("doesn't use the cached state if the proof is not valid", func() {
+			certManager.verifyServerProofResult = false
+			cs.restoreFromSessionCache()
+			Expect(cs.serverVerified).To(BeFalse())
+			Expect(cs.zeroRTT).To(BeFalse())
+			Expect(cs.serverConfig).To(BeNil())
+			Expect(cs.certManager).ToNot(Equal(certManager))
+		})
+
+		It("sends a complete CHLO and derives the keys for 0-RTT", func() {
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				err := cs.HandleCryptoStream()
+				Expect(err).To(MatchError(qerr.Error(qerr.HandshakeFailed, errMockStreamClosing.Error())))
+				close(done)
+			}()
+			Eventually(handshakeEvent).Should(Receive())
+			Expect(handshakeEvent).ToNot(Receive())
+			Expect(cs.secureAEAD).ToNot(BeNil())
+			Expect(cs.diversifiedSecureAEAD).To(BeFalse())
+			Expect(keyDerivationCalledWith.divNonce).To(BeEmpty())
+			Expect(keyDerivationCalledWith.chlo).To(Equal(cs.lastSentCHLO))
+			chlo, err := ParseHandshakeMessage(bytes.NewReader(stream.dataWritten.Bytes()))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(chlo.Tag).To(Equal(TagCHLO))
+			Expect(chlo.Data).To(HaveKeyWithValue(TagSTK, state.stk))
+			Expect(chlo.Data).To(HaveKey(TagSCID))
+			Expect(chlo.Data).To(HaveKey(TagXLCT))
+			Expect(chlo.Data).To(HaveKey(TagPUBS))
+			Expect(chlo.Data).To(HaveKeyWithValue(TagNONC, cs.nonc))
+			// make the go routine return
+			stream.close()
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("derives the keys again when receiving the diversification nonce", func() {
+			cs.restoreFromSessionCache()
+			cs.lastSentCHLO = []byte("lastSentCHLO")
+			Expect(cs.maybeUpgradeCrypto()).To(Succeed())
+			Expect(cs.secureAEAD).ToNot(BeNil())
+			Expect(handshakeEvent).To(Receive())
+			zeroRTTAEAD := cs.secureAEAD
+			cs.diversificationNonce = []byte("divnonce")
+			Expect(cs.maybeUpgradeCrypto()).To(Succeed())
+			Expect(cs.secureAEAD).ToNot(BeIdenticalTo(zeroRTTAEAD))
+			Expect(cs.diversifiedSecureAEAD).To(BeTrue())
+			Expect(keyDerivationCalledWith.divNonce).To(Equal([]byte("divnonce")))
+			Expect(handshakeEvent).To(Receive())
+			// the keys are only derived once with the diversification nonce
+			keyDerivationCalledWith = nil
+			Expect(cs.maybeUpgradeCrypto()).To(Succeed())
+			Expect(keyDerivationCalledWith).To(BeNil())
+			Expect(handshakeEvent).ToNot(Receive())
+		})
+
+		It("doesn't block if the session didn't process the event for the 0-RTT keys yet", func() {
+			cs.handshakeEvent = make(chan struct{}, 1)
+			cs.restoreFromSessionCache()
+			cs.lastSentCHLO = []byte("lastSentCHLO")
+			Expect(cs.maybeUpgradeCrypto()).To(Succeed())
+			cs.diversificationNonce = []byte("divnonce")
+			Expect(cs.maybeUpgradeCrypto()).To(Succeed())
+			Expect(cs.diversifiedSecureAEAD).To(BeTrue())
+		})
+
+		It("sends a new CHLO when the server rejects the 0-RTT handshake", func() {
+			// the server didn't accept the source address token
+			HandshakeMessage{Tag: TagREJ, Data: map[Tag][]byte{TagSTK: []byte("new stk")}}.Write(&stream.dataToRead)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				err := cs.HandleCryptoStream()
+				Expect(err).To(MatchError(qerr.Error(qerr.HandshakeFailed, errMockStreamClosing.Error())))
+				close(done)
+			}()
+			Eventually(handshakeEvent).Should(Receive())
+			// make the go routine return
+			stream.close()
+			Eventually(done).Should(BeClosed())
+			Expect(cs.clientHelloCounter).To(Equal(2))
+			Expect(cs.zeroRTT).To(BeFalse())
+			Expect(cs.stk).To(Equal([]byte("new stk")))
+			// the 0-RTT keys are kept until the server sends the diversification nonce
+			Expect(cs.secureAEAD).ToNot(BeNil())
+		})
+
+		It("verifies the server again if the server sends a different server config", func() {
+			cs.restoreFromSessionCache()
+			nonc := cs.nonc
+			b := &bytes.Buffer{}
+			scfg := getDefaultServerConfigClient()
+			scfg[TagSCID] = bytes.Repeat([]byte{'A'}, 16)
+			HandshakeMessage{Tag: TagSCFG, Data: scfg}.Write(b)
+			err := cs.handleREJMessage(map[Tag][]byte{TagSCFG: b.Bytes()})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cs.serverVerified).To(BeFalse())
+			Expect(cs.zeroRTT).To(BeFalse())
+			Expect(cs.serverConfig.ID).To(Equal(scfg[TagSCID]))
+			Expect(cs.proof).To(BeEmpty())
+			Expect(cs.certManager).ToNot(Equal(certManager))
+			Expect(cs.nonc).To(HaveLen(32))
+			Expect(cs.nonc).ToNot(Equal(nonc))
+		})
+
+		It("keeps the cached proof if the server sends the same server config again", func() {
+			cs.restoreFromSessionCache()
+			err := cs.handleREJMessage(map[Tag][]byte{TagSCFG: state.serverConfig})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cs.serverVerified).To(BeTrue())
+			Expect(cs.proof).To(Equal(state.proof))
+		})
+
+		It("saves the state after the handshake completed", func() {
+			sessionCache = NewLRUClientSessionCache(1)
+			cs.sessionCache = sessionCache
+			b := &bytes.Buffer{}
+			HandshakeMessage{Tag: TagSCFG, Data: getDefaultServerConfigClient()}.Write(b)
+			err := cs.handleREJMessage(map[Tag][]byte{
+				TagSCFG: b.Bytes(),
+				TagSTK:  []byte("stk"),
+				TagCERT: []byte("cert"),
+				TagPROF: []byte("proof"),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cs.serverVerified).To(BeTrue())
+			cs.receivedSecurePacket = true
+			shloMap[TagSTK] = []byte("new stk")
+			HandshakeMessage{Tag: TagSHLO, Data: shloMap}.Write(&stream.dataToRead)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				err := cs.HandleCryptoStream()
+				Expect(err).To(MatchError(qerr.Error(qerr.HandshakeFailed, errMockStreamClosing.Error())))
+				close(done)
+			}()
+			Eventually(handshakeEvent).Should(BeClosed())
+			Eventually(func() bool { _, ok := sessionCache.Get("hostname:443"); return ok }).Should(BeTrue())
+			s, _ := sessionCache.Get("hostname:443")
+			Expect(s.serverConfig).To(Equal(b.Bytes()))
+			Expect(s.stk).To(Equal([]byte("new stk")))
+			Expect(s.certData).To(Equal([]byte("cert")))
+			Expect(s.proof).To(Equal([]byte("proof")))
+			// make the go routine return
+			stream.close()
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("doesn't save the state if the server was not verified", func() {
+			sessionCache = NewLRUClientSessionCache(1)
+			cs.sessionCache = sessionCache
+			cs.saveToSessionCache()
+			_, ok := sessionCache.Get("hostname:443")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
 	Context("Diversification Nonces", func() {
 		It("sets a diversification nonce", func() {
 			done := make(chan struct{})
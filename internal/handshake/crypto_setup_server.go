@@ -456,6 +456,14 @@ func (h *cryptoSetupServer) SetDiversificationNonce(data []byte) {
 	panic("not needed for cryptoSetupServer")
 }
 
+func (h *cryptoSetupServer) KeyPhase() int {
+	panic("key updates not supported for gQUIC")
+}
+
+func (h *cryptoSetupServer) UpdateKeys() error {
+	panic("key updates not supported for gQUIC")
+}
+
 func (h *cryptoSetupServer) ConnectionState() ConnectionState {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
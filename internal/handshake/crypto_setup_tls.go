@@ -1,6 +1,7 @@
 package handshake
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -9,13 +10,17 @@ import (
 	"github.com/bifurcation/mint"
 	"github.com/lucas-clemente/quic-go/internal/crypto"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 )
 
 // ErrCloseSessionForRetry is returned by HandleCryptoStream when the server wishes to perform a stateless retry
 var ErrCloseSessionForRetry = errors.New("closing session in order to recreate after a retry")
 
 // KeyDerivationFunction is used for key derivation
-type KeyDerivationFunction func(crypto.TLSExporter, protocol.Perspective) (crypto.AEAD, error)
+type KeyDerivationFunction func(crypto.TLSExporter, protocol.Perspective) (crypto.AEAD, crypto.KeyUpdater, error)
+
+// ErrKeyUpdateBeforeHandshake is returned by UpdateKeys when the handshake didn't complete yet
+var ErrKeyUpdateBeforeHandshake = errors.New("keys can only be updated after the handshake completed")
 
 type cryptoSetupTLS struct {
 	mutex sync.RWMutex
@@ -24,38 +29,56 @@ type cryptoSetupTLS struct {
 
 	keyDerivation KeyDerivationFunction
 	nullAEAD      crypto.AEAD
-	aead          crypto.AEAD
+	earlyAEAD     crypto.AEAD // the AEAD used for 0-RTT packets. Only set if 0-RTT is used.
+	aead          *updatableAEAD
+
+	keyUpdateInterval uint64
 
 	tls            MintTLS
 	cryptoStream   *CryptoStreamConn
 	handshakeEvent chan<- struct{}
+
+	// only used by the client
+	stream          io.ReadWriter
+	sessionCacheKey string // the hostname and the port of the server
+	extHandler      TLSExtensionHandler
+	sessionCache    ClientSessionCache
 }
 
-// NewCryptoSetupTLSServer creates a new TLS CryptoSetup instance for a server
+// NewCryptoSetupTLSServer creates a new TLS CryptoSetup instance for a server.
+// The earlyAEAD is only set if 0-RTT data was accepted.
 func NewCryptoSetupTLSServer(
 	tls MintTLS,
 	cryptoStream *CryptoStreamConn,
 	nullAEAD crypto.AEAD,
+	earlyAEAD crypto.AEAD,
 	handshakeEvent chan<- struct{},
+	keyUpdateInterval uint64,
 	version protocol.VersionNumber,
 ) CryptoSetup {
 	return &cryptoSetupTLS{
-		tls:            tls,
-		cryptoStream:   cryptoStream,
-		nullAEAD:       nullAEAD,
-		perspective:    protocol.PerspectiveServer,
-		keyDerivation:  crypto.DeriveAESKeys,
-		handshakeEvent: handshakeEvent,
+		tls:               tls,
+		cryptoStream:      cryptoStream,
+		nullAEAD:          nullAEAD,
+		earlyAEAD:         earlyAEAD,
+		perspective:       protocol.PerspectiveServer,
+		keyDerivation:     crypto.DeriveAESKeys,
+		handshakeEvent:    handshakeEvent,
+		keyUpdateInterval: keyUpdateInterval,
 	}
 }
 
-// NewCryptoSetupTLSClient creates a new TLS CryptoSetup instance for a client
+// NewCryptoSetupTLSClient creates a new TLS CryptoSetup instance for a client.
+// If the sessionCache contains a session ticket for the server, the client sends 0-RTT data.
 func NewCryptoSetupTLSClient(
 	cryptoStream io.ReadWriter,
 	connID protocol.ConnectionID,
-	hostname string,
+	sessionCacheKey string,
 	handshakeEvent chan<- struct{},
 	tls MintTLS,
+	extHandler TLSExtensionHandler,
+	sessionCache ClientSessionCache,
+	keyUpdateInterval uint64,
 	version protocol.VersionNumber,
 ) (CryptoSetup, error) {
 	nullAEAD, err := crypto.NewNullAEAD(protocol.PerspectiveClient, connID, version)
@@ -64,11 +87,16 @@ func NewCryptoSetupTLSClient(
 	}
 
 	return &cryptoSetupTLS{
-		perspective:    protocol.PerspectiveClient,
-		tls:            tls,
-		nullAEAD:       nullAEAD,
-		keyDerivation:  crypto.DeriveAESKeys,
-		handshakeEvent: handshakeEvent,
+		perspective:       protocol.PerspectiveClient,
+		tls:               tls,
+		stream:            cryptoStream,
+		sessionCacheKey:   sessionCacheKey,
+		extHandler:        extHandler,
+		sessionCache:      sessionCache,
+		nullAEAD:          nullAEAD,
+		keyDerivation:     crypto.DeriveAESKeys,
+		handshakeEvent:    handshakeEvent,
+		keyUpdateInterval: keyUpdateInterval,
 	}, nil
 }
 
@@ -79,6 +107,18 @@ func (h *cryptoSetupTLS) HandleCryptoStream() error {
 		if _, err := h.cryptoStream.Flush(); err != nil {
 			return err
 		}
+		if h.earlyAEAD != nil {
+			// the session can now be used to receive 0-RTT data
+			h.handshakeEvent <- struct{}{}
+		}
+		// mint writes the session ticket right after the handshake completes.
+		// It must only be sent once the forward-secure keys are available,
+		// since the client doesn't accept unencrypted packets after the handshake.
+		h.cryptoStream.BufferWrites()
+	} else {
+		if err := h.sendClientHello(); err != nil {
+			return err
+		}
 	}
 
 handshakeLoop:
@@ -88,35 +128,146 @@ handshakeLoop:
 		}
 		switch h.tls.State() {
 		case mint.StateClientStart: // this happens if a stateless retry is performed
+			h.dropSessionTicket()
 			return ErrCloseSessionForRetry
 		case mint.StateClientConnected, mint.StateServerConnected:
 			break handshakeLoop
 		}
 	}
 
-	aead, err := h.keyDerivation(h.tls, h.perspective)
+	aead, keyUpdater, err := h.keyDerivation(h.tls, h.perspective)
+	if err != nil {
+		return err
+	}
+	updatableAEAD, err := newUpdatableAEAD(aead, keyUpdater, h.keyUpdateInterval)
 	if err != nil {
 		return err
 	}
 	h.mutex.Lock()
-	h.aead = aead
+	h.aead = updatableAEAD
+	if h.perspective == protocol.PerspectiveClient && h.earlyAEAD != nil && !h.extHandler.EarlyDataAccepted() {
+		utils.Debugf("Server rejected the 0-RTT data")
+		h.earlyAEAD = nil
+	}
 	h.mutex.Unlock()
 
+	if h.perspective == protocol.PerspectiveServer {
+		if _, err := h.cryptoStream.Flush(); err != nil {
+			return err
+		}
+	}
+
 	h.handshakeEvent <- struct{}{}
 	close(h.handshakeEvent)
+
+	if h.perspective == protocol.PerspectiveClient {
+		// The server sends session tickets after the handshake completed.
+		// mint processes them (and stores them in the session cache) when reading from the connection.
+		// This only returns when reading from the crypto stream fails, i.e. when the session is closed.
+		b := make([]byte, 1)
+		for {
+			if _, err := h.tls.Read(b); err != nil && err != mint.WouldBlock {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+// sendClientHello lets mint write the ClientHello, and sends it on the crypto stream.
+// If the client has a session ticket for the server, the 0-RTT keys are derived from the ClientHello,
+// and the session is notified that it can send 0-RTT data.
+func (h *cryptoSetupTLS) sendClientHello() error {
+	buf := &bytes.Buffer{}
+	h.tls.SetCryptoStream(buf)
+	// mint returns after writing the ClientHello, without reading from the stream
+	alert := h.tls.Handshake()
+	h.tls.SetCryptoStream(h.stream)
+	if alert != mint.AlertNoAlert {
+		return fmt.Errorf("TLS handshake error: %s (Alert %d)", alert.String(), alert)
+	}
+	data := buf.Bytes()
+	var earlyAEAD crypto.AEAD
+	var peerParams *TransportParameters
+	if cs, ok := getSessionTicket(h.sessionCache, h.sessionCacheKey); ok {
+		var err error
+		earlyAEAD, err = h.deriveEarlyAEAD(cs.psk, data)
+		if err != nil {
+			utils.Debugf("Not using 0-RTT: %s", err)
+		} else {
+			h.mutex.Lock()
+			h.earlyAEAD = earlyAEAD
+			h.mutex.Unlock()
+			peerParams = cs.peerParams
+		}
+	}
+	if _, err := h.stream.Write(data); err != nil {
+		return err
+	}
+	if earlyAEAD != nil {
+		// The stream limits and flow control windows sent by the server are needed to send 0-RTT data.
+		if peerParams != nil {
+			if err := h.extHandler.RestorePeerParams(peerParams); err != nil {
+				return err
+			}
+		}
+		h.handshakeEvent <- struct{}{}
+	}
+	return nil
+}
+
+// dropSessionTicket deletes the session ticket offered in the ClientHello.
+// The server only performs a stateless retry if it didn't accept the 0-RTT data,
+// so it probably doesn't know the session ticket.
+// mint fails the handshake if the ClientHello sent after a retry offers an unknown session ticket.
+func (h *cryptoSetupTLS) dropSessionTicket() {
+	if _, ok := getSessionTicket(h.sessionCache, h.sessionCacheKey); ok {
+		h.sessionCache.Put(sessionTicketKey(h.sessionCacheKey), &ClientSessionState{})
+	}
+}
+
+func (h *cryptoSetupTLS) deriveEarlyAEAD(psk *mint.PreSharedKey, data []byte) (crypto.AEAD, error) {
+	clientHello, ch, err := parseClientHello(data)
+	if err != nil {
+		return nil, err
+	}
+	if identity, ok := getPSKIdentity(ch); !ok || !bytes.Equal(identity, psk.Identity) {
+		return nil, errors.New("ClientHello didn't offer the session ticket")
+	}
+	return crypto.DeriveEarlyAESKeys(psk, clientHello)
+}
+
 func (h *cryptoSetupTLS) Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) ([]byte, protocol.EncryptionLevel, error) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
 
+	// only the server receives 0-RTT packets
+	openEarly := h.perspective == protocol.PerspectiveServer && h.earlyAEAD != nil
 	if h.aead != nil {
-		data, err := h.aead.Open(dst, src, packetNumber, associatedData)
+		keyPhase := h.aead.KeyPhase()
+		// The associated data is the packet header.
+		// Only the Short Header has a KeyPhase bit, Long Header packets are opened with the current keys.
+		if len(associatedData) > 0 && associatedData[0]&0x80 == 0 {
+			keyPhase = int(associatedData[0]&0x20) >> 5
+		}
+		data, err := h.aead.Open(dst, src, packetNumber, associatedData, keyPhase)
+		if err == nil {
+			return data, protocol.EncryptionForwardSecure, nil
+		}
+		if !openEarly {
+			return nil, protocol.EncryptionUnspecified, err
+		}
+		// 0-RTT packets might arrive after the handshake completed
+		data, err = h.earlyAEAD.Open(dst, src, packetNumber, associatedData)
 		if err != nil {
 			return nil, protocol.EncryptionUnspecified, err
 		}
-		return data, protocol.EncryptionForwardSecure, nil
+		return data, protocol.EncryptionSecure, nil
+	}
+	if openEarly {
+		if data, err := h.earlyAEAD.Open(dst, src, packetNumber, associatedData); err == nil {
+			return data, protocol.EncryptionSecure, nil
+		}
 	}
 	data, err := h.nullAEAD.Open(dst, src, packetNumber, associatedData)
 	if err != nil {
@@ -126,12 +277,19 @@ func (h *cryptoSetupTLS) Open(dst, src []byte, packetNumber protocol.PacketNumbe
 }
 
 func (h *cryptoSetupTLS) GetSealer() (protocol.EncryptionLevel, Sealer) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
 
 	if h.aead != nil {
+		if err := h.aead.MaybeInitiateKeyUpdate(); err != nil {
+			utils.Errorf("Key update failed: %s", err)
+		}
 		return protocol.EncryptionForwardSecure, h.aead
 	}
+	// only the client sends 0-RTT packets
+	if h.perspective == protocol.PerspectiveClient && h.earlyAEAD != nil {
+		return protocol.EncryptionSecure, h.earlyAEAD
+	}
 	return protocol.EncryptionUnencrypted, h.nullAEAD
 }
 
@@ -143,6 +301,11 @@ func (h *cryptoSetupTLS) GetSealerWithEncryptionLevel(encLevel protocol.Encrypti
 	switch encLevel {
 	case protocol.EncryptionUnencrypted:
 		return h.nullAEAD, nil
+	case protocol.EncryptionSecure:
+		if h.perspective == protocol.PerspectiveServer || h.earlyAEAD == nil {
+			return nil, errNoSealer
+		}
+		return h.earlyAEAD, nil
 	case protocol.EncryptionForwardSecure:
 		if h.aead == nil {
 			return nil, errNoSealer
@@ -154,9 +317,42 @@ func (h *cryptoSetupTLS) GetSealerWithEncryptionLevel(encLevel protocol.Encrypti
 }
 
 func (h *cryptoSetupTLS) GetSealerForCryptoStream() (protocol.EncryptionLevel, Sealer) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	// After the handshake completed, the server only sends session tickets on the crypto stream.
+	// The client doesn't accept unencrypted packets any more at that point.
+	if h.perspective == protocol.PerspectiveServer && h.aead != nil {
+		return protocol.EncryptionForwardSecure, h.aead
+	}
 	return protocol.EncryptionUnencrypted, h.nullAEAD
 }
 
+// KeyPhase returns the key phase of packets sealed by the forward-secure sealer.
+// A key update is only initiated by GetSealer, so the key phase doesn't change until the next call to GetSealer.
+func (h *cryptoSetupTLS) KeyPhase() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if h.aead == nil {
+		return 0
+	}
+	return h.aead.KeyPhase()
+}
+
+// UpdateKeys requests a key update.
+// It is initiated as soon as the peer started using the current keys.
+func (h *cryptoSetupTLS) UpdateKeys() error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.aead == nil {
+		return ErrKeyUpdateBeforeHandshake
+	}
+	h.aead.RequestKeyUpdate()
+	return nil
+}
+
 func (h *cryptoSetupTLS) DiversificationNonce() []byte {
 	panic("diversification nonce not needed for TLS")
 }
@@ -173,5 +369,6 @@ func (h *cryptoSetupTLS) ConnectionState() ConnectionState {
 		// TODO: set the ServerName, once mint exports it
 		HandshakeComplete: h.aead != nil,
 		PeerCertificates:  mintConnState.PeerCertificates,
+		Used0RTT:          h.aead != nil && h.earlyAEAD != nil,
 	}
 }
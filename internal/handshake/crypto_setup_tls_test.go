@@ -1,10 +1,13 @@
 package handshake
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/bifurcation/mint"
+	"github.com/golang/mock/gomock"
 	"github.com/lucas-clemente/quic-go/internal/crypto"
 	"github.com/lucas-clemente/quic-go/internal/mocks/crypto"
 	"github.com/lucas-clemente/quic-go/internal/mocks/handshake"
@@ -14,8 +17,12 @@ import (
 	. "github.com/onsi/gomega"
 )
 
-func mockKeyDerivation(crypto.TLSExporter, protocol.Perspective) (crypto.AEAD, error) {
-	return mockcrypto.NewMockAEAD(mockCtrl), nil
+type mockKeyUpdater struct{}
+
+func (mockKeyUpdater) Next() (crypto.AEAD, error) { return mockcrypto.NewMockAEAD(mockCtrl), nil }
+
+func mockKeyDerivation(crypto.TLSExporter, protocol.Perspective) (crypto.AEAD, crypto.KeyUpdater, error) {
+	return mockcrypto.NewMockAEAD(mockCtrl), mockKeyUpdater{}, nil
 }
 
 var _ = Describe("TLS Crypto Setup", func() {
@@ -30,7 +37,9 @@ var _ = Describe("TLS Crypto Setup", func() {
 			nil,
 			NewCryptoStreamConn(nil),
 			nil, // AEAD
+			nil, // 0-RTT AEAD
 			handshakeEvent,
+			protocol.DefaultKeyUpdateInterval,
 			protocol.VersionTLS,
 		).(*cryptoSetupTLS)
 		cs.nullAEAD = mockcrypto.NewMockAEAD(mockCtrl)
@@ -55,6 +64,23 @@ var _ = Describe("TLS Crypto Setup", func() {
 		Expect(handshakeEvent).To(BeClosed())
 	})
 
+	It("sends the session ticket after deriving the forward-secure keys", func() {
+		stream := &bytes.Buffer{}
+		cs.cryptoStream.SetStream(stream)
+		cs.tls = mockhandshake.NewMockMintTLS(mockCtrl)
+		cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Handshake().Do(func() {
+			cs.cryptoStream.Write([]byte("session ticket"))
+		}).Return(mint.AlertNoAlert)
+		cs.tls.(*mockhandshake.MockMintTLS).EXPECT().State().Return(mint.StateServerConnected)
+		cs.keyDerivation = func(tls crypto.TLSExporter, p protocol.Perspective) (crypto.AEAD, crypto.KeyUpdater, error) {
+			Expect(stream.Len()).To(BeZero())
+			return mockKeyDerivation(tls, p)
+		}
+		err := cs.HandleCryptoStream()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stream.Bytes()).To(Equal([]byte("session ticket")))
+	})
+
 	It("handshakes until it is connected", func() {
 		cs.tls = mockhandshake.NewMockMintTLS(mockCtrl)
 		cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Handshake().Return(mint.AlertNoAlert).Times(10)
@@ -133,7 +159,7 @@ var _ = Describe("TLS Crypto Setup", func() {
 
 			It("is not accepted after the handshake completes", func() {
 				doHandshake()
-				cs.aead.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("foobar encrypted"), protocol.PacketNumber(1), []byte{}).Return(nil, errors.New("authentication failed"))
+				cs.aead.current.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("foobar encrypted"), protocol.PacketNumber(1), []byte{}).Return(nil, errors.New("authentication failed"))
 				_, enc, err := cs.Open(nil, []byte("foobar encrypted"), 1, []byte{})
 				Expect(err).To(MatchError("authentication failed"))
 				Expect(enc).To(Equal(protocol.EncryptionUnspecified))
@@ -143,21 +169,118 @@ var _ = Describe("TLS Crypto Setup", func() {
 		Context("forward-secure encryption", func() {
 			It("is used for sealing after the handshake completes", func() {
 				doHandshake()
-				cs.aead.(*mockcrypto.MockAEAD).EXPECT().Seal(nil, []byte("foobar"), protocol.PacketNumber(5), []byte{}).Return([]byte("foobar forward sec"))
+				cs.aead.current.(*mockcrypto.MockAEAD).EXPECT().Seal(nil, []byte("foobar"), protocol.PacketNumber(5), []byte{}).Return([]byte("foobar forward sec"))
 				enc, sealer := cs.GetSealer()
 				Expect(enc).To(Equal(protocol.EncryptionForwardSecure))
 				d := sealer.Seal(nil, []byte("foobar"), 5, []byte{})
 				Expect(d).To(Equal([]byte("foobar forward sec")))
 			})
 
+			It("is used for the crypto stream after the handshake completes", func() {
+				doHandshake()
+				cs.aead.current.(*mockcrypto.MockAEAD).EXPECT().Seal(nil, []byte("foobar"), protocol.PacketNumber(5), []byte{}).Return([]byte("foobar forward sec"))
+				enc, sealer := cs.GetSealerForCryptoStream()
+				Expect(enc).To(Equal(protocol.EncryptionForwardSecure))
+				d := sealer.Seal(nil, []byte("foobar"), 5, []byte{})
+				Expect(d).To(Equal([]byte("foobar forward sec")))
+			})
+
 			It("is used for opening after the handshake completes", func() {
 				doHandshake()
-				cs.aead.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("encrypted"), protocol.PacketNumber(6), []byte{}).Return([]byte("decrypted"), nil)
+				cs.aead.current.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("encrypted"), protocol.PacketNumber(6), []byte{}).Return([]byte("decrypted"), nil)
 				d, enc, err := cs.Open(nil, []byte("encrypted"), 6, []byte{})
 				Expect(err).ToNot(HaveOccurred())
 				Expect(enc).To(Equal(protocol.EncryptionForwardSecure))
 				Expect(d).To(Equal([]byte("decrypted")))
 			})
+
+			It("errors when updating keys before the handshake completes", func() {
+				Expect(cs.UpdateKeys()).To(MatchError(ErrKeyUpdateBeforeHandshake))
+			})
+
+			It("updates keys", func() {
+				doHandshake()
+				Expect(cs.KeyPhase()).To(BeZero())
+				// a Short Header packet with key phase 0
+				hdr := []byte{0x40}
+				cs.aead.current.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("encrypted"), protocol.PacketNumber(6), hdr).Return([]byte("decrypted"), nil)
+				_, _, err := cs.Open(nil, []byte("encrypted"), 6, hdr)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cs.UpdateKeys()).To(Succeed())
+				next := cs.aead.next
+				enc, sealer := cs.GetSealer()
+				Expect(enc).To(Equal(protocol.EncryptionForwardSecure))
+				Expect(cs.KeyPhase()).To(Equal(1))
+				next.(*mockcrypto.MockAEAD).EXPECT().Seal(nil, []byte("foobar"), protocol.PacketNumber(7), []byte{}).Return([]byte("foobar forward sec"))
+				Expect(sealer.Seal(nil, []byte("foobar"), 7, []byte{})).To(Equal([]byte("foobar forward sec")))
+			})
+
+			It("opens packets using the key phase of the Short Header", func() {
+				doHandshake()
+				// a Short Header packet with key phase 1
+				hdr := []byte{0x40 | 0x20}
+				cs.aead.next.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("encrypted"), protocol.PacketNumber(6), hdr).Return([]byte("decrypted"), nil)
+				d, enc, err := cs.Open(nil, []byte("encrypted"), 6, hdr)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(enc).To(Equal(protocol.EncryptionForwardSecure))
+				Expect(d).To(Equal([]byte("decrypted")))
+				Expect(cs.KeyPhase()).To(Equal(1))
+			})
+		})
+
+		Context("0-RTT encryption", func() {
+			BeforeEach(func() {
+				cs.earlyAEAD = mockcrypto.NewMockAEAD(mockCtrl)
+			})
+
+			It("opens 0-RTT packets", func() {
+				cs.earlyAEAD.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("foobar enc"), protocol.PacketNumber(10), []byte{}).Return([]byte("foobar"), nil)
+				d, enc, err := cs.Open(nil, []byte("foobar enc"), 10, []byte{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(d).To(Equal([]byte("foobar")))
+				Expect(enc).To(Equal(protocol.EncryptionSecure))
+			})
+
+			It("falls back to null encryption", func() {
+				cs.earlyAEAD.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("foobar enc"), protocol.PacketNumber(10), []byte{}).Return(nil, errors.New("authentication failed"))
+				cs.nullAEAD.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("foobar enc"), protocol.PacketNumber(10), []byte{}).Return([]byte("foobar"), nil)
+				d, enc, err := cs.Open(nil, []byte("foobar enc"), 10, []byte{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(d).To(Equal([]byte("foobar")))
+				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
+			})
+
+			It("opens 0-RTT packets after the handshake completes", func() {
+				doHandshake()
+				cs.aead.current.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("foobar enc"), protocol.PacketNumber(10), []byte{}).Return(nil, errors.New("authentication failed"))
+				cs.earlyAEAD.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("foobar enc"), protocol.PacketNumber(10), []byte{}).Return([]byte("foobar"), nil)
+				d, enc, err := cs.Open(nil, []byte("foobar enc"), 10, []byte{})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(d).To(Equal([]byte("foobar")))
+				Expect(enc).To(Equal(protocol.EncryptionSecure))
+			})
+
+			It("doesn't accept unencrypted packets after the handshake completes", func() {
+				doHandshake()
+				cs.aead.current.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("foobar enc"), protocol.PacketNumber(10), []byte{}).Return(nil, errors.New("authentication failed"))
+				cs.earlyAEAD.(*mockcrypto.MockAEAD).EXPECT().Open(nil, []byte("foobar enc"), protocol.PacketNumber(10), []byte{}).Return(nil, errors.New("authentication failed"))
+				_, enc, err := cs.Open(nil, []byte("foobar enc"), 10, []byte{})
+				Expect(err).To(MatchError("authentication failed"))
+				Expect(enc).To(Equal(protocol.EncryptionUnspecified))
+			})
+
+			It("never seals with the 0-RTT keys", func() {
+				enc, _ := cs.GetSealer()
+				Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
+				_, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionSecure)
+				Expect(err).To(MatchError("CryptoSetup: no sealer with encryption level encrypted (not forward-secure)"))
+			})
+
+			It("reports that 0-RTT was used", func() {
+				doHandshake()
+				cs.tls.(*mockhandshake.MockMintTLS).EXPECT().ConnectionState().Return(mint.ConnectionState{})
+				Expect(cs.ConnectionState().Used0RTT).To(BeTrue())
+			})
 		})
 
 		Context("forcing encryption levels", func() {
@@ -172,7 +295,7 @@ var _ = Describe("TLS Crypto Setup", func() {
 
 			It("forces forward-secure encryption", func() {
 				doHandshake()
-				cs.aead.(*mockcrypto.MockAEAD).EXPECT().Seal(nil, []byte("foobar"), protocol.PacketNumber(5), []byte{}).Return([]byte("foobar forward sec"))
+				cs.aead.current.(*mockcrypto.MockAEAD).EXPECT().Seal(nil, []byte("foobar"), protocol.PacketNumber(5), []byte{}).Return([]byte("foobar forward sec"))
 				sealer, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionForwardSecure)
 				Expect(err).ToNot(HaveOccurred())
 				d := sealer.Seal(nil, []byte("foobar"), 5, []byte{})
@@ -204,29 +327,182 @@ var _ = Describe("TLS Crypto Setup", func() {
 var _ = Describe("TLS Crypto Setup, for the client", func() {
 	var (
 		cs             *cryptoSetupTLS
+		stream         *bytes.Buffer
+		sessionCache   ClientSessionCache
+		extHandler     *extensionHandlerClient
 		handshakeEvent chan struct{}
 	)
 
 	BeforeEach(func() {
-		handshakeEvent = make(chan struct{})
+		stream = &bytes.Buffer{}
+		sessionCache = NewLRUClientSessionCache(1)
+		extHandler = &extensionHandlerClient{}
+		handshakeEvent = make(chan struct{}, 2)
 		csInt, err := NewCryptoSetupTLSClient(
-			nil,
+			stream,
 			0,
-			"quic.clemente.io",
+			"quic.clemente.io:443",
 			handshakeEvent,
 			nil, // mintTLS
+			extHandler,
+			sessionCache,
+			protocol.DefaultKeyUpdateInterval,
 			protocol.VersionTLS,
 		)
 		Expect(err).ToNot(HaveOccurred())
 		cs = csInt.(*cryptoSetupTLS)
+		cs.keyDerivation = mockKeyDerivation
+		cs.tls = mockhandshake.NewMockMintTLS(mockCtrl)
 	})
 
+	// expectClientHello lets the mock mint write the ClientHello
+	expectClientHello := func(clientHello []byte) {
+		var buf io.ReadWriter
+		gomock.InOrder(
+			cs.tls.(*mockhandshake.MockMintTLS).EXPECT().SetCryptoStream(gomock.Any()).Do(func(s io.ReadWriter) { buf = s }),
+			cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Handshake().Do(func() { buf.Write(clientHello) }).Return(mint.AlertNoAlert),
+			cs.tls.(*mockhandshake.MockMintTLS).EXPECT().SetCryptoStream(stream),
+		)
+	}
+
+	// expectHandshake completes the handshake, and closes the crypto stream afterwards
+	expectHandshake := func() {
+		cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Handshake().Return(mint.AlertNoAlert)
+		cs.tls.(*mockhandshake.MockMintTLS).EXPECT().State().Return(mint.StateClientConnected)
+		cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Read(gomock.Any()).Return(0, io.EOF)
+	}
+
 	It("returns when a retry is performed", func() {
-		cs.tls = mockhandshake.NewMockMintTLS(mockCtrl)
+		expectClientHello(getClientHello(nil))
 		cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Handshake().Return(mint.AlertNoAlert)
 		cs.tls.(*mockhandshake.MockMintTLS).EXPECT().State().Return(mint.StateClientStart)
 		err := cs.HandleCryptoStream()
 		Expect(err).To(MatchError(ErrCloseSessionForRetry))
 	})
 
+	It("sends the ClientHello", func() {
+		clientHello := getClientHello(nil)
+		expectClientHello(clientHello)
+		expectHandshake()
+		err := cs.HandleCryptoStream()
+		Expect(err).To(MatchError(io.EOF))
+		Expect(stream.Bytes()).To(Equal(clientHello))
+		Expect(handshakeEvent).To(Receive())
+	})
+
+	It("errors if writing the ClientHello fails", func() {
+		alert := mint.AlertInternalError
+		cs.tls.(*mockhandshake.MockMintTLS).EXPECT().SetCryptoStream(gomock.Any()).Times(2)
+		cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Handshake().Return(alert)
+		err := cs.HandleCryptoStream()
+		Expect(err).To(MatchError(fmt.Errorf("TLS handshake error: %s (Alert %d)", alert.String(), alert)))
+	})
+
+	It("reads session tickets after the handshake completes", func() {
+		expectClientHello(getClientHello(nil))
+		cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Handshake().Return(mint.AlertNoAlert)
+		cs.tls.(*mockhandshake.MockMintTLS).EXPECT().State().Return(mint.StateClientConnected)
+		gomock.InOrder(
+			cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Read(gomock.Any()).Return(0, mint.WouldBlock).Times(3),
+			cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Read(gomock.Any()).Return(0, errors.New("stream closed")),
+		)
+		err := cs.HandleCryptoStream()
+		Expect(err).To(MatchError("stream closed"))
+	})
+
+	It("uses null encryption for the crypto stream after the handshake completes", func() {
+		expectClientHello(getClientHello(nil))
+		expectHandshake()
+		cs.HandleCryptoStream()
+		enc, _ := cs.GetSealerForCryptoStream()
+		Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
+	})
+
+	Context("0-RTT", func() {
+		var psk *mint.PreSharedKey
+
+		BeforeEach(func() {
+			psk = getTestPSK()
+			NewClientPSKCache(sessionCache, "quic.clemente.io:443", extHandler).Put("quic.clemente.io", *psk)
+		})
+
+		It("doesn't use 0-RTT without a session ticket", func() {
+			sessionCache.Put(sessionTicketKey("quic.clemente.io:443"), &ClientSessionState{})
+			expectClientHello(getClientHello(nil))
+			expectHandshake()
+			cs.HandleCryptoStream()
+			Expect(cs.earlyAEAD).To(BeNil())
+		})
+
+		It("doesn't use 0-RTT if the ClientHello doesn't offer the session ticket", func() {
+			expectClientHello(getClientHello(nil))
+			expectHandshake()
+			cs.HandleCryptoStream()
+			Expect(cs.earlyAEAD).To(BeNil())
+		})
+
+		It("drops the session ticket when a retry is performed", func() {
+			expectClientHello(getClientHello(psk))
+			cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Handshake().Return(mint.AlertNoAlert)
+			cs.tls.(*mockhandshake.MockMintTLS).EXPECT().State().Return(mint.StateClientStart)
+			err := cs.HandleCryptoStream()
+			Expect(err).To(MatchError(ErrCloseSessionForRetry))
+			_, ok := getSessionTicket(sessionCache, "quic.clemente.io:443")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("seals with the 0-RTT keys before the handshake completes", func() {
+			expectClientHello(getClientHello(psk))
+			// block the handshake, so that we can check the sealer
+			cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Handshake().Return(mint.AlertInternalError)
+			cs.HandleCryptoStream()
+			enc, sealer := cs.GetSealer()
+			Expect(enc).To(Equal(protocol.EncryptionSecure))
+			Expect(sealer).ToNot(BeNil())
+			s, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionSecure)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(s).To(Equal(sealer))
+			enc, _ = cs.GetSealerForCryptoStream()
+			Expect(enc).To(Equal(protocol.EncryptionUnencrypted))
+		})
+
+		It("tells the session when the 0-RTT keys are available", func() {
+			expectClientHello(getClientHello(psk))
+			cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Handshake().Return(mint.AlertInternalError)
+			cs.HandleCryptoStream()
+			Expect(handshakeEvent).To(Receive())
+			Expect(handshakeEvent).ToNot(Receive())
+		})
+
+		It("restores the transport parameters sent by the server", func() {
+			params := &TransportParameters{MaxBidiStreamID: 100}
+			sessionCache.Put(sessionTicketKey("quic.clemente.io:443"), &ClientSessionState{psk: psk, peerParams: params})
+			extHandler.paramsChan = make(chan TransportParameters, 1)
+			expectClientHello(getClientHello(psk))
+			cs.tls.(*mockhandshake.MockMintTLS).EXPECT().Handshake().Return(mint.AlertInternalError)
+			cs.HandleCryptoStream()
+			Expect(extHandler.GetPeerParams()).To(Receive(Equal(*params)))
+		})
+
+		It("reports when 0-RTT was accepted", func() {
+			extHandler.earlyDataAccepted = true
+			expectClientHello(getClientHello(psk))
+			expectHandshake()
+			cs.HandleCryptoStream()
+			cs.tls.(*mockhandshake.MockMintTLS).EXPECT().ConnectionState().Return(mint.ConnectionState{})
+			Expect(cs.ConnectionState().Used0RTT).To(BeTrue())
+			enc, _ := cs.GetSealer()
+			Expect(enc).To(Equal(protocol.EncryptionForwardSecure))
+		})
+
+		It("drops the 0-RTT keys when 0-RTT was rejected", func() {
+			expectClientHello(getClientHello(psk))
+			expectHandshake()
+			cs.HandleCryptoStream()
+			cs.tls.(*mockhandshake.MockMintTLS).EXPECT().ConnectionState().Return(mint.ConnectionState{})
+			Expect(cs.ConnectionState().Used0RTT).To(BeFalse())
+			_, err := cs.GetSealerWithEncryptionLevel(protocol.EncryptionSecure)
+			Expect(err).To(MatchError("CryptoSetup: no sealer with encryption level encrypted (not forward-secure)"))
+		})
+	})
 })
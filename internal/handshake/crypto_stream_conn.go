@@ -21,6 +21,8 @@ type CryptoStreamConn struct {
 
 	// stream will be set once the session is initialized
 	stream io.ReadWriter
+	// if set, data is written to the write buffer, even if the stream is set
+	bufferWrites bool
 }
 
 var _ net.Conn = &CryptoStreamConn{}
@@ -44,7 +46,7 @@ func (c *CryptoStreamConn) AddDataForReading(data []byte) {
 }
 
 func (c *CryptoStreamConn) Write(p []byte) (int, error) {
-	if c.stream != nil {
+	if c.stream != nil && !c.bufferWrites {
 		return c.stream.Write(p)
 	}
 	return c.writeBuf.Write(p)
@@ -64,8 +66,15 @@ func (c *CryptoStreamConn) SetStream(stream io.ReadWriter) {
 	c.stream = stream
 }
 
-// Flush copies the contents of the write buffer to the stream
+// BufferWrites makes all data written go to the write buffer, until Flush is called.
+func (c *CryptoStreamConn) BufferWrites() {
+	c.bufferWrites = true
+}
+
+// Flush copies the contents of the write buffer to the stream.
+// Data written after calling Flush is written to the stream directly.
 func (c *CryptoStreamConn) Flush() (int, error) {
+	c.bufferWrites = false
 	n, err := io.Copy(c.stream, &c.writeBuf)
 	return int(n), err
 }
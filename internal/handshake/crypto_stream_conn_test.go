@@ -53,6 +53,20 @@ var _ = Describe("CryptoStreamConn", func() {
 		Expect(stream.Bytes()).To(Equal([]byte("foobar")))
 	})
 
+	It("buffers writes until it is flushed", func() {
+		stream := &bytes.Buffer{}
+		csc.SetStream(stream)
+		csc.BufferWrites()
+		csc.Write([]byte("foo"))
+		Expect(stream.Len()).To(BeZero())
+		n, err := csc.Flush()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(3))
+		Expect(stream.Bytes()).To(Equal([]byte("foo")))
+		csc.Write([]byte("bar"))
+		Expect(stream.Bytes()).To(Equal([]byte("foobar")))
+	})
+
 	It("returns the remote address", func() {
 		Expect(csc.RemoteAddr()).To(Equal(remoteAddr))
 	})
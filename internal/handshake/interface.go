@@ -21,6 +21,15 @@ type TLSExtensionHandler interface {
 	Send(mint.HandshakeType, *mint.ExtensionList) error
 	Receive(mint.HandshakeType, *mint.ExtensionList) error
 	GetPeerParams() <-chan TransportParameters
+	// EarlyDataAccepted says if the server accepted 0-RTT data.
+	EarlyDataAccepted() bool
+	// ReceivedPeerParams returns the parameters sent by the server, or nil if they weren't received yet.
+	// It is only used by the client, to store them with the session ticket. The server returns an error.
+	ReceivedPeerParams() (*TransportParameters, error)
+	// RestorePeerParams provides the parameters sent by the server in a previous connection.
+	// They are used for 0-RTT, until the server's parameters are received.
+	// It is only used by the client. The server returns an error.
+	RestorePeerParams(*TransportParameters) error
 }
 
 // MintTLS combines some methods needed to interact with mint.
@@ -31,6 +40,8 @@ type MintTLS interface {
 	Handshake() mint.Alert
 	State() mint.State
 	ConnectionState() mint.ConnectionState
+	// Read processes post-handshake messages, i.e. session tickets.
+	Read([]byte) (int, error)
 
 	SetCryptoStream(io.ReadWriter)
 }
@@ -42,6 +53,8 @@ type CryptoSetup interface {
 	// TODO: clean up this interface
 	DiversificationNonce() []byte   // only needed for cryptoSetupServer
 	SetDiversificationNonce([]byte) // only needed for cryptoSetupClient
+	KeyPhase() int                  // only needed for cryptoSetupTLS
+	UpdateKeys() error              // only needed for cryptoSetupTLS
 	ConnectionState() ConnectionState
 
 	GetSealer() (protocol.EncryptionLevel, Sealer)
@@ -55,4 +68,5 @@ type ConnectionState struct {
 	HandshakeComplete bool                // handshake is complete
 	ServerName        string              // server name requested by client, if any (server side only)
 	PeerCertificates  []*x509.Certificate // certificate chain presented by remote peer
+	Used0RTT          bool                // 0-RTT data was accepted by the server (IETF QUIC only)
 }
@@ -0,0 +1,128 @@
+package handshake
+
+import (
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/bifurcation/mint"
+	"github.com/lucas-clemente/quic-go/internal/crypto"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+type sessionTicket struct {
+	psk              mint.PreSharedKey
+	usedForEarlyData bool
+}
+
+// The SessionTicketStore stores the session tickets issued by the server.
+// It is used as mint's PreSharedKeyCache, and it is safe for concurrent use.
+type SessionTicketStore struct {
+	mutex   sync.Mutex
+	tickets map[string]*sessionTicket // indexed by the hex-encoded ticket
+}
+
+var _ mint.PreSharedKeyCache = &SessionTicketStore{}
+
+// NewSessionTicketStore creates a new SessionTicketStore
+func NewSessionTicketStore() *SessionTicketStore {
+	return &SessionTicketStore{tickets: make(map[string]*sessionTicket)}
+}
+
+// Get returns the pre-shared key for a session ticket.
+// Expired tickets are never returned.
+func (s *SessionTicketStore) Get(identity string) (mint.PreSharedKey, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	t, ok := s.tickets[identity]
+	if !ok {
+		return mint.PreSharedKey{}, false
+	}
+	if time.Now().After(t.psk.ExpiresAt) {
+		delete(s.tickets, identity)
+		return mint.PreSharedKey{}, false
+	}
+	return t.psk, true
+}
+
+// Put stores the pre-shared key for a session ticket.
+// When the maximum number of session tickets is reached, expired tickets are deleted.
+// If that doesn't free any space, the ticket isn't stored, and a client using it will perform a full handshake.
+func (s *SessionTicketStore) Put(identity string, psk mint.PreSharedKey) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.tickets) >= protocol.MaxSessionTickets {
+		s.deleteExpiredTickets()
+	}
+	if len(s.tickets) >= protocol.MaxSessionTickets {
+		utils.Debugf("Not storing session ticket. Too many session tickets.")
+		return
+	}
+	s.tickets[identity] = &sessionTicket{psk: psk}
+}
+
+// Size returns the number of stored session tickets
+func (s *SessionTicketStore) Size() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.tickets)
+}
+
+// AcceptEarlyData decides if the 0-RTT data sent along with a ClientHello is accepted.
+// 0-RTT data is accepted if the client offers a valid session ticket, and proves that it knows the pre-shared key.
+// Every session ticket can only be used for 0-RTT once. This prevents replays of the 0-RTT data.
+// If 0-RTT data is accepted, it returns the AEAD used to open the 0-RTT packets.
+func (s *SessionTicketStore) AcceptEarlyData(data []byte) (crypto.AEAD, bool) {
+	clientHello, ch, err := parseClientHello(data)
+	if err != nil {
+		utils.Debugf("Not accepting 0-RTT. Error parsing the ClientHello: %s", err)
+		return nil, false
+	}
+	ext, ok := getPSKExtension(ch)
+	if !ok {
+		return nil, false
+	}
+	truncatedClientHello, err := ch.Truncated()
+	if err != nil {
+		utils.Debugf("Not accepting 0-RTT. Error truncating the ClientHello: %s", err)
+		return nil, false
+	}
+	// mint only verifies the PSK binder when it processes the ClientHello, which happens after this.
+	// Only a ticket with a valid binder can be used for 0-RTT. Otherwise, anyone who observed a
+	// session ticket on the wire could use it up, preventing the legitimate client from using 0-RTT.
+	found, selected, psk, _, err := mint.PSKNegotiation(ext.Identities, ext.Binders, truncatedClientHello, s)
+	if err != nil || !found {
+		utils.Debugf("Not accepting 0-RTT. Unknown or expired session ticket, or invalid binder.")
+		return nil, false
+	}
+
+	s.mutex.Lock()
+	t, ok := s.tickets[hex.EncodeToString(ext.Identities[selected].Identity)]
+	if !ok || t.usedForEarlyData {
+		s.mutex.Unlock()
+		utils.Debugf("Not accepting 0-RTT. Session ticket was already used.")
+		return nil, false
+	}
+	t.usedForEarlyData = true
+	s.mutex.Unlock()
+
+	aead, err := crypto.DeriveEarlyAESKeys(psk, clientHello)
+	if err != nil {
+		utils.Debugf("Not accepting 0-RTT: %s", err)
+		return nil, false
+	}
+	return aead, true
+}
+
+func (s *SessionTicketStore) deleteExpiredTickets() {
+	now := time.Now()
+	for identity, t := range s.tickets {
+		if now.After(t.psk.ExpiresAt) {
+			delete(s.tickets, identity)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package handshake
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/bifurcation/mint"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Session Ticket Store", func() {
+	var store *SessionTicketStore
+
+	BeforeEach(func() {
+		store = NewSessionTicketStore()
+	})
+
+	It("saves session tickets", func() {
+		psk := getTestPSK()
+		store.Put("foobar", *psk)
+		Expect(store.Size()).To(Equal(1))
+		p, ok := store.Get("foobar")
+		Expect(ok).To(BeTrue())
+		Expect(p).To(Equal(*psk))
+	})
+
+	It("returns false for unknown session tickets", func() {
+		_, ok := store.Get("foobar")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("deletes expired session tickets", func() {
+		psk := getTestPSK()
+		psk.ExpiresAt = time.Now().Add(-time.Second)
+		store.Put("foobar", *psk)
+		_, ok := store.Get("foobar")
+		Expect(ok).To(BeFalse())
+		Expect(store.Size()).To(BeZero())
+	})
+
+	Context("limiting the number of session tickets", func() {
+		fill := func(expiresAt time.Time) {
+			psk := getTestPSK()
+			psk.ExpiresAt = expiresAt
+			for i := 0; i < protocol.MaxSessionTickets; i++ {
+				store.Put(strconv.Itoa(i), *psk)
+			}
+			Expect(store.Size()).To(Equal(protocol.MaxSessionTickets))
+		}
+
+		It("doesn't store more than the maximum number of session tickets", func() {
+			fill(time.Now().Add(time.Hour))
+			store.Put("foobar", *getTestPSK())
+			Expect(store.Size()).To(Equal(protocol.MaxSessionTickets))
+			_, ok := store.Get("foobar")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("deletes expired session tickets when the maximum number is reached", func() {
+			fill(time.Now().Add(-time.Second))
+			store.Put("foobar", *getTestPSK())
+			Expect(store.Size()).To(Equal(1))
+			_, ok := store.Get("foobar")
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Context("accepting 0-RTT data", func() {
+		var psk *mint.PreSharedKey
+
+		BeforeEach(func() {
+			psk = getTestPSK()
+			store.Put(hex.EncodeToString(psk.Identity), *psk)
+		})
+
+		It("accepts 0-RTT data for a valid session ticket", func() {
+			aead, ok := store.AcceptEarlyData(getClientHello(psk))
+			Expect(ok).To(BeTrue())
+			Expect(aead).ToNot(BeNil())
+		})
+
+		It("accepts 0-RTT data only once per session ticket", func() {
+			data := getClientHello(psk)
+			_, ok := store.AcceptEarlyData(data)
+			Expect(ok).To(BeTrue())
+			_, ok = store.AcceptEarlyData(data)
+			Expect(ok).To(BeFalse())
+			// the session ticket can still be used for resumption
+			_, ok = store.Get(hex.EncodeToString(psk.Identity))
+			Expect(ok).To(BeTrue())
+		})
+
+		It("doesn't accept 0-RTT data if no session ticket is offered", func() {
+			_, ok := store.AcceptEarlyData(getClientHello(nil))
+			Expect(ok).To(BeFalse())
+		})
+
+		It("doesn't accept 0-RTT data for unknown session tickets", func() {
+			p := getTestPSK()
+			p.Identity = []byte("unknown")
+			_, ok := store.AcceptEarlyData(getClientHello(p))
+			Expect(ok).To(BeFalse())
+		})
+
+		It("doesn't accept 0-RTT data for expired session tickets", func() {
+			psk.ExpiresAt = time.Now().Add(-time.Second)
+			store.Put(hex.EncodeToString(psk.Identity), *psk)
+			_, ok := store.AcceptEarlyData(getClientHello(getTestPSK()))
+			Expect(ok).To(BeFalse())
+		})
+
+		It("doesn't accept 0-RTT data if the PSK binder is invalid", func() {
+			p := getTestPSK()
+			p.Key = bytes.Repeat([]byte{42}, 32)
+			_, ok := store.AcceptEarlyData(getClientHello(p))
+			Expect(ok).To(BeFalse())
+			// the session ticket can still be used for 0-RTT by a client that knows the key
+			_, ok = store.AcceptEarlyData(getClientHello(psk))
+			Expect(ok).To(BeTrue())
+		})
+
+		It("doesn't accept 0-RTT data if the ClientHello can't be parsed", func() {
+			_, ok := store.AcceptEarlyData([]byte("foobar"))
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,28 @@
+package handshake
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// GetStatelessResetToken derives the stateless reset token for a connection ID from a static key.
+// Any server that knows the key can reset a connection, even if it doesn't have any state for it.
+func GetStatelessResetToken(key []byte, connID protocol.ConnectionID) [16]byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(connID))
+	h := hmac.New(sha256.New, key)
+	h.Write(b)
+	var token [16]byte
+	copy(token[:], h.Sum(nil))
+	return token
+}
+
+// NonceProof is the nonce proof sent in a Public Reset.
+// It consists of the first 8 bytes of the stateless reset token,
+// allowing the client to check that the Public Reset was sent by the server.
+func NonceProof(token [16]byte) uint64 {
+	return binary.LittleEndian.Uint64(token[:8])
+}
@@ -0,0 +1,27 @@
+package handshake
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stateless Reset Tokens", func() {
+	It("derives the same token for the same key and connection ID", func() {
+		key := []byte("foobar")
+		Expect(GetStatelessResetToken(key, 0x1337)).To(Equal(GetStatelessResetToken(key, 0x1337)))
+	})
+
+	It("derives different tokens for different connection IDs", func() {
+		key := []byte("foobar")
+		Expect(GetStatelessResetToken(key, 0x1337)).ToNot(Equal(GetStatelessResetToken(key, 0x1338)))
+	})
+
+	It("derives different tokens for different keys", func() {
+		Expect(GetStatelessResetToken([]byte("foo"), 0x1337)).ToNot(Equal(GetStatelessResetToken([]byte("bar"), 0x1337)))
+	})
+
+	It("uses the first 8 bytes of the token as nonce proof", func() {
+		token := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+		Expect(NonceProof(token)).To(Equal(uint64(0x0807060504030201)))
+	})
+})
@@ -29,6 +29,12 @@ const (
 	TagUAID Tag = 'U' + 'A'<<8 + 'I'<<16 + 'D'<<24
 	// TagSVID is the server ID (unofficial tag by us :)
 	TagSVID Tag = 'S' + 'V'<<8 + 'I'<<16 + 'D'<<24
+	// TagMDFS is the maximum DATAGRAM frame size (unofficial tag by us)
+	TagMDFS Tag = 'M' + 'D'<<8 + 'F'<<16 + 'S'<<24
+	// TagMAKD is the maximum ACK delay, in milliseconds (unofficial tag by us)
+	TagMAKD Tag = 'M' + 'A'<<8 + 'K'<<16 + 'D'<<24
+	// TagSRST is the stateless reset token
+	TagSRST Tag = 'S' + 'R'<<8 + 'S'<<16 + 'T'<<24
 	// TagTCID is truncation of the connection ID
 	TagTCID Tag = 'T' + 'C'<<8 + 'I'<<16 + 'D'<<24
 	// TagPDMD is the proof demand
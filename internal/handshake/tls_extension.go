@@ -1,6 +1,8 @@
 package handshake
 
 import (
+	"errors"
+
 	"github.com/bifurcation/mint"
 )
 
@@ -8,6 +10,10 @@ type transportParameterID uint16
 
 const quicTLSExtensionType = 26
 
+// earlyDataExtensionType is the type of the extension that the server sends in the EncryptedExtensions to accept 0-RTT data.
+// We can't use the early_data extension here, since mint would then expect the early data to be sent in TLS records.
+const earlyDataExtensionType = 0xff5c
+
 const (
 	initialMaxStreamDataParameterID   transportParameterID = 0x0
 	initialMaxDataParameterID         transportParameterID = 0x1
@@ -17,6 +23,9 @@ const (
 	maxPacketSizeParameterID          transportParameterID = 0x5
 	statelessResetTokenParameterID    transportParameterID = 0x6
 	initialMaxStreamIDUniParameterID  transportParameterID = 0x8
+	maxAckDelayParameterID            transportParameterID = 0xc
+	maxDatagramFrameSizeParameterID   transportParameterID = 0x20
+	minAckDelayParameterID            transportParameterID = 0xde1a
 )
 
 type transportParameter struct {
@@ -53,3 +62,23 @@ func (e *tlsExtensionBody) Unmarshal(data []byte) (int, error) {
 	e.data = data
 	return len(data), nil
 }
+
+// The earlyDataExtensionBody doesn't carry any data.
+type earlyDataExtensionBody struct{}
+
+var _ mint.ExtensionBody = &earlyDataExtensionBody{}
+
+func (e *earlyDataExtensionBody) Type() mint.ExtensionType {
+	return earlyDataExtensionType
+}
+
+func (e *earlyDataExtensionBody) Marshal() ([]byte, error) {
+	return nil, nil
+}
+
+func (e *earlyDataExtensionBody) Unmarshal(data []byte) (int, error) {
+	if len(data) != 0 {
+		return 0, errors.New("early data extension must be empty")
+	}
+	return 0, nil
+}
@@ -13,11 +13,14 @@ import (
 
 type extensionHandlerClient struct {
 	ourParams  *TransportParameters
+	peerParams *TransportParameters
 	paramsChan chan TransportParameters
 
 	initialVersion    protocol.VersionNumber
 	supportedVersions []protocol.VersionNumber
 	version           protocol.VersionNumber
+
+	earlyDataAccepted bool
 }
 
 var _ mint.AppExtensionHandler = &extensionHandlerClient{}
@@ -76,6 +79,10 @@ func (h *extensionHandlerClient) Receive(hType mint.HandshakeType, el *mint.Exte
 	if !found {
 		return errors.New("EncryptedExtensions message didn't contain a QUIC extension")
 	}
+	h.earlyDataAccepted, err = el.Find(&earlyDataExtensionBody{})
+	if err != nil {
+		return err
+	}
 
 	eetp := &encryptedExtensionsTransportParameters{}
 	if _, err := syntax.Unmarshal(ext.data, eetp); err != nil {
@@ -105,11 +112,7 @@ func (h *extensionHandlerClient) Receive(hType mint.HandshakeType, el *mint.Exte
 	var foundStatelessResetToken bool
 	for _, p := range eetp.Parameters {
 		if p.Parameter == statelessResetTokenParameterID {
-			if len(p.Value) != 16 {
-				return fmt.Errorf("wrong length for stateless_reset_token: %d (expected 16)", len(p.Value))
-			}
 			foundStatelessResetToken = true
-			// TODO: handle this value
 		}
 	}
 	if !foundStatelessResetToken {
@@ -120,6 +123,7 @@ func (h *extensionHandlerClient) Receive(hType mint.HandshakeType, el *mint.Exte
 	if err != nil {
 		return err
 	}
+	h.peerParams = params
 	h.paramsChan <- *params
 	return nil
 }
@@ -127,3 +131,22 @@ func (h *extensionHandlerClient) Receive(hType mint.HandshakeType, el *mint.Exte
 func (h *extensionHandlerClient) GetPeerParams() <-chan TransportParameters {
 	return h.paramsChan
 }
+
+// EarlyDataAccepted says if the server accepted our 0-RTT data.
+// It must only be called after the EncryptedExtensions message was received.
+func (h *extensionHandlerClient) EarlyDataAccepted() bool {
+	return h.earlyDataAccepted
+}
+
+// ReceivedPeerParams returns the parameters received in the EncryptedExtensions message.
+// It must only be called from the goroutine that runs the handshake.
+func (h *extensionHandlerClient) ReceivedPeerParams() (*TransportParameters, error) {
+	return h.peerParams, nil
+}
+
+// RestorePeerParams sends the parameters on the same channel as the parameters received from the server.
+// It blocks until the session reads them.
+func (h *extensionHandlerClient) RestorePeerParams(params *TransportParameters) error {
+	h.paramsChan <- *params
+	return nil
+}
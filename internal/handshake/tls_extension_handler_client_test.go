@@ -100,7 +100,39 @@ var _ = Describe("TLS Extension Handler, for the client", func() {
 			var params TransportParameters
 			Eventually(handler.GetPeerParams()).Should(Receive(&params))
 			Expect(params.StreamFlowControlWindow).To(BeEquivalentTo(0x11223344))
+			Expect(params.StatelessResetToken).To(Equal(&[16]byte{}))
 			Eventually(done).Should(BeClosed())
+			Expect(handler.ReceivedPeerParams()).To(Equal(&params))
+		})
+
+		It("restores TransportParameters", func() {
+			params := &TransportParameters{MaxBidiStreamID: 100}
+			go handler.RestorePeerParams(params)
+			Eventually(handler.GetPeerParams()).Should(Receive(Equal(*params)))
+			Expect(handler.ReceivedPeerParams()).To(BeNil())
+		})
+
+		It("notices when the server accepts 0-RTT data", func() {
+			go func() {
+				defer GinkgoRecover()
+				Expect(el.Add(&earlyDataExtensionBody{})).To(Succeed())
+				addEncryptedExtensionsWithParameters(parameters)
+				err := handler.Receive(mint.HandshakeTypeEncryptedExtensions, &el)
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			Eventually(handler.GetPeerParams()).Should(Receive())
+			Expect(handler.EarlyDataAccepted()).To(BeTrue())
+		})
+
+		It("notices when the server rejects 0-RTT data", func() {
+			go func() {
+				defer GinkgoRecover()
+				addEncryptedExtensionsWithParameters(parameters)
+				err := handler.Receive(mint.HandshakeTypeEncryptedExtensions, &el)
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			Eventually(handler.GetPeerParams()).Should(Receive())
+			Expect(handler.EarlyDataAccepted()).To(BeFalse())
 		})
 
 		It("errors if the EncryptedExtensions message doesn't contain TransportParameters", func() {
@@ -1,7 +1,6 @@
 package handshake
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 
@@ -18,16 +17,22 @@ type extensionHandlerServer struct {
 
 	version           protocol.VersionNumber
 	supportedVersions []protocol.VersionNumber
+
+	acceptEarlyData bool
 }
 
 var _ mint.AppExtensionHandler = &extensionHandlerServer{}
 var _ TLSExtensionHandler = &extensionHandlerServer{}
 
-// NewExtensionHandlerServer creates a new extension handler for the server
+var errPeerParamsOnlyUsedByClient = errors.New("restoring the peer's parameters is only possible for the client")
+
+// NewExtensionHandlerServer creates a new extension handler for the server.
+// If acceptEarlyData is set, it tells the client that its 0-RTT data was accepted.
 func NewExtensionHandlerServer(
 	params *TransportParameters,
 	supportedVersions []protocol.VersionNumber,
 	version protocol.VersionNumber,
+	acceptEarlyData bool,
 ) TLSExtensionHandler {
 	// Processing the ClientHello is performed statelessly (and from a single go-routine).
 	// Therefore, we have to use a buffered chan to pass the transport parameters to that go routine.
@@ -37,6 +42,7 @@ func NewExtensionHandlerServer(
 		paramsChan:        paramsChan,
 		supportedVersions: supportedVersions,
 		version:           version,
+		acceptEarlyData:   acceptEarlyData,
 	}
 }
 
@@ -45,11 +51,7 @@ func (h *extensionHandlerServer) Send(hType mint.HandshakeType, el *mint.Extensi
 		return nil
 	}
 
-	transportParams := append(
-		h.ourParams.getTransportParameters(),
-		// TODO(#855): generate a real token
-		transportParameter{statelessResetTokenParameterID, bytes.Repeat([]byte{42}, 16)},
-	)
+	transportParams := h.ourParams.getTransportParameters()
 	supportedVersions := protocol.GetGreasedVersions(h.supportedVersions)
 	versions := make([]uint32, len(supportedVersions))
 	for i, v := range supportedVersions {
@@ -63,6 +65,11 @@ func (h *extensionHandlerServer) Send(hType mint.HandshakeType, el *mint.Extensi
 	if err != nil {
 		return err
 	}
+	if h.acceptEarlyData {
+		if err := el.Add(&earlyDataExtensionBody{}); err != nil {
+			return err
+		}
+	}
 	return el.Add(&tlsExtensionBody{data})
 }
 
@@ -113,3 +120,15 @@ func (h *extensionHandlerServer) Receive(hType mint.HandshakeType, el *mint.Exte
 func (h *extensionHandlerServer) GetPeerParams() <-chan TransportParameters {
 	return h.paramsChan
 }
+
+func (h *extensionHandlerServer) EarlyDataAccepted() bool {
+	return h.acceptEarlyData
+}
+
+func (h *extensionHandlerServer) ReceivedPeerParams() (*TransportParameters, error) {
+	return nil, errPeerParamsOnlyUsedByClient
+}
+
+func (h *extensionHandlerServer) RestorePeerParams(*TransportParameters) error {
+	return errPeerParamsOnlyUsedByClient
+}
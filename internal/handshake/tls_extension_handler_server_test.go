@@ -25,7 +25,7 @@ var _ = Describe("TLS Extension Handler, for the server", func() {
 	)
 
 	BeforeEach(func() {
-		handler = NewExtensionHandlerServer(&TransportParameters{}, nil, protocol.VersionWhatever).(*extensionHandlerServer)
+		handler = NewExtensionHandlerServer(&TransportParameters{}, nil, protocol.VersionWhatever, false).(*extensionHandlerServer)
 		el = make(mint.ExtensionList, 0)
 	})
 
@@ -61,6 +61,40 @@ var _ = Describe("TLS Extension Handler, for the server", func() {
 				Expect(eetp.SupportedVersions).To(ContainElement(uint32(version)))
 			}
 		})
+
+		It("accepts 0-RTT data", func() {
+			handler.acceptEarlyData = true
+			err := handler.Send(mint.HandshakeTypeEncryptedExtensions, &el)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(el).To(HaveLen(2))
+			found, err := el.Find(&earlyDataExtensionBody{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeTrue())
+			Expect(handler.EarlyDataAccepted()).To(BeTrue())
+		})
+
+		It("doesn't accept 0-RTT data by default", func() {
+			err := handler.Send(mint.HandshakeTypeEncryptedExtensions, &el)
+			Expect(err).ToNot(HaveOccurred())
+			found, err := el.Find(&earlyDataExtensionBody{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(found).To(BeFalse())
+			Expect(handler.EarlyDataAccepted()).To(BeFalse())
+		})
+
+		It("sends the stateless reset token", func() {
+			token := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+			handler.ourParams.StatelessResetToken = &token
+			err := handler.Send(mint.HandshakeTypeEncryptedExtensions, &el)
+			Expect(err).ToNot(HaveOccurred())
+			ext := &tlsExtensionBody{}
+			_, err = el.Find(ext)
+			Expect(err).ToNot(HaveOccurred())
+			eetp := &encryptedExtensionsTransportParameters{}
+			_, err = syntax.Unmarshal(ext.data, eetp)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(eetp.Parameters).To(ContainElement(transportParameter{statelessResetTokenParameterID, token[:]}))
+		})
 	})
 
 	Context("receiving", func() {
@@ -165,4 +199,10 @@ var _ = Describe("TLS Extension Handler, for the server", func() {
 			})
 		})
 	})
+
+	It("errors when asked for the parameters received from a previous connection", func() {
+		_, err := handler.ReceivedPeerParams()
+		Expect(err).To(MatchError(errPeerParamsOnlyUsedByClient))
+		Expect(handler.RestorePeerParams(&TransportParameters{})).To(MatchError(errPeerParamsOnlyUsedByClient))
+	})
 })
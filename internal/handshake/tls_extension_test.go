@@ -30,3 +30,23 @@ var _ = Describe("TLS extension body", func() {
 		Expect(data).To(Equal([]byte("foo")))
 	})
 })
+
+var _ = Describe("early data extension body", func() {
+	It("has the right TLS extension type", func() {
+		Expect((&earlyDataExtensionBody{}).Type()).To(BeEquivalentTo(earlyDataExtensionType))
+	})
+
+	It("is empty", func() {
+		data, err := (&earlyDataExtensionBody{}).Marshal()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(BeEmpty())
+		n, err := (&earlyDataExtensionBody{}).Unmarshal(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(BeZero())
+	})
+
+	It("errors if it's not empty", func() {
+		_, err := (&earlyDataExtensionBody{}).Unmarshal([]byte("foobar"))
+		Expect(err).To(MatchError("early data extension must be empty"))
+	})
+})
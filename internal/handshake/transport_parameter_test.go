@@ -1,6 +1,7 @@
 package handshake
 
 import (
+	"bytes"
 	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
@@ -25,6 +26,53 @@ var _ = Describe("Transport Parameters", func() {
 				Expect(params.IdleTimeout).To(Equal(time.Duration(0xbaadf00d) * time.Second))
 				Expect(params.MaxStreams).To(Equal(uint32(0xc00010ff)))
 				Expect(params.OmitConnectionID).To(BeFalse())
+				Expect(params.MaxDatagramFrameSize).To(BeZero())
+			})
+
+			It("reads the maximum DATAGRAM frame size", func() {
+				values := map[Tag][]byte{TagMDFS: {0x37, 0x13, 0, 0}}
+				params, err := readHelloMap(values)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(params.MaxDatagramFrameSize).To(Equal(protocol.ByteCount(0x1337)))
+			})
+
+			It("errors when given an invalid MDFS value", func() {
+				values := map[Tag][]byte{TagMDFS: {2, 0, 0}} // 1 byte too short
+				_, err := readHelloMap(values)
+				Expect(err).To(MatchError(errMalformedTag))
+			})
+
+			It("reads the maximum ACK delay", func() {
+				values := map[Tag][]byte{TagMAKD: {42, 0, 0, 0}}
+				params, err := readHelloMap(values)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(params.MaxAckDelay).To(Equal(42 * time.Millisecond))
+			})
+
+			It("errors when given an invalid MAKD value", func() {
+				values := map[Tag][]byte{TagMAKD: {2, 0, 0}} // 1 byte too short
+				_, err := readHelloMap(values)
+				Expect(err).To(MatchError(errMalformedTag))
+			})
+
+			It("errors when the maximum ACK delay is too large", func() {
+				values := map[Tag][]byte{TagMAKD: {0, 0x40, 0, 0}} // 2^14 ms
+				_, err := readHelloMap(values)
+				Expect(err).To(MatchError("InvalidCryptoMessageParameter: invalid value for max_ack_delay: 16.384s (maximum 16.383s)"))
+			})
+
+			It("reads the stateless reset token", func() {
+				token := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+				values := map[Tag][]byte{TagSRST: token[:]}
+				params, err := readHelloMap(values)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(params.StatelessResetToken).To(Equal(&token))
+			})
+
+			It("errors when given an invalid SRST value", func() {
+				values := map[Tag][]byte{TagSRST: bytes.Repeat([]byte{0}, 15)} // 1 byte too short
+				_, err := readHelloMap(values)
+				Expect(err).To(MatchError(errMalformedTag))
 			})
 
 			It("reads if the connection ID should be omitted", func() {
@@ -87,6 +135,8 @@ var _ = Describe("Transport Parameters", func() {
 				entryMap := params.getHelloMap()
 				Expect(entryMap).To(HaveLen(4))
 				Expect(entryMap).ToNot(HaveKey(TagTCID))
+				Expect(entryMap).ToNot(HaveKey(TagMDFS))
+				Expect(entryMap).ToNot(HaveKey(TagMAKD))
 				Expect(entryMap).To(HaveKeyWithValue(TagSFCW, []byte{0xef, 0xbe, 0xad, 0xde}))
 				Expect(entryMap).To(HaveKeyWithValue(TagCFCW, []byte{0xad, 0xfb, 0xca, 0xde}))
 				Expect(entryMap).To(HaveKeyWithValue(TagICSL, []byte{0xad, 0xaa, 0xaa, 0xba}))
@@ -98,6 +148,25 @@ var _ = Describe("Transport Parameters", func() {
 				entryMap := params.getHelloMap()
 				Expect(entryMap).To(HaveKeyWithValue(TagTCID, []byte{0, 0, 0, 0}))
 			})
+
+			It("sends the maximum DATAGRAM frame size", func() {
+				params := &TransportParameters{MaxDatagramFrameSize: 0x1337}
+				entryMap := params.getHelloMap()
+				Expect(entryMap).To(HaveKeyWithValue(TagMDFS, []byte{0x37, 0x13, 0, 0}))
+			})
+
+			It("sends the maximum ACK delay", func() {
+				params := &TransportParameters{MaxAckDelay: 1500 * time.Microsecond} // rounded up to 2 ms
+				entryMap := params.getHelloMap()
+				Expect(entryMap).To(HaveKeyWithValue(TagMAKD, []byte{2, 0, 0, 0}))
+			})
+
+			It("sends the stateless reset token", func() {
+				token := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+				params := &TransportParameters{StatelessResetToken: &token}
+				entryMap := params.getHelloMap()
+				Expect(entryMap).To(HaveKeyWithValue(TagSRST, token[:]))
+			})
 		})
 	})
 
@@ -131,6 +200,89 @@ var _ = Describe("Transport Parameters", func() {
 				Expect(params.MaxUniStreamID).To(Equal(protocol.StreamID(0x44556677)))
 				Expect(params.IdleTimeout).To(Equal(0x1337 * time.Second))
 				Expect(params.OmitConnectionID).To(BeFalse())
+				Expect(params.MaxDatagramFrameSize).To(BeZero())
+				Expect(params.MaxAckDelay).To(BeZero())
+				Expect(params.MinAckDelay).To(BeZero())
+			})
+
+			It("reads the maximum DATAGRAM frame size", func() {
+				parameters[maxDatagramFrameSizeParameterID] = []byte{0x5, 0xac}
+				params, err := readTransportParamters(paramsMapToList(parameters))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(params.MaxDatagramFrameSize).To(Equal(protocol.ByteCount(0x5ac)))
+			})
+
+			It("reads the stateless reset token", func() {
+				token := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+				parameters[statelessResetTokenParameterID] = token[:]
+				params, err := readTransportParamters(paramsMapToList(parameters))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(params.StatelessResetToken).To(Equal(&token))
+			})
+
+			It("rejects the parameters if stateless_reset_token has the wrong length", func() {
+				parameters[statelessResetTokenParameterID] = bytes.Repeat([]byte{0}, 17)
+				_, err := readTransportParamters(paramsMapToList(parameters))
+				Expect(err).To(MatchError("wrong length for stateless_reset_token: 17 (expected 16)"))
+			})
+
+			It("reads the max_packet_size", func() {
+				parameters[maxPacketSizeParameterID] = []byte{0x5, 0xac}
+				params, err := readTransportParamters(paramsMapToList(parameters))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(params.MaxPacketSize).To(Equal(protocol.ByteCount(0x5ac)))
+			})
+
+			It("rejects the parameters if max_packet_size has the wrong length", func() {
+				parameters[maxPacketSizeParameterID] = []byte{0x11, 0x22, 0x33} // should be 2 bytes
+				_, err := readTransportParamters(paramsMapToList(parameters))
+				Expect(err).To(MatchError("wrong length for max_packet_size: 3 (expected 2)"))
+			})
+
+			It("rejects a max_packet_size smaller than 1200 bytes", func() {
+				parameters[maxPacketSizeParameterID] = []byte{0x4, 0xaf} // 1199
+				_, err := readTransportParamters(paramsMapToList(parameters))
+				Expect(err).To(MatchError("invalid value for max_packet_size: 1199 (minimum 1200)"))
+			})
+
+			It("rejects the parameters if max_datagram_frame_size has the wrong length", func() {
+				parameters[maxDatagramFrameSizeParameterID] = []byte{0x11, 0x22, 0x33} // should be 2 bytes
+				_, err := readTransportParamters(paramsMapToList(parameters))
+				Expect(err).To(MatchError("wrong length for max_datagram_frame_size: 3 (expected 2)"))
+			})
+
+			It("reads the max_ack_delay and min_ack_delay", func() {
+				parameters[maxAckDelayParameterID] = []byte{0, 42}
+				parameters[minAckDelayParameterID] = []byte{0, 0, 0x3, 0xe8} // 1000 microseconds
+				params, err := readTransportParamters(paramsMapToList(parameters))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(params.MaxAckDelay).To(Equal(42 * time.Millisecond))
+				Expect(params.MinAckDelay).To(Equal(time.Millisecond))
+			})
+
+			It("rejects the parameters if max_ack_delay has the wrong length", func() {
+				parameters[maxAckDelayParameterID] = []byte{0x11, 0x22, 0x33} // should be 2 bytes
+				_, err := readTransportParamters(paramsMapToList(parameters))
+				Expect(err).To(MatchError("wrong length for max_ack_delay: 3 (expected 2)"))
+			})
+
+			It("rejects the parameters if min_ack_delay has the wrong length", func() {
+				parameters[minAckDelayParameterID] = []byte{0x11, 0x22, 0x33} // should be 4 bytes
+				_, err := readTransportParamters(paramsMapToList(parameters))
+				Expect(err).To(MatchError("wrong length for min_ack_delay: 3 (expected 4)"))
+			})
+
+			It("rejects a max_ack_delay of 2^14 milliseconds or more", func() {
+				parameters[maxAckDelayParameterID] = []byte{0x40, 0}
+				_, err := readTransportParamters(paramsMapToList(parameters))
+				Expect(err).To(MatchError("invalid value for max_ack_delay: 16.384s (maximum 16.383s)"))
+			})
+
+			It("rejects a min_ack_delay larger than the max_ack_delay", func() {
+				parameters[maxAckDelayParameterID] = []byte{0, 1}
+				parameters[minAckDelayParameterID] = []byte{0, 0, 0x3, 0xe9} // 1001 microseconds
+				_, err := readTransportParamters(paramsMapToList(parameters))
+				Expect(err).To(MatchError("min_ack_delay (1.001ms) is larger than max_ack_delay (1ms)"))
 			})
 
 			It("saves if it should omit the connection ID", func() {
@@ -247,6 +399,27 @@ var _ = Describe("Transport Parameters", func() {
 				values := paramsListToMap(params.getTransportParameters())
 				Expect(values).To(HaveKeyWithValue(omitConnectionIDParameterID, []byte{}))
 			})
+
+			It("sends the maximum DATAGRAM frame size", func() {
+				params.MaxDatagramFrameSize = 0x5ac
+				values := paramsListToMap(params.getTransportParameters())
+				Expect(values).To(HaveKeyWithValue(maxDatagramFrameSizeParameterID, []byte{0x5, 0xac}))
+			})
+
+			It("sends the max_ack_delay and min_ack_delay", func() {
+				params.MaxAckDelay = 1500 * time.Microsecond // rounded up to 2 ms
+				params.MinAckDelay = time.Millisecond
+				values := paramsListToMap(params.getTransportParameters())
+				Expect(values).To(HaveKeyWithValue(maxAckDelayParameterID, []byte{0, 2}))
+				Expect(values).To(HaveKeyWithValue(minAckDelayParameterID, []byte{0, 0, 0x3, 0xe8}))
+			})
+
+			It("sends the stateless reset token", func() {
+				token := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+				params.StatelessResetToken = &token
+				values := paramsListToMap(params.getTransportParameters())
+				Expect(values).To(HaveKeyWithValue(statelessResetTokenParameterID, token[:]))
+			})
 		})
 	})
 })
@@ -26,6 +26,26 @@ type TransportParameters struct {
 
 	OmitConnectionID bool
 	IdleTimeout      time.Duration
+
+	// MaxPacketSize is the maximum size of packets that the peer is willing to receive.
+	// A value of 0 means that the peer didn't announce a limit.
+	// It is only used for IETF QUIC.
+	MaxPacketSize protocol.ByteCount
+
+	// MaxDatagramFrameSize is the maximum size of DATAGRAM frames that will be accepted.
+	// A value of 0 means that DATAGRAM frames are not supported.
+	MaxDatagramFrameSize protocol.ByteCount
+
+	// MaxAckDelay is the maximum time that the peer delays sending an ACK.
+	// A value of 0 means that the peer didn't announce it.
+	MaxAckDelay time.Duration
+	// MinAckDelay is the smallest maximum ACK delay that can be requested using an ACK_FREQUENCY frame.
+	// A value of 0 means that ACK_FREQUENCY frames are not supported.
+	// It is only used for IETF QUIC.
+	MinAckDelay time.Duration
+
+	// StatelessResetToken is only sent by the server
+	StatelessResetToken *[16]byte
 }
 
 // readHelloMap reads the transport parameters from the tags sent in a gQUIC handshake message
@@ -66,6 +86,31 @@ func readHelloMap(tags map[Tag][]byte) (*TransportParameters, error) {
 		}
 		params.ConnectionFlowControlWindow = protocol.ByteCount(v)
 	}
+	if value, ok := tags[TagMDFS]; ok {
+		v, err := utils.LittleEndian.ReadUint32(bytes.NewBuffer(value))
+		if err != nil {
+			return nil, errMalformedTag
+		}
+		params.MaxDatagramFrameSize = protocol.ByteCount(v)
+	}
+	if value, ok := tags[TagMAKD]; ok {
+		v, err := utils.LittleEndian.ReadUint32(bytes.NewBuffer(value))
+		if err != nil {
+			return nil, errMalformedTag
+		}
+		params.MaxAckDelay = time.Duration(v) * time.Millisecond
+	}
+	if err := params.validateAckDelays(); err != nil {
+		return nil, qerr.Error(qerr.InvalidCryptoMessageParameter, err.Error())
+	}
+	if value, ok := tags[TagSRST]; ok {
+		if len(value) != 16 {
+			return nil, errMalformedTag
+		}
+		var token [16]byte
+		copy(token[:], value)
+		params.StatelessResetToken = &token
+	}
 	return params, nil
 }
 
@@ -89,6 +134,19 @@ func (p *TransportParameters) getHelloMap() map[Tag][]byte {
 	if p.OmitConnectionID {
 		tags[TagTCID] = []byte{0, 0, 0, 0}
 	}
+	if p.MaxDatagramFrameSize > 0 {
+		mdfs := bytes.NewBuffer([]byte{})
+		utils.LittleEndian.WriteUint32(mdfs, uint32(p.MaxDatagramFrameSize))
+		tags[TagMDFS] = mdfs.Bytes()
+	}
+	if p.MaxAckDelay > 0 {
+		makd := bytes.NewBuffer([]byte{})
+		utils.LittleEndian.WriteUint32(makd, uint32(p.encodedMaxAckDelay()))
+		tags[TagMAKD] = makd.Bytes()
+	}
+	if p.StatelessResetToken != nil {
+		tags[TagSRST] = p.StatelessResetToken[:]
+	}
 	return tags
 }
 
@@ -137,15 +195,64 @@ func readTransportParamters(paramsList []transportParameter) (*TransportParamete
 				return nil, fmt.Errorf("wrong length for omit_connection_id: %d (expected empty)", len(p.Value))
 			}
 			params.OmitConnectionID = true
+		case maxPacketSizeParameterID:
+			if len(p.Value) != 2 {
+				return nil, fmt.Errorf("wrong length for max_packet_size: %d (expected 2)", len(p.Value))
+			}
+			maxPacketSize := protocol.ByteCount(binary.BigEndian.Uint16(p.Value))
+			if maxPacketSize < protocol.InitialPacketSize {
+				return nil, fmt.Errorf("invalid value for max_packet_size: %d (minimum %d)", maxPacketSize, protocol.InitialPacketSize)
+			}
+			params.MaxPacketSize = maxPacketSize
+		case maxDatagramFrameSizeParameterID:
+			if len(p.Value) != 2 {
+				return nil, fmt.Errorf("wrong length for max_datagram_frame_size: %d (expected 2)", len(p.Value))
+			}
+			params.MaxDatagramFrameSize = protocol.ByteCount(binary.BigEndian.Uint16(p.Value))
+		case maxAckDelayParameterID:
+			if len(p.Value) != 2 {
+				return nil, fmt.Errorf("wrong length for max_ack_delay: %d (expected 2)", len(p.Value))
+			}
+			params.MaxAckDelay = time.Duration(binary.BigEndian.Uint16(p.Value)) * time.Millisecond
+		case minAckDelayParameterID:
+			if len(p.Value) != 4 {
+				return nil, fmt.Errorf("wrong length for min_ack_delay: %d (expected 4)", len(p.Value))
+			}
+			params.MinAckDelay = time.Duration(binary.BigEndian.Uint32(p.Value)) * time.Microsecond
+		case statelessResetTokenParameterID:
+			if len(p.Value) != 16 {
+				return nil, fmt.Errorf("wrong length for stateless_reset_token: %d (expected 16)", len(p.Value))
+			}
+			var token [16]byte
+			copy(token[:], p.Value)
+			params.StatelessResetToken = &token
 		}
 	}
 
 	if !(foundInitialMaxStreamData && foundInitialMaxData && foundIdleTimeout) {
 		return nil, errors.New("missing parameter")
 	}
+	if err := params.validateAckDelays(); err != nil {
+		return nil, err
+	}
 	return params, nil
 }
 
+func (p *TransportParameters) validateAckDelays() error {
+	if p.MaxAckDelay > protocol.MaxMaxAckDelay {
+		return fmt.Errorf("invalid value for max_ack_delay: %s (maximum %s)", p.MaxAckDelay, protocol.MaxMaxAckDelay)
+	}
+	if p.MaxAckDelay > 0 && p.MinAckDelay > p.MaxAckDelay {
+		return fmt.Errorf("min_ack_delay (%s) is larger than max_ack_delay (%s)", p.MinAckDelay, p.MaxAckDelay)
+	}
+	return nil
+}
+
+// encodedMaxAckDelay is the maximum ACK delay in milliseconds, rounded up
+func (p *TransportParameters) encodedMaxAckDelay() uint64 {
+	return uint64((p.MaxAckDelay + time.Millisecond - 1) / time.Millisecond)
+}
+
 // GetTransportParameters gets the parameters needed for the TLS handshake.
 // It doesn't send the initial_max_stream_id_uni parameter, so the peer isn't allowed to open any unidirectional streams.
 func (p *TransportParameters) getTransportParameters() []transportParameter {
@@ -172,5 +279,23 @@ func (p *TransportParameters) getTransportParameters() []transportParameter {
 	if p.OmitConnectionID {
 		params = append(params, transportParameter{omitConnectionIDParameterID, []byte{}})
 	}
+	if p.MaxDatagramFrameSize > 0 {
+		maxDatagramFrameSize := make([]byte, 2)
+		binary.BigEndian.PutUint16(maxDatagramFrameSize, uint16(p.MaxDatagramFrameSize))
+		params = append(params, transportParameter{maxDatagramFrameSizeParameterID, maxDatagramFrameSize})
+	}
+	if p.MaxAckDelay > 0 {
+		maxAckDelay := make([]byte, 2)
+		binary.BigEndian.PutUint16(maxAckDelay, uint16(p.encodedMaxAckDelay()))
+		params = append(params, transportParameter{maxAckDelayParameterID, maxAckDelay})
+	}
+	if p.MinAckDelay > 0 {
+		minAckDelay := make([]byte, 4)
+		binary.BigEndian.PutUint32(minAckDelay, uint32(p.MinAckDelay/time.Microsecond))
+		params = append(params, transportParameter{minAckDelayParameterID, minAckDelay})
+	}
+	if p.StatelessResetToken != nil {
+		params = append(params, transportParameter{statelessResetTokenParameterID, p.StatelessResetToken[:]})
+	}
 	return params
 }
@@ -0,0 +1,138 @@
+package handshake
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/crypto"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// The updatableAEAD is the AEAD used for 1-RTT packets in IETF QUIC.
+// It performs key updates, which are signaled by the KeyPhase bit of the Short Header.
+// It is not safe for concurrent use.
+type updatableAEAD struct {
+	keyUpdater crypto.KeyUpdater
+
+	keyPhase int
+	current  crypto.AEAD
+	next     crypto.AEAD
+	// previous is used to open packets that were reordered across a key update
+	previous       crypto.AEAD
+	previousExpiry time.Time // zero until the peer starts using the current keys
+
+	// set as soon as a packet protected with the current keys was received
+	rcvdWithCurrentKey      bool
+	firstRcvdWithCurrentKey protocol.PacketNumber
+	numSealedWithCurrentKey uint64
+
+	keyUpdateInterval uint64
+	updateRequested   bool
+}
+
+var _ Sealer = &updatableAEAD{}
+
+func newUpdatableAEAD(aead crypto.AEAD, keyUpdater crypto.KeyUpdater, keyUpdateInterval uint64) (*updatableAEAD, error) {
+	next, err := keyUpdater.Next()
+	if err != nil {
+		return nil, err
+	}
+	return &updatableAEAD{
+		keyUpdater:        keyUpdater,
+		current:           aead,
+		next:              next,
+		keyUpdateInterval: keyUpdateInterval,
+	}, nil
+}
+
+// Open opens a packet that was sent with the given key phase.
+// A packet sent with a different key phase is either a packet that was reordered across a key update,
+// or a packet sent after the peer initiated a key update.
+func (u *updatableAEAD) Open(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte, keyPhase int) ([]byte, error) {
+	if u.previous != nil && !u.previousExpiry.IsZero() && time.Now().After(u.previousExpiry) {
+		u.previous = nil
+	}
+	if keyPhase == u.keyPhase {
+		data, err := u.current.Open(dst, src, packetNumber, associatedData)
+		if err != nil {
+			return nil, err
+		}
+		if !u.rcvdWithCurrentKey {
+			u.receivedWithCurrentKey(packetNumber)
+		}
+		return data, nil
+	}
+	if u.previous != nil && (!u.rcvdWithCurrentKey || packetNumber < u.firstRcvdWithCurrentKey) {
+		return u.previous.Open(dst, src, packetNumber, associatedData)
+	}
+	// The peer initiated a key update.
+	// Only roll the keys if the packet can be decrypted, so that a forged packet can't trigger a key update.
+	data, err := u.next.Open(dst, src, packetNumber, associatedData)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.rollKeys(); err != nil {
+		return nil, err
+	}
+	utils.Infof("Peer updated keys. Key phase: %d", u.keyPhase)
+	u.receivedWithCurrentKey(packetNumber)
+	return data, nil
+}
+
+func (u *updatableAEAD) receivedWithCurrentKey(packetNumber protocol.PacketNumber) {
+	u.rcvdWithCurrentKey = true
+	u.firstRcvdWithCurrentKey = packetNumber
+	if u.previous != nil {
+		u.previousExpiry = time.Now().Add(protocol.KeyPhaseRetentionTime)
+	}
+}
+
+func (u *updatableAEAD) Seal(dst, src []byte, packetNumber protocol.PacketNumber, associatedData []byte) []byte {
+	u.numSealedWithCurrentKey++
+	return u.current.Seal(dst, src, packetNumber, associatedData)
+}
+
+func (u *updatableAEAD) Overhead() int {
+	return u.current.Overhead()
+}
+
+// KeyPhase is the key phase of the packets sealed by this AEAD.
+func (u *updatableAEAD) KeyPhase() int {
+	return u.keyPhase
+}
+
+// RequestKeyUpdate requests a key update.
+// The update is initiated by the next call to MaybeInitiateKeyUpdate that is allowed to initiate it.
+func (u *updatableAEAD) RequestKeyUpdate() {
+	u.updateRequested = true
+}
+
+// MaybeInitiateKeyUpdate initiates a key update, if one was requested, or if enough packets were sealed with the current keys.
+// A new key update can only be initiated after the peer started using the current keys.
+func (u *updatableAEAD) MaybeInitiateKeyUpdate() error {
+	if !u.rcvdWithCurrentKey {
+		return nil
+	}
+	if !u.updateRequested && (u.keyUpdateInterval == 0 || u.numSealedWithCurrentKey < u.keyUpdateInterval) {
+		return nil
+	}
+	if err := u.rollKeys(); err != nil {
+		return err
+	}
+	u.updateRequested = false
+	utils.Infof("Initiated key update. Key phase: %d", u.keyPhase)
+	return nil
+}
+
+func (u *updatableAEAD) rollKeys() error {
+	next, err := u.keyUpdater.Next()
+	if err != nil {
+		return err
+	}
+	u.previous, u.current, u.next = u.current, u.next, next
+	u.previousExpiry = time.Time{}
+	u.keyPhase ^= 1
+	u.rcvdWithCurrentKey = false
+	u.numSealedWithCurrentKey = 0
+	return nil
+}
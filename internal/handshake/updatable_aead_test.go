@@ -0,0 +1,146 @@
+package handshake
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/crypto"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// the testKeyUpdater derives AES-GCM keys that only depend on the key phase
+type testKeyUpdater struct {
+	perspective protocol.Perspective
+	generation  byte
+}
+
+func (u *testKeyUpdater) Next() (crypto.AEAD, error) {
+	u.generation++
+	return newTestAEAD(u.perspective, u.generation)
+}
+
+func newTestAEAD(pers protocol.Perspective, generation byte) (crypto.AEAD, error) {
+	clientKey := bytes.Repeat([]byte{generation}, 16)
+	serverKey := bytes.Repeat([]byte{generation + 0x80}, 16)
+	iv := make([]byte, 12)
+	if pers == protocol.PerspectiveClient {
+		return crypto.NewAEADAESGCM(serverKey, clientKey, iv, iv)
+	}
+	return crypto.NewAEADAESGCM(clientKey, serverKey, iv, iv)
+}
+
+var _ = Describe("Updatable AEAD", func() {
+	var client, server *updatableAEAD
+
+	newUpdatableTestAEAD := func(pers protocol.Perspective, interval uint64) *updatableAEAD {
+		aead, err := newTestAEAD(pers, 0)
+		Expect(err).ToNot(HaveOccurred())
+		u, err := newUpdatableAEAD(aead, &testKeyUpdater{perspective: pers}, interval)
+		Expect(err).ToNot(HaveOccurred())
+		return u
+	}
+
+	BeforeEach(func() {
+		client = newUpdatableTestAEAD(protocol.PerspectiveClient, 0)
+		server = newUpdatableTestAEAD(protocol.PerspectiveServer, 0)
+	})
+
+	// send seals a packet and lets the receiver open it, using the key phase of the sender
+	send := func(sender, receiver *updatableAEAD, pn protocol.PacketNumber) error {
+		sealed := sender.Seal(nil, []byte("foobar"), pn, []byte("ad"))
+		data, err := receiver.Open(nil, sealed, pn, []byte("ad"), sender.KeyPhase())
+		if err != nil {
+			return err
+		}
+		Expect(data).To(Equal([]byte("foobar")))
+		return nil
+	}
+
+	It("opens and seals packets", func() {
+		Expect(send(client, server, 1)).To(Succeed())
+		Expect(send(server, client, 1)).To(Succeed())
+		Expect(client.KeyPhase()).To(BeZero())
+		Expect(server.KeyPhase()).To(BeZero())
+	})
+
+	It("doesn't initiate a key update before a packet was received with the current keys", func() {
+		client.RequestKeyUpdate()
+		Expect(client.MaybeInitiateKeyUpdate()).To(Succeed())
+		Expect(client.KeyPhase()).To(BeZero())
+	})
+
+	It("initiates a key update, and the peer follows", func() {
+		Expect(send(server, client, 1)).To(Succeed())
+		client.RequestKeyUpdate()
+		Expect(client.MaybeInitiateKeyUpdate()).To(Succeed())
+		Expect(client.KeyPhase()).To(Equal(1))
+		Expect(send(client, server, 2)).To(Succeed())
+		Expect(server.KeyPhase()).To(Equal(1))
+		Expect(send(server, client, 2)).To(Succeed())
+	})
+
+	It("only initiates the next key update after the peer used the current keys", func() {
+		Expect(send(server, client, 1)).To(Succeed())
+		client.RequestKeyUpdate()
+		Expect(client.MaybeInitiateKeyUpdate()).To(Succeed())
+		Expect(client.KeyPhase()).To(Equal(1))
+		client.RequestKeyUpdate()
+		Expect(client.MaybeInitiateKeyUpdate()).To(Succeed())
+		Expect(client.KeyPhase()).To(Equal(1))
+		Expect(send(client, server, 2)).To(Succeed())
+		Expect(send(server, client, 2)).To(Succeed())
+		Expect(client.MaybeInitiateKeyUpdate()).To(Succeed())
+		Expect(client.KeyPhase()).To(BeZero())
+		Expect(send(client, server, 3)).To(Succeed())
+		Expect(server.KeyPhase()).To(BeZero())
+	})
+
+	It("initiates a key update after sending the configured number of packets", func() {
+		client = newUpdatableTestAEAD(protocol.PerspectiveClient, 3)
+		Expect(send(server, client, 1)).To(Succeed())
+		for pn := protocol.PacketNumber(1); pn <= 2; pn++ {
+			client.Seal(nil, []byte("foobar"), pn, nil)
+			Expect(client.MaybeInitiateKeyUpdate()).To(Succeed())
+			Expect(client.KeyPhase()).To(BeZero())
+		}
+		client.Seal(nil, []byte("foobar"), 3, nil)
+		Expect(client.MaybeInitiateKeyUpdate()).To(Succeed())
+		Expect(client.KeyPhase()).To(Equal(1))
+	})
+
+	It("opens packets that were reordered across a key update", func() {
+		Expect(send(server, client, 1)).To(Succeed())
+		reordered := client.Seal(nil, []byte("foobar"), 2, []byte("ad"))
+		client.RequestKeyUpdate()
+		Expect(client.MaybeInitiateKeyUpdate()).To(Succeed())
+		Expect(send(client, server, 3)).To(Succeed())
+		Expect(server.KeyPhase()).To(Equal(1))
+		data, err := server.Open(nil, reordered, 2, []byte("ad"), 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal([]byte("foobar")))
+		Expect(server.KeyPhase()).To(Equal(1))
+	})
+
+	It("drops the previous keys after they expired", func() {
+		Expect(send(server, client, 1)).To(Succeed())
+		reordered := client.Seal(nil, []byte("foobar"), 2, []byte("ad"))
+		client.RequestKeyUpdate()
+		Expect(client.MaybeInitiateKeyUpdate()).To(Succeed())
+		Expect(send(client, server, 3)).To(Succeed())
+		Expect(server.previousExpiry).To(BeTemporally("~", time.Now().Add(protocol.KeyPhaseRetentionTime), time.Second))
+		server.previousExpiry = time.Now().Add(-time.Millisecond)
+		_, err := server.Open(nil, reordered, 2, []byte("ad"), 0)
+		Expect(err).To(HaveOccurred())
+		Expect(server.previous).To(BeNil())
+	})
+
+	It("doesn't update the keys when a packet can't be decrypted", func() {
+		_, err := server.Open(nil, []byte("forged packet with a flipped key phase"), 1, []byte("ad"), 1)
+		Expect(err).To(HaveOccurred())
+		Expect(server.KeyPhase()).To(BeZero())
+		Expect(send(client, server, 2)).To(Succeed())
+	})
+})
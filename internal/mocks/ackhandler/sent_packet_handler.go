@@ -49,6 +49,18 @@ func (mr *MockSentPacketHandlerMockRecorder) DequeuePacketForRetransmission() *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DequeuePacketForRetransmission", reflect.TypeOf((*MockSentPacketHandler)(nil).DequeuePacketForRetransmission))
 }
 
+// ECNMode mocks base method
+func (m *MockSentPacketHandler) ECNMode() protocol.ECN {
+	ret := m.ctrl.Call(m, "ECNMode")
+	ret0, _ := ret[0].(protocol.ECN)
+	return ret0
+}
+
+// ECNMode indicates an expected call of ECNMode
+func (mr *MockSentPacketHandlerMockRecorder) ECNMode() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ECNMode", reflect.TypeOf((*MockSentPacketHandler)(nil).ECNMode))
+}
+
 // GetAlarmTimeout mocks base method
 func (m *MockSentPacketHandler) GetAlarmTimeout() time.Time {
 	ret := m.ctrl.Call(m, "GetAlarmTimeout")
@@ -85,6 +97,18 @@ func (mr *MockSentPacketHandlerMockRecorder) GetLowestPacketNotConfirmedAcked()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLowestPacketNotConfirmedAcked", reflect.TypeOf((*MockSentPacketHandler)(nil).GetLowestPacketNotConfirmedAcked))
 }
 
+// GetStats mocks base method
+func (m *MockSentPacketHandler) GetStats() ackhandler.Stats {
+	ret := m.ctrl.Call(m, "GetStats")
+	ret0, _ := ret[0].(ackhandler.Stats)
+	return ret0
+}
+
+// GetStats indicates an expected call of GetStats
+func (mr *MockSentPacketHandlerMockRecorder) GetStats() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStats", reflect.TypeOf((*MockSentPacketHandler)(nil).GetStats))
+}
+
 // GetStopWaitingFrame mocks base method
 func (m *MockSentPacketHandler) GetStopWaitingFrame(arg0 bool) *wire.StopWaitingFrame {
 	ret := m.ctrl.Call(m, "GetStopWaitingFrame", arg0)
@@ -97,6 +121,30 @@ func (mr *MockSentPacketHandlerMockRecorder) GetStopWaitingFrame(arg0 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStopWaitingFrame", reflect.TypeOf((*MockSentPacketHandler)(nil).GetStopWaitingFrame), arg0)
 }
 
+// MTUProbeSize mocks base method
+func (m *MockSentPacketHandler) MTUProbeSize(arg0 time.Time) protocol.ByteCount {
+	ret := m.ctrl.Call(m, "MTUProbeSize", arg0)
+	ret0, _ := ret[0].(protocol.ByteCount)
+	return ret0
+}
+
+// MTUProbeSize indicates an expected call of MTUProbeSize
+func (mr *MockSentPacketHandlerMockRecorder) MTUProbeSize(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MTUProbeSize", reflect.TypeOf((*MockSentPacketHandler)(nil).MTUProbeSize), arg0)
+}
+
+// MaxPacketSize mocks base method
+func (m *MockSentPacketHandler) MaxPacketSize() protocol.ByteCount {
+	ret := m.ctrl.Call(m, "MaxPacketSize")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	return ret0
+}
+
+// MaxPacketSize indicates an expected call of MaxPacketSize
+func (mr *MockSentPacketHandlerMockRecorder) MaxPacketSize() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MaxPacketSize", reflect.TypeOf((*MockSentPacketHandler)(nil).MaxPacketSize))
+}
+
 // OnAlarm mocks base method
 func (m *MockSentPacketHandler) OnAlarm() {
 	m.ctrl.Call(m, "OnAlarm")
@@ -107,6 +155,26 @@ func (mr *MockSentPacketHandlerMockRecorder) OnAlarm() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnAlarm", reflect.TypeOf((*MockSentPacketHandler)(nil).OnAlarm))
 }
 
+// OnConnectionMigration mocks base method
+func (m *MockSentPacketHandler) OnConnectionMigration(arg0 bool) {
+	m.ctrl.Call(m, "OnConnectionMigration", arg0)
+}
+
+// OnConnectionMigration indicates an expected call of OnConnectionMigration
+func (mr *MockSentPacketHandlerMockRecorder) OnConnectionMigration(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnConnectionMigration", reflect.TypeOf((*MockSentPacketHandler)(nil).OnConnectionMigration), arg0)
+}
+
+// Queue0RTTPacketsForRetransmission mocks base method
+func (m *MockSentPacketHandler) Queue0RTTPacketsForRetransmission() {
+	m.ctrl.Call(m, "Queue0RTTPacketsForRetransmission")
+}
+
+// Queue0RTTPacketsForRetransmission indicates an expected call of Queue0RTTPacketsForRetransmission
+func (mr *MockSentPacketHandlerMockRecorder) Queue0RTTPacketsForRetransmission() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Queue0RTTPacketsForRetransmission", reflect.TypeOf((*MockSentPacketHandler)(nil).Queue0RTTPacketsForRetransmission))
+}
+
 // ReceivedAck mocks base method
 func (m *MockSentPacketHandler) ReceivedAck(arg0 *wire.AckFrame, arg1 protocol.PacketNumber, arg2 protocol.EncryptionLevel, arg3 time.Time) error {
 	ret := m.ctrl.Call(m, "ReceivedAck", arg0, arg1, arg2, arg3)
@@ -153,6 +221,26 @@ func (mr *MockSentPacketHandlerMockRecorder) SetHandshakeComplete() *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetHandshakeComplete", reflect.TypeOf((*MockSentPacketHandler)(nil).SetHandshakeComplete))
 }
 
+// SetPeerMaxAckDelay mocks base method
+func (m *MockSentPacketHandler) SetPeerMaxAckDelay(arg0 time.Duration) {
+	m.ctrl.Call(m, "SetPeerMaxAckDelay", arg0)
+}
+
+// SetPeerMaxAckDelay indicates an expected call of SetPeerMaxAckDelay
+func (mr *MockSentPacketHandlerMockRecorder) SetPeerMaxAckDelay(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPeerMaxAckDelay", reflect.TypeOf((*MockSentPacketHandler)(nil).SetPeerMaxAckDelay), arg0)
+}
+
+// SetPeerMaxPacketSize mocks base method
+func (m *MockSentPacketHandler) SetPeerMaxPacketSize(arg0 protocol.ByteCount) {
+	m.ctrl.Call(m, "SetPeerMaxPacketSize", arg0)
+}
+
+// SetPeerMaxPacketSize indicates an expected call of SetPeerMaxPacketSize
+func (mr *MockSentPacketHandlerMockRecorder) SetPeerMaxPacketSize(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPeerMaxPacketSize", reflect.TypeOf((*MockSentPacketHandler)(nil).SetPeerMaxPacketSize), arg0)
+}
+
 // ShouldSendNumPackets mocks base method
 func (m *MockSentPacketHandler) ShouldSendNumPackets() int {
 	ret := m.ctrl.Call(m, "ShouldSendNumPackets")
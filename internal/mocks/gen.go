@@ -8,4 +8,6 @@ package mocks
 //go:generate sh -c "./mockgen_internal.sh mocks congestion.go github.com/lucas-clemente/quic-go/internal/congestion SendAlgorithm"
 //go:generate sh -c "./mockgen_internal.sh mocks connection_flow_controller.go github.com/lucas-clemente/quic-go/internal/flowcontrol ConnectionFlowController"
 //go:generate sh -c "./mockgen_internal.sh mockcrypto crypto/aead.go github.com/lucas-clemente/quic-go/internal/crypto AEAD"
+//go:generate sh -c "mockgen -package mocklogging -self_package mocklogging -destination logging/connection_tracer.go github.com/lucas-clemente/quic-go/logging ConnectionTracer"
+//go:generate sh -c "mockgen -package mocklogging -self_package mocklogging -destination logging/tracer.go github.com/lucas-clemente/quic-go/logging Tracer"
 //go:generate sh -c "goimports -w ."
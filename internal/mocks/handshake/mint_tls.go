@@ -84,6 +84,19 @@ func (mr *MockMintTLSMockRecorder) Handshake() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Handshake", reflect.TypeOf((*MockMintTLS)(nil).Handshake))
 }
 
+// Read mocks base method
+func (m *MockMintTLS) Read(arg0 []byte) (int, error) {
+	ret := m.ctrl.Call(m, "Read", arg0)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Read indicates an expected call of Read
+func (mr *MockMintTLSMockRecorder) Read(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Read", reflect.TypeOf((*MockMintTLS)(nil).Read), arg0)
+}
+
 // SetCryptoStream mocks base method
 func (m *MockMintTLS) SetCryptoStream(arg0 io.ReadWriter) {
 	m.ctrl.Call(m, "SetCryptoStream", arg0)
@@ -0,0 +1,108 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/lucas-clemente/quic-go/logging (interfaces: ConnectionTracer)
+
+// Package mocklogging is a generated GoMock package.
+package mocklogging
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	congestion "github.com/lucas-clemente/quic-go/internal/congestion"
+	protocol "github.com/lucas-clemente/quic-go/internal/protocol"
+	wire "github.com/lucas-clemente/quic-go/internal/wire"
+	logging "github.com/lucas-clemente/quic-go/logging"
+)
+
+// MockConnectionTracer is a mock of ConnectionTracer interface
+type MockConnectionTracer struct {
+	ctrl     *gomock.Controller
+	recorder *MockConnectionTracerMockRecorder
+}
+
+// MockConnectionTracerMockRecorder is the mock recorder for MockConnectionTracer
+type MockConnectionTracerMockRecorder struct {
+	mock *MockConnectionTracer
+}
+
+// NewMockConnectionTracer creates a new mock instance
+func NewMockConnectionTracer(ctrl *gomock.Controller) *MockConnectionTracer {
+	mock := &MockConnectionTracer{ctrl: ctrl}
+	mock.recorder = &MockConnectionTracerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockConnectionTracer) EXPECT() *MockConnectionTracerMockRecorder {
+	return m.recorder
+}
+
+// Close mocks base method
+func (m *MockConnectionTracer) Close() {
+	m.ctrl.Call(m, "Close")
+}
+
+// Close indicates an expected call of Close
+func (mr *MockConnectionTracerMockRecorder) Close() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockConnectionTracer)(nil).Close))
+}
+
+// ClosedConnection mocks base method
+func (m *MockConnectionTracer) ClosedConnection(arg0 error, arg1 bool) {
+	m.ctrl.Call(m, "ClosedConnection", arg0, arg1)
+}
+
+// ClosedConnection indicates an expected call of ClosedConnection
+func (mr *MockConnectionTracerMockRecorder) ClosedConnection(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClosedConnection", reflect.TypeOf((*MockConnectionTracer)(nil).ClosedConnection), arg0, arg1)
+}
+
+// LostPacket mocks base method
+func (m *MockConnectionTracer) LostPacket(arg0 protocol.EncryptionLevel, arg1 protocol.PacketNumber, arg2 logging.PacketLossReason) {
+	m.ctrl.Call(m, "LostPacket", arg0, arg1, arg2)
+}
+
+// LostPacket indicates an expected call of LostPacket
+func (mr *MockConnectionTracerMockRecorder) LostPacket(arg0, arg1, arg2 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LostPacket", reflect.TypeOf((*MockConnectionTracer)(nil).LostPacket), arg0, arg1, arg2)
+}
+
+// ReceivedPacket mocks base method
+func (m *MockConnectionTracer) ReceivedPacket(arg0 *wire.Header, arg1 protocol.ByteCount, arg2 protocol.EncryptionLevel, arg3 []wire.Frame) {
+	m.ctrl.Call(m, "ReceivedPacket", arg0, arg1, arg2, arg3)
+}
+
+// ReceivedPacket indicates an expected call of ReceivedPacket
+func (mr *MockConnectionTracerMockRecorder) ReceivedPacket(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceivedPacket", reflect.TypeOf((*MockConnectionTracer)(nil).ReceivedPacket), arg0, arg1, arg2, arg3)
+}
+
+// SentPacket mocks base method
+func (m *MockConnectionTracer) SentPacket(arg0 *wire.Header, arg1 protocol.ByteCount, arg2 protocol.EncryptionLevel, arg3 []wire.Frame) {
+	m.ctrl.Call(m, "SentPacket", arg0, arg1, arg2, arg3)
+}
+
+// SentPacket indicates an expected call of SentPacket
+func (mr *MockConnectionTracerMockRecorder) SentPacket(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SentPacket", reflect.TypeOf((*MockConnectionTracer)(nil).SentPacket), arg0, arg1, arg2, arg3)
+}
+
+// UpdatedCongestionWindow mocks base method
+func (m *MockConnectionTracer) UpdatedCongestionWindow(arg0 protocol.ByteCount) {
+	m.ctrl.Call(m, "UpdatedCongestionWindow", arg0)
+}
+
+// UpdatedCongestionWindow indicates an expected call of UpdatedCongestionWindow
+func (mr *MockConnectionTracerMockRecorder) UpdatedCongestionWindow(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatedCongestionWindow", reflect.TypeOf((*MockConnectionTracer)(nil).UpdatedCongestionWindow), arg0)
+}
+
+// UpdatedRTT mocks base method
+func (m *MockConnectionTracer) UpdatedRTT(arg0 *congestion.RTTStats) {
+	m.ctrl.Call(m, "UpdatedRTT", arg0)
+}
+
+// UpdatedRTT indicates an expected call of UpdatedRTT
+func (mr *MockConnectionTracerMockRecorder) UpdatedRTT(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatedRTT", reflect.TypeOf((*MockConnectionTracer)(nil).UpdatedRTT), arg0)
+}
@@ -35,6 +35,18 @@ func (m *MockTLSExtensionHandler) EXPECT() *MockTLSExtensionHandlerMockRecorder
 	return m.recorder
 }
 
+// EarlyDataAccepted mocks base method
+func (m *MockTLSExtensionHandler) EarlyDataAccepted() bool {
+	ret := m.ctrl.Call(m, "EarlyDataAccepted")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// EarlyDataAccepted indicates an expected call of EarlyDataAccepted
+func (mr *MockTLSExtensionHandlerMockRecorder) EarlyDataAccepted() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EarlyDataAccepted", reflect.TypeOf((*MockTLSExtensionHandler)(nil).EarlyDataAccepted))
+}
+
 // GetPeerParams mocks base method
 func (m *MockTLSExtensionHandler) GetPeerParams() <-chan handshake.TransportParameters {
 	ret := m.ctrl.Call(m, "GetPeerParams")
@@ -59,6 +71,31 @@ func (mr *MockTLSExtensionHandlerMockRecorder) Receive(arg0, arg1 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Receive", reflect.TypeOf((*MockTLSExtensionHandler)(nil).Receive), arg0, arg1)
 }
 
+// ReceivedPeerParams mocks base method
+func (m *MockTLSExtensionHandler) ReceivedPeerParams() (*handshake.TransportParameters, error) {
+	ret := m.ctrl.Call(m, "ReceivedPeerParams")
+	ret0, _ := ret[0].(*handshake.TransportParameters)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReceivedPeerParams indicates an expected call of ReceivedPeerParams
+func (mr *MockTLSExtensionHandlerMockRecorder) ReceivedPeerParams() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceivedPeerParams", reflect.TypeOf((*MockTLSExtensionHandler)(nil).ReceivedPeerParams))
+}
+
+// RestorePeerParams mocks base method
+func (m *MockTLSExtensionHandler) RestorePeerParams(arg0 *handshake.TransportParameters) error {
+	ret := m.ctrl.Call(m, "RestorePeerParams", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestorePeerParams indicates an expected call of RestorePeerParams
+func (mr *MockTLSExtensionHandlerMockRecorder) RestorePeerParams(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestorePeerParams", reflect.TypeOf((*MockTLSExtensionHandler)(nil).RestorePeerParams), arg0)
+}
+
 // Send mocks base method
 func (m *MockTLSExtensionHandler) Send(arg0 mint.HandshakeType, arg1 *mint.ExtensionList) error {
 	ret := m.ctrl.Call(m, "Send", arg0, arg1)
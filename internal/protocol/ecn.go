@@ -0,0 +1,29 @@
+package protocol
+
+// ECN is the ECN codepoint of a packet, i.e. the two least significant bits of the IP TOS / Traffic Class field
+type ECN uint8
+
+const (
+	// ECNNon is the Not-ECT codepoint, for packets that are not ECN-capable
+	ECNNon ECN = 0x0
+	// ECT1 is the ECT(1) codepoint
+	ECT1 ECN = 0x1
+	// ECT0 is the ECT(0) codepoint
+	ECT0 ECN = 0x2
+	// ECNCE is the CE codepoint, which is set by routers that experience congestion
+	ECNCE ECN = 0x3
+)
+
+func (e ECN) String() string {
+	switch e {
+	case ECNNon:
+		return "Not-ECT"
+	case ECT1:
+		return "ECT(1)"
+	case ECT0:
+		return "ECT(0)"
+	case ECNCE:
+		return "CE"
+	}
+	return "invalid ECN value"
+}
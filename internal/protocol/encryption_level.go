@@ -10,6 +10,7 @@ const (
 	// EncryptionUnencrypted is not encrypted
 	EncryptionUnencrypted
 	// EncryptionSecure is encrypted, but not forward secure
+	// For IETF QUIC, this is the encryption level of 0-RTT packets.
 	EncryptionSecure
 	// EncryptionForwardSecure is forward secure
 	EncryptionForwardSecure
@@ -2,14 +2,28 @@ package protocol
 
 import "time"
 
-// MaxPacketSize is the maximum packet size that we use for sending packets.
+// InitialPacketSize is the packet size that we use for sending packets, until path MTU discovery confirms a larger size.
 // It includes the QUIC packet header, but excludes the UDP and IP header.
-const MaxPacketSize ByteCount = 1200
+const InitialPacketSize ByteCount = 1200
+
+// DefaultMaxPacketSize is the default for the largest packet size that path MTU discovery probes for.
+// It is based on ethernet's max size, minus the IP and UDP headers.
+// IPv6 has a 40 byte header, UDP adds an additional 8 bytes. This is a total overhead of 48 bytes.
+// Ethernet's max packet size is 1500 bytes, 1500 - 48 = 1452.
+const DefaultMaxPacketSize ByteCount = 1452
+
+// MaxMaxPacketSize is the largest maximum packet size that can be configured.
+// It is based on the max size of an ethernet jumbo frame, minus the IP and UDP headers, 9000 - 48 = 8952.
+// Packets larger than the MaxReceivePacketSize are only sent to peers that can receive them.
+const MaxMaxPacketSize ByteCount = 8952
 
 // NonForwardSecurePacketSizeReduction is the number of bytes a non forward-secure packet has to be smaller than a forward-secure packet
 // This makes sure that those packets can always be retransmitted without splitting the contained StreamFrames
 const NonForwardSecurePacketSizeReduction = 50
 
+// PacketBatchSize is the maximum number of packets that are read or written using a single system call
+const PacketBatchSize = 16
+
 // DefaultMaxCongestionWindow is the default for the max congestion window
 const DefaultMaxCongestionWindow = 1000
 
@@ -24,10 +38,17 @@ const MaxUndecryptablePackets = 10
 // This timeout allows the Go scheduler to switch to the Go rountine that reads the crypto stream and to escalate the crypto
 const PublicResetTimeout = 500 * time.Millisecond
 
-// AckSendDelay is the maximum delay that can be applied to an ACK for a retransmittable packet
+// AckSendDelay is the default maximum delay that can be applied to an ACK for a retransmittable packet
 // This is the value Chromium is using
 const AckSendDelay = 25 * time.Millisecond
 
+// MaxMaxAckDelay is the largest maximum ACK delay that can be configured, or requested by the peer
+const MaxMaxAckDelay = (1<<14 - 1) * time.Millisecond
+
+// MinAckDelay is the smallest maximum ACK delay that the peer may request using an ACK_FREQUENCY frame.
+// It is announced in the min_ack_delay transport parameter.
+const MinAckDelay = time.Millisecond
+
 // ReceiveStreamFlowControlWindow is the stream-level flow control window for receiving data
 // This is the value that Google servers are using
 const ReceiveStreamFlowControlWindow = (1 << 10) * 32 // 32 kB
@@ -92,7 +113,7 @@ const MaxTrackedReceivedAckRanges = DefaultMaxCongestionWindow
 // MaxNonRetransmittableAcks is the maximum number of packets containing an ACK, but no retransmittable frames, that we send in a row
 const MaxNonRetransmittableAcks = 19
 
-// RetransmittablePacketsBeforeAck is the number of retransmittable that an ACK is sent for
+// RetransmittablePacketsBeforeAck is the default number of retransmittable packets that an ACK is sent for
 const RetransmittablePacketsBeforeAck = 10
 
 // MaxStreamFrameSorterGaps is the maximum number of gaps between received StreamFrames
@@ -135,3 +156,41 @@ const MinStreamFrameSize ByteCount = 128
 // If the packet packing frequency is higher, multiple packets might be sent at once.
 // Example: For a packet pacing delay of 20 microseconds, we would send 5 packets at once, wait for 100 microseconds, and so forth.
 const MinPacingDelay time.Duration = 100 * time.Microsecond
+
+// MaxDatagramFrameSize is the maximum size of a DATAGRAM frame that we send, including the frame header.
+// It is chosen such that a DATAGRAM frame always fits into a forward-secure packet.
+const MaxDatagramFrameSize ByteCount = 1150
+
+// DatagramRcvQueueLen is the maximum number of received DATAGRAM frames that are queued until the application reads them.
+// If the queue is full, additional DATAGRAM frames are dropped.
+const DatagramRcvQueueLen = 128
+
+// PathValidationAmplificationFactor is the maximum ratio of bytes sent to bytes received on a path that has not been validated yet.
+const PathValidationAmplificationFactor = 3
+
+// MaxPathChallenges is the maximum number of outstanding PATH_CHALLENGE frames for a path.
+// If more PATH_CHALLENGE frames are sent, a PATH_RESPONSE for the oldest one won't validate the path any more.
+const MaxPathChallenges = 4
+
+// MinPathChallengeInterval is the minimum time between two PATH_CHALLENGE frames sent on the same path.
+const MinPathChallengeInterval = 100 * time.Millisecond
+
+// MaxActiveConnectionIDs is the maximum number of connection IDs that are active at the same time.
+// The server issues connection IDs up to this limit, and the client accepts at most this number.
+const MaxActiveConnectionIDs = 4
+
+// MinStatelessResetKeyLen is the minimum length of the static key used to derive stateless reset tokens
+const MinStatelessResetKeyLen = 32
+
+// SessionTicketLifetime is the lifetime of the session tickets issued by the server (for IETF QUIC)
+const SessionTicketLifetime = 24 * time.Hour
+
+// MaxSessionTickets is the maximum number of session tickets that the server keeps track of (for IETF QUIC)
+const MaxSessionTickets = 1 << 14
+
+// DefaultKeyUpdateInterval is the number of packets sent with the same 1-RTT keys, after which a key update is initiated (for IETF QUIC)
+const DefaultKeyUpdateInterval = 1 << 20
+
+// KeyPhaseRetentionTime is the time that the keys of the previous key phase are kept after a key update (for IETF QUIC).
+// They are needed to decrypt reordered packets.
+const KeyPhaseRetentionTime = 10 * time.Second
@@ -12,6 +12,9 @@ import (
 // TODO: use the value sent in the transport parameters
 const ackDelayExponent = 3
 
+// the type byte of an ACK frame that contains ECN counts
+const ackECNFrameType = 0x20
+
 // An AckFrame is an ACK frame
 type AckFrame struct {
 	LargestAcked protocol.PacketNumber
@@ -22,6 +25,10 @@ type AckFrame struct {
 	// this field will not be set for received ACKs frames
 	PacketReceivedTime time.Time
 	DelayTime          time.Duration
+
+	// ECN counts, only used for IETF QUIC.
+	// If any of them is non-zero, the frame is sent as an ACK_ECN frame.
+	ECT0, ECT1, ECNCE uint64
 }
 
 // ParseAckFrame reads an ACK frame
@@ -30,7 +37,8 @@ func ParseAckFrame(r *bytes.Reader, version protocol.VersionNumber) (*AckFrame,
 		return parseAckFrameLegacy(r, version)
 	}
 
-	if _, err := r.ReadByte(); err != nil {
+	typeByte, err := r.ReadByte()
+	if err != nil {
 		return nil, err
 	}
 
@@ -95,6 +103,18 @@ func ParseAckFrame(r *bytes.Reader, version protocol.VersionNumber) (*AckFrame,
 		return nil, errInvalidAckRanges
 	}
 
+	// read the ECN counts
+	if typeByte == ackECNFrameType {
+		if frame.ECT0, err = utils.ReadVarInt(r); err != nil {
+			return nil, err
+		}
+		if frame.ECT1, err = utils.ReadVarInt(r); err != nil {
+			return nil, err
+		}
+		if frame.ECNCE, err = utils.ReadVarInt(r); err != nil {
+			return nil, err
+		}
+	}
 	return frame, nil
 }
 
@@ -104,7 +124,11 @@ func (f *AckFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) error
 		return f.writeLegacy(b, version)
 	}
 
-	b.WriteByte(0xe)
+	if f.HasECN() {
+		b.WriteByte(ackECNFrameType)
+	} else {
+		b.WriteByte(0xe)
+	}
 	utils.WriteVarInt(b, uint64(f.LargestAcked))
 	utils.WriteVarInt(b, encodeAckDelay(f.DelayTime))
 
@@ -122,18 +146,23 @@ func (f *AckFrame) Write(b *bytes.Buffer, version protocol.VersionNumber) error
 	utils.WriteVarInt(b, uint64(f.LargestAcked-lowestInFirstRange))
 
 	// write all the other range
-	if !f.HasMissingRanges() {
-		return nil
-	}
-	var lowest protocol.PacketNumber
-	for i, ackRange := range f.AckRanges {
-		if i == 0 {
-			lowest = lowestInFirstRange
-			continue
+	if f.HasMissingRanges() {
+		var lowest protocol.PacketNumber
+		for i, ackRange := range f.AckRanges {
+			if i == 0 {
+				lowest = lowestInFirstRange
+				continue
+			}
+			utils.WriteVarInt(b, uint64(lowest-ackRange.Last-2))
+			utils.WriteVarInt(b, uint64(ackRange.Last-ackRange.First))
+			lowest = ackRange.First
 		}
-		utils.WriteVarInt(b, uint64(lowest-ackRange.Last-2))
-		utils.WriteVarInt(b, uint64(ackRange.Last-ackRange.First))
-		lowest = ackRange.First
+	}
+
+	if f.HasECN() {
+		utils.WriteVarInt(b, f.ECT0)
+		utils.WriteVarInt(b, f.ECT1)
+		utils.WriteVarInt(b, f.ECNCE)
 	}
 	return nil
 }
@@ -156,22 +185,30 @@ func (f *AckFrame) Length(version protocol.VersionNumber) protocol.ByteCount {
 	}
 	length += utils.VarIntLen(uint64(f.LargestAcked - lowestInFirstRange))
 
-	if !f.HasMissingRanges() {
-		return length
-	}
-	var lowest protocol.PacketNumber
-	for i, ackRange := range f.AckRanges {
-		if i == 0 {
+	if f.HasMissingRanges() {
+		var lowest protocol.PacketNumber
+		for i, ackRange := range f.AckRanges {
+			if i == 0 {
+				lowest = ackRange.First
+				continue
+			}
+			length += utils.VarIntLen(uint64(lowest - ackRange.Last - 2))
+			length += utils.VarIntLen(uint64(ackRange.Last - ackRange.First))
 			lowest = ackRange.First
-			continue
 		}
-		length += utils.VarIntLen(uint64(lowest - ackRange.Last - 2))
-		length += utils.VarIntLen(uint64(ackRange.Last - ackRange.First))
-		lowest = ackRange.First
+	}
+
+	if f.HasECN() {
+		length += utils.VarIntLen(f.ECT0) + utils.VarIntLen(f.ECT1) + utils.VarIntLen(f.ECNCE)
 	}
 	return length
 }
 
+// HasECN says if the frame contains ECN counts
+func (f *AckFrame) HasECN() bool {
+	return f.ECT0 > 0 || f.ECT1 > 0 || f.ECNCE > 0
+}
+
 // HasMissingRanges returns if this frame reports any missing packets
 func (f *AckFrame) HasMissingRanges() bool {
 	return len(f.AckRanges) > 0
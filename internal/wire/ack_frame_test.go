@@ -128,6 +128,44 @@ var _ = Describe("ACK Frame (for IETF QUIC)", func() {
 				Expect(err).To(MatchError(io.EOF))
 			}
 		})
+
+		It("parses an ACK_ECN frame", func() {
+			data := []byte{0x20}
+			data = append(data, encodeVarInt(100)...) // largest acked
+			data = append(data, encodeVarInt(0)...)   // delay
+			data = append(data, encodeVarInt(0)...)   // num blocks
+			data = append(data, encodeVarInt(10)...)  // first ack block
+			data = append(data, encodeVarInt(0x42)...)
+			data = append(data, encodeVarInt(0x12345)...)
+			data = append(data, encodeVarInt(0x12345678)...)
+			b := bytes.NewReader(data)
+			frame, err := ParseAckFrame(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.LargestAcked).To(Equal(protocol.PacketNumber(100)))
+			Expect(frame.LowestAcked).To(Equal(protocol.PacketNumber(90)))
+			Expect(frame.HasECN()).To(BeTrue())
+			Expect(frame.ECT0).To(BeEquivalentTo(0x42))
+			Expect(frame.ECT1).To(BeEquivalentTo(0x12345))
+			Expect(frame.ECNCE).To(BeEquivalentTo(0x12345678))
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("errors on EOF, for ACK_ECN frames", func() {
+			data := []byte{0x20}
+			data = append(data, encodeVarInt(1000)...) // largest acked
+			data = append(data, encodeVarInt(0)...)    // delay
+			data = append(data, encodeVarInt(0)...)    // num blocks
+			data = append(data, encodeVarInt(100)...)  // first ack block
+			data = append(data, encodeVarInt(1)...)
+			data = append(data, encodeVarInt(2)...)
+			data = append(data, encodeVarInt(3)...)
+			_, err := ParseAckFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range data {
+				_, err := ParseAckFrame(bytes.NewReader(data[0:i]), versionIETFFrames)
+				Expect(err).To(MatchError(io.EOF))
+			}
+		})
 	})
 
 	Context("when writing", func() {
@@ -211,6 +249,28 @@ var _ = Describe("ACK Frame (for IETF QUIC)", func() {
 			Expect(frame.HasMissingRanges()).To(BeTrue())
 			Expect(b.Len()).To(BeZero())
 		})
+
+		It("writes a frame with ECN counts", func() {
+			buf := &bytes.Buffer{}
+			f := &AckFrame{
+				LargestAcked: 10,
+				LowestAcked:  1,
+				AckRanges: []AckRange{
+					{First: 8, Last: 10},
+					{First: 1, Last: 6},
+				},
+				ECT0:  0x1337,
+				ECNCE: 0x42,
+			}
+			Expect(f.Write(buf, versionIETFFrames)).To(Succeed())
+			Expect(buf.Bytes()[0]).To(BeEquivalentTo(0x20))
+			Expect(f.Length(versionIETFFrames)).To(BeEquivalentTo(buf.Len()))
+			b := bytes.NewReader(buf.Bytes())
+			frame, err := ParseAckFrame(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(f))
+			Expect(b.Len()).To(BeZero())
+		})
 	})
 
 	Context("ACK range validator", func() {
@@ -0,0 +1,82 @@
+package wire
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+// An AckFrequencyFrame is an ACK_FREQUENCY frame.
+// It asks the peer to change how often it sends ACKs.
+// The frame uses the same encoding for gQUIC and IETF QUIC.
+type AckFrequencyFrame struct {
+	SequenceNumber uint64
+	// PacketTolerance is the number of retransmittable packets that the peer receives before it sends an ACK
+	PacketTolerance uint64
+	// UpdateMaxAckDelay is the maximum time the peer delays an ACK
+	UpdateMaxAckDelay time.Duration
+	// IgnoreOrder tells the peer not to send an ACK immediately when it receives packets out of order
+	IgnoreOrder bool
+}
+
+// ParseAckFrequencyFrame parses an ACK_FREQUENCY frame
+func ParseAckFrequencyFrame(r *bytes.Reader, _ protocol.VersionNumber) (*AckFrequencyFrame, error) {
+	if _, err := r.ReadByte(); err != nil { // read the TypeByte
+		return nil, err
+	}
+	seq, err := utils.ReadVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	tolerance, err := utils.ReadVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	if tolerance == 0 {
+		return nil, errors.New("invalid packet tolerance: 0")
+	}
+	delay, err := utils.ReadVarInt(r)
+	if err != nil {
+		return nil, err
+	}
+	ignoreOrder, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if ignoreOrder > 1 {
+		return nil, errors.New("invalid value for Ignore Order")
+	}
+	return &AckFrequencyFrame{
+		SequenceNumber:    seq,
+		PacketTolerance:   tolerance,
+		UpdateMaxAckDelay: time.Duration(delay) * time.Microsecond,
+		IgnoreOrder:       ignoreOrder == 1,
+	}, nil
+}
+
+// Write writes an ACK_FREQUENCY frame.
+// The draft version we implement doesn't define this frame, so we use a type byte that is unused in gQUIC and IETF QUIC.
+func (f *AckFrequencyFrame) Write(b *bytes.Buffer, _ protocol.VersionNumber) error {
+	b.WriteByte(0x1a)
+	utils.WriteVarInt(b, f.SequenceNumber)
+	utils.WriteVarInt(b, f.PacketTolerance)
+	utils.WriteVarInt(b, f.encodedMaxAckDelay())
+	if f.IgnoreOrder {
+		b.WriteByte(1)
+	} else {
+		b.WriteByte(0)
+	}
+	return nil
+}
+
+// Length of a written frame
+func (f *AckFrequencyFrame) Length(protocol.VersionNumber) protocol.ByteCount {
+	return 1 + utils.VarIntLen(f.SequenceNumber) + utils.VarIntLen(f.PacketTolerance) + utils.VarIntLen(f.encodedMaxAckDelay()) + 1
+}
+
+func (f *AckFrequencyFrame) encodedMaxAckDelay() uint64 {
+	return uint64(f.UpdateMaxAckDelay / time.Microsecond)
+}
@@ -0,0 +1,109 @@
+package wire
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ACK_FREQUENCY frame", func() {
+	Context("when parsing", func() {
+		It("accepts sample frame", func() {
+			data := []byte{0x1a}
+			data = append(data, encodeVarInt(0xdeadbeef)...) // sequence number
+			data = append(data, encodeVarInt(0xcafe)...)     // packet tolerance
+			data = append(data, encodeVarInt(1337)...)       // max ack delay, in microseconds
+			data = append(data, 1)                           // ignore order
+			b := bytes.NewReader(data)
+			f, err := ParseAckFrequencyFrame(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f.SequenceNumber).To(Equal(uint64(0xdeadbeef)))
+			Expect(f.PacketTolerance).To(Equal(uint64(0xcafe)))
+			Expect(f.UpdateMaxAckDelay).To(Equal(1337 * time.Microsecond))
+			Expect(f.IgnoreOrder).To(BeTrue())
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("errors when the packet tolerance is 0", func() {
+			data := []byte{0x1a}
+			data = append(data, encodeVarInt(1)...)
+			data = append(data, encodeVarInt(0)...)
+			data = append(data, encodeVarInt(1000)...)
+			data = append(data, 0)
+			_, err := ParseAckFrequencyFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).To(MatchError("invalid packet tolerance: 0"))
+		})
+
+		It("errors on invalid values for the Ignore Order field", func() {
+			data := []byte{0x1a}
+			data = append(data, encodeVarInt(1)...)
+			data = append(data, encodeVarInt(2)...)
+			data = append(data, encodeVarInt(1000)...)
+			data = append(data, 2)
+			_, err := ParseAckFrequencyFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).To(MatchError("invalid value for Ignore Order"))
+		})
+
+		It("errors on EOFs", func() {
+			data := []byte{0x1a}
+			data = append(data, encodeVarInt(0xdeadbeef)...)
+			data = append(data, encodeVarInt(0xcafe)...)
+			data = append(data, encodeVarInt(1337)...)
+			data = append(data, 0)
+			_, err := ParseAckFrequencyFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range data {
+				_, err := ParseAckFrequencyFrame(bytes.NewReader(data[0:i]), versionIETFFrames)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+
+	Context("when writing", func() {
+		It("writes a sample frame", func() {
+			b := &bytes.Buffer{}
+			frame := AckFrequencyFrame{
+				SequenceNumber:    0x1337,
+				PacketTolerance:   42,
+				UpdateMaxAckDelay: 12 * time.Millisecond,
+			}
+			err := frame.Write(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			expected := []byte{0x1a}
+			expected = append(expected, encodeVarInt(0x1337)...)
+			expected = append(expected, encodeVarInt(42)...)
+			expected = append(expected, encodeVarInt(12000)...)
+			expected = append(expected, 0)
+			Expect(b.Bytes()).To(Equal(expected))
+		})
+
+		It("writes the Ignore Order field", func() {
+			b := &bytes.Buffer{}
+			frame := AckFrequencyFrame{
+				SequenceNumber:    1,
+				PacketTolerance:   1,
+				UpdateMaxAckDelay: time.Millisecond,
+				IgnoreOrder:       true,
+			}
+			err := frame.Write(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			f, err := ParseAckFrequencyFrame(bytes.NewReader(b.Bytes()), versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).To(Equal(&frame))
+		})
+
+		It("has the correct length", func() {
+			frame := AckFrequencyFrame{
+				SequenceNumber:    0xdecafbad,
+				PacketTolerance:   0x1337,
+				UpdateMaxAckDelay: time.Second,
+			}
+			b := &bytes.Buffer{}
+			err := frame.Write(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.Length(versionIETFFrames)).To(BeEquivalentTo(b.Len()))
+		})
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"bytes"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 )
 
 // Header is the header of a QUIC packet.
@@ -73,6 +74,25 @@ func ParseHeaderSentByClient(b *bytes.Reader) (*Header, error) {
 	return parsePacketHeader(b, protocol.PerspectiveClient, isPublicHeader)
 }
 
+// PeekConnectionID gets the connection ID of a packet, without parsing the rest of the header.
+// In all header formats, the connection ID directly follows the first byte.
+// It returns false if the packet is a gQUIC packet with an omitted connection ID.
+// An IETF draft Short Header with an omitted connection ID can't be distinguished from
+// a gQUIC Public Header that contains a connection ID, so the caller must be prepared
+// to receive a meaningless connection ID for such packets.
+func PeekConnectionID(data []byte) (protocol.ConnectionID, bool) {
+	if len(data) < 9 {
+		return 0, false
+	}
+	typeByte := data[0]
+	// gQUIC always has 0x40 unset. 0x08 is the connection ID flag.
+	if typeByte&0x40 == 0 && typeByte&0x08 == 0 {
+		return 0, false
+	}
+	connID, _ := utils.BigEndian.ReadUint64(bytes.NewReader(data[1:9]))
+	return protocol.ConnectionID(connID), true
+}
+
 func parsePacketHeader(b *bytes.Reader, sentBy protocol.Perspective, isPublicHeader bool) (*Header, error) {
 	// This is a gQUIC Public Header.
 	if isPublicHeader {
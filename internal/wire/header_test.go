@@ -151,6 +151,67 @@ var _ = Describe("Header", func() {
 		})
 	})
 
+	Context("peeking the connection ID", func() {
+		It("gets the connection ID from a gQUIC Public Header", func() {
+			buf := &bytes.Buffer{}
+			err := (&Header{
+				ConnectionID:    0xdeadbeef,
+				PacketNumber:    0x42,
+				PacketNumberLen: protocol.PacketNumberLen2,
+			}).Write(buf, protocol.PerspectiveServer, versionPublicHeader)
+			Expect(err).ToNot(HaveOccurred())
+			connID, ok := PeekConnectionID(buf.Bytes())
+			Expect(ok).To(BeTrue())
+			Expect(connID).To(Equal(protocol.ConnectionID(0xdeadbeef)))
+		})
+
+		It("gets the connection ID from an IETF draft Long Header", func() {
+			buf := &bytes.Buffer{}
+			err := (&Header{
+				IsLongHeader: true,
+				Type:         protocol.PacketTypeHandshake,
+				ConnectionID: 0xdeadbeef,
+				PacketNumber: 0x42,
+				Version:      versionIETFHeader,
+			}).Write(buf, protocol.PerspectiveServer, versionIETFHeader)
+			Expect(err).ToNot(HaveOccurred())
+			connID, ok := PeekConnectionID(buf.Bytes())
+			Expect(ok).To(BeTrue())
+			Expect(connID).To(Equal(protocol.ConnectionID(0xdeadbeef)))
+		})
+
+		It("gets the connection ID from an IETF draft Short Header", func() {
+			buf := &bytes.Buffer{}
+			err := (&Header{
+				ConnectionID:    0xdeadbeef,
+				PacketNumber:    0x42,
+				PacketNumberLen: protocol.PacketNumberLen2,
+			}).Write(buf, protocol.PerspectiveServer, versionIETFHeader)
+			Expect(err).ToNot(HaveOccurred())
+			connID, ok := PeekConnectionID(buf.Bytes())
+			Expect(ok).To(BeTrue())
+			Expect(connID).To(Equal(protocol.ConnectionID(0xdeadbeef)))
+		})
+
+		It("recognizes gQUIC packets that omit the connection ID", func() {
+			buf := &bytes.Buffer{}
+			err := (&Header{
+				OmitConnectionID: true,
+				PacketNumber:     0x42,
+				PacketNumberLen:  protocol.PacketNumberLen6,
+			}).Write(buf, protocol.PerspectiveServer, versionPublicHeader)
+			Expect(err).ToNot(HaveOccurred())
+			buf.Write([]byte("foobar")) // add some payload
+			_, ok := PeekConnectionID(buf.Bytes())
+			Expect(ok).To(BeFalse())
+		})
+
+		It("doesn't get a connection ID from packets that are too short", func() {
+			_, ok := PeekConnectionID([]byte{0x08, 1, 2, 3, 4, 5, 6, 7})
+			Expect(ok).To(BeFalse())
+		})
+	})
+
 	Context("writing", func() {
 		It("writes a gQUIC Public Header", func() {
 			buf := &bytes.Buffer{}
@@ -55,6 +55,11 @@ var _ = Describe("Frame logging", func() {
 		Expect(buf.Bytes()).To(ContainSubstring("\t<- &wire.StreamFrame{StreamID: 42, FinBit: false, Offset: 0x1337, Data length: 0x100, Offset + Data length: 0x1437}\n"))
 	})
 
+	It("logs DATAGRAM frames", func() {
+		LogFrame(&DatagramFrame{Data: bytes.Repeat([]byte{'f'}, 0x100)}, true)
+		Expect(buf.Bytes()).To(ContainSubstring("\t-> &wire.DatagramFrame{Data length: 0x100}\n"))
+	})
+
 	It("logs ACK frames", func() {
 		frame := &AckFrame{
 			LargestAcked: 0x1337,
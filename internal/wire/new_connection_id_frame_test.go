@@ -0,0 +1,70 @@
+package wire
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NEW_CONNECTION_ID frame", func() {
+	token := [16]byte{0xf, 0xe, 0xd, 0xc, 0xb, 0xa, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0}
+
+	Context("when parsing", func() {
+		It("accepts sample frame", func() {
+			data := []byte{0x0b}
+			data = append(data, encodeVarInt(0xdeadbeef)...)
+			data = append(data, []byte{1, 2, 3, 4, 5, 6, 7, 8}...)
+			data = append(data, token[:]...)
+			b := bytes.NewReader(data)
+			f, err := ParseNewConnectionIDFrame(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f.SequenceNumber).To(Equal(uint64(0xdeadbeef)))
+			Expect(f.ConnectionID).To(Equal(protocol.ConnectionID(0x0102030405060708)))
+			Expect(f.StatelessResetToken).To(Equal(token))
+			Expect(b.Len()).To(BeZero())
+		})
+
+		It("errors on EOFs", func() {
+			data := []byte{0x0b}
+			data = append(data, encodeVarInt(0xdeadbeef)...)
+			data = append(data, []byte{1, 2, 3, 4, 5, 6, 7, 8}...)
+			data = append(data, token[:]...)
+			_, err := ParseNewConnectionIDFrame(bytes.NewReader(data), versionIETFFrames)
+			Expect(err).NotTo(HaveOccurred())
+			for i := range data {
+				_, err := ParseNewConnectionIDFrame(bytes.NewReader(data[0:i]), versionIETFFrames)
+				Expect(err).To(MatchError(io.EOF))
+			}
+		})
+	})
+
+	Context("when writing", func() {
+		It("writes a sample frame", func() {
+			b := &bytes.Buffer{}
+			frame := NewConnectionIDFrame{
+				SequenceNumber:      0x1337,
+				ConnectionID:        0xdeadbeefcafe1337,
+				StatelessResetToken: token,
+			}
+			err := frame.Write(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			expected := []byte{0x0b}
+			expected = append(expected, encodeVarInt(0x1337)...)
+			expected = append(expected, []byte{0xde, 0xad, 0xbe, 0xef, 0xca, 0xfe, 0x13, 0x37}...)
+			expected = append(expected, token[:]...)
+			Expect(b.Bytes()).To(Equal(expected))
+		})
+
+		It("has the correct length", func() {
+			frame := NewConnectionIDFrame{SequenceNumber: 0xdecafbad}
+			b := &bytes.Buffer{}
+			err := frame.Write(b, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame.Length(versionIETFFrames)).To(BeEquivalentTo(b.Len()))
+		})
+	})
+})
@@ -0,0 +1,116 @@
+// Package logging defines a tracing interface for QUIC connections.
+// It can be used to observe the packets and frames sent and received on a connection,
+// as well as loss detection and congestion control events.
+package logging
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+type (
+	// A ByteCount is used to count bytes.
+	ByteCount = protocol.ByteCount
+	// A ConnectionID is a QUIC connection ID.
+	ConnectionID = protocol.ConnectionID
+	// The EncryptionLevel is the encryption level of a packet.
+	EncryptionLevel = protocol.EncryptionLevel
+	// The PacketNumber is the packet number of a packet.
+	PacketNumber = protocol.PacketNumber
+	// The Perspective is the role of a QUIC endpoint (client or server).
+	Perspective = protocol.Perspective
+	// The StreamID is the stream ID.
+	StreamID = protocol.StreamID
+	// The VersionNumber is the QUIC version.
+	VersionNumber = protocol.VersionNumber
+	// RTTStats contains the RTT statistics of a connection.
+	RTTStats = congestion.RTTStats
+
+	// The Header is the header of a QUIC packet.
+	Header = wire.Header
+	// A Frame is a QUIC frame.
+	Frame = wire.Frame
+
+	// An AckFrame is an ACK frame.
+	AckFrame = wire.AckFrame
+	// An AckFrequencyFrame is an ACK_FREQUENCY frame.
+	AckFrequencyFrame = wire.AckFrequencyFrame
+	// A BlockedFrame is a BLOCKED frame.
+	BlockedFrame = wire.BlockedFrame
+	// A ConnectionCloseFrame is a CONNECTION_CLOSE frame.
+	ConnectionCloseFrame = wire.ConnectionCloseFrame
+	// A DatagramFrame is a DATAGRAM frame.
+	DatagramFrame = wire.DatagramFrame
+	// A GoawayFrame is a GOAWAY frame.
+	GoawayFrame = wire.GoawayFrame
+	// A MaxDataFrame is a MAX_DATA frame.
+	MaxDataFrame = wire.MaxDataFrame
+	// A MaxStreamDataFrame is a MAX_STREAM_DATA frame.
+	MaxStreamDataFrame = wire.MaxStreamDataFrame
+	// A MaxStreamIDFrame is a MAX_STREAM_ID frame.
+	MaxStreamIDFrame = wire.MaxStreamIDFrame
+	// A NewConnectionIDFrame is a NEW_CONNECTION_ID frame.
+	NewConnectionIDFrame = wire.NewConnectionIDFrame
+	// A PathChallengeFrame is a PATH_CHALLENGE frame.
+	PathChallengeFrame = wire.PathChallengeFrame
+	// A PathResponseFrame is a PATH_RESPONSE frame.
+	PathResponseFrame = wire.PathResponseFrame
+	// A PingFrame is a PING frame.
+	PingFrame = wire.PingFrame
+	// A RetireConnectionIDFrame is a RETIRE_CONNECTION_ID frame.
+	RetireConnectionIDFrame = wire.RetireConnectionIDFrame
+	// A RstStreamFrame is a RST_STREAM frame.
+	RstStreamFrame = wire.RstStreamFrame
+	// A StopSendingFrame is a STOP_SENDING frame.
+	StopSendingFrame = wire.StopSendingFrame
+	// A StopWaitingFrame is a STOP_WAITING frame.
+	StopWaitingFrame = wire.StopWaitingFrame
+	// A StreamBlockedFrame is a STREAM_BLOCKED frame.
+	StreamBlockedFrame = wire.StreamBlockedFrame
+	// A StreamFrame is a STREAM frame.
+	StreamFrame = wire.StreamFrame
+	// A StreamIDBlockedFrame is a STREAM_ID_BLOCKED frame.
+	StreamIDBlockedFrame = wire.StreamIDBlockedFrame
+)
+
+// The encryption levels
+const (
+	EncryptionUnencrypted   = protocol.EncryptionUnencrypted
+	EncryptionSecure        = protocol.EncryptionSecure
+	EncryptionForwardSecure = protocol.EncryptionForwardSecure
+)
+
+// The perspectives
+const (
+	PerspectiveServer = protocol.PerspectiveServer
+	PerspectiveClient = protocol.PerspectiveClient
+)
+
+// A Tracer creates ConnectionTracers.
+type Tracer interface {
+	// TracerForConnection is called for every new connection.
+	// It may return nil, in which case the connection is not traced.
+	TracerForConnection(p Perspective, connID ConnectionID) ConnectionTracer
+}
+
+// A ConnectionTracer records events of a single QUIC connection.
+// All methods are called from the same go routine.
+type ConnectionTracer interface {
+	// SentPacket is called for every packet sent, including retransmissions.
+	SentPacket(hdr *Header, size ByteCount, encLevel EncryptionLevel, frames []Frame)
+	// ReceivedPacket is called for every packet that was received and successfully decrypted.
+	ReceivedPacket(hdr *Header, size ByteCount, encLevel EncryptionLevel, frames []Frame)
+	// LostPacket is called when a packet is declared lost.
+	LostPacket(encLevel EncryptionLevel, pn PacketNumber, reason PacketLossReason)
+	// UpdatedRTT is called when a new RTT sample was taken.
+	UpdatedRTT(rttStats *RTTStats)
+	// UpdatedCongestionWindow is called when the congestion controller changes the congestion window.
+	UpdatedCongestionWindow(cwnd ByteCount)
+	// ClosedConnection is called when the connection is closed.
+	// The remote flag is set if the connection was closed by the peer.
+	ClosedConnection(err error, remote bool)
+	// Close is called when the connection's run loop stopped.
+	// No methods will be called after Close.
+	Close()
+}
@@ -0,0 +1,61 @@
+package logging
+
+type connTracerMultiplexer struct {
+	tracers []ConnectionTracer
+}
+
+var _ ConnectionTracer = &connTracerMultiplexer{}
+
+// NewMultiplexedConnectionTracer creates a new connection tracer that multiplexes events to multiple tracers.
+// It returns nil if no tracers are passed.
+func NewMultiplexedConnectionTracer(tracers ...ConnectionTracer) ConnectionTracer {
+	if len(tracers) == 0 {
+		return nil
+	}
+	if len(tracers) == 1 {
+		return tracers[0]
+	}
+	return &connTracerMultiplexer{tracers: tracers}
+}
+
+func (m *connTracerMultiplexer) SentPacket(hdr *Header, size ByteCount, encLevel EncryptionLevel, frames []Frame) {
+	for _, t := range m.tracers {
+		t.SentPacket(hdr, size, encLevel, frames)
+	}
+}
+
+func (m *connTracerMultiplexer) ReceivedPacket(hdr *Header, size ByteCount, encLevel EncryptionLevel, frames []Frame) {
+	for _, t := range m.tracers {
+		t.ReceivedPacket(hdr, size, encLevel, frames)
+	}
+}
+
+func (m *connTracerMultiplexer) LostPacket(encLevel EncryptionLevel, pn PacketNumber, reason PacketLossReason) {
+	for _, t := range m.tracers {
+		t.LostPacket(encLevel, pn, reason)
+	}
+}
+
+func (m *connTracerMultiplexer) UpdatedRTT(rttStats *RTTStats) {
+	for _, t := range m.tracers {
+		t.UpdatedRTT(rttStats)
+	}
+}
+
+func (m *connTracerMultiplexer) UpdatedCongestionWindow(cwnd ByteCount) {
+	for _, t := range m.tracers {
+		t.UpdatedCongestionWindow(cwnd)
+	}
+}
+
+func (m *connTracerMultiplexer) ClosedConnection(err error, remote bool) {
+	for _, t := range m.tracers {
+		t.ClosedConnection(err, remote)
+	}
+}
+
+func (m *connTracerMultiplexer) Close() {
+	for _, t := range m.tracers {
+		t.Close()
+	}
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingTracer records the names of the methods that were called
+type recordingTracer struct {
+	events []string
+}
+
+var _ ConnectionTracer = &recordingTracer{}
+
+func (t *recordingTracer) SentPacket(*Header, ByteCount, EncryptionLevel, []Frame) {
+	t.events = append(t.events, "SentPacket")
+}
+func (t *recordingTracer) ReceivedPacket(*Header, ByteCount, EncryptionLevel, []Frame) {
+	t.events = append(t.events, "ReceivedPacket")
+}
+func (t *recordingTracer) LostPacket(EncryptionLevel, PacketNumber, PacketLossReason) {
+	t.events = append(t.events, "LostPacket")
+}
+func (t *recordingTracer) UpdatedRTT(*RTTStats) { t.events = append(t.events, "UpdatedRTT") }
+func (t *recordingTracer) UpdatedCongestionWindow(ByteCount) {
+	t.events = append(t.events, "UpdatedCongestionWindow")
+}
+func (t *recordingTracer) ClosedConnection(error, bool) {
+	t.events = append(t.events, "ClosedConnection")
+}
+func (t *recordingTracer) Close() { t.events = append(t.events, "Close") }
+
+var _ = Describe("Tracing", func() {
+	It("returns nil if there are no tracers", func() {
+		Expect(NewMultiplexedConnectionTracer()).To(BeNil())
+	})
+
+	It("returns the raw tracer if there's only one tracer", func() {
+		tr := &recordingTracer{}
+		Expect(NewMultiplexedConnectionTracer(tr)).To(Equal(tr))
+	})
+
+	It("passes all events to all tracers", func() {
+		tr1 := &recordingTracer{}
+		tr2 := &recordingTracer{}
+		tracer := NewMultiplexedConnectionTracer(tr1, tr2)
+		tracer.SentPacket(&Header{}, 1337, EncryptionForwardSecure, nil)
+		tracer.ReceivedPacket(&Header{}, 42, EncryptionForwardSecure, nil)
+		tracer.LostPacket(EncryptionForwardSecure, 10, PacketLossRTO)
+		tracer.UpdatedRTT(&RTTStats{})
+		tracer.UpdatedCongestionWindow(1000)
+		tracer.ClosedConnection(errors.New("foobar"), true)
+		tracer.Close()
+		expected := []string{"SentPacket", "ReceivedPacket", "LostPacket", "UpdatedRTT", "UpdatedCongestionWindow", "ClosedConnection", "Close"}
+		Expect(tr1.events).To(Equal(expected))
+		Expect(tr2.events).To(Equal(expected))
+	})
+})
@@ -0,0 +1,22 @@
+package logging
+
+// PacketLossReason is the reason why a packet was declared lost
+type PacketLossReason uint8
+
+const (
+	// PacketLossTimeThreshold is used when a packet was declared lost by time-based loss detection
+	PacketLossTimeThreshold PacketLossReason = iota
+	// PacketLossRTO is used when a packet was declared lost due to a retransmission timeout
+	PacketLossRTO
+)
+
+func (r PacketLossReason) String() string {
+	switch r {
+	case PacketLossTimeThreshold:
+		return "time threshold"
+	case PacketLossRTO:
+		return "RTO"
+	default:
+		return "unknown reason"
+	}
+}
@@ -0,0 +1,14 @@
+package logging
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Types", func() {
+	It("has a string representation for the packet loss reason", func() {
+		Expect(PacketLossTimeThreshold.String()).To(Equal("time threshold"))
+		Expect(PacketLossRTO.String()).To(Equal("RTO"))
+		Expect(PacketLossReason(42).String()).To(Equal("unknown reason"))
+	})
+})
@@ -19,6 +19,9 @@ import (
 type mintController struct {
 	csc  *handshake.CryptoStreamConn
 	conn *mint.Conn
+
+	perspective protocol.Perspective
+	mconf       *mint.Config
 }
 
 var _ handshake.MintTLS = &mintController{}
@@ -35,8 +38,10 @@ func newMintController(
 		conn = mint.Server(csc, mconf)
 	}
 	return &mintController{
-		csc:  csc,
-		conn: conn,
+		csc:         csc,
+		conn:        conn,
+		perspective: pers,
+		mconf:       mconf,
 	}
 }
 
@@ -60,8 +65,19 @@ func (mc *mintController) ConnectionState() mint.ConnectionState {
 	return mc.conn.ConnectionState()
 }
 
+func (mc *mintController) Read(b []byte) (int, error) {
+	return mc.conn.Read(b)
+}
+
+// SetCryptoStream sets the stream that mint reads from and writes to.
+// The server uses mint in non-blocking mode to statelessly process the first flight.
+// Once the stream is set, reading blocks until data is available.
+// mint only sends session tickets when it is used in blocking mode.
 func (mc *mintController) SetCryptoStream(stream io.ReadWriter) {
 	mc.csc.SetStream(stream)
+	if mc.perspective == protocol.PerspectiveServer {
+		mc.mconf.NonBlocking = false
+	}
 }
 
 func tlsToMintConfig(tlsConf *tls.Config, pers protocol.Perspective) (*mint.Config, error) {
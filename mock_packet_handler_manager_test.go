@@ -0,0 +1,77 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/lucas-clemente/quic-go (interfaces: PacketHandlerManager)
+
+// Package quic is a generated GoMock package.
+package quic
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	protocol "github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// MockPacketHandlerManager is a mock of PacketHandlerManager interface
+type MockPacketHandlerManager struct {
+	ctrl     *gomock.Controller
+	recorder *MockPacketHandlerManagerMockRecorder
+}
+
+// MockPacketHandlerManagerMockRecorder is the mock recorder for MockPacketHandlerManager
+type MockPacketHandlerManagerMockRecorder struct {
+	mock *MockPacketHandlerManager
+}
+
+// NewMockPacketHandlerManager creates a new mock instance
+func NewMockPacketHandlerManager(ctrl *gomock.Controller) *MockPacketHandlerManager {
+	mock := &MockPacketHandlerManager{ctrl: ctrl}
+	mock.recorder = &MockPacketHandlerManagerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockPacketHandlerManager) EXPECT() *MockPacketHandlerManagerMockRecorder {
+	return m.recorder
+}
+
+// Add mocks base method
+func (m *MockPacketHandlerManager) Add(arg0 protocol.ConnectionID, arg1 packetReceiver) {
+	m.ctrl.Call(m, "Add", arg0, arg1)
+}
+
+// Add indicates an expected call of Add
+func (mr *MockPacketHandlerManagerMockRecorder) Add(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockPacketHandlerManager)(nil).Add), arg0, arg1)
+}
+
+// Remove mocks base method
+func (m *MockPacketHandlerManager) Remove(arg0 protocol.ConnectionID) {
+	m.ctrl.Call(m, "Remove", arg0)
+}
+
+// Remove indicates an expected call of Remove
+func (mr *MockPacketHandlerManagerMockRecorder) Remove(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockPacketHandlerManager)(nil).Remove), arg0)
+}
+
+// RemoveUnknownPacketHandler mocks base method
+func (m *MockPacketHandlerManager) RemoveUnknownPacketHandler() {
+	m.ctrl.Call(m, "RemoveUnknownPacketHandler")
+}
+
+// RemoveUnknownPacketHandler indicates an expected call of RemoveUnknownPacketHandler
+func (mr *MockPacketHandlerManagerMockRecorder) RemoveUnknownPacketHandler() *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveUnknownPacketHandler", reflect.TypeOf((*MockPacketHandlerManager)(nil).RemoveUnknownPacketHandler))
+}
+
+// SetUnknownPacketHandler mocks base method
+func (m *MockPacketHandlerManager) SetUnknownPacketHandler(arg0 packetReceiver) error {
+	ret := m.ctrl.Call(m, "SetUnknownPacketHandler", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUnknownPacketHandler indicates an expected call of SetUnknownPacketHandler
+func (mr *MockPacketHandlerManagerMockRecorder) SetUnknownPacketHandler(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUnknownPacketHandler", reflect.TypeOf((*MockPacketHandlerManager)(nil).SetUnknownPacketHandler), arg0)
+}
@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/lucas-clemente/quic-go (interfaces: SessionRunner)
+
+// Package quic is a generated GoMock package.
+package quic
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	protocol "github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// MockSessionRunner is a mock of SessionRunner interface
+type MockSessionRunner struct {
+	ctrl     *gomock.Controller
+	recorder *MockSessionRunnerMockRecorder
+}
+
+// MockSessionRunnerMockRecorder is the mock recorder for MockSessionRunner
+type MockSessionRunnerMockRecorder struct {
+	mock *MockSessionRunner
+}
+
+// NewMockSessionRunner creates a new mock instance
+func NewMockSessionRunner(ctrl *gomock.Controller) *MockSessionRunner {
+	mock := &MockSessionRunner{ctrl: ctrl}
+	mock.recorder = &MockSessionRunnerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockSessionRunner) EXPECT() *MockSessionRunnerMockRecorder {
+	return m.recorder
+}
+
+// addConnectionID mocks base method
+func (m *MockSessionRunner) addConnectionID(arg0 protocol.ConnectionID, arg1 packetHandler) {
+	m.ctrl.Call(m, "addConnectionID", arg0, arg1)
+}
+
+// addConnectionID indicates an expected call of addConnectionID
+func (mr *MockSessionRunnerMockRecorder) addConnectionID(arg0, arg1 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "addConnectionID", reflect.TypeOf((*MockSessionRunner)(nil).addConnectionID), arg0, arg1)
+}
+
+// retireConnectionID mocks base method
+func (m *MockSessionRunner) retireConnectionID(arg0 protocol.ConnectionID) {
+	m.ctrl.Call(m, "retireConnectionID", arg0)
+}
+
+// retireConnectionID indicates an expected call of retireConnectionID
+func (mr *MockSessionRunnerMockRecorder) retireConnectionID(arg0 interface{}) *gomock.Call {
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "retireConnectionID", reflect.TypeOf((*MockSessionRunner)(nil).retireConnectionID), arg0)
+}
@@ -0,0 +1,57 @@
+package quic
+
+import (
+	"net"
+	"sync"
+)
+
+var (
+	connMuxerOnce sync.Once
+	connMuxer     *connMultiplexer
+)
+
+// The connMultiplexer makes it possible to use a single net.PacketConn for multiple clients and one server.
+// For every net.PacketConn, one packetHandlerManager reads packets, and passes them on to the clients and the server.
+type connMultiplexer struct {
+	mutex sync.Mutex
+
+	conns                   map[net.PacketConn]packetHandlerManager
+	newPacketHandlerManager func(net.PacketConn, func()) packetHandlerManager // so it can be replaced in the tests
+}
+
+func getMultiplexer() *connMultiplexer {
+	connMuxerOnce.Do(func() {
+		connMuxer = newConnMultiplexer()
+	})
+	return connMuxer
+}
+
+func newConnMultiplexer() *connMultiplexer {
+	return &connMultiplexer{
+		conns:                   make(map[net.PacketConn]packetHandlerManager),
+		newPacketHandlerManager: newPacketHandlerMap,
+	}
+}
+
+// AddConn returns the packetHandlerManager for a net.PacketConn.
+// A new packetHandlerManager is created when the net.PacketConn is used for the first time.
+// It is removed as soon as reading from the net.PacketConn fails, e.g. because it was closed.
+func (m *connMultiplexer) AddConn(c net.PacketConn) packetHandlerManager {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if p, ok := m.conns[c]; ok {
+		return p
+	}
+	var p packetHandlerManager
+	p = m.newPacketHandlerManager(c, func() {
+		m.mutex.Lock()
+		// the net.PacketConn might already be used by a new packetHandlerManager
+		if m.conns[c] == p {
+			delete(m.conns, c)
+		}
+		m.mutex.Unlock()
+	})
+	m.conns[c] = p
+	return p
+}
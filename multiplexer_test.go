@@ -0,0 +1,61 @@
+package quic
+
+import (
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client Multiplexer", func() {
+	var muxer *connMultiplexer
+
+	BeforeEach(func() {
+		muxer = newConnMultiplexer()
+	})
+
+	It("creates a new packet handler manager for every connection", func() {
+		conn1 := newMockPacketConn()
+		conn2 := newMockPacketConn()
+		defer conn1.Close()
+		defer conn2.Close()
+		Expect(muxer.AddConn(conn1)).ToNot(BeIdenticalTo(muxer.AddConn(conn2)))
+	})
+
+	It("reuses the packet handler manager of a connection", func() {
+		conn := newMockPacketConn()
+		defer conn.Close()
+		Expect(muxer.AddConn(conn)).To(BeIdenticalTo(muxer.AddConn(conn)))
+	})
+
+	It("removes the connection when reading from it fails", func() {
+		conn := newMockPacketConn()
+		muxer.AddConn(conn)
+		isRegistered := func() bool {
+			muxer.mutex.Lock()
+			defer muxer.mutex.Unlock()
+			_, ok := muxer.conns[conn]
+			return ok
+		}
+		Expect(isRegistered()).To(BeTrue())
+		conn.Close()
+		Eventually(isRegistered).Should(BeFalse())
+	})
+
+	It("doesn't remove a new packet handler manager for the same connection", func() {
+		conn := newMockPacketConn()
+		var onClose func()
+		muxer.newPacketHandlerManager = func(_ net.PacketConn, f func()) packetHandlerManager {
+			onClose = f
+			return NewMockPacketHandlerManager(mockCtrl)
+		}
+		muxer.AddConn(conn)
+		oldOnClose := onClose
+		muxer.mutex.Lock()
+		delete(muxer.conns, conn)
+		muxer.mutex.Unlock()
+		manager := muxer.AddConn(conn)
+		oldOnClose()
+		Expect(muxer.AddConn(conn)).To(BeIdenticalTo(manager))
+	})
+})
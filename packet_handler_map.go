@@ -0,0 +1,162 @@
+package quic
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+// A packetReceiver receives the packets that the packetHandlerMap reads from a net.PacketConn.
+// It is implemented by the client and the server.
+type packetReceiver interface {
+	handlePacket(remoteAddr net.Addr, data []byte, ecn protocol.ECN)
+	// closeWithError is called when reading from the net.PacketConn fails
+	closeWithError(error)
+}
+
+// The packetHandlerManager routes the packets received on a net.PacketConn.
+// Packets are passed to the packetReceiver that registered their connection ID.
+// All other packets are passed to the packetReceiver for unknown packets (usually the server).
+type packetHandlerManager interface {
+	Add(protocol.ConnectionID, packetReceiver)
+	Remove(protocol.ConnectionID)
+	SetUnknownPacketHandler(packetReceiver) error
+	RemoveUnknownPacketHandler()
+}
+
+// The packetHandlerMap reads packets from a net.PacketConn.
+type packetHandlerMap struct {
+	mutex sync.RWMutex
+
+	conn       net.PacketConn
+	readPacket packetReadFunc
+	onClose    func() // called when reading from the conn fails
+
+	handlers       map[protocol.ConnectionID]packetReceiver
+	unknownHandler packetReceiver
+
+	deleteClosedHandlersAfter time.Duration
+}
+
+var _ packetHandlerManager = &packetHandlerMap{}
+
+func newPacketHandlerMap(conn net.PacketConn, onClose func()) packetHandlerManager {
+	m := &packetHandlerMap{
+		conn:                      conn,
+		readPacket:                newPacketReadFunc(conn),
+		onClose:                   onClose,
+		handlers:                  make(map[protocol.ConnectionID]packetReceiver),
+		deleteClosedHandlersAfter: protocol.ClosedSessionDeleteTimeout,
+	}
+	go m.listen()
+	return m
+}
+
+func (h *packetHandlerMap) Add(id protocol.ConnectionID, handler packetReceiver) {
+	h.mutex.Lock()
+	h.handlers[id] = handler
+	h.mutex.Unlock()
+}
+
+// Remove removes a connection ID.
+// Packets that arrive for this connection ID in the next time are dropped,
+// instead of being passed to the handler for unknown packets.
+func (h *packetHandlerMap) Remove(id protocol.ConnectionID) {
+	h.mutex.Lock()
+	h.handlers[id] = nil
+	h.mutex.Unlock()
+
+	time.AfterFunc(h.deleteClosedHandlersAfter, func() {
+		h.mutex.Lock()
+		if h.handlers[id] == nil {
+			delete(h.handlers, id)
+		}
+		h.mutex.Unlock()
+	})
+}
+
+// SetUnknownPacketHandler sets the handler for packets with connection IDs that were not registered.
+// There can only be one such handler per net.PacketConn.
+func (h *packetHandlerMap) SetUnknownPacketHandler(handler packetReceiver) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.unknownHandler != nil {
+		return errors.New("only one server can listen on a net.PacketConn")
+	}
+	h.unknownHandler = handler
+	return nil
+}
+
+func (h *packetHandlerMap) RemoveUnknownPacketHandler() {
+	h.mutex.Lock()
+	h.unknownHandler = nil
+	h.mutex.Unlock()
+}
+
+func (h *packetHandlerMap) listen() {
+	for {
+		data := *getPacketBuffer()
+		data = data[:protocol.MaxReceivePacketSize]
+		// The packet size should not exceed protocol.MaxReceivePacketSize bytes
+		// If it does, we only read a truncated packet, which will then end up undecryptable
+		n, addr, ecn, err := h.readPacket(data)
+		if err != nil {
+			h.close(err)
+			return
+		}
+		h.handlePacket(addr, data[:n], ecn)
+	}
+}
+
+func (h *packetHandlerMap) handlePacket(addr net.Addr, data []byte, ecn protocol.ECN) {
+	connID, hasConnID := wire.PeekConnectionID(data)
+
+	h.mutex.RLock()
+	var handler packetReceiver
+	var known bool
+	if hasConnID {
+		handler, known = h.handlers[connID]
+	}
+	unknownHandler := h.unknownHandler
+	h.mutex.RUnlock()
+
+	if known {
+		if handler == nil {
+			// Late packet for a closed connection
+			return
+		}
+		handler.handlePacket(addr, data, ecn)
+		return
+	}
+	if unknownHandler == nil {
+		utils.Debugf("Dropping packet from %s for unknown connection %x", addr, connID)
+		return
+	}
+	unknownHandler.handlePacket(addr, data, ecn)
+}
+
+// close is called when reading from the net.PacketConn fails.
+// All handlers are notified. Every handler is only notified once, even if it registered multiple connection IDs.
+func (h *packetHandlerMap) close(e error) {
+	h.mutex.Lock()
+	handlers := make(map[packetReceiver]struct{})
+	for _, handler := range h.handlers {
+		if handler != nil {
+			handlers[handler] = struct{}{}
+		}
+	}
+	if h.unknownHandler != nil {
+		handlers[h.unknownHandler] = struct{}{}
+	}
+	h.mutex.Unlock()
+
+	for handler := range handlers {
+		handler.closeWithError(e)
+	}
+	h.onClose()
+}
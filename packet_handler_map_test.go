@@ -0,0 +1,184 @@
+package quic
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Packet Handler Map", func() {
+	var (
+		handler *packetHandlerMap
+		conn    *mockPacketConn
+		closed  chan struct{}
+	)
+
+	getPacket := func(connID protocol.ConnectionID) []byte {
+		buf := &bytes.Buffer{}
+		err := (&wire.Header{
+			ConnectionID:    connID,
+			PacketNumber:    1,
+			PacketNumberLen: protocol.PacketNumberLen1,
+		}).Write(buf, protocol.PerspectiveServer, versionGQUICFrames)
+		Expect(err).ToNot(HaveOccurred())
+		return buf.Bytes()
+	}
+
+	BeforeEach(func() {
+		conn = newMockPacketConn()
+		closed = make(chan struct{})
+		handler = newPacketHandlerMap(conn, func() { close(closed) }).(*packetHandlerMap)
+	})
+
+	AfterEach(func() {
+		// close the connection, so that the listen go routine returns
+		conn.Close()
+		Eventually(closed).Should(BeClosed())
+	})
+
+	It("handles packets for different connection IDs", func() {
+		handledPacket1 := make(chan struct{})
+		handledPacket2 := make(chan struct{})
+		packetHandler1 := NewMockPacketReceiver(mockCtrl)
+		packetHandler2 := NewMockPacketReceiver(mockCtrl)
+		packetHandler1.EXPECT().handlePacket(gomock.Any(), getPacket(1), protocol.ECNNon).Do(func(interface{}, interface{}, interface{}) {
+			close(handledPacket1)
+		})
+		packetHandler2.EXPECT().handlePacket(gomock.Any(), getPacket(2), protocol.ECNNon).Do(func(interface{}, interface{}, interface{}) {
+			close(handledPacket2)
+		})
+		packetHandler1.EXPECT().closeWithError(gomock.Any())
+		packetHandler2.EXPECT().closeWithError(gomock.Any())
+		handler.Add(1, packetHandler1)
+		handler.Add(2, packetHandler2)
+		conn.dataToRead <- getPacket(1)
+		conn.dataToRead <- getPacket(2)
+		Eventually(handledPacket1).Should(BeClosed())
+		Eventually(handledPacket2).Should(BeClosed())
+	})
+
+	It("passes packets for unknown connection IDs to the unknown packet handler", func() {
+		handledPacket := make(chan struct{})
+		packetHandler := NewMockPacketReceiver(mockCtrl)
+		packetHandler.EXPECT().handlePacket(gomock.Any(), getPacket(1337), protocol.ECNNon).Do(func(interface{}, interface{}, interface{}) {
+			close(handledPacket)
+		})
+		packetHandler.EXPECT().closeWithError(gomock.Any())
+		Expect(handler.SetUnknownPacketHandler(packetHandler)).To(Succeed())
+		conn.dataToRead <- getPacket(1337)
+		Eventually(handledPacket).Should(BeClosed())
+	})
+
+	It("passes packets with an omitted connection ID to the unknown packet handler", func() {
+		buf := &bytes.Buffer{}
+		err := (&wire.Header{
+			OmitConnectionID: true,
+			PacketNumber:     1,
+			PacketNumberLen:  protocol.PacketNumberLen1,
+		}).Write(buf, protocol.PerspectiveServer, versionGQUICFrames)
+		Expect(err).ToNot(HaveOccurred())
+		buf.Write([]byte("foobar"))
+		handledPacket := make(chan struct{})
+		packetHandler := NewMockPacketReceiver(mockCtrl)
+		packetHandler.EXPECT().handlePacket(gomock.Any(), buf.Bytes(), protocol.ECNNon).Do(func(interface{}, interface{}, interface{}) {
+			close(handledPacket)
+		})
+		packetHandler.EXPECT().closeWithError(gomock.Any())
+		Expect(handler.SetUnknownPacketHandler(packetHandler)).To(Succeed())
+		conn.dataToRead <- buf.Bytes()
+		Eventually(handledPacket).Should(BeClosed())
+	})
+
+	It("only allows one unknown packet handler", func() {
+		packetHandler := NewMockPacketReceiver(mockCtrl)
+		packetHandler.EXPECT().closeWithError(gomock.Any())
+		Expect(handler.SetUnknownPacketHandler(packetHandler)).To(Succeed())
+		err := handler.SetUnknownPacketHandler(NewMockPacketReceiver(mockCtrl))
+		Expect(err).To(MatchError("only one server can listen on a net.PacketConn"))
+	})
+
+	It("allows setting a new unknown packet handler after the old one was removed", func() {
+		Expect(handler.SetUnknownPacketHandler(NewMockPacketReceiver(mockCtrl))).To(Succeed())
+		handler.RemoveUnknownPacketHandler()
+		packetHandler := NewMockPacketReceiver(mockCtrl)
+		packetHandler.EXPECT().closeWithError(gomock.Any())
+		Expect(handler.SetUnknownPacketHandler(packetHandler)).To(Succeed())
+	})
+
+	It("drops packets for unknown connection IDs if there's no unknown packet handler", func() {
+		packetHandler := NewMockPacketReceiver(mockCtrl)
+		packetHandler.EXPECT().closeWithError(gomock.Any())
+		handler.Add(1, packetHandler)
+		conn.dataToRead <- getPacket(2)
+		Eventually(conn.dataToRead).Should(BeEmpty())
+		time.Sleep(10 * time.Millisecond) // the mock would fail if the packet was passed to a handler
+	})
+
+	It("drops packets for removed connection IDs, instead of passing them to the unknown packet handler", func() {
+		handler.deleteClosedHandlersAfter = time.Hour
+		unknownHandler := NewMockPacketReceiver(mockCtrl)
+		unknownHandler.EXPECT().closeWithError(gomock.Any())
+		Expect(handler.SetUnknownPacketHandler(unknownHandler)).To(Succeed())
+		handler.Add(1, NewMockPacketReceiver(mockCtrl))
+		handler.Remove(1)
+		conn.dataToRead <- getPacket(1)
+		Eventually(conn.dataToRead).Should(BeEmpty())
+		time.Sleep(10 * time.Millisecond) // the mock would fail if the packet was passed to a handler
+	})
+
+	It("deletes removed connection IDs after a while", func() {
+		handler.deleteClosedHandlersAfter = 10 * time.Millisecond
+		handler.Add(1, NewMockPacketReceiver(mockCtrl))
+		handler.Remove(1)
+		handler.mutex.RLock()
+		Expect(handler.handlers).To(HaveKey(protocol.ConnectionID(1)))
+		handler.mutex.RUnlock()
+		Eventually(func() int {
+			handler.mutex.RLock()
+			defer handler.mutex.RUnlock()
+			return len(handler.handlers)
+		}).Should(BeZero())
+	})
+
+	It("doesn't delete connection IDs that were added again", func() {
+		handler.deleteClosedHandlersAfter = 10 * time.Millisecond
+		packetHandler := NewMockPacketReceiver(mockCtrl)
+		packetHandler.EXPECT().closeWithError(gomock.Any())
+		handler.Add(1, packetHandler)
+		handler.Remove(1)
+		handler.Add(1, packetHandler)
+		Consistently(func() int {
+			handler.mutex.RLock()
+			defer handler.mutex.RUnlock()
+			return len(handler.handlers)
+		}).Should(Equal(1))
+	})
+
+	It("closes all handlers when reading from the conn fails", func() {
+		done := make(chan struct{}, 2)
+		packetHandler := NewMockPacketReceiver(mockCtrl)
+		unknownHandler := NewMockPacketReceiver(mockCtrl)
+		// the handler is only closed once, although it registered two connection IDs
+		packetHandler.EXPECT().closeWithError(gomock.Any()).Do(func(err error) {
+			defer GinkgoRecover()
+			Expect(err).To(MatchError("i/o timeout"))
+			done <- struct{}{}
+		})
+		unknownHandler.EXPECT().closeWithError(gomock.Any()).Do(func(err error) {
+			defer GinkgoRecover()
+			Expect(err).To(MatchError("i/o timeout"))
+			done <- struct{}{}
+		})
+		handler.Add(1, packetHandler)
+		handler.Add(2, packetHandler)
+		Expect(handler.SetUnknownPacketHandler(unknownHandler)).To(Succeed())
+		conn.SetReadDeadline(time.Now()) // makes the ReadFrom return an error
+		Eventually(closed).Should(BeClosed())
+		Expect(done).To(HaveLen(2))
+	})
+})
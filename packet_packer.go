@@ -9,6 +9,7 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/ackhandler"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 )
 
@@ -17,6 +18,10 @@ type packedPacket struct {
 	raw             []byte
 	frames          []wire.Frame
 	encryptionLevel protocol.EncryptionLevel
+	// isMTUProbePacket is set for packets that only contain a PING frame, padded to the size that is probed for
+	isMTUProbePacket bool
+	// isRetransmission is set by the session for packets that carry retransmitted frames
+	isRetransmission bool
 }
 
 type streamFrameSource interface {
@@ -33,6 +38,7 @@ type packetPacker struct {
 
 	packetNumberGenerator *packetNumberGenerator
 	streams               streamFrameSource
+	datagramQueue         *datagramQueue
 
 	controlFrameMutex sync.Mutex
 	controlFrames     []wire.Frame
@@ -44,12 +50,15 @@ type packetPacker struct {
 	spinBit                   bool
 	hasSentPacket             bool // has the packetPacker already sent a packet
 	numNonRetransmittableAcks int
+	// the maximum size of forward-secure packets, as confirmed by path MTU discovery
+	maxPacketSize protocol.ByteCount
 }
 
 func newPacketPacker(connectionID protocol.ConnectionID,
 	initialPacketNumber protocol.PacketNumber,
 	cryptoSetup handshake.CryptoSetup,
 	streamFramer streamFrameSource,
+	datagramQueue *datagramQueue,
 	perspective protocol.Perspective,
 	version protocol.VersionNumber,
 ) *packetPacker {
@@ -59,10 +68,46 @@ func newPacketPacker(connectionID protocol.ConnectionID,
 		perspective:           perspective,
 		version:               version,
 		streams:               streamFramer,
+		datagramQueue:         datagramQueue,
 		packetNumberGenerator: newPacketNumberGenerator(initialPacketNumber, protocol.SkipPacketAveragePeriodLength),
+		maxPacketSize:         protocol.InitialPacketSize,
 	}
 }
 
+// PackPathChallenge packs a packet that ONLY contains a PATH_CHALLENGE frame.
+// It is sent on a path that has not been validated yet.
+func (p *packetPacker) PackPathChallenge(f *wire.PathChallengeFrame) (*packedPacket, error) {
+	frames := []wire.Frame{f}
+	encLevel, sealer := p.cryptoSetup.GetSealer()
+	header := p.getHeader(encLevel)
+	raw, err := p.writeAndSealPacket(header, frames, sealer)
+	return &packedPacket{
+		header:          header,
+		raw:             raw,
+		frames:          frames,
+		encryptionLevel: encLevel,
+	}, err
+}
+
+// PackMTUProbePacket packs a packet that ONLY contains a PING frame, padded to the size that is probed for.
+// The size may be larger than the maximum packet size.
+func (p *packetPacker) PackMTUProbePacket(size protocol.ByteCount) (*packedPacket, error) {
+	frames := []wire.Frame{&wire.PingFrame{}}
+	encLevel, sealer := p.cryptoSetup.GetSealer()
+	if encLevel != protocol.EncryptionForwardSecure {
+		return nil, errors.New("PacketPacker BUG: MTU probe packets must be forward-secure")
+	}
+	header := p.getHeader(encLevel)
+	raw, err := p.writeAndSealPaddedPacket(header, frames, sealer, size)
+	return &packedPacket{
+		header:           header,
+		raw:              raw,
+		frames:           frames,
+		encryptionLevel:  encLevel,
+		isMTUProbePacket: true,
+	}, err
+}
+
 // PackConnectionClose packs a packet that ONLY contains a ConnectionCloseFrame
 func (p *packetPacker) PackConnectionClose(ccf *wire.ConnectionCloseFrame) (*packedPacket, error) {
 	frames := []wire.Frame{ccf}
@@ -160,7 +205,7 @@ func (p *packetPacker) PackPacket() (*packedPacket, error) {
 		p.stopWaiting.PacketNumberLen = header.PacketNumberLen
 	}
 
-	maxSize := protocol.MaxPacketSize - protocol.ByteCount(sealer.Overhead()) - headerLength
+	maxSize := p.maxPacketSize - protocol.ByteCount(sealer.Overhead()) - headerLength
 	payloadFrames, err := p.composeNextPacket(maxSize, p.canSendData(encLevel))
 	if err != nil {
 		return nil, err
@@ -209,7 +254,7 @@ func (p *packetPacker) packCryptoPacket() (*packedPacket, error) {
 	if err != nil {
 		return nil, err
 	}
-	maxLen := protocol.MaxPacketSize - protocol.ByteCount(sealer.Overhead()) - protocol.NonForwardSecurePacketSizeReduction - headerLength
+	maxLen := protocol.InitialPacketSize - protocol.ByteCount(sealer.Overhead()) - protocol.NonForwardSecurePacketSizeReduction - headerLength
 	sf := p.streams.PopCryptoStreamFrame(maxLen)
 	sf.DataLenPresent = false
 	frames := []wire.Frame{sf}
@@ -264,6 +309,17 @@ func (p *packetPacker) composeNextPacket(
 		return payloadFrames, nil
 	}
 
+	// DATAGRAM frames are sent before STREAM frames, since they usually carry latency-sensitive data.
+	// SendMessage makes sure that a DATAGRAM frame always fits into an otherwise empty packet.
+	// If it doesn't fit into this packet, it is sent in the next one.
+	if f := p.datagramQueue.Peek(); f != nil {
+		if length := f.Length(p.version); payloadLength+length <= maxFrameSize {
+			payloadFrames = append(payloadFrames, f)
+			payloadLength += length
+			p.datagramQueue.Pop()
+		}
+	}
+
 	// temporarily increase the maxFrameSize by the (minimum) length of the DataLen field
 	// this leads to a properly sized packet in all cases, since we do all the packet length calculations with StreamFrames that have the DataLen set
 	// however, for the last StreamFrame in the packet, we can omit the DataLen, thus yielding a packet of exactly the correct size
@@ -313,13 +369,19 @@ func (p *packetPacker) getHeader(encLevel protocol.EncryptionLevel) *wire.Header
 	if p.version.UsesTLS() && encLevel != protocol.EncryptionForwardSecure {
 		header.PacketNumberLen = protocol.PacketNumberLen4
 		header.IsLongHeader = true
-		if !p.hasSentPacket && p.perspective == protocol.PerspectiveClient {
+		if encLevel == protocol.EncryptionSecure {
+			header.Type = protocol.PacketType0RTT
+		} else if !p.hasSentPacket && p.perspective == protocol.PerspectiveClient {
 			header.Type = protocol.PacketTypeInitial
 		} else {
 			header.Type = protocol.PacketTypeHandshake
 		}
 	}
 
+	if p.version.UsesTLS() && encLevel == protocol.EncryptionForwardSecure {
+		header.KeyPhase = p.cryptoSetup.KeyPhase()
+	}
+
 	if p.omitConnectionID && encLevel == protocol.EncryptionForwardSecure {
 		header.OmitConnectionID = true
 	}
@@ -343,6 +405,23 @@ func (p *packetPacker) writeAndSealPacket(
 	header *wire.Header,
 	payloadFrames []wire.Frame,
 	sealer handshake.Sealer,
+) ([]byte, error) {
+	var paddedSize protocol.ByteCount
+	// if this is an IETF QUIC Initial packet, we need to pad it to fulfill the minimum size requirement
+	// in gQUIC, padding is handled in the CHLO
+	if header.Type == protocol.PacketTypeInitial {
+		paddedSize = protocol.MinInitialPacketSize
+	}
+	return p.writeAndSealPaddedPacket(header, payloadFrames, sealer, paddedSize)
+}
+
+// writeAndSealPaddedPacket writes a packet, and pads it to paddedSize bytes.
+// If paddedSize is 0, the packet is not padded.
+func (p *packetPacker) writeAndSealPaddedPacket(
+	header *wire.Header,
+	payloadFrames []wire.Frame,
+	sealer handshake.Sealer,
+	paddedSize protocol.ByteCount,
 ) ([]byte, error) {
 	raw := *getPacketBuffer()
 	buffer := bytes.NewBuffer(raw[:0])
@@ -352,8 +431,8 @@ func (p *packetPacker) writeAndSealPacket(
 	}
 	payloadStartIndex := buffer.Len()
 
-	// the Initial packet needs to be padded, so the last STREAM frame must have the data length present
-	if header.Type == protocol.PacketTypeInitial {
+	// if the packet is padded, the last STREAM frame must have the data length present
+	if paddedSize > 0 {
 		lastFrame := payloadFrames[len(payloadFrames)-1]
 		if sf, ok := lastFrame.(*wire.StreamFrame); ok {
 			sf.DataLenPresent = true
@@ -364,17 +443,13 @@ func (p *packetPacker) writeAndSealPacket(
 			return nil, err
 		}
 	}
-	// if this is an IETF QUIC Initial packet, we need to pad it to fulfill the minimum size requirement
-	// in gQUIC, padding is handled in the CHLO
-	if header.Type == protocol.PacketTypeInitial {
-		paddingLen := protocol.MinInitialPacketSize - sealer.Overhead() - buffer.Len()
-		if paddingLen > 0 {
-			buffer.Write(bytes.Repeat([]byte{0}, paddingLen))
-		}
+	if paddingLen := int(paddedSize) - sealer.Overhead() - buffer.Len(); paddingLen > 0 {
+		buffer.Write(bytes.Repeat([]byte{0}, paddingLen))
 	}
 
-	if size := protocol.ByteCount(buffer.Len() + sealer.Overhead()); size > protocol.MaxPacketSize {
-		return nil, fmt.Errorf("PacketPacker BUG: packet too large (%d bytes, allowed %d bytes)", size, protocol.MaxPacketSize)
+	maxSize := utils.MaxByteCount(p.maxPacketSize, paddedSize)
+	if size := protocol.ByteCount(buffer.Len() + sealer.Overhead()); size > maxSize {
+		return nil, fmt.Errorf("PacketPacker BUG: packet too large (%d bytes, allowed %d bytes)", size, maxSize)
 	}
 
 	raw = raw[0:buffer.Len()]
@@ -407,3 +482,14 @@ func (p *packetPacker) SetOmitConnectionID() {
 func (p *packetPacker) SetSpinBit(value bool) {
 	p.spinBit = value
 }
+
+// SetMaxPacketSize sets the maximum size of forward-secure packets.
+// It is called when path MTU discovery confirms a new packet size.
+func (p *packetPacker) SetMaxPacketSize(s protocol.ByteCount) {
+	p.maxPacketSize = s
+}
+
+// SetConnectionID sets the connection ID used for all packets packed from now on.
+func (p *packetPacker) SetConnectionID(connID protocol.ConnectionID) {
+	p.connectionID = connID
+}
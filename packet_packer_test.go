@@ -28,6 +28,7 @@ type mockCryptoSetup struct {
 	divNonce           []byte
 	encLevelSeal       protocol.EncryptionLevel
 	encLevelSealCrypto protocol.EncryptionLevel
+	keyPhase           int
 }
 
 var _ handshake.CryptoSetup = &mockCryptoSetup{}
@@ -50,6 +51,8 @@ func (m *mockCryptoSetup) GetSealerWithEncryptionLevel(protocol.EncryptionLevel)
 func (m *mockCryptoSetup) DiversificationNonce() []byte            { return m.divNonce }
 func (m *mockCryptoSetup) SetDiversificationNonce(divNonce []byte) { m.divNonce = divNonce }
 func (m *mockCryptoSetup) ConnectionState() ConnectionState        { panic("not implemented") }
+func (m *mockCryptoSetup) KeyPhase() int                           { return m.keyPhase }
+func (m *mockCryptoSetup) UpdateKeys() error                       { panic("not implemented") }
 
 var _ = Describe("Packet packer", func() {
 	var (
@@ -57,6 +60,7 @@ var _ = Describe("Packet packer", func() {
 		publicHeaderLen  protocol.ByteCount
 		maxFrameSize     protocol.ByteCount
 		mockStreamFramer *MockStreamFrameSource
+		datagramQueue    *datagramQueue
 	)
 
 	BeforeEach(func() {
@@ -64,17 +68,19 @@ var _ = Describe("Packet packer", func() {
 		mockSender := NewMockStreamSender(mockCtrl)
 		mockSender.EXPECT().onHasStreamData(gomock.Any()).AnyTimes()
 		mockStreamFramer = NewMockStreamFrameSource(mockCtrl)
+		datagramQueue = newDatagramQueue(func() {})
 
 		packer = newPacketPacker(
 			0x1337,
 			1,
 			&mockCryptoSetup{encLevelSeal: protocol.EncryptionForwardSecure},
 			mockStreamFramer,
+			datagramQueue,
 			protocol.PerspectiveServer,
 			version,
 		)
 		publicHeaderLen = 1 + 8 + 2 // 1 flag byte, 8 connection ID, 2 packet number
-		maxFrameSize = protocol.MaxPacketSize - protocol.ByteCount((&mockSealer{}).Overhead()) - publicHeaderLen
+		maxFrameSize = protocol.InitialPacketSize - protocol.ByteCount((&mockSealer{}).Overhead()) - publicHeaderLen
 		packer.hasSentPacket = true
 		packer.version = version
 	})
@@ -195,12 +201,34 @@ var _ = Describe("Packet packer", func() {
 				Expect(h.Version).To(Equal(versionIETFHeader))
 			})
 
+			It("uses 0-RTT packets for packets sealed with the 0-RTT keys", func() {
+				packer.perspective = protocol.PerspectiveClient
+				h := packer.getHeader(protocol.EncryptionSecure)
+				Expect(h.IsLongHeader).To(BeTrue())
+				Expect(h.Type).To(Equal(protocol.PacketType0RTT))
+			})
+
+			It("doesn't use 0-RTT packets for unencrypted packets", func() {
+				packer.perspective = protocol.PerspectiveClient
+				h := packer.getHeader(protocol.EncryptionUnencrypted)
+				Expect(h.IsLongHeader).To(BeTrue())
+				Expect(h.Type).ToNot(Equal(protocol.PacketType0RTT))
+			})
+
 			It("uses the Short Header format for forward-secure packets", func() {
 				h := packer.getHeader(protocol.EncryptionForwardSecure)
 				Expect(h.IsLongHeader).To(BeFalse())
 				Expect(h.PacketNumberLen).To(BeNumerically(">", 0))
 			})
 
+			It("sets the key phase for forward-secure packets", func() {
+				h := packer.getHeader(protocol.EncryptionForwardSecure)
+				Expect(h.KeyPhase).To(BeZero())
+				packer.cryptoSetup.(*mockCryptoSetup).keyPhase = 1
+				h = packer.getHeader(protocol.EncryptionForwardSecure)
+				Expect(h.KeyPhase).To(Equal(1))
+			})
+
 			It("it omits the connection ID for forward-secure packets", func() {
 				h := packer.getHeader(protocol.EncryptionForwardSecure)
 				Expect(h.OmitConnectionID).To(BeFalse())
@@ -228,6 +256,50 @@ var _ = Describe("Packet packer", func() {
 		Expect(p.frames[0]).To(Equal(&ccf))
 	})
 
+	It("packs a PATH_CHALLENGE", func() {
+		f := &wire.PathChallengeFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}
+		// expect no mockStreamFramer.PopStreamFrames
+		packer.controlFrames = []wire.Frame{&wire.MaxDataFrame{ByteOffset: 0x42}}
+		p, err := packer.PackPathChallenge(f)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.frames).To(Equal([]wire.Frame{f}))
+		Expect(p.encryptionLevel).To(Equal(protocol.EncryptionForwardSecure))
+		Expect(packer.controlFrames).To(HaveLen(1))
+	})
+
+	It("packs MTU probe packets", func() {
+		// expect no mockStreamFramer.PopStreamFrames
+		packer.controlFrames = []wire.Frame{&wire.MaxDataFrame{ByteOffset: 0x42}}
+		p, err := packer.PackMTUProbePacket(1400)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.frames).To(Equal([]wire.Frame{&wire.PingFrame{}}))
+		Expect(p.raw).To(HaveLen(1400))
+		Expect(p.isMTUProbePacket).To(BeTrue())
+		Expect(packer.controlFrames).To(HaveLen(1))
+	})
+
+	It("only packs forward-secure MTU probe packets", func() {
+		packer.cryptoSetup.(*mockCryptoSetup).encLevelSeal = protocol.EncryptionSecure
+		_, err := packer.PackMTUProbePacket(1400)
+		Expect(err).To(MatchError("PacketPacker BUG: MTU probe packets must be forward-secure"))
+	})
+
+	It("uses the maximum packet size confirmed by path MTU discovery", func() {
+		packer.SetMaxPacketSize(1400)
+		mockStreamFramer.EXPECT().HasCryptoStreamData()
+		mockStreamFramer.EXPECT().PopStreamFrames(gomock.Any()).DoAndReturn(func(maxSize protocol.ByteCount) []*wire.StreamFrame {
+			f := &wire.StreamFrame{
+				StreamID:       5,
+				DataLenPresent: true,
+			}
+			f.Data = bytes.Repeat([]byte{'f'}, int(maxSize-f.Length(packer.version)))
+			return []*wire.StreamFrame{f}
+		})
+		p, err := packer.PackPacket()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(p.raw).To(HaveLen(1400))
+	})
+
 	It("doesn't send any other frames when sending a CONNECTION_CLOSE", func() {
 		// expect no mockStreamFramer.PopStreamFrames
 		ccf := &wire.ConnectionCloseFrame{
@@ -449,7 +521,7 @@ var _ = Describe("Packet packer", func() {
 			p, err := packer.PackPacket()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p.frames).To(HaveLen(1))
-			Expect(p.raw).To(HaveLen(int(protocol.MaxPacketSize)))
+			Expect(p.raw).To(HaveLen(int(protocol.InitialPacketSize)))
 			Expect(p.frames[0].(*wire.StreamFrame).DataLenPresent).To(BeFalse())
 			p, err = packer.PackPacket()
 			Expect(err).ToNot(HaveOccurred())
@@ -472,7 +544,7 @@ var _ = Describe("Packet packer", func() {
 			p, err := packer.PackPacket()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p.frames).To(HaveLen(1))
-			Expect(p.raw).To(HaveLen(int(protocol.MaxPacketSize)))
+			Expect(p.raw).To(HaveLen(int(protocol.InitialPacketSize)))
 			Expect(p.frames[0].(*wire.StreamFrame).DataLenPresent).To(BeFalse())
 			p, err = packer.PackPacket()
 			Expect(err).ToNot(HaveOccurred())
@@ -582,6 +654,72 @@ var _ = Describe("Packet packer", func() {
 		})
 	})
 
+	Context("DATAGRAM frame handling", func() {
+		var datagramQueued chan struct{}
+
+		BeforeEach(func() {
+			datagramQueued = make(chan struct{}, 1)
+			datagramQueue = newDatagramQueue(func() { datagramQueued <- struct{}{} })
+			datagramQueue.SetMaxFrameSize(protocol.MaxDatagramFrameSize)
+			packer.datagramQueue = datagramQueue
+		})
+
+		// queueDatagram queues a DATAGRAM frame, and returns a channel that is closed when AddAndWait returns
+		queueDatagram := func(f *wire.DatagramFrame) <-chan struct{} {
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(datagramQueue.AddAndWait(f)).To(Succeed())
+			}()
+			Eventually(datagramQueued).Should(Receive())
+			return done
+		}
+
+		It("packs DATAGRAM frames before STREAM frames", func() {
+			f := &wire.DatagramFrame{DataLenPresent: true, Data: []byte("foobar")}
+			done := queueDatagram(f)
+			sf := &wire.StreamFrame{StreamID: 5, Data: []byte("foobar")}
+			mockStreamFramer.EXPECT().HasCryptoStreamData()
+			mockStreamFramer.EXPECT().PopStreamFrames(gomock.Any()).Return([]*wire.StreamFrame{sf})
+			p, err := packer.PackPacket()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.frames).To(Equal([]wire.Frame{f, sf}))
+			Eventually(done).Should(BeClosed())
+			Expect(datagramQueue.Peek()).To(BeNil())
+		})
+
+		It("sends a DATAGRAM frame in the next packet, if it doesn't fit", func() {
+			f := &wire.DatagramFrame{DataLenPresent: true, Data: make([]byte, 1000)}
+			done := queueDatagram(f)
+			// fill the packet with control frames, such that there's only space for 500 more bytes
+			blockedFrame := &wire.BlockedFrame{}
+			var controlFrames []wire.Frame
+			for l := protocol.ByteCount(0); l+blockedFrame.Length(packer.version) <= maxFrameSize-500; l += blockedFrame.Length(packer.version) {
+				controlFrames = append(controlFrames, blockedFrame)
+			}
+			packer.controlFrames = controlFrames
+			mockStreamFramer.EXPECT().PopStreamFrames(gomock.Any()).Times(2)
+			payloadFrames, err := packer.composeNextPacket(maxFrameSize, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(payloadFrames).To(HaveLen(len(controlFrames)))
+			Expect(datagramQueue.Peek()).To(Equal(f))
+			Consistently(done).ShouldNot(BeClosed())
+			payloadFrames, err = packer.composeNextPacket(maxFrameSize, true)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(payloadFrames).To(Equal([]wire.Frame{f}))
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("doesn't pack DATAGRAM frames if STREAM frames are not allowed", func() {
+			f := &wire.DatagramFrame{DataLenPresent: true, Data: []byte("foobar")}
+			queueDatagram(f)
+			payloadFrames, err := packer.composeNextPacket(maxFrameSize, false)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(payloadFrames).To(BeEmpty())
+		})
+	})
+
 	It("packs a single ACK", func() {
 		mockStreamFramer.EXPECT().HasCryptoStreamData()
 		mockStreamFramer.EXPECT().PopStreamFrames(gomock.Any())
@@ -663,15 +801,15 @@ var _ = Describe("Packet packer", func() {
 			Expect(p.encryptionLevel).To(Equal(protocol.EncryptionSecure))
 		})
 
-		// this should never happen, since non forward-secure packets are limited to a size smaller than MaxPacketSize, such that it is always possible to retransmit them without splitting the StreamFrame
+		// this should never happen, since non forward-secure packets are limited to a size smaller than InitialPacketSize, such that it is always possible to retransmit them without splitting the StreamFrame
 		// (note that the retransmitted packet needs to have enough space for the StopWaitingFrame)
-		It("refuses to send a packet larger than MaxPacketSize", func() {
+		It("refuses to send a packet larger than InitialPacketSize", func() {
 			packet := &ackhandler.Packet{
 				EncryptionLevel: protocol.EncryptionSecure,
 				Frames: []wire.Frame{
 					&wire.StreamFrame{
 						StreamID: 1,
-						Data:     bytes.Repeat([]byte{'f'}, int(protocol.MaxPacketSize-5)),
+						Data:     bytes.Repeat([]byte{'f'}, int(protocol.InitialPacketSize-5)),
 					},
 				},
 			}
@@ -32,6 +32,10 @@ func (u *packetUnpacker) Unpack(headerBinary []byte, hdr *wire.Header, data []by
 		// Wrap err in quicError so that public reset is sent by session
 		return nil, qerr.Error(qerr.DecryptionFailure, err.Error())
 	}
+	// 0-RTT packets must be protected with the 0-RTT keys, and the 0-RTT keys must only be used for 0-RTT packets
+	if u.version.UsesTLS() && hdr.IsLongHeader && (hdr.Type == protocol.PacketType0RTT) != (encryptionLevel == protocol.EncryptionSecure) {
+		return nil, qerr.Error(qerr.InvalidPacketHeader, fmt.Sprintf("received %s packet with encryption level %s", hdr.Type, encryptionLevel))
+	}
 	r := bytes.NewReader(decrypted)
 
 	if r.Len() == 0 {
@@ -57,6 +61,12 @@ func (u *packetUnpacker) Unpack(headerBinary []byte, hdr *wire.Header, data []by
 				return nil, qerr.Error(qerr.UnencryptedStreamData, fmt.Sprintf("received unencrypted stream data on stream %d", sf.StreamID))
 			}
 		}
+		if _, ok := frame.(*wire.DatagramFrame); ok && encryptionLevel <= protocol.EncryptionUnencrypted {
+			return nil, qerr.Error(qerr.InvalidFrameData, "received unencrypted DATAGRAM frame")
+		}
+		if _, ok := frame.(*wire.AckFrequencyFrame); ok && encryptionLevel <= protocol.EncryptionUnencrypted {
+			return nil, qerr.Error(qerr.InvalidFrameData, "received unencrypted ACK_FREQUENCY frame")
+		}
 		if frame != nil {
 			fs = append(fs, frame)
 		}
@@ -129,16 +139,46 @@ func (u *packetUnpacker) parseIETFFrame(r *bytes.Reader, typeByte byte, hdr *wir
 		if err != nil {
 			err = qerr.Error(qerr.InvalidFrameData, err.Error())
 		}
+	case 0xb:
+		frame, err = wire.ParseNewConnectionIDFrame(r, u.version)
+		if err != nil {
+			err = qerr.Error(qerr.InvalidFrameData, err.Error())
+		}
 	case 0xc:
 		frame, err = wire.ParseStopSendingFrame(r, u.version)
 		if err != nil {
 			err = qerr.Error(qerr.InvalidFrameData, err.Error())
 		}
-	case 0xe:
+	case 0xd:
+		frame, err = wire.ParsePathChallengeFrame(r, u.version)
+		if err != nil {
+			err = qerr.Error(qerr.InvalidFrameData, err.Error())
+		}
+	case 0xe, 0x20:
 		frame, err = wire.ParseAckFrame(r, u.version)
 		if err != nil {
 			err = qerr.Error(qerr.InvalidAckData, err.Error())
 		}
+	case 0xf:
+		frame, err = wire.ParsePathResponseFrame(r, u.version)
+		if err != nil {
+			err = qerr.Error(qerr.InvalidFrameData, err.Error())
+		}
+	case 0x19:
+		frame, err = wire.ParseRetireConnectionIDFrame(r, u.version)
+		if err != nil {
+			err = qerr.Error(qerr.InvalidFrameData, err.Error())
+		}
+	case 0x1a:
+		frame, err = wire.ParseAckFrequencyFrame(r, u.version)
+		if err != nil {
+			err = qerr.Error(qerr.InvalidFrameData, err.Error())
+		}
+	case 0x30, 0x31:
+		frame, err = wire.ParseDatagramFrame(r, u.version)
+		if err != nil {
+			err = qerr.Error(qerr.InvalidFrameData, err.Error())
+		}
 	default:
 		err = qerr.Error(qerr.InvalidFrameData, fmt.Sprintf("unknown type byte 0x%x", typeByte))
 	}
@@ -194,6 +234,11 @@ func (u *packetUnpacker) parseGQUICFrame(r *bytes.Reader, typeByte byte, hdr *wi
 		}
 	case 0x7:
 		frame, err = wire.ParsePingFrame(r, u.version)
+	case 0x30, 0x31:
+		frame, err = wire.ParseDatagramFrame(r, u.version)
+		if err != nil {
+			err = qerr.Error(qerr.InvalidFrameData, err.Error())
+		}
 	default:
 		err = qerr.Error(qerr.InvalidFrameData, fmt.Sprintf("unknown type byte 0x%x", typeByte))
 	}
@@ -2,6 +2,7 @@ package quic
 
 import (
 	"bytes"
+	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/crypto"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
@@ -203,12 +204,54 @@ var _ = Describe("Packet unpacker", func() {
 			}))
 		})
 
+		It("unpacks DATAGRAM frames", func() {
+			unpacker.aead.(*mockAEAD).encLevelOpen = protocol.EncryptionForwardSecure
+			f := &wire.DatagramFrame{DataLenPresent: true, Data: []byte("foobar")}
+			err := f.Write(buf, versionGQUICFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			packet, err := unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(packet.frames).To(Equal([]wire.Frame{f}))
+		})
+
+		It("does not unpack unencrypted DATAGRAM frames", func() {
+			unpacker.aead.(*mockAEAD).encLevelOpen = protocol.EncryptionUnencrypted
+			f := &wire.DatagramFrame{Data: []byte("foobar")}
+			err := f.Write(buf, versionGQUICFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			_, err = unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).To(MatchError(qerr.Error(qerr.InvalidFrameData, "received unencrypted DATAGRAM frame")))
+		})
+
+		It("errors on ACK_FREQUENCY frames", func() {
+			f := &wire.AckFrequencyFrame{SequenceNumber: 1, PacketTolerance: 2, UpdateMaxAckDelay: 3 * time.Millisecond}
+			err := f.Write(buf, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			_, err = unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).To(MatchError("InvalidFrameData: unknown type byte 0x1a"))
+		})
+
 		It("errors on invalid type", func() {
 			setData([]byte{0xf})
 			_, err := unpacker.Unpack(hdrBin, hdr, data)
 			Expect(err).To(MatchError("InvalidFrameData: unknown type byte 0xf"))
 		})
 
+		It("errors on connection ID frames", func() {
+			f := &wire.RetireConnectionIDFrame{SequenceNumber: 0}
+			err := f.Write(buf, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			_, err = unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).To(MatchError("InvalidFrameData: unknown type byte 0x19"))
+			setData([]byte{0xb})
+			_, err = unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).To(MatchError("InvalidFrameData: unknown type byte 0xb"))
+		})
+
 		It("errors on invalid frames", func() {
 			for b, e := range map[byte]qerr.ErrorCode{
 				0x80: qerr.InvalidStreamData,
@@ -219,6 +262,8 @@ var _ = Describe("Packet unpacker", func() {
 				0x04: qerr.InvalidWindowUpdateData,
 				0x05: qerr.InvalidBlockedData,
 				0x06: qerr.InvalidStopWaitingData,
+				0x1a: qerr.InvalidFrameData,
+				0x31: qerr.InvalidFrameData,
 			} {
 				setData([]byte{b})
 				_, err := unpacker.Unpack(hdrBin, hdr, data)
@@ -315,6 +360,37 @@ var _ = Describe("Packet unpacker", func() {
 			Expect(packet.frames).To(Equal([]wire.Frame{f}))
 		})
 
+		Context("0-RTT packets", func() {
+			BeforeEach(func() {
+				hdr.IsLongHeader = true
+				f := &wire.ConnectionCloseFrame{ReasonPhrase: "foo"}
+				Expect(f.Write(buf, versionIETFFrames)).To(Succeed())
+				setData(buf.Bytes())
+			})
+
+			It("unpacks 0-RTT packets", func() {
+				hdr.Type = protocol.PacketType0RTT
+				unpacker.aead.(*mockAEAD).encLevelOpen = protocol.EncryptionSecure
+				packet, err := unpacker.Unpack(hdrBin, hdr, data)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(packet.encryptionLevel).To(Equal(protocol.EncryptionSecure))
+			})
+
+			It("errors if a 0-RTT packet isn't protected with the 0-RTT keys", func() {
+				hdr.Type = protocol.PacketType0RTT
+				unpacker.aead.(*mockAEAD).encLevelOpen = protocol.EncryptionUnencrypted
+				_, err := unpacker.Unpack(hdrBin, hdr, data)
+				Expect(err).To(MatchError(qerr.Error(qerr.InvalidPacketHeader, "received 0-RTT Protected packet with encryption level unencrypted")))
+			})
+
+			It("errors if a Handshake packet is protected with the 0-RTT keys", func() {
+				hdr.Type = protocol.PacketTypeHandshake
+				unpacker.aead.(*mockAEAD).encLevelOpen = protocol.EncryptionSecure
+				_, err := unpacker.Unpack(hdrBin, hdr, data)
+				Expect(err).To(MatchError(qerr.Error(qerr.InvalidPacketHeader, "received Handshake packet with encryption level encrypted (not forward-secure)")))
+			})
+		})
+
 		It("unpacks MAX_DATA frames", func() {
 			f := &wire.MaxDataFrame{
 				ByteOffset: 0xcafe,
@@ -400,6 +476,54 @@ var _ = Describe("Packet unpacker", func() {
 			Expect(packet.frames).To(Equal([]wire.Frame{f}))
 		})
 
+		It("unpacks PATH_CHALLENGE frames", func() {
+			f := &wire.PathChallengeFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}
+			buf := &bytes.Buffer{}
+			err := f.Write(buf, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			packet, err := unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(packet.frames).To(Equal([]wire.Frame{f}))
+		})
+
+		It("unpacks NEW_CONNECTION_ID frames", func() {
+			f := &wire.NewConnectionIDFrame{
+				SequenceNumber:      3,
+				ConnectionID:        0xdeadbeef,
+				StatelessResetToken: [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+			}
+			buf := &bytes.Buffer{}
+			err := f.Write(buf, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			packet, err := unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(packet.frames).To(Equal([]wire.Frame{f}))
+		})
+
+		It("unpacks RETIRE_CONNECTION_ID frames", func() {
+			f := &wire.RetireConnectionIDFrame{SequenceNumber: 3}
+			buf := &bytes.Buffer{}
+			err := f.Write(buf, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			packet, err := unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(packet.frames).To(Equal([]wire.Frame{f}))
+		})
+
+		It("unpacks PATH_RESPONSE frames", func() {
+			f := &wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}
+			buf := &bytes.Buffer{}
+			err := f.Write(buf, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			packet, err := unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(packet.frames).To(Equal([]wire.Frame{f}))
+		})
+
 		It("unpacks ACK frames", func() {
 			f := &wire.AckFrame{
 				LargestAcked: 0x13,
@@ -416,10 +540,67 @@ var _ = Describe("Packet unpacker", func() {
 			Expect(readFrame.LargestAcked).To(Equal(protocol.PacketNumber(0x13)))
 		})
 
+		It("unpacks ACK_ECN frames", func() {
+			f := &wire.AckFrame{
+				LargestAcked: 0x13,
+				LowestAcked:  1,
+				ECT0:         10,
+				ECNCE:        2,
+			}
+			err := f.Write(buf, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			packet, err := unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(packet.frames).To(Equal([]wire.Frame{f}))
+		})
+
+		It("unpacks DATAGRAM frames", func() {
+			unpacker.aead.(*mockAEAD).encLevelOpen = protocol.EncryptionForwardSecure
+			f := &wire.DatagramFrame{DataLenPresent: true, Data: []byte("foobar")}
+			err := f.Write(buf, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			packet, err := unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(packet.frames).To(Equal([]wire.Frame{f}))
+		})
+
+		It("does not unpack unencrypted DATAGRAM frames", func() {
+			unpacker.aead.(*mockAEAD).encLevelOpen = protocol.EncryptionUnencrypted
+			f := &wire.DatagramFrame{Data: []byte("foobar")}
+			err := f.Write(buf, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			_, err = unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).To(MatchError(qerr.Error(qerr.InvalidFrameData, "received unencrypted DATAGRAM frame")))
+		})
+
+		It("unpacks ACK_FREQUENCY frames", func() {
+			unpacker.aead.(*mockAEAD).encLevelOpen = protocol.EncryptionForwardSecure
+			f := &wire.AckFrequencyFrame{SequenceNumber: 1, PacketTolerance: 2, UpdateMaxAckDelay: 3 * time.Millisecond}
+			err := f.Write(buf, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			packet, err := unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(packet.frames).To(Equal([]wire.Frame{f}))
+		})
+
+		It("does not unpack unencrypted ACK_FREQUENCY frames", func() {
+			unpacker.aead.(*mockAEAD).encLevelOpen = protocol.EncryptionUnencrypted
+			f := &wire.AckFrequencyFrame{SequenceNumber: 1, PacketTolerance: 2, UpdateMaxAckDelay: 3 * time.Millisecond}
+			err := f.Write(buf, versionIETFFrames)
+			Expect(err).ToNot(HaveOccurred())
+			setData(buf.Bytes())
+			_, err = unpacker.Unpack(hdrBin, hdr, data)
+			Expect(err).To(MatchError(qerr.Error(qerr.InvalidFrameData, "received unencrypted ACK_FREQUENCY frame")))
+		})
+
 		It("errors on invalid type", func() {
-			setData([]byte{0xf})
+			setData([]byte{0x42})
 			_, err := unpacker.Unpack(hdrBin, hdr, data)
-			Expect(err).To(MatchError("InvalidFrameData: unknown type byte 0xf"))
+			Expect(err).To(MatchError("InvalidFrameData: unknown type byte 0x42"))
 		})
 
 		It("errors on invalid frames", func() {
@@ -432,9 +613,15 @@ var _ = Describe("Packet unpacker", func() {
 				0x08: qerr.InvalidBlockedData,
 				0x09: qerr.InvalidBlockedData,
 				0x0a: qerr.InvalidFrameData,
+				0x0b: qerr.InvalidFrameData,
 				0x0c: qerr.InvalidFrameData,
+				0x0d: qerr.InvalidFrameData,
 				0x0e: qerr.InvalidAckData,
+				0x0f: qerr.InvalidFrameData,
 				0x10: qerr.InvalidStreamData,
+				0x19: qerr.InvalidFrameData,
+				0x1a: qerr.InvalidFrameData,
+				0x31: qerr.InvalidFrameData,
 			} {
 				setData([]byte{b})
 				_, err := unpacker.Unpack(hdrBin, hdr, data)
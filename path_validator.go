@@ -0,0 +1,88 @@
+package quic
+
+import (
+	"crypto/rand"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+// The pathValidator validates new paths using PATH_CHALLENGE and PATH_RESPONSE frames.
+// It is used by the server, when it receives packets from a new remote address.
+// Until the path is validated, only PATH_CHALLENGE frames are sent on it,
+// and the number of bytes sent is limited to a multiple of the number of bytes received on it.
+type pathValidator struct {
+	candidate net.Addr
+	// the data of the PATH_CHALLENGE frames sent on the candidate path
+	challenges    [][8]byte
+	lastChallenge time.Time
+
+	bytesReceived protocol.ByteCount
+	bytesSent     protocol.ByteCount
+}
+
+func newPathValidator() *pathValidator {
+	return &pathValidator{}
+}
+
+// OnPacketReceived is called for every packet received from a remote address that is not the current remote address.
+// It returns a PATH_CHALLENGE frame, if one should be sent to this address.
+func (v *pathValidator) OnPacketReceived(addr net.Addr, size protocol.ByteCount, rcvTime time.Time, challengeTimeout time.Duration) (*wire.PathChallengeFrame, error) {
+	if v.candidate == nil || !sameAddr(v.candidate, addr) {
+		// Start validating a new path, and abandon the validation of the previous candidate.
+		v.reset()
+		v.candidate = addr
+	}
+	v.bytesReceived += size
+
+	if len(v.challenges) > 0 && rcvTime.Sub(v.lastChallenge) < challengeTimeout {
+		return nil, nil
+	}
+	f := &wire.PathChallengeFrame{}
+	if _, err := rand.Read(f.Data[:]); err != nil {
+		return nil, err
+	}
+	if len(v.challenges) == protocol.MaxPathChallenges {
+		v.challenges = v.challenges[1:]
+	}
+	v.challenges = append(v.challenges, f.Data)
+	v.lastChallenge = rcvTime
+	return f, nil
+}
+
+// AllowSending says if a packet of the given size may be sent on the candidate path.
+// If it returns true, the packet is counted towards the anti-amplification limit.
+func (v *pathValidator) AllowSending(size protocol.ByteCount) bool {
+	if v.bytesSent+size > protocol.PathValidationAmplificationFactor*v.bytesReceived {
+		return false
+	}
+	v.bytesSent += size
+	return true
+}
+
+// OnPathResponse handles a PATH_RESPONSE frame.
+// It returns the remote address of the path, if the frame validated the path, and nil otherwise.
+func (v *pathValidator) OnPathResponse(f *wire.PathResponseFrame) net.Addr {
+	for _, data := range v.challenges {
+		if data == f.Data {
+			addr := v.candidate
+			v.reset()
+			return addr
+		}
+	}
+	return nil
+}
+
+func (v *pathValidator) reset() {
+	v.candidate = nil
+	v.challenges = nil
+	v.lastChallenge = time.Time{}
+	v.bytesReceived = 0
+	v.bytesSent = 0
+}
+
+func sameAddr(a, b net.Addr) bool {
+	return a.Network() == b.Network() && a.String() == b.String()
+}
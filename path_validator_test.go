@@ -0,0 +1,126 @@
+package quic
+
+import (
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Path Validator", func() {
+	const timeout = time.Second
+
+	var (
+		v    *pathValidator
+		addr net.Addr
+		now  time.Time
+	)
+
+	BeforeEach(func() {
+		v = newPathValidator()
+		addr = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}
+		now = time.Now()
+	})
+
+	responseTo := func(f *wire.PathChallengeFrame) *wire.PathResponseFrame {
+		return &wire.PathResponseFrame{Data: f.Data}
+	}
+
+	It("sends a PATH_CHALLENGE when receiving a packet from a new address", func() {
+		f, err := v.OnPacketReceived(addr, 100, now, timeout)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f).ToNot(BeNil())
+		Expect(f.Data).ToNot(BeZero())
+	})
+
+	It("validates the path when receiving the PATH_RESPONSE", func() {
+		f, err := v.OnPacketReceived(addr, 100, now, timeout)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.OnPathResponse(responseTo(f))).To(Equal(addr))
+		// the path was validated, so we're not expecting any more PATH_RESPONSEs
+		Expect(v.OnPathResponse(responseTo(f))).To(BeNil())
+	})
+
+	It("ignores PATH_RESPONSEs that don't match a PATH_CHALLENGE", func() {
+		_, err := v.OnPacketReceived(addr, 100, now, timeout)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v.OnPathResponse(&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}})).To(BeNil())
+	})
+
+	It("ignores PATH_RESPONSEs when not validating a path", func() {
+		Expect(v.OnPathResponse(&wire.PathResponseFrame{})).To(BeNil())
+	})
+
+	It("doesn't send another PATH_CHALLENGE before the timeout", func() {
+		f, err := v.OnPacketReceived(addr, 100, now, timeout)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f).ToNot(BeNil())
+		f, err = v.OnPacketReceived(addr, 100, now.Add(timeout/2), timeout)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f).To(BeNil())
+	})
+
+	It("sends another PATH_CHALLENGE after the timeout, and accepts a PATH_RESPONSE for either of them", func() {
+		f1, err := v.OnPacketReceived(addr, 100, now, timeout)
+		Expect(err).ToNot(HaveOccurred())
+		f2, err := v.OnPacketReceived(addr, 100, now.Add(timeout), timeout)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f2).ToNot(BeNil())
+		Expect(f2.Data).ToNot(Equal(f1.Data))
+		Expect(v.OnPathResponse(responseTo(f1))).To(Equal(addr))
+	})
+
+	It("only remembers a limited number of PATH_CHALLENGEs", func() {
+		var frames []*wire.PathChallengeFrame
+		for i := 0; i <= protocol.MaxPathChallenges; i++ {
+			f, err := v.OnPacketReceived(addr, 100, now.Add(time.Duration(i)*timeout), timeout)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(f).ToNot(BeNil())
+			frames = append(frames, f)
+		}
+		Expect(v.OnPathResponse(responseTo(frames[0]))).To(BeNil())
+		Expect(v.OnPathResponse(responseTo(frames[1]))).To(Equal(addr))
+	})
+
+	It("abandons the validation when receiving a packet from another address", func() {
+		f1, err := v.OnPacketReceived(addr, 100, now, timeout)
+		Expect(err).ToNot(HaveOccurred())
+		addr2 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 2), Port: 1337}
+		f2, err := v.OnPacketReceived(addr2, 100, now, timeout)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(f2).ToNot(BeNil())
+		Expect(v.OnPathResponse(responseTo(f1))).To(BeNil())
+		Expect(v.OnPathResponse(responseTo(f2))).To(Equal(addr2))
+	})
+
+	Context("anti-amplification limit", func() {
+		It("limits the number of bytes sent on an unvalidated path", func() {
+			_, err := v.OnPacketReceived(addr, 100, now, timeout)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v.AllowSending(protocol.PathValidationAmplificationFactor*100 - 1)).To(BeTrue())
+			Expect(v.AllowSending(2)).To(BeFalse())
+			Expect(v.AllowSending(1)).To(BeTrue())
+		})
+
+		It("allows sending more data when more packets are received", func() {
+			_, err := v.OnPacketReceived(addr, 10, now, timeout)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v.AllowSending(50)).To(BeFalse())
+			_, err = v.OnPacketReceived(addr, 10, now, timeout)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v.AllowSending(50)).To(BeTrue())
+		})
+
+		It("resets the limit when the candidate path changes", func() {
+			_, err := v.OnPacketReceived(addr, 100, now, timeout)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = v.OnPacketReceived(&net.UDPAddr{IP: net.IPv4(192, 168, 0, 2), Port: 1337}, 10, now, timeout)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(v.AllowSending(50)).To(BeFalse())
+		})
+	})
+})
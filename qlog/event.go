@@ -0,0 +1,59 @@
+package qlog
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// An event is serialized as an array, in the order given by the event_fields of the trace
+type event struct {
+	RelativeTime time.Duration
+	Category     string
+	Name         string
+	Data         interface{}
+}
+
+func (e event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{milliseconds(e.RelativeTime), e.Category, e.Name, e.Data})
+}
+
+// milliseconds converts a duration to (fractional) milliseconds, the time unit used by qlog
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Nanoseconds()) / 1e6
+}
+
+type packetHeader struct {
+	PacketNumber protocol.PacketNumber `json:"packet_number"`
+	PacketSize   protocol.ByteCount    `json:"packet_size"`
+}
+
+type packetEvent struct {
+	PacketType packetType   `json:"packet_type"`
+	Header     packetHeader `json:"header"`
+	Frames     []frame      `json:"frames"`
+}
+
+type packetLostEvent struct {
+	PacketType   packetType            `json:"packet_type"`
+	PacketNumber protocol.PacketNumber `json:"packet_number"`
+	Trigger      lossTrigger           `json:"trigger"`
+}
+
+type rttUpdatedEvent struct {
+	MinRTT      float64 `json:"min_rtt"`
+	SmoothedRTT float64 `json:"smoothed_rtt"`
+	LatestRTT   float64 `json:"latest_rtt"`
+	RTTVariance float64 `json:"rtt_variance"`
+}
+
+type congestionWindowUpdatedEvent struct {
+	CongestionWindow protocol.ByteCount `json:"congestion_window"`
+}
+
+type connectionClosedEvent struct {
+	Owner     string `json:"owner"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
@@ -0,0 +1,155 @@
+package qlog
+
+import (
+	"fmt"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+// A frame is the JSON representation of a QUIC frame.
+// All frames contain a frame_type, the other fields depend on the frame type.
+type frame map[string]interface{}
+
+func transformFrame(f wire.Frame) frame {
+	switch f := f.(type) {
+	case *wire.StreamFrame:
+		return frame{
+			"frame_type": "stream",
+			"stream_id":  f.StreamID,
+			"offset":     f.Offset,
+			"length":     f.DataLen(),
+			"fin":        f.FinBit,
+		}
+	case *wire.AckFrame:
+		fr := frame{
+			"frame_type":   "ack",
+			"ack_delay":    milliseconds(f.DelayTime),
+			"acked_ranges": transformAckRanges(f),
+		}
+		if f.HasECN() {
+			fr["ect0"] = f.ECT0
+			fr["ect1"] = f.ECT1
+			fr["ce"] = f.ECNCE
+		}
+		return fr
+	case *wire.StopWaitingFrame:
+		return frame{
+			"frame_type":    "stop_waiting",
+			"least_unacked": f.LeastUnacked,
+		}
+	case *wire.PingFrame:
+		return frame{"frame_type": "ping"}
+	case *wire.RstStreamFrame:
+		return frame{
+			"frame_type": "reset_stream",
+			"stream_id":  f.StreamID,
+			"error_code": f.ErrorCode,
+			"final_size": f.ByteOffset,
+		}
+	case *wire.StopSendingFrame:
+		return frame{
+			"frame_type": "stop_sending",
+			"stream_id":  f.StreamID,
+			"error_code": f.ErrorCode,
+		}
+	case *wire.ConnectionCloseFrame:
+		return frame{
+			"frame_type": "connection_close",
+			"error_code": f.ErrorCode.String(),
+			"reason":     f.ReasonPhrase,
+		}
+	case *wire.GoawayFrame:
+		return frame{
+			"frame_type":       "goaway",
+			"error_code":       f.ErrorCode.String(),
+			"last_good_stream": f.LastGoodStream,
+			"reason":           f.ReasonPhrase,
+		}
+	case *wire.MaxDataFrame:
+		return frame{
+			"frame_type": "max_data",
+			"maximum":    f.ByteOffset,
+		}
+	case *wire.MaxStreamDataFrame:
+		return frame{
+			"frame_type": "max_stream_data",
+			"stream_id":  f.StreamID,
+			"maximum":    f.ByteOffset,
+		}
+	case *wire.MaxStreamIDFrame:
+		return frame{
+			"frame_type": "max_stream_id",
+			"maximum":    f.StreamID,
+		}
+	case *wire.BlockedFrame:
+		return frame{
+			"frame_type": "data_blocked",
+			"limit":      f.Offset,
+		}
+	case *wire.StreamBlockedFrame:
+		return frame{
+			"frame_type": "stream_data_blocked",
+			"stream_id":  f.StreamID,
+			"limit":      f.Offset,
+		}
+	case *wire.StreamIDBlockedFrame:
+		return frame{
+			"frame_type": "stream_id_blocked",
+			"limit":      f.StreamID,
+		}
+	case *wire.PathChallengeFrame:
+		return frame{
+			"frame_type": "path_challenge",
+			"data":       fmt.Sprintf("%x", f.Data[:]),
+		}
+	case *wire.PathResponseFrame:
+		return frame{
+			"frame_type": "path_response",
+			"data":       fmt.Sprintf("%x", f.Data[:]),
+		}
+	case *wire.NewConnectionIDFrame:
+		return frame{
+			"frame_type":            "new_connection_id",
+			"sequence_number":       f.SequenceNumber,
+			"connection_id":         fmt.Sprintf("%x", uint64(f.ConnectionID)),
+			"stateless_reset_token": fmt.Sprintf("%x", f.StatelessResetToken[:]),
+		}
+	case *wire.RetireConnectionIDFrame:
+		return frame{
+			"frame_type":      "retire_connection_id",
+			"sequence_number": f.SequenceNumber,
+		}
+	case *wire.DatagramFrame:
+		return frame{
+			"frame_type": "datagram",
+			"length":     len(f.Data),
+		}
+	case *wire.AckFrequencyFrame:
+		return frame{
+			"frame_type":           "ack_frequency",
+			"sequence_number":      f.SequenceNumber,
+			"packet_tolerance":     f.PacketTolerance,
+			"update_max_ack_delay": milliseconds(f.UpdateMaxAckDelay),
+			"ignore_order":         f.IgnoreOrder,
+		}
+	default:
+		return frame{
+			"frame_type": "unknown",
+			"raw":        fmt.Sprintf("%#v", f),
+		}
+	}
+}
+
+// transformAckRanges returns the ACK ranges in ascending order.
+// Every range is represented by its first and its last packet number.
+func transformAckRanges(f *wire.AckFrame) [][2]protocol.PacketNumber {
+	if !f.HasMissingRanges() {
+		return [][2]protocol.PacketNumber{{f.LowestAcked, f.LargestAcked}}
+	}
+	ranges := make([][2]protocol.PacketNumber, len(f.AckRanges))
+	for i, r := range f.AckRanges {
+		ranges[len(f.AckRanges)-1-i] = [2]protocol.PacketNumber{r.First, r.Last}
+	}
+	return ranges
+}
@@ -0,0 +1,112 @@
+package qlog
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/qerr"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Frames", func() {
+	It("transforms PING frames", func() {
+		Expect(transformFrame(&wire.PingFrame{})).To(Equal(frame{"frame_type": "ping"}))
+	})
+
+	It("transforms ACK frames with multiple ranges", func() {
+		f := &wire.AckFrame{
+			LargestAcked: 20,
+			LowestAcked:  1,
+			AckRanges: []wire.AckRange{
+				{First: 15, Last: 20},
+				{First: 5, Last: 10},
+				{First: 1, Last: 2},
+			},
+		}
+		Expect(transformFrame(f)["acked_ranges"]).To(Equal([][2]protocol.PacketNumber{{1, 2}, {5, 10}, {15, 20}}))
+		Expect(transformFrame(f)).ToNot(HaveKey("ect0"))
+	})
+
+	It("transforms ACK frames with ECN counts", func() {
+		f := &wire.AckFrame{LargestAcked: 10, LowestAcked: 1, ECT0: 5, ECT1: 1, ECNCE: 3}
+		fr := transformFrame(f)
+		Expect(fr).To(HaveKeyWithValue("ect0", uint64(5)))
+		Expect(fr).To(HaveKeyWithValue("ect1", uint64(1)))
+		Expect(fr).To(HaveKeyWithValue("ce", uint64(3)))
+	})
+
+	It("transforms RST_STREAM frames", func() {
+		f := &wire.RstStreamFrame{StreamID: 5, ErrorCode: 42, ByteOffset: 1337}
+		Expect(transformFrame(f)).To(Equal(frame{
+			"frame_type": "reset_stream",
+			"stream_id":  f.StreamID,
+			"error_code": f.ErrorCode,
+			"final_size": f.ByteOffset,
+		}))
+	})
+
+	It("transforms CONNECTION_CLOSE frames", func() {
+		f := &wire.ConnectionCloseFrame{ErrorCode: qerr.PeerGoingAway, ReasonPhrase: "bye"}
+		Expect(transformFrame(f)).To(Equal(frame{
+			"frame_type": "connection_close",
+			"error_code": "PeerGoingAway",
+			"reason":     "bye",
+		}))
+	})
+
+	It("transforms flow control frames", func() {
+		Expect(transformFrame(&wire.MaxDataFrame{ByteOffset: 100})).To(HaveKeyWithValue("frame_type", "max_data"))
+		Expect(transformFrame(&wire.BlockedFrame{Offset: 100})).To(HaveKeyWithValue("frame_type", "data_blocked"))
+		Expect(transformFrame(&wire.StreamBlockedFrame{StreamID: 5, Offset: 100})).To(HaveKeyWithValue("frame_type", "stream_data_blocked"))
+		Expect(transformFrame(&wire.StreamIDBlockedFrame{StreamID: 5})).To(HaveKeyWithValue("frame_type", "stream_id_blocked"))
+		Expect(transformFrame(&wire.MaxStreamIDFrame{StreamID: 5})).To(HaveKeyWithValue("frame_type", "max_stream_id"))
+	})
+
+	It("transforms PATH_CHALLENGE and PATH_RESPONSE frames", func() {
+		data := [8]byte{0xde, 0xad, 0xbe, 0xef, 0xca, 0xfe, 0x13, 0x37}
+		f := transformFrame(&wire.PathChallengeFrame{Data: data})
+		Expect(f).To(HaveKeyWithValue("frame_type", "path_challenge"))
+		Expect(f).To(HaveKeyWithValue("data", "deadbeefcafe1337"))
+		f = transformFrame(&wire.PathResponseFrame{Data: data})
+		Expect(f).To(HaveKeyWithValue("frame_type", "path_response"))
+		Expect(f).To(HaveKeyWithValue("data", "deadbeefcafe1337"))
+	})
+
+	It("transforms NEW_CONNECTION_ID and RETIRE_CONNECTION_ID frames", func() {
+		f := transformFrame(&wire.NewConnectionIDFrame{
+			SequenceNumber:      3,
+			ConnectionID:        0xdeadbeef,
+			StatelessResetToken: [16]byte{0xf, 0xe, 0xd, 0xc, 0xb, 0xa, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+		})
+		Expect(f).To(HaveKeyWithValue("frame_type", "new_connection_id"))
+		Expect(f).To(HaveKeyWithValue("sequence_number", uint64(3)))
+		Expect(f).To(HaveKeyWithValue("connection_id", "deadbeef"))
+		Expect(f).To(HaveKeyWithValue("stateless_reset_token", "0f0e0d0c0b0a09080706050403020100"))
+		f = transformFrame(&wire.RetireConnectionIDFrame{SequenceNumber: 3})
+		Expect(f).To(HaveKeyWithValue("frame_type", "retire_connection_id"))
+		Expect(f).To(HaveKeyWithValue("sequence_number", uint64(3)))
+	})
+
+	It("transforms DATAGRAM frames", func() {
+		f := transformFrame(&wire.DatagramFrame{Data: []byte("foobar")})
+		Expect(f).To(HaveKeyWithValue("frame_type", "datagram"))
+		Expect(f).To(HaveKeyWithValue("length", 6))
+	})
+
+	It("transforms ACK_FREQUENCY frames", func() {
+		f := transformFrame(&wire.AckFrequencyFrame{
+			SequenceNumber:    2,
+			PacketTolerance:   10,
+			UpdateMaxAckDelay: 1500 * time.Microsecond,
+			IgnoreOrder:       true,
+		})
+		Expect(f).To(HaveKeyWithValue("frame_type", "ack_frequency"))
+		Expect(f).To(HaveKeyWithValue("sequence_number", uint64(2)))
+		Expect(f).To(HaveKeyWithValue("packet_tolerance", uint64(10)))
+		Expect(f).To(HaveKeyWithValue("update_max_ack_delay", 1.5))
+		Expect(f).To(HaveKeyWithValue("ignore_order", true))
+	})
+})
@@ -0,0 +1,170 @@
+// Package qlog implements a logging.ConnectionTracer that writes a qlog-style JSON trace for a single connection.
+package qlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
+	"github.com/lucas-clemente/quic-go/qerr"
+)
+
+const qlogVersion = "draft-01"
+
+type connectionTracer struct {
+	w      io.WriteCloser
+	buf    *bufio.Writer
+	enc    *json.Encoder
+	closed bool
+	// err is the first error that occurred when writing the trace
+	err error
+
+	referenceTime time.Time
+	numEvents     int
+}
+
+var _ logging.ConnectionTracer = &connectionTracer{}
+
+// NewConnectionTracer creates a new tracer that writes a qlog trace to w.
+// The trace is only valid JSON after the tracer's Close method was called.
+// Close also closes w.
+func NewConnectionTracer(w io.WriteCloser, p logging.Perspective, connID logging.ConnectionID) logging.ConnectionTracer {
+	buf := bufio.NewWriter(w)
+	t := &connectionTracer{
+		w:             w,
+		buf:           buf,
+		enc:           json.NewEncoder(buf),
+		referenceTime: time.Now(),
+	}
+	t.writeHeader(p, connID)
+	return t
+}
+
+func (t *connectionTracer) writeHeader(p logging.Perspective, connID logging.ConnectionID) {
+	vantagePoint := "server"
+	if p == protocol.PerspectiveClient {
+		vantagePoint = "client"
+	}
+	commonFields, err := json.Marshal(map[string]interface{}{
+		"ODCID":          fmt.Sprintf("%x", uint64(connID)),
+		"reference_time": t.referenceTime.UnixNano() / int64(time.Millisecond),
+	})
+	if err != nil {
+		t.err = err
+		return
+	}
+	t.write(fmt.Sprintf(
+		`{"qlog_version":%q,"title":"quic-go qlog","traces":[{"vantage_point":{"type":%q},"common_fields":%s,"event_fields":["relative_time","category","event","data"],"events":[`,
+		qlogVersion,
+		vantagePoint,
+		commonFields,
+	))
+}
+
+func (t *connectionTracer) write(s string) {
+	if t.err != nil {
+		return
+	}
+	_, t.err = t.buf.WriteString(s)
+}
+
+func (t *connectionTracer) recordEvent(category, name string, data interface{}) {
+	if t.closed || t.err != nil {
+		return
+	}
+	if t.numEvents > 0 {
+		t.write(",")
+	}
+	t.numEvents++
+	ev := event{
+		RelativeTime: time.Since(t.referenceTime),
+		Category:     category,
+		Name:         name,
+		Data:         data,
+	}
+	// Encode appends a newline, which conveniently puts every event on its own line
+	if err := t.enc.Encode(ev); err != nil && t.err == nil {
+		t.err = err
+	}
+}
+
+func (t *connectionTracer) SentPacket(hdr *logging.Header, size logging.ByteCount, encLevel logging.EncryptionLevel, frames []logging.Frame) {
+	t.recordEvent("transport", "packet_sent", newPacketEvent(hdr, size, encLevel, frames))
+}
+
+func (t *connectionTracer) ReceivedPacket(hdr *logging.Header, size logging.ByteCount, encLevel logging.EncryptionLevel, frames []logging.Frame) {
+	t.recordEvent("transport", "packet_received", newPacketEvent(hdr, size, encLevel, frames))
+}
+
+func (t *connectionTracer) LostPacket(encLevel logging.EncryptionLevel, pn logging.PacketNumber, reason logging.PacketLossReason) {
+	t.recordEvent("recovery", "packet_lost", &packetLostEvent{
+		PacketType:   packetTypeFromEncryptionLevel(encLevel),
+		PacketNumber: pn,
+		Trigger:      newLossTrigger(reason),
+	})
+}
+
+func (t *connectionTracer) UpdatedRTT(rttStats *logging.RTTStats) {
+	t.recordEvent("recovery", "metrics_updated", &rttUpdatedEvent{
+		MinRTT:      milliseconds(rttStats.MinRTT()),
+		SmoothedRTT: milliseconds(rttStats.SmoothedRTT()),
+		LatestRTT:   milliseconds(rttStats.LatestRTT()),
+		RTTVariance: milliseconds(rttStats.MeanDeviation()),
+	})
+}
+
+func (t *connectionTracer) UpdatedCongestionWindow(cwnd logging.ByteCount) {
+	t.recordEvent("recovery", "metrics_updated", &congestionWindowUpdatedEvent{CongestionWindow: cwnd})
+}
+
+func (t *connectionTracer) ClosedConnection(err error, remote bool) {
+	ev := &connectionClosedEvent{Owner: "local"}
+	if remote {
+		ev.Owner = "remote"
+	}
+	if quicErr, ok := err.(*qerr.QuicError); ok {
+		ev.ErrorCode = quicErr.ErrorCode.String()
+		ev.Reason = quicErr.ErrorMessage
+	} else if err != nil {
+		ev.Reason = err.Error()
+	}
+	t.recordEvent("transport", "connection_closed", ev)
+}
+
+func (t *connectionTracer) Close() {
+	if t.closed {
+		return
+	}
+	t.write("]}]}\n")
+	if t.err == nil {
+		t.err = t.buf.Flush()
+	}
+	t.closed = true
+	if err := t.w.Close(); err != nil && t.err == nil {
+		t.err = err
+	}
+	if t.err != nil {
+		utils.Errorf("Writing qlog failed: %s", t.err)
+	}
+}
+
+func newPacketEvent(hdr *wire.Header, size protocol.ByteCount, encLevel protocol.EncryptionLevel, frames []wire.Frame) *packetEvent {
+	fs := make([]frame, 0, len(frames))
+	for _, f := range frames {
+		fs = append(fs, transformFrame(f))
+	}
+	return &packetEvent{
+		PacketType: packetTypeFromHeader(hdr, encLevel),
+		Header: packetHeader{
+			PacketNumber: hdr.PacketNumber,
+			PacketSize:   size,
+		},
+		Frames: fs,
+	}
+}
@@ -0,0 +1,213 @@
+package qlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/congestion"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
+	"github.com/lucas-clemente/quic-go/qerr"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type bufferWriteCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferWriteCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+type errorWriteCloser struct {
+	closed bool
+}
+
+func (e *errorWriteCloser) Write([]byte) (int, error) { return 0, errors.New("write error") }
+func (e *errorWriteCloser) Close() error {
+	e.closed = true
+	return nil
+}
+
+var _ = Describe("Tracer", func() {
+	var (
+		buf    *bufferWriteCloser
+		tracer logging.ConnectionTracer
+	)
+
+	BeforeEach(func() {
+		buf = &bufferWriteCloser{}
+		tracer = NewConnectionTracer(buf, protocol.PerspectiveServer, 0xdecafbad)
+	})
+
+	type trace struct {
+		VantagePoint struct {
+			Type string `json:"type"`
+		} `json:"vantage_point"`
+		CommonFields map[string]interface{} `json:"common_fields"`
+		EventFields  []string               `json:"event_fields"`
+		Events       [][]interface{}        `json:"events"`
+	}
+
+	type qlog struct {
+		QlogVersion string  `json:"qlog_version"`
+		Traces      []trace `json:"traces"`
+	}
+
+	parse := func() *trace {
+		Expect(buf.closed).To(BeTrue())
+		q := &qlog{}
+		Expect(json.Unmarshal(buf.Bytes(), q)).To(Succeed())
+		Expect(q.QlogVersion).To(Equal("draft-01"))
+		Expect(q.Traces).To(HaveLen(1))
+		return &q.Traces[0]
+	}
+
+	// getEvent returns the category, the event name and the data of the only event in the trace
+	getEvent := func() (string, string, map[string]interface{}) {
+		tracer.Close()
+		t := parse()
+		Expect(t.Events).To(HaveLen(1))
+		ev := t.Events[0]
+		Expect(ev).To(HaveLen(4))
+		Expect(ev[0]).To(BeNumerically(">=", 0))
+		return ev[1].(string), ev[2].(string), ev[3].(map[string]interface{})
+	}
+
+	It("writes the header", func() {
+		tracer.Close()
+		t := parse()
+		Expect(t.VantagePoint.Type).To(Equal("server"))
+		Expect(t.CommonFields).To(HaveKeyWithValue("ODCID", "decafbad"))
+		Expect(t.CommonFields).To(HaveKey("reference_time"))
+		Expect(t.EventFields).To(Equal([]string{"relative_time", "category", "event", "data"}))
+		Expect(t.Events).To(BeEmpty())
+	})
+
+	It("sets the vantage point for clients", func() {
+		tracer = NewConnectionTracer(buf, protocol.PerspectiveClient, 0x1337)
+		tracer.Close()
+		Expect(parse().VantagePoint.Type).To(Equal("client"))
+	})
+
+	It("records sent packets", func() {
+		tracer.SentPacket(
+			&wire.Header{PacketNumber: 42},
+			1234,
+			protocol.EncryptionForwardSecure,
+			[]wire.Frame{
+				&wire.MaxStreamDataFrame{StreamID: 3, ByteOffset: 987},
+				&wire.StreamFrame{StreamID: 5, Offset: 100, Data: []byte("foobar"), FinBit: true},
+			},
+		)
+		category, name, data := getEvent()
+		Expect(category).To(Equal("transport"))
+		Expect(name).To(Equal("packet_sent"))
+		Expect(data).To(HaveKeyWithValue("packet_type", "1RTT"))
+		Expect(data).To(HaveKeyWithValue("header", map[string]interface{}{
+			"packet_number": float64(42),
+			"packet_size":   float64(1234),
+		}))
+		Expect(data["frames"]).To(Equal([]interface{}{
+			map[string]interface{}{"frame_type": "max_stream_data", "stream_id": float64(3), "maximum": float64(987)},
+			map[string]interface{}{"frame_type": "stream", "stream_id": float64(5), "offset": float64(100), "length": float64(6), "fin": true},
+		}))
+	})
+
+	It("records received packets", func() {
+		tracer.ReceivedPacket(
+			&wire.Header{IsLongHeader: true, Type: protocol.PacketTypeHandshake, PacketNumber: 1},
+			1200,
+			protocol.EncryptionUnencrypted,
+			[]wire.Frame{&wire.AckFrame{LargestAcked: 10, LowestAcked: 1, DelayTime: 2 * time.Millisecond}},
+		)
+		category, name, data := getEvent()
+		Expect(category).To(Equal("transport"))
+		Expect(name).To(Equal("packet_received"))
+		Expect(data).To(HaveKeyWithValue("packet_type", "handshake"))
+		Expect(data["frames"]).To(Equal([]interface{}{
+			map[string]interface{}{
+				"frame_type":   "ack",
+				"ack_delay":    float64(2),
+				"acked_ranges": []interface{}{[]interface{}{float64(1), float64(10)}},
+			},
+		}))
+	})
+
+	It("records lost packets", func() {
+		tracer.LostPacket(protocol.EncryptionSecure, 42, logging.PacketLossRTO)
+		category, name, data := getEvent()
+		Expect(category).To(Equal("recovery"))
+		Expect(name).To(Equal("packet_lost"))
+		Expect(data).To(HaveKeyWithValue("packet_type", "0RTT"))
+		Expect(data).To(HaveKeyWithValue("packet_number", float64(42)))
+		Expect(data).To(HaveKeyWithValue("trigger", "retransmission_timer"))
+	})
+
+	It("records RTT updates", func() {
+		rttStats := &congestion.RTTStats{}
+		rttStats.UpdateRTT(15*time.Millisecond, 0, time.Now())
+		tracer.UpdatedRTT(rttStats)
+		category, name, data := getEvent()
+		Expect(category).To(Equal("recovery"))
+		Expect(name).To(Equal("metrics_updated"))
+		Expect(data).To(HaveKeyWithValue("min_rtt", float64(15)))
+		Expect(data).To(HaveKeyWithValue("smoothed_rtt", float64(15)))
+		Expect(data).To(HaveKeyWithValue("latest_rtt", float64(15)))
+		Expect(data).To(HaveKeyWithValue("rtt_variance", 7.5))
+	})
+
+	It("records congestion window updates", func() {
+		tracer.UpdatedCongestionWindow(12345)
+		category, name, data := getEvent()
+		Expect(category).To(Equal("recovery"))
+		Expect(name).To(Equal("metrics_updated"))
+		Expect(data).To(Equal(map[string]interface{}{"congestion_window": float64(12345)}))
+	})
+
+	It("records connection closes", func() {
+		tracer.ClosedConnection(qerr.Error(qerr.NetworkIdleTimeout, "idle"), true)
+		category, name, data := getEvent()
+		Expect(category).To(Equal("transport"))
+		Expect(name).To(Equal("connection_closed"))
+		Expect(data).To(HaveKeyWithValue("owner", "remote"))
+		Expect(data).To(HaveKeyWithValue("error_code", "NetworkIdleTimeout"))
+		Expect(data).To(HaveKeyWithValue("reason", "idle"))
+	})
+
+	It("records multiple events", func() {
+		tracer.UpdatedCongestionWindow(1000)
+		tracer.UpdatedCongestionWindow(2000)
+		tracer.ClosedConnection(errors.New("foobar"), false)
+		tracer.Close()
+		events := parse().Events
+		Expect(events).To(HaveLen(3))
+		Expect(events[0][1]).To(Equal("recovery"))
+		Expect(events[1][1]).To(Equal("recovery"))
+		Expect(events[2][1]).To(Equal("transport"))
+		Expect(events[2][3]).To(Equal(map[string]interface{}{"owner": "local", "reason": "foobar"}))
+	})
+
+	It("doesn't write events after it was closed", func() {
+		tracer.Close()
+		l := buf.Len()
+		tracer.UpdatedCongestionWindow(1000)
+		tracer.Close()
+		Expect(buf.Len()).To(Equal(l))
+	})
+
+	It("closes the writer if writing fails", func() {
+		w := &errorWriteCloser{}
+		tracer = NewConnectionTracer(w, protocol.PerspectiveClient, 0x1337)
+		tracer.UpdatedCongestionWindow(1000)
+		tracer.Close()
+		Expect(w.closed).To(BeTrue())
+	})
+})
@@ -0,0 +1,72 @@
+package qlog
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
+)
+
+type packetType string
+
+const (
+	packetTypeInitial            packetType = "initial"
+	packetTypeHandshake          packetType = "handshake"
+	packetTypeRetry              packetType = "retry"
+	packetType0RTT               packetType = "0RTT"
+	packetType1RTT               packetType = "1RTT"
+	packetTypeVersionNegotiation packetType = "version_negotiation"
+	packetTypeUnknown            packetType = "unknown"
+)
+
+func packetTypeFromHeader(hdr *wire.Header, encLevel protocol.EncryptionLevel) packetType {
+	if hdr.IsVersionNegotiation {
+		return packetTypeVersionNegotiation
+	}
+	if hdr.IsLongHeader {
+		switch hdr.Type {
+		case protocol.PacketTypeInitial:
+			return packetTypeInitial
+		case protocol.PacketTypeHandshake:
+			return packetTypeHandshake
+		case protocol.PacketTypeRetry:
+			return packetTypeRetry
+		case protocol.PacketType0RTT:
+			return packetType0RTT
+		default:
+			return packetTypeUnknown
+		}
+	}
+	// gQUIC packets and IETF QUIC short header packets
+	return packetTypeFromEncryptionLevel(encLevel)
+}
+
+func packetTypeFromEncryptionLevel(encLevel protocol.EncryptionLevel) packetType {
+	switch encLevel {
+	case protocol.EncryptionUnencrypted:
+		return packetTypeHandshake
+	case protocol.EncryptionSecure:
+		return packetType0RTT
+	case protocol.EncryptionForwardSecure:
+		return packetType1RTT
+	default:
+		return packetTypeUnknown
+	}
+}
+
+type lossTrigger string
+
+const (
+	lossTriggerTimeThreshold lossTrigger = "time_threshold"
+	lossTriggerRTO           lossTrigger = "retransmission_timer"
+)
+
+func newLossTrigger(reason logging.PacketLossReason) lossTrigger {
+	switch reason {
+	case logging.PacketLossTimeThreshold:
+		return lossTriggerTimeThreshold
+	case logging.PacketLossRTO:
+		return lossTriggerRTO
+	default:
+		return lossTrigger(reason.String())
+	}
+}
@@ -480,6 +480,12 @@ var _ = Describe("Send Stream", func() {
 		})
 	})
 
+	It("tells the sender when the priority is changed", func() {
+		p := StreamPriority{Urgency: 7, Weight: 2}
+		mockSender.EXPECT().onStreamPriorityChanged(streamID, p)
+		str.SetPriority(p)
+	})
+
 	Context("handling MAX_STREAM_DATA frames", func() {
 		It("informs the flow controller", func() {
 			mockFC.EXPECT().UpdateSendWindow(protocol.ByteCount(0x1337))
@@ -2,8 +2,11 @@ package quic
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"net"
 	"sync"
 	"time"
@@ -18,21 +21,34 @@ import (
 
 // packetHandler handles packets
 type packetHandler interface {
-	Session
+	EarlySession
 	getCryptoStream() cryptoStreamI
 	handshakeStatus() <-chan error
+	earlySessionReady() <-chan struct{}
 	handlePacket(*receivedPacket)
 	GetVersion() protocol.VersionNumber
 	run() error
 	closeRemote(error)
 }
 
+// A sessionRunner is used by a session to register the connection IDs it uses.
+// Packets sent with any of the registered connection IDs are routed to the same packetHandler.
+type sessionRunner interface {
+	addConnectionID(protocol.ConnectionID, packetHandler)
+	retireConnectionID(protocol.ConnectionID)
+}
+
 // A Listener of QUIC
 type server struct {
 	tlsConf *tls.Config
 	config  *Config
 
 	conn net.PacketConn
+	// If the server is started with ListenAddr, we create a packet conn.
+	// If it is started with Listen, we take a packet conn as a parameter.
+	createdPacketConn bool
+	// the packetHandlers route the packets received on the conn to the server
+	packetHandlers packetHandlerManager
 
 	supportsTLS bool
 	serverTLS   *serverTLS
@@ -44,9 +60,15 @@ type server struct {
 	sessions      map[protocol.ConnectionID]packetHandler
 	closed        bool
 
+	// limiter decides if a new session can be created
+	limiter *connectionLimiter
+
 	serverError  error
-	sessionQueue chan Session
+	sessionQueue chan EarlySession
 	errorChan    chan struct{}
+	// set when the server is started with ListenEarly or ListenAddrEarly
+	// Sessions are then queued before the handshake completes.
+	acceptEarlySessions bool
 
 	// set as members, so they can be set in the tests
 	newSession                func(conn connection, v protocol.VersionNumber, connectionID protocol.ConnectionID, sCfg *handshake.ServerConfig, tlsConf *tls.Config, config *Config) (packetHandler, error)
@@ -54,11 +76,37 @@ type server struct {
 }
 
 var _ Listener = &server{}
+var _ sessionRunner = &server{}
+var _ packetReceiver = &server{}
+
+// An earlyServer is a server that returns sessions before the handshake completes.
+type earlyServer struct{ *server }
+
+var _ EarlyListener = &earlyServer{}
+
+var errServerClosed = errors.New("server closed")
 
 // ListenAddr creates a QUIC server listening on a given address.
 // The listener is not active until Serve() is called.
 // The tls.Config must not be nil, the quic.Config may be nil.
 func ListenAddr(addr string, tlsConf *tls.Config, config *Config) (Listener, error) {
+	s, err := listenAddr(addr, tlsConf, config, false)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListenAddrEarly works like ListenAddr, but it returns sessions before the handshake completes.
+func ListenAddrEarly(addr string, tlsConf *tls.Config, config *Config) (EarlyListener, error) {
+	s, err := listenAddr(addr, tlsConf, config, true)
+	if err != nil {
+		return nil, err
+	}
+	return &earlyServer{s}, nil
+}
+
+func listenAddr(addr string, tlsConf *tls.Config, config *Config, acceptEarly bool) (*server, error) {
 	udpAddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
 		return nil, err
@@ -67,13 +115,35 @@ func ListenAddr(addr string, tlsConf *tls.Config, config *Config) (Listener, err
 	if err != nil {
 		return nil, err
 	}
-	return Listen(conn, tlsConf, config)
+	return listen(conn, tlsConf, config, true, acceptEarly)
 }
 
 // Listen listens for QUIC connections on a given net.PacketConn.
+// The net.PacketConn can also be used to dial QUIC connections (see Dial), but only one Listener can use it.
+// It is not closed when the Listener is closed.
 // The listener is not active until Serve() is called.
 // The tls.Config must not be nil, the quic.Config may be nil.
 func Listen(conn net.PacketConn, tlsConf *tls.Config, config *Config) (Listener, error) {
+	s, err := listen(conn, tlsConf, config, false, false)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ListenEarly works like Listen, but it returns sessions before the handshake completes.
+func ListenEarly(conn net.PacketConn, tlsConf *tls.Config, config *Config) (EarlyListener, error) {
+	s, err := listen(conn, tlsConf, config, false, true)
+	if err != nil {
+		return nil, err
+	}
+	return &earlyServer{s}, nil
+}
+
+func listen(conn net.PacketConn, tlsConf *tls.Config, config *Config, createdPacketConn, acceptEarly bool) (*server, error) {
+	if err := validateConfig(config); err != nil {
+		return nil, err
+	}
 	certChain := crypto.NewCertChain(tlsConf)
 	kex, err := crypto.NewCurve25519KEX()
 	if err != nil {
@@ -84,6 +154,15 @@ func Listen(conn net.PacketConn, tlsConf *tls.Config, config *Config) (Listener,
 		return nil, err
 	}
 	config = populateServerConfig(config)
+	if config.StatelessResetKey == nil {
+		// Without a static key, connections can't be reset after a restart of the server.
+		config.StatelessResetKey = make([]byte, protocol.MinStatelessResetKeyLen)
+		if _, err := rand.Read(config.StatelessResetKey); err != nil {
+			return nil, err
+		}
+	} else if len(config.StatelessResetKey) < protocol.MinStatelessResetKeyLen {
+		return nil, fmt.Errorf("StatelessResetKey too short (%d bytes, minimum %d bytes)", len(config.StatelessResetKey), protocol.MinStatelessResetKeyLen)
+	}
 
 	// check if any of the supported versions supports TLS
 	var supportsTLS bool
@@ -96,6 +175,7 @@ func Listen(conn net.PacketConn, tlsConf *tls.Config, config *Config) (Listener,
 
 	s := &server{
 		conn:                      conn,
+		createdPacketConn:         createdPacketConn,
 		tlsConf:                   tlsConf,
 		config:                    config,
 		certChain:                 certChain,
@@ -103,16 +183,26 @@ func Listen(conn net.PacketConn, tlsConf *tls.Config, config *Config) (Listener,
 		sessions:                  map[protocol.ConnectionID]packetHandler{},
 		newSession:                newSession,
 		deleteClosedSessionsAfter: protocol.ClosedSessionDeleteTimeout,
-		sessionQueue:              make(chan Session, 5),
+		sessionQueue:              make(chan EarlySession, 5),
 		errorChan:                 make(chan struct{}),
+		acceptEarlySessions:       acceptEarly,
 		supportsTLS:               supportsTLS,
+		limiter:                   newConnectionLimiter(config),
+	}
+	if config.AddressValidationThreshold > 0 {
+		// require a valid cookie while the server is under load
+		config.AcceptCookie = s.limiter.AcceptCookie(config.AcceptCookie)
 	}
 	if supportsTLS {
 		if err := s.setupTLS(); err != nil {
 			return nil, err
 		}
 	}
-	go s.serve()
+	s.packetHandlers = getMultiplexer().AddConn(conn)
+	if err := s.packetHandlers.SetUnknownPacketHandler(s); err != nil {
+		close(s.errorChan) // stops the go routine started by setupTLS
+		return nil, err
+	}
 	utils.Debugf("Listening for %s connections on %s", conn.LocalAddr().Network(), conn.LocalAddr().String())
 	return s, nil
 }
@@ -122,10 +212,11 @@ func (s *server) setupTLS() error {
 	if err != nil {
 		return err
 	}
-	serverTLS, sessionChan, err := newServerTLS(s.conn, s.config, cookieHandler, s.tlsConf)
+	serverTLS, sessionChan, err := newServerTLS(s.conn, s.config, s, cookieHandler, s.tlsConf)
 	if err != nil {
 		return err
 	}
+	serverTLS.underLoad = s.limiter.UnderLoad
 	s.serverTLS = serverTLS
 	// handle TLS connection establishment statelessly
 	go func() {
@@ -136,12 +227,15 @@ func (s *server) setupTLS() error {
 			case tlsSession := <-sessionChan:
 				connID := tlsSession.connID
 				sess := tlsSession.sess
+				s.sessionsMutex.Lock()
 				if _, ok := s.sessions[connID]; ok { // drop this session if it already exists
-					return
+					s.sessionsMutex.Unlock()
+					s.limiter.Release(tlsSession.remoteAddr)
+					continue
 				}
-				s.sessionsMutex.Lock()
 				s.sessions[connID] = sess
 				s.sessionsMutex.Unlock()
+				s.limiter.SessionCreated()
 				s.runHandshakeAndSession(sess, connID)
 			}
 		}
@@ -211,45 +305,81 @@ func populateServerConfig(config *Config) *Config {
 		maxIncomingUniStreams = 0
 	}
 
+	congestionControl := config.CongestionControl
+	if congestionControl == nil {
+		congestionControl = CongestionControlCubic
+	}
+	keyUpdateInterval := config.KeyUpdateInterval
+	if keyUpdateInterval == 0 {
+		keyUpdateInterval = protocol.DefaultKeyUpdateInterval
+	}
+	maxPacketSize := config.MaxPacketSize
+	if maxPacketSize == 0 {
+		maxPacketSize = protocol.DefaultMaxPacketSize
+	}
+	maxPacketSize = utils.MinByteCount(maxPacketSize, protocol.MaxMaxPacketSize)
+	maxAckDelay := config.MaxAckDelay
+	if maxAckDelay == 0 {
+		maxAckDelay = protocol.AckSendDelay
+	}
+	maxAckDelay = utils.MinDuration(maxAckDelay, protocol.MaxMaxAckDelay)
+	maxAckDelay = utils.MaxDuration(maxAckDelay, protocol.MinAckDelay)
+	packetsBeforeAck := config.RetransmittablePacketsBeforeAck
+	if packetsBeforeAck <= 0 {
+		packetsBeforeAck = protocol.RetransmittablePacketsBeforeAck
+	}
+
 	return &Config{
 		Versions:                              versions,
 		HandshakeTimeout:                      handshakeTimeout,
 		IdleTimeout:                           idleTimeout,
 		AcceptCookie:                          vsa,
 		KeepAlive:                             config.KeepAlive,
+		EnableDatagrams:                       config.EnableDatagrams,
 		MaxReceiveStreamFlowControlWindow:     maxReceiveStreamFlowControlWindow,
 		MaxReceiveConnectionFlowControlWindow: maxReceiveConnectionFlowControlWindow,
 		MaxIncomingStreams:                    maxIncomingStreams,
 		MaxIncomingUniStreams:                 maxIncomingUniStreams,
-	}
-}
-
-// serve listens on an existing PacketConn
-func (s *server) serve() {
-	for {
-		data := *getPacketBuffer()
-		data = data[:protocol.MaxReceivePacketSize]
-		// The packet size should not exceed protocol.MaxReceivePacketSize bytes
-		// If it does, we only read a truncated packet, which will then end up undecryptable
-		n, remoteAddr, err := s.conn.ReadFrom(data)
-		if err != nil {
-			s.serverError = err
-			close(s.errorChan)
-			_ = s.Close()
-			return
-		}
-		data = data[:n]
-		if err := s.handlePacket(s.conn, remoteAddr, data); err != nil {
-			utils.Errorf("error handling packet: %s", err.Error())
-		}
+		Tracer:                                config.Tracer,
+		GetLogWriter:                          config.GetLogWriter,
+		CongestionControl:                     congestionControl,
+		StatelessResetKey:                     config.StatelessResetKey,
+		MaxIncomingSessions:                   config.MaxIncomingSessions,
+		MaxIncomingHandshakes:                 config.MaxIncomingHandshakes,
+		MaxIncomingSessionRatePerIP:           config.MaxIncomingSessionRatePerIP,
+		AddressValidationThreshold:            config.AddressValidationThreshold,
+		KeyUpdateInterval:                     keyUpdateInterval,
+		MaxPacketSize:                         maxPacketSize,
+		MaxAckDelay:                           maxAckDelay,
+		RetransmittablePacketsBeforeAck:       packetsBeforeAck,
 	}
 }
 
 // Accept returns newly openend sessions
 func (s *server) Accept() (Session, error) {
-	var sess Session
+	return s.AcceptContext(context.Background())
+}
+
+// AcceptContext returns newly openend sessions, or the context's error when the context is canceled
+func (s *server) AcceptContext(ctx context.Context) (Session, error) {
+	return s.accept(ctx)
+}
+
+// Accept returns new sessions before the handshake completes
+func (s *earlyServer) Accept() (EarlySession, error) {
+	return s.AcceptContext(context.Background())
+}
+
+// AcceptContext returns new sessions before the handshake completes, or the context's error when the context is canceled
+func (s *earlyServer) AcceptContext(ctx context.Context) (EarlySession, error) {
+	return s.accept(ctx)
+}
+
+func (s *server) accept(ctx context.Context) (EarlySession, error) {
 	select {
-	case sess = <-s.sessionQueue:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case sess := <-s.sessionQueue:
 		return sess, nil
 	case <-s.errorChan:
 		return nil, s.serverError
@@ -264,24 +394,54 @@ func (s *server) Close() error {
 		return nil
 	}
 	s.closed = true
+	if s.serverError == nil {
+		s.serverError = errServerClosed
+	}
 
 	var wg sync.WaitGroup
+	// a session might be registered for multiple connection IDs
+	closing := make(map[packetHandler]struct{})
 	for _, session := range s.sessions {
-		if session != nil {
-			wg.Add(1)
-			go func(sess packetHandler) {
-				// session.Close() blocks until the CONNECTION_CLOSE has been sent and the run-loop has stopped
-				_ = sess.Close(nil)
-				wg.Done()
-			}(session)
+		if session == nil {
+			continue
 		}
+		if _, ok := closing[session]; ok {
+			continue
+		}
+		closing[session] = struct{}{}
+		wg.Add(1)
+		go func(sess packetHandler) {
+			// session.Close() blocks until the CONNECTION_CLOSE has been sent and the run-loop has stopped
+			_ = sess.Close(nil)
+			wg.Done()
+		}(session)
 	}
 	s.sessionsMutex.Unlock()
 	wg.Wait()
 
-	err := s.conn.Close()
-	<-s.errorChan // wait for serve() to return
-	return err
+	s.packetHandlers.RemoveUnknownPacketHandler()
+	close(s.errorChan)
+	if !s.createdPacketConn {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+// closeWithError is called when reading from the conn fails.
+func (s *server) closeWithError(e error) {
+	s.sessionsMutex.Lock()
+	if s.closed {
+		s.sessionsMutex.Unlock()
+		return
+	}
+	s.serverError = e
+	s.sessionsMutex.Unlock()
+	_ = s.Close()
+}
+
+// ListenerStats returns a snapshot of the server's session counters
+func (s *server) ListenerStats() ListenerStats {
+	return s.limiter.Stats()
 }
 
 // Addr returns the server's network address
@@ -289,7 +449,13 @@ func (s *server) Addr() net.Addr {
 	return s.conn.LocalAddr()
 }
 
-func (s *server) handlePacket(pconn net.PacketConn, remoteAddr net.Addr, packet []byte) error {
+func (s *server) handlePacket(remoteAddr net.Addr, packet []byte, ecn protocol.ECN) {
+	if err := s.handlePacketImpl(remoteAddr, packet, ecn); err != nil {
+		utils.Errorf("error handling packet: %s", err.Error())
+	}
+}
+
+func (s *server) handlePacketImpl(remoteAddr net.Addr, packet []byte, ecn protocol.ECN) error {
 	rcvTime := time.Now()
 
 	r := bytes.NewReader(packet)
@@ -302,9 +468,18 @@ func (s *server) handlePacket(pconn net.PacketConn, remoteAddr net.Addr, packet
 	connID := hdr.ConnectionID
 
 	if hdr.Type == protocol.PacketTypeInitial {
-		if s.supportsTLS {
-			go s.serverTLS.HandleInitial(remoteAddr, hdr, packetData)
+		if !s.supportsTLS {
+			return nil
 		}
+		if !s.limiter.Reserve(remoteAddr, rcvTime) {
+			utils.Debugf("Dropping Initial packet from %s for connection %x. Too many connection attempts.", remoteAddr, connID)
+			return nil
+		}
+		go func() {
+			if !s.serverTLS.HandleInitial(remoteAddr, hdr, packetData) {
+				s.limiter.Release(remoteAddr)
+			}
+		}()
 		return nil
 	}
 
@@ -333,11 +508,18 @@ func (s *server) handlePacket(pconn net.PacketConn, remoteAddr net.Addr, packet
 		return nil
 	}
 
+	// 0-RTT packets can arrive before the Initial packet was processed, or after the server rejected the 0-RTT data.
+	// The client will retransmit the data after the handshake completes.
+	if !sessionKnown && hdr.IsLongHeader && hdr.Type == protocol.PacketType0RTT {
+		return nil
+	}
+
 	// If we don't have a session for this connection, and this packet cannot open a new connection, send a Public Reset
 	// This should only happen after a server restart, when we still receive packets for connections that we lost the state for.
 	// TODO(#943): implement sending of IETF draft style stateless resets
 	if !sessionKnown && (!hdr.VersionFlag && hdr.Type != protocol.PacketTypeInitial) {
-		_, err = pconn.WriteTo(wire.WritePublicReset(connID, 0, 0), remoteAddr)
+		token := handshake.GetStatelessResetToken(s.config.StatelessResetKey, connID)
+		_, err = s.conn.WriteTo(wire.WritePublicReset(connID, 0, handshake.NonceProof(token)), remoteAddr)
 		return err
 	}
 
@@ -356,7 +538,7 @@ func (s *server) handlePacket(pconn net.PacketConn, remoteAddr net.Addr, packet
 			return errors.New("dropping small packet with unknown version")
 		}
 		utils.Infof("Client offered version %s, sending Version Negotiation Packet", hdr.Version)
-		_, err := pconn.WriteTo(wire.ComposeGQUICVersionNegotiation(hdr.ConnectionID, s.config.Versions), remoteAddr)
+		_, err := s.conn.WriteTo(wire.ComposeGQUICVersionNegotiation(hdr.ConnectionID, s.config.Versions), remoteAddr)
 		return err
 	}
 
@@ -371,10 +553,14 @@ func (s *server) handlePacket(pconn net.PacketConn, remoteAddr net.Addr, packet
 		if !protocol.IsSupportedVersion(s.config.Versions, version) {
 			return errors.New("Server BUG: negotiated version not supported")
 		}
+		if !s.limiter.Reserve(remoteAddr, rcvTime) {
+			utils.Debugf("Dropping packet from %s for new connection %x. Too many connection attempts.", remoteAddr, connID)
+			return nil
+		}
 
 		utils.Infof("Serving new connection: %x, version %s from %v", hdr.ConnectionID, version, remoteAddr)
 		session, err = s.newSession(
-			&conn{pconn: pconn, currentAddr: remoteAddr},
+			newConn(s.conn, remoteAddr),
 			version,
 			hdr.ConnectionID,
 			s.scfg,
@@ -382,11 +568,13 @@ func (s *server) handlePacket(pconn net.PacketConn, remoteAddr net.Addr, packet
 			s.config,
 		)
 		if err != nil {
+			s.limiter.Release(remoteAddr)
 			return err
 		}
 		s.sessionsMutex.Lock()
 		s.sessions[connID] = session
 		s.sessionsMutex.Unlock()
+		s.limiter.SessionCreated()
 
 		s.runHandshakeAndSession(session, connID)
 	}
@@ -395,6 +583,7 @@ func (s *server) handlePacket(pconn net.PacketConn, remoteAddr net.Addr, packet
 		header:     hdr,
 		data:       packetData,
 		rcvTime:    rcvTime,
+		ecn:        ecn,
 	})
 	return nil
 }
@@ -404,16 +593,50 @@ func (s *server) runHandshakeAndSession(session packetHandler, connID protocol.C
 		_ = session.run()
 		// session.run() returns as soon as the session is closed
 		s.removeConnection(connID)
+		s.limiter.SessionClosed()
+	}()
+
+	// The handshake status is read by a separate go routine,
+	// so that handshakes are counted correctly while the session queue is full.
+	var handshakeErr error
+	handshakeDone := make(chan struct{})
+	go func() {
+		handshakeErr = <-session.handshakeStatus()
+		s.limiter.HandshakeFinished()
+		close(handshakeDone)
 	}()
 
 	go func() {
-		if err := <-session.handshakeStatus(); err != nil {
+		earlySessionReady := session.earlySessionReady()
+		for {
+			select {
+			case <-earlySessionReady:
+				if !s.acceptEarlySessions {
+					// wait for the handshake to complete
+					earlySessionReady = nil
+					continue
+				}
+			case <-handshakeDone:
+				if handshakeErr != nil {
+					return
+				}
+			}
+			s.sessionQueue <- session
 			return
 		}
-		s.sessionQueue <- session
 	}()
 }
 
+func (s *server) addConnectionID(id protocol.ConnectionID, session packetHandler) {
+	s.sessionsMutex.Lock()
+	s.sessions[id] = session
+	s.sessionsMutex.Unlock()
+}
+
+func (s *server) retireConnectionID(id protocol.ConnectionID) {
+	s.removeConnection(id)
+}
+
 func (s *server) removeConnection(id protocol.ConnectionID) {
 	s.sessionsMutex.Lock()
 	s.sessions[id] = nil
@@ -4,13 +4,18 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/binary"
 	"errors"
+	"io"
 	"net"
 	"reflect"
 	"time"
 
+	"github.com/bifurcation/mint"
 	"github.com/lucas-clemente/quic-go/internal/crypto"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
+	"github.com/lucas-clemente/quic-go/internal/mocks/handshake"
+	"github.com/lucas-clemente/quic-go/internal/mocks/logging"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/testdata"
 	"github.com/lucas-clemente/quic-go/internal/utils"
@@ -29,6 +34,8 @@ type mockSession struct {
 	closedRemote  bool
 	stopRunLoop   chan struct{} // run returns as soon as this channel receives a value
 	handshakeChan chan error
+	// earlySessionReadyChan is closed when the session can be used before the handshake completes
+	earlySessionReadyChan chan struct{}
 }
 
 func (s *mockSession) handlePacket(*receivedPacket) {
@@ -66,11 +73,35 @@ func (s *mockSession) LocalAddr() net.Addr                     { panic("not impl
 func (s *mockSession) RemoteAddr() net.Addr                    { panic("not implemented") }
 func (*mockSession) Context() context.Context                  { panic("not implemented") }
 func (*mockSession) ConnectionState() ConnectionState          { panic("not implemented") }
+func (*mockSession) ConnectionStats() ConnectionStats          { panic("not implemented") }
 func (*mockSession) GetVersion() protocol.VersionNumber        { return protocol.VersionWhatever }
 func (s *mockSession) handshakeStatus() <-chan error           { return s.handshakeChan }
+func (s *mockSession) earlySessionReady() <-chan struct{}      { return s.earlySessionReadyChan }
+func (*mockSession) HandshakeComplete() context.Context        { panic("not implemented") }
 func (*mockSession) getCryptoStream() cryptoStreamI            { panic("not implemented") }
+func (*mockSession) SendMessage([]byte) error                  { panic("not implemented") }
+func (*mockSession) ReceiveMessage() ([]byte, error)           { panic("not implemented") }
+func (*mockSession) MigrateTo(net.PacketConn) error            { panic("not implemented") }
+func (*mockSession) UpdateKeys() error                         { panic("not implemented") }
+func (*mockSession) GoAway(error) error                        { panic("not implemented") }
 
-var _ Session = &mockSession{}
+func (*mockSession) AcceptStreamContext(context.Context) (Stream, error) {
+	panic("not implemented")
+}
+func (*mockSession) AcceptUniStreamContext(context.Context) (ReceiveStream, error) {
+	panic("not implemented")
+}
+func (*mockSession) OpenStreamSyncContext(context.Context) (Stream, error) {
+	panic("not implemented")
+}
+func (*mockSession) OpenUniStreamSyncContext(context.Context) (SendStream, error) {
+	panic("not implemented")
+}
+func (*mockSession) RequestAckFrequency(int, time.Duration, bool) error {
+	panic("not implemented")
+}
+
+var _ packetHandler = &mockSession{}
 
 func newMockSession(
 	_ connection,
@@ -81,9 +112,10 @@ func newMockSession(
 	_ *Config,
 ) (packetHandler, error) {
 	s := mockSession{
-		connectionID:  connectionID,
-		handshakeChan: make(chan error),
-		stopRunLoop:   make(chan struct{}),
+		connectionID:          connectionID,
+		handshakeChan:         make(chan error),
+		earlySessionReadyChan: make(chan struct{}),
+		stopRunLoop:           make(chan struct{}),
 	}
 	return &s, nil
 }
@@ -103,19 +135,23 @@ var _ = Describe("Server", func() {
 
 	Context("with mock session", func() {
 		var (
-			serv        *server
-			firstPacket []byte // a valid first packet for a new connection with connectionID 0x4cfa9f9b668619f6 (= connID)
-			connID      = protocol.ConnectionID(0x4cfa9f9b668619f6)
+			serv           *server
+			packetHandlers *MockPacketHandlerManager
+			firstPacket    []byte // a valid first packet for a new connection with connectionID 0x4cfa9f9b668619f6 (= connID)
+			connID         = protocol.ConnectionID(0x4cfa9f9b668619f6)
 		)
 
 		BeforeEach(func() {
+			packetHandlers = NewMockPacketHandlerManager(mockCtrl)
 			serv = &server{
-				sessions:     make(map[protocol.ConnectionID]packetHandler),
-				newSession:   newMockSession,
-				conn:         conn,
-				config:       config,
-				sessionQueue: make(chan Session, 5),
-				errorChan:    make(chan struct{}),
+				sessions:       make(map[protocol.ConnectionID]packetHandler),
+				newSession:     newMockSession,
+				conn:           conn,
+				packetHandlers: packetHandlers,
+				config:         config,
+				sessionQueue:   make(chan EarlySession, 5),
+				errorChan:      make(chan struct{}),
+				limiter:        newConnectionLimiter(config),
 			}
 			b := &bytes.Buffer{}
 			utils.BigEndian.WriteUint32(b, uint32(protocol.SupportedVersions[0]))
@@ -125,12 +161,23 @@ var _ = Describe("Server", func() {
 		})
 
 		It("setups with the right values", func() {
+			tracer := mocklogging.NewMockTracer(mockCtrl)
 			config := &Config{
 				HandshakeTimeout:            1337 * time.Minute,
 				IdleTimeout:                 42 * time.Hour,
 				RequestConnectionIDOmission: true,
 				MaxIncomingStreams:          1234,
 				MaxIncomingUniStreams:       4321,
+				Tracer:                      tracer,
+				GetLogWriter:                func(ConnectionID) io.WriteCloser { return nil },
+				CongestionControl:           CongestionControlNewReno,
+				EnableDatagrams:             true,
+				StatelessResetKey:           bytes.Repeat([]byte{'f'}, 32),
+				MaxIncomingSessions:         100,
+				MaxIncomingHandshakes:       10,
+				MaxIncomingSessionRatePerIP: 5,
+				AddressValidationThreshold:  8,
+				MaxPacketSize:               1400,
 			}
 			c := populateServerConfig(config)
 			Expect(c.HandshakeTimeout).To(Equal(1337 * time.Minute))
@@ -138,6 +185,50 @@ var _ = Describe("Server", func() {
 			Expect(c.RequestConnectionIDOmission).To(BeFalse())
 			Expect(c.MaxIncomingStreams).To(Equal(1234))
 			Expect(c.MaxIncomingUniStreams).To(Equal(4321))
+			Expect(c.Tracer).To(Equal(tracer))
+			Expect(c.GetLogWriter).ToNot(BeNil())
+			Expect(reflect.ValueOf(c.CongestionControl)).To(Equal(reflect.ValueOf(CongestionControlNewReno)))
+			Expect(c.EnableDatagrams).To(BeTrue())
+			Expect(c.StatelessResetKey).To(Equal(bytes.Repeat([]byte{'f'}, 32)))
+			Expect(c.MaxIncomingSessions).To(Equal(100))
+			Expect(c.MaxIncomingHandshakes).To(Equal(10))
+			Expect(c.MaxIncomingSessionRatePerIP).To(Equal(5))
+			Expect(c.AddressValidationThreshold).To(Equal(8))
+			Expect(c.MaxPacketSize).To(BeEquivalentTo(1400))
+		})
+
+		It("uses the configured ACK policy", func() {
+			c := populateServerConfig(&Config{
+				MaxAckDelay:                     time.Millisecond,
+				RetransmittablePacketsBeforeAck: 1,
+			})
+			Expect(c.MaxAckDelay).To(Equal(time.Millisecond))
+			Expect(c.RetransmittablePacketsBeforeAck).To(Equal(1))
+		})
+
+		It("uses the default ACK policy", func() {
+			c := populateServerConfig(&Config{})
+			Expect(c.MaxAckDelay).To(Equal(protocol.AckSendDelay))
+			Expect(c.RetransmittablePacketsBeforeAck).To(Equal(protocol.RetransmittablePacketsBeforeAck))
+		})
+
+		It("limits the maximum ACK delay", func() {
+			c := populateServerConfig(&Config{MaxAckDelay: time.Hour})
+			Expect(c.MaxAckDelay).To(Equal(protocol.MaxMaxAckDelay))
+		})
+
+		It("doesn't use a maximum ACK delay smaller than the minimum ACK delay", func() {
+			c := populateServerConfig(&Config{MaxAckDelay: time.Microsecond})
+			Expect(c.MaxAckDelay).To(Equal(protocol.MinAckDelay))
+		})
+
+		It("uses the default maximum packet size", func() {
+			Expect(populateServerConfig(&Config{}).MaxPacketSize).To(Equal(protocol.DefaultMaxPacketSize))
+		})
+
+		It("limits the maximum packet size to the size of the receive buffers", func() {
+			c := populateServerConfig(&Config{MaxPacketSize: 2 * protocol.MaxMaxPacketSize})
+			Expect(c.MaxPacketSize).To(Equal(protocol.MaxMaxPacketSize))
 		})
 
 		It("disables bidirectional streams", func() {
@@ -169,7 +260,7 @@ var _ = Describe("Server", func() {
 		})
 
 		It("creates new sessions", func() {
-			err := serv.handlePacket(nil, nil, firstPacket)
+			err := serv.handlePacketImpl(nil, firstPacket, protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			sess := serv.sessions[connID].(*mockSession)
@@ -230,7 +321,7 @@ var _ = Describe("Server", func() {
 				acceptedSess, err = serv.Accept()
 				Expect(err).ToNot(HaveOccurred())
 			}()
-			err := serv.handlePacket(nil, nil, firstPacket)
+			err := serv.handlePacketImpl(nil, firstPacket, protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			sess := serv.sessions[connID].(*mockSession)
@@ -240,6 +331,64 @@ var _ = Describe("Server", func() {
 			close(done)
 		}, 0.5)
 
+		It("doesn't accept a session before the handshake completes", func(done Done) {
+			var acceptedSess Session
+			go func() {
+				defer GinkgoRecover()
+				var err error
+				acceptedSess, err = serv.Accept()
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			err := serv.handlePacketImpl(nil, firstPacket, protocol.ECNNon)
+			Expect(err).ToNot(HaveOccurred())
+			sess := serv.sessions[connID].(*mockSession)
+			close(sess.earlySessionReadyChan)
+			Consistently(func() Session { return acceptedSess }).Should(BeNil())
+			close(sess.handshakeChan)
+			Eventually(func() Session { return acceptedSess }).Should(Equal(sess))
+			close(done)
+		}, 0.5)
+
+		It("accepts a session early, as soon as it can be used", func(done Done) {
+			serv.acceptEarlySessions = true
+			var acceptedSess EarlySession
+			go func() {
+				defer GinkgoRecover()
+				var err error
+				acceptedSess, err = (&earlyServer{serv}).Accept()
+				Expect(err).ToNot(HaveOccurred())
+			}()
+			err := serv.handlePacketImpl(nil, firstPacket, protocol.ECNNon)
+			Expect(err).ToNot(HaveOccurred())
+			sess := serv.sessions[connID].(*mockSession)
+			Consistently(func() EarlySession { return acceptedSess }).Should(BeNil())
+			close(sess.earlySessionReadyChan)
+			Eventually(func() EarlySession { return acceptedSess }).Should(Equal(sess))
+			close(done)
+		}, 0.5)
+
+		It("stops accepting when the context is canceled", func(done Done) {
+			ctx, cancel := context.WithCancel(context.Background())
+			errChan := make(chan error)
+			go func() {
+				defer GinkgoRecover()
+				_, err := serv.AcceptContext(ctx)
+				errChan <- err
+			}()
+			Consistently(errChan).ShouldNot(Receive())
+			cancel()
+			Eventually(errChan).Should(Receive(MatchError(context.Canceled)))
+			// the session is returned by the next call to Accept
+			err := serv.handlePacketImpl(nil, firstPacket, protocol.ECNNon)
+			Expect(err).ToNot(HaveOccurred())
+			sess := serv.sessions[connID].(*mockSession)
+			close(sess.handshakeChan)
+			acceptedSess, err := serv.Accept()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(acceptedSess).To(Equal(sess))
+			close(done)
+		}, 0.5)
+
 		It("doesn't accept session that error during the handshake", func(done Done) {
 			var accepted bool
 			go func() {
@@ -247,7 +396,7 @@ var _ = Describe("Server", func() {
 				serv.Accept()
 				accepted = true
 			}()
-			err := serv.handlePacket(nil, nil, firstPacket)
+			err := serv.handlePacketImpl(nil, firstPacket, protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			sess := serv.sessions[connID].(*mockSession)
@@ -256,21 +405,227 @@ var _ = Describe("Server", func() {
 			close(done)
 		})
 
+		Context("limiting new connections", func() {
+			// getFirstPacket returns a valid first packet for a new connection with the given connection ID
+			getFirstPacket := func(connID protocol.ConnectionID) []byte {
+				p := append([]byte{}, firstPacket...)
+				binary.BigEndian.PutUint64(p[1:9], uint64(connID))
+				return p
+			}
+
+			It("counts sessions and handshakes", func() {
+				Expect(serv.handlePacketImpl(udpAddr, firstPacket, protocol.ECNNon)).To(Succeed())
+				stats := serv.ListenerStats()
+				Expect(stats.ActiveSessions).To(BeEquivalentTo(1))
+				Expect(stats.ActiveHandshakes).To(BeEquivalentTo(1))
+				Expect(stats.SessionsCreated).To(BeEquivalentTo(1))
+				sess := serv.sessions[connID].(*mockSession)
+				close(sess.handshakeChan)
+				Eventually(func() uint64 { return serv.ListenerStats().ActiveHandshakes }).Should(BeZero())
+				Expect(serv.ListenerStats().ActiveSessions).To(BeEquivalentTo(1))
+				sess.Close(nil)
+				Eventually(func() uint64 { return serv.ListenerStats().ActiveSessions }).Should(BeZero())
+				Expect(serv.ListenerStats().SessionsCreated).To(BeEquivalentTo(1))
+			})
+
+			It("counts handshakes that fail", func() {
+				Expect(serv.handlePacketImpl(udpAddr, firstPacket, protocol.ECNNon)).To(Succeed())
+				sess := serv.sessions[connID].(*mockSession)
+				sess.handshakeChan <- errors.New("handshake failed")
+				Eventually(func() uint64 { return serv.ListenerStats().ActiveHandshakes }).Should(BeZero())
+			})
+
+			It("counts handshakes while the session queue is full", func() {
+				serv.sessionQueue = make(chan EarlySession)
+				Expect(serv.handlePacketImpl(udpAddr, firstPacket, protocol.ECNNon)).To(Succeed())
+				sess := serv.sessions[connID].(*mockSession)
+				close(sess.handshakeChan)
+				Eventually(func() uint64 { return serv.ListenerStats().ActiveHandshakes }).Should(BeZero())
+				Expect(serv.Accept()).To(Equal(sess))
+			})
+
+			It("drops new connections when the session limit is reached", func() {
+				serv.limiter = newConnectionLimiter(&Config{MaxIncomingSessions: 1})
+				Expect(serv.handlePacketImpl(udpAddr, getFirstPacket(1), protocol.ECNNon)).To(Succeed())
+				Expect(serv.handlePacketImpl(udpAddr, getFirstPacket(2), protocol.ECNNon)).To(Succeed())
+				Expect(serv.sessions).To(HaveLen(1))
+				Expect(serv.sessions).To(HaveKey(protocol.ConnectionID(1)))
+				Expect(serv.ListenerStats().RefusedSessionLimit).To(BeEquivalentTo(1))
+				// packets for the existing session are still handled
+				Expect(serv.handlePacketImpl(udpAddr, getFirstPacket(1), protocol.ECNNon)).To(Succeed())
+				Expect(serv.sessions[1].(*mockSession).packetCount).To(Equal(2))
+				// completing the handshake doesn't free a slot
+				sess := serv.sessions[1].(*mockSession)
+				close(sess.handshakeChan)
+				Eventually(func() uint64 { return serv.ListenerStats().ActiveHandshakes }).Should(BeZero())
+				Expect(serv.handlePacketImpl(udpAddr, getFirstPacket(3), protocol.ECNNon)).To(Succeed())
+				Expect(serv.ListenerStats().RefusedSessionLimit).To(BeEquivalentTo(2))
+				// closing the session does
+				sess.Close(nil)
+				Eventually(func() uint64 { return serv.ListenerStats().ActiveSessions }).Should(BeZero())
+				Expect(serv.handlePacketImpl(udpAddr, getFirstPacket(4), protocol.ECNNon)).To(Succeed())
+				serv.sessionsMutex.RLock()
+				defer serv.sessionsMutex.RUnlock()
+				Expect(serv.sessions[4]).ToNot(BeNil())
+			})
+
+			It("drops new connections when the handshake limit is reached", func() {
+				serv.limiter = newConnectionLimiter(&Config{MaxIncomingHandshakes: 1})
+				Expect(serv.handlePacketImpl(udpAddr, getFirstPacket(1), protocol.ECNNon)).To(Succeed())
+				Expect(serv.handlePacketImpl(udpAddr, getFirstPacket(2), protocol.ECNNon)).To(Succeed())
+				Expect(serv.sessions).To(HaveLen(1))
+				Expect(serv.ListenerStats().RefusedHandshakeLimit).To(BeEquivalentTo(1))
+				close(serv.sessions[1].(*mockSession).handshakeChan)
+				Eventually(func() uint64 { return serv.ListenerStats().ActiveHandshakes }).Should(BeZero())
+				Expect(serv.handlePacketImpl(udpAddr, getFirstPacket(3), protocol.ECNNon)).To(Succeed())
+				serv.sessionsMutex.RLock()
+				defer serv.sessionsMutex.RUnlock()
+				Expect(serv.sessions).To(HaveLen(2))
+				Expect(serv.sessions[3]).ToNot(BeNil())
+			})
+
+			It("limits the rate of new connections per IP", func() {
+				serv.limiter = newConnectionLimiter(&Config{MaxIncomingSessionRatePerIP: 2})
+				Expect(serv.handlePacketImpl(udpAddr, getFirstPacket(1), protocol.ECNNon)).To(Succeed())
+				Expect(serv.handlePacketImpl(udpAddr, getFirstPacket(2), protocol.ECNNon)).To(Succeed())
+				Expect(serv.handlePacketImpl(udpAddr, getFirstPacket(3), protocol.ECNNon)).To(Succeed())
+				Expect(serv.sessions).To(HaveLen(2))
+				Expect(serv.sessions).ToNot(HaveKey(protocol.ConnectionID(3)))
+				Expect(serv.ListenerStats().RefusedRateLimit).To(BeEquivalentTo(1))
+				// connections from a different IP are not affected
+				otherAddr := &net.UDPAddr{IP: net.IPv4(192, 168, 100, 201), Port: 1337}
+				Expect(serv.handlePacketImpl(otherAddr, getFirstPacket(4), protocol.ECNNon)).To(Succeed())
+				Expect(serv.sessions).To(HaveKey(protocol.ConnectionID(4)))
+			})
+
+			It("releases the reservation if creating the session fails", func() {
+				serv.newSession = func(connection, protocol.VersionNumber, protocol.ConnectionID, *handshake.ServerConfig, *tls.Config, *Config) (packetHandler, error) {
+					return nil, errors.New("session creation failed")
+				}
+				Expect(serv.handlePacketImpl(udpAddr, firstPacket, protocol.ECNNon)).To(MatchError("session creation failed"))
+				stats := serv.ListenerStats()
+				Expect(stats.ActiveSessions).To(BeZero())
+				Expect(stats.ActiveHandshakes).To(BeZero())
+				Expect(stats.SessionsCreated).To(BeZero())
+			})
+
+			It("drops Initial packets when the limit is reached", func() {
+				serv.supportsTLS = true
+				serv.limiter = newConnectionLimiter(&Config{MaxIncomingSessions: 1})
+				serv.limiter.numSessions = 1
+				hdr := &wire.Header{
+					IsLongHeader: true,
+					Type:         protocol.PacketTypeInitial,
+					ConnectionID: 0x1337,
+					PacketNumber: 1,
+					Version:      protocol.VersionTLS,
+				}
+				b := &bytes.Buffer{}
+				Expect(hdr.Write(b, protocol.PerspectiveClient, protocol.VersionTLS)).To(Succeed())
+				b.Write(bytes.Repeat([]byte{0}, protocol.MinInitialPacketSize))
+				// serverTLS is nil, so handling the packet would panic
+				Expect(serv.handlePacketImpl(udpAddr, b.Bytes(), protocol.ECNNon)).To(Succeed())
+				Expect(serv.ListenerStats().RefusedSessionLimit).To(BeEquivalentTo(1))
+			})
+
+			It("doesn't count Initial packets answered with a Retry towards the rate limit", func() {
+				serv.supportsTLS = true
+				serv.config = populateServerConfig(&Config{Versions: []protocol.VersionNumber{protocol.VersionTLS}})
+				serv.limiter = newConnectionLimiter(&Config{MaxIncomingSessionRatePerIP: 1})
+				serverTLS, _, err := newServerTLS(conn, serv.config, serv, nil, testdata.GetTLSConfig())
+				Expect(err).ToNot(HaveOccurred())
+				mintTLS := mockhandshake.NewMockMintTLS(mockCtrl)
+				serverTLS.newMintConn = func(bc *handshake.CryptoStreamConn, _ protocol.ConnectionID, _ protocol.VersionNumber, _ bool) (handshake.MintTLS, <-chan handshake.TransportParameters, error) {
+					mintTLS.EXPECT().Handshake().Return(mint.AlertStatelessRetry).Do(func() {
+						bc.Write([]byte("Retry with this Cookie"))
+					})
+					return mintTLS, nil, nil
+				}
+				serv.serverTLS = serverTLS
+
+				hdr := &wire.Header{
+					IsLongHeader: true,
+					Type:         protocol.PacketTypeInitial,
+					ConnectionID: 0x1337,
+					PacketNumber: 1,
+					Version:      protocol.VersionTLS,
+				}
+				hdrBuf := &bytes.Buffer{}
+				Expect(hdr.Write(hdrBuf, protocol.PerspectiveClient, protocol.VersionTLS)).To(Succeed())
+				aead, err := crypto.NewNullAEAD(protocol.PerspectiveClient, hdr.ConnectionID, protocol.VersionTLS)
+				Expect(err).ToNot(HaveOccurred())
+				payload := &bytes.Buffer{}
+				Expect((&wire.StreamFrame{Data: []byte("Client Hello")}).Write(payload, protocol.VersionTLS)).To(Succeed())
+				payload.Write(bytes.Repeat([]byte{0}, protocol.MinInitialPacketSize-hdrBuf.Len()-aead.Overhead()-payload.Len()))
+				packet := aead.Seal(hdrBuf.Bytes(), payload.Bytes(), hdr.PacketNumber, hdrBuf.Bytes())
+
+				// the first Initial is answered with a Retry, the retried Initial must not be dropped
+				for i := 0; i < 2; i++ {
+					Expect(serv.handlePacketImpl(udpAddr, packet, protocol.ECNNon)).To(Succeed())
+					Eventually(func() uint64 { return serv.ListenerStats().ActiveHandshakes }).Should(BeZero())
+				}
+				Expect(conn.dataWritten.Len()).ToNot(BeZero())
+				Expect(serv.ListenerStats().RefusedRateLimit).To(BeZero())
+			})
+		})
+
 		It("assigns packets to existing sessions", func() {
-			err := serv.handlePacket(nil, nil, firstPacket)
+			err := serv.handlePacketImpl(nil, firstPacket, protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
-			err = serv.handlePacket(nil, nil, []byte{0x08, 0x4c, 0xfa, 0x9f, 0x9b, 0x66, 0x86, 0x19, 0xf6, 0x01})
+			err = serv.handlePacketImpl(nil, []byte{0x08, 0x4c, 0xfa, 0x9f, 0x9b, 0x66, 0x86, 0x19, 0xf6, 0x01}, protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			Expect(serv.sessions[connID].(*mockSession).connectionID).To(Equal(connID))
 			Expect(serv.sessions[connID].(*mockSession).packetCount).To(Equal(2))
 		})
 
+		It("drops 0-RTT packets for unknown connections", func() {
+			b := &bytes.Buffer{}
+			hdr := wire.Header{
+				Type:         protocol.PacketType0RTT,
+				IsLongHeader: true,
+				ConnectionID: 0x1337,
+				PacketNumber: 0x55,
+				Version:      protocol.VersionTLS,
+			}
+			Expect(hdr.Write(b, protocol.PerspectiveClient, protocol.VersionTLS)).To(Succeed())
+			b.Write(bytes.Repeat([]byte{0}, 100))
+			// the server would panic if it tried to send a Public Reset on the nil connection
+			err := serv.handlePacketImpl(nil, b.Bytes(), protocol.ECNNon)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serv.sessions).To(BeEmpty())
+		})
+
+		It("assigns packets for additional connection IDs to the same session", func() {
+			err := serv.handlePacketImpl(nil, firstPacket, protocol.ECNNon)
+			Expect(err).ToNot(HaveOccurred())
+			sess := serv.sessions[connID]
+			serv.addConnectionID(0xdeadbeef, sess)
+			err = serv.handlePacketImpl(nil, []byte{0x08, 0, 0, 0, 0, 0xde, 0xad, 0xbe, 0xef, 0x01}, protocol.ECNNon)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(serv.sessions).To(HaveLen(2))
+			Expect(sess.(*mockSession).packetCount).To(Equal(2))
+		})
+
+		It("ignores packets for retired connection IDs", func() {
+			serv.deleteClosedSessionsAfter = time.Second // make sure that the nil value for the retired connection ID doesn't get deleted in this test
+			err := serv.handlePacketImpl(nil, firstPacket, protocol.ECNNon)
+			Expect(err).ToNot(HaveOccurred())
+			sess := serv.sessions[connID]
+			serv.addConnectionID(0xdeadbeef, sess)
+			serv.retireConnectionID(0xdeadbeef)
+			Expect(serv.sessions).To(HaveKeyWithValue(protocol.ConnectionID(0xdeadbeef), BeNil()))
+			err = serv.handlePacketImpl(nil, []byte{0x08, 0, 0, 0, 0, 0xde, 0xad, 0xbe, 0xef, 0x01}, protocol.ECNNon)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.(*mockSession).packetCount).To(Equal(1))
+			Expect(conn.dataWritten.Len()).To(BeZero())
+		})
+
 		It("closes and deletes sessions", func() {
 			serv.deleteClosedSessionsAfter = time.Second // make sure that the nil value for the closed session doesn't get deleted in this test
 			nullAEAD, err := crypto.NewNullAEAD(protocol.PerspectiveServer, connID, protocol.VersionWhatever)
 			Expect(err).ToNot(HaveOccurred())
-			err = serv.handlePacket(nil, nil, append(firstPacket, nullAEAD.Seal(nil, nil, 0, firstPacket)...))
+			err = serv.handlePacketImpl(nil, append(firstPacket, nullAEAD.Seal(nil, nil, 0, firstPacket)...), protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			Expect(serv.sessions[connID]).ToNot(BeNil())
@@ -285,7 +640,7 @@ var _ = Describe("Server", func() {
 			serv.deleteClosedSessionsAfter = 25 * time.Millisecond
 			nullAEAD, err := crypto.NewNullAEAD(protocol.PerspectiveServer, connID, protocol.VersionWhatever)
 			Expect(err).ToNot(HaveOccurred())
-			err = serv.handlePacket(nil, nil, append(firstPacket, nullAEAD.Seal(nil, nil, 0, firstPacket)...))
+			err = serv.handlePacketImpl(nil, append(firstPacket, nullAEAD.Seal(nil, nil, 0, firstPacket)...), protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			Expect(serv.sessions).To(HaveKey(connID))
@@ -299,19 +654,36 @@ var _ = Describe("Server", func() {
 			}).Should(BeFalse())
 		})
 
-		It("closes sessions and the connection when Close is called", func() {
-			go serv.serve()
+		It("closes sessions when Close is called", func() {
+			packetHandlers.EXPECT().RemoveUnknownPacketHandler()
 			session, _ := newMockSession(nil, 0, 0, nil, nil, nil)
 			serv.sessions[1] = session
 			err := serv.Close()
 			Expect(err).NotTo(HaveOccurred())
 			Expect(session.(*mockSession).closed).To(BeTrue())
+			Expect(conn.closed).To(BeFalse())
+		})
+
+		It("closes the connection when Close is called, if it created the connection", func() {
+			packetHandlers.EXPECT().RemoveUnknownPacketHandler()
+			serv.createdPacketConn = true
+			Expect(serv.Close()).To(Succeed())
 			Expect(conn.closed).To(BeTrue())
 		})
 
+		It("closes sessions registered for multiple connection IDs only once", func() {
+			packetHandlers.EXPECT().RemoveUnknownPacketHandler()
+			session, _ := newMockSession(nil, 0, 0, nil, nil, nil)
+			serv.sessions[1] = session
+			serv.sessions[2] = session
+			err := serv.Close()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(session.(*mockSession).closed).To(BeTrue())
+		})
+
 		It("ignores packets for closed sessions", func() {
 			serv.sessions[connID] = nil
-			err := serv.handlePacket(nil, nil, []byte{0x08, 0x4c, 0xfa, 0x9f, 0x9b, 0x66, 0x86, 0x19, 0xf6, 0x01})
+			err := serv.handlePacketImpl(nil, []byte{0x08, 0x4c, 0xfa, 0x9f, 0x9b, 0x66, 0x86, 0x19, 0xf6, 0x01}, protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			Expect(serv.sessions[connID]).To(BeNil())
@@ -332,8 +704,7 @@ var _ = Describe("Server", func() {
 			go func() {
 				defer GinkgoRecover()
 				_, err := ln.Accept()
-				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(ContainSubstring("use of closed network connection"))
+				Expect(err).To(MatchError(errServerClosed))
 				returned = true
 			}()
 			ln.Close()
@@ -341,9 +712,9 @@ var _ = Describe("Server", func() {
 		})
 
 		It("errors when encountering a connection error", func(done Done) {
+			packetHandlers.EXPECT().RemoveUnknownPacketHandler()
 			testErr := errors.New("connection error")
-			conn.readErr = testErr
-			go serv.serve()
+			serv.closeWithError(testErr)
 			_, err := serv.Accept()
 			Expect(err).To(MatchError(testErr))
 			Expect(serv.Close()).To(Succeed())
@@ -351,19 +722,24 @@ var _ = Describe("Server", func() {
 		}, 0.5)
 
 		It("closes all sessions when encountering a connection error", func() {
+			packetHandlers.EXPECT().RemoveUnknownPacketHandler()
 			session, _ := newMockSession(nil, 0, 0, nil, nil, nil)
 			serv.sessions[0x12345] = session
 			Expect(serv.sessions[0x12345].(*mockSession).closed).To(BeFalse())
-			testErr := errors.New("connection error")
-			conn.readErr = testErr
-			go serv.serve()
-			Eventually(func() Session { return serv.sessions[connID] }).Should(BeNil())
-			Eventually(func() bool { return session.(*mockSession).closed }).Should(BeTrue())
-			Expect(serv.Close()).To(Succeed())
+			serv.closeWithError(errors.New("connection error"))
+			Expect(session.(*mockSession).closed).To(BeTrue())
 		})
 
+		It("returns an error from Accept when it is closed", func(done Done) {
+			packetHandlers.EXPECT().RemoveUnknownPacketHandler()
+			Expect(serv.Close()).To(Succeed())
+			_, err := serv.Accept()
+			Expect(err).To(MatchError(errServerClosed))
+			close(done)
+		}, 0.5)
+
 		It("ignores delayed packets with mismatching versions", func() {
-			err := serv.handlePacket(nil, nil, firstPacket)
+			err := serv.handlePacketImpl(nil, firstPacket, protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions[connID].(*mockSession).packetCount).To(Equal(1))
 			b := &bytes.Buffer{}
@@ -371,7 +747,7 @@ var _ = Describe("Server", func() {
 			data := []byte{0x09, 0x4c, 0xfa, 0x9f, 0x9b, 0x66, 0x86, 0x19, 0xf6}
 			utils.BigEndian.WriteUint32(b, uint32(protocol.SupportedVersions[0]+1))
 			data = append(append(data, b.Bytes()...), 0x01)
-			err = serv.handlePacket(nil, nil, data)
+			err = serv.handlePacketImpl(nil, data, protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			// if we didn't ignore the packet, the server would try to send a version negotiation packet, which would make the test panic because it doesn't have a udpConn
 			Expect(conn.dataWritten.Bytes()).To(BeEmpty())
@@ -380,34 +756,34 @@ var _ = Describe("Server", func() {
 		})
 
 		It("errors on invalid public header", func() {
-			err := serv.handlePacket(nil, nil, nil)
+			err := serv.handlePacketImpl(nil, nil, protocol.ECNNon)
 			Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.InvalidPacketHeader))
 		})
 
 		It("ignores public resets for unknown connections", func() {
-			err := serv.handlePacket(nil, nil, wire.WritePublicReset(999, 1, 1337))
+			err := serv.handlePacketImpl(nil, wire.WritePublicReset(999, 1, 1337), protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(BeEmpty())
 		})
 
 		It("ignores public resets for known connections", func() {
-			err := serv.handlePacket(nil, nil, firstPacket)
+			err := serv.handlePacketImpl(nil, firstPacket, protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			Expect(serv.sessions[connID].(*mockSession).packetCount).To(Equal(1))
-			err = serv.handlePacket(nil, nil, wire.WritePublicReset(connID, 1, 1337))
+			err = serv.handlePacketImpl(nil, wire.WritePublicReset(connID, 1, 1337), protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			Expect(serv.sessions[connID].(*mockSession).packetCount).To(Equal(1))
 		})
 
 		It("ignores invalid public resets for known connections", func() {
-			err := serv.handlePacket(nil, nil, firstPacket)
+			err := serv.handlePacketImpl(nil, firstPacket, protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			Expect(serv.sessions[connID].(*mockSession).packetCount).To(Equal(1))
 			data := wire.WritePublicReset(connID, 1, 1337)
-			err = serv.handlePacket(nil, nil, data[:len(data)-2])
+			err = serv.handlePacketImpl(nil, data[:len(data)-2], protocol.ECNNon)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(serv.sessions).To(HaveLen(1))
 			Expect(serv.sessions[connID].(*mockSession).packetCount).To(Equal(1))
@@ -424,7 +800,7 @@ var _ = Describe("Server", func() {
 			}
 			hdr.Write(b, protocol.PerspectiveClient, 13 /* not a valid QUIC version */)
 			b.Write(bytes.Repeat([]byte{0}, protocol.MinClientHelloSize)) // add a fake CHLO
-			err := serv.handlePacket(conn, nil, b.Bytes())
+			err := serv.handlePacketImpl(nil, b.Bytes(), protocol.ECNNon)
 			Expect(conn.dataWritten.Bytes()).ToNot(BeEmpty())
 			Expect(err).ToNot(HaveOccurred())
 		})
@@ -439,7 +815,7 @@ var _ = Describe("Server", func() {
 			}
 			hdr.Write(b, protocol.PerspectiveClient, 13 /* not a valid QUIC version */)
 			b.Write(bytes.Repeat([]byte{0}, protocol.MinClientHelloSize-1)) // this packet is 1 byte too small
-			err := serv.handlePacket(conn, udpAddr, b.Bytes())
+			err := serv.handlePacketImpl(udpAddr, b.Bytes(), protocol.ECNNon)
 			Expect(err).To(MatchError("dropping small packet with unknown version"))
 			Expect(conn.dataWritten.Len()).Should(BeZero())
 		})
@@ -477,6 +853,26 @@ var _ = Describe("Server", func() {
 		Expect(server.config.IdleTimeout).To(Equal(protocol.DefaultIdleTimeout))
 		Expect(reflect.ValueOf(server.config.AcceptCookie)).To(Equal(reflect.ValueOf(defaultAcceptCookie)))
 		Expect(server.config.KeepAlive).To(BeFalse())
+		Expect(reflect.ValueOf(server.config.CongestionControl)).To(Equal(reflect.ValueOf(CongestionControlCubic)))
+		Expect(server.config.StatelessResetKey).To(HaveLen(32))
+	})
+
+	It("errors when the stateless reset key is too short", func() {
+		_, err := Listen(conn, &tls.Config{}, &Config{StatelessResetKey: make([]byte, 31)})
+		Expect(err).To(MatchError("StatelessResetKey too short (31 bytes, minimum 32 bytes)"))
+	})
+
+	It("errors when the maximum packet size is smaller than the initial packet size", func() {
+		_, err := Listen(conn, &tls.Config{}, &Config{MaxPacketSize: 1199})
+		Expect(err).To(MatchError("MaxPacketSize too small (1199 bytes, minimum 1200 bytes)"))
+	})
+
+	It("errors when another server already listens on the connection", func() {
+		ln, err := Listen(conn, nil, config)
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		_, err = Listen(conn, nil, config)
+		Expect(err).To(MatchError("only one server can listen on a net.PacketConn"))
 	})
 
 	It("listens on a given address", func() {
@@ -487,6 +883,29 @@ var _ = Describe("Server", func() {
 		Expect(serv.Addr().String()).To(Equal(addr))
 	})
 
+	It("only returns sessions after the handshake completes, when started with Listen", func() {
+		ln, err := Listen(conn, nil, config)
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		Expect(ln.(*server).acceptEarlySessions).To(BeFalse())
+	})
+
+	It("returns sessions before the handshake completes, when started with ListenEarly", func() {
+		ln, err := ListenEarly(conn, nil, config)
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		Expect(ln.(*earlyServer).acceptEarlySessions).To(BeTrue())
+	})
+
+	It("listens early on a given address", func() {
+		addr := "127.0.0.1:13580"
+		ln, err := ListenAddrEarly(addr, nil, config)
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		Expect(ln.Addr().String()).To(Equal(addr))
+		Expect(ln.(*earlyServer).acceptEarlySessions).To(BeTrue())
+	})
+
 	It("errors if given an invalid address", func() {
 		addr := "127.0.0.1"
 		_, err := ListenAddr(addr, nil, config)
@@ -610,6 +1029,27 @@ var _ = Describe("Server", func() {
 		Expect(conn.dataWritten.Bytes()[0] & 0x02).ToNot(BeZero()) // check that the ResetFlag is set
 		Expect(ln.(*server).sessions).To(BeEmpty())
 	})
+
+	It("authenticates the PublicReset using the stateless reset key", func() {
+		conf := populateServerConfig(&Config{StatelessResetKey: bytes.Repeat([]byte{'f'}, 32)})
+		connID := protocol.ConnectionID(0x4cfa9f9b668619f6)
+		conn.dataReadFrom = udpAddr
+		conn.dataToRead <- []byte{0x08, 0x4c, 0xfa, 0x9f, 0x9b, 0x66, 0x86, 0x19, 0xf6, 0x01}
+		ln, err := Listen(conn, nil, conf)
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+
+		Eventually(func() int { return conn.dataWritten.Len() }).ShouldNot(BeZero())
+		r := bytes.NewReader(conn.dataWritten.Bytes())
+		hdr, err := wire.ParseHeaderSentByServer(r, protocol.Version39)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hdr.ResetFlag).To(BeTrue())
+		Expect(hdr.ConnectionID).To(Equal(connID))
+		pr, err := wire.ParsePublicReset(r)
+		Expect(err).ToNot(HaveOccurred())
+		token := handshake.GetStatelessResetToken(bytes.Repeat([]byte{'f'}, 32), connID)
+		Expect(pr.Nonce).To(Equal(handshake.NonceProof(token)))
+	})
 })
 
 var _ = Describe("default source address verification", func() {
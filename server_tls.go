@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/bifurcation/mint"
 	"github.com/lucas-clemente/quic-go/internal/crypto"
@@ -27,8 +28,9 @@ func (n *nullAEAD) Open(dst, src []byte, packetNumber protocol.PacketNumber, ass
 }
 
 type tlsSession struct {
-	connID protocol.ConnectionID
-	sess   packetHandler
+	connID     protocol.ConnectionID
+	remoteAddr net.Addr
+	sess       packetHandler
 }
 
 type serverTLS struct {
@@ -37,7 +39,11 @@ type serverTLS struct {
 	supportedVersions []protocol.VersionNumber
 	mintConf          *mint.Config
 	params            *handshake.TransportParameters
-	newMintConn       func(*handshake.CryptoStreamConn, protocol.VersionNumber) (handshake.MintTLS, <-chan handshake.TransportParameters, error)
+	runner            sessionRunner
+	ticketStore       *handshake.SessionTicketStore
+	newMintConn       func(bc *handshake.CryptoStreamConn, connID protocol.ConnectionID, v protocol.VersionNumber, acceptEarlyData bool) (handshake.MintTLS, <-chan handshake.TransportParameters, error)
+	// underLoad says if the server requires address validation, see Config.AddressValidationThreshold
+	underLoad func() bool
 
 	sessionChan chan<- tlsSession
 }
@@ -45,6 +51,7 @@ type serverTLS struct {
 func newServerTLS(
 	conn net.PacketConn,
 	config *Config,
+	runner sessionRunner,
 	cookieHandler *handshake.CookieHandler,
 	tlsConf *tls.Config,
 ) (*serverTLS, <-chan tlsSession, error) {
@@ -59,6 +66,10 @@ func newServerTLS(
 	}
 	mconf.CookieProtector = cs
 	mconf.CookieHandler = cookieHandler
+	ticketStore := handshake.NewSessionTicketStore()
+	mconf.SendSessionTickets = true
+	mconf.TicketLifetime = uint32(protocol.SessionTicketLifetime / time.Second)
+	mconf.PSKs = ticketStore
 
 	sessionChan := make(chan tlsSession)
 	s := &serverTLS{
@@ -66,40 +77,58 @@ func newServerTLS(
 		config:            config,
 		supportedVersions: config.Versions,
 		mintConf:          mconf,
+		runner:            runner,
+		ticketStore:       ticketStore,
 		sessionChan:       sessionChan,
+		underLoad:         func() bool { return false },
 		params: &handshake.TransportParameters{
 			StreamFlowControlWindow:     protocol.ReceiveStreamFlowControlWindow,
 			ConnectionFlowControlWindow: protocol.ReceiveConnectionFlowControlWindow,
 			IdleTimeout:                 config.IdleTimeout,
 			MaxBidiStreamID:             protocol.MaxBidiStreamID(config.MaxIncomingStreams, protocol.PerspectiveServer),
 			MaxUniStreamID:              protocol.MaxUniStreamID(config.MaxIncomingUniStreams, protocol.PerspectiveServer),
+			MaxDatagramFrameSize:        maxDatagramFrameSize(config),
+			MaxAckDelay:                 config.MaxAckDelay,
+			MinAckDelay:                 protocol.MinAckDelay,
 		},
 	}
 	s.newMintConn = s.newMintConnImpl
 	return s, sessionChan, nil
 }
 
-func (s *serverTLS) HandleInitial(remoteAddr net.Addr, hdr *wire.Header, data []byte) {
+// HandleInitial handles an Initial packet.
+// It returns true if a new session was created.
+func (s *serverTLS) HandleInitial(remoteAddr net.Addr, hdr *wire.Header, data []byte) bool {
 	utils.Debugf("Received a Packet. Handling it statelessly.")
 	sess, err := s.handleInitialImpl(remoteAddr, hdr, data)
 	if err != nil {
 		utils.Errorf("Error occurred handling initial packet: %s", err)
-		return
+		return false
 	}
 	if sess == nil { // a stateless reset was done
-		return
+		return false
 	}
 	s.sessionChan <- tlsSession{
-		connID: hdr.ConnectionID,
-		sess:   sess,
+		connID:     hdr.ConnectionID,
+		remoteAddr: remoteAddr,
+		sess:       sess,
 	}
+	return true
 }
 
 // will be set to s.newMintConn by the constructor
-func (s *serverTLS) newMintConnImpl(bc *handshake.CryptoStreamConn, v protocol.VersionNumber) (handshake.MintTLS, <-chan handshake.TransportParameters, error) {
-	extHandler := handshake.NewExtensionHandlerServer(s.params, s.config.Versions, v)
+func (s *serverTLS) newMintConnImpl(bc *handshake.CryptoStreamConn, connID protocol.ConnectionID, v protocol.VersionNumber, acceptEarlyData bool) (handshake.MintTLS, <-chan handshake.TransportParameters, error) {
+	params := *s.params
+	token := handshake.GetStatelessResetToken(s.config.StatelessResetKey, connID)
+	params.StatelessResetToken = &token
+	extHandler := handshake.NewExtensionHandlerServer(&params, s.config.Versions, v, acceptEarlyData)
 	conf := s.mintConf.Clone()
 	conf.ExtensionHandler = extHandler
+	if acceptEarlyData {
+		// Performing a stateless retry would discard the 0-RTT packets.
+		// The session ticket proves that the client owns its address.
+		conf.RequireCookie = false
+	}
 	return newMintController(bc, conf, protocol.PerspectiveServer), extHandler.GetPeerParams(), nil
 }
 
@@ -158,7 +187,13 @@ func (s *serverTLS) handleUnpackedInitial(remoteAddr net.Addr, hdr *wire.Header,
 	version := hdr.Version
 	bc := handshake.NewCryptoStreamConn(remoteAddr)
 	bc.AddDataForReading(frame.Data)
-	tls, paramsChan, err := s.newMintConn(bc, version)
+	var earlyAEAD crypto.AEAD
+	var acceptEarlyData bool
+	// Under load, every client has to perform a stateless retry, which would discard the 0-RTT packets anyway.
+	if !s.underLoad() {
+		earlyAEAD, acceptEarlyData = s.ticketStore.AcceptEarlyData(frame.Data)
+	}
+	tls, paramsChan, err := s.newMintConn(bc, hdr.ConnectionID, version, acceptEarlyData)
 	if err != nil {
 		return nil, err
 	}
@@ -198,13 +233,15 @@ func (s *serverTLS) handleUnpackedInitial(remoteAddr net.Addr, hdr *wire.Header,
 	}
 	params := <-paramsChan
 	sess, err := newTLSServerSession(
-		&conn{pconn: s.conn, currentAddr: remoteAddr},
+		newConn(s.conn, remoteAddr),
+		s.runner,
 		hdr.ConnectionID,         // TODO: we can use a server-chosen connection ID here
 		protocol.PacketNumber(1), // TODO: use a random packet number here
 		s.config,
 		tls,
 		bc,
 		aead,
+		earlyAEAD,
 		&params,
 		version,
 	)
@@ -213,6 +250,6 @@ func (s *serverTLS) handleUnpackedInitial(remoteAddr net.Addr, hdr *wire.Header,
 	}
 	cs := sess.getCryptoStream()
 	cs.setReadOffset(frame.DataLen())
-	bc.SetStream(cs)
+	tls.SetCryptoStream(cs)
 	return sess, nil
 }
@@ -2,9 +2,12 @@ package quic
 
 import (
 	"bytes"
+	"encoding/hex"
 	"io"
+	"time"
 
 	"github.com/bifurcation/mint"
+	"github.com/golang/mock/gomock"
 	"github.com/lucas-clemente/quic-go/internal/crypto"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
@@ -26,20 +29,22 @@ var _ = Describe("Stateless TLS handling", func() {
 		mintTLS     *mockhandshake.MockMintTLS
 		extHandler  *mocks.MockTLSExtensionHandler
 		mintReply   io.Writer
+		acceptEarly bool
 	)
 
 	BeforeEach(func() {
 		mintTLS = mockhandshake.NewMockMintTLS(mockCtrl)
 		extHandler = mocks.NewMockTLSExtensionHandler(mockCtrl)
 		conn = newMockPacketConn()
-		config := &Config{
+		config := populateServerConfig(&Config{
 			Versions: []protocol.VersionNumber{protocol.VersionTLS},
-		}
+		})
 		var err error
-		server, sessionChan, err = newServerTLS(conn, config, nil, testdata.GetTLSConfig())
+		server, sessionChan, err = newServerTLS(conn, config, NewMockSessionRunner(mockCtrl), nil, testdata.GetTLSConfig())
 		Expect(err).ToNot(HaveOccurred())
-		server.newMintConn = func(bc *handshake.CryptoStreamConn, v protocol.VersionNumber) (handshake.MintTLS, <-chan handshake.TransportParameters, error) {
+		server.newMintConn = func(bc *handshake.CryptoStreamConn, _ protocol.ConnectionID, v protocol.VersionNumber, acceptEarlyData bool) (handshake.MintTLS, <-chan handshake.TransportParameters, error) {
 			mintReply = bc
+			acceptEarly = acceptEarlyData
 			return mintTLS, extHandler.GetPeerParams(), nil
 		}
 	})
@@ -107,7 +112,7 @@ var _ = Describe("Stateless TLS handling", func() {
 			mintReply.Write([]byte("Retry with this Cookie"))
 		})
 		hdr, data := getPacket(&wire.StreamFrame{Data: []byte("Client Hello")})
-		server.HandleInitial(nil, hdr, data)
+		Expect(server.HandleInitial(nil, hdr, data)).To(BeFalse())
 		Expect(conn.dataWritten.Len()).ToNot(BeZero())
 		hdr, err := wire.ParseHeaderSentByServer(bytes.NewReader(conn.dataWritten.Bytes()), protocol.VersionTLS)
 		Expect(err).ToNot(HaveOccurred())
@@ -122,6 +127,7 @@ var _ = Describe("Stateless TLS handling", func() {
 		mintTLS.EXPECT().Handshake().Return(mint.AlertNoAlert)
 		mintTLS.EXPECT().State().Return(mint.StateServerNegotiated)
 		mintTLS.EXPECT().State().Return(mint.StateServerWaitFlight2)
+		mintTLS.EXPECT().SetCryptoStream(gomock.Any())
 		paramsChan := make(chan handshake.TransportParameters, 1)
 		paramsChan <- handshake.TransportParameters{}
 		extHandler.EXPECT().GetPeerParams().Return(paramsChan)
@@ -129,13 +135,81 @@ var _ = Describe("Stateless TLS handling", func() {
 		done := make(chan struct{})
 		go func() {
 			defer GinkgoRecover()
-			server.HandleInitial(nil, hdr, data)
+			Expect(server.HandleInitial(nil, hdr, data)).To(BeTrue())
 			// the Handshake packet is written by the session
 			Expect(conn.dataWritten.Len()).To(BeZero())
 			close(done)
 		}()
 		Eventually(sessionChan).Should(Receive())
 		Eventually(done).Should(BeClosed())
+		Expect(acceptEarly).To(BeFalse())
+	})
+
+	It("accepts 0-RTT data, if the client offers a valid session ticket", func() {
+		psk := mint.PreSharedKey{
+			CipherSuite:  mint.TLS_AES_128_GCM_SHA256,
+			IsResumption: true,
+			Identity:     []byte("session ticket"),
+			Key:          make([]byte, 32),
+			ExpiresAt:    time.Now().Add(time.Hour),
+		}
+		server.ticketStore.Put(hex.EncodeToString(psk.Identity), psk)
+		// let mint generate a ClientHello offering the session ticket
+		cs := handshake.NewCryptoStreamConn(nil)
+		client := mint.Client(cs, &mint.Config{
+			ServerName:  "quic.clemente.io",
+			NonBlocking: true,
+			PSKs:        &mint.PSKMapCache{"quic.clemente.io": psk},
+		})
+		Expect(client.Handshake()).To(Equal(mint.AlertNoAlert))
+
+		mintTLS.EXPECT().Handshake().Return(mint.AlertNoAlert).Times(2)
+		mintTLS.EXPECT().State().Return(mint.StateServerNegotiated)
+		mintTLS.EXPECT().State().Return(mint.StateServerWaitFlight2)
+		mintTLS.EXPECT().SetCryptoStream(gomock.Any())
+		paramsChan := make(chan handshake.TransportParameters, 1)
+		paramsChan <- handshake.TransportParameters{}
+		extHandler.EXPECT().GetPeerParams().Return(paramsChan)
+		hdr, data := getPacket(&wire.StreamFrame{Data: cs.GetDataForWriting()})
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			server.HandleInitial(nil, hdr, data)
+			close(done)
+		}()
+		Eventually(sessionChan).Should(Receive())
+		Eventually(done).Should(BeClosed())
+		Expect(acceptEarly).To(BeTrue())
+	})
+
+	It("doesn't accept 0-RTT data when the server is under load", func() {
+		server.underLoad = func() bool { return true }
+		psk := mint.PreSharedKey{
+			CipherSuite:  mint.TLS_AES_128_GCM_SHA256,
+			IsResumption: true,
+			Identity:     []byte("session ticket"),
+			Key:          make([]byte, 32),
+			ExpiresAt:    time.Now().Add(time.Hour),
+		}
+		server.ticketStore.Put(hex.EncodeToString(psk.Identity), psk)
+		cs := handshake.NewCryptoStreamConn(nil)
+		client := mint.Client(cs, &mint.Config{
+			ServerName:  "quic.clemente.io",
+			NonBlocking: true,
+			PSKs:        &mint.PSKMapCache{"quic.clemente.io": psk},
+		})
+		Expect(client.Handshake()).To(Equal(mint.AlertNoAlert))
+
+		extHandler.EXPECT().GetPeerParams()
+		mintTLS.EXPECT().Handshake().Return(mint.AlertStatelessRetry).Do(func() {
+			mintReply.Write([]byte("Retry with this Cookie"))
+		})
+		hdr, data := getPacket(&wire.StreamFrame{Data: cs.GetDataForWriting()})
+		Expect(server.HandleInitial(nil, hdr, data)).To(BeFalse())
+		Expect(acceptEarly).To(BeFalse())
+		hdr, err := wire.ParseHeaderSentByServer(bytes.NewReader(conn.dataWritten.Bytes()), protocol.VersionTLS)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hdr.Type).To(Equal(protocol.PacketTypeRetry))
 	})
 
 	It("sends a CONNECTION_CLOSE, if mint returns an error", func() {
@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"errors"
@@ -17,7 +18,9 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
+	"github.com/lucas-clemente/quic-go/logging"
 	"github.com/lucas-clemente/quic-go/qerr"
+	"github.com/lucas-clemente/quic-go/qlog"
 )
 
 type unpacker interface {
@@ -34,13 +37,15 @@ type streamManager interface {
 	GetOrOpenReceiveStream(protocol.StreamID) (receiveStreamI, error)
 	OpenStream() (Stream, error)
 	OpenUniStream() (SendStream, error)
-	OpenStreamSync() (Stream, error)
-	OpenUniStreamSync() (SendStream, error)
-	AcceptStream() (Stream, error)
-	AcceptUniStream() (ReceiveStream, error)
+	OpenStreamSync(context.Context) (Stream, error)
+	OpenUniStreamSync(context.Context) (SendStream, error)
+	AcceptStream(context.Context) (Stream, error)
+	AcceptUniStream(context.Context) (ReceiveStream, error)
 	DeleteStream(protocol.StreamID) error
 	UpdateLimits(*handshake.TransportParameters)
 	HandleMaxStreamIDFrame(*wire.MaxStreamIDFrame) error
+	GoAway() (protocol.StreamID, error)
+	HandleGoawayFrame(*wire.GoawayFrame) error
 	CloseWithError(error)
 }
 
@@ -49,6 +54,7 @@ type receivedPacket struct {
 	header     *wire.Header
 	data       []byte
 	rcvTime    time.Time
+	ecn        protocol.ECN
 }
 
 var (
@@ -56,6 +62,16 @@ var (
 	newCryptoSetupClient = handshake.NewCryptoSetupClient
 )
 
+type migrationRequest struct {
+	pconn net.PacketConn
+	err   chan<- error
+}
+
+type ackFrequencyRequest struct {
+	frame *wire.AckFrequencyFrame
+	err   chan<- error
+}
+
 type closeError struct {
 	err    error
 	remote bool
@@ -69,6 +85,8 @@ type session struct {
 	config       *Config
 
 	conn connection
+	// runner is used to manage the connection IDs of IETF QUIC sessions
+	runner sessionRunner
 
 	streamsMap   streamManager
 	cryptoStream cryptoStreamI
@@ -79,6 +97,10 @@ type session struct {
 	receivedPacketHandler ackhandler.ReceivedPacketHandler
 	streamFramer          *streamFramer
 	windowUpdateQueue     *windowUpdateQueue
+	datagramQueue         *datagramQueue
+	pathValidator         *pathValidator
+	connIDGenerator       *connIDGenerator // only used by the server, nil for gQUIC
+	connIDManager         *connIDManager   // only used by the client, nil for gQUIC
 	connFlowController    flowcontrol.ConnectionFlowController
 
 	unpacker unpacker
@@ -88,6 +110,16 @@ type session struct {
 
 	receivedPackets  chan *receivedPacket
 	sendingScheduled chan struct{}
+	// statsRequests is used to retrieve the ConnectionStats from the run loop
+	statsRequests chan chan<- ConnectionStats
+	// migrationRequests is used to pass a new net.PacketConn to the run loop
+	migrationRequests chan migrationRequest
+	// ackFrequencyRequests is used to pass ACK_FREQUENCY frames to the run loop
+	ackFrequencyRequests chan ackFrequencyRequest
+	// the sequence number of the next ACK_FREQUENCY frame sent
+	nextAckFrequencySeq uint64
+	// the min_ack_delay we sent in our transport parameters, 0 if we didn't offer the ACK frequency extension
+	minAckDelay time.Duration
 	// closeChan is used to notify the run loop that it should terminate.
 	closeChan chan closeError
 	closeOnce sync.Once
@@ -110,6 +142,14 @@ type session struct {
 	// It is closed when the handshake is complete.
 	handshakeChan     chan error
 	handshakeComplete bool
+	// handshakeCtx is returned by HandshakeComplete.
+	// It is cancelled when the handshake completes, or when the session is closed.
+	handshakeCtx       context.Context
+	handshakeCtxCancel context.CancelFunc
+	// earlySessionReadyChan is closed as soon as the session can be used before the handshake completes,
+	// i.e. when the keys for 0-RTT data are available (or when the handshake completes).
+	// The first handshakeEvent signals that these keys are available.
+	earlySessionReadyChan chan struct{}
 
 	receivedFirstPacket              bool // since packet numbers start at 0, we can't use largestRcvdPacketNumber != 0 for this
 	receivedFirstForwardSecurePacket bool
@@ -122,16 +162,30 @@ type session struct {
 	lastNetworkActivityTime time.Time
 	// pacingDeadline is the time when the next packet should be sent
 	pacingDeadline time.Time
+	// While batching, sent packets are collected in the sendBatch, instead of being written to the connection one by one.
+	batching  bool
+	sendBatch []outgoingPacket
 
 	peerParams *handshake.TransportParameters
+	// the stateless reset tokens sent by the server, for every connection ID
+	// only used by the client
+	statelessResetTokens map[protocol.ConnectionID][16]byte
 
 	timer *utils.Timer
 	// keepAlivePingSent stores whether a Ping frame was sent to the peer or not
 	// it is reset as soon as we receive a packet from the peer
 	keepAlivePingSent bool
+
+	packetsSent     uint64
+	packetsReceived uint64
+	bytesSent       uint64
+	bytesReceived   uint64
+
+	// tracer is nil if the connection is not traced
+	tracer logging.ConnectionTracer
 }
 
-var _ Session = &session{}
+var _ EarlySession = &session{}
 var _ streamSender = &session{}
 
 // newSession makes a new session
@@ -155,11 +209,15 @@ func newSession(
 		paramsChan:     paramsChan,
 	}
 	s.preSetup()
+	statelessResetToken := s.getStatelessResetToken(s.connectionID)
 	transportParams := &handshake.TransportParameters{
 		StreamFlowControlWindow:     protocol.ReceiveStreamFlowControlWindow,
 		ConnectionFlowControlWindow: protocol.ReceiveConnectionFlowControlWindow,
 		MaxStreams:                  uint32(s.config.MaxIncomingStreams),
 		IdleTimeout:                 s.config.IdleTimeout,
+		MaxDatagramFrameSize:        maxDatagramFrameSize(s.config),
+		MaxAckDelay:                 s.config.MaxAckDelay,
+		StatelessResetToken:         &statelessResetToken,
 	}
 	cs, err := newCryptoSetup(
 		s.cryptoStream,
@@ -209,6 +267,8 @@ var newClientSession = func(
 		MaxStreams:                  uint32(s.config.MaxIncomingStreams),
 		IdleTimeout:                 s.config.IdleTimeout,
 		OmitConnectionID:            s.config.RequestConnectionIDOmission,
+		MaxDatagramFrameSize:        maxDatagramFrameSize(s.config),
+		MaxAckDelay:                 s.config.MaxAckDelay,
 	}
 	cs, err := newCryptoSetupClient(
 		s.cryptoStream,
@@ -216,6 +276,8 @@ var newClientSession = func(
 		s.connectionID,
 		s.version,
 		tlsConf,
+		s.config.ClientSessionCache,
+		clientSessionCacheKey(hostname, conn.RemoteAddr()),
 		transportParams,
 		paramsChan,
 		handshakeEvent,
@@ -231,30 +293,36 @@ var newClientSession = func(
 
 func newTLSServerSession(
 	conn connection,
+	runner sessionRunner,
 	connectionID protocol.ConnectionID,
 	initialPacketNumber protocol.PacketNumber,
 	config *Config,
 	tls handshake.MintTLS,
 	cryptoStreamConn *handshake.CryptoStreamConn,
 	nullAEAD crypto.AEAD,
+	earlyAEAD crypto.AEAD,
 	peerParams *handshake.TransportParameters,
 	v protocol.VersionNumber,
 ) (packetHandler, error) {
 	handshakeEvent := make(chan struct{}, 1)
 	s := &session{
 		conn:           conn,
+		runner:         runner,
 		config:         config,
 		connectionID:   connectionID,
 		perspective:    protocol.PerspectiveServer,
 		version:        v,
 		handshakeEvent: handshakeEvent,
+		minAckDelay:    protocol.MinAckDelay,
 	}
 	s.preSetup()
 	s.cryptoSetup = handshake.NewCryptoSetupTLSServer(
 		tls,
 		cryptoStreamConn,
 		nullAEAD,
+		earlyAEAD,
 		handshakeEvent,
+		s.config.KeyUpdateInterval,
 		v,
 	)
 	if err := s.postSetup(initialPacketNumber); err != nil {
@@ -269,32 +337,37 @@ func newTLSServerSession(
 // declare this as a variable, such that we can it mock it in the tests
 var newTLSClientSession = func(
 	conn connection,
+	runner sessionRunner,
 	hostname string,
 	v protocol.VersionNumber,
 	connectionID protocol.ConnectionID,
 	config *Config,
 	tls handshake.MintTLS,
-	paramsChan <-chan handshake.TransportParameters,
+	extHandler handshake.TLSExtensionHandler,
 	initialPacketNumber protocol.PacketNumber,
 ) (packetHandler, error) {
 	handshakeEvent := make(chan struct{}, 1)
 	s := &session{
 		conn:           conn,
+		runner:         runner,
 		config:         config,
 		connectionID:   connectionID,
 		perspective:    protocol.PerspectiveClient,
 		version:        v,
 		handshakeEvent: handshakeEvent,
-		paramsChan:     paramsChan,
+		paramsChan:     extHandler.GetPeerParams(),
+		minAckDelay:    protocol.MinAckDelay,
 	}
 	s.preSetup()
-	tls.SetCryptoStream(s.cryptoStream)
 	cs, err := handshake.NewCryptoSetupTLSClient(
 		s.cryptoStream,
 		s.connectionID,
-		hostname,
+		clientSessionCacheKey(hostname, conn.RemoteAddr()),
 		handshakeEvent,
 		tls,
+		extHandler,
+		s.config.ClientSessionCache,
+		s.config.KeyUpdateInterval,
 		v,
 	)
 	if err != nil {
@@ -305,6 +378,18 @@ var newTLSClientSession = func(
 }
 
 func (s *session) preSetup() {
+	var tracers []logging.ConnectionTracer
+	if s.config.Tracer != nil {
+		if t := s.config.Tracer.TracerForConnection(s.perspective, s.connectionID); t != nil {
+			tracers = append(tracers, t)
+		}
+	}
+	if s.config.GetLogWriter != nil {
+		if w := s.config.GetLogWriter(s.connectionID); w != nil {
+			tracers = append(tracers, qlog.NewConnectionTracer(w, s.perspective, s.connectionID))
+		}
+	}
+	s.tracer = logging.NewMultiplexedConnectionTracer(tracers...)
 	s.rttStats = &congestion.RTTStats{}
 	s.connFlowController = flowcontrol.NewConnectionFlowController(
 		protocol.ReceiveConnectionFlowControlWindow,
@@ -319,16 +404,24 @@ func (s *session) postSetup(initialPacketNumber protocol.PacketNumber) error {
 	s.receivedPackets = make(chan *receivedPacket, protocol.MaxSessionUnprocessedPackets)
 	s.closeChan = make(chan closeError, 1)
 	s.sendingScheduled = make(chan struct{}, 1)
+	s.statsRequests = make(chan chan<- ConnectionStats)
+	s.migrationRequests = make(chan migrationRequest)
+	s.ackFrequencyRequests = make(chan ackFrequencyRequest)
 	s.undecryptablePackets = make([]*receivedPacket, 0, protocol.MaxUndecryptablePackets)
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+	s.handshakeCtx, s.handshakeCtxCancel = context.WithCancel(context.Background())
+	s.earlySessionReadyChan = make(chan struct{})
 
 	s.timer = utils.NewTimer()
 	now := time.Now()
 	s.lastNetworkActivityTime = now
 	s.sessionCreationTime = now
 
-	s.sentPacketHandler = ackhandler.NewSentPacketHandler(s.rttStats)
-	s.receivedPacketHandler = ackhandler.NewReceivedPacketHandler(s.version)
+	// ECN is only supported for IETF QUIC, since gQUIC ACK frames can't carry the ECN counts
+	enableECN := s.version.UsesIETFFrameFormat() && s.conn.SupportsECN()
+	// probe packets are only sent if they can't be fragmented
+	s.sentPacketHandler = ackhandler.NewSentPacketHandler(s.rttStats, s.config.CongestionControl(s.rttStats), enableECN, s.config.MaxPacketSize, s.conn.SupportsPathMTUDiscovery(), s.tracer)
+	s.receivedPacketHandler = ackhandler.NewReceivedPacketHandler(s.config.MaxAckDelay, s.config.RetransmittablePacketsBeforeAck, s.version)
 
 	if s.version.UsesTLS() {
 		s.streamsMap = newStreamsMap(s, s.newFlowController, s.config.MaxIncomingStreams, s.config.MaxIncomingUniStreams, s.perspective, s.version)
@@ -336,14 +429,25 @@ func (s *session) postSetup(initialPacketNumber protocol.PacketNumber) error {
 		s.streamsMap = newStreamsMapLegacy(s.newStream, s.config.MaxIncomingStreams, s.perspective)
 	}
 	s.streamFramer = newStreamFramer(s.cryptoStream, s.streamsMap, s.version)
+	s.datagramQueue = newDatagramQueue(s.scheduleSending)
+	s.pathValidator = newPathValidator()
 	s.packer = newPacketPacker(s.connectionID,
 		initialPacketNumber,
 		s.cryptoSetup,
 		s.streamFramer,
+		s.datagramQueue,
 		s.perspective,
 		s.version,
 	)
 	s.windowUpdateQueue = newWindowUpdateQueue(s.streamsMap, s.cryptoStream, s.packer.QueueControlFrame)
+	// gQUIC doesn't support multiple connection IDs
+	if s.version.UsesIETFFrameFormat() {
+		if s.perspective == protocol.PerspectiveServer {
+			s.connIDGenerator = newConnIDGenerator(s.connectionID, s, s.runner, s.getStatelessResetToken, s.packer.QueueControlFrame)
+		} else {
+			s.connIDManager = newConnIDManager(s.connectionID, s, s.runner, s.packer.QueueControlFrame)
+		}
+	}
 	s.unpacker = &packetUnpacker{aead: s.cryptoSetup, version: s.version}
 	return nil
 }
@@ -351,6 +455,7 @@ func (s *session) postSetup(initialPacketNumber protocol.PacketNumber) error {
 // run the session main loop
 func (s *session) run() error {
 	defer s.ctxCancel()
+	defer s.handshakeCtxCancel()
 
 	go func() {
 		if err := s.cryptoSetup.HandleCryptoStream(); err != nil {
@@ -398,6 +503,12 @@ runLoop:
 			putPacketBuffer(&p.header.Raw)
 		case p := <-s.paramsChan:
 			s.processTransportParameters(&p)
+		case c := <-s.statsRequests:
+			c <- s.getConnectionStats()
+		case r := <-s.migrationRequests:
+			r.err <- s.migrate(r.pconn)
+		case r := <-s.ackFrequencyRequests:
+			r.err <- s.sendAckFrequency(r.frame)
 		case _, ok := <-handshakeEvent:
 			if !ok { // the aeadChanged chan was closed. This means that the handshake is completed.
 				s.handshakeComplete = true
@@ -408,9 +519,21 @@ runLoop:
 					// We need to make sure that the client actually sends such a packet.
 					s.packer.QueueControlFrame(&wire.PingFrame{})
 				}
+				if s.connIDGenerator != nil {
+					if err := s.connIDGenerator.IssueConnectionIDs(); err != nil {
+						s.closeLocal(err)
+					}
+				}
+				if s.version.UsesTLS() && s.perspective == protocol.PerspectiveClient && !s.cryptoSetup.ConnectionState().Used0RTT {
+					// If the server rejected the 0-RTT data, it needs to be retransmitted.
+					s.sentPacketHandler.Queue0RTTPacketsForRetransmission()
+				}
 				close(s.handshakeChan)
+				s.signalEarlySessionReady()
+				s.handshakeCtxCancel()
 			} else {
 				s.tryDecryptingQueuedPackets()
+				s.signalEarlySessionReady()
 			}
 		}
 
@@ -458,6 +581,12 @@ runLoop:
 		s.handshakeChan <- closeErr.err
 	}
 	s.handleCloseError(closeErr)
+	if s.connIDGenerator != nil {
+		s.connIDGenerator.RemoveAll()
+	}
+	if s.tracer != nil {
+		s.tracer.Close()
+	}
 	return closeErr.err
 }
 
@@ -465,10 +594,193 @@ func (s *session) Context() context.Context {
 	return s.ctx
 }
 
+// HandshakeComplete returns a context that is cancelled when the handshake completes.
+// It is also cancelled when the session is closed before that.
+func (s *session) HandshakeComplete() context.Context {
+	return s.handshakeCtx
+}
+
 func (s *session) ConnectionState() ConnectionState {
 	return s.cryptoSetup.ConnectionState()
 }
 
+// ConnectionStats returns a snapshot of the transport metrics.
+// While the session is running, the snapshot is taken by the run loop.
+func (s *session) ConnectionStats() ConnectionStats {
+	c := make(chan ConnectionStats, 1)
+	select {
+	case s.statsRequests <- c:
+		return <-c
+	case <-s.ctx.Done():
+		// the run loop has returned, so it's safe to access its state
+		return s.getConnectionStats()
+	}
+}
+
+// GoAway sends a GOAWAY frame.
+// The peer may continue to use all streams that we already accepted.
+func (s *session) GoAway(e error) error {
+	select {
+	case <-s.ctx.Done():
+		return errors.New("session already closed")
+	default:
+	}
+	lastGoodStream, err := s.streamsMap.GoAway()
+	if err != nil {
+		return err
+	}
+	var quicErr *qerr.QuicError
+	if e == nil {
+		quicErr = qerr.Error(qerr.PeerGoingAway, "")
+	} else {
+		quicErr = qerr.ToQuicError(e)
+	}
+	s.queueControlFrame(&wire.GoawayFrame{
+		ErrorCode:      quicErr.ErrorCode,
+		LastGoodStream: lastGoodStream,
+		ReasonPhrase:   quicErr.ErrorMessage,
+	})
+	return nil
+}
+
+// UpdateKeys requests an update of the 1-RTT keys.
+func (s *session) UpdateKeys() error {
+	if !s.version.UsesTLS() {
+		return errors.New("key updates are only supported for IETF QUIC")
+	}
+	return s.cryptoSetup.UpdateKeys()
+}
+
+// RequestAckFrequency sends an ACK_FREQUENCY frame.
+func (s *session) RequestAckFrequency(packetTolerance int, maxAckDelay time.Duration, ignoreOrder bool) error {
+	if packetTolerance < 1 {
+		return fmt.Errorf("invalid packet tolerance: %d", packetTolerance)
+	}
+	if maxAckDelay > protocol.MaxMaxAckDelay {
+		return fmt.Errorf("max ACK delay too large: %s (maximum %s)", maxAckDelay, protocol.MaxMaxAckDelay)
+	}
+	errChan := make(chan error, 1)
+	frame := &wire.AckFrequencyFrame{
+		PacketTolerance:   uint64(packetTolerance),
+		UpdateMaxAckDelay: maxAckDelay,
+		IgnoreOrder:       ignoreOrder,
+	}
+	select {
+	case s.ackFrequencyRequests <- ackFrequencyRequest{frame: frame, err: errChan}:
+		return <-errChan
+	case <-s.ctx.Done():
+		return errors.New("session already closed")
+	}
+}
+
+func (s *session) sendAckFrequency(frame *wire.AckFrequencyFrame) error {
+	if !s.handshakeComplete {
+		return errors.New("can't request an ACK frequency before the handshake completed")
+	}
+	// ACK_FREQUENCY frames can only be sent if the peer announced a min_ack_delay, which gQUIC doesn't
+	if !s.version.UsesIETFFrameFormat() || s.peerParams == nil || s.peerParams.MinAckDelay == 0 {
+		return errors.New("peer doesn't support ACK_FREQUENCY frames")
+	}
+	if frame.UpdateMaxAckDelay < s.peerParams.MinAckDelay {
+		return fmt.Errorf("max ACK delay too small: %s (peer's minimum %s)", frame.UpdateMaxAckDelay, s.peerParams.MinAckDelay)
+	}
+	frame.SequenceNumber = s.nextAckFrequencySeq
+	s.nextAckFrequencySeq++
+	// Until the peer receives this frame, it might still use the old max ACK delay.
+	// If the new value is smaller, this leads to a larger RTT estimate for a short time, which is safe.
+	s.sentPacketHandler.SetPeerMaxAckDelay(frame.UpdateMaxAckDelay)
+	s.queueControlFrame(frame)
+	return nil
+}
+
+// MigrateTo moves the session to a new net.PacketConn.
+func (s *session) MigrateTo(pconn net.PacketConn) error {
+	if s.perspective == protocol.PerspectiveServer {
+		return errors.New("only clients can migrate a session")
+	}
+	errChan := make(chan error, 1)
+	select {
+	case s.migrationRequests <- migrationRequest{pconn: pconn, err: errChan}:
+		return <-errChan
+	case <-s.ctx.Done():
+		return errors.New("session already closed")
+	}
+}
+
+func (s *session) migrate(pconn net.PacketConn) error {
+	if !s.handshakeComplete {
+		return errors.New("can't migrate a session before the handshake completed")
+	}
+	utils.Infof("Migrating connection %x from %s to %s", s.connectionID, s.conn.LocalAddr(), pconn.LocalAddr())
+	// use a new connection ID on the new path, so that on-path observers can't link the two paths
+	if s.connIDManager == nil {
+		utils.Debugf("Multiple connection IDs are not supported. Migrating with the current connection ID.")
+	} else if connID, ok := s.connIDManager.SwitchToNext(); ok {
+		utils.Debugf("Switching to connection ID %x", connID)
+		s.packer.SetConnectionID(connID)
+	} else {
+		utils.Debugf("No unused connection ID available. Migrating with the current connection ID.")
+	}
+	s.conn.SetPacketConn(pconn)
+	// the new net.PacketConn might not support setting the Don't Fragment bit
+	s.sentPacketHandler.OnConnectionMigration(s.conn.SupportsPathMTUDiscovery())
+	s.packer.SetMaxPacketSize(s.sentPacketHandler.MaxPacketSize())
+	// make sure that the peer receives a packet from the new address
+	s.packer.QueueControlFrame(&wire.PingFrame{})
+	return nil
+}
+
+// validatePath sends a PATH_CHALLENGE on the path to a new remote address.
+// Packets are only sent to the new address after it has been validated.
+func (s *session) validatePath(addr net.Addr, size protocol.ByteCount, rcvTime time.Time) error {
+	challengeTimeout := utils.MaxDuration(3*s.rttStats.SmoothedRTT(), protocol.MinPathChallengeInterval)
+	f, err := s.pathValidator.OnPacketReceived(addr, size, rcvTime, challengeTimeout)
+	if err != nil || f == nil {
+		return err
+	}
+	packet, err := s.packer.PackPathChallenge(f)
+	if err != nil {
+		return err
+	}
+	defer putPacketBuffer(&packet.raw)
+	if !s.pathValidator.AllowSending(protocol.ByteCount(len(packet.raw))) {
+		utils.Debugf("Not sending a PATH_CHALLENGE to %s. Anti-amplification limit reached.", addr)
+		return nil
+	}
+	utils.Debugf("Validating the path to %s", addr)
+	if err := s.trackSentPacket(packet, protocol.ECNNon); err != nil {
+		return err
+	}
+	return s.conn.WriteTo(packet.raw, addr)
+}
+
+// migrateRemoteAddr is called by the server when the client moved to a new remote address
+func (s *session) migrateRemoteAddr(addr net.Addr) {
+	utils.Infof("Peer of connection %x migrated from %s to %s", s.connectionID, s.conn.RemoteAddr(), addr)
+	s.conn.SetCurrentRemoteAddr(addr)
+	s.sentPacketHandler.OnConnectionMigration(s.conn.SupportsPathMTUDiscovery())
+	s.packer.SetMaxPacketSize(s.sentPacketHandler.MaxPacketSize())
+}
+
+func (s *session) getConnectionStats() ConnectionStats {
+	sentPacketStats := s.sentPacketHandler.GetStats()
+	return ConnectionStats{
+		MinRTT:               s.rttStats.MinRTT(),
+		LatestRTT:            s.rttStats.LatestRTT(),
+		SmoothedRTT:          s.rttStats.SmoothedRTT(),
+		MeanDeviation:        s.rttStats.MeanDeviation(),
+		CongestionWindow:     uint64(sentPacketStats.CongestionWindow),
+		BytesInFlight:        uint64(sentPacketStats.BytesInFlight),
+		PacketsSent:          s.packetsSent,
+		PacketsReceived:      s.packetsReceived,
+		PacketsLost:          sentPacketStats.PacketsLost,
+		PacketsRetransmitted: sentPacketStats.PacketsRetransmitted,
+		BytesSent:            s.bytesSent,
+		BytesReceived:        s.bytesReceived,
+		SpinBit:              s.packer.spinBit,
+	}
+}
+
 func (s *session) maybeResetTimer() {
 	var deadline time.Time
 	if s.config.KeepAlive && s.handshakeComplete && !s.keepAlivePingSent {
@@ -498,6 +810,10 @@ func (s *session) maybeResetTimer() {
 }
 
 func (s *session) handlePacketImpl(p *receivedPacket) error {
+	if p.header.ResetFlag {
+		s.handlePublicReset(p)
+		return nil
+	}
 	if s.perspective == protocol.PerspectiveClient {
 		diversificationNonce := p.header.DiversificationNonce
 		if len(diversificationNonce) > 0 {
@@ -544,6 +860,11 @@ func (s *session) handlePacketImpl(p *receivedPacket) error {
 	if err != nil {
 		return err
 	}
+	s.packetsReceived++
+	s.bytesReceived += uint64(len(data) + len(hdr.Raw))
+	if s.tracer != nil {
+		s.tracer.ReceivedPacket(hdr, protocol.ByteCount(len(data)+len(hdr.Raw)), packet.encryptionLevel, packet.frames)
+	}
 
 	// In TLS 1.3, the client considers the handshake complete as soon as
 	// it received the server's Finished message and sent its Finished.
@@ -554,6 +875,32 @@ func (s *session) handlePacketImpl(p *receivedPacket) error {
 		s.sentPacketHandler.SetHandshakeComplete()
 	}
 
+	// Only switch to a new remote address for packets that were not reordered,
+	// and only after decrypting, so we are sure the packet is not attacker-controlled.
+	if s.perspective == protocol.PerspectiveServer &&
+		packet.encryptionLevel == protocol.EncryptionForwardSecure &&
+		hdr.PacketNumber > s.largestRcvdPacketNumber &&
+		p.remoteAddr != nil &&
+		!sameAddr(s.conn.RemoteAddr(), p.remoteAddr) {
+		if s.version.UsesIETFFrameFormat() {
+			if err := s.validatePath(p.remoteAddr, protocol.ByteCount(len(data)+len(hdr.Raw)), p.rcvTime); err != nil {
+				return err
+			}
+		} else {
+			// gQUIC doesn't have a way to validate the new path
+			s.migrateRemoteAddr(p.remoteAddr)
+		}
+	}
+	// The client switched to a new connection ID. Use the same connection ID for the packets we send.
+	if s.perspective == protocol.PerspectiveServer &&
+		packet.encryptionLevel == protocol.EncryptionForwardSecure &&
+		hdr.PacketNumber > s.largestRcvdPacketNumber &&
+		!hdr.OmitConnectionID &&
+		hdr.ConnectionID != s.packer.connectionID {
+		utils.Debugf("Peer of connection %x switched to connection ID %x", s.connectionID, hdr.ConnectionID)
+		s.packer.SetConnectionID(hdr.ConnectionID)
+	}
+
 	s.lastRcvdPacketNumber = hdr.PacketNumber
 	// Only do this after decrypting, so we are sure the packet is not attacker-controlled
 	s.largestRcvdPacketNumber = utils.MaxPacketNumber(s.largestRcvdPacketNumber, hdr.PacketNumber)
@@ -562,7 +909,7 @@ func (s *session) handlePacketImpl(p *receivedPacket) error {
 	// The session will be closed and recreated as soon as the crypto setup processed the HRR.
 	if hdr.Type != protocol.PacketTypeRetry {
 		isRetransmittable := ackhandler.HasRetransmittableFrames(packet.frames)
-		if err := s.receivedPacketHandler.ReceivedPacket(hdr.PacketNumber, p.rcvTime, isRetransmittable); err != nil {
+		if err := s.receivedPacketHandler.ReceivedPacket(hdr.PacketNumber, p.ecn, p.rcvTime, isRetransmittable); err != nil {
 			return err
 		}
 	}
@@ -582,7 +929,7 @@ func (s *session) handleFrames(fs []wire.Frame, encLevel protocol.EncryptionLeve
 		case *wire.ConnectionCloseFrame:
 			s.closeRemote(qerr.Error(frame.ErrorCode, frame.ReasonPhrase))
 		case *wire.GoawayFrame:
-			err = errors.New("unimplemented: handling GOAWAY frames")
+			err = s.handleGoawayFrame(frame)
 		case *wire.StopWaitingFrame: // ignore STOP_WAITINGs
 		case *wire.RstStreamFrame:
 			err = s.handleRstStreamFrame(frame)
@@ -598,6 +945,18 @@ func (s *session) handleFrames(fs []wire.Frame, encLevel protocol.EncryptionLeve
 		case *wire.StopSendingFrame:
 			err = s.handleStopSendingFrame(frame)
 		case *wire.PingFrame:
+		case *wire.DatagramFrame:
+			err = s.handleDatagramFrame(frame)
+		case *wire.AckFrequencyFrame:
+			err = s.handleAckFrequencyFrame(frame)
+		case *wire.PathChallengeFrame:
+			s.handlePathChallengeFrame(frame)
+		case *wire.PathResponseFrame:
+			s.handlePathResponseFrame(frame)
+		case *wire.NewConnectionIDFrame:
+			err = s.handleNewConnectionIDFrame(frame)
+		case *wire.RetireConnectionIDFrame:
+			err = s.handleRetireConnectionIDFrame(frame)
 		default:
 			return errors.New("Session BUG: unexpected frame type")
 		}
@@ -624,6 +983,67 @@ func (s *session) handlePacket(p *receivedPacket) {
 	}
 }
 
+func (s *session) handleGoawayFrame(frame *wire.GoawayFrame) error {
+	utils.Infof("Peer of connection %x is going away: %s", s.connectionID, qerr.Error(frame.ErrorCode, frame.ReasonPhrase))
+	return s.streamsMap.HandleGoawayFrame(frame)
+}
+
+func (s *session) handlePathChallengeFrame(frame *wire.PathChallengeFrame) {
+	s.packer.QueueControlFrame(&wire.PathResponseFrame{Data: frame.Data})
+}
+
+func (s *session) handlePathResponseFrame(frame *wire.PathResponseFrame) {
+	if addr := s.pathValidator.OnPathResponse(frame); addr != nil {
+		s.migrateRemoteAddr(addr)
+	}
+}
+
+func (s *session) handleNewConnectionIDFrame(frame *wire.NewConnectionIDFrame) error {
+	if s.perspective == protocol.PerspectiveServer {
+		return qerr.Error(qerr.InvalidFrameData, "received NEW_CONNECTION_ID frame from the client")
+	}
+	if s.connIDManager == nil {
+		return qerr.Error(qerr.InvalidFrameData, "received NEW_CONNECTION_ID frame, but multiple connection IDs are not supported")
+	}
+	if err := s.connIDManager.Add(frame); err != nil {
+		return err
+	}
+	s.addStatelessResetToken(frame.ConnectionID, frame.StatelessResetToken)
+	return nil
+}
+
+func (s *session) handleRetireConnectionIDFrame(frame *wire.RetireConnectionIDFrame) error {
+	if s.perspective == protocol.PerspectiveClient {
+		return qerr.Error(qerr.InvalidFrameData, "received RETIRE_CONNECTION_ID frame from the server")
+	}
+	if s.connIDGenerator == nil {
+		return qerr.Error(qerr.InvalidFrameData, "received RETIRE_CONNECTION_ID frame, but multiple connection IDs are not supported")
+	}
+	return s.connIDGenerator.Retire(frame.SequenceNumber)
+}
+
+func (s *session) handleDatagramFrame(frame *wire.DatagramFrame) error {
+	if !s.config.EnableDatagrams {
+		return qerr.Error(qerr.InvalidFrameData, "received a DATAGRAM frame, but datagram support is disabled")
+	}
+	if l := frame.Length(s.version); l > maxDatagramFrameSize(s.config) {
+		return qerr.Error(qerr.InvalidFrameData, fmt.Sprintf("received a DATAGRAM frame larger than the maximum DATAGRAM frame size (%d bytes, maximum %d bytes)", l, maxDatagramFrameSize(s.config)))
+	}
+	s.datagramQueue.HandleDatagramFrame(frame)
+	return nil
+}
+
+func (s *session) handleAckFrequencyFrame(frame *wire.AckFrequencyFrame) error {
+	if !s.version.UsesIETFFrameFormat() || s.minAckDelay == 0 {
+		return qerr.Error(qerr.InvalidFrameData, "received ACK_FREQUENCY frame, but the ACK frequency extension wasn't negotiated")
+	}
+	if frame.UpdateMaxAckDelay < s.minAckDelay || frame.UpdateMaxAckDelay > protocol.MaxMaxAckDelay {
+		return qerr.Error(qerr.InvalidFrameData, fmt.Sprintf("invalid max ACK delay in ACK_FREQUENCY frame: %s", frame.UpdateMaxAckDelay))
+	}
+	s.receivedPacketHandler.SetAckFrequency(frame)
+	return nil
+}
+
 func (s *session) handleStreamFrame(frame *wire.StreamFrame) error {
 	if frame.StreamID == s.version.CryptoStreamID() {
 		if frame.FinBit {
@@ -704,6 +1124,7 @@ func (s *session) handleAckFrame(frame *wire.AckFrame, encLevel protocol.Encrypt
 		return err
 	}
 	s.receivedPacketHandler.IgnoreBelow(s.sentPacketHandler.GetLowestPacketNotConfirmedAcked())
+	s.packer.SetMaxPacketSize(s.sentPacketHandler.MaxPacketSize())
 	return nil
 }
 
@@ -743,11 +1164,22 @@ func (s *session) handleCloseError(closeErr closeError) error {
 	} else {
 		utils.Errorf("Closing session with error: %s", closeErr.err.Error())
 	}
+	if s.tracer != nil {
+		s.tracer.ClosedConnection(quicErr, closeErr.remote)
+	}
 
+	recreate := closeErr.err == errCloseSessionForNewVersion || closeErr.err == handshake.ErrCloseSessionForRetry
+	var streamErr error = quicErr
+	if recreate && s.isEarlySessionReady() {
+		// The session might already have been returned by DialEarly.
+		// The data sent on it is lost, and needs to be resent on a new session.
+		streamErr = Err0RTTRejected
+	}
 	s.cryptoStream.closeForShutdown(quicErr)
-	s.streamsMap.CloseWithError(quicErr)
+	s.streamsMap.CloseWithError(streamErr)
+	s.datagramQueue.CloseWithError(streamErr)
 
-	if closeErr.err == errCloseSessionForNewVersion || closeErr.err == handshake.ErrCloseSessionForRetry {
+	if recreate {
 		return nil
 	}
 
@@ -771,15 +1203,41 @@ func (s *session) processTransportParameters(params *handshake.TransportParamete
 		s.packer.SetOmitConnectionID()
 	}
 	s.connFlowController.UpdateSendWindow(params.ConnectionFlowControlWindow)
-	// the crypto stream is the only open stream at this moment
-	// so we don't need to update stream flow control windows
+	if params.MaxPacketSize != 0 {
+		s.sentPacketHandler.SetPeerMaxPacketSize(params.MaxPacketSize)
+	}
+	if params.MaxAckDelay != 0 {
+		s.sentPacketHandler.SetPeerMaxAckDelay(params.MaxAckDelay)
+	}
+	if s.config.EnableDatagrams {
+		s.datagramQueue.SetMaxFrameSize(params.MaxDatagramFrameSize)
+	}
+	if s.perspective == protocol.PerspectiveClient && params.StatelessResetToken != nil {
+		s.addStatelessResetToken(s.connectionID, *params.StatelessResetToken)
+	}
+	// The only streams open at this moment are the crypto stream, and streams opened to send 0-RTT data.
+	// The latter use the flow control windows the server sent in the previous connection.
+	// The server must not reduce them, so we don't need to update stream flow control windows.
 }
 
-func (s *session) sendPackets() error {
+func (s *session) sendPackets() (err error) {
 	s.pacingDeadline = time.Time{}
+	// all packets sent in this call are written to the connection as a batch
+	s.batching = true
+	defer func() {
+		s.batching = false
+		if ferr := s.flushSendBatch(); err == nil {
+			err = ferr
+		}
+	}()
 	if !s.sentPacketHandler.SendingAllowed() { // if congestion limited, at least try sending an ACK frame
 		return s.maybeSendAckOnlyPacket()
 	}
+	if size := s.sentPacketHandler.MTUProbeSize(time.Now()); size != 0 {
+		if err := s.sendMTUProbePacket(size); err != nil {
+			return err
+		}
+	}
 	numPackets := s.sentPacketHandler.ShouldSendNumPackets()
 	for i := 0; i < numPackets; i++ {
 		sentPacket, err := s.sendPacket()
@@ -797,6 +1255,27 @@ func (s *session) sendPackets() error {
 	return nil
 }
 
+// sendMTUProbePacket sends a probe packet for path MTU discovery.
+// It is not sent as part of a batch, since it might be larger than the MTU of the interface.
+func (s *session) sendMTUProbePacket(size protocol.ByteCount) error {
+	packet, err := s.packer.PackMTUProbePacket(size)
+	if err != nil {
+		return err
+	}
+	defer putPacketBuffer(&packet.raw)
+	// Probe packets are not marked, so that their loss doesn't interfere with ECN validation.
+	if err := s.trackSentPacket(packet, protocol.ECNNon); err != nil {
+		return err
+	}
+	utils.Debugf("Sending path MTU probe packet (%d bytes)", size)
+	// The kernel refuses to send packets that are larger than the MTU of the interface.
+	// The probe packet will be declared lost.
+	if err := s.conn.Write(packet.raw, protocol.ECNNon); err != nil && !isMsgSizeErr(err) {
+		return err
+	}
+	return nil
+}
+
 func (s *session) maybeSendAckOnlyPacket() error {
 	ack := s.receivedPacketHandler.GetAckFrame()
 	if ack == nil {
@@ -833,6 +1312,7 @@ func (s *session) sendPacket() (bool, error) {
 	}
 
 	// check for retransmissions first
+	var queuedRetransmission bool
 	for {
 		retransmitPacket := s.sentPacketHandler.DequeuePacketForRetransmission()
 		if retransmitPacket == nil {
@@ -847,7 +1327,9 @@ func (s *session) sendPacket() (bool, error) {
 		}
 
 		// retransmit handshake packets
-		if retransmitPacket.EncryptionLevel != protocol.EncryptionForwardSecure {
+		// The frames of 0-RTT packets are retransmitted like the frames of forward-secure packets.
+		// They are sent in 0-RTT packets as long as the handshake is not complete.
+		if retransmitPacket.EncryptionLevel != protocol.EncryptionForwardSecure && retransmitPacket.PacketType != protocol.PacketType0RTT {
 			utils.Debugf("\tDequeueing handshake retransmission for packet 0x%x", retransmitPacket.PacketNumber)
 			if !s.version.UsesIETFFrameFormat() {
 				s.packer.QueueControlFrame(s.sentPacketHandler.GetStopWaitingFrame(true))
@@ -856,14 +1338,16 @@ func (s *session) sendPacket() (bool, error) {
 			if err != nil {
 				return false, err
 			}
+			packet.isRetransmission = true
 			if err := s.sendPackedPacket(packet); err != nil {
 				return false, err
 			}
 			return true, nil
 		}
 
-		// queue all retransmittable frames sent in forward-secure packets
+		// queue all retransmittable frames sent in forward-secure and 0-RTT packets
 		utils.Debugf("\tDequeueing retransmission for packet 0x%x", retransmitPacket.PacketNumber)
+		queuedRetransmission = true
 		// resend the frames that were in the packet
 		for _, frame := range retransmitPacket.GetFramesForRetransmission() {
 			// TODO: only retransmit WINDOW_UPDATEs if they actually enlarge the window
@@ -886,6 +1370,8 @@ func (s *session) sendPacket() (bool, error) {
 	if err != nil || packet == nil {
 		return false, err
 	}
+	// Retransmitted STREAM frames that don't fit into this packet are sent (and counted) with the next packets.
+	packet.isRetransmission = queuedRetransmission || hasRetransmission
 	if err := s.sendPackedPacket(packet); err != nil {
 		return false, err
 	}
@@ -893,19 +1379,55 @@ func (s *session) sendPacket() (bool, error) {
 }
 
 func (s *session) sendPackedPacket(packet *packedPacket) error {
+	ecn := s.sentPacketHandler.ECNMode()
+	if err := s.trackSentPacket(packet, ecn); err != nil {
+		putPacketBuffer(&packet.raw)
+		return err
+	}
+	if s.batching {
+		s.sendBatch = append(s.sendBatch, outgoingPacket{data: packet.raw, ecn: ecn})
+		if len(s.sendBatch) >= protocol.PacketBatchSize {
+			return s.flushSendBatch()
+		}
+		return nil
+	}
 	defer putPacketBuffer(&packet.raw)
+	return s.conn.Write(packet.raw, ecn)
+}
+
+// flushSendBatch writes the packets collected while batching to the connection.
+func (s *session) flushSendBatch() error {
+	if len(s.sendBatch) == 0 {
+		return nil
+	}
+	err := s.conn.WriteBatch(s.sendBatch)
+	for i := range s.sendBatch {
+		data := s.sendBatch[i].data
+		putPacketBuffer(&data)
+		s.sendBatch[i] = outgoingPacket{}
+	}
+	s.sendBatch = s.sendBatch[:0]
+	return err
+}
+
+// trackSentPacket passes a packet that is about to be sent to the sentPacketHandler
+func (s *session) trackSentPacket(packet *packedPacket, ecn protocol.ECN) error {
 	err := s.sentPacketHandler.SentPacket(&ackhandler.Packet{
-		PacketNumber:    packet.header.PacketNumber,
-		PacketType:      packet.header.Type,
-		Frames:          packet.frames,
-		Length:          protocol.ByteCount(len(packet.raw)),
-		EncryptionLevel: packet.encryptionLevel,
+		PacketNumber:     packet.header.PacketNumber,
+		PacketType:       packet.header.Type,
+		Frames:           packet.frames,
+		Length:           protocol.ByteCount(len(packet.raw)),
+		EncryptionLevel:  packet.encryptionLevel,
+		ECN:              ecn,
+		IsMTUProbePacket: packet.isMTUProbePacket,
+		IsRetransmission: packet.isRetransmission,
 	})
 	if err != nil {
 		return err
 	}
 	s.logPacket(packet)
-	return s.conn.Write(packet.raw)
+	s.onPacketWritten(packet)
+	return nil
 }
 
 func (s *session) sendConnectionClose(quicErr *qerr.QuicError) error {
@@ -918,7 +1440,16 @@ func (s *session) sendConnectionClose(quicErr *qerr.QuicError) error {
 		return err
 	}
 	s.logPacket(packet)
-	return s.conn.Write(packet.raw)
+	s.onPacketWritten(packet)
+	return s.conn.Write(packet.raw, protocol.ECNNon)
+}
+
+func (s *session) onPacketWritten(packet *packedPacket) {
+	s.packetsSent++
+	s.bytesSent += uint64(len(packet.raw))
+	if s.tracer != nil {
+		s.tracer.SentPacket(packet.header, protocol.ByteCount(len(packet.raw)), packet.encryptionLevel, packet.frames)
+	}
 }
 
 func (s *session) logPacket(packet *packedPacket) {
@@ -950,11 +1481,19 @@ func (s *session) GetOrOpenStream(id protocol.StreamID) (Stream, error) {
 
 // AcceptStream returns the next stream openend by the peer
 func (s *session) AcceptStream() (Stream, error) {
-	return s.streamsMap.AcceptStream()
+	return s.AcceptStreamContext(context.Background())
+}
+
+func (s *session) AcceptStreamContext(ctx context.Context) (Stream, error) {
+	return s.streamsMap.AcceptStream(ctx)
 }
 
 func (s *session) AcceptUniStream() (ReceiveStream, error) {
-	return s.streamsMap.AcceptUniStream()
+	return s.AcceptUniStreamContext(context.Background())
+}
+
+func (s *session) AcceptUniStreamContext(ctx context.Context) (ReceiveStream, error) {
+	return s.streamsMap.AcceptUniStream(ctx)
 }
 
 // OpenStream opens a stream
@@ -963,7 +1502,11 @@ func (s *session) OpenStream() (Stream, error) {
 }
 
 func (s *session) OpenStreamSync() (Stream, error) {
-	return s.streamsMap.OpenStreamSync()
+	return s.OpenStreamSyncContext(context.Background())
+}
+
+func (s *session) OpenStreamSyncContext(ctx context.Context) (Stream, error) {
+	return s.streamsMap.OpenStreamSync(ctx)
 }
 
 func (s *session) OpenUniStream() (SendStream, error) {
@@ -971,7 +1514,28 @@ func (s *session) OpenUniStream() (SendStream, error) {
 }
 
 func (s *session) OpenUniStreamSync() (SendStream, error) {
-	return s.streamsMap.OpenUniStreamSync()
+	return s.OpenUniStreamSyncContext(context.Background())
+}
+
+func (s *session) OpenUniStreamSyncContext(ctx context.Context) (SendStream, error) {
+	return s.streamsMap.OpenUniStreamSync(ctx)
+}
+
+func (s *session) SendMessage(p []byte) error {
+	if !s.config.EnableDatagrams {
+		return errors.New("datagram support disabled")
+	}
+	f := &wire.DatagramFrame{DataLenPresent: true}
+	f.Data = make([]byte, len(p))
+	copy(f.Data, p)
+	return s.datagramQueue.AddAndWait(f)
+}
+
+func (s *session) ReceiveMessage() ([]byte, error) {
+	if !s.config.EnableDatagrams {
+		return nil, errors.New("datagram support disabled")
+	}
+	return s.datagramQueue.Receive()
 }
 
 func (s *session) newStream(id protocol.StreamID) streamI {
@@ -1011,7 +1575,47 @@ func (s *session) newCryptoStream() cryptoStreamI {
 
 func (s *session) sendPublicReset(rejectedPacketNumber protocol.PacketNumber) error {
 	utils.Infof("Sending public reset for connection %x, packet number %d", s.connectionID, rejectedPacketNumber)
-	return s.conn.Write(wire.WritePublicReset(s.connectionID, rejectedPacketNumber, 0))
+	// only the server sends a stateless reset token to the peer
+	var nonceProof uint64
+	if s.perspective == protocol.PerspectiveServer {
+		nonceProof = handshake.NonceProof(s.getStatelessResetToken(s.connectionID))
+	}
+	return s.conn.Write(wire.WritePublicReset(s.connectionID, rejectedPacketNumber, nonceProof), protocol.ECNNon)
+}
+
+// handlePublicReset handles a Public Reset sent by the server.
+// If the server sent us a stateless reset token, the nonce proof must match the token.
+// Otherwise, the Public Reset must be sent for the connection ID we're using.
+func (s *session) handlePublicReset(p *receivedPacket) {
+	pr, err := wire.ParsePublicReset(bytes.NewReader(p.data))
+	if err != nil {
+		utils.Infof("Received a Public Reset. An error occurred parsing the packet: %s", err)
+		return
+	}
+	if len(s.statelessResetTokens) > 0 {
+		token, ok := s.statelessResetTokens[p.header.ConnectionID]
+		if !ok || handshake.NonceProof(token) != pr.Nonce {
+			utils.Infof("Received a Public Reset with an invalid nonce proof. Ignoring.")
+			return
+		}
+	} else if p.header.ConnectionID != s.connectionID {
+		// Without a stateless reset token, the server can't have issued any other connection IDs.
+		utils.Infof("Received a Public Reset for an unknown connection ID %x. Ignoring.", p.header.ConnectionID)
+		return
+	}
+	utils.Infof("Received Public Reset, rejected packet number: %#x.", pr.RejectedPacketNumber)
+	s.closeRemote(qerr.Error(qerr.PublicReset, fmt.Sprintf("Received a Public Reset for packet number %#x", pr.RejectedPacketNumber)))
+}
+
+func (s *session) addStatelessResetToken(connID protocol.ConnectionID, token [16]byte) {
+	if s.statelessResetTokens == nil {
+		s.statelessResetTokens = make(map[protocol.ConnectionID][16]byte)
+	}
+	s.statelessResetTokens[connID] = token
+}
+
+func (s *session) getStatelessResetToken(connID protocol.ConnectionID) [16]byte {
+	return handshake.GetStatelessResetToken(s.config.StatelessResetKey, connID)
 }
 
 // scheduleSending signals that we have data for sending
@@ -1062,7 +1666,12 @@ func (s *session) onHasStreamData(id protocol.StreamID) {
 	s.scheduleSending()
 }
 
+func (s *session) onStreamPriorityChanged(id protocol.StreamID, p StreamPriority) {
+	s.streamFramer.SetStreamPriority(id, p)
+}
+
 func (s *session) onStreamCompleted(id protocol.StreamID) {
+	s.streamFramer.RemoveStream(id)
 	if err := s.streamsMap.DeleteStream(id); err != nil {
 		s.Close(err)
 	}
@@ -1081,6 +1690,26 @@ func (s *session) handshakeStatus() <-chan error {
 	return s.handshakeChan
 }
 
+func (s *session) earlySessionReady() <-chan struct{} {
+	return s.earlySessionReadyChan
+}
+
+// signalEarlySessionReady must only be called from the run loop
+func (s *session) signalEarlySessionReady() {
+	if !s.isEarlySessionReady() {
+		close(s.earlySessionReadyChan)
+	}
+}
+
+func (s *session) isEarlySessionReady() bool {
+	select {
+	case <-s.earlySessionReadyChan:
+		return true
+	default:
+		return false
+	}
+}
+
 func (s *session) getCryptoStream() cryptoStreamI {
 	return s.cryptoStream
 }
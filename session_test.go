@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"runtime/pprof"
@@ -20,6 +21,7 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
 	"github.com/lucas-clemente/quic-go/internal/mocks/ackhandler"
+	"github.com/lucas-clemente/quic-go/internal/mocks/logging"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/testdata"
 	"github.com/lucas-clemente/quic-go/internal/wire"
@@ -29,7 +31,11 @@ import (
 type mockConnection struct {
 	remoteAddr net.Addr
 	localAddr  net.Addr
+	pconn      net.PacketConn
 	written    chan []byte
+	writtenTo  net.Addr     // the address of the last packet written using WriteTo
+	writtenECN protocol.ECN // the ECN codepoint of the last packet written using Write
+	numBatches int          // the number of calls to WriteBatch
 }
 
 func newMockConnection() *mockConnection {
@@ -39,7 +45,8 @@ func newMockConnection() *mockConnection {
 	}
 }
 
-func (m *mockConnection) Write(p []byte) error {
+func (m *mockConnection) Write(p []byte, ecn protocol.ECN) error {
+	m.writtenECN = ecn
 	b := make([]byte, len(p))
 	copy(b, p)
 	select {
@@ -49,17 +56,37 @@ func (m *mockConnection) Write(p []byte) error {
 	}
 	return nil
 }
+func (m *mockConnection) WriteBatch(packets []outgoingPacket) error {
+	m.numBatches++
+	for _, p := range packets {
+		if err := m.Write(p.data, p.ecn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (m *mockConnection) WriteTo(p []byte, addr net.Addr) error {
+	m.writtenTo = addr
+	return m.Write(p, protocol.ECNNon)
+}
+func (m *mockConnection) SupportsECN() bool                  { return false }
+func (m *mockConnection) SupportsPathMTUDiscovery() bool     { return false }
 func (m *mockConnection) Read([]byte) (int, net.Addr, error) { panic("not implemented") }
 
 func (m *mockConnection) SetCurrentRemoteAddr(addr net.Addr) {
 	m.remoteAddr = addr
 }
+func (m *mockConnection) SetPacketConn(pconn net.PacketConn) {
+	m.pconn = pconn
+	m.localAddr = pconn.LocalAddr()
+}
 func (m *mockConnection) LocalAddr() net.Addr  { return m.localAddr }
 func (m *mockConnection) RemoteAddr() net.Addr { return m.remoteAddr }
 func (*mockConnection) Close() error           { panic("not implemented") }
 
 type mockUnpacker struct {
 	unpackErr error
+	encLevel  protocol.EncryptionLevel
 }
 
 func (m *mockUnpacker) Unpack(headerBinary []byte, hdr *wire.Header, data []byte) (*unpackedPacket, error) {
@@ -67,10 +94,17 @@ func (m *mockUnpacker) Unpack(headerBinary []byte, hdr *wire.Header, data []byte
 		return nil, m.unpackErr
 	}
 	return &unpackedPacket{
-		frames: nil,
+		encryptionLevel: m.encLevel,
+		frames:          nil,
 	}, nil
 }
 
+type nopWriteCloser struct {
+	bytes.Buffer
+}
+
+func (*nopWriteCloser) Close() error { return nil }
+
 func areSessionsRunning() bool {
 	var b bytes.Buffer
 	pprof.Lookup("goroutine").WriteTo(&b, 1)
@@ -85,6 +119,7 @@ var _ = Describe("Session", func() {
 		cryptoSetup   *mockCryptoSetup
 		streamManager *MockStreamManager
 		handshakeChan chan<- struct{}
+		ourParams     *handshake.TransportParameters
 	)
 
 	BeforeEach(func() {
@@ -97,13 +132,14 @@ var _ = Describe("Session", func() {
 			_ net.Addr,
 			_ protocol.VersionNumber,
 			_ *handshake.ServerConfig,
-			_ *handshake.TransportParameters,
+			params *handshake.TransportParameters,
 			_ []protocol.VersionNumber,
 			_ func(net.Addr, *Cookie) bool,
 			_ chan<- handshake.TransportParameters,
 			handshakeChanP chan<- struct{},
 		) (handshake.CryptoSetup, error) {
 			handshakeChan = handshakeChanP
+			ourParams = params
 			return cryptoSetup, nil
 		}
 
@@ -245,16 +281,29 @@ var _ = Describe("Session", func() {
 				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 				sph.EXPECT().ReceivedAck(f, protocol.PacketNumber(42), protocol.EncryptionSecure, gomock.Any())
 				sph.EXPECT().GetLowestPacketNotConfirmedAcked()
+				sph.EXPECT().MaxPacketSize().Return(protocol.InitialPacketSize)
 				sess.sentPacketHandler = sph
 				sess.lastRcvdPacketNumber = 42
 				err := sess.handleAckFrame(f, protocol.EncryptionSecure)
 				Expect(err).ToNot(HaveOccurred())
 			})
 
+			It("increases the packet size when path MTU discovery confirms a larger size", func() {
+				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sph.EXPECT().ReceivedAck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+				sph.EXPECT().GetLowestPacketNotConfirmedAcked()
+				sph.EXPECT().MaxPacketSize().Return(protocol.ByteCount(1400))
+				sess.sentPacketHandler = sph
+				err := sess.handleAckFrame(&wire.AckFrame{LargestAcked: 3, LowestAcked: 2}, protocol.EncryptionForwardSecure)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sess.packer.maxPacketSize).To(Equal(protocol.ByteCount(1400)))
+			})
+
 			It("tells the ReceivedPacketHandler to ignore low ranges", func() {
 				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 				sph.EXPECT().ReceivedAck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
 				sph.EXPECT().GetLowestPacketNotConfirmedAcked().Return(protocol.PacketNumber(0x42))
+				sph.EXPECT().MaxPacketSize().Return(protocol.InitialPacketSize)
 				sess.sentPacketHandler = sph
 				rph := mockackhandler.NewMockReceivedPacketHandler(mockCtrl)
 				rph.EXPECT().IgnoreBelow(protocol.PacketNumber(0x42))
@@ -425,9 +474,11 @@ var _ = Describe("Session", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("errors on GOAWAY frames", func() {
-			err := sess.handleFrames([]wire.Frame{&wire.GoawayFrame{}}, protocol.EncryptionUnspecified)
-			Expect(err).To(MatchError("unimplemented: handling GOAWAY frames"))
+		It("passes GOAWAY frames to the streams map", func() {
+			f := &wire.GoawayFrame{ErrorCode: qerr.PeerGoingAway, LastGoodStream: 7}
+			streamManager.EXPECT().HandleGoawayFrame(f)
+			err := sess.handleFrames([]wire.Frame{f}, protocol.EncryptionUnspecified)
+			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("handles STOP_WAITING frames", func() {
@@ -452,6 +503,11 @@ var _ = Describe("Session", func() {
 		})
 	})
 
+	It("sends the stateless reset token derived from the stateless reset key", func() {
+		token := handshake.GetStatelessResetToken(sess.config.StatelessResetKey, sess.connectionID)
+		Expect(ourParams.StatelessResetToken).To(Equal(&token))
+	})
+
 	It("tells its versions", func() {
 		sess.version = 4242
 		Expect(sess.GetVersion()).To(Equal(protocol.VersionNumber(4242)))
@@ -459,12 +515,126 @@ var _ = Describe("Session", func() {
 
 	It("accepts new streams", func() {
 		mstr := NewMockStreamI(mockCtrl)
-		streamManager.EXPECT().AcceptStream().Return(mstr, nil)
+		streamManager.EXPECT().AcceptStream(context.Background()).Return(mstr, nil)
 		str, err := sess.AcceptStream()
 		Expect(err).ToNot(HaveOccurred())
 		Expect(str).To(Equal(mstr))
 	})
 
+	Context("going away", func() {
+		It("sends a GOAWAY frame", func() {
+			streamManager.EXPECT().GoAway().Return(protocol.StreamID(7), nil)
+			Expect(sess.GoAway(qerr.Error(qerr.PeerGoingAway, "restarting"))).To(Succeed())
+			Expect(sess.packer.controlFrames).To(Equal([]wire.Frame{&wire.GoawayFrame{
+				ErrorCode:      qerr.PeerGoingAway,
+				LastGoodStream: 7,
+				ReasonPhrase:   "restarting",
+			}}))
+		})
+
+		It("uses PeerGoingAway if no error is given", func() {
+			streamManager.EXPECT().GoAway().Return(protocol.StreamID(7), nil)
+			Expect(sess.GoAway(nil)).To(Succeed())
+			Expect(sess.packer.controlFrames).To(HaveLen(1))
+			Expect(sess.packer.controlFrames[0].(*wire.GoawayFrame).ErrorCode).To(Equal(qerr.PeerGoingAway))
+		})
+
+		It("returns the error from the streams map", func() {
+			testErr := errors.New("GOAWAY not supported")
+			streamManager.EXPECT().GoAway().Return(protocol.StreamID(0), testErr)
+			Expect(sess.GoAway(nil)).To(MatchError(testErr))
+			Expect(sess.packer.controlFrames).To(BeEmpty())
+		})
+
+		It("errors when the session is already closed", func() {
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+				close(done)
+			}()
+			Expect(sess.Close(nil)).To(Succeed())
+			Eventually(done).Should(BeClosed())
+			Expect(sess.GoAway(nil)).To(MatchError("session already closed"))
+		})
+	})
+
+	It("errors when receiving a RETIRE_CONNECTION_ID frame in a gQUIC session", func() {
+		// gQUIC sessions don't have a session runner
+		Expect(sess.connIDGenerator).To(BeNil())
+		err := sess.handleFrames([]wire.Frame{&wire.RetireConnectionIDFrame{SequenceNumber: 0}}, protocol.EncryptionForwardSecure)
+		Expect(err).To(MatchError(qerr.Error(qerr.InvalidFrameData, "received RETIRE_CONNECTION_ID frame, but multiple connection IDs are not supported")))
+	})
+
+	Context("connection IDs", func() {
+		var runner *MockSessionRunner
+
+		BeforeEach(func() {
+			runner = NewMockSessionRunner(mockCtrl)
+			sess.runner = runner
+			sess.connIDGenerator = newConnIDGenerator(sess.connectionID, sess, runner, sess.getStatelessResetToken, sess.packer.QueueControlFrame)
+		})
+
+		It("issues new connection IDs when the handshake completes, and retires them when the session is closed", func() {
+			runner.EXPECT().addConnectionID(gomock.Any(), sess).Times(protocol.MaxActiveConnectionIDs - 1)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+				close(done)
+			}()
+			close(handshakeChan)
+			Eventually(sess.handshakeStatus()).Should(BeClosed())
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			runner.EXPECT().retireConnectionID(gomock.Any()).Times(protocol.MaxActiveConnectionIDs - 1)
+			Expect(sess.Close(nil)).To(Succeed())
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("retires connection IDs when receiving a RETIRE_CONNECTION_ID frame", func() {
+			runner.EXPECT().addConnectionID(gomock.Any(), sess).Times(protocol.MaxActiveConnectionIDs - 1)
+			Expect(sess.connIDGenerator.IssueConnectionIDs()).To(Succeed())
+			connID := sess.connIDGenerator.activeConnIDs[1]
+			runner.EXPECT().retireConnectionID(connID)
+			runner.EXPECT().addConnectionID(gomock.Any(), sess)
+			err := sess.handleFrames([]wire.Frame{&wire.RetireConnectionIDFrame{SequenceNumber: 1}}, protocol.EncryptionForwardSecure)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("errors when receiving a NEW_CONNECTION_ID frame", func() {
+			err := sess.handleFrames([]wire.Frame{&wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: 0xdeadbeef}}, protocol.EncryptionForwardSecure)
+			Expect(err).To(MatchError(qerr.Error(qerr.InvalidFrameData, "received NEW_CONNECTION_ID frame from the client")))
+		})
+
+		It("switches to the connection ID used by the client", func() {
+			sess.unpacker = &mockUnpacker{encLevel: protocol.EncryptionForwardSecure}
+			err := sess.handlePacketImpl(&receivedPacket{
+				remoteAddr: mconn.remoteAddr,
+				header:     &wire.Header{ConnectionID: 0xdeadbeef, PacketNumber: 1337, PacketNumberLen: protocol.PacketNumberLen6},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.packer.connectionID).To(Equal(protocol.ConnectionID(0xdeadbeef)))
+			// reordered packets don't make us switch back
+			err = sess.handlePacketImpl(&receivedPacket{
+				remoteAddr: mconn.remoteAddr,
+				header:     &wire.Header{ConnectionID: sess.connectionID, PacketNumber: 1336, PacketNumberLen: protocol.PacketNumberLen6},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.packer.connectionID).To(Equal(protocol.ConnectionID(0xdeadbeef)))
+		})
+
+		It("doesn't switch the connection ID for packets that are not forward-secure", func() {
+			sess.unpacker = &mockUnpacker{}
+			err := sess.handlePacketImpl(&receivedPacket{
+				remoteAddr: mconn.remoteAddr,
+				header:     &wire.Header{ConnectionID: 0xdeadbeef, PacketNumber: 1337, PacketNumberLen: protocol.PacketNumberLen6},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.packer.connectionID).To(Equal(sess.connectionID))
+		})
+	})
+
 	Context("closing", func() {
 		BeforeEach(func() {
 			Eventually(areSessionsRunning).Should(BeFalse())
@@ -511,6 +681,14 @@ var _ = Describe("Session", func() {
 			Expect(mconn.written).To(BeEmpty()) // no CONNECTION_CLOSE or PUBLIC_RESET sent
 		})
 
+		It("closes the streams with Err0RTTRejected if the session could already be used", func() {
+			close(sess.earlySessionReadyChan)
+			streamManager.EXPECT().CloseWithError(Err0RTTRejected)
+			sess.Close(handshake.ErrCloseSessionForRetry)
+			Eventually(areSessionsRunning).Should(BeFalse())
+			Expect(mconn.written).To(BeEmpty())
+		})
+
 		It("sends a Public Reset if the client is initiating the head-of-line blocking experiment", func() {
 			streamManager.EXPECT().CloseWithError(gomock.Any())
 			sess.Close(handshake.ErrHOLExperiment)
@@ -562,10 +740,10 @@ var _ = Describe("Session", func() {
 		It("informs the ReceivedPacketHandler", func() {
 			now := time.Now().Add(time.Hour)
 			rph := mockackhandler.NewMockReceivedPacketHandler(mockCtrl)
-			rph.EXPECT().ReceivedPacket(protocol.PacketNumber(5), now, false)
+			rph.EXPECT().ReceivedPacket(protocol.PacketNumber(5), protocol.ECNCE, now, false)
 			sess.receivedPacketHandler = rph
 			hdr.PacketNumber = 5
-			err := sess.handlePacketImpl(&receivedPacket{header: hdr, rcvTime: now})
+			err := sess.handlePacketImpl(&receivedPacket{header: hdr, rcvTime: now, ecn: protocol.ECNCE})
 			Expect(err).ToNot(HaveOccurred())
 		})
 
@@ -618,7 +796,93 @@ var _ = Describe("Session", func() {
 		})
 
 		Context("updating the remote address", func() {
-			It("doesn't support connection migration", func() {
+			It("switches to a new remote address when receiving a forward-secure packet", func() {
+				sess.unpacker.(*mockUnpacker).encLevel = protocol.EncryptionForwardSecure
+				sess.rttStats.UpdateRTT(time.Second, 0, time.Now())
+				remoteIP := &net.IPAddr{IP: net.IPv4(192, 168, 0, 100)}
+				err := sess.handlePacketImpl(&receivedPacket{
+					remoteAddr: remoteIP,
+					header:     &wire.Header{PacketNumber: 1337},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sess.conn.(*mockConnection).remoteAddr).To(Equal(remoteIP))
+				Expect(sess.rttStats.SmoothedRTT()).To(BeZero())
+			})
+
+			It("doesn't switch to a new remote address for reordered packets", func() {
+				sess.unpacker.(*mockUnpacker).encLevel = protocol.EncryptionForwardSecure
+				origAddr := sess.conn.(*mockConnection).remoteAddr
+				err := sess.handlePacketImpl(&receivedPacket{
+					remoteAddr: origAddr,
+					header:     &wire.Header{PacketNumber: 1337, PacketNumberLen: protocol.PacketNumberLen6},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				err = sess.handlePacketImpl(&receivedPacket{
+					remoteAddr: &net.IPAddr{IP: net.IPv4(192, 168, 0, 100)},
+					header:     &wire.Header{PacketNumber: 1336, PacketNumberLen: protocol.PacketNumberLen6},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sess.conn.(*mockConnection).remoteAddr).To(Equal(origAddr))
+			})
+
+			Context("validating the path, for IETF QUIC", func() {
+				var remoteIP *net.IPAddr
+
+				BeforeEach(func() {
+					sess.version = versionIETFFrames
+					sess.packer.version = versionIETFFrames
+					sess.unpacker.(*mockUnpacker).encLevel = protocol.EncryptionForwardSecure
+					sess.packer.cryptoSetup = &mockCryptoSetup{encLevelSeal: protocol.EncryptionForwardSecure}
+					remoteIP = &net.IPAddr{IP: net.IPv4(192, 168, 0, 100)}
+				})
+
+				receivePacket := func(pn protocol.PacketNumber) {
+					err := sess.handlePacketImpl(&receivedPacket{
+						remoteAddr: remoteIP,
+						header:     &wire.Header{PacketNumber: pn, PacketNumberLen: protocol.PacketNumberLen6},
+						data:       make([]byte, 100),
+					})
+					Expect(err).ToNot(HaveOccurred())
+				}
+
+				It("sends a PATH_CHALLENGE to the new address, and switches when receiving the PATH_RESPONSE", func() {
+					origAddr := sess.conn.(*mockConnection).remoteAddr
+					receivePacket(1337)
+					Expect(sess.conn.(*mockConnection).remoteAddr).To(Equal(origAddr))
+					Expect(mconn.writtenTo).To(Equal(remoteIP))
+					Expect(mconn.written).To(Receive())
+					Expect(sess.pathValidator.challenges).To(HaveLen(1))
+					err := sess.handleFrames([]wire.Frame{&wire.PathResponseFrame{Data: sess.pathValidator.challenges[0]}}, protocol.EncryptionForwardSecure)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(sess.conn.(*mockConnection).remoteAddr).To(Equal(remoteIP))
+				})
+
+				It("doesn't switch when receiving a PATH_RESPONSE that doesn't match", func() {
+					origAddr := sess.conn.(*mockConnection).remoteAddr
+					receivePacket(1337)
+					err := sess.handleFrames([]wire.Frame{&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}}, protocol.EncryptionForwardSecure)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(sess.conn.(*mockConnection).remoteAddr).To(Equal(origAddr))
+				})
+
+				It("doesn't send a PATH_CHALLENGE for every packet", func() {
+					receivePacket(1337)
+					Expect(mconn.written).To(Receive())
+					receivePacket(1338)
+					Expect(mconn.written).ToNot(Receive())
+				})
+
+				It("respects the anti-amplification limit", func() {
+					err := sess.handlePacketImpl(&receivedPacket{
+						remoteAddr: remoteIP,
+						header:     &wire.Header{PacketNumber: 1337, PacketNumberLen: protocol.PacketNumberLen6},
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(mconn.written).ToNot(Receive())
+				})
+			})
+
+			It("doesn't switch to a new remote address for non-forward-secure packets", func() {
 				origAddr := sess.conn.(*mockConnection).remoteAddr
 				remoteIP := &net.IPAddr{IP: net.IPv4(192, 168, 0, 100)}
 				Expect(origAddr).ToNot(Equal(remoteIP))
@@ -657,7 +921,7 @@ var _ = Describe("Session", func() {
 
 		It("sends ACK frames", func() {
 			packetNumber := protocol.PacketNumber(0x035e)
-			err := sess.receivedPacketHandler.ReceivedPacket(packetNumber, time.Now(), true)
+			err := sess.receivedPacketHandler.ReceivedPacket(packetNumber, protocol.ECNNon, time.Now(), true)
 			Expect(err).ToNot(HaveOccurred())
 			sent, err := sess.sendPacket()
 			Expect(err).NotTo(HaveOccurred())
@@ -666,6 +930,26 @@ var _ = Describe("Session", func() {
 			Expect(mconn.written).To(Receive(ContainSubstring(string([]byte{0x03, 0x5e}))))
 		})
 
+		It("sends MTU probe packets", func() {
+			sess.packer.cryptoSetup = &mockCryptoSetup{encLevelSeal: protocol.EncryptionForwardSecure}
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sph.EXPECT().SendingAllowed().Return(true).AnyTimes()
+			sph.EXPECT().MTUProbeSize(gomock.Any()).Return(protocol.ByteCount(1400))
+			sph.EXPECT().ShouldSendNumPackets().Return(1)
+			sph.EXPECT().GetLeastUnacked().AnyTimes()
+			sph.EXPECT().DequeuePacketForRetransmission()
+			sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
+				Expect(p.IsMTUProbePacket).To(BeTrue())
+				Expect(p.Length).To(Equal(protocol.ByteCount(1400)))
+				Expect(p.ECN).To(Equal(protocol.ECNNon))
+				Expect(p.Frames).To(Equal([]wire.Frame{&wire.PingFrame{}}))
+			})
+			sess.sentPacketHandler = sph
+			Expect(sess.sendPackets()).To(Succeed())
+			Expect(mconn.written).To(Receive(HaveLen(1400)))
+			Expect(mconn.numBatches).To(BeZero())
+		})
+
 		It("adds a MAX_DATA frames", func() {
 			fc := mocks.NewMockConnectionFlowController(mockCtrl)
 			fc.EXPECT().GetWindowUpdate().Return(protocol.ByteCount(0x1337))
@@ -673,6 +957,7 @@ var _ = Describe("Session", func() {
 			sess.connFlowController = fc
 			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 			sph.EXPECT().GetLeastUnacked().AnyTimes()
+			sph.EXPECT().ECNMode().AnyTimes()
 			sph.EXPECT().DequeuePacketForRetransmission()
 			sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
 				Expect(p.Frames).To(Equal([]wire.Frame{
@@ -692,6 +977,7 @@ var _ = Describe("Session", func() {
 			})
 			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 			sph.EXPECT().GetLeastUnacked().AnyTimes()
+			sph.EXPECT().ECNMode().AnyTimes()
 			sph.EXPECT().DequeuePacketForRetransmission()
 			sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
 				Expect(p.Frames).To(ContainElement(&wire.MaxStreamDataFrame{StreamID: 2, ByteOffset: 20}))
@@ -709,6 +995,7 @@ var _ = Describe("Session", func() {
 			sess.connFlowController = fc
 			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 			sph.EXPECT().GetLeastUnacked().AnyTimes()
+			sph.EXPECT().ECNMode().AnyTimes()
 			sph.EXPECT().DequeuePacketForRetransmission()
 			sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
 				Expect(p.Frames).To(Equal([]wire.Frame{
@@ -728,6 +1015,18 @@ var _ = Describe("Session", func() {
 			Expect(mconn.written).To(Receive(ContainSubstring("PRST")))
 		})
 
+		It("sends the nonce proof derived from the stateless reset key in a public reset", func() {
+			sess.config.StatelessResetKey = []byte("foobar")
+			err := sess.sendPublicReset(1)
+			Expect(err).NotTo(HaveOccurred())
+			var data []byte
+			Expect(mconn.written).To(Receive(&data))
+			pr, err := wire.ParsePublicReset(bytes.NewReader(data[9:])) // skip the public header
+			Expect(err).ToNot(HaveOccurred())
+			token := handshake.GetStatelessResetToken([]byte("foobar"), sess.connectionID)
+			Expect(pr.Nonce).To(Equal(handshake.NonceProof(token)))
+		})
+
 		It("informs the SentPacketHandler about sent packets", func() {
 			f := &wire.StreamFrame{
 				StreamID: 5,
@@ -736,6 +1035,7 @@ var _ = Describe("Session", func() {
 			var sentPacket *ackhandler.Packet
 			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 			sph.EXPECT().GetLeastUnacked().AnyTimes()
+			sph.EXPECT().ECNMode().AnyTimes()
 			sph.EXPECT().GetStopWaitingFrame(gomock.Any())
 			sph.EXPECT().DequeuePacketForRetransmission()
 			sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
@@ -756,17 +1056,37 @@ var _ = Describe("Session", func() {
 			Expect(sentPacket.Length).To(BeEquivalentTo(len(<-mconn.written)))
 		})
 
+		It("marks packets with the ECN codepoint", func() {
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sph.EXPECT().GetLeastUnacked().AnyTimes()
+			sph.EXPECT().ECNMode().Return(protocol.ECT0)
+			sph.EXPECT().GetStopWaitingFrame(gomock.Any())
+			sph.EXPECT().DequeuePacketForRetransmission()
+			sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
+				Expect(p.ECN).To(Equal(protocol.ECT0))
+			})
+			sess.sentPacketHandler = sph
+			sess.packer.cryptoSetup = &mockCryptoSetup{encLevelSeal: protocol.EncryptionForwardSecure}
+			sess.streamFramer.AddFrameForRetransmission(&wire.StreamFrame{StreamID: 5, Data: []byte("foobar")})
+			sent, err := sess.sendPacket()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sent).To(BeTrue())
+			Expect(mconn.written).To(HaveLen(1))
+			Expect(mconn.writtenECN).To(Equal(protocol.ECT0))
+		})
+
 		It("doesn't retransmit an initial packet if it already received a response", func() {
 			sess.unpacker = &mockUnpacker{}
 			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 			sph.EXPECT().GetLeastUnacked().AnyTimes()
+			sph.EXPECT().ECNMode().AnyTimes()
 			sph.EXPECT().DequeuePacketForRetransmission().Return(&ackhandler.Packet{
 				PacketNumber: 10,
 				PacketType:   protocol.PacketTypeInitial,
 			})
 			sph.EXPECT().DequeuePacketForRetransmission()
 			rph := mockackhandler.NewMockReceivedPacketHandler(mockCtrl)
-			rph.EXPECT().ReceivedPacket(gomock.Any(), gomock.Any(), gomock.Any())
+			rph.EXPECT().ReceivedPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
 			rph.EXPECT().GetAckFrame()
 			sess.receivedPacketHandler = rph
 			sess.sentPacketHandler = sph
@@ -789,6 +1109,8 @@ var _ = Describe("Session", func() {
 			sph = mockackhandler.NewMockSentPacketHandler(mockCtrl)
 			sph.EXPECT().GetAlarmTimeout().AnyTimes()
 			sph.EXPECT().GetLeastUnacked().AnyTimes()
+			sph.EXPECT().ECNMode().AnyTimes()
+			sph.EXPECT().MTUProbeSize(gomock.Any()).AnyTimes()
 			sph.EXPECT().DequeuePacketForRetransmission().AnyTimes()
 			sess.sentPacketHandler = sph
 			sess.packer.hasSentPacket = true
@@ -867,6 +1189,31 @@ var _ = Describe("Session", func() {
 			// make the go routine return
 			sess.Close(nil)
 			Eventually(done).Should(BeClosed())
+			Expect(mconn.numBatches).To(Equal(1))
+		})
+
+		It("splits large bursts into multiple batches", func() {
+			numPackets := protocol.PacketBatchSize + 2
+			sph.EXPECT().SentPacket(gomock.Any()).Times(numPackets)
+			sph.EXPECT().ShouldSendNumPackets().Return(numPackets)
+			sph.EXPECT().TimeUntilSend().Return(time.Now())
+			sph.EXPECT().TimeUntilSend().Return(time.Now().Add(time.Hour))
+			sph.EXPECT().SendingAllowed().Do(func() {
+				// make sure there's something to send
+				sess.packer.QueueControlFrame(&wire.MaxDataFrame{ByteOffset: 1})
+			}).Return(true).Times(numPackets + 1)
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+				close(done)
+			}()
+			sess.scheduleSending()
+			Eventually(mconn.written).Should(HaveLen(numPackets))
+			// make the go routine return
+			sess.Close(nil)
+			Eventually(done).Should(BeClosed())
+			Expect(mconn.numBatches).To(Equal(2))
 		})
 
 		It("doesn't set a pacing timer when there is no data to send", func() {
@@ -907,6 +1254,7 @@ var _ = Describe("Session", func() {
 			sph.EXPECT().SendingAllowed()
 			sph.EXPECT().GetStopWaitingFrame(false).Return(swf)
 			sph.EXPECT().TimeUntilSend()
+			sph.EXPECT().ECNMode()
 			sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
 				Expect(p.Frames).To(HaveLen(2))
 				Expect(p.Frames[0]).To(BeAssignableToTypeOf(&wire.AckFrame{}))
@@ -914,7 +1262,7 @@ var _ = Describe("Session", func() {
 			})
 			sess.sentPacketHandler = sph
 			sess.packer.packetNumberGenerator.next = 0x1338
-			sess.receivedPacketHandler.ReceivedPacket(1, time.Now(), true)
+			sess.receivedPacketHandler.ReceivedPacket(1, protocol.ECNNon, time.Now(), true)
 			done := make(chan struct{})
 			go func() {
 				defer GinkgoRecover()
@@ -937,13 +1285,14 @@ var _ = Describe("Session", func() {
 			sph.EXPECT().GetAlarmTimeout().AnyTimes()
 			sph.EXPECT().SendingAllowed()
 			sph.EXPECT().TimeUntilSend()
+			sph.EXPECT().ECNMode()
 			sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
 				Expect(p.Frames).To(HaveLen(1))
 				Expect(p.Frames[0]).To(BeAssignableToTypeOf(&wire.AckFrame{}))
 			})
 			sess.sentPacketHandler = sph
 			sess.packer.packetNumberGenerator.next = 0x1338
-			sess.receivedPacketHandler.ReceivedPacket(1, time.Now(), true)
+			sess.receivedPacketHandler.ReceivedPacket(1, protocol.ECNNon, time.Now(), true)
 			go func() {
 				defer GinkgoRecover()
 				sess.run()
@@ -966,6 +1315,7 @@ var _ = Describe("Session", func() {
 			sess.packer.hasSentPacket = true // make sure this is not the first packet the packer sends
 			sph = mockackhandler.NewMockSentPacketHandler(mockCtrl)
 			sph.EXPECT().GetLeastUnacked().AnyTimes()
+			sph.EXPECT().ECNMode().AnyTimes()
 			sess.sentPacketHandler = sph
 			sess.packer.cryptoSetup = &mockCryptoSetup{encLevelSeal: protocol.EncryptionForwardSecure}
 		})
@@ -982,6 +1332,7 @@ var _ = Describe("Session", func() {
 				sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
 					Expect(p.EncryptionLevel).To(Equal(protocol.EncryptionUnencrypted))
 					Expect(p.Frames).To(Equal([]wire.Frame{swf, sf}))
+					Expect(p.IsRetransmission).To(BeTrue())
 				})
 				sent, err := sess.sendPacket()
 				Expect(err).NotTo(HaveOccurred())
@@ -1047,6 +1398,7 @@ var _ = Describe("Session", func() {
 				sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
 					Expect(p.Frames).To(Equal([]wire.Frame{f}))
 					Expect(p.EncryptionLevel).To(Equal(protocol.EncryptionForwardSecure))
+					Expect(p.IsRetransmission).To(BeTrue())
 				})
 				sent, err := sess.sendPacket()
 				Expect(err).NotTo(HaveOccurred())
@@ -1054,6 +1406,19 @@ var _ = Describe("Session", func() {
 				Expect(mconn.written).To(HaveLen(1))
 			})
 
+			It("doesn't mark packets without retransmitted frames as retransmissions", func() {
+				sess.version = versionIETFFrames
+				sess.packer.version = versionIETFFrames
+				sess.packer.QueueControlFrame(&wire.PingFrame{})
+				sph.EXPECT().DequeuePacketForRetransmission()
+				sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
+					Expect(p.IsRetransmission).To(BeFalse())
+				})
+				sent, err := sess.sendPacket()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sent).To(BeTrue())
+			})
+
 			It("sends a STREAM frame from a packet queued for retransmission", func() {
 				f1 := wire.StreamFrame{
 					StreamID: 0x5,
@@ -1155,6 +1520,8 @@ var _ = Describe("Session", func() {
 			sph.EXPECT().DequeuePacketForRetransmission()
 			sph.EXPECT().GetStopWaitingFrame(gomock.Any())
 			sph.EXPECT().ShouldSendNumPackets().Return(1)
+			sph.EXPECT().MTUProbeSize(gomock.Any())
+			sph.EXPECT().ECNMode()
 			sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
 				Expect(p.Frames[0]).To(BeAssignableToTypeOf(&wire.AckFrame{}))
 				Expect(p.Frames[0].(*wire.AckFrame).LargestAcked).To(Equal(protocol.PacketNumber(0x1337)))
@@ -1338,6 +1705,54 @@ var _ = Describe("Session", func() {
 		Eventually(done).Should(BeClosed())
 	})
 
+	It("can be used early after the first handshake event", func() {
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			sess.run()
+			close(done)
+		}()
+		Consistently(sess.earlySessionReady()).ShouldNot(BeClosed())
+		handshakeChan <- struct{}{}
+		Eventually(sess.earlySessionReady()).Should(BeClosed())
+		Expect(sess.HandshakeComplete().Done()).ToNot(BeClosed())
+		// make sure the go routine returns
+		streamManager.EXPECT().CloseWithError(gomock.Any())
+		Expect(sess.Close(nil)).To(Succeed())
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("cancels the HandshakeComplete context when the handshake completes", func() {
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			sess.run()
+			close(done)
+		}()
+		close(handshakeChan)
+		Eventually(sess.HandshakeComplete().Done()).Should(BeClosed())
+		Expect(sess.earlySessionReady()).To(BeClosed())
+		Expect(sess.Context().Done()).ToNot(BeClosed())
+		// make sure the go routine returns
+		streamManager.EXPECT().CloseWithError(gomock.Any())
+		Expect(sess.Close(nil)).To(Succeed())
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("cancels the HandshakeComplete context when the session is closed", func() {
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			sess.run()
+			close(done)
+		}()
+		streamManager.EXPECT().CloseWithError(gomock.Any())
+		Expect(sess.Close(nil)).To(Succeed())
+		Eventually(done).Should(BeClosed())
+		Expect(sess.HandshakeComplete().Done()).To(BeClosed())
+		Expect(sess.earlySessionReady()).ToNot(BeClosed())
+	})
+
 	It("passes errors to the handshakeChan", func() {
 		testErr := errors.New("handshake error")
 		done := make(chan struct{})
@@ -1542,12 +1957,22 @@ var _ = Describe("Session", func() {
 
 		It("opens streams synchronously", func() {
 			mstr := NewMockStreamI(mockCtrl)
-			streamManager.EXPECT().OpenStreamSync().Return(mstr, nil)
+			streamManager.EXPECT().OpenStreamSync(context.Background()).Return(mstr, nil)
 			str, err := sess.OpenStreamSync()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(str).To(Equal(mstr))
 		})
 
+		It("opens streams synchronously, using a context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			mstr := NewMockStreamI(mockCtrl)
+			streamManager.EXPECT().OpenStreamSync(ctx).Return(mstr, nil)
+			str, err := sess.OpenStreamSyncContext(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str).To(Equal(mstr))
+		})
+
 		It("opens unidirectional streams", func() {
 			mstr := NewMockSendStreamI(mockCtrl)
 			streamManager.EXPECT().OpenUniStream().Return(mstr, nil)
@@ -1558,7 +1983,7 @@ var _ = Describe("Session", func() {
 
 		It("opens unidirectional streams synchronously", func() {
 			mstr := NewMockSendStreamI(mockCtrl)
-			streamManager.EXPECT().OpenUniStreamSync().Return(mstr, nil)
+			streamManager.EXPECT().OpenUniStreamSync(context.Background()).Return(mstr, nil)
 			str, err := sess.OpenUniStreamSync()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(str).To(Equal(mstr))
@@ -1566,21 +1991,188 @@ var _ = Describe("Session", func() {
 
 		It("accepts streams", func() {
 			mstr := NewMockStreamI(mockCtrl)
-			streamManager.EXPECT().AcceptStream().Return(mstr, nil)
+			streamManager.EXPECT().AcceptStream(context.Background()).Return(mstr, nil)
 			str, err := sess.AcceptStream()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(str).To(Equal(mstr))
 		})
 
+		It("accepts streams, using a context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			mstr := NewMockStreamI(mockCtrl)
+			streamManager.EXPECT().AcceptStream(ctx).Return(mstr, nil)
+			str, err := sess.AcceptStreamContext(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str).To(Equal(mstr))
+		})
+
 		It("accepts unidirectional streams", func() {
 			mstr := NewMockReceiveStreamI(mockCtrl)
-			streamManager.EXPECT().AcceptUniStream().Return(mstr, nil)
+			streamManager.EXPECT().AcceptUniStream(context.Background()).Return(mstr, nil)
 			str, err := sess.AcceptUniStream()
 			Expect(err).ToNot(HaveOccurred())
 			Expect(str).To(Equal(mstr))
 		})
 	})
 
+	Context("stream priorities", func() {
+		It("passes stream priorities to the stream framer", func() {
+			p := StreamPriority{Urgency: 1, Weight: 3}
+			sess.onStreamPriorityChanged(5, p)
+			Expect(sess.streamFramer.getPriority(5)).To(Equal(p))
+		})
+
+		It("removes the priority when a stream is completed", func() {
+			sess.onStreamPriorityChanged(5, StreamPriority{Urgency: 1, Weight: 3})
+			streamManager.EXPECT().DeleteStream(protocol.StreamID(5))
+			sess.onStreamCompleted(5)
+			Expect(sess.streamFramer.getPriority(5)).To(Equal(DefaultStreamPriority))
+		})
+	})
+
+	It("responds to PATH_CHALLENGE frames", func() {
+		data := [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+		err := sess.handleFrames([]wire.Frame{&wire.PathChallengeFrame{Data: data}}, protocol.EncryptionForwardSecure)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sess.packer.controlFrames).To(Equal([]wire.Frame{&wire.PathResponseFrame{Data: data}}))
+	})
+
+	It("doesn't allow the server to migrate", func() {
+		Expect(sess.MigrateTo(newMockPacketConn())).To(MatchError("only clients can migrate a session"))
+	})
+
+	It("doesn't update keys for gQUIC", func() {
+		Expect(sess.UpdateKeys()).To(MatchError("key updates are only supported for IETF QUIC"))
+	})
+
+	Context("datagrams", func() {
+		It("errors when sending a datagram if datagram support is disabled", func() {
+			Expect(sess.SendMessage([]byte("foobar"))).To(MatchError("datagram support disabled"))
+		})
+
+		It("errors when receiving a datagram if datagram support is disabled", func() {
+			_, err := sess.ReceiveMessage()
+			Expect(err).To(MatchError("datagram support disabled"))
+		})
+
+		It("errors on DATAGRAM frames if datagram support is disabled", func() {
+			err := sess.handleFrames([]wire.Frame{&wire.DatagramFrame{Data: []byte("foobar")}}, protocol.EncryptionForwardSecure)
+			Expect(err).To(MatchError(qerr.Error(qerr.InvalidFrameData, "received a DATAGRAM frame, but datagram support is disabled")))
+		})
+
+		Context("with datagram support enabled", func() {
+			BeforeEach(func() {
+				sess.config.EnableDatagrams = true
+			})
+
+			It("receives DATAGRAM frames", func() {
+				err := sess.handleFrames([]wire.Frame{&wire.DatagramFrame{Data: []byte("foobar")}}, protocol.EncryptionForwardSecure)
+				Expect(err).ToNot(HaveOccurred())
+				data, err := sess.ReceiveMessage()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("foobar")))
+			})
+
+			It("errors on DATAGRAM frames that are larger than the maximum DATAGRAM frame size", func() {
+				f := &wire.DatagramFrame{DataLenPresent: true, Data: make([]byte, protocol.MaxReceivePacketSize)}
+				err := sess.handleFrames([]wire.Frame{f}, protocol.EncryptionForwardSecure)
+				Expect(err).To(MatchError(qerr.Error(qerr.InvalidFrameData, fmt.Sprintf("received a DATAGRAM frame larger than the maximum DATAGRAM frame size (%d bytes, maximum %d bytes)", f.Length(sess.version), protocol.MaxReceivePacketSize))))
+			})
+
+			It("errors when sending if the peer doesn't support datagrams", func() {
+				streamManager.EXPECT().UpdateLimits(gomock.Any())
+				sess.processTransportParameters(&handshake.TransportParameters{})
+				Expect(sess.SendMessage([]byte("foobar"))).To(MatchError(errDatagramsNotNegotiated))
+			})
+
+			It("queues a DATAGRAM frame for sending", func() {
+				streamManager.EXPECT().UpdateLimits(gomock.Any())
+				sess.processTransportParameters(&handshake.TransportParameters{MaxDatagramFrameSize: protocol.MaxReceivePacketSize})
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					Expect(sess.SendMessage([]byte("foobar"))).To(Succeed())
+				}()
+				Eventually(func() *wire.DatagramFrame { return sess.datagramQueue.Peek() }).Should(Equal(&wire.DatagramFrame{
+					DataLenPresent: true,
+					Data:           []byte("foobar"),
+				}))
+				Consistently(done).ShouldNot(BeClosed())
+				sess.datagramQueue.Pop()
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("unblocks ReceiveMessage when the session is closed", func() {
+				streamManager.EXPECT().CloseWithError(gomock.Any())
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					_, err := sess.ReceiveMessage()
+					Expect(err).To(MatchError(qerr.Error(qerr.PeerGoingAway, "")))
+				}()
+				Consistently(done).ShouldNot(BeClosed())
+				go func() {
+					defer GinkgoRecover()
+					sess.run()
+				}()
+				sess.Close(nil)
+				Eventually(done).Should(BeClosed())
+			})
+		})
+	})
+
+	Context("ACK frequency", func() {
+		BeforeEach(func() {
+			sess.version = versionIETFFrames
+			sess.minAckDelay = protocol.MinAckDelay
+		})
+
+		It("handles ACK_FREQUENCY frames", func() {
+			rph := mockackhandler.NewMockReceivedPacketHandler(mockCtrl)
+			sess.receivedPacketHandler = rph
+			f := &wire.AckFrequencyFrame{SequenceNumber: 1, PacketTolerance: 20, UpdateMaxAckDelay: 50 * time.Millisecond}
+			rph.EXPECT().SetAckFrequency(f)
+			err := sess.handleFrames([]wire.Frame{f}, protocol.EncryptionForwardSecure)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("rejects ACK_FREQUENCY frames that request a max ACK delay below the min_ack_delay", func() {
+			f := &wire.AckFrequencyFrame{PacketTolerance: 2, UpdateMaxAckDelay: 999 * time.Microsecond}
+			err := sess.handleFrames([]wire.Frame{f}, protocol.EncryptionForwardSecure)
+			Expect(err).To(MatchError(qerr.Error(qerr.InvalidFrameData, "invalid max ACK delay in ACK_FREQUENCY frame: 999µs")))
+		})
+
+		It("rejects ACK_FREQUENCY frames if we didn't send a min_ack_delay", func() {
+			sess.minAckDelay = 0
+			f := &wire.AckFrequencyFrame{PacketTolerance: 2, UpdateMaxAckDelay: 50 * time.Millisecond}
+			err := sess.handleFrames([]wire.Frame{f}, protocol.EncryptionForwardSecure)
+			Expect(err).To(MatchError(qerr.Error(qerr.InvalidFrameData, "received ACK_FREQUENCY frame, but the ACK frequency extension wasn't negotiated")))
+		})
+
+		It("rejects ACK_FREQUENCY frames in gQUIC", func() {
+			sess.version = versionGQUICFrames
+			f := &wire.AckFrequencyFrame{PacketTolerance: 2, UpdateMaxAckDelay: 50 * time.Millisecond}
+			err := sess.handleFrames([]wire.Frame{f}, protocol.EncryptionForwardSecure)
+			Expect(err).To(MatchError(qerr.Error(qerr.InvalidFrameData, "received ACK_FREQUENCY frame, but the ACK frequency extension wasn't negotiated")))
+		})
+
+		It("uses the peer's max_ack_delay", func() {
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sess.sentPacketHandler = sph
+			streamManager.EXPECT().UpdateLimits(gomock.Any())
+			sph.EXPECT().SetPeerMaxAckDelay(42 * time.Millisecond)
+			sess.processTransportParameters(&handshake.TransportParameters{MaxAckDelay: 42 * time.Millisecond})
+		})
+
+		It("announces the max ACK delay, but no min ACK delay in gQUIC", func() {
+			Expect(ourParams.MaxAckDelay).To(Equal(protocol.AckSendDelay))
+			Expect(ourParams.MinAckDelay).To(BeZero())
+		})
+	})
+
 	Context("ignoring errors", func() {
 		It("ignores duplicate acks", func() {
 			sess.sentPacketHandler.SentPacket(&ackhandler.Packet{
@@ -1609,6 +2201,196 @@ var _ = Describe("Session", func() {
 		mconn.remoteAddr = addr
 		Expect(sess.RemoteAddr()).To(Equal(addr))
 	})
+	Context("connection statistics", func() {
+		It("counts sent packets", func() {
+			sess.packer.hasSentPacket = true
+			err := sess.receivedPacketHandler.ReceivedPacket(1, protocol.ECNNon, time.Now(), true)
+			Expect(err).ToNot(HaveOccurred())
+			sent, err := sess.sendPacket()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sent).To(BeTrue())
+			stats := sess.getConnectionStats()
+			Expect(stats.PacketsSent).To(BeEquivalentTo(1))
+			Expect(stats.BytesSent).To(BeEquivalentTo(len(<-mconn.written)))
+		})
+
+		It("counts received packets", func() {
+			sess.unpacker = &mockUnpacker{}
+			hdr := &wire.Header{PacketNumber: 5, PacketNumberLen: protocol.PacketNumberLen6, Raw: []byte("header")}
+			err := sess.handlePacketImpl(&receivedPacket{header: hdr, data: []byte("foobar")})
+			Expect(err).ToNot(HaveOccurred())
+			stats := sess.getConnectionStats()
+			Expect(stats.PacketsReceived).To(BeEquivalentTo(1))
+			Expect(stats.BytesReceived).To(BeEquivalentTo(12))
+		})
+
+		It("doesn't count packets that can't be decrypted", func() {
+			sess.unpacker = &mockUnpacker{unpackErr: qerr.Error(qerr.DecryptionFailure, "")}
+			hdr := &wire.Header{PacketNumber: 5, PacketNumberLen: protocol.PacketNumberLen6}
+			err := sess.handlePacketImpl(&receivedPacket{header: hdr, data: []byte("foobar")})
+			Expect(err).To(HaveOccurred())
+			Expect(sess.getConnectionStats().PacketsReceived).To(BeZero())
+		})
+
+		It("reports the RTT and the congestion state", func() {
+			sess.rttStats.UpdateRTT(50*time.Millisecond, 0, time.Now())
+			sess.packer.SetSpinBit(true)
+			stats := sess.getConnectionStats()
+			Expect(stats.MinRTT).To(Equal(50 * time.Millisecond))
+			Expect(stats.LatestRTT).To(Equal(50 * time.Millisecond))
+			Expect(stats.SmoothedRTT).To(Equal(50 * time.Millisecond))
+			Expect(stats.MeanDeviation).To(Equal(25 * time.Millisecond))
+			Expect(stats.CongestionWindow).ToNot(BeZero())
+			Expect(stats.SpinBit).To(BeTrue())
+		})
+
+		It("gets the statistics from the run loop", func() {
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sph.EXPECT().GetAlarmTimeout().AnyTimes()
+			sph.EXPECT().TimeUntilSend().AnyTimes()
+			sph.EXPECT().SendingAllowed().AnyTimes()
+			sph.EXPECT().GetStats().Return(ackhandler.Stats{
+				CongestionWindow:     1000,
+				BytesInFlight:        500,
+				PacketsLost:          3,
+				PacketsRetransmitted: 4,
+			}).Times(2)
+			sess.sentPacketHandler = sph
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+			}()
+			stats := sess.ConnectionStats()
+			Expect(stats.CongestionWindow).To(BeEquivalentTo(1000))
+			Expect(stats.BytesInFlight).To(BeEquivalentTo(500))
+			Expect(stats.PacketsLost).To(BeEquivalentTo(3))
+			Expect(stats.PacketsRetransmitted).To(BeEquivalentTo(4))
+			// make sure the session can be queried after it was closed
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			sph.EXPECT().GetLeastUnacked().AnyTimes()
+			sph.EXPECT().ECNMode().AnyTimes()
+			sess.Close(nil)
+			Expect(sess.ConnectionStats().PacketsLost).To(BeEquivalentTo(3))
+		})
+	})
+
+	It("uses the congestion controller from the config", func() {
+		cong := mocks.NewMockSendAlgorithm(mockCtrl)
+		var rttStats *RTTStats
+		conf := populateServerConfig(&Config{
+			CongestionControl: func(r *RTTStats) SendAlgorithm {
+				rttStats = r
+				return cong
+			},
+		})
+		pSess, err := newSession(mconn, protocol.Version39, 0x1337, scfg, nil, conf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rttStats).To(Equal(pSess.(*session).rttStats))
+		cong.EXPECT().GetCongestionWindow().Return(protocol.ByteCount(1234))
+		Expect(pSess.(*session).getConnectionStats().CongestionWindow).To(BeEquivalentTo(1234))
+	})
+
+	Context("tracing", func() {
+		var tracer *mocklogging.MockConnectionTracer
+
+		BeforeEach(func() {
+			tracer = mocklogging.NewMockConnectionTracer(mockCtrl)
+			sess.tracer = tracer
+		})
+
+		It("creates a tracer for new sessions", func() {
+			t := mocklogging.NewMockTracer(mockCtrl)
+			t.EXPECT().TracerForConnection(protocol.PerspectiveServer, protocol.ConnectionID(0x1337)).Return(tracer)
+			conf := populateServerConfig(&Config{Tracer: t})
+			pSess, err := newSession(mconn, protocol.Version39, 0x1337, scfg, nil, conf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pSess.(*session).tracer).To(Equal(tracer))
+		})
+
+		It("doesn't trace the session if the tracer returns nil", func() {
+			t := mocklogging.NewMockTracer(mockCtrl)
+			t.EXPECT().TracerForConnection(gomock.Any(), gomock.Any())
+			conf := populateServerConfig(&Config{Tracer: t})
+			pSess, err := newSession(mconn, protocol.Version39, 0x1337, scfg, nil, conf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pSess.(*session).tracer).To(BeNil())
+		})
+
+		It("writes a qlog trace if GetLogWriter returns a writer", func() {
+			var connID protocol.ConnectionID
+			w := &nopWriteCloser{}
+			conf := populateServerConfig(&Config{
+				GetLogWriter: func(c ConnectionID) io.WriteCloser {
+					connID = c
+					return w
+				},
+			})
+			pSess, err := newSession(mconn, protocol.Version39, 0x1337, scfg, nil, conf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(connID).To(Equal(protocol.ConnectionID(0x1337)))
+			Expect(pSess.(*session).tracer).ToNot(BeNil())
+			pSess.(*session).tracer.Close()
+			Expect(w.String()).To(ContainSubstring("qlog_version"))
+		})
+
+		It("uses both the Tracer and the qlog writer", func() {
+			t := mocklogging.NewMockTracer(mockCtrl)
+			t.EXPECT().TracerForConnection(gomock.Any(), gomock.Any()).Return(tracer)
+			conf := populateServerConfig(&Config{
+				Tracer:       t,
+				GetLogWriter: func(ConnectionID) io.WriteCloser { return &nopWriteCloser{} },
+			})
+			pSess, err := newSession(mconn, protocol.Version39, 0x1337, scfg, nil, conf)
+			Expect(err).ToNot(HaveOccurred())
+			tr := pSess.(*session).tracer
+			Expect(tr).ToNot(BeNil())
+			Expect(tr).ToNot(Equal(tracer))
+			tracer.EXPECT().UpdatedCongestionWindow(protocol.ByteCount(1000))
+			tr.UpdatedCongestionWindow(1000)
+		})
+
+		It("traces sent packets", func() {
+			sess.packer.hasSentPacket = true
+			err := sess.receivedPacketHandler.ReceivedPacket(1, protocol.ECNNon, time.Now(), true)
+			Expect(err).ToNot(HaveOccurred())
+			var size protocol.ByteCount
+			tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(func(hdr *wire.Header, s protocol.ByteCount, _ protocol.EncryptionLevel, frames []wire.Frame) {
+				Expect(hdr.PacketNumber).To(Equal(protocol.PacketNumber(1)))
+				Expect(frames).To(HaveLen(1))
+				Expect(frames[0]).To(BeAssignableToTypeOf(&wire.AckFrame{}))
+				size = s
+			})
+			sent, err := sess.sendPacket()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sent).To(BeTrue())
+			Expect(size).To(BeEquivalentTo(len(<-mconn.written)))
+		})
+
+		It("traces received packets", func() {
+			sess.unpacker = &mockUnpacker{}
+			hdr := &wire.Header{PacketNumber: 5, PacketNumberLen: protocol.PacketNumberLen6, Raw: []byte("header")}
+			tracer.EXPECT().ReceivedPacket(hdr, protocol.ByteCount(12), gomock.Any(), gomock.Any())
+			err := sess.handlePacketImpl(&receivedPacket{header: hdr, data: []byte("foobar")})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("traces when the session is closed", func() {
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+				close(done)
+			}()
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			gomock.InOrder(
+				tracer.EXPECT().ClosedConnection(qerr.Error(qerr.PeerGoingAway, ""), false),
+				tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()),
+				tracer.EXPECT().Close(),
+			)
+			sess.Close(nil)
+			Eventually(done).Should(BeClosed())
+		})
+	})
 })
 
 var _ = Describe("Client Session", func() {
@@ -1630,6 +2412,8 @@ var _ = Describe("Client Session", func() {
 			_ protocol.ConnectionID,
 			_ protocol.VersionNumber,
 			_ *tls.Config,
+			_ handshake.ClientSessionCache,
+			_ string,
 			_ *handshake.TransportParameters,
 			_ chan<- handshake.TransportParameters,
 			handshakeChanP chan<- struct{},
@@ -1647,7 +2431,7 @@ var _ = Describe("Client Session", func() {
 			protocol.Version39,
 			0,
 			nil,
-			populateClientConfig(&Config{}),
+			populateClientConfig(&Config{}, false),
 			protocol.VersionWhatever,
 			nil,
 		)
@@ -1675,6 +2459,238 @@ var _ = Describe("Client Session", func() {
 		Eventually(done).Should(BeClosed())
 	})
 
+	Context("migrating", func() {
+		It("doesn't migrate before the handshake completed", func() {
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+				close(done)
+			}()
+			err := sess.MigrateTo(newMockPacketConn())
+			Expect(err).To(MatchError("can't migrate a session before the handshake completed"))
+			Expect(sess.Close(nil)).To(Succeed())
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("migrates to a new packet conn", func() {
+			sess.packer.hasSentPacket = true
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+				close(done)
+			}()
+			close(handshakeChan)
+			Eventually(mconn.written).Should(Receive())
+			sess.rttStats.UpdateRTT(time.Second, 0, time.Now())
+			pconn := newMockPacketConn()
+			pconn.addr = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1234}
+			Expect(sess.MigrateTo(pconn)).To(Succeed())
+			Expect(mconn.pconn).To(Equal(pconn))
+			Expect(sess.LocalAddr()).To(Equal(pconn.addr))
+			// a PING is sent on the new path
+			Eventually(mconn.written).Should(Receive())
+			Expect(sess.rttStats.SmoothedRTT()).To(BeZero())
+			Expect(sess.Close(nil)).To(Succeed())
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("switches to a new connection ID", func() {
+			runner := NewMockSessionRunner(mockCtrl)
+			sess.runner = runner
+			sess.connIDManager = newConnIDManager(sess.connectionID, sess, runner, sess.packer.QueueControlFrame)
+			runner.EXPECT().addConnectionID(protocol.ConnectionID(0xdeadbeef), sess)
+			err := sess.handleFrames([]wire.Frame{&wire.NewConnectionIDFrame{SequenceNumber: 1, ConnectionID: 0xdeadbeef}}, protocol.EncryptionForwardSecure)
+			Expect(err).ToNot(HaveOccurred())
+			sess.packer.hasSentPacket = true
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+				close(done)
+			}()
+			close(handshakeChan)
+			Eventually(mconn.written).Should(Receive())
+			runner.EXPECT().retireConnectionID(sess.connectionID)
+			Expect(sess.MigrateTo(newMockPacketConn())).To(Succeed())
+			Expect(sess.packer.connectionID).To(Equal(protocol.ConnectionID(0xdeadbeef)))
+			Expect(sess.Close(nil)).To(Succeed())
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("errors when receiving a RETIRE_CONNECTION_ID frame", func() {
+			err := sess.handleFrames([]wire.Frame{&wire.RetireConnectionIDFrame{SequenceNumber: 1}}, protocol.EncryptionForwardSecure)
+			Expect(err).To(MatchError(qerr.Error(qerr.InvalidFrameData, "received RETIRE_CONNECTION_ID frame from the server")))
+		})
+
+		It("errors when migrating a closed session", func() {
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+				close(done)
+			}()
+			Expect(sess.Close(nil)).To(Succeed())
+			Eventually(done).Should(BeClosed())
+			Expect(sess.MigrateTo(newMockPacketConn())).To(MatchError("session already closed"))
+		})
+	})
+
+	Context("requesting an ACK frequency", func() {
+		It("errors when requesting an invalid ACK frequency", func() {
+			Expect(sess.RequestAckFrequency(0, time.Millisecond, false)).To(MatchError("invalid packet tolerance: 0"))
+			Expect(sess.RequestAckFrequency(2, time.Hour, false)).To(MatchError("max ACK delay too large: 1h0m0s (maximum 16.383s)"))
+		})
+
+		It("doesn't request an ACK frequency before the handshake completed", func() {
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+				close(done)
+			}()
+			err := sess.RequestAckFrequency(2, 10*time.Millisecond, false)
+			Expect(err).To(MatchError("can't request an ACK frequency before the handshake completed"))
+			Expect(sess.Close(nil)).To(Succeed())
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("doesn't request an ACK frequency if the peer doesn't support it", func() {
+			sess.peerParams = &handshake.TransportParameters{}
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+				close(done)
+			}()
+			close(handshakeChan)
+			Eventually(sess.HandshakeComplete().Done()).Should(BeClosed())
+			err := sess.RequestAckFrequency(2, 10*time.Millisecond, false)
+			Expect(err).To(MatchError("peer doesn't support ACK_FREQUENCY frames"))
+			Expect(sess.Close(nil)).To(Succeed())
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("doesn't request an ACK frequency in gQUIC", func() {
+			sess.peerParams = &handshake.TransportParameters{MinAckDelay: 5 * time.Millisecond}
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+				close(done)
+			}()
+			close(handshakeChan)
+			Eventually(sess.HandshakeComplete().Done()).Should(BeClosed())
+			err := sess.RequestAckFrequency(2, 10*time.Millisecond, false)
+			Expect(err).To(MatchError("peer doesn't support ACK_FREQUENCY frames"))
+			Expect(sess.Close(nil)).To(Succeed())
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("sends ACK_FREQUENCY frames", func() {
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sess.sentPacketHandler = sph
+			sess.version = versionIETFFrames
+			sess.handshakeComplete = true
+			sess.peerParams = &handshake.TransportParameters{MinAckDelay: 5 * time.Millisecond}
+			err := sess.sendAckFrequency(&wire.AckFrequencyFrame{PacketTolerance: 2, UpdateMaxAckDelay: 4 * time.Millisecond})
+			Expect(err).To(MatchError("max ACK delay too small: 4ms (peer's minimum 5ms)"))
+			sph.EXPECT().SetPeerMaxAckDelay(10 * time.Millisecond)
+			Expect(sess.sendAckFrequency(&wire.AckFrequencyFrame{PacketTolerance: 2, UpdateMaxAckDelay: 10 * time.Millisecond, IgnoreOrder: true})).To(Succeed())
+			sph.EXPECT().SetPeerMaxAckDelay(20 * time.Millisecond)
+			Expect(sess.sendAckFrequency(&wire.AckFrequencyFrame{PacketTolerance: 10, UpdateMaxAckDelay: 20 * time.Millisecond})).To(Succeed())
+			Expect(sess.packer.controlFrames).To(Equal([]wire.Frame{
+				&wire.AckFrequencyFrame{SequenceNumber: 0, PacketTolerance: 2, UpdateMaxAckDelay: 10 * time.Millisecond, IgnoreOrder: true},
+				&wire.AckFrequencyFrame{SequenceNumber: 1, PacketTolerance: 10, UpdateMaxAckDelay: 20 * time.Millisecond},
+			}))
+			Expect(sess.nextAckFrequencySeq).To(BeEquivalentTo(2))
+		})
+
+		It("errors when requesting an ACK frequency on a closed session", func() {
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				sess.run()
+				close(done)
+			}()
+			Expect(sess.Close(nil)).To(Succeed())
+			Eventually(done).Should(BeClosed())
+			Expect(sess.RequestAckFrequency(2, 10*time.Millisecond, false)).To(MatchError("session already closed"))
+		})
+	})
+
+	Context("Public Reset handling", func() {
+		var token [16]byte
+
+		getPublicReset := func(connID protocol.ConnectionID, nonceProof uint64) *receivedPacket {
+			data := wire.WritePublicReset(connID, 1, nonceProof)
+			return &receivedPacket{
+				header: &wire.Header{ResetFlag: true, ConnectionID: connID},
+				data:   data[9:], // strip the public header
+			}
+		}
+
+		BeforeEach(func() {
+			token = [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+		})
+
+		It("closes the session when receiving a Public Reset, if the server didn't send a stateless reset token", func() {
+			Expect(sess.handlePacketImpl(getPublicReset(sess.connectionID, 42))).To(Succeed())
+			var closeErr closeError
+			Expect(sess.closeChan).To(Receive(&closeErr))
+			Expect(closeErr.remote).To(BeTrue())
+			Expect(closeErr.err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.PublicReset))
+		})
+
+		It("ignores Public Resets for other connection IDs, if the server didn't send a stateless reset token", func() {
+			Expect(sess.handlePacketImpl(getPublicReset(sess.connectionID+1, 42))).To(Succeed())
+			Expect(sess.closeChan).ToNot(Receive())
+		})
+
+		It("closes the session when receiving a Public Reset with the correct nonce proof", func() {
+			sess.processTransportParameters(&handshake.TransportParameters{StatelessResetToken: &token})
+			Expect(sess.handlePacketImpl(getPublicReset(sess.connectionID, handshake.NonceProof(token)))).To(Succeed())
+			var closeErr closeError
+			Expect(sess.closeChan).To(Receive(&closeErr))
+			Expect(closeErr.err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.PublicReset))
+		})
+
+		It("ignores Public Resets with an invalid nonce proof", func() {
+			sess.processTransportParameters(&handshake.TransportParameters{StatelessResetToken: &token})
+			Expect(sess.handlePacketImpl(getPublicReset(sess.connectionID, handshake.NonceProof(token)+1))).To(Succeed())
+			Expect(sess.closeChan).ToNot(Receive())
+		})
+
+		It("ignores Public Resets for connection IDs without a stateless reset token", func() {
+			sess.processTransportParameters(&handshake.TransportParameters{StatelessResetToken: &token})
+			Expect(sess.handlePacketImpl(getPublicReset(sess.connectionID+1, handshake.NonceProof(token)))).To(Succeed())
+			Expect(sess.closeChan).ToNot(Receive())
+		})
+
+		It("uses the stateless reset tokens from NEW_CONNECTION_ID frames", func() {
+			runner := NewMockSessionRunner(mockCtrl)
+			sess.runner = runner
+			sess.connIDManager = newConnIDManager(sess.connectionID, sess, runner, sess.packer.QueueControlFrame)
+			runner.EXPECT().addConnectionID(protocol.ConnectionID(0xdeadbeef), sess)
+			err := sess.handleFrames([]wire.Frame{&wire.NewConnectionIDFrame{
+				SequenceNumber:      1,
+				ConnectionID:        0xdeadbeef,
+				StatelessResetToken: token,
+			}}, protocol.EncryptionForwardSecure)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sess.handlePacketImpl(getPublicReset(0xdeadbeef, handshake.NonceProof(token)))).To(Succeed())
+			Expect(sess.closeChan).To(Receive())
+		})
+
+		It("ignores unparseable Public Resets", func() {
+			p := getPublicReset(sess.connectionID, 42)
+			p.data = p.data[:len(p.data)-5]
+			Expect(sess.handlePacketImpl(p)).To(Succeed())
+			Expect(sess.closeChan).ToNot(Receive())
+		})
+	})
+
 	Context("receiving packets", func() {
 		var hdr *wire.Header
 
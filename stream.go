@@ -20,6 +20,7 @@ type streamSender interface {
 	queueControlFrame(wire.Frame)
 	onHasWindowUpdate(protocol.StreamID)
 	onHasStreamData(protocol.StreamID)
+	onStreamPriorityChanged(protocol.StreamID, StreamPriority)
 	onStreamCompleted(protocol.StreamID)
 }
 
@@ -42,6 +43,10 @@ func (s *uniStreamSender) onHasStreamData(id protocol.StreamID) {
 	s.streamSender.onHasStreamData(id)
 }
 
+func (s *uniStreamSender) onStreamPriorityChanged(id protocol.StreamID, p StreamPriority) {
+	s.streamSender.onStreamPriorityChanged(id, p)
+}
+
 func (s *uniStreamSender) onStreamCompleted(protocol.StreamID) {
 	s.onStreamCompletedImpl()
 }
@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
@@ -18,6 +19,14 @@ type streamFramer struct {
 	activeStreams       map[protocol.StreamID]struct{}
 	streamQueue         []protocol.StreamID
 	hasCryptoStreamData bool
+	// the number of frames a stream has sent in its current turn
+	framesInTurn map[protocol.StreamID]uint8
+
+	// The priorities have their own mutex, since streams are removed when they complete.
+	// This can happen while the streamQueueMutex is held, when popping a STREAM frame.
+	prioritiesMutex sync.Mutex
+	// Streams that are not contained in this map have the DefaultStreamPriority.
+	priorities map[protocol.StreamID]StreamPriority
 }
 
 func newStreamFramer(
@@ -29,12 +38,59 @@ func newStreamFramer(
 		streamGetter:  streamGetter,
 		cryptoStream:  cryptoStream,
 		activeStreams: make(map[protocol.StreamID]struct{}),
+		priorities:    make(map[protocol.StreamID]StreamPriority),
+		framesInTurn:  make(map[protocol.StreamID]uint8),
 		version:       v,
 	}
 }
 
+// AddFrameForRetransmission queues a frame for retransmission.
+// The retransmission queue is sorted by urgency, frames of streams with the same urgency are retransmitted in order.
 func (f *streamFramer) AddFrameForRetransmission(frame *wire.StreamFrame) {
-	f.retransmissionQueue = append(f.retransmissionQueue, frame)
+	f.prioritiesMutex.Lock()
+	urgency := f.getPriorityLocked(frame.StreamID).Urgency
+	i := len(f.retransmissionQueue)
+	// The first frame might already have been partially retransmitted. Never insert a frame in front of it.
+	for i > 1 && f.getPriorityLocked(f.retransmissionQueue[i-1].StreamID).Urgency > urgency {
+		i--
+	}
+	f.prioritiesMutex.Unlock()
+	f.retransmissionQueue = append(f.retransmissionQueue, nil)
+	copy(f.retransmissionQueue[i+1:], f.retransmissionQueue[i:])
+	f.retransmissionQueue[i] = frame
+}
+
+// SetStreamPriority sets the priority of a stream.
+func (f *streamFramer) SetStreamPriority(id protocol.StreamID, p StreamPriority) {
+	if p.Weight == 0 {
+		p.Weight = 1
+	}
+	f.prioritiesMutex.Lock()
+	f.priorities[id] = p
+	f.prioritiesMutex.Unlock()
+}
+
+// RemoveStream is called when a stream is completed.
+// STREAM frames of that stream that are retransmitted later use the default priority.
+func (f *streamFramer) RemoveStream(id protocol.StreamID) {
+	f.prioritiesMutex.Lock()
+	delete(f.priorities, id)
+	f.prioritiesMutex.Unlock()
+}
+
+func (f *streamFramer) getPriority(id protocol.StreamID) StreamPriority {
+	f.prioritiesMutex.Lock()
+	defer f.prioritiesMutex.Unlock()
+	return f.getPriorityLocked(id)
+}
+
+// getPriorityLocked returns the priority of a stream.
+// It must be called with the prioritiesMutex held.
+func (f *streamFramer) getPriorityLocked(id protocol.StreamID) StreamPriority {
+	if p, ok := f.priorities[id]; ok {
+		return p
+	}
+	return DefaultStreamPriority
 }
 
 func (f *streamFramer) AddActiveStream(id protocol.StreamID) {
@@ -107,27 +163,44 @@ func (f *streamFramer) maybePopNormalFrames(maxTotalLen protocol.ByteCount) []*w
 	var currentLen protocol.ByteCount
 	var frames []*wire.StreamFrame
 	f.streamQueueMutex.Lock()
+	// Every stream is asked for data at most once per packet.
+	// Streams with a lower urgency are asked first, streams with the same urgency in the order of the queue.
+	order := make([]protocol.StreamID, len(f.streamQueue))
+	copy(order, f.streamQueue)
+	f.prioritiesMutex.Lock()
+	if len(f.priorities) > 0 {
+		// look up the urgencies once, so that the mutex isn't acquired for every comparison
+		urgencies := make([]uint8, len(order))
+		for i, id := range order {
+			urgencies[i] = f.getPriorityLocked(id).Urgency
+		}
+		sort.Stable(streamsByUrgency{ids: order, urgencies: urgencies})
+	}
+	f.prioritiesMutex.Unlock()
 	// pop STREAM frames, until less than MinStreamFrameSize bytes are left in the packet
-	numActiveStreams := len(f.streamQueue)
-	for i := 0; i < numActiveStreams; i++ {
+	for _, id := range order {
 		if maxTotalLen-currentLen < protocol.MinStreamFrameSize {
 			break
 		}
-		id := f.streamQueue[0]
-		f.streamQueue = f.streamQueue[1:]
 		// This should never return an error. Better check it anyway.
 		// The stream will only be in the streamQueue, if it enqueued itself there.
 		str, err := f.streamGetter.GetOrOpenSendStream(id)
 		// The stream can be nil if it completed after it said it had data.
 		if str == nil || err != nil {
-			delete(f.activeStreams, id)
+			f.removeFromQueue(id)
 			continue
 		}
 		frame, hasMoreData := str.popStreamFrame(maxTotalLen - currentLen)
-		if hasMoreData { // put the stream back in the queue (at the end)
-			f.streamQueue = append(f.streamQueue, id)
+		if hasMoreData {
+			f.framesInTurn[id]++
+			// put the stream back in the queue (at the end), if it used up its turn
+			if f.framesInTurn[id] >= f.getPriority(id).Weight {
+				f.removeFromQueue(id)
+				f.streamQueue = append(f.streamQueue, id)
+				f.activeStreams[id] = struct{}{}
+			}
 		} else { // no more data to send. Stream is not active any more
-			delete(f.activeStreams, id)
+			f.removeFromQueue(id)
 		}
 		if frame == nil { // can happen if the receiveStream was canceled after it said it had data
 			continue
@@ -138,3 +211,29 @@ func (f *streamFramer) maybePopNormalFrames(maxTotalLen protocol.ByteCount) []*w
 	f.streamQueueMutex.Unlock()
 	return frames
 }
+
+// streamsByUrgency sorts a list of streams by their urgency.
+type streamsByUrgency struct {
+	ids       []protocol.StreamID
+	urgencies []uint8
+}
+
+func (s streamsByUrgency) Len() int           { return len(s.ids) }
+func (s streamsByUrgency) Less(i, j int) bool { return s.urgencies[i] < s.urgencies[j] }
+func (s streamsByUrgency) Swap(i, j int) {
+	s.ids[i], s.ids[j] = s.ids[j], s.ids[i]
+	s.urgencies[i], s.urgencies[j] = s.urgencies[j], s.urgencies[i]
+}
+
+// removeFromQueue removes a stream from the stream queue.
+// It must be called with the streamQueueMutex held.
+func (f *streamFramer) removeFromQueue(id protocol.StreamID) {
+	delete(f.activeStreams, id)
+	delete(f.framesInTurn, id)
+	for i, sid := range f.streamQueue {
+		if sid == id {
+			f.streamQueue = append(f.streamQueue[:i], f.streamQueue[i+1:]...)
+			return
+		}
+	}
+}
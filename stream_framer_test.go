@@ -267,6 +267,92 @@ var _ = Describe("Stream Framer", func() {
 			Expect(fs).To(Equal([]*wire.StreamFrame{f}))
 		})
 
+		Context("priorities", func() {
+			It("uses the default priority for streams that didn't set a priority", func() {
+				Expect(framer.getPriority(id1)).To(Equal(DefaultStreamPriority))
+			})
+
+			It("treats a weight of 0 as 1", func() {
+				framer.SetStreamPriority(id1, StreamPriority{Urgency: 2})
+				Expect(framer.getPriority(id1)).To(Equal(StreamPriority{Urgency: 2, Weight: 1}))
+			})
+
+			It("uses the default priority after a stream was removed", func() {
+				framer.SetStreamPriority(id1, StreamPriority{Urgency: 2, Weight: 4})
+				framer.RemoveStream(id1)
+				Expect(framer.getPriority(id1)).To(Equal(DefaultStreamPriority))
+			})
+
+			It("removes streams that complete while their STREAM frame is popped", func() {
+				framer.SetStreamPriority(id1, StreamPriority{Urgency: 2})
+				streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil)
+				f := &wire.StreamFrame{StreamID: id1, Data: []byte("foobar"), FinBit: true}
+				stream1.EXPECT().popStreamFrame(gomock.Any()).DoAndReturn(func(protocol.ByteCount) (*wire.StreamFrame, bool) {
+					// the session removes the stream when sending the FIN completes it
+					framer.RemoveStream(id1)
+					return f, false
+				})
+				framer.AddActiveStream(id1)
+				Expect(framer.PopStreamFrames(1000)).To(Equal([]*wire.StreamFrame{f}))
+				Expect(framer.getPriority(id1)).To(Equal(DefaultStreamPriority))
+			})
+
+			It("serves streams with a lower urgency first", func() {
+				framer.SetStreamPriority(id2, StreamPriority{Urgency: 1})
+				streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil)
+				streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil)
+				f1 := &wire.StreamFrame{StreamID: id1, Data: []byte("foobar")}
+				f2 := &wire.StreamFrame{StreamID: id2, Data: []byte("raboof")}
+				gomock.InOrder(
+					stream2.EXPECT().popStreamFrame(gomock.Any()).Return(f2, false),
+					stream1.EXPECT().popStreamFrame(gomock.Any()).Return(f1, false),
+				)
+				framer.AddActiveStream(id1)
+				framer.AddActiveStream(id2)
+				Expect(framer.PopStreamFrames(1000)).To(Equal([]*wire.StreamFrame{f2, f1}))
+			})
+
+			It("doesn't serve streams with a higher urgency, as long as more urgent streams fill the packets", func() {
+				framer.SetStreamPriority(id2, StreamPriority{Urgency: 1})
+				streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil).Times(3)
+				f := &wire.StreamFrame{StreamID: id2, Data: []byte("foobar")}
+				stream2.EXPECT().popStreamFrame(gomock.Any()).Return(f, true).Times(3)
+				// don't EXPECT any calls to stream1.popStreamFrame
+				framer.AddActiveStream(id1)
+				framer.AddActiveStream(id2)
+				for i := 0; i < 3; i++ {
+					Expect(framer.PopStreamFrames(protocol.MinStreamFrameSize)).To(Equal([]*wire.StreamFrame{f}))
+				}
+			})
+
+			It("serves streams with the same urgency according to their weights", func() {
+				framer.SetStreamPriority(id1, StreamPriority{Urgency: 3, Weight: 2})
+				streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil).AnyTimes()
+				streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil).AnyTimes()
+				f1 := &wire.StreamFrame{StreamID: id1, Data: []byte("foobar")}
+				f2 := &wire.StreamFrame{StreamID: id2, Data: []byte("raboof")}
+				stream1.EXPECT().popStreamFrame(gomock.Any()).Return(f1, true).AnyTimes()
+				stream2.EXPECT().popStreamFrame(gomock.Any()).Return(f2, true).AnyTimes()
+				framer.AddActiveStream(id1)
+				framer.AddActiveStream(id2)
+				var frames []*wire.StreamFrame
+				for i := 0; i < 6; i++ {
+					frames = append(frames, framer.PopStreamFrames(protocol.MinStreamFrameSize)...)
+				}
+				Expect(frames).To(Equal([]*wire.StreamFrame{f1, f1, f2, f1, f1, f2}))
+			})
+
+			It("retransmits frames of streams with a lower urgency first", func() {
+				framer.SetStreamPriority(retransmittedFrame2.StreamID, StreamPriority{Urgency: 1})
+				f1 := &wire.StreamFrame{StreamID: retransmittedFrame1.StreamID, Data: []byte("foobar")}
+				framer.AddFrameForRetransmission(retransmittedFrame1)
+				framer.AddFrameForRetransmission(f1)
+				framer.AddFrameForRetransmission(retransmittedFrame2)
+				// the first frame might already have been partially retransmitted, so it stays at the front
+				Expect(framer.PopStreamFrames(1000)).To(Equal([]*wire.StreamFrame{retransmittedFrame1, retransmittedFrame2, f1}))
+			})
+		})
+
 		Context("splitting of frames", func() {
 			It("splits a frame", func() {
 				framer.AddFrameForRetransmission(&wire.StreamFrame{Data: make([]byte, 600)})
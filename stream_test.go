@@ -189,6 +189,12 @@ var _ = Describe("Stream", func() {
 		})
 	})
 
+	It("tells the sender when the priority is changed", func() {
+		p := StreamPriority{Urgency: 1, Weight: 5}
+		mockSender.EXPECT().onStreamPriorityChanged(streamID, p)
+		str.SetPriority(p)
+	})
+
 	Context("completing", func() {
 		It("is not completed when only the receive side is completed", func() {
 			// don't EXPECT a call to mockSender.onStreamCompleted()
@@ -1,6 +1,8 @@
 package quic
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/lucas-clemente/quic-go/internal/flowcontrol"
@@ -124,24 +126,24 @@ func (m *streamsMap) OpenStream() (Stream, error) {
 	return m.outgoingBidiStreams.OpenStream()
 }
 
-func (m *streamsMap) OpenStreamSync() (Stream, error) {
-	return m.outgoingBidiStreams.OpenStreamSync()
+func (m *streamsMap) OpenStreamSync(ctx context.Context) (Stream, error) {
+	return m.outgoingBidiStreams.OpenStreamSync(ctx)
 }
 
 func (m *streamsMap) OpenUniStream() (SendStream, error) {
 	return m.outgoingUniStreams.OpenStream()
 }
 
-func (m *streamsMap) OpenUniStreamSync() (SendStream, error) {
-	return m.outgoingUniStreams.OpenStreamSync()
+func (m *streamsMap) OpenUniStreamSync(ctx context.Context) (SendStream, error) {
+	return m.outgoingUniStreams.OpenStreamSync(ctx)
 }
 
-func (m *streamsMap) AcceptStream() (Stream, error) {
-	return m.incomingBidiStreams.AcceptStream()
+func (m *streamsMap) AcceptStream(ctx context.Context) (Stream, error) {
+	return m.incomingBidiStreams.AcceptStream(ctx)
 }
 
-func (m *streamsMap) AcceptUniStream() (ReceiveStream, error) {
-	return m.incomingUniStreams.AcceptStream()
+func (m *streamsMap) AcceptUniStream(ctx context.Context) (ReceiveStream, error) {
+	return m.incomingUniStreams.AcceptStream(ctx)
 }
 
 func (m *streamsMap) DeleteStream(id protocol.StreamID) error {
@@ -205,6 +207,16 @@ func (m *streamsMap) HandleMaxStreamIDFrame(f *wire.MaxStreamIDFrame) error {
 	}
 }
 
+// GoAway is not supported, since IETF QUIC doesn't have GOAWAY frames
+func (m *streamsMap) GoAway() (protocol.StreamID, error) {
+	return 0, errors.New("IETF QUIC doesn't have GOAWAY frames")
+}
+
+// should never be called, since GOAWAY frames can only be unpacked for gQUIC
+func (m *streamsMap) HandleGoawayFrame(*wire.GoawayFrame) error {
+	return errors.New("IETF QUIC doesn't have GOAWAY frames")
+}
+
 func (m *streamsMap) UpdateLimits(p *handshake.TransportParameters) {
 	m.outgoingBidiStreams.SetMaxStream(p.MaxBidiStreamID)
 	m.outgoingUniStreams.SetMaxStream(p.MaxUniStreamID)
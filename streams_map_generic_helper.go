@@ -0,0 +1,35 @@
+package quic
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cheekybits/genny/generic"
+)
+
+// In the auto-generated streams maps, we need to be able to close the streams.
+// Therefore, extend the generic.Type with the stream close method.
+// This definition must be in a file that Genny doesn't process.
+type item interface {
+	generic.Type
+	closeForShutdown(error)
+}
+
+// wakeOnCancel wakes up the goroutines waiting on the cond when the context is canceled.
+// The returned function must be called when the caller stops waiting.
+func wakeOnCancel(ctx context.Context, cond *sync.Cond) func() {
+	if ctx.Done() == nil { // the context can never be canceled
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			cond.L.Lock()
+			cond.Broadcast()
+			cond.L.Unlock()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
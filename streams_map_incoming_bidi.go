@@ -5,6 +5,7 @@
 package quic
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -48,10 +49,13 @@ func newIncomingBidiStreamsMap(
 	return m
 }
 
-func (m *incomingBidiStreamsMap) AcceptStream() (streamI, error) {
+func (m *incomingBidiStreamsMap) AcceptStream(ctx context.Context) (streamI, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	stopWaking := wakeOnCancel(ctx, &m.cond)
+	defer stopWaking()
+
 	var str streamI
 	for {
 		var ok bool
@@ -63,6 +67,9 @@ func (m *incomingBidiStreamsMap) AcceptStream() (streamI, error) {
 			break
 		}
 		m.cond.Wait()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 	}
 	m.nextStream += 4
 	return str, nil
@@ -123,6 +130,9 @@ func (m *incomingBidiStreamsMap) DeleteStream(id protocol.StreamID) error {
 func (m *incomingBidiStreamsMap) CloseWithError(err error) {
 	m.mutex.Lock()
 	m.closeErr = err
+	for _, str := range m.streams {
+		str.closeForShutdown(err)
+	}
 	m.mutex.Unlock()
 	m.cond.Broadcast()
 }
@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -46,10 +47,13 @@ func newIncomingItemsMap(
 	return m
 }
 
-func (m *incomingItemsMap) AcceptStream() (item, error) {
+func (m *incomingItemsMap) AcceptStream(ctx context.Context) (item, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	stopWaking := wakeOnCancel(ctx, &m.cond)
+	defer stopWaking()
+
 	var str item
 	for {
 		var ok bool
@@ -61,6 +65,9 @@ func (m *incomingItemsMap) AcceptStream() (item, error) {
 			break
 		}
 		m.cond.Wait()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 	}
 	m.nextStream += 4
 	return str, nil
@@ -121,6 +128,9 @@ func (m *incomingItemsMap) DeleteStream(id protocol.StreamID) error {
 func (m *incomingItemsMap) CloseWithError(err error) {
 	m.mutex.Lock()
 	m.closeErr = err
+	for _, str := range m.streams {
+		str.closeForShutdown(err)
+	}
 	m.mutex.Unlock()
 	m.cond.Broadcast()
 }
@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -30,7 +31,7 @@ var _ = Describe("Streams Map (outgoing)", func() {
 		newItemCounter = 0
 		newItem = func(id protocol.StreamID) item {
 			newItemCounter++
-			return id
+			return &mockGenericStream{id: id}
 		}
 		mockSender = NewMockStreamSender(mockCtrl)
 		m = newIncomingItemsMap(firstNewStream, initialMaxStream, maxNumStreams, mockSender.queueControlFrame, newItem)
@@ -55,18 +56,18 @@ var _ = Describe("Streams Map (outgoing)", func() {
 	It("accepts streams in the right order", func() {
 		_, err := m.GetOrOpenStream(firstNewStream + 4) // open stream 20 and 24
 		Expect(err).ToNot(HaveOccurred())
-		str, err := m.AcceptStream()
+		str, err := m.AcceptStream(context.Background())
 		Expect(err).ToNot(HaveOccurred())
-		Expect(str).To(Equal(firstNewStream))
-		str, err = m.AcceptStream()
+		Expect(str).To(Equal(&mockGenericStream{id: firstNewStream}))
+		str, err = m.AcceptStream(context.Background())
 		Expect(err).ToNot(HaveOccurred())
-		Expect(str).To(Equal(firstNewStream + 4))
+		Expect(str).To(Equal(&mockGenericStream{id: firstNewStream + 4}))
 	})
 
 	It("allows opening the maximum stream ID", func() {
 		str, err := m.GetOrOpenStream(initialMaxStream)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(str).To(Equal(initialMaxStream))
+		Expect(str).To(Equal(&mockGenericStream{id: initialMaxStream}))
 	})
 
 	It("errors when trying to get a stream ID higher than the maximum", func() {
@@ -78,15 +79,15 @@ var _ = Describe("Streams Map (outgoing)", func() {
 		strChan := make(chan item)
 		go func() {
 			defer GinkgoRecover()
-			str, err := m.AcceptStream()
+			str, err := m.AcceptStream(context.Background())
 			Expect(err).ToNot(HaveOccurred())
 			strChan <- str
 		}()
 		Consistently(strChan).ShouldNot(Receive())
 		str, err := m.GetOrOpenStream(firstNewStream)
 		Expect(err).ToNot(HaveOccurred())
-		Expect(str).To(Equal(firstNewStream))
-		Eventually(strChan).Should(Receive(Equal(firstNewStream)))
+		Expect(str).To(Equal(&mockGenericStream{id: firstNewStream}))
+		Eventually(strChan).Should(Receive(Equal(&mockGenericStream{id: firstNewStream})))
 	})
 
 	It("unblocks AcceptStream when it is closed", func() {
@@ -94,7 +95,7 @@ var _ = Describe("Streams Map (outgoing)", func() {
 		done := make(chan struct{})
 		go func() {
 			defer GinkgoRecover()
-			_, err := m.AcceptStream()
+			_, err := m.AcceptStream(context.Background())
 			Expect(err).To(MatchError(testErr))
 			close(done)
 		}()
@@ -103,13 +104,46 @@ var _ = Describe("Streams Map (outgoing)", func() {
 		Eventually(done).Should(BeClosed())
 	})
 
+	It("unblocks AcceptStream when the context is canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			_, err := m.AcceptStream(ctx)
+			Expect(err).To(MatchError(context.Canceled))
+			close(done)
+		}()
+		Consistently(done).ShouldNot(BeClosed())
+		cancel()
+		Eventually(done).Should(BeClosed())
+		// the stream is returned by the next call to AcceptStream
+		_, err := m.GetOrOpenStream(firstNewStream)
+		Expect(err).ToNot(HaveOccurred())
+		str, err := m.AcceptStream(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(str).To(Equal(&mockGenericStream{id: firstNewStream}))
+	})
+
 	It("errors AcceptStream immediately if it is closed", func() {
 		testErr := errors.New("test error")
 		m.CloseWithError(testErr)
-		_, err := m.AcceptStream()
+		_, err := m.AcceptStream(context.Background())
 		Expect(err).To(MatchError(testErr))
 	})
 
+	It("closes all streams when CloseWithError is called", func() {
+		str1, err := m.GetOrOpenStream(20)
+		Expect(err).ToNot(HaveOccurred())
+		str2, err := m.GetOrOpenStream(24)
+		Expect(err).ToNot(HaveOccurred())
+		testErr := errors.New("test err")
+		m.CloseWithError(testErr)
+		Expect(str1.(*mockGenericStream).closed).To(BeTrue())
+		Expect(str1.(*mockGenericStream).closeErr).To(MatchError(testErr))
+		Expect(str2.(*mockGenericStream).closed).To(BeTrue())
+		Expect(str2.(*mockGenericStream).closeErr).To(MatchError(testErr))
+	})
+
 	It("deletes streams", func() {
 		mockSender.EXPECT().queueControlFrame(gomock.Any())
 		_, err := m.GetOrOpenStream(20)
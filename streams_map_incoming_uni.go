@@ -5,6 +5,7 @@
 package quic
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -48,10 +49,13 @@ func newIncomingUniStreamsMap(
 	return m
 }
 
-func (m *incomingUniStreamsMap) AcceptStream() (receiveStreamI, error) {
+func (m *incomingUniStreamsMap) AcceptStream(ctx context.Context) (receiveStreamI, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	stopWaking := wakeOnCancel(ctx, &m.cond)
+	defer stopWaking()
+
 	var str receiveStreamI
 	for {
 		var ok bool
@@ -63,6 +67,9 @@ func (m *incomingUniStreamsMap) AcceptStream() (receiveStreamI, error) {
 			break
 		}
 		m.cond.Wait()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 	}
 	m.nextStream += 4
 	return str, nil
@@ -123,6 +130,9 @@ func (m *incomingUniStreamsMap) DeleteStream(id protocol.StreamID) error {
 func (m *incomingUniStreamsMap) CloseWithError(err error) {
 	m.mutex.Lock()
 	m.closeErr = err
+	for _, str := range m.streams {
+		str.closeForShutdown(err)
+	}
 	m.mutex.Unlock()
 	m.cond.Broadcast()
 }
@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -27,6 +28,11 @@ type streamsMapLegacy struct {
 	closeErr           error
 	nextStreamToAccept protocol.StreamID
 
+	// goawaySent is set when we sent a GOAWAY frame. New streams opened by the peer are ignored.
+	goawaySent bool
+	// goawayErr is set when we received a GOAWAY frame. We don't open any new streams.
+	goawayErr error
+
 	newStream func(protocol.StreamID) streamI
 
 	numOutgoingStreams uint32
@@ -115,6 +121,11 @@ func (m *streamsMapLegacy) getOrOpenStream(id protocol.StreamID) (streamI, error
 	if id <= m.highestStreamOpenedByPeer { // this is a peer-initiated stream that doesn't exist anymore. Must have been closed already
 		return nil, nil
 	}
+	// The peer opened this stream before it received our GOAWAY frame.
+	// It will close the stream when it processes the GOAWAY.
+	if m.goawaySent {
+		return nil, nil
+	}
 
 	for sid := m.highestStreamOpenedByPeer + 2; sid <= id; sid += 2 {
 		if _, err := m.openRemoteStream(sid); err != nil {
@@ -147,6 +158,9 @@ func (m *streamsMapLegacy) openRemoteStream(id protocol.StreamID) (streamI, erro
 }
 
 func (m *streamsMapLegacy) openStreamImpl() (streamI, error) {
+	if m.goawayErr != nil {
+		return nil, m.goawayErr
+	}
 	if m.numOutgoingStreams >= m.maxOutgoingStreams {
 		return nil, qerr.TooManyOpenStreams
 	}
@@ -168,10 +182,13 @@ func (m *streamsMapLegacy) OpenStream() (Stream, error) {
 	return m.openStreamImpl()
 }
 
-func (m *streamsMapLegacy) OpenStreamSync() (Stream, error) {
+func (m *streamsMapLegacy) OpenStreamSync(ctx context.Context) (Stream, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	stopWaking := wakeOnCancel(ctx, &m.openStreamOrErrCond)
+	defer stopWaking()
+
 	for {
 		if m.closeErr != nil {
 			return nil, m.closeErr
@@ -184,6 +201,9 @@ func (m *streamsMapLegacy) OpenStreamSync() (Stream, error) {
 			return nil, err
 		}
 		m.openStreamOrErrCond.Wait()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 	}
 }
 
@@ -191,15 +211,17 @@ func (m *streamsMapLegacy) OpenUniStream() (SendStream, error) {
 	return nil, errors.New("gQUIC doesn't support unidirectional streams")
 }
 
-func (m *streamsMapLegacy) OpenUniStreamSync() (SendStream, error) {
+func (m *streamsMapLegacy) OpenUniStreamSync(context.Context) (SendStream, error) {
 	return nil, errors.New("gQUIC doesn't support unidirectional streams")
 }
 
 // AcceptStream returns the next stream opened by the peer
 // it blocks until a new stream is opened
-func (m *streamsMapLegacy) AcceptStream() (Stream, error) {
+func (m *streamsMapLegacy) AcceptStream(ctx context.Context) (Stream, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
+	stopWaking := wakeOnCancel(ctx, &m.nextStreamOrErrCond)
+	defer stopWaking()
 	var str streamI
 	for {
 		var ok bool
@@ -211,12 +233,15 @@ func (m *streamsMapLegacy) AcceptStream() (Stream, error) {
 			break
 		}
 		m.nextStreamOrErrCond.Wait()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 	}
 	m.nextStreamToAccept += 2
 	return str, nil
 }
 
-func (m *streamsMapLegacy) AcceptUniStream() (ReceiveStream, error) {
+func (m *streamsMapLegacy) AcceptUniStream(context.Context) (ReceiveStream, error) {
 	return nil, errors.New("gQUIC doesn't support unidirectional streams")
 }
 
@@ -271,6 +296,30 @@ func (m *streamsMapLegacy) UpdateLimits(params *handshake.TransportParameters) {
 	m.openStreamOrErrCond.Broadcast()
 }
 
+// GoAway stops accepting new streams opened by the peer.
+// It returns the ID of the highest stream opened by the peer, which is sent in the GOAWAY frame.
+func (m *streamsMapLegacy) GoAway() (protocol.StreamID, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.goawaySent = true
+	return m.highestStreamOpenedByPeer, nil
+}
+
+// HandleGoawayFrame handles a GOAWAY frame sent by the peer.
+// No new streams are opened. Streams that the peer won't process any more are closed, all other streams continue.
+func (m *streamsMapLegacy) HandleGoawayFrame(f *wire.GoawayFrame) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.goawayErr = qerr.Error(f.ErrorCode, f.ReasonPhrase)
+	for id, str := range m.streams {
+		if m.streamInitiatedBy(id) == m.perspective && id > f.LastGoodStream {
+			str.closeForShutdown(m.goawayErr)
+		}
+	}
+	m.openStreamOrErrCond.Broadcast()
+	return nil
+}
+
 // should never be called, since MAX_STREAM_ID frames can only be unpacked for IETF QUIC
 func (m *streamsMapLegacy) HandleMaxStreamIDFrame(f *wire.MaxStreamIDFrame) error {
 	return errors.New("gQUIC doesn't have MAX_STREAM_ID frames")
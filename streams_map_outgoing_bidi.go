@@ -5,6 +5,7 @@
 package quic
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -51,10 +52,13 @@ func (m *outgoingBidiStreamsMap) OpenStream() (streamI, error) {
 	return m.openStreamImpl()
 }
 
-func (m *outgoingBidiStreamsMap) OpenStreamSync() (streamI, error) {
+func (m *outgoingBidiStreamsMap) OpenStreamSync(ctx context.Context) (streamI, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
+	stopWaking := wakeOnCancel(ctx, &m.cond)
+	defer stopWaking()
+
 	for {
 		str, err := m.openStreamImpl()
 		if err == nil {
@@ -64,6 +68,9 @@ func (m *outgoingBidiStreamsMap) OpenStreamSync() (streamI, error) {
 			return nil, err
 		}
 		m.cond.Wait()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 	}
 }
 
@@ -118,6 +125,9 @@ func (m *outgoingBidiStreamsMap) SetMaxStream(id protocol.StreamID) {
 func (m *outgoingBidiStreamsMap) CloseWithError(err error) {
 	m.mutex.Lock()
 	m.closeErr = err
+	for _, str := range m.streams {
+		str.closeForShutdown(err)
+	}
 	m.cond.Broadcast()
 	m.mutex.Unlock()
 }
@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"context"
 	"errors"
 
 	"github.com/golang/mock/gomock"
@@ -11,6 +12,18 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+type mockGenericStream struct {
+	id protocol.StreamID
+
+	closed   bool
+	closeErr error
+}
+
+func (s *mockGenericStream) closeForShutdown(err error) {
+	s.closed = true
+	s.closeErr = err
+}
+
 var _ = Describe("Streams Map (outgoing)", func() {
 	const firstNewStream protocol.StreamID = 10
 	var (
@@ -21,7 +34,7 @@ var _ = Describe("Streams Map (outgoing)", func() {
 
 	BeforeEach(func() {
 		newItem = func(id protocol.StreamID) item {
-			return id
+			return &mockGenericStream{id: id}
 		}
 		mockSender = NewMockStreamSender(mockCtrl)
 		m = newOutgoingItemsMap(firstNewStream, newItem, mockSender.queueControlFrame)
@@ -35,10 +48,10 @@ var _ = Describe("Streams Map (outgoing)", func() {
 		It("opens streams", func() {
 			str, err := m.OpenStream()
 			Expect(err).ToNot(HaveOccurred())
-			Expect(str).To(Equal(firstNewStream))
+			Expect(str).To(Equal(&mockGenericStream{id: firstNewStream}))
 			str, err = m.OpenStream()
 			Expect(err).ToNot(HaveOccurred())
-			Expect(str).To(Equal(firstNewStream + 4))
+			Expect(str).To(Equal(&mockGenericStream{id: firstNewStream + 4}))
 		})
 
 		It("doesn't open streams after it has been closed", func() {
@@ -48,12 +61,25 @@ var _ = Describe("Streams Map (outgoing)", func() {
 			Expect(err).To(MatchError(testErr))
 		})
 
+		It("closes all streams when CloseWithError is called", func() {
+			str1, err := m.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			str2, err := m.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			testErr := errors.New("test err")
+			m.CloseWithError(testErr)
+			Expect(str1.(*mockGenericStream).closed).To(BeTrue())
+			Expect(str1.(*mockGenericStream).closeErr).To(MatchError(testErr))
+			Expect(str2.(*mockGenericStream).closed).To(BeTrue())
+			Expect(str2.(*mockGenericStream).closeErr).To(MatchError(testErr))
+		})
+
 		It("gets streams", func() {
 			_, err := m.OpenStream()
 			Expect(err).ToNot(HaveOccurred())
 			str, err := m.GetStream(firstNewStream)
 			Expect(err).ToNot(HaveOccurred())
-			Expect(str).To(Equal(firstNewStream))
+			Expect(str).To(Equal(&mockGenericStream{id: firstNewStream}))
 		})
 
 		It("errors when trying to get a stream that has not yet been opened", func() {
@@ -98,9 +124,9 @@ var _ = Describe("Streams Map (outgoing)", func() {
 			done := make(chan struct{})
 			go func() {
 				defer GinkgoRecover()
-				str, err := m.OpenStreamSync()
+				str, err := m.OpenStreamSync(context.Background())
 				Expect(err).ToNot(HaveOccurred())
-				Expect(str).To(Equal(firstNewStream))
+				Expect(str).To(Equal(&mockGenericStream{id: firstNewStream}))
 				close(done)
 			}()
 
@@ -115,7 +141,7 @@ var _ = Describe("Streams Map (outgoing)", func() {
 			done := make(chan struct{})
 			go func() {
 				defer GinkgoRecover()
-				_, err := m.OpenStreamSync()
+				_, err := m.OpenStreamSync(context.Background())
 				Expect(err).To(MatchError(testErr))
 				close(done)
 			}()
@@ -125,12 +151,33 @@ var _ = Describe("Streams Map (outgoing)", func() {
 			Eventually(done).Should(BeClosed())
 		})
 
+		It("stops opening synchronously when the context is canceled", func() {
+			mockSender.EXPECT().queueControlFrame(gomock.Any())
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				_, err := m.OpenStreamSync(ctx)
+				Expect(err).To(MatchError(context.Canceled))
+				close(done)
+			}()
+
+			Consistently(done).ShouldNot(BeClosed())
+			cancel()
+			Eventually(done).Should(BeClosed())
+			// the stream ID wasn't used
+			m.SetMaxStream(firstNewStream)
+			str, err := m.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str).To(Equal(&mockGenericStream{id: firstNewStream}))
+		})
+
 		It("doesn't reduce the stream limit", func() {
 			m.SetMaxStream(firstNewStream)
 			m.SetMaxStream(firstNewStream - 4)
 			str, err := m.OpenStream()
 			Expect(err).ToNot(HaveOccurred())
-			Expect(str).To(Equal(firstNewStream))
+			Expect(str).To(Equal(&mockGenericStream{id: firstNewStream}))
 		})
 
 		It("queues a STREAM_ID_BLOCKED frame if no stream can be opened", func() {
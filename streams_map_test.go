@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -103,7 +104,7 @@ var _ = Describe("Streams Map (for IETF QUIC)", func() {
 				It("accepts bidirectional streams", func() {
 					_, err := m.GetOrOpenReceiveStream(ids.firstIncomingBidiStream)
 					Expect(err).ToNot(HaveOccurred())
-					str, err := m.AcceptStream()
+					str, err := m.AcceptStream(context.Background())
 					Expect(err).ToNot(HaveOccurred())
 					Expect(str).To(BeAssignableToTypeOf(&stream{}))
 					Expect(str.StreamID()).To(Equal(ids.firstIncomingBidiStream))
@@ -112,7 +113,7 @@ var _ = Describe("Streams Map (for IETF QUIC)", func() {
 				It("accepts unidirectional streams", func() {
 					_, err := m.GetOrOpenReceiveStream(ids.firstIncomingUniStream)
 					Expect(err).ToNot(HaveOccurred())
-					str, err := m.AcceptUniStream()
+					str, err := m.AcceptUniStream(context.Background())
 					Expect(err).ToNot(HaveOccurred())
 					Expect(str).To(BeAssignableToTypeOf(&receiveStream{}))
 					Expect(str.StreamID()).To(Equal(ids.firstIncomingUniStream))
@@ -345,6 +346,13 @@ var _ = Describe("Streams Map (for IETF QUIC)", func() {
 				})
 			})
 
+			It("doesn't support GOAWAY", func() {
+				_, err := m.GoAway()
+				Expect(err).To(MatchError("IETF QUIC doesn't have GOAWAY frames"))
+				err = m.HandleGoawayFrame(&wire.GoawayFrame{})
+				Expect(err).To(MatchError("IETF QUIC doesn't have GOAWAY frames"))
+			})
+
 			It("closes", func() {
 				testErr := errors.New("test error")
 				m.CloseWithError(testErr)
@@ -352,9 +360,9 @@ var _ = Describe("Streams Map (for IETF QUIC)", func() {
 				Expect(err).To(MatchError(testErr))
 				_, err = m.OpenUniStream()
 				Expect(err).To(MatchError(testErr))
-				_, err = m.AcceptStream()
+				_, err = m.AcceptStream(context.Background())
 				Expect(err).To(MatchError(testErr))
-				_, err = m.AcceptUniStream()
+				_, err = m.AcceptUniStream(context.Background())
 				Expect(err).To(MatchError(testErr))
 			})
 		})